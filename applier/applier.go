@@ -6,11 +6,14 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/skeema/fs"
 	"github.com/skeema/skeema/linter"
 	"github.com/skeema/tengo"
+	"golang.org/x/sync/errgroup"
 )
 
 // Result stores the overall result of all operations the worker has completed.
@@ -18,12 +21,84 @@ type Result struct {
 	Differences      bool
 	SkipCount        int
 	UnsupportedCount int
+	TimeoutCount     int             // number of targets abandoned due to a timeout
+	ObjectCount      int             // number of objects with generated DDL
+	StatementCount   int             // number of generated statements
+	UnsafeCount      int             // number of generated statements which are destructive, including ones refused for this reason
+	UnsafeCodes      []UnsafeCode    // sorted distinct codes of the destructive statements counted by UnsafeCount
+	SkippedObjects   []SkippedObject // objects excluded due to unsupported features or introspection failures
+	Targets          []TargetResult  // outcome of each target, in order of completion
+	schemaState      SchemaState     // state of the schema on the instance prior to any changes; only set for a single target's result
+}
+
+// TargetResult describes the outcome of a single target.
+type TargetResult struct {
+	Target         string          `json:"target,omitempty"` // identifier of the target spec, if supplied by the targets option
+	Dir            string          `json:"dir"`
+	Instance       string          `json:"instance"`
+	Schema         string          `json:"schema"`
+	Status         string          `json:"status"` // one of "no-differences", "differences", "pushed", "skipped", "unsupported", "timeout", or "error"
+	ObjectCount    int             `json:"objects_changed"`
+	StatementCount int             `json:"statements"`
+	UnsafeCount    int             `json:"unsafe_statements"`
+	UnsafeCodes    []UnsafeCode    `json:"unsafe_codes,omitempty"`
+	SkippedObjects []SkippedObject `json:"skipped_objects,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	SchemaState    SchemaState     `json:"schema_state,omitempty"` // state of the schema prior to any changes: "missing", "empty", or "populated"; blank if it could not be introspected
+}
+
+// newTargetResult returns a TargetResult for t, based on the Result of t alone
+// and any fatal error that occurred while processing it.
+func newTargetResult(t *Target, r Result, err error) TargetResult {
+	tr := TargetResult{
+		Target:         t.specID,
+		Dir:            t.Dir.RelPath(),
+		Schema:         t.SchemaName,
+		ObjectCount:    r.ObjectCount,
+		StatementCount: r.StatementCount,
+		UnsafeCount:    r.UnsafeCount,
+		UnsafeCodes:    r.UnsafeCodes,
+		SkippedObjects: r.SkippedObjects,
+		SchemaState:    r.schemaState,
+	}
+	if t.Instance != nil {
+		tr.Instance = t.Instance.String()
+	}
+	switch {
+	case err != nil:
+		tr.Status, tr.Error = "error", err.Error()
+	case r.TimeoutCount > 0:
+		tr.Status = "timeout"
+	case r.SkipCount > 0:
+		tr.Status = "skipped"
+	case r.UnsupportedCount > 0:
+		tr.Status = "unsupported"
+	case r.Differences && t.dryRun():
+		tr.Status = "differences"
+	case r.Differences:
+		tr.Status = "pushed"
+	default:
+		tr.Status = "no-differences"
+	}
+	return tr
+}
+
+// withTarget returns a copy of r which records the outcome of t.
+func (r Result) withTarget(t *Target, err error) Result {
+	r.Targets = []TargetResult{newTargetResult(t, r, err)}
+	return r
 }
 
 // Summary returns a string reflecting the contents of the result.
 func (r Result) Summary() string {
+	var timeouts string
+	if r.TimeoutCount > 0 {
+		timeouts = fmt.Sprintf("%s timed out", countAndNoun(r.TimeoutCount, "target"))
+	}
 	if r.SkipCount+r.UnsupportedCount == 0 {
-		return ""
+		return timeouts
+	} else if timeouts != "" {
+		timeouts = "; " + timeouts
 	}
 	var plural, reason string
 	if r.SkipCount+r.UnsupportedCount > 1 {
@@ -36,7 +111,7 @@ func (r Result) Summary() string {
 	} else {
 		reason = "unsupported features or error"
 	}
-	return fmt.Sprintf("Skipped %d operation%s due to %s%s", r.SkipCount+r.UnsupportedCount, plural, reason, plural)
+	return fmt.Sprintf("Skipped %d operation%s due to %s%s%s", r.SkipCount+r.UnsupportedCount, plural, reason, plural, timeouts)
 }
 
 // Worker reads TargetGroups from the input channel and performs the appropriate
@@ -44,43 +119,233 @@ func (r Result) Summary() string {
 // TargetGroups to read, it writes its aggregate Result to the output channel.
 // If a fatal error occurs, it will be returned immediately; Worker is meant to
 // be called via an errgroup (see golang.org/x/sync/errgroup).
+//
+// If ctx has a deadline, any target still in progress when the deadline is
+// reached is abandoned before its next statement is executed, and all
+// remaining targets are skipped; these are tracked as timeouts in the Result.
+// Worker does not return until the in-progress target has stopped.
+//
+// If sleep-between-targets is configured, Worker sleeps before each target
+// that follows one where DDL was executed.
 func Worker(ctx context.Context, targetGroups <-chan TargetGroup, results chan<- Result, printer *Printer) error {
+	var executedDDL bool // whether the previous target executed any DDL, for sleep-between-targets
 	for tg := range targetGroups {
+		if passed, err := preflightGroup(ctx, tg, results); err != nil {
+			return err
+		} else if !passed {
+			continue
+		}
 		for _, t := range tg {
-			result, err := applyTarget(t, printer)
+			if ctx.Err() == context.DeadlineExceeded {
+				t.logger().Errorf("Skipping %s %s: run-timeout exceeded", t.Instance, t.SchemaName)
+				results <- Result{TimeoutCount: 1}.withTarget(t, nil)
+				continue
+			}
+			if executedDDL {
+				th, err := throttleForDir(t.Dir)
+				if err != nil {
+					return err
+				} else if err := th.beforeTarget(t); err != nil {
+					return err
+				}
+			}
+			result, err := applyTarget(ctx, t, printer)
 			if err != nil {
 				return err
 			}
 			results <- result
+			executedDDL = result.Differences && !t.dryRun()
 
-			// Exit early if context cancelled
-			select {
-			case <-ctx.Done():
+			// Exit early if context cancelled, unless this was due to its deadline,
+			// in which case remaining targets are tracked as timed out above
+			if ctx.Err() == context.Canceled {
 				return nil
-			default:
 			}
 		}
 	}
 	return nil
 }
 
-func applyTarget(t *Target, printer *Printer) (Result, error) {
+// PrepareTargets generates the DDL for each of targets without printing or
+// executing it, returning the combined Result as of that point, with a
+// TargetResult for each target. When the targets are subsequently processed by
+// Worker, the DDL generated here is printed and executed as-is, without
+// diff'ing again; this permits the total amount of change to be checked before
+// anything is executed. Up to concurrency instances are processed at once. A
+// non-nil error is only returned for fatal errors, in which case the targets
+// should not be processed further.
+func PrepareTargets(targets []*Target, printer *Printer, concurrency int) (Result, error) {
+	groups := TargetGroupChan(targets)
+	var g errgroup.Group
+	var mu sync.Mutex
+	results := make([]Result, 0, len(targets))
+	for n := 0; n < concurrency; n++ {
+		g.Go(func() error {
+			for tg := range groups {
+				for _, t := range tg {
+					prepared, err := prepareTarget(t, printer)
+					if err != nil {
+						return err
+					}
+					t.prepared = prepared
+					result := prepared.result.withTarget(t, nil)
+					if result.Targets[0].Status == "pushed" {
+						result.Targets[0].Status = "differences" // nothing has been executed yet
+					}
+					mu.Lock()
+					results = append(results, result)
+					mu.Unlock()
+				}
+			}
+			return nil
+		})
+	}
+	err := g.Wait()
+
+	// After a fatal error, drain any remaining groups so that the sender exits
+	for range groups {
+	}
+	return SumResults(results), err
+}
+
+// preflightGroup runs preflight checks on the instance of a TargetGroup, unless
+// the targets are only being used for dry-run purposes. If a check fails, a
+// result is sent to results for each target in the group, and passed will be
+// false. A non-nil error is only returned for configuration problems. If ctx is
+// already done, or its deadline is reached while the checks run, no results
+// are sent; passed is true only for a deadline, leaving the caller to handle
+// the targets as timed out. The checks' queries are bounded by query-timeout.
+func preflightGroup(ctx context.Context, tg TargetGroup, results chan<- Result) (passed bool, err error) {
+	if len(tg) == 0 || tg[0].dryRun() {
+		return true, nil
+	} else if ctx.Err() != nil {
+		return ctx.Err() == context.DeadlineExceeded, nil
+	}
+	err = preflightCheck(tg[0])
+	if ctx.Err() != nil {
+		return ctx.Err() == context.DeadlineExceeded, nil
+	} else if err == nil {
+		return true, nil
+	} else if _, ok := err.(ConfigError); ok {
+		return false, err
+	}
+	log.Errorf("Skipping %s: preflight check failed: %s", tg[0].Instance, err)
+	for _, t := range tg {
+		results <- Result{SkipCount: 1}.withTarget(t, nil)
+	}
+	return false, nil
+}
+
+// applyTarget performs the diff/push operation on t, returning a Result which
+// records the outcome of t. If ctx is done before all of t's statements have
+// been executed, the remaining statements are skipped; if this was due to
+// ctx's deadline, t is tracked as timed out. Note that a statement already
+// running on the database server is not killed.
+func applyTarget(ctx context.Context, t *Target, printer *Printer) (Result, error) {
+	result, err := applyTargetStatements(ctx, t, printer)
+	return result.withTarget(t, err), err
+}
+
+func applyTargetStatements(ctx context.Context, t *Target, printer *Printer) (Result, error) {
+	prepared := t.prepared
+	if prepared == nil {
+		var err error
+		if prepared, err = prepareTarget(t, printer); err != nil || prepared.finished {
+			return prepared.result, err
+		}
+	} else if prepared.finished {
+		return prepared.result, nil
+	}
+	return prepared.execute(ctx, t, printer)
+}
+
+// preparedTarget holds the DDL generated for a target, along with the other
+// state needed to execute it.
+type preparedTarget struct {
+	result            Result
+	finished          bool // true if the target was skipped or otherwise finished during preparation
+	ddls              []*DDLStatement
+	schemaFromDir     *tengo.Schema
+	trackingTableName string
+}
+
+// prepareTarget performs every step of the diff/push operation on t prior to
+// printing or executing DDL. The returned value is never nil. If t was finished
+// during preparation, for example because it was skipped, the returned
+// preparedTarget is marked as finished and only its result is meaningful.
+func prepareTarget(t *Target, printer *Printer) (*preparedTarget, error) {
 	var result Result
+	finish := func(err error) (*preparedTarget, error) {
+		return &preparedTarget{result: result, finished: true}, err
+	}
+	if _, err := t.outputPrefix(); err != nil {
+		return finish(ConfigError(err.Error()))
+	} else if _, err := t.statementComment(); err != nil {
+		return finish(ConfigError(err.Error()))
+	}
 
+	start := time.Now()
 	schemaFromInstance, err := t.SchemaFromInstance()
-	if err != nil {
+	if err != nil && t.isQueryTimeout(time.Since(start)) {
+		// The driver reports read timeouts as generic connection errors, so the
+		// elapsed time is used to distinguish timeouts from other failures. A
+		// timed out target does not prevent other targets from proceeding.
+		result.TimeoutCount++
+		t.logger().Errorf("Skipping %s schema %s for %s: query timed out: %s", t.Instance, t.SchemaName, t.Dir, err)
+		return finish(nil)
+	} else if err != nil {
+		// If introspection failed due to one specific object, only this target is
+		// skipped, so that other targets may still proceed
 		result.SkipCount++
-		log.Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
-		return result, err
+		t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+		if obj, ok := introspectionFailure(err); ok {
+			result.SkippedObjects = append(result.SkippedObjects, obj)
+			return finish(nil)
+		}
+		return finish(err)
 	}
 
 	t.logApplyStart()
+	if err := t.checkVariables(); err != nil {
+		if _, ok := err.(ConfigError); ok {
+			return finish(err)
+		}
+		result.SkipCount++
+		t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+		return finish(nil)
+	}
 	schemaFromDir := t.SchemaFromDir()
 
+	// If a previous push was interrupted while replacing an object, report on
+	// how it will be recovered
+	t.recoverJournal(schemaFromInstance, schemaFromDir)
+
+	// Table options and column attributes which tengo doesn't handle itself,
+	// such as AUTOEXTEND_SIZE or SRID, are modeled here so that they can be
+	// diff'ed. If a tracking table is configured, it is excluded from the diff,
+	// and the last recorded push is reported when only diff'ing.
+	schemaFromInstance, hasTrackingTable := t.diffableSchema(schemaFromInstance)
+	schemaFromDir, _ = t.diffableSchema(schemaFromDir)
+	result.schemaState = schemaStateOf(schemaFromInstance)
+	if err := t.checkSchemaState(result.schemaState, schemaFromDir); err != nil {
+		if _, ok := err.(ConfigError); ok {
+			return finish(err)
+		}
+		result.SkipCount++
+		t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+		return finish(nil)
+	}
+	trackingTableName := t.Dir.Config.Get("tracking-table")
+	if hasTrackingTable && t.dryRun() && !t.briefOutput() {
+		if err := t.logLastPush(trackingTableName, schemaFromDir); err != nil {
+			t.logger().Warnf("%s %s: unable to read tracking table %s: %s", t.Instance, t.SchemaName, tengo.EscapeIdentifier(trackingTableName), err)
+		}
+	}
+
 	// Obtain StatementModifiers based on the dir's config
 	mods, err := StatementModifiersForDir(t.Dir)
 	if err != nil {
-		return result, ConfigError(err.Error())
+		return finish(ConfigError(err.Error()))
 	}
 	mods.Flavor = t.Instance.Flavor()
 	if mods.Partitioning == tengo.PartitioningRemove {
@@ -97,17 +362,133 @@ func applyTarget(t *Target, printer *Printer) (Result, error) {
 		}
 	}
 
-	diff := tengo.NewSchemaDiff(schemaFromInstance, schemaFromDir)
+	// With ignore-table-options, differences in the specified table options are
+	// excluded from the diff by treating the instance as already matching the
+	// filesystem. A copy is used, since schemaFromInstance is used again later.
+	// Equivalent TIMESTAMP and DATETIME attributes are also excluded from the
+	// diff, since their representation varies by server version and by the
+	// target's explicit_defaults_for_timestamp setting. This setting is only
+	// queried if needed. Likewise for character sets and collations which only
+	// differ in spelling of the utf8mb3 alias.
+	ignoreOptions := parseIgnoreTableOptions(t.Dir.Config.Get("ignore-table-options"))
+	var explicitDefaults *bool
+	getExplicitDefaults := func() bool {
+		if explicitDefaults == nil {
+			value := t.explicitDefaultsForTimestamp()
+			explicitDefaults = &value
+		}
+		return *explicitDefaults
+	}
+	var creates map[tengo.ObjectKey]*fs.Statement
+	if t.DesiredSchema.LogicalSchema != nil {
+		creates = t.DesiredSchema.LogicalSchema.Creates
+	}
+	normalize := func(from *tengo.Schema) *tengo.Schema {
+		if len(ignoreOptions) > 0 {
+			from = ignoreTableOptions(from, schemaFromDir, ignoreOptions, mods.Flavor)
+		}
+		from = normalizeCharSetAliases(from, schemaFromDir, mods.Flavor)
+		return normalizeTimestamps(from, schemaFromDir, creates, getExplicitDefaults, mods.Flavor)
+	}
+	diffFrom := normalize(schemaFromInstance)
+
+	diff := tengo.NewSchemaDiff(diffFrom, schemaFromDir)
 	if err := VerifyDiff(diff, t); err != nil {
-		return result, err
+		return finish(err)
 	}
 
 	// Build DDLStatements for each ObjectDiff, handling pre-execution errors
 	// accordingly. Also track ObjectKeys for modified objects, for subsequent
-	// use in linting.
-	objDiffs := diff.ObjectDiffs()
+	// use in linting. Modified foreign keys are re-added as soon as possible
+	// after being dropped, and then statements are reordered as needed to
+	// satisfy dependencies between foreign keys and the indexes they require.
+	objDiffs := pairForeignKeyChanges(diff.ObjectDiffs(), schemaFromInstance)
+	if ordered, ok := orderByDependencies(objDiffs, t.SchemaName); ok {
+		objDiffs = ordered
+	} else {
+		t.logger().Warnf("%s %s: circular dependencies between foreign keys and indexes in generated statements; using default statement order", t.Instance, t.SchemaName)
+	}
+
+	// Schema comments aren't handled by tengo, so any change is inserted after
+	// the CREATE DATABASE or ALTER DATABASE, if there is one, or first otherwise
+	if commentDiff, err := t.schemaCommentChange(schemaFromInstance != nil); err != nil {
+		result.SkipCount++
+		t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+		return finish(nil)
+	} else if commentDiff != nil {
+		var pos int
+		if len(objDiffs) > 0 && objDiffs[0].ObjectKey().Type == tengo.ObjectTypeDatabase {
+			pos = 1
+		}
+		objDiffs = append(objDiffs[:pos], append([]tengo.ObjectDiff{commentDiff}, objDiffs[pos:]...)...)
+	}
+
+	// With the since option, only objects affected by changed files are diff'ed
+	objDiffs = t.narrowObjectDiffs(objDiffs)
+
+	// Tables using unsupported features are never dropped automatically
+	objDiffs, skippedDrops := withoutUnsupportedDrops(objDiffs)
+	for _, obj := range skippedDrops {
+		result.Differences = true
+		result.UnsupportedCount++
+		result.SkippedObjects = append(result.SkippedObjects, obj)
+		t.logger().Warnf("Skipping drop of %s %s: %s", obj.Type, tengo.EscapeIdentifier(obj.Name), obj.Reason)
+	}
+
+	// Privileges aren't handled by tengo either. Any changes are made after all
+	// other DDL, since tables must exist before privileges can be granted on
+	// them.
+	grantDiffs, err := t.grantChanges()
+	if _, ok := err.(ConfigError); ok {
+		return finish(err)
+	} else if err != nil {
+		result.SkipCount++
+		t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+		return finish(nil)
+	}
+	objDiffs = append(objDiffs, grantDiffs...)
+	batching, err := t.Dir.Config.GetEnum("ddl-batching", "per-table", "per-clause")
+	if err != nil {
+		return finish(ConfigError(err.Error()))
+	}
+
+	// Manual migrations supersede the generated DDL for the tables they declare.
+	// Their statements are run prior to any other generated DDL, and the
+	// superseded tables are still linted.
+	migrations, err := t.applicableMigrations(schemaFromInstance, schemaFromDir)
+	if err != nil {
+		result.SkipCount++
+		t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+		return finish(nil)
+	} else if len(migrations) > 0 && printer.plan != nil {
+		return finish(ConfigError(fmt.Sprintf("%s: manual migrations cannot be recorded in a plan file", t.Dir)))
+	}
 	ddls := make([]*DDLStatement, 0, len(objDiffs))
 	keys := make([]tengo.ObjectKey, 0, len(objDiffs))
+	if len(migrations) > 0 {
+		var superseded []tengo.ObjectKey
+		objDiffs, superseded = supersededBy(objDiffs, migrations)
+		for _, key := range superseded {
+			t.logger().Infof("%s %s: generated DDL for %s superseded by manual migration", t.Instance, t.SchemaName, key)
+		}
+		keys = append(keys, superseded...)
+		for _, mm := range migrations {
+			migrationDDLs, err := migrationDDL(mm, t)
+			if err != nil {
+				return finish(err)
+			}
+			ddls = append(ddls, migrationDDLs...)
+		}
+		result.Differences = true
+	}
+	// Status of affected tables is looked up in batches up-front, rather than
+	// separately for each table when building its DDLStatement
+	if err := t.prefetchTableStatus(objDiffs); err != nil {
+		if _, ok := err.(ConfigError); ok {
+			return finish(err)
+		}
+		t.logger().Debugf("Unable to prefetch table status for %s %s: %s", t.Instance, t.SchemaName, err)
+	}
 	for _, objDiff := range objDiffs {
 		ddl, err := NewDDLStatement(objDiff, mods, t)
 		if ddl == nil && err == nil {
@@ -115,19 +496,87 @@ func applyTarget(t *Target, printer *Printer) (Result, error) {
 		}
 		result.Differences = true
 		if err == nil {
-			ddls = append(ddls, ddl)
+			if batching == "per-clause" {
+				ddls = append(ddls, ddl.splitClauses(mods)...)
+			} else {
+				ddls = append(ddls, ddl)
+			}
 			keys = append(keys, objDiff.ObjectKey())
+			if changed := rebuildTableOptionChanges(objDiff); len(changed) > 0 {
+				t.logger().Warnf("%s: changing %s requires rebuilding the entire table, which may take a long time for large tables", objDiff.ObjectKey(), strings.Join(changed, ", "))
+			}
 		} else if unsupportedErr, ok := err.(*tengo.UnsupportedDiffError); ok {
 			result.UnsupportedCount++
-			log.Warnf("Skipping %s: unable to generate DDL due to use of unsupported features. Use --debug for more information.", unsupportedErr.ObjectKey)
+			result.SkippedObjects = append(result.SkippedObjects, SkippedObject{
+				Type:   string(unsupportedErr.ObjectKey.Type),
+				Name:   unsupportedErr.ObjectKey.Name,
+				Reason: unsupportedErr.Error(),
+			})
+			t.logger().Warnf("Skipping %s: unable to generate DDL due to use of unsupported features. Use --debug for more information.", unsupportedErr.ObjectKey)
 			DebugLogUnsupportedDiff(unsupportedErr)
 		} else {
+			if use, ok := err.(unsafeStatementError); ok {
+				result.UnsafeCount++
+				result.UnsafeCodes = mergeUnsafeCodes(result.UnsafeCodes, use.codes)
+			}
 			result.SkipCount += len(objDiffs)
-			log.Errorf(err.Error())
+			t.logger().Errorf(err.Error())
 			if len(objDiffs) > 1 {
-				log.Warnf("Skipping %d additional operations for %s %s due to previous error", len(objDiffs)-1, t.Instance, t.SchemaName)
+				t.logger().Warnf("Skipping %d additional operations for %s %s due to previous error", len(objDiffs)-1, t.Instance, t.SchemaName)
 			}
-			return result, nil
+			return finish(nil)
+		}
+	}
+
+	// Foreign keys referencing missing tables are reported separately, since the
+	// filesystem typically matches the broken live definition. Dropping them is
+	// considered unsafe; without --allow-unsafe, the suggested statement is only
+	// logged.
+	for _, bfk := range brokenForeignKeys(schemaFromInstance, schemaFromDir) {
+		if !t.includesObject(tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: bfk.Table.Name}) {
+			continue
+		}
+		ddl, err := newDDLStatement(bfk.TableDiff(mods.Flavor), mods, t, bfk.String(), UnsafeDropBrokenFK)
+		if ddl == nil && err == nil {
+			continue
+		}
+		result.Differences = true
+		if err != nil {
+			t.logger().Warnf("Broken constraint: %s", err)
+		} else {
+			ddls = append(ddls, ddl)
+		}
+	}
+
+	// The tracking table is created like any other table, so that it is shown
+	// in diff output, and is subject to the same wrappers and plan recording.
+	// It is exempt from ignore-table, since it is ignored in diffs regardless.
+	if trackingTableName != "" && !hasTrackingTable {
+		trackingMods := mods
+		trackingMods.IgnoreTable = nil
+		ddl, err := NewDDLStatement(tengo.NewCreateTable(trackingTable(trackingTableName)), trackingMods, t)
+		if err != nil {
+			return finish(err)
+		}
+		result.Differences = true
+		ddls = append(ddls, ddl)
+	}
+
+	// Passthrough files are only run upon request, after all other DDL, since
+	// they may depend on managed objects but are never diff'ed
+	if t.Dir.Config.GetBool("include-passthrough") {
+		passthroughDDLs, err := passthroughDDL(t)
+		if _, ok := err.(ConfigError); ok {
+			return finish(err)
+		} else if err != nil {
+			result.SkipCount++
+			t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+			return finish(nil)
+		} else if len(passthroughDDLs) > 0 && printer.plan != nil {
+			return finish(ConfigError(fmt.Sprintf("%s: passthrough files cannot be recorded in a plan file", t.Dir)))
+		} else if len(passthroughDDLs) > 0 {
+			result.Differences = true
+			ddls = append(ddls, passthroughDDLs...)
 		}
 	}
 
@@ -136,7 +585,10 @@ func applyTarget(t *Target, printer *Printer) (Result, error) {
 	if t.Dir.Config.GetBool("lint") {
 		lintOpts, err := linter.OptionsForDir(t.Dir)
 		if err != nil {
-			return result, ConfigError(err.Error())
+			return finish(ConfigError(err.Error()))
+		}
+		if flavor := t.Instance.Flavor(); flavor.Known() {
+			lintOpts.Flavor = flavor // deprecations are specific to the target's version
 		}
 		lintOpts.OnlyKeys(keys)
 		lintResult := linter.CheckSchema(t.DesiredSchema, lintOpts)
@@ -146,13 +598,115 @@ func applyTarget(t *Target, printer *Printer) (Result, error) {
 		}
 		if lintResult.ErrorCount > 0 {
 			result.SkipCount += len(objDiffs)
-			log.Warnf("Skipping %s %s due to %s", t.Instance, t.SchemaName, countAndNoun(lintResult.ErrorCount, "linter error"))
-			return result, nil
+			t.logger().Warnf("Skipping %s %s due to %s", t.Instance, t.SchemaName, countAndNoun(lintResult.ErrorCount, "linter error"))
+			return finish(nil)
+		}
+	}
+
+	// If a state file is configured, objects changed both on the server and in
+	// the filesystem since the last pull or push are conflicts: pushing them
+	// would likely revert someone else's change. This requires --force-conflicts
+	// when pushing, and is only reported when diff'ing.
+	conflicts, err := t.syncConflicts(keys, schemaFromInstance, schemaFromDir)
+	if err != nil {
+		t.logger().Warnf("%s %s: unable to check for conflicts: %s", t.Instance, t.SchemaName, err)
+	}
+	if len(conflicts) > 0 {
+		logConflict := t.logger().Errorf
+		if t.dryRun() || t.Dir.Config.GetBool("force-conflicts") {
+			logConflict = t.logger().Warnf
+		}
+		for _, conflict := range conflicts {
+			logConflict("%s %s: %s", t.Instance, t.SchemaName, conflict)
+		}
+		if !t.dryRun() && !t.Dir.Config.GetBool("force-conflicts") {
+			result.SkipCount += len(ddls)
+			t.logger().Warnf("Skipping %s %s due to %s; use --force-conflicts to push anyway", t.Instance, t.SchemaName, countAndNoun(len(conflicts), "conflict"))
+			return finish(nil)
+		}
+	}
+
+	// Statements using syntax removed in the target's version would only fail
+	// upon execution, so refuse to run them, regardless of lint settings
+	if !t.dryRun() {
+		if problems := t.removedFeatures(keys); len(problems) > 0 {
+			for _, problem := range problems {
+				t.logger().Errorf("%s %s: %s", t.Instance, t.SchemaName, problem)
+			}
+			result.SkipCount += len(ddls)
+			t.logger().Warnf("Skipping %s %s due to use of %s removed in %s", t.Instance, t.SchemaName, countAndNoun(len(problems), "feature"), t.Instance.Flavor())
+			return finish(nil)
+		}
+	}
+
+	// Before executing anything, confirm that running the full sequence of
+	// statements in a workspace yields the desired definitions
+	if !t.dryRun() && len(ddls) > 0 && t.Dir.Config.GetBool("verify-sequence") {
+		normalizeResult := func(result *tengo.Schema) *tengo.Schema {
+			return normalize(modelTableOptions(result, mods.Flavor))
+		}
+		if err := verifySequence(ddls, schemaFromInstance, schemaFromDir, keys, mods, normalizeResult, t); err != nil {
+			result.SkipCount += len(ddls)
+			t.logger().Errorf("Skipping %s schema %s for %s: %s", t.Instance, t.SchemaName, t.Dir, err)
+			return finish(nil)
 		}
 	}
 
+	// If recording a plan, fingerprint the pre-change definition of each object
+	// being modified, so that drift can be detected prior to applying the plan
+	if printer.plan != nil {
+		fingerprints := ObjectFingerprints(schemaFromInstance)
+		for _, ddl := range ddls {
+			ddl.fingerprint = fingerprints[ddl.diff.ObjectKey()]
+		}
+	}
+
+	result.ObjectCount = len(keys)
+	result.StatementCount = len(ddls)
+	for _, ddl := range ddls {
+		if ddl.unsafe {
+			result.UnsafeCount++
+			result.UnsafeCodes = mergeUnsafeCodes(result.UnsafeCodes, ddl.unsafeCodes)
+		}
+	}
+
+	return &preparedTarget{
+		result:            result,
+		ddls:              ddls,
+		schemaFromDir:     schemaFromDir,
+		trackingTableName: trackingTableName,
+	}, nil
+}
+
+// execute prints the DDL of p, and executes it unless t is only being used for
+// dry-run purposes, returning the final Result for t.
+func (p *preparedTarget) execute(ctx context.Context, t *Target, printer *Printer) (Result, error) {
+	result, ddls, schemaFromDir, trackingTableName := p.result, p.ddls, p.schemaFromDir, p.trackingTableName
+
 	// Print DDL; if not dry-run, execute it; final logging; return result
-	result.SkipCount += t.processDDL(ddls, printer)
+	skipCount, err := t.processDDL(ctx, ddls, printer)
+	result.SkipCount += skipCount
+	if err == context.DeadlineExceeded {
+		result.TimeoutCount++
+		return result, nil
+	} else if err == context.Canceled {
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	if !t.dryRun() {
+		t.verifyJournal(schemaFromDir)
+	}
+	if trackingTableName != "" && skipCount == 0 && !t.dryRun() {
+		if err := t.recordPush(trackingTableName, schemaFromDir); err != nil {
+			t.logger().Warnf("%s %s: unable to record push in tracking table %s: %s", t.Instance, t.SchemaName, tengo.EscapeIdentifier(trackingTableName), err)
+		}
+	}
+	if skipCount == 0 && !t.dryRun() {
+		if err := recordSync(t.Dir, t.Instance, t.SchemaName, schemaFromDir, "push"); err != nil {
+			t.logger().Warnf("%s %s: unable to record push in state file: %s", t.Instance, t.SchemaName, err)
+		}
+	}
 	t.logApplyEnd(result)
 	return result, nil
 }
@@ -179,6 +733,13 @@ func SumResults(results []Result) Result {
 		total.Differences = total.Differences || r.Differences
 		total.SkipCount += r.SkipCount
 		total.UnsupportedCount += r.UnsupportedCount
+		total.TimeoutCount += r.TimeoutCount
+		total.ObjectCount += r.ObjectCount
+		total.StatementCount += r.StatementCount
+		total.UnsafeCount += r.UnsafeCount
+		total.UnsafeCodes = mergeUnsafeCodes(total.UnsafeCodes, r.UnsafeCodes)
+		total.SkippedObjects = append(total.SkippedObjects, r.SkippedObjects...)
+		total.Targets = append(total.Targets, r.Targets...)
 	}
 	return total
 }
@@ -234,3 +795,15 @@ type ConfigError string
 func (ce ConfigError) Error() string {
 	return string(ce)
 }
+
+// unsafeStatementError is an error returned when generating a statement which
+// is considered unsafe, without unsafe operations being permitted.
+type unsafeStatementError struct {
+	text  string
+	codes []UnsafeCode
+}
+
+// Error satisfies the builtin error interface.
+func (use unsafeStatementError) Error() string {
+	return use.text
+}
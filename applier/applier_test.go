@@ -1,11 +1,15 @@
 package applier
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
-	"strings"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/skeema/skeema/testutil"
 	"github.com/skeema/tengo"
 	"golang.org/x/sync/errgroup"
 )
@@ -24,41 +28,186 @@ func TestSumResults(t *testing.T) {
 			Differences:      false,
 			SkipCount:        1,
 			UnsupportedCount: 0,
+			Targets:          []TargetResult{{Schema: "one", Status: "skipped"}},
 		},
 		{
 			Differences:      true,
 			SkipCount:        3,
 			UnsupportedCount: 5,
+			TimeoutCount:     2,
+			ObjectCount:      4,
+			StatementCount:   6,
+			UnsafeCount:      1,
+			Targets:          []TargetResult{{Schema: "two", Status: "timeout"}},
 		},
 	}
 	expectSum := Result{
 		Differences:      true,
 		SkipCount:        4,
 		UnsupportedCount: 5,
+		TimeoutCount:     2,
+		ObjectCount:      4,
+		StatementCount:   6,
+		UnsafeCount:      1,
+		Targets:          []TargetResult{{Schema: "one", Status: "skipped"}, {Schema: "two", Status: "timeout"}},
 	}
-	if actualSum := SumResults(input); actualSum != expectSum {
+	if actualSum := SumResults(input); !reflect.DeepEqual(actualSum, expectSum) {
 		t.Errorf("Unexpected result from SumResults: %+v", actualSum)
 	}
 }
 
-func TestIntegration(t *testing.T) {
-	images := tengo.SplitEnv("SKEEMA_TEST_IMAGES")
-	if len(images) == 0 {
-		fmt.Println("SKEEMA_TEST_IMAGES env var is not set, so integration tests will be skipped!")
-		fmt.Println("To run integration tests, you may set SKEEMA_TEST_IMAGES to a comma-separated")
-		fmt.Println("list of Docker images. Example:\n# SKEEMA_TEST_IMAGES=\"mysql:5.6,mysql:5.7\" go test")
+func TestResultSummary(t *testing.T) {
+	cases := []struct {
+		input    Result
+		expected string
+	}{
+		{Result{}, ""},
+		{Result{SkipCount: 2}, "Skipped 2 operations due to errors"},
+		{Result{UnsupportedCount: 1}, "Skipped 1 operation due to unsupported feature"},
+		{Result{TimeoutCount: 1}, "1 target timed out"},
+		{Result{SkipCount: 1, TimeoutCount: 3}, "Skipped 1 operation due to error; 3 targets timed out"},
+		{Result{Differences: true, TimeoutCount: 2}, "2 targets timed out"},
+	}
+	for _, c := range cases {
+		if actual := c.input.Summary(); actual != c.expected {
+			t.Errorf("Expected Summary of %+v to return %q, instead found %q", c.input, c.expected, actual)
+		}
+	}
+}
+
+// unresponsiveListener returns a TCP listener which accepts connections but
+// never writes anything to them, simulating a hung database server.
+func unresponsiveListener(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return listener
+}
+
+func TestApplyTargetQueryTimeout(t *testing.T) {
+	listener := unresponsiveListener(t)
+	defer listener.Close()
+
+	dir := getDir(t, "testdata/simple", "--query-timeout=1")
+	params, err := dir.InstanceDefaultParams()
+	if err != nil {
+		t.Fatalf("Unexpected error from InstanceDefaultParams: %s", err)
+	}
+	inst, err := tengo.NewInstance("mysql", fmt.Sprintf("root:@tcp(%s)/?%s", listener.Addr(), params))
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{Instance: inst, Dir: dir, SchemaName: "product"}
+	result, err := applyTarget(context.Background(), target, NewPrinter(false))
+	if err != nil {
+		t.Errorf("Expected query timeout to not return an error, instead found %s", err)
+	}
+	if result.TimeoutCount != 1 || result.SkipCount != 0 {
+		t.Errorf("Unexpected result from applyTarget: %+v", result)
+	}
+}
+
+func TestProcessDDLContext(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	target.Dir = getDir(t, "testdata/simple/one", "")
+
+	// Once ctx is done, no statements are printed or executed, and all are
+	// counted as skipped
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if skipCount, err := target.processDDL(ctx, ddls, NewPrinter(false)); err != context.DeadlineExceeded || skipCount != len(ddls) {
+		t.Errorf("Unexpected result from processDDL with expired deadline: %d, %v", skipCount, err)
 	}
-	manager, err := tengo.NewDockerClient(tengo.DockerClientOptions{})
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if skipCount, err := target.processDDL(ctx, ddls, NewPrinter(false)); err != context.Canceled || skipCount != len(ddls) {
+		t.Errorf("Unexpected result from processDDL with cancelled context: %d, %v", skipCount, err)
+	}
+
+	// Without a deadline, dry-run output proceeds normally
+	target.Dir = getDir(t, "testdata/simple/one", "--dry-run")
+	if skipCount, err := target.processDDL(context.Background(), ddls, NewPrinter(false)); err != nil || skipCount != 0 {
+		t.Errorf("Unexpected result from processDDL with dry-run: %d, %v", skipCount, err)
+	}
+}
+
+func TestTargetSkipRemaining(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	if len(ddls) < 2 {
+		t.Fatalf("Test fixture needs at least 2 statements, found %d", len(ddls))
+	}
+	last := len(ddls) - 1
+	cases := []struct {
+		i              int
+		includeCurrent bool
+		expected       int
+	}{
+		{0, true, len(ddls)},
+		{0, false, len(ddls) - 1},
+		{last, true, 1},
+		{last, false, 0},
+	}
+	for _, c := range cases {
+		if actual := target.skipRemaining(ddls, c.i, c.includeCurrent); actual != c.expected {
+			t.Errorf("Expected skipRemaining(ddls, %d, %t) to return %d, instead found %d", c.i, c.includeCurrent, c.expected, actual)
+		}
+	}
+}
+
+func TestWorkerRunTimeout(t *testing.T) {
+	listener := unresponsiveListener(t)
+	defer listener.Close()
+
+	dir := getDir(t, "testdata/simple", "--query-timeout=1")
+	params, err := dir.InstanceDefaultParams()
 	if err != nil {
-		t.Errorf("Unable to create sandbox manager: %s", err)
+		t.Fatalf("Unexpected error from InstanceDefaultParams: %s", err)
 	}
-	suite := &ApplierIntegrationSuite{manager: manager}
-	tengo.RunSuite(suite, t, images)
+	inst, err := tengo.NewInstance("mysql", fmt.Sprintf("root:@tcp(%s)/?%s", listener.Addr(), params))
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	targetGroups := make(chan TargetGroup, 1)
+	targetGroups <- TargetGroup{
+		{Instance: inst, Dir: dir, SchemaName: "product"},
+		{Instance: inst, Dir: dir, SchemaName: "other"},
+	}
+	close(targetGroups)
+	results := make(chan Result, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := Worker(ctx, targetGroups, results, NewPrinter(false)); err != nil {
+		t.Errorf("Unexpected error from Worker: %s", err)
+	}
+	close(results)
+	var all []Result
+	for result := range results {
+		all = append(all, result)
+	}
+	if sum := SumResults(all); len(all) != 2 || sum.TimeoutCount != 2 {
+		t.Errorf("Expected both targets to time out, instead found results %+v", all)
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	testutil.RunSuite(t, &ApplierIntegrationSuite{})
 }
 
 type ApplierIntegrationSuite struct {
-	manager *tengo.DockerClient
-	d       []*tengo.DockerizedInstance
+	d []*tengo.DockerizedInstance
 }
 
 func (s *ApplierIntegrationSuite) Setup(backend string) error {
@@ -68,15 +217,11 @@ func (s *ApplierIntegrationSuite) Setup(backend string) error {
 		n := n
 		g.Go(func() error {
 			var err error
-			containerName := fmt.Sprintf("skeema-test-%s", strings.Replace(backend, ":", "-", -1))
+			var suffix string
 			if n > 0 {
-				containerName = fmt.Sprintf("%s-%d", containerName, n+1)
+				suffix = fmt.Sprintf("%d", n+1)
 			}
-			s.d[n], err = s.manager.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
-				Name:         containerName,
-				Image:        backend,
-				RootPassword: "fakepw",
-			})
+			s.d[n], err = testutil.DockerInstance(backend, "", suffix)
 			return err
 		})
 	}
@@ -0,0 +1,119 @@
+package applier
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+// backupTimestamp is used as the name of the subdirectory containing all
+// backups taken during this process's lifetime, so that backups from a single
+// run of `skeema push` are grouped together.
+var backupTimestamp = time.Now().Format("20060102-150405")
+
+// needsBackup returns true if ddl drops a table, or drops one or more columns
+// of a table. If ddl is a single clause of a split ALTER TABLE, only that
+// clause is considered, so that the table is not backed up repeatedly.
+func (ddl *DDLStatement) needsBackup() bool {
+	td, ok := ddl.diff.(*tengo.TableDiff)
+	if !ok || td.From == nil {
+		return false
+	}
+	if td.Type == tengo.DiffTypeDrop {
+		return true
+	} else if td.Type != tengo.DiffTypeAlter {
+		return false
+	}
+	if ddl.clause != nil {
+		_, ok := ddl.clause.(tengo.DropColumn)
+		return ok
+	}
+	clauses, _ := td.From.Diff(td.To)
+	for _, clause := range clauses {
+		if _, ok := clause.(tengo.DropColumn); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// backupRoot returns the top-level backup dir configured for t. A relative
+// backup-dir is interpreted relative to the repo base.
+func (t *Target) backupRoot() string {
+	root := t.Dir.Config.Get("backup-dir")
+	if root == "" {
+		root = ".skeema-backups"
+	}
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(t.Dir.RepoBase(), root)
+	}
+	return root
+}
+
+// backupDir returns the directory in which backups for t should be written
+// during this run.
+func (t *Target) backupDir() string {
+	instanceName := strings.Replace(t.Instance.String(), ":", "_", -1)
+	return filepath.Join(t.backupRoot(), backupTimestamp, instanceName, t.SchemaName)
+}
+
+// backup writes the instance's current definition of the table affected by
+// ddl to a file in the target's backup dir, returning the path of the file.
+// The caller should not proceed with executing ddl if an error is returned.
+func (t *Target) backup(ddl *DDLStatement) (string, error) {
+	tableName := ddl.diff.ObjectKey().Name
+	create, err := t.Instance.ShowCreateTable(t.SchemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Backup of %s.%s on %s, taken prior to running:\n", tengo.EscapeIdentifier(t.SchemaName), tengo.EscapeIdentifier(tableName), t.Instance)
+	fmt.Fprintf(&b, "-- %s\n", strings.Replace(ddl.stmt, "\n", "\n-- ", -1))
+	if t.Dir.Config.GetBool("backup-row-count") {
+		db, err := t.Instance.Connect(t.SchemaName, "readTimeout=0")
+		if err != nil {
+			return "", err
+		}
+		var count int64
+		if err := db.QueryRow("SELECT COUNT(*) FROM " + tengo.EscapeIdentifier(tableName)).Scan(&count); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "-- Row count: %d\n", count)
+	}
+	b.WriteString(create)
+	b.WriteString(";\n")
+
+	dir := t.backupDir()
+	if err := os.MkdirAll(dir, util.DirMode); err != nil {
+		return "", err
+	}
+	ignoreBackupDir(t.backupRoot())
+	filePath := filepath.Join(dir, tableName+".sql")
+	if err := ioutil.WriteFile(filePath, []byte(b.String()), util.FileMode); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+// ignoreBackupDir creates a .gitignore file in the top-level backup dir, so
+// that backups are not accidentally committed to the schema repo. An existing
+// .gitignore is never overwritten. Errors are only logged, since they do not
+// affect the backup itself.
+func ignoreBackupDir(dir string) {
+	f, err := os.OpenFile(filepath.Join(dir, ".gitignore"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, util.FileMode)
+	if os.IsExist(err) {
+		return
+	} else if err != nil {
+		log.Debugf("Unable to create .gitignore in %s: %s", dir, err)
+		return
+	}
+	defer f.Close()
+	f.WriteString("*\n")
+}
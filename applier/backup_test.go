@@ -0,0 +1,48 @@
+package applier
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestDDLStatementNeedsBackup(t *testing.T) {
+	from := brokenFKTestTable("orphans", "parents", "users")
+	droppedCol := brokenFKTestTable("orphans", "parents")
+	addedCol := brokenFKTestTable("orphans", "parents", "users", "posts")
+	cases := map[tengo.ObjectDiff]bool{
+		tengo.NewDropTable(from):              true,
+		tengo.NewAlterTable(from, droppedCol): true,
+		tengo.NewAlterTable(from, addedCol):   false,
+		tengo.NewCreateTable(from):            false,
+	}
+	for diff, expected := range cases {
+		ddl := &DDLStatement{diff: diff}
+		if actual := ddl.needsBackup(); actual != expected {
+			t.Errorf("Expected needsBackup() to return %t for %s %s, instead found %t", expected, diff.DiffType(), diff.ObjectKey(), actual)
+		}
+	}
+}
+
+func TestTargetBackupDir(t *testing.T) {
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{
+		Instance:   inst,
+		Dir:        getDir(t, "testdata/simple/one", ""),
+		SchemaName: "product",
+	}
+	expected := filepath.Join(target.Dir.RepoBase(), ".skeema-backups", backupTimestamp, "127.0.0.1_3306", "product")
+	if actual := target.backupDir(); actual != expected {
+		t.Errorf("Expected backupDir() to return %s, instead found %s", expected, actual)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--backup-dir=/var/backups/skeema")
+	if actual := target.backupDir(); !strings.HasPrefix(actual, "/var/backups/skeema/"+backupTimestamp+"/") {
+		t.Errorf("Unexpected result from backupDir() with absolute backup-dir: %s", actual)
+	}
+}
@@ -0,0 +1,124 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/tengo"
+)
+
+// splitClauses returns ddl split into separate DDLStatements, one per clause of
+// its ALTER TABLE, for use with ddl-batching=per-clause. Each returned
+// statement is only considered unsafe if its own clause is unsafe. The
+// original ddl is returned as the sole element if it is not an ALTER TABLE
+// with multiple clauses, or if its clauses cannot safely be run separately:
+//
+//   - Statements run via alter-wrapper or ddl-wrapper are never split, since
+//     an external online schema change tool typically performs a full table
+//     copy per invocation.
+//   - Partitioning changes must be combined with other clauses in specific
+//     ways, and changes involving an auto_increment column or its index may
+//     not be valid individually, since the server requires auto_increment
+//     columns to be indexed at all times.
+//   - Statements with an unsafe note (e.g. excessive index key size) apply to
+//     the whole table, and are not split.
+//
+// As a final safeguard, ddl is only split if the per-clause statements fully
+// account for the original statement.
+func (ddl *DDLStatement) splitClauses(mods tengo.StatementModifiers) []*DDLStatement {
+	whole := []*DDLStatement{ddl}
+	td, ok := ddl.diff.(*tengo.TableDiff)
+	if !ok || td.Type != tengo.DiffTypeAlter || ddl.IsShellOut() || ddl.note != "" || mods.VirtualColValidation {
+		return whole
+	}
+	clauses, supported := tableDiffClauses(td)
+	if !supported || len(clauses) < 2 {
+		return whole
+	}
+
+	// Replicate the diff engine's adjustments to mods when generating clauses
+	mods.AllowUnsafe = true
+	if !mods.StrictIndexOrder && td.To.ClusteredIndexKey() != td.To.PrimaryKey {
+		mods.StrictIndexOrder = true
+	}
+	clauses = withIndexRenames(clauses, td, mods)
+	if replacesUniqueIndex(clauses) {
+		log.Debugf("Not splitting ALTER for %s: a unique index is dropped and re-added", td.ObjectKey())
+		return whole
+	}
+	var prefixClauses []string
+	if mods.AlgorithmClause != "" {
+		prefixClauses = append(prefixClauses, fmt.Sprintf("ALGORITHM=%s", strings.ToUpper(mods.AlgorithmClause)))
+	}
+	if mods.LockClause != "" {
+		prefixClauses = append(prefixClauses, fmt.Sprintf("LOCK=%s", strings.ToUpper(mods.LockClause)))
+	}
+
+	clauseStrings := make([]string, 0, len(clauses))
+	pieceClauses := make([]tengo.TableAlterClause, 0, len(clauses))
+	for _, clause := range clauses {
+		if !separable(clause) {
+			log.Debugf("Not splitting ALTER for %s: clause %T cannot be run separately", td.ObjectKey(), clause)
+			return whole
+		}
+		if clauseString := clause.Clause(mods); clauseString != "" {
+			clauseStrings = append(clauseStrings, clauseString)
+			pieceClauses = append(pieceClauses, clause)
+		}
+	}
+	if len(clauseStrings) < 2 {
+		return whole
+	}
+	alterPrefix := td.From.AlterStatement() + " "
+	if expected := alterPrefix + strings.Join(append(prefixClauses, clauseStrings...), ", "); expected != ddl.stmt {
+		log.Debugf("Not splitting ALTER for %s: per-clause statements do not match combined statement %s", td.ObjectKey(), ddl.stmt)
+		return whole
+	}
+
+	result := make([]*DDLStatement, len(clauseStrings))
+	for n, clauseString := range clauseStrings {
+		piece := *ddl
+		piece.stmt = alterPrefix + strings.Join(append(prefixClauses[:len(prefixClauses):len(prefixClauses)], clauseString), ", ")
+		piece.clause = pieceClauses[n]
+		unsafer, ok := pieceClauses[n].(tengo.Unsafer)
+		piece.unsafe = ok && unsafer.Unsafe()
+		piece.unsafeCodes = nil
+		if piece.unsafe {
+			piece.unsafeCodes = []UnsafeCode{clauseUnsafeCode(pieceClauses[n])}
+		}
+		if ddl.estimate != nil {
+			piece.setEstimate(pieceClauses[n:n+1], mods)
+		}
+		result[n] = &piece
+	}
+	return result
+}
+
+// separable returns true if clause may be executed in its own ALTER TABLE,
+// independently of the other clauses for the same table.
+func separable(clause tengo.TableAlterClause) bool {
+	indexHasAutoInc := func(idx *tengo.Index) bool {
+		for _, col := range idx.Columns {
+			if col.AutoIncrement {
+				return true
+			}
+		}
+		return false
+	}
+	switch clause := clause.(type) {
+	case tengo.PartitionBy, tengo.RemovePartitioning, tengo.ModifyPartitions:
+		return false
+	case tengo.AddColumn:
+		return !clause.Column.AutoIncrement
+	case tengo.DropColumn:
+		return !clause.Column.AutoIncrement
+	case tengo.ModifyColumn:
+		return !clause.OldColumn.AutoIncrement && !clause.NewColumn.AutoIncrement
+	case tengo.AddIndex:
+		return !indexHasAutoInc(clause.Index)
+	case tengo.DropIndex:
+		return !indexHasAutoInc(clause.Index)
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+func newSplitTestDDL(t *testing.T, diff tengo.ObjectDiff, mods tengo.StatementModifiers) *DDLStatement {
+	t.Helper()
+	mods.AllowUnsafe = true
+	stmt, err := diff.Statement(mods)
+	if err != nil {
+		t.Fatalf("Unexpected error from Statement: %v", err)
+	}
+	return &DDLStatement{stmt: stmt, diff: diff, unsafe: true}
+}
+
+func TestDDLStatementSplitClauses(t *testing.T) {
+	from := brokenFKTestTable("orphans", "parents", "users")
+	to := brokenFKTestTable("orphans", "parents")
+	mods := tengo.StatementModifiers{AlgorithmClause: "inplace", LockClause: "none"}
+	ddl := newSplitTestDDL(t, tengo.NewAlterTable(from, to), mods)
+	pieces := ddl.splitClauses(mods)
+	if len(pieces) != 3 {
+		t.Fatalf("Expected split into 3 statements, instead found %d", len(pieces))
+	}
+	var unsafeCount, backupCount int
+	for _, piece := range pieces {
+		if !strings.HasPrefix(piece.stmt, "ALTER TABLE `orphans` ALGORITHM=INPLACE, LOCK=NONE, ") || strings.Count(piece.stmt, ",") != 2 {
+			t.Errorf("Unexpected statement for single clause: %s", piece.stmt)
+		}
+		if _, isDropCol := piece.clause.(tengo.DropColumn); piece.unsafe != isDropCol {
+			t.Errorf("Expected unsafe=%t for %s, instead found %t", isDropCol, piece.stmt, piece.unsafe)
+		}
+		if piece.unsafe {
+			unsafeCount++
+		}
+		if piece.needsBackup() {
+			backupCount++
+		}
+	}
+	if unsafeCount != 1 || backupCount != 1 {
+		t.Errorf("Expected exactly 1 unsafe statement needing a backup; instead found unsafe=%d, backups=%d", unsafeCount, backupCount)
+	}
+	var lastPos int
+	for _, piece := range pieces {
+		clause := piece.stmt[strings.LastIndex(piece.stmt, ", ")+2:]
+		if pos := strings.Index(ddl.stmt, clause); pos <= lastPos {
+			t.Errorf("Expected original clause order to be preserved, but %q is out of order in %s", clause, ddl.stmt)
+		} else {
+			lastPos = pos
+		}
+	}
+
+	// Single-clause ALTERs, non-ALTERs, and shell-outs are never split
+	commented := brokenFKTestTable("users")
+	commented.Comment = "hello"
+	commented.CreateStatement = commented.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	single := newSplitTestDDL(t, tengo.NewAlterTable(brokenFKTestTable("users"), commented), tengo.StatementModifiers{})
+	create := newSplitTestDDL(t, tengo.NewCreateTable(from), tengo.StatementModifiers{})
+	shellOut := newSplitTestDDL(t, tengo.NewAlterTable(from, to), tengo.StatementModifiers{})
+	shellOut.shellOut = &util.ShellOut{Command: "echo hello"}
+	noted := newSplitTestDDL(t, tengo.NewAlterTable(from, to), tengo.StatementModifiers{})
+	noted.note = "something bad"
+	for _, ddl := range []*DDLStatement{single, create, shellOut, noted} {
+		if pieces := ddl.splitClauses(tengo.StatementModifiers{}); len(pieces) != 1 || pieces[0] != ddl {
+			t.Errorf("Expected %s to remain unsplit, instead found %d statements", ddl.stmt, len(pieces))
+		}
+	}
+
+	// Changes involving an auto_increment column are not split
+	autoFrom := brokenFKTestTable("orphans")
+	autoFrom.Columns[0].AutoIncrement = true
+	autoFrom.CreateStatement = autoFrom.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	autoTo := brokenFKTestTable("orphans", "parents")
+	autoInc := newSplitTestDDL(t, tengo.NewAlterTable(autoFrom, autoTo), tengo.StatementModifiers{})
+	if pieces := autoInc.splitClauses(tengo.StatementModifiers{}); len(pieces) != 1 {
+		t.Errorf("Expected %s to remain unsplit, instead found %d statements", autoInc.stmt, len(pieces))
+	}
+}
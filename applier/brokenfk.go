@@ -0,0 +1,69 @@
+package applier
+
+import (
+	"fmt"
+
+	"github.com/skeema/tengo"
+)
+
+// brokenForeignKey represents a foreign key on a live table which references a
+// table that does not exist. This typically occurs when the parent table was
+// dropped while foreign_key_checks was disabled. Such a foreign key cannot be
+// reconciled by the regular diff, since it is also present in the filesystem
+// version of the table, typically as a result of `skeema pull`.
+type brokenForeignKey struct {
+	Table      *tengo.Table
+	ForeignKey *tengo.ForeignKey
+}
+
+// String returns a description of the broken reference, for use as a note on
+// its DDLStatement.
+func (bfk brokenForeignKey) String() string {
+	return fmt.Sprintf("foreign key %s of table %s references missing table %s", tengo.EscapeIdentifier(bfk.ForeignKey.Name), tengo.EscapeIdentifier(bfk.Table.Name), tengo.EscapeIdentifier(bfk.ForeignKey.ReferencedTableName))
+}
+
+// TableDiff returns an ALTER TABLE diff which drops the broken foreign key,
+// leaving the rest of the table unchanged.
+func (bfk brokenForeignKey) TableDiff(flavor tengo.Flavor) *tengo.TableDiff {
+	to := *bfk.Table
+	to.ForeignKeys = make([]*tengo.ForeignKey, 0, len(bfk.Table.ForeignKeys))
+	for _, fk := range bfk.Table.ForeignKeys {
+		if fk != bfk.ForeignKey {
+			to.ForeignKeys = append(to.ForeignKeys, fk)
+		}
+	}
+	to.CreateStatement = to.GeneratedCreateStatement(flavor)
+	return tengo.NewAlterTable(bfk.Table, &to)
+}
+
+// brokenForeignKeys returns foreign keys in instSchema which reference a table
+// that exists in neither instSchema nor desiredSchema. Foreign keys which the
+// regular diff between the two schemas already drops are excluded, as are
+// references to other schemas, which cannot be verified here. Either schema
+// may be nil.
+func brokenForeignKeys(instSchema, desiredSchema *tengo.Schema) (result []brokenForeignKey) {
+	if instSchema == nil || desiredSchema == nil {
+		return nil
+	}
+	for _, table := range instSchema.Tables {
+		desiredTable := desiredSchema.Table(table.Name)
+		if desiredTable == nil || table.UnsupportedDDL {
+			continue
+		}
+		for _, fk := range table.ForeignKeys {
+			if fk.ReferencedSchemaName != "" && fk.ReferencedSchemaName != instSchema.Name {
+				continue
+			}
+			if instSchema.HasTable(fk.ReferencedTableName) || desiredSchema.HasTable(fk.ReferencedTableName) {
+				continue
+			}
+			for _, desiredFK := range desiredTable.ForeignKeys {
+				if desiredFK.Equivalent(fk) {
+					result = append(result, brokenForeignKey{Table: table, ForeignKey: fk})
+					break
+				}
+			}
+		}
+	}
+	return result
+}
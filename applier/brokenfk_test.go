@@ -0,0 +1,103 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// brokenFKTestTable returns a table with foreign keys referencing each of the
+// supplied table names.
+func brokenFKTestTable(name string, referencedTables ...string) *tengo.Table {
+	id := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	table := &tengo.Table{
+		Name:       name,
+		Engine:     "InnoDB",
+		CharSet:    "utf8mb4",
+		Collation:  "utf8mb4_general_ci",
+		Columns:    []*tengo.Column{id},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true, Unique: true},
+	}
+	for _, referenced := range referencedTables {
+		col := &tengo.Column{Name: referenced + "_id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+		table.Columns = append(table.Columns, col)
+		table.SecondaryIndexes = append(table.SecondaryIndexes, &tengo.Index{Name: col.Name, Columns: []*tengo.Column{col}, SubParts: []uint16{0}})
+		table.ForeignKeys = append(table.ForeignKeys, &tengo.ForeignKey{
+			Name:                  name + "_" + referenced,
+			Columns:               []*tengo.Column{col},
+			ReferencedTableName:   referenced,
+			ReferencedColumnNames: []string{"id"},
+			UpdateRule:            "RESTRICT",
+			DeleteRule:            "RESTRICT",
+		})
+	}
+	table.CreateStatement = table.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	return table
+}
+
+func TestBrokenForeignKeys(t *testing.T) {
+	instSchema := &tengo.Schema{
+		Name: "product",
+		Tables: []*tengo.Table{
+			brokenFKTestTable("orphans", "parents", "users", "posts"),
+			brokenFKTestTable("users"),
+		},
+	}
+	desiredSchema := &tengo.Schema{
+		Name: "product",
+		Tables: []*tengo.Table{
+			brokenFKTestTable("orphans", "parents", "users", "posts"),
+			brokenFKTestTable("users"),
+			brokenFKTestTable("posts"), // being created by the diff
+		},
+	}
+	broken := brokenForeignKeys(instSchema, desiredSchema)
+	if len(broken) != 1 || broken[0].ForeignKey.Name != "orphans_parents" {
+		t.Fatalf("Expected only orphans_parents to be broken, instead found %+v", broken)
+	}
+	if expected := "foreign key `orphans_parents` of table `orphans` references missing table `parents`"; broken[0].String() != expected {
+		t.Errorf("Unexpected String(): %s", broken[0].String())
+	}
+	stmt, err := broken[0].TableDiff(tengo.FlavorMySQL57).Statement(tengo.StatementModifiers{})
+	if err != nil || stmt != "ALTER TABLE `orphans` DROP FOREIGN KEY `orphans_parents`" {
+		t.Errorf("Unexpected statement from TableDiff: %q / %v", stmt, err)
+	}
+
+	// If the filesystem version no longer has the foreign key, or the table is
+	// being dropped entirely, the regular diff handles it
+	desiredSchema.Tables[0] = brokenFKTestTable("orphans", "users", "posts")
+	if broken := brokenForeignKeys(instSchema, desiredSchema); len(broken) != 0 {
+		t.Errorf("Expected no broken foreign keys, instead found %+v", broken)
+	}
+	desiredSchema.Tables = desiredSchema.Tables[1:]
+	if broken := brokenForeignKeys(instSchema, desiredSchema); len(broken) != 0 {
+		t.Errorf("Expected no broken foreign keys, instead found %+v", broken)
+	}
+
+	// References to other schemas are never considered broken
+	instSchema.Tables[0].ForeignKeys[0].ReferencedSchemaName = "other"
+	desiredSchema.Tables = append(desiredSchema.Tables, brokenFKTestTable("orphans", "parents", "users", "posts"))
+	desiredSchema.Tables[len(desiredSchema.Tables)-1].ForeignKeys[0].ReferencedSchemaName = "other"
+	if broken := brokenForeignKeys(instSchema, desiredSchema); len(broken) != 0 {
+		t.Errorf("Expected no broken foreign keys, instead found %+v", broken)
+	}
+
+	if broken := brokenForeignKeys(nil, desiredSchema); broken != nil {
+		t.Errorf("Expected nil schema to return no broken foreign keys, instead found %+v", broken)
+	}
+}
+
+func TestBrokenForeignKeyTableDiffUnchanged(t *testing.T) {
+	// Dropping the broken foreign key must leave its index in place, and must
+	// not modify the original table
+	table := brokenFKTestTable("orphans", "parents")
+	bfk := brokenForeignKey{Table: table, ForeignKey: table.ForeignKeys[0]}
+	td := bfk.TableDiff(tengo.FlavorMySQL57)
+	if len(table.ForeignKeys) != 1 || len(td.To.ForeignKeys) != 0 || len(td.To.SecondaryIndexes) != 1 {
+		t.Errorf("Unexpected tables in TableDiff: from %+v, to %+v", td.From, td.To)
+	}
+	if strings.Contains(td.To.CreateStatement, "FOREIGN KEY") {
+		t.Errorf("Expected CreateStatement of TableDiff.To to be regenerated, instead found %s", td.To.CreateStatement)
+	}
+}
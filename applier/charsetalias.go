@@ -0,0 +1,80 @@
+package applier
+
+import (
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+// normalizeCharSetAliases returns a shallow copy of from, in which character
+// set and collation names that differ from the corresponding names in to only
+// by spelling of the utf8mb3 alias are replaced with to's spelling. This
+// applies to the schema's defaults, each table's defaults, and each column.
+// This prevents a schema which was introspected from a server reporting
+// "utf8mb3" from appearing to differ from an identical schema reporting
+// "utf8", such as when the workspace and target run different server versions.
+// from is returned unchanged if flavor, the target's flavor, predates the
+// utf8mb3 spelling. Tables only present on one side, or using features
+// unsupported by tengo, are left as-is.
+func normalizeCharSetAliases(from, to *tengo.Schema, flavor tengo.Flavor) *tengo.Schema {
+	if from == nil || to == nil || !util.FlavorHasUtf8mb3Alias(flavor) {
+		return from
+	}
+	schemaCopy := *from
+	if equivalentCharSetAttrs(from.CharSet, from.Collation, to.CharSet, to.Collation) {
+		schemaCopy.CharSet, schemaCopy.Collation = to.CharSet, to.Collation
+	}
+	toTables := to.TablesByName()
+	schemaCopy.Tables = make([]*tengo.Table, len(from.Tables))
+	for n, fromTable := range from.Tables {
+		schemaCopy.Tables[n] = fromTable
+		toTable, ok := toTables[fromTable.Name]
+		if !ok || fromTable.UnsupportedDDL || toTable.UnsupportedDDL {
+			continue
+		}
+		tableCopy := *fromTable
+		var changed bool
+		if equivalentCharSetAttrs(fromTable.CharSet, fromTable.Collation, toTable.CharSet, toTable.Collation) {
+			tableCopy.CharSet, tableCopy.Collation = toTable.CharSet, toTable.Collation
+			changed = true
+		}
+		toColumns := make(map[string]*tengo.Column, len(toTable.Columns))
+		for _, col := range toTable.Columns {
+			toColumns[col.Name] = col
+		}
+		var columns []*tengo.Column
+		for i, fromCol := range fromTable.Columns {
+			toCol := toColumns[fromCol.Name]
+			if toCol == nil || !equivalentCharSetAttrs(fromCol.CharSet, fromCol.Collation, toCol.CharSet, toCol.Collation) {
+				continue
+			}
+			colCopy := *fromCol
+			colCopy.CharSet, colCopy.Collation = toCol.CharSet, toCol.Collation
+			if !colCopy.Equals(toCol) {
+				continue // column also differs in some other way
+			}
+			if columns == nil {
+				columns = make([]*tengo.Column, len(fromTable.Columns))
+				copy(columns, fromTable.Columns)
+			}
+			columns[i] = toCol
+		}
+		if columns != nil {
+			tableCopy.Columns = columns
+			changed = true
+		}
+		if changed {
+			tableCopy.CreateStatement = tableCopy.GeneratedCreateStatement(flavor)
+			schemaCopy.Tables[n] = &tableCopy
+		}
+	}
+	return &schemaCopy
+}
+
+// equivalentCharSetAttrs returns true if the supplied pairs of character set
+// and collation differ, but only in spelling of the utf8mb3 alias.
+func equivalentCharSetAttrs(fromCharSet, fromCollation, toCharSet, toCollation string) bool {
+	if fromCharSet == toCharSet && fromCollation == toCollation {
+		return false
+	}
+	return util.EquivalentCharSets(fromCharSet, toCharSet) && util.EquivalentCharSets(fromCollation, toCollation)
+}
@@ -0,0 +1,79 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// utf8mb3Fixture returns a schema containing a single table, modeled on the
+// SHOW CREATE TABLE output in the supplied testdata file as reported by a
+// server using the supplied spellings of the utf8 character set and its
+// utf8_bin collation.
+func utf8mb3Fixture(t *testing.T, fileName, charSet, binCollation string, flavor tengo.Flavor) *tengo.Schema {
+	t.Helper()
+	defaultCollation := strings.Replace(binCollation, "_bin", "_general_ci", 1)
+	table := &tengo.Table{
+		Name:               "countries",
+		Engine:             "InnoDB",
+		CharSet:            charSet,
+		Collation:          defaultCollation,
+		CollationIsDefault: true,
+		Columns: []*tengo.Column{
+			{Name: "code", TypeInDB: "char(2)", Default: tengo.ColumnDefaultNull, CharSet: charSet, Collation: defaultCollation, CollationIsDefault: true},
+			{Name: "name", TypeInDB: "varchar(60)", Default: tengo.ColumnDefaultNull, CharSet: charSet, Collation: defaultCollation, CollationIsDefault: true},
+			{Name: "slug", TypeInDB: "varchar(60)", Nullable: true, Default: tengo.ColumnDefaultNull, CharSet: charSet, Collation: binCollation},
+		},
+	}
+	table.PrimaryKey = &tengo.Index{Name: "PRIMARY", Columns: table.Columns[0:1], SubParts: []uint16{0}, PrimaryKey: true, Unique: true}
+	table.CreateStatement = table.GeneratedCreateStatement(flavor)
+	if expected := strings.TrimSpace(fs.ReadTestFile(t, "testdata/utf8mb3/"+fileName)); table.CreateStatement != expected {
+		t.Fatalf("Fixture table does not match %s:\n%s", fileName, table.CreateStatement)
+	}
+	return &tengo.Schema{Name: "geo", CharSet: charSet, Collation: defaultCollation, Tables: []*tengo.Table{table}}
+}
+
+func TestNormalizeCharSetAliases(t *testing.T) {
+	schemas := map[string]*tengo.Schema{
+		"5.7":    utf8mb3Fixture(t, "mysql57.sql", "utf8", "utf8_bin", tengo.FlavorMySQL57),
+		"8.0.28": utf8mb3Fixture(t, "mysql80_28.sql", "utf8mb3", "utf8_bin", tengo.FlavorMySQL80),
+		"8.0.34": utf8mb3Fixture(t, "mysql80_34.sql", "utf8mb3", "utf8mb3_bin", tengo.FlavorMySQL80),
+	}
+	for fromVersion, from := range schemas {
+		for toVersion, to := range schemas {
+			if objDiffs := tengo.NewSchemaDiff(from, to).ObjectDiffs(); fromVersion != toVersion && len(objDiffs) == 0 {
+				t.Fatalf("Test setup problem: expected differences between %s and %s prior to normalization", fromVersion, toVersion)
+			}
+			normalized := normalizeCharSetAliases(from, to, tengo.FlavorMySQL80)
+			if objDiffs := tengo.NewSchemaDiff(normalized, to).ObjectDiffs(); len(objDiffs) != 0 {
+				t.Errorf("Expected no differences between %s and %s after normalization, instead found %d", fromVersion, toVersion, len(objDiffs))
+			}
+		}
+	}
+	if schemas["8.0.34"].Tables[0].Columns[2].Collation != "utf8mb3_bin" {
+		t.Error("Expected original schema to be unmodified, but it was changed")
+	}
+
+	// Targets which predate the utf8mb3 spelling are not normalized
+	from, to := schemas["8.0.34"], schemas["5.7"]
+	if normalized := normalizeCharSetAliases(from, to, tengo.FlavorMySQL57); normalized != from {
+		t.Error("Expected normalizeCharSetAliases to return original schema for a MySQL 5.7 target")
+	}
+
+	// Other differences in a column are still reported, using the target's
+	// spelling
+	to = utf8mb3Fixture(t, "mysql57.sql", "utf8", "utf8_bin", tengo.FlavorMySQL57)
+	to.Tables[0].Columns[2].TypeInDB = "varchar(80)"
+	to.Tables[0].CreateStatement = to.Tables[0].GeneratedCreateStatement(tengo.FlavorMySQL80)
+	normalized := normalizeCharSetAliases(from, to, tengo.FlavorMySQL80)
+	objDiffs := tengo.NewSchemaDiff(normalized, to).ObjectDiffs()
+	if len(objDiffs) != 1 {
+		t.Fatalf("Expected 1 difference, instead found %d", len(objDiffs))
+	}
+	stmt, err := objDiffs[0].Statement(tengo.StatementModifiers{AllowUnsafe: true})
+	if err != nil || stmt != "ALTER TABLE `countries` MODIFY COLUMN `slug` varchar(80) CHARACTER SET utf8 COLLATE utf8_bin DEFAULT NULL" {
+		t.Errorf("Unexpected statement %q (error %v)", stmt, err)
+	}
+}
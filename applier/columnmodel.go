@@ -0,0 +1,196 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// reColumnSRID matches the SRID attribute of a spatial column, which MySQL 8.0
+// wraps in a version-gated comment in SHOW CREATE TABLE. tengo does not
+// introspect this attribute.
+var reColumnSRID = regexp.MustCompile(` /\*!80003 SRID \d+ \*/`)
+
+// reColumnAttribute matches any column attribute which SHOW CREATE TABLE places
+// after the column's nullability in a version-gated comment, and which tengo
+// does not introspect: the SRID of a spatial column in MySQL 8.0, and the
+// STORAGE and COLUMN_FORMAT attributes in MySQL 5.6+, used by NDB and
+// (for COLUMN_FORMAT COMPRESSED) by Percona Server's column compression.
+// Attributes always appear in this order.
+var reColumnAttribute = regexp.MustCompile(` /\*!80003 SRID \d+ \*/| /\*!506\d\d STORAGE \w+ \*/| /\*!506\d\d COLUMN_FORMAT [^*]+? \*/`)
+
+// reTrailingColumnAttributes matches the attributes modeled by modelColumns at
+// the end of a column's TypeInDB.
+var reTrailingColumnAttributes = regexp.MustCompile(`(?:` + reColumnAttribute.String() + `)+$`)
+
+// reColumnCompressed matches MariaDB's COMPRESSED column attribute, which SHOW
+// CREATE TABLE places immediately after the column's type.
+var reColumnCompressed = regexp.MustCompile(` /\*M?!100301 COMPRESSED(?:=\w+)? ?\*/`)
+
+// modelColumns returns a copy of table's columns, in which any column having
+// attributes in table's CreateStatement which tengo does not introspect has
+// those attributes appended to its TypeInDB: SRID, STORAGE, and COLUMN_FORMAT
+// attributes matched by reColumnAttribute, as well as MariaDB's COMPRESSED
+// attribute if the introspected type lacks it. This way, tengo compares these
+// attributes along with the rest of each column's type, and generates a
+// MODIFY COLUMN whenever they change. Any change to a column's SRID is
+// considered unsafe, since it requires validating existing data and rebuilding
+// any SPATIAL index. The original columns are returned as-is if no column has
+// such attributes.
+func modelColumns(table *tengo.Table) []*tengo.Column {
+	if !strings.Contains(table.CreateStatement, "SRID") && !strings.Contains(table.CreateStatement, "STORAGE") && !strings.Contains(table.CreateStatement, "COLUMN_FORMAT") && !strings.Contains(table.CreateStatement, "COMPRESSED") {
+		return table.Columns
+	}
+	var columns []*tengo.Column
+	for n, col := range table.Columns {
+		line := columnLine(table.CreateStatement, col)
+		typeInDB := col.TypeInDB
+		if compressed := reColumnCompressed.FindString(line); compressed != "" && !strings.Contains(typeInDB, compressed) {
+			typeInDB += compressed
+		}
+		if attributes := strings.Join(reColumnAttribute.FindAllString(line, -1), ""); !strings.HasSuffix(typeInDB, attributes) {
+			typeInDB += attributes
+		}
+		if typeInDB == col.TypeInDB {
+			continue
+		}
+		if columns == nil {
+			columns = make([]*tengo.Column, len(table.Columns))
+			copy(columns, table.Columns)
+		}
+		colCopy := *col
+		colCopy.TypeInDB = typeInDB
+		columns[n] = &colCopy
+	}
+	if columns == nil {
+		return table.Columns
+	}
+	return columns
+}
+
+// splitColumnAttributes splits typeInDB into its base type and any trailing
+// attributes modeled by modelColumns.
+func splitColumnAttributes(typeInDB string) (baseType, attributes string) {
+	attributes = reTrailingColumnAttributes.FindString(typeInDB)
+	return strings.TrimSuffix(typeInDB, attributes), attributes
+}
+
+// columnLine returns the line of createStatement which defines col, or an
+// empty string if no such line is found.
+func columnLine(createStatement string, col *tengo.Column) string {
+	prefix := "  " + tengo.EscapeIdentifier(col.Name) + " "
+	for _, line := range strings.Split(createStatement, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return ""
+}
+
+// columnDefinition returns col's definition clause in the same form as SHOW
+// CREATE TABLE, including attributes which tengo's Column.Definition does not
+// handle properly: attributes modeled by modelColumns are positioned after the
+// column's nullability, other than MariaDB's COMPRESSED which directly follows
+// the type; and on MySQL 8.0.13+ a default expression of a BLOB or TEXT
+// column is included, instead of being omitted.
+func columnDefinition(col *tengo.Column, flavor tengo.Flavor, table *tengo.Table) string {
+	baseType, attributes := splitColumnAttributes(col.TypeInDB)
+	colCopy := *col
+	colCopy.TypeInDB = baseType
+	def := colCopy.Definition(flavor, table)
+	var autoIncrement, onUpdate, comment string
+	if col.AutoIncrement {
+		autoIncrement = " AUTO_INCREMENT"
+	}
+	if col.OnUpdate != "" {
+		onUpdate = fmt.Sprintf(" ON UPDATE %s", col.OnUpdate)
+	}
+	if col.Comment != "" {
+		comment = fmt.Sprintf(" COMMENT '%s'", tengo.EscapeValueForCreateTable(col.Comment))
+	}
+	defaultClause := colCopy.Default.Clause(flavor, &colCopy)
+	if blobDefaultOmitted(&colCopy, flavor) {
+		tail := onUpdate + comment
+		defaultClause = fmt.Sprintf(" DEFAULT %s", col.Default.Value)
+		if col.Default.Quoted {
+			defaultClause = fmt.Sprintf(" DEFAULT '%s'", tengo.EscapeValueForCreateTable(col.Default.Value))
+		}
+		def = def[:len(def)-len(tail)] + defaultClause + tail
+	}
+	if attributes != "" {
+		tail := autoIncrement + defaultClause + onUpdate + comment
+		def = def[:len(def)-len(tail)] + attributes + tail
+	}
+	return def
+}
+
+// blobDefaultOmitted returns true if col is a BLOB or TEXT column with a non-
+// NULL default, which tengo omits from col's definition for flavor.
+func blobDefaultOmitted(col *tengo.Column, flavor tengo.Flavor) bool {
+	if col.Default.Null || col.AutoIncrement || col.GenerationExpr != "" || flavor.AllowBlobDefaults() {
+		return false
+	}
+	baseType, _ := splitColumnAttributes(col.TypeInDB)
+	return strings.HasSuffix(baseType, "blob") || strings.HasSuffix(baseType, "text")
+}
+
+// generatedCreateStatement returns table's CREATE TABLE as generated by tengo,
+// with column definitions corrected by columnDefinition.
+func generatedCreateStatement(table *tengo.Table, flavor tengo.Flavor) string {
+	return fixColumnDefinitions(table.GeneratedCreateStatement(flavor), table, flavor)
+}
+
+// fixColumnDefinitions adjusts a CREATE TABLE or ALTER TABLE statement
+// generated by tengo, replacing the definition of any column of table that is
+// affected by attributes modeled by modelColumns or BLOB/TEXT default
+// expressions with the corrected definition from columnDefinition. For ALTER TABLE statements, table
+// should be the desired (new) version of the table.
+func fixColumnDefinitions(stmt string, table *tengo.Table, flavor tengo.Flavor) string {
+	if table == nil {
+		return stmt
+	}
+	for _, col := range table.Columns {
+		if !reTrailingColumnAttributes.MatchString(col.TypeInDB) && !blobDefaultOmitted(col, flavor) {
+			continue
+		}
+		generated := col.Definition(flavor, table)
+		for _, prefix := range []string{"  ", "ADD COLUMN ", "MODIFY COLUMN "} {
+			stmt = strings.Replace(stmt, prefix+generated, prefix+columnDefinition(col, flavor, table), -1)
+		}
+	}
+	return stmt
+}
+
+// sridNote returns a note describing the first column whose SRID attribute is
+// changed by td, or an empty string if no column's SRID changes. Such changes
+// are always considered unsafe, since the server must validate that existing
+// values use the new SRID, and cannot use a SPATIAL index on a column lacking
+// an SRID.
+func sridNote(td *tengo.TableDiff) string {
+	if td.Type != tengo.DiffTypeAlter || td.From == nil || td.To == nil {
+		return ""
+	}
+	fromCols := td.From.ColumnsByName()
+	for _, toCol := range td.To.Columns {
+		fromCol, ok := fromCols[toCol.Name]
+		if !ok || fromCol.TypeInDB == toCol.TypeInDB {
+			continue
+		}
+		fromSRID, toSRID := reColumnSRID.FindString(fromCol.TypeInDB), reColumnSRID.FindString(toCol.TypeInDB)
+		if fromSRID != toSRID && reColumnSRID.ReplaceAllString(fromCol.TypeInDB, "") == reColumnSRID.ReplaceAllString(toCol.TypeInDB, "") {
+			return fmt.Sprintf("column %s SRID change from %s to %s requires validating all existing values", tengo.EscapeIdentifier(toCol.Name), describeSRID(fromSRID), describeSRID(toSRID))
+		}
+	}
+	return ""
+}
+
+// describeSRID returns a description of an SRID attribute matched by
+// reColumnSRID, for use in notes.
+func describeSRID(srid string) string {
+	if srid == "" {
+		return "none"
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(srid, " /*!80003 SRID "), " */")
+}
@@ -0,0 +1,230 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// columnModelFixture returns a schema containing a single table, simulating
+// tengo's introspection of the SHOW CREATE TABLE output in the supplied
+// testdata file: tengo does not introspect SRID attributes, and omits default
+// expressions of BLOB and TEXT columns when generating a CREATE TABLE, so the
+// table is marked as unsupported.
+func columnModelFixture(t *testing.T, fileName, notesDefault string, extraDefault tengo.ColumnDefault) *tengo.Schema {
+	t.Helper()
+	flavor := tengo.FlavorMySQL80
+	table := &tengo.Table{
+		Name:               "places",
+		Engine:             "InnoDB",
+		CharSet:            "utf8mb4",
+		Collation:          "utf8mb4_0900_ai_ci",
+		CollationIsDefault: true,
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int unsigned", Default: tengo.ColumnDefaultNull},
+			{Name: "loc", TypeInDB: "point", Default: tengo.ColumnDefaultNull},
+			{Name: "area", TypeInDB: "polygon", Nullable: true, Default: tengo.ColumnDefaultNull, Comment: "boundary"},
+			{Name: "notes", TypeInDB: "text", Default: tengo.ColumnDefaultExpression(notesDefault), CharSet: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", CollationIsDefault: true},
+			{Name: "extra", TypeInDB: "blob", Nullable: true, Default: extraDefault, Comment: "raw"},
+		},
+	}
+	table.PrimaryKey = &tengo.Index{Name: "PRIMARY", Columns: table.Columns[0:1], SubParts: []uint16{0}, PrimaryKey: true, Unique: true}
+	table.CreateStatement = strings.TrimSpace(fs.ReadTestFile(t, "testdata/columnmodel/"+fileName))
+	if table.GeneratedCreateStatement(flavor) == table.CreateStatement {
+		t.Fatalf("Test setup problem: expected tengo to be unable to generate %s", fileName)
+	}
+	table.UnsupportedDDL = true
+	return &tengo.Schema{Name: "geo", CharSet: "utf8mb4", Collation: "utf8mb4_0900_ai_ci", Tables: []*tengo.Table{table}}
+}
+
+func TestModelColumns(t *testing.T) {
+	flavor := tengo.FlavorMySQL80
+	orig := columnModelFixture(t, "mysql80.sql", "(_utf8mb4'none')", tengo.ColumnDefaultExpression("(_utf8mb4'')"))
+	from := modelTableOptions(orig, flavor)
+	table := from.Tables[0]
+	if table.UnsupportedDDL {
+		t.Fatalf("Expected table to be supported after modeling, but it was not. Generated CREATE:\n%s", generatedCreateStatement(table, flavor))
+	}
+	if table.Columns[1].TypeInDB != "point /*!80003 SRID 4326 */" || table.Columns[2].TypeInDB != "polygon /*!80003 SRID 4326 */" {
+		t.Errorf("Unexpected modeled column types: %q, %q", table.Columns[1].TypeInDB, table.Columns[2].TypeInDB)
+	}
+	if orig.Tables[0].Columns[1].TypeInDB != "point" || !orig.Tables[0].UnsupportedDDL {
+		t.Error("Expected original schema to be unmodified, but it was changed")
+	}
+	if table.CreateStatement != orig.Tables[0].CreateStatement {
+		t.Error("Expected CreateStatement to be left as-is")
+	}
+
+	altered := columnModelFixture(t, "mysql80_altered.sql", "(_utf8mb4'unknown')", tengo.ColumnDefaultNull)
+	to := modelTableOptions(altered, flavor)
+	if to.Tables[0].UnsupportedDDL {
+		t.Fatal("Expected altered table to be supported after modeling, but it was not")
+	}
+	objDiffs := tengo.NewSchemaDiff(from, to).ObjectDiffs()
+	if len(objDiffs) != 1 {
+		t.Fatalf("Expected 1 diff, instead found %d", len(objDiffs))
+	}
+	td := objDiffs[0].(*tengo.TableDiff)
+	if _, err := td.Statement(tengo.StatementModifiers{Flavor: flavor}); !tengo.IsForbiddenDiff(err) {
+		t.Errorf("Expected SRID changes to be considered unsafe, instead err=%v", err)
+	}
+	stmt, err := td.Statement(tengo.StatementModifiers{Flavor: flavor, AllowUnsafe: true})
+	if err != nil {
+		t.Fatalf("Unexpected error from Statement: %s", err)
+	}
+	stmt = fixColumnDefinitions(stmt, td.To, flavor)
+	for _, expected := range []string{
+		"MODIFY COLUMN `loc` point NOT NULL /*!80003 SRID 0 */",
+		"MODIFY COLUMN `area` polygon DEFAULT NULL COMMENT 'boundary'",
+		"MODIFY COLUMN `notes` text NOT NULL DEFAULT (_utf8mb4'unknown')",
+		"MODIFY COLUMN `extra` blob COMMENT 'raw'",
+	} {
+		if !strings.Contains(stmt, expected) {
+			t.Errorf("Expected statement to contain %q, instead found %q", expected, stmt)
+		}
+	}
+	if note := sridNote(td); note != "column `loc` SRID change from 4326 to 0 requires validating all existing values" {
+		t.Errorf("Unexpected result from sridNote: %q", note)
+	}
+
+	// Reverse direction re-adds the default expression of the BLOB column
+	td = tengo.NewSchemaDiff(to, from).ObjectDiffs()[0].(*tengo.TableDiff)
+	stmt, _ = td.Statement(tengo.StatementModifiers{Flavor: flavor, AllowUnsafe: true})
+	if stmt = fixColumnDefinitions(stmt, td.To, flavor); !strings.Contains(stmt, "MODIFY COLUMN `extra` blob DEFAULT (_utf8mb4'') COMMENT 'raw'") {
+		t.Errorf("Unexpected statement: %q", stmt)
+	}
+
+	// Tables with other unsupported features remain unsupported
+	other := columnModelFixture(t, "mysql80.sql", "(_utf8mb4'none')", tengo.ColumnDefaultExpression("(_utf8mb4'')"))
+	other.Tables[0].CreateStatement = strings.Replace(other.Tables[0].CreateStatement, "`id` int unsigned NOT NULL", "`id` int unsigned NOT NULL /*!80023 INVISIBLE */", 1)
+	if schema := modelTableOptions(other, flavor); !schema.Tables[0].UnsupportedDDL {
+		t.Error("Expected table with other unsupported features to remain unsupported")
+	}
+}
+
+// storageAttributeFixture returns a schema containing a single table, simulating
+// tengo's introspection of the SHOW CREATE TABLE output in the supplied
+// testdata file, which uses column attributes that tengo does not introspect.
+func storageAttributeFixture(t *testing.T, fileName string, flavor tengo.Flavor, columns ...*tengo.Column) *tengo.Schema {
+	t.Helper()
+	table := &tengo.Table{
+		Name:               "events",
+		Engine:             "InnoDB",
+		CharSet:            "latin1",
+		Collation:          "latin1_swedish_ci",
+		CollationIsDefault: true,
+		Columns:            columns,
+	}
+	table.PrimaryKey = &tengo.Index{Name: "PRIMARY", Columns: table.Columns[0:1], SubParts: []uint16{0}, PrimaryKey: true, Unique: true}
+	table.CreateStatement = strings.TrimSpace(fs.ReadTestFile(t, "testdata/columnmodel/"+fileName))
+	table.UnsupportedDDL = table.GeneratedCreateStatement(flavor) != table.CreateStatement
+	return &tengo.Schema{Name: "analytics", CharSet: "latin1", Collation: "latin1_swedish_ci", Tables: []*tengo.Table{table}}
+}
+
+func TestModelColumnsStorageAttributes(t *testing.T) {
+	flavor := tengo.FlavorMySQL57
+	newColumns := func() []*tengo.Column {
+		return []*tengo.Column{
+			{Name: "id", TypeInDB: "bigint(20) unsigned", AutoIncrement: true, Default: tengo.ColumnDefaultNull},
+			{Name: "counter", TypeInDB: "int(11)", Default: tengo.ColumnDefaultValue("0")},
+			{Name: "label", TypeInDB: "varchar(40)", Nullable: true, Default: tengo.ColumnDefaultNull, Comment: "display"},
+			{Name: "payload", TypeInDB: "blob", Nullable: true, Default: tengo.ColumnDefaultNull},
+		}
+	}
+	orig := storageAttributeFixture(t, "ndb.sql", flavor, newColumns()...)
+	if !orig.Tables[0].UnsupportedDDL {
+		t.Fatal("Test setup problem: expected tengo to be unable to generate ndb.sql")
+	}
+	from := modelTableOptions(orig, flavor)
+	table := from.Tables[0]
+	if table.UnsupportedDDL {
+		t.Fatalf("Expected table to be supported after modeling, but it was not. Generated CREATE:\n%s", generatedCreateStatement(table, flavor))
+	}
+	expectedTypes := []string{
+		"bigint(20) unsigned /*!50606 COLUMN_FORMAT FIXED */",
+		"int(11) /*!50606 STORAGE MEMORY */ /*!50606 COLUMN_FORMAT DYNAMIC */",
+		"varchar(40) /*!50606 STORAGE DISK */",
+		"blob",
+	}
+	for n, col := range table.Columns {
+		if col.TypeInDB != expectedTypes[n] {
+			t.Errorf("Expected column %s to be modeled as %q, instead found %q", col.Name, expectedTypes[n], col.TypeInDB)
+		}
+	}
+
+	// Removing the attributes on the database side generates a MODIFY COLUMN
+	// for each affected column, and re-adding them restores each attribute in
+	// its proper position
+	plain := storageAttributeFixture(t, "ndb_plain.sql", flavor, newColumns()...)
+	if plain.Tables[0].UnsupportedDDL {
+		t.Fatal("Test setup problem: expected tengo to be able to generate ndb_plain.sql")
+	}
+	to := modelTableOptions(plain, flavor)
+	td := tengo.NewSchemaDiff(to, from).ObjectDiffs()[0].(*tengo.TableDiff)
+	stmt, err := td.Statement(tengo.StatementModifiers{Flavor: flavor})
+	if err != nil {
+		t.Fatalf("Unexpected error from Statement: %s", err)
+	}
+	stmt = fixColumnDefinitions(stmt, td.To, flavor)
+	for _, expected := range []string{
+		"MODIFY COLUMN `id` bigint(20) unsigned NOT NULL /*!50606 COLUMN_FORMAT FIXED */ AUTO_INCREMENT",
+		"MODIFY COLUMN `counter` int(11) NOT NULL /*!50606 STORAGE MEMORY */ /*!50606 COLUMN_FORMAT DYNAMIC */ DEFAULT '0'",
+		"MODIFY COLUMN `label` varchar(40) /*!50606 STORAGE DISK */ DEFAULT NULL COMMENT 'display'",
+	} {
+		if !strings.Contains(stmt, expected) {
+			t.Errorf("Expected statement to contain %q, instead found %q", expected, stmt)
+		}
+	}
+	if strings.Contains(stmt, "`payload`") {
+		t.Errorf("Expected unchanged column to be omitted from statement, instead found %q", stmt)
+	}
+	td = tengo.NewSchemaDiff(from, to).ObjectDiffs()[0].(*tengo.TableDiff)
+	stmt, _ = td.Statement(tengo.StatementModifiers{Flavor: flavor})
+	if stmt = fixColumnDefinitions(stmt, td.To, flavor); !strings.Contains(stmt, "MODIFY COLUMN `counter` int(11) NOT NULL DEFAULT '0'") || strings.Contains(stmt, "STORAGE") || strings.Contains(stmt, "COLUMN_FORMAT") {
+		t.Errorf("Unexpected statement: %q", stmt)
+	}
+}
+
+func TestModelColumnsCompressed(t *testing.T) {
+	flavor := tengo.FlavorMariaDB103
+	newColumns := func() []*tengo.Column {
+		return []*tengo.Column{
+			{Name: "id", TypeInDB: "bigint(20) unsigned", Default: tengo.ColumnDefaultNull},
+			{Name: "body", TypeInDB: "text", Nullable: true, Default: tengo.ColumnDefaultNull},
+			{Name: "summary", TypeInDB: "varchar(1000)", Nullable: true, Default: tengo.ColumnDefaultNull},
+		}
+	}
+	orig := storageAttributeFixture(t, "mariadb.sql", flavor, newColumns()...)
+	from := modelTableOptions(orig, flavor)
+	table := from.Tables[0]
+	if table.UnsupportedDDL {
+		t.Fatalf("Expected table to be supported after modeling, but it was not. Generated CREATE:\n%s", generatedCreateStatement(table, flavor))
+	}
+	if table.Columns[1].TypeInDB != "text /*M!100301 COMPRESSED*/" || table.Columns[2].TypeInDB != "varchar(1000)" {
+		t.Errorf("Unexpected modeled column types: %q, %q", table.Columns[1].TypeInDB, table.Columns[2].TypeInDB)
+	}
+
+	// If the introspected type already includes the attribute, it is not
+	// duplicated
+	columns := newColumns()
+	columns[1].TypeInDB = "text /*M!100301 COMPRESSED*/"
+	already := storageAttributeFixture(t, "mariadb.sql", flavor, columns...)
+	if cols := modelColumns(already.Tables[0]); cols[1].TypeInDB != "text /*M!100301 COMPRESSED*/" {
+		t.Errorf("Unexpected modeled column type: %q", cols[1].TypeInDB)
+	}
+
+	// Compressing another column generates a MODIFY COLUMN including the
+	// attribute directly after the type
+	altered := storageAttributeFixture(t, "mariadb_altered.sql", flavor, newColumns()...)
+	to := modelTableOptions(altered, flavor)
+	td := tengo.NewSchemaDiff(from, to).ObjectDiffs()[0].(*tengo.TableDiff)
+	stmt, err := td.Statement(tengo.StatementModifiers{Flavor: flavor})
+	if err != nil {
+		t.Fatalf("Unexpected error from Statement: %s", err)
+	}
+	if stmt = fixColumnDefinitions(stmt, td.To, flavor); !strings.Contains(stmt, "MODIFY COLUMN `summary` varchar(1000) /*M!100301 COMPRESSED*/ DEFAULT NULL") || strings.Contains(stmt, "`body`") {
+		t.Errorf("Unexpected statement: %q", stmt)
+	}
+}
@@ -0,0 +1,78 @@
+package applier
+
+import (
+	"fmt"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// reStatementCommentVar matches variable placeholders of format "{VARNAME}" in
+// the statement-comment option.
+var reStatementCommentVar = regexp.MustCompile(`{([^}]*)}`)
+
+// statementComment returns the SQL block comment which should prefix each
+// statement executed against t, obtained by interpolating the
+// statement-comment option. This allows statements run by Skeema to be
+// attributed when viewed in the server's processlist. A blank string is
+// returned if statement-comment is not in use. An error is returned if the
+// option contains any unknown variable placeholders.
+func (t *Target) statementComment() (string, error) {
+	if t.Dir.Config.FindOption("statement-comment") == nil {
+		return "", nil
+	}
+	template := strings.TrimSpace(t.Dir.Config.Get("statement-comment"))
+	if template == "" {
+		return "", nil
+	}
+	var port string
+	if t.Instance.SocketPath == "" {
+		port = strconv.Itoa(t.Instance.Port)
+	}
+	variables := map[string]string{
+		"USER":        statementCommentUser(t),
+		"HOST":        t.Instance.Host,
+		"PORT":        port,
+		"SCHEMA":      t.SchemaName,
+		"ENVIRONMENT": t.Dir.Config.Get("environment"),
+		"DIR":         t.Dir.RelPath(),
+		"DIRNAME":     t.Dir.BaseName(),
+		"DIRPATH":     t.Dir.Path,
+		"LABEL":       t.Dir.Config.Get("label"),
+	}
+	var err error
+	comment := reStatementCommentVar.ReplaceAllStringFunc(template, func(input string) string {
+		value, ok := variables[strings.ToUpper(input[1:len(input)-1])]
+		if !ok {
+			err = fmt.Errorf("Option statement-comment contains unknown variable %s", input)
+			return input
+		}
+		return value
+	})
+	return "/* " + strings.Replace(comment, "*/", "* /", -1) + " */", err
+}
+
+// statementCommentUser returns the username to include in statement comments:
+// the statement-comment-user option if set, or else the OS username of the
+// user running Skeema.
+func statementCommentUser(t *Target) string {
+	if t.Dir.Config.FindOption("statement-comment-user") != nil {
+		if override := t.Dir.Config.Get("statement-comment-user"); override != "" {
+			return override
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// withComment returns stmt prefixed by comment, or stmt as-is if comment is
+// blank.
+func withComment(comment, stmt string) string {
+	if comment == "" {
+		return stmt
+	}
+	return comment + " " + stmt
+}
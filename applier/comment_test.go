@@ -0,0 +1,66 @@
+package applier
+
+import (
+	"context"
+	"os/user"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestTargetStatementComment(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	osUser := "unknown"
+	if u, err := user.Current(); err == nil {
+		osUser = u.Username
+	}
+	expected := "/* skeema:push user=" + osUser + " dir=" + target.Dir.RelPath() + " env=production */"
+	if comment, err := target.statementComment(); comment != expected || err != nil {
+		t.Errorf("Expected default comment %q, instead found %q, %v", expected, comment, err)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--statement-comment='app {user} {SCHEMA}@{host}:{PORT} {dirname} {LABEL}' --statement-comment-user=jane --label='*/ DROP'")
+	expected = "/* app jane product@127.0.0.1:3306 one * / DROP */"
+	if comment, err := target.statementComment(); comment != expected || err != nil {
+		t.Errorf("Expected comment %q, instead found %q, %v", expected, comment, err)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--statement-comment=''")
+	if comment, err := target.statementComment(); comment != "" || err != nil {
+		t.Errorf("Expected blank statement-comment to result in no comment, instead found %q, %v", comment, err)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--statement-comment='skeema {SHARD}'")
+	if _, err := target.statementComment(); err == nil {
+		t.Error("Expected error from unknown variable in statement-comment, but err was nil")
+	}
+	if _, err := applyTarget(context.Background(), target, NewPrinter(false)); err == nil {
+		t.Error("Expected error from applyTarget with invalid statement-comment, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok {
+		t.Errorf("Expected ConfigError, instead found %T: %s", err, err)
+	}
+}
+
+func TestDDLStatementCommentShellOut(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	target.Dir = getDir(t, "testdata/simple/one", "--statement-comment='skeema {SCHEMA}' --ddl-wrapper='/bin/echo {DDL}' --dry-run")
+	table := &tengo.Table{
+		Name:            "widgets",
+		CreateStatement: "CREATE TABLE `widgets` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1",
+	}
+	ddl, err := NewDDLStatement(tengo.NewCreateTable(table), tengo.StatementModifiers{}, target)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDDLStatement: %s", err)
+	}
+
+	// The comment must not appear in output, but must be included in the
+	// executed command's {DDL}
+	if strings.Contains(ddl.String(), "skeema product") {
+		t.Errorf("Expected String() to omit statement comment, instead found %q", ddl.String())
+	}
+	expected := "/* skeema product */ " + table.CreateStatement + "\n"
+	if output, err := ddl.execShellOut.RunCapture(); err != nil || output != expected {
+		t.Errorf("Expected executed shell-out to output %q, instead found %q, %v", expected, output, err)
+	}
+}
@@ -0,0 +1,216 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/skeema/tengo"
+)
+
+// ComparedSchema is a live schema included in a cross-schema comparison,
+// along with a name identifying it in reports, typically "host:port:schema".
+type ComparedSchema struct {
+	Name   string
+	Schema *tengo.Schema
+}
+
+// CompareReport is the result of CompareSchemas.
+type CompareReport struct {
+	Schemas         []string           `json:"schemas"`
+	Reference       string             `json:"reference,omitempty"` // blank if outliers are relative to the majority definitions
+	ConsistentCount int                `json:"consistent_objects"`
+	Objects         []ObjectComparison `json:"differing_objects"`
+}
+
+// ObjectComparison describes how a single schema attribute or object varies
+// between the compared schemas.
+type ObjectComparison struct {
+	Object   string            `json:"object"`   // e.g. "table `foo`" or "default collation"
+	Variants []DefinitionGroup `json:"variants"` // baseline definition first
+	Outliers []Outlier         `json:"outliers"`
+}
+
+// DefinitionGroup is a set of schemas sharing an identical definition of an
+// object. A blank Definition means the object does not exist in these schemas.
+type DefinitionGroup struct {
+	Definition string   `json:"definition"`
+	Schemas    []string `json:"schemas"`
+}
+
+// Outlier is a schema whose definition of an object differs from the
+// baseline, along with the DDL which would bring it in line. If DDL cannot be
+// generated, Error describes why.
+type Outlier struct {
+	Schema     string   `json:"schema"`
+	Variant    int      `json:"variant"` // index into the ObjectComparison's Variants
+	Statements []string `json:"statements"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// Consistent returns true if the report found no differences.
+func (cr *CompareReport) Consistent() bool {
+	return len(cr.Objects) == 0
+}
+
+// OutlierCount returns the number of distinct schemas which are an outlier in
+// at least one respect.
+func (cr *CompareReport) OutlierCount() int {
+	seen := make(map[string]bool)
+	for _, obj := range cr.Objects {
+		for _, o := range obj.Outliers {
+			seen[o.Schema] = true
+		}
+	}
+	return len(seen)
+}
+
+// CompareSchemas compares the definitions of all attributes and objects
+// across schemas. For each one that is not identical in every schema, the
+// baseline definition is that of the schema named by reference, or the most
+// common definition if reference is blank. Ties are broken in favor of
+// whichever definition appears in the earliest schema. Each schema with a
+// non-baseline definition is reported as an outlier, along with the DDL to
+// convert it to the baseline, as generated using mods. As with
+// CheckConsistency, table definitions exclude AUTO_INCREMENT values.
+func CompareSchemas(schemas []ComparedSchema, reference string, mods tengo.StatementModifiers) (*CompareReport, error) {
+	report := &CompareReport{
+		Schemas:   make([]string, len(schemas)),
+		Reference: reference,
+		Objects:   []ObjectComparison{},
+	}
+	refIndex := -1
+	defs := make([]map[string]string, len(schemas))
+	allKeys := make(map[string]bool)
+	for n, cs := range schemas {
+		report.Schemas[n] = cs.Name
+		if cs.Name == reference {
+			refIndex = n
+		}
+		defs[n] = shardDefinitions(cs.Schema)
+		for desc := range defs[n] {
+			allKeys[desc] = true
+		}
+	}
+	if reference != "" && refIndex < 0 {
+		return nil, fmt.Errorf("Reference schema %s is not among the compared schemas", reference)
+	}
+	descs := make([]string, 0, len(allKeys))
+	for desc := range allKeys {
+		descs = append(descs, desc)
+	}
+	sort.Strings(descs)
+
+	// Diffs between pairs of schemas are shared across all of their differing
+	// objects
+	type schemaPair struct{ from, to int }
+	diffs := make(map[schemaPair][]tengo.ObjectDiff)
+	diffsFor := func(from, to int) []tengo.ObjectDiff {
+		pair := schemaPair{from, to}
+		if _, ok := diffs[pair]; !ok {
+			diffs[pair] = tengo.NewSchemaDiff(schemas[from].Schema, schemas[to].Schema).ObjectDiffs()
+		}
+		return diffs[pair]
+	}
+
+	for _, desc := range descs {
+		variants, variantOf := groupDefinitions(desc, defs, report.Schemas)
+		if len(variants) < 2 {
+			report.ConsistentCount++
+			continue
+		}
+		baseline := 0
+		if refIndex >= 0 {
+			baseline = variantOf[refIndex]
+		} else {
+			for v := range variants {
+				if len(variants[v].Schemas) > len(variants[baseline].Schemas) {
+					baseline = v
+				}
+			}
+		}
+		// Reorder so that the baseline variant is first
+		order := append([]int{baseline}, rangeExcept(len(variants), baseline)...)
+		newIndex := make([]int, len(variants))
+		obj := ObjectComparison{Object: desc, Variants: make([]DefinitionGroup, len(variants))}
+		for newPos, oldPos := range order {
+			obj.Variants[newPos] = variants[oldPos]
+			newIndex[oldPos] = newPos
+		}
+
+		// Any schema having the baseline definition can serve as the target of
+		// the diff, so use the reference schema if there is one, or otherwise
+		// whichever schema with that definition appears first
+		exemplar := refIndex
+		if exemplar < 0 {
+			for n := range schemas {
+				if variantOf[n] == baseline {
+					exemplar = n
+					break
+				}
+			}
+		}
+		for n := range schemas {
+			if variantOf[n] == baseline {
+				continue
+			}
+			outlier := Outlier{Schema: schemas[n].Name, Variant: newIndex[variantOf[n]], Statements: []string{}}
+			for _, diff := range diffsFor(n, exemplar) {
+				if !diffAffects(diff, desc) {
+					continue
+				}
+				stmt, err := diff.Statement(mods)
+				if err != nil && !tengo.IsForbiddenDiff(err) {
+					outlier.Error = err.Error()
+				} else if stmt != "" {
+					outlier.Statements = append(outlier.Statements, stmt)
+				}
+			}
+			if len(outlier.Statements) == 0 && outlier.Error == "" {
+				outlier.Error = "definitions differ only in ways which DDL cannot express, such as formatting from an older server version"
+			}
+			obj.Outliers = append(obj.Outliers, outlier)
+		}
+		report.Objects = append(report.Objects, obj)
+	}
+	return report, nil
+}
+
+// groupDefinitions groups the schemas by their definition of the attribute or
+// object desc, in order of first appearance. The second return value maps
+// each schema's position to the index of its group.
+func groupDefinitions(desc string, defs []map[string]string, names []string) (variants []DefinitionGroup, variantOf []int) {
+	variantOf = make([]int, len(defs))
+	positions := make(map[string]int)
+	for n, schemaDefs := range defs {
+		def := schemaDefs[desc]
+		pos, ok := positions[def]
+		if !ok {
+			pos = len(variants)
+			positions[def] = pos
+			variants = append(variants, DefinitionGroup{Definition: def})
+		}
+		variants[pos].Schemas = append(variants[pos].Schemas, names[n])
+		variantOf[n] = pos
+	}
+	return variants, variantOf
+}
+
+// diffAffects returns true if diff affects the attribute or object described
+// by desc, using the same descriptions as shardDefinitions. Database-level
+// diffs affect both the default character set and default collation.
+func diffAffects(diff tengo.ObjectDiff, desc string) bool {
+	if _, ok := diff.(*tengo.DatabaseDiff); ok {
+		return desc == "default character set" || desc == "default collation"
+	}
+	return diff.ObjectKey().String() == desc
+}
+
+func rangeExcept(n, except int) []int {
+	result := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if i != except {
+			result = append(result, i)
+		}
+	}
+	return result
+}
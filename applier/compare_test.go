@@ -0,0 +1,77 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestCompareSchemas(t *testing.T) {
+	makeSchema := func(name, collation string, tables ...*tengo.Table) ComparedSchema {
+		return ComparedSchema{
+			Name:   "db1:3306:" + name,
+			Schema: &tengo.Schema{Name: name, CharSet: "utf8mb4", Collation: collation, Tables: tables},
+		}
+	}
+	commented := orderTestTable("users", false)
+	commented.Comment = "hello"
+	commented.CreateStatement = commented.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	schemas := []ComparedSchema{
+		makeSchema("shard1", "utf8mb4_general_ci", orderTestTable("users", false), orderTestTable("posts", true)),
+		makeSchema("shard2", "utf8mb4_general_ci", commented, orderTestTable("posts", true)),
+		makeSchema("shard3", "utf8mb4_general_ci", orderTestTable("users", false), orderTestTable("posts", true)),
+		makeSchema("shard4", "utf8mb4_bin", orderTestTable("users", false)),
+	}
+	mods := tengo.StatementModifiers{AllowUnsafe: true, NextAutoInc: tengo.NextAutoIncIgnore}
+	report, err := CompareSchemas(schemas, "", mods)
+	if err != nil {
+		t.Fatalf("Unexpected error from CompareSchemas: %s", err)
+	}
+	if report.Consistent() || report.ConsistentCount != 1 || report.OutlierCount() != 2 {
+		t.Fatalf("Unexpected report: consistent=%d outliers=%d", report.ConsistentCount, report.OutlierCount())
+	}
+	expected := map[string]string{
+		"default collation": "db1:3306:shard4 ALTER DATABASE `shard4` COLLATE utf8mb4_general_ci",
+		"table `posts`":     "db1:3306:shard4 CREATE TABLE `posts`",
+		"table `users`":     "db1:3306:shard2 ALTER TABLE `users` COMMENT ''",
+	}
+	if len(report.Objects) != len(expected) {
+		t.Fatalf("Expected %d differing objects, instead found %d", len(expected), len(report.Objects))
+	}
+	for _, obj := range report.Objects {
+		if len(obj.Outliers) != 1 || len(obj.Outliers[0].Statements) != 1 {
+			t.Errorf("Unexpected outliers for %s: %+v", obj.Object, obj.Outliers)
+			continue
+		}
+		outlier := obj.Outliers[0]
+		if actual := outlier.Schema + " " + outlier.Statements[0]; !strings.HasPrefix(actual, expected[obj.Object]) {
+			t.Errorf("Unexpected outlier for %s: found %q, expected prefix %q", obj.Object, actual, expected[obj.Object])
+		}
+		if len(obj.Variants) != 2 || len(obj.Variants[0].Schemas) != 3 || outlier.Variant != 1 {
+			t.Errorf("Unexpected variants for %s: %+v", obj.Object, obj.Variants)
+		}
+	}
+
+	// With a reference schema, its definitions are the baseline even if they're
+	// a minority
+	report, err = CompareSchemas(schemas, "db1:3306:shard2", mods)
+	if err != nil {
+		t.Fatalf("Unexpected error from CompareSchemas: %s", err)
+	}
+	for _, obj := range report.Objects {
+		if obj.Object == "table `users`" {
+			if len(obj.Outliers) != 3 || obj.Variants[0].Schemas[0] != "db1:3306:shard2" {
+				t.Errorf("Expected reference schema to be the baseline for %s, instead found %+v", obj.Object, obj)
+			}
+		}
+	}
+	if _, err := CompareSchemas(schemas, "db1:3306:shard9", mods); err == nil {
+		t.Error("Expected error from nonexistent reference, but err was nil")
+	}
+
+	// Identical schemas are consistent
+	if report, err := CompareSchemas(schemas[0:1], "", mods); err != nil || !report.Consistent() {
+		t.Errorf("Expected a single schema to be consistent, instead found %+v, %v", report, err)
+	}
+}
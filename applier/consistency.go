@@ -0,0 +1,132 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/tengo"
+)
+
+// CheckConsistency compares the live schemas of targets which were generated
+// from the same directory and logical schema -- typically a set of shards
+// matched by a schema wildcard or shellout -- against each other, rather than
+// against the filesystem. Any schema defaults or object definitions that vary
+// between these shards are logged as warnings, naming the outlier shards. Only
+// targets whose dir has check-consistency enabled are examined.
+//
+// The return value is the number of distinct shards found to be outliers in at
+// least one respect.
+func CheckConsistency(targets []*Target) (outlierCount int) {
+	type groupKey struct {
+		dirPath string
+		schema  interface{}
+	}
+	var keys []groupKey
+	groups := make(map[groupKey][]*Target)
+	for _, t := range targets {
+		if !t.Dir.Config.GetBool("check-consistency") {
+			continue
+		}
+		key := groupKey{dirPath: t.Dir.Path, schema: t.DesiredSchema}
+		if _, already := groups[key]; !already {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(group))
+		defs := make([]map[string]string, 0, len(group))
+		for _, t := range group {
+			schema, err := t.SchemaFromInstance()
+			if err != nil {
+				t.logger().Warnf("Unable to check consistency of %s %s: %s", t.Instance, t.SchemaName, err)
+				continue
+			} else if schema == nil {
+				t.logger().Debugf("Excluding %s %s from consistency check: schema does not exist yet", t.Instance, t.SchemaName)
+				continue
+			}
+			names = append(names, fmt.Sprintf("%s:%s", t.Instance, t.SchemaName))
+			defs = append(defs, shardDefinitions(schema))
+		}
+		outliers := consistencyOutliers(names, defs)
+		if len(outliers) == 0 {
+			log.Infof("%s: all %s have consistent definitions", group[0].Dir, countAndNoun(len(names), "shard"))
+			continue
+		}
+		descriptions := make([]string, 0, len(outliers))
+		for desc := range outliers {
+			descriptions = append(descriptions, desc)
+		}
+		sort.Strings(descriptions)
+		seen := make(map[string]bool)
+		for _, desc := range descriptions {
+			log.Warnf("%s: %s differs from the other shards on %s", group[0].Dir, desc, strings.Join(outliers[desc], ", "))
+			for _, name := range outliers[desc] {
+				seen[name] = true
+			}
+		}
+		outlierCount += len(seen)
+	}
+	return outlierCount
+}
+
+// shardDefinitions returns a map of human-readable descriptions of schema
+// attributes and objects to their definitions in the supplied schema. Table
+// definitions exclude AUTO_INCREMENT values, since these are expected to vary
+// between shards.
+func shardDefinitions(schema *tengo.Schema) map[string]string {
+	defs := map[string]string{
+		"default character set": schema.CharSet,
+		"default collation":     schema.Collation,
+	}
+	for key, create := range schema.ObjectDefinitions() {
+		if key.Type == tengo.ObjectTypeTable {
+			create, _ = tengo.ParseCreateAutoInc(create)
+		}
+		defs[key.String()] = create
+	}
+	return defs
+}
+
+// consistencyOutliers compares the supplied per-shard definitions, which must
+// be parallel to the supplied shard names. For each attribute or object which
+// is not identical across all shards, the most common definition is treated
+// as correct, and the names of shards with any other definition (including
+// missing the object entirely) are returned. Ties are broken in favor of
+// whichever definition appears in the earliest shard.
+func consistencyOutliers(names []string, defs []map[string]string) map[string][]string {
+	allKeys := make(map[string]bool)
+	for _, shardDefs := range defs {
+		for desc := range shardDefs {
+			allKeys[desc] = true
+		}
+	}
+	outliers := make(map[string][]string)
+	for desc := range allKeys {
+		counts := make(map[string]int)
+		var majority string
+		for n, shardDefs := range defs {
+			val := shardDefs[desc]
+			counts[val]++
+			if n == 0 || counts[val] > counts[majority] {
+				majority = val
+			}
+		}
+		if len(counts) < 2 {
+			continue
+		}
+		for n, shardDefs := range defs {
+			if shardDefs[desc] != majority {
+				outliers[desc] = append(outliers[desc], names[n])
+			}
+		}
+	}
+	return outliers
+}
@@ -0,0 +1,34 @@
+package applier
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConsistencyOutliers(t *testing.T) {
+	names := []string{"db1:3306:shard1", "db1:3306:shard2", "db2:3306:shard3", "db2:3306:shard4"}
+	defs := []map[string]string{
+		{"default collation": "utf8mb4_general_ci", "table `foo`": "CREATE TABLE foo (id int)"},
+		{"default collation": "utf8mb4_general_ci", "table `foo`": "CREATE TABLE foo (id int)"},
+		{"default collation": "latin1_swedish_ci", "table `foo`": "CREATE TABLE foo (id int)", "table `bar`": "CREATE TABLE bar (id int)"},
+		{"default collation": "utf8mb4_general_ci", "table `foo`": "CREATE TABLE foo (id bigint)"},
+	}
+	expected := map[string][]string{
+		"default collation": {"db2:3306:shard3"},
+		"table `foo`":       {"db2:3306:shard4"},
+		"table `bar`":       {"db2:3306:shard3"},
+	}
+	if actual := consistencyOutliers(names, defs); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected result from consistencyOutliers: %v", actual)
+	}
+
+	// Ties should favor the definition found in the earliest shard
+	if actual := consistencyOutliers(names[0:2], []map[string]string{defs[0], defs[2]}); len(actual) != 2 || !reflect.DeepEqual(actual["default collation"], []string{"db1:3306:shard2"}) {
+		t.Errorf("Unexpected result from consistencyOutliers with tie: %v", actual)
+	}
+
+	// Identical shards should not return any outliers
+	if actual := consistencyOutliers(names[0:2], defs[0:2]); len(actual) != 0 {
+		t.Errorf("Expected no outliers for identical shards, instead found %v", actual)
+	}
+}
@@ -0,0 +1,113 @@
+package applier
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/tengo"
+)
+
+// schemaReference represents a foreign key in a target's desired schema which
+// references a table in a different schema on the same instance.
+type schemaReference struct {
+	Table      string
+	ForeignKey string
+	Schema     string
+}
+
+// crossSchemaReferences returns all foreign keys in t's desired schema which
+// reference another schema.
+func (t *Target) crossSchemaReferences() (refs []schemaReference) {
+	if t.DesiredSchema == nil || t.DesiredSchema.Schema == nil {
+		return nil
+	}
+	for _, table := range t.DesiredSchema.Tables {
+		for _, fk := range table.ForeignKeys {
+			if fk.ReferencedSchemaName != "" && fk.ReferencedSchemaName != t.SchemaName {
+				refs = append(refs, schemaReference{Table: table.Name, ForeignKey: fk.Name, Schema: fk.ReferencedSchemaName})
+			}
+		}
+	}
+	return refs
+}
+
+// orderByReferences returns a copy of tg, sorted so that any target whose
+// foreign keys reference another schema in the group comes after the targets
+// for that schema. This way, when foreign key checks are enabled, parent
+// schemas and tables are created before the children referencing them. If no
+// targets in tg have cross-schema references, tg is returned unchanged.
+// Relative order is otherwise preserved. If references are circular, the
+// targets involved are left in their original relative order.
+func (tg TargetGroup) orderByReferences() TargetGroup {
+	deps := make(map[*Target]map[string]bool)
+	for _, t := range tg {
+		for _, ref := range t.crossSchemaReferences() {
+			if deps[t] == nil {
+				deps[t] = make(map[string]bool)
+			}
+			deps[t][ref.Schema] = true
+		}
+	}
+	if len(deps) == 0 {
+		return tg
+	}
+
+	// remaining tracks how many targets in the group for each schema name have
+	// not yet been placed into the result
+	remaining := make(map[string]int)
+	for _, t := range tg {
+		remaining[t.SchemaName]++
+	}
+	ready := func(t *Target) bool {
+		for schemaName := range deps[t] {
+			if remaining[schemaName] > 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	result := make(TargetGroup, 0, len(tg))
+	pending := append(TargetGroup{}, tg...)
+	for len(pending) > 0 {
+		next := -1
+		for n, t := range pending {
+			if ready(t) {
+				next = n
+				break
+			}
+		}
+		if next < 0 {
+			log.Debugf("Circular cross-schema foreign key references on %s; using default order for %d remaining schemas", pending[0].Instance, len(pending))
+			return append(result, pending...)
+		}
+		t := pending[next]
+		result = append(result, t)
+		remaining[t.SchemaName]--
+		pending = append(pending[:next], pending[next+1:]...)
+	}
+	return result
+}
+
+// warnUnmanagedReferences logs a warning for each foreign key in targets which
+// references a schema that is not managed by any target on the same instance.
+// Targets excluded by filtering options should be supplied in others, so that
+// references to their schemas are not reported; they are not checked
+// themselves.
+func warnUnmanagedReferences(targets, others []*Target) {
+	managed := make(map[string]map[string]bool)
+	for _, list := range [][]*Target{targets, others} {
+		for _, t := range list {
+			key := t.Instance.String()
+			if managed[key] == nil {
+				managed[key] = make(map[string]bool)
+			}
+			managed[key][t.SchemaName] = true
+		}
+	}
+	for _, t := range targets {
+		for _, ref := range t.crossSchemaReferences() {
+			if !managed[t.Instance.String()][ref.Schema] {
+				t.logger().Warnf("%s %s: foreign key %s of table %s references schema %s, which is not managed by any directory being processed", t.Instance, t.SchemaName, tengo.EscapeIdentifier(ref.ForeignKey), tengo.EscapeIdentifier(ref.Table), tengo.EscapeIdentifier(ref.Schema))
+			}
+		}
+	}
+}
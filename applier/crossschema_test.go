@@ -0,0 +1,78 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
+)
+
+// crossSchemaTestTarget returns a target for schemaName on inst, with a table
+// for each supplied referenced schema name containing a foreign key to that
+// schema's users table.
+func crossSchemaTestTarget(inst *tengo.Instance, schemaName string, referencedSchemas ...string) *Target {
+	schema := &tengo.Schema{Name: "_skeema_tmp"}
+	for _, referenced := range referencedSchemas {
+		table := brokenFKTestTable(referenced+"_refs", "users")
+		table.ForeignKeys[0].ReferencedSchemaName = referenced
+		schema.Tables = append(schema.Tables, table)
+	}
+	return &Target{
+		Instance:      inst,
+		SchemaName:    schemaName,
+		DesiredSchema: &workspace.Schema{Schema: schema},
+	}
+}
+
+func TestTargetGroupOrderByReferences(t *testing.T) {
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	billing := crossSchemaTestTarget(inst, "billing", "accounts")
+	reports := crossSchemaTestTarget(inst, "reports", "billing", "accounts", "external")
+	accounts := crossSchemaTestTarget(inst, "accounts")
+	other := crossSchemaTestTarget(inst, "other")
+	self := crossSchemaTestTarget(inst, "self", "self")
+
+	// Without any cross-schema references, the original group is returned as-is
+	tg := TargetGroup{other, self, accounts}
+	if result := tg.orderByReferences(); &result[0] != &tg[0] {
+		t.Errorf("Expected group without cross-schema references to be returned unchanged, instead found %v", result)
+	}
+
+	tg = TargetGroup{reports, other, billing, accounts}
+	result := tg.orderByReferences()
+	expected := TargetGroup{other, accounts, billing, reports}
+	for n := range expected {
+		if result[n] != expected[n] {
+			t.Errorf("Unexpected target at position %d: expected %s, found %s", n, expected[n].SchemaName, result[n].SchemaName)
+		}
+	}
+	if tg[0] != reports {
+		t.Error("orderByReferences unexpectedly modified its receiver")
+	}
+
+	// Circular references fall back to the original relative order
+	circular := crossSchemaTestTarget(inst, "accounts", "reports")
+	tg = TargetGroup{other, reports, circular, billing}
+	result = tg.orderByReferences()
+	expected = TargetGroup{other, reports, circular, billing}
+	for n := range expected {
+		if result[n] != expected[n] {
+			t.Errorf("Unexpected target at position %d with circular references: expected %s, found %s", n, expected[n].SchemaName, result[n].SchemaName)
+		}
+	}
+}
+
+func TestTargetCrossSchemaReferences(t *testing.T) {
+	target := crossSchemaTestTarget(nil, "billing", "accounts", "billing")
+	refs := target.crossSchemaReferences()
+	if len(refs) != 1 || refs[0] != (schemaReference{Table: "accounts_refs", ForeignKey: "accounts_refs_users", Schema: "accounts"}) {
+		t.Errorf("Unexpected result from crossSchemaReferences: %+v", refs)
+	}
+	target.DesiredSchema = nil
+	if refs := target.crossSchemaReferences(); len(refs) != 0 {
+		t.Errorf("Expected no references for nil DesiredSchema, instead found %+v", refs)
+	}
+}
@@ -20,9 +20,29 @@ type DDLStatement struct {
 	stmt     string
 	shellOut *util.ShellOut
 
+	// Statement comment, and shell-out variant incorporating it; these are only
+	// used upon execution, so that output and plans never include the comment
+	comment      string
+	execShellOut *util.ShellOut
+
 	instance      *tengo.Instance
 	schemaName    string
 	connectParams string
+
+	// Fields used when recording a Plan
+	target       *Target
+	diff         tengo.ObjectDiff
+	unsafe       bool
+	unsafeCodes  []UnsafeCode // categories of unsafe operations performed, if unsafe
+	commentedOut bool         // unsafe statement which is only generated for inclusion in a script-out file in commented-out form
+	note         string       // explanation of why the statement is unsafe, if not obvious from its type
+	wrapperNote  string       // warning that the configured wrapper-flavor may be unable to handle the table
+	fingerprint  string
+	clause       tengo.TableAlterClause // sole clause of an ALTER TABLE split by ddl-batching=per-clause
+	estimate     *ddlEstimate           // expected algorithm and lock level, for ALTER TABLE only
+	migration    string                 // path of manual migration or passthrough file, if the statement came from one; diff is nil in this case
+	passthrough  bool                   // true if migration is a passthrough file
+	stats        *TableStats            // size of the affected existing table, only if the stats option is enabled
 }
 
 // NewDDLStatement creates and returns a DDLStatement. If the statement ends up
@@ -31,9 +51,30 @@ type DDLStatement struct {
 // invalid variable interpolation in --alter-wrapper, etc), the DDLStatement
 // pointer will be nil, and a non-nil error will be returned.
 func NewDDLStatement(diff tengo.ObjectDiff, mods tengo.StatementModifiers, target *Target) (ddl *DDLStatement, err error) {
+	return newDDLStatement(diff, mods, target, "", "")
+}
+
+// newDDLStatement behaves like NewDDLStatement, but permits the caller to
+// supply a note explaining why the statement is unsafe, along with the note's
+// unsafe code. A non-empty note causes the statement to be treated as unsafe
+// regardless of its type.
+func newDDLStatement(diff tengo.ObjectDiff, mods tengo.StatementModifiers, target *Target, note string, noteCode UnsafeCode) (ddl *DDLStatement, err error) {
 	ddl = &DDLStatement{
 		instance:   target.Instance,
 		schemaName: target.SchemaName,
+		target:     target,
+		diff:       diff,
+	}
+	if ddl.comment, err = target.statementComment(); err != nil {
+		return nil, ConfigError(err.Error())
+	}
+
+	// If separate DDL credentials are configured, use them for executing the
+	// statement. These are not required for dry-run, which never executes DDL.
+	if ddlInstance, err := target.Dir.DDLInstance(target.Instance); err == nil {
+		ddl.instance = ddlInstance
+	} else if !target.dryRun() {
+		return nil, ConfigError(err.Error())
 	}
 
 	// Don't run database-level DDL in a schema; not even possible for CREATE
@@ -60,23 +101,121 @@ func NewDDLStatement(diff tengo.ObjectDiff, mods tengo.StatementModifiers, targe
 		}
 	}
 
+	// Changes to ENUM or SET value lists which aren't purely additive get a note
+	// naming the affected values, whether or not tengo already considers them
+	// unsafe. Likewise for changes to the SRID of spatial columns. Column changes
+	// may also cause an index to exceed the server's key size limit, or the table
+	// to exceed the row size limit, which would otherwise only be discovered when
+	// the ALTER fails mid-push. The index key size check queries the target's
+	// server settings, even in dry-run mode, but only if an indexed column
+	// changes.
+	var keySizeNote string
+	if td, ok := diff.(*tengo.TableDiff); ok && note == "" {
+		if note = enumSetNote(td); note != "" {
+			noteCode = UnsafeEnumSetChange
+		} else if note = sridNote(td); note != "" {
+			noteCode = UnsafeSRIDChange
+		} else if keySizeNote, err = indexKeySizeNote(td, target.Instance); err != nil {
+			return nil, err
+		} else if keySizeNote != "" {
+			// Unlike other unsafe operations, an index exceeding the key size limit
+			// is permitted only by its own option, handled further below
+		} else if note = rowSizeNote(td); note != "" {
+			noteCode = UnsafeRowSize
+		}
+	}
+
+	// If allow-unsafe-codes permits every category of unsafe operation in the
+	// statement, it is permitted even without allow-unsafe
+	codes := withUnsafeCode(diffUnsafeCodes(diff), noteCode)
+	if allowedCodes, err := target.allowedUnsafeCodes(); err != nil {
+		return nil, err
+	} else if !mods.AllowUnsafe && allowedCodes.permits(codes) {
+		mods.AllowUnsafe = true
+		log.Debugf("Allowing unsafe operations for %s: allow-unsafe-codes permits %s", diff.ObjectKey(), describeUnsafeCodes(codes))
+	}
+
 	// Options may indicate some/all DDL gets executed by shelling out to another program.
+	origMods := mods
 	wrapper, err := getWrapper(target.Dir.Config, diff, tableSize, &mods)
 	if err != nil {
 		return nil, err
 	}
 
+	// If the wrapper is a known online schema change tool, check whether the
+	// table has features which the tool cannot handle. With wrapper-strict, the
+	// ALTER is executed directly instead of via the wrapper.
+	if td, ok := diff.(*tengo.TableDiff); ok && wrapper != "" && td.Type == tengo.DiffTypeAlter {
+		if ddl.wrapperNote, err = wrapperWarning(target, td); err != nil {
+			return nil, err
+		} else if ddl.wrapperNote != "" && target.Dir.Config.GetBool("wrapper-strict") {
+			target.logger().Warnf("%s; running ALTER directly instead of via wrapper, due to wrapper-strict", ddl.wrapperNote)
+			ddl.wrapperNote += "; running directly due to wrapper-strict"
+			wrapper = ""
+			mods.AlgorithmClause, mods.LockClause = origMods.AlgorithmClause, origMods.LockClause
+		} else if ddl.wrapperNote != "" {
+			target.logger().Warn(ddl.wrapperNote)
+		}
+	}
+
+	// When writing scripts, unsafe statements which aren't permitted are still
+	// generated, so that they can be included in the script as comments
+	var scriptOnly bool
+	if !mods.AllowUnsafe && target.scriptTemplate() != "" {
+		mods.AllowUnsafe, scriptOnly = true, true
+	}
+
 	// Get the raw DDL statement as a string, handling errors and noops correctly
 	if ddl.stmt, err = diff.Statement(mods); tengo.IsForbiddenDiff(err) {
 		// Intentionally avoiding fmt.Errorf here to avoid golint complaining about capitalization
-		errorText := fmt.Sprintf("Destructive statement /* %s */ is considered unsafe. Use --allow-unsafe or --safe-below-size to permit this operation; see --help for more information.", ddl.stmt)
-		return nil, errors.New(errorText)
+		errorText := fmt.Sprintf("Destructive statement /* %s */ is considered unsafe [%s]. Use --allow-unsafe, --allow-unsafe-codes, or --safe-below-size to permit this operation; see --help for more information.", ddl.stmt, describeUnsafeCodes(codes))
+		if note != "" {
+			errorText = fmt.Sprintf("Destructive statement /* %s */ is considered unsafe [%s]: %s. Use --allow-unsafe, --allow-unsafe-codes, or --safe-below-size to permit this operation; see --help for more information.", ddl.stmt, describeUnsafeCodes(codes), note)
+		}
+		return nil, unsafeStatementError{text: errorText, codes: codes}
 	} else if err != nil {
 		// Leave the error untouched/unwrapped to allow caller to handle appropriately
 		return nil, err
 	} else if ddl.stmt == "" {
 		// Noop statements (due to mods) must be skipped by caller
 		return nil, nil
+	} else if diff.ObjectKey().Type == tengo.ObjectTypeTable && diff.DiffType() == tengo.DiffTypeAlter {
+		ddl.stmt = fixTableOptionDefaults(ddl.stmt)
+		ddl.stmt = fixColumnDefinitions(ddl.stmt, diff.(*tengo.TableDiff).To, mods.Flavor)
+		ddl.stmt = fixIndexRenames(ddl.stmt, diff.(*tengo.TableDiff), mods)
+	}
+
+	// Track whether the statement is destructive, even if mods permitted it
+	if mods.AllowUnsafe {
+		safeMods := mods
+		safeMods.AllowUnsafe = false
+		_, err := diff.Statement(safeMods)
+		ddl.unsafe = tengo.IsForbiddenDiff(err)
+	}
+
+	ddl.note = note
+	// Like other unsafe operations, brief dry-run mode permits oversized keys, so
+	// that the target can be listed as having differences
+	allowOversized := target.Dir.Config.GetBool("allow-oversized-keys") || target.briefOutput()
+	if keySizeNote != "" && !allowOversized && target.scriptTemplate() == "" {
+		// Intentionally avoiding fmt.Errorf here to avoid golint complaining about capitalization
+		errorText := fmt.Sprintf("Statement /* %s */ is considered unsafe [%s]: %s. Use --allow-oversized-keys to permit this operation; see --help for more information.", ddl.stmt, UnsafeIndexKeySize, keySizeNote)
+		return nil, unsafeStatementError{text: errorText, codes: []UnsafeCode{UnsafeIndexKeySize}}
+	} else if ddl.note != "" && !mods.AllowUnsafe {
+		// Intentionally avoiding fmt.Errorf here to avoid golint complaining about capitalization
+		errorText := fmt.Sprintf("Statement /* %s */ is considered unsafe [%s]: %s. Use --allow-unsafe, --allow-unsafe-codes, or --safe-below-size to permit this operation; see --help for more information.", ddl.stmt, describeUnsafeCodes(codes), ddl.note)
+		return nil, unsafeStatementError{text: errorText, codes: codes}
+	} else if ddl.note != "" {
+		ddl.unsafe = true
+	}
+	ddl.commentedOut = scriptOnly && ddl.unsafe
+	if keySizeNote != "" {
+		ddl.note, ddl.unsafe = keySizeNote, true
+		codes = withUnsafeCode(codes, UnsafeIndexKeySize)
+		ddl.commentedOut = ddl.commentedOut || !allowOversized
+	}
+	if ddl.unsafe {
+		ddl.unsafeCodes = codes
 	}
 
 	if wrapper == "" {
@@ -96,8 +235,8 @@ func NewDDLStatement(diff tengo.ObjectDiff, mods tengo.StatementModifiers, targe
 			"PORT":        port,
 			"SOCKET":      socket,
 			"SCHEMA":      ddl.schemaName,
-			"USER":        target.Dir.Config.Get("user"),
-			"PASSWORD":    target.Dir.Config.Get("password"),
+			"USER":        ddl.instance.User,
+			"PASSWORD":    ddl.instance.Password,
 			"ENVIRONMENT": target.Dir.Config.Get("environment"),
 			"DDL":         ddl.stmt,
 			"CLAUSES":     "", // filled in below only for tables
@@ -121,8 +260,27 @@ func NewDDLStatement(diff tengo.ObjectDiff, mods tengo.StatementModifiers, targe
 			errorText := fmt.Sprintf("A fatal error occurred with pre-processing a DDL statement: %s.", err)
 			return nil, errors.New(errorText)
 		}
+		if ddl.comment != "" {
+			variables["DDL"] = withComment(ddl.comment, variables["DDL"])
+			if variables["CLAUSES"] != "" {
+				variables["CLAUSES"] = withComment(ddl.comment, variables["CLAUSES"])
+			}
+			if ddl.execShellOut, err = util.NewInterpolatedShellOut(wrapper, variables); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if td, ok := diff.(*tengo.TableDiff); ok && td.Type == tengo.DiffTypeAlter {
+		if clauses, supported := tableDiffClauses(td); supported {
+			ddl.setEstimate(withIndexRenames(clauses, td, mods), mods)
+		}
 	}
 
+	// Stats are only relevant for tables which already exist
+	if diff.ObjectKey().Type == tengo.ObjectTypeTable && diff.DiffType() != tengo.DiffTypeCreate && target.Dir.Config.GetBool("stats") {
+		ddl.stats = tableStatsForDiff(target, diff)
+	}
 	return ddl, nil
 }
 
@@ -157,11 +315,15 @@ func needTableSize(diff tengo.ObjectDiff, config *mybase.Config) bool {
 // getTableSize returns the size of the table on the instance corresponding to
 // the target. If the table has no rows, this method always returns a size of 0,
 // even though information_schema normally indicates at least 16kb in this case.
+// If the table's status was prefetched, its size is not queried again.
 func getTableSize(target *Target, tableName string) (int64, error) {
 	hasRows, err := target.Instance.TableHasRows(target.SchemaName, tableName)
 	if !hasRows || err != nil {
 		return 0, err
 	}
+	if ts, ok := target.tableStatus[tableName]; ok {
+		return ts.Size(), nil
+	}
 	return target.Instance.TableSize(target.SchemaName, tableName)
 }
 
@@ -169,6 +331,9 @@ func getTableSize(target *Target, tableName string) (int64, error) {
 // configured to do so. Any variable placeholders in the returned string have
 // NOT been interpolated yet.
 func getWrapper(config *mybase.Config, diff tengo.ObjectDiff, tableSize int64, mods *tengo.StatementModifiers) (string, error) {
+	if _, ok := diff.(*grantDiff); ok {
+		return "", nil // GRANT and REVOKE are not DDL, so ddl-wrapper does not apply
+	}
 	wrapper := config.Get("ddl-wrapper")
 	if diff.ObjectKey().Type == tengo.ObjectTypeTable && diff.DiffType() == tengo.DiffTypeAlter && config.Changed("alter-wrapper") {
 		minSize, err := config.GetBytes("alter-wrapper-min-size")
@@ -245,15 +410,19 @@ func (ddl *DDLStatement) String() string {
 }
 
 // Execute runs the DDL statement, either by running a SQL query against a DB,
-// or shelling out to an external program, as appropriate.
+// or shelling out to an external program, as appropriate. If statement-comment
+// is in use, the comment is prepended to the statement, including in any {DDL}
+// or {CLAUSES} of a shell-out.
 func (ddl *DDLStatement) Execute() error {
-	if ddl.IsShellOut() {
+	if ddl.execShellOut != nil {
+		return ddl.execShellOut.Run()
+	} else if ddl.IsShellOut() {
 		return ddl.shellOut.Run()
 	}
 	db, err := ddl.instance.Connect(ddl.schemaName, ddl.connectParams)
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(ddl.stmt)
+	_, err = db.Exec(withComment(ddl.comment, ddl.stmt))
 	return err
 }
@@ -0,0 +1,30 @@
+package applier
+
+import (
+	"sort"
+
+	"github.com/skeema/skeema/linter"
+	"github.com/skeema/tengo"
+)
+
+// removedFeatures returns a description of each use of a feature which is no
+// longer supported by t's flavor, among the tables in keys which are still
+// present in t's desired schema. Dropped tables are irrelevant, since their
+// definitions are not executed.
+func (t *Target) removedFeatures(keys []tengo.ObjectKey) []string {
+	if t.DesiredSchema == nil || t.DesiredSchema.Schema == nil || t.DesiredSchema.LogicalSchema == nil {
+		return nil
+	}
+	flavor := t.Instance.Flavor()
+	tables := t.DesiredSchema.TablesByName()
+	var problems []string
+	for _, key := range keys {
+		table, stmt := tables[key.Name], t.DesiredSchema.LogicalSchema.Creates[key]
+		if key.Type != tengo.ObjectTypeTable || table == nil || stmt == nil {
+			continue
+		}
+		problems = append(problems, linter.RemovedFeatures(table, stmt.Text, flavor)...)
+	}
+	sort.Strings(problems)
+	return problems
+}
@@ -0,0 +1,50 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
+)
+
+func TestTargetRemovedFeatures(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	createStatement := "CREATE TABLE `visits` (\n  `id` int NOT NULL,\n  `yr` year(2) NOT NULL\n) ENGINE=InnoDB"
+	table := &tengo.Table{
+		Name: "visits",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(11)"},
+			{Name: "yr", TypeInDB: "year(2)"},
+		},
+		CreateStatement: createStatement,
+	}
+	key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "visits"}
+	target.DesiredSchema = &workspace.Schema{
+		Schema: &tengo.Schema{Name: "product", Tables: []*tengo.Table{table}},
+		LogicalSchema: &fs.LogicalSchema{
+			Creates: map[tengo.ObjectKey]*fs.Statement{
+				key: {Type: fs.StatementTypeCreate, Text: createStatement, ObjectType: tengo.ObjectTypeTable, ObjectName: "visits"},
+			},
+		},
+	}
+
+	target.Instance.ForceFlavor(tengo.FlavorMySQL56)
+	if problems := target.removedFeatures([]tengo.ObjectKey{key}); len(problems) != 0 {
+		t.Errorf("Expected no problems for MySQL 5.6, instead found %v", problems)
+	}
+	target.Instance.ForceFlavor(tengo.FlavorMySQL80)
+	if problems := target.removedFeatures([]tengo.ObjectKey{key}); len(problems) != 1 || !strings.Contains(problems[0], "YEAR(2)") {
+		t.Errorf("Unexpected problems for MySQL 8.0: %v", problems)
+	}
+
+	// Dropped or unmodified tables are not checked
+	dropped := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "gone"}
+	if problems := target.removedFeatures([]tengo.ObjectKey{dropped}); len(problems) != 0 {
+		t.Errorf("Expected no problems for dropped table, instead found %v", problems)
+	}
+	if problems := target.removedFeatures(nil); len(problems) != 0 {
+		t.Errorf("Expected no problems without modified tables, instead found %v", problems)
+	}
+}
@@ -0,0 +1,170 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// parseEnumSetMembers parses the value list of an ENUM or SET column type, as
+// reported by the server in information_schema, returning the lowercased type
+// name and its members in order. Members may contain commas, parentheses, or
+// quotes. If colType is not an ENUM or SET, or its value list is malformed, ok
+// will be false.
+func parseEnumSetMembers(colType string) (typeName string, members []string, ok bool) {
+	lower := strings.ToLower(colType)
+	for _, candidate := range []string{"enum", "set"} {
+		if strings.HasPrefix(lower, candidate+"(") {
+			typeName = candidate
+		}
+	}
+	if typeName == "" {
+		return "", nil, false
+	}
+	s := colType[len(typeName)+1:]
+	for {
+		if s == "" || s[0] != '\'' {
+			return "", nil, false
+		}
+		var member strings.Builder
+		var closed bool
+		pos := 1
+		for pos < len(s) && !closed {
+			c := s[pos]
+			if c == '\\' && pos+1 < len(s) {
+				member.WriteByte(unescapeByte(s[pos+1]))
+				pos += 2
+			} else if c == '\'' && pos+1 < len(s) && s[pos+1] == '\'' {
+				member.WriteByte('\'')
+				pos += 2
+			} else if c == '\'' {
+				closed = true
+				pos++
+			} else {
+				member.WriteByte(c)
+				pos++
+			}
+		}
+		if !closed || pos >= len(s) {
+			return "", nil, false
+		}
+		members = append(members, member.String())
+		switch s[pos] {
+		case ',':
+			s = s[pos+1:]
+		case ')':
+			return typeName, members, true
+		default:
+			return "", nil, false
+		}
+	}
+}
+
+// unescapeByte returns the byte represented by a backslash escape sequence
+// ending in c.
+func unescapeByte(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case '0':
+		return 0
+	}
+	return c
+}
+
+// enumSetNote examines an ALTER TABLE diff for changes to the value lists of
+// ENUM or SET columns. Appending new values to the end of the list is safe,
+// since existing values keep their internal numbering. Any other change to the
+// list requires the server to rewrite the stored values, and may lose data. In
+// this case a note describing the first affected column is returned, naming
+// the specific values which are removed, change position, or change only in
+// letter case; otherwise an empty string is returned.
+//
+// Values are matched using the column's collation: with a case-insensitive
+// collation, a value which only changes letter case keeps its stored data, but
+// with a case-sensitive or binary collation, it is effectively removed.
+func enumSetNote(td *tengo.TableDiff) string {
+	if td.Type != tengo.DiffTypeAlter || td.From == nil || td.To == nil {
+		return ""
+	}
+	fromCols := td.From.ColumnsByName()
+	for _, toCol := range td.To.Columns {
+		fromCol, ok := fromCols[toCol.Name]
+		if !ok || fromCol.TypeInDB == toCol.TypeInDB {
+			continue
+		}
+		fromType, oldMembers, ok := parseEnumSetMembers(fromCol.TypeInDB)
+		if !ok {
+			continue
+		}
+		toType, newMembers, ok := parseEnumSetMembers(toCol.TypeInDB)
+		if !ok || fromType != toType {
+			continue
+		}
+		if problems := enumSetChanges(oldMembers, newMembers, collationIsCaseSensitive(toCol.Collation)); problems != "" {
+			return fmt.Sprintf("column %s %s value list change %s", tengo.EscapeIdentifier(toCol.Name), strings.ToUpper(toType), problems)
+		}
+	}
+	return ""
+}
+
+// enumSetChanges compares two ENUM or SET value lists, returning a description
+// of any changes which are not simply appending values to the end of the list.
+// A blank string is returned if the change is append-only.
+func enumSetChanges(oldMembers, newMembers []string, caseSensitive bool) string {
+	appendOnly := len(newMembers) >= len(oldMembers)
+	for n := 0; appendOnly && n < len(oldMembers); n++ {
+		appendOnly = oldMembers[n] == newMembers[n]
+	}
+	if appendOnly {
+		return ""
+	}
+
+	var removed, recased, moved []string
+	for oldPos, oldMember := range oldMembers {
+		newPos := -1
+		for n, newMember := range newMembers {
+			if newMember == oldMember || (!caseSensitive && strings.EqualFold(newMember, oldMember)) {
+				newPos = n
+				break
+			}
+		}
+		if newPos == -1 {
+			removed = append(removed, quoteMember(oldMember))
+			continue
+		}
+		if newMembers[newPos] != oldMember {
+			recased = append(recased, quoteMember(oldMember)+" to "+quoteMember(newMembers[newPos]))
+		}
+		if newPos != oldPos {
+			moved = append(moved, quoteMember(oldMember))
+		}
+	}
+	var problems []string
+	if len(removed) > 0 {
+		problems = append(problems, "removes "+strings.Join(removed, ", "))
+	}
+	if len(recased) > 0 {
+		problems = append(problems, "changes letter case of "+strings.Join(recased, ", "))
+	}
+	if len(moved) > 0 {
+		problems = append(problems, "changes position of "+strings.Join(moved, ", "))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// collationIsCaseSensitive returns true if the named collation compares
+// strings case-sensitively. Columns without a collation are treated as binary.
+func collationIsCaseSensitive(collation string) bool {
+	collation = strings.ToLower(collation)
+	return collation == "" || collation == "binary" || strings.HasSuffix(collation, "_bin") || strings.HasSuffix(collation, "_cs")
+}
+
+func quoteMember(member string) string {
+	return "'" + tengo.EscapeValueForCreateTable(member) + "'"
+}
@@ -0,0 +1,96 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestParseEnumSetMembers(t *testing.T) {
+	cases := map[string][]string{
+		"enum('a','b')":                 {"a", "b"},
+		"ENUM('a,b','c')":               {"a,b", "c"},
+		"set('it''s','a)b','x\\\\y')":   {"it's", "a)b", "x\\y"},
+		"enum('')":                      {""},
+		"set('Big','big','BIG')":        {"Big", "big", "BIG"},
+		"enum('new\\nline','q\\'uote')": {"new\nline", "q'uote"},
+	}
+	for input, expected := range cases {
+		typeName, members, ok := parseEnumSetMembers(input)
+		if !ok || typeName != strings.ToLower(input[0:strings.IndexByte(input, '(')]) || strings.Join(members, "|") != strings.Join(expected, "|") {
+			t.Errorf("Unexpected return from parseEnumSetMembers(%q): %q, %q, %t", input, typeName, members, ok)
+		}
+	}
+	for _, input := range []string{"int(10)", "varchar(20)", "enum()", "enum('a'", "enum('a' 'b')", "enum(a,b)", "setting('a')"} {
+		if _, _, ok := parseEnumSetMembers(input); ok {
+			t.Errorf("Expected parseEnumSetMembers(%q) to fail, but it did not", input)
+		}
+	}
+}
+
+func TestEnumSetChanges(t *testing.T) {
+	cases := []struct {
+		oldMembers    string
+		newMembers    string
+		caseSensitive bool
+		expected      string
+	}{
+		{"a,b", "a,b,c", false, ""},
+		{"a,b", "a,b,c,d", true, ""},
+		{"a,b,c", "a,c", false, "removes 'b'; changes position of 'c'"},
+		{"a,b,c", "a,b", false, "removes 'c'"},
+		{"a,b,c", "c,b,a", false, "changes position of 'a', 'c'"},
+		{"a,c", "a,b,c", false, "changes position of 'c'"},
+		{"a,b", "a,B", false, "changes letter case of 'b' to 'B'"},
+		{"a,b", "a,B", true, "removes 'b'"},
+		{"a,b", "A,b,c", false, "changes letter case of 'a' to 'A'"},
+	}
+	for _, c := range cases {
+		if actual := enumSetChanges(strings.Split(c.oldMembers, ","), strings.Split(c.newMembers, ","), c.caseSensitive); actual != c.expected {
+			t.Errorf("Expected enumSetChanges(%s -> %s, %t) to return %q, instead found %q", c.oldMembers, c.newMembers, c.caseSensitive, c.expected, actual)
+		}
+	}
+}
+
+func TestEnumSetNote(t *testing.T) {
+	makeTable := func(statusType, collation string) *tengo.Table {
+		return &tengo.Table{
+			Name: "posts",
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int(10) unsigned"},
+				{Name: "status", TypeInDB: statusType, CharSet: "utf8mb4", Collation: collation},
+			},
+		}
+	}
+	cases := []struct {
+		fromType  string
+		toType    string
+		collation string
+		expected  string
+	}{
+		{"enum('new','it''s, done')", "enum('new','it''s, done','archived')", "utf8mb4_general_ci", ""},
+		{"enum('new','it''s, done')", "enum('new')", "utf8mb4_general_ci", "column `status` ENUM value list change removes 'it''s, done'"},
+		{"set('a','b')", "set('b','a')", "utf8mb4_general_ci", "column `status` SET value list change changes position of 'a', 'b'"},
+		{"enum('new','done')", "enum('new','Done')", "utf8mb4_general_ci", "column `status` ENUM value list change changes letter case of 'done' to 'Done'"},
+		{"enum('new','done')", "enum('new','Done')", "utf8mb4_bin", "column `status` ENUM value list change removes 'done'"},
+		{"enum('new','done')", "enum('new','Done')", "utf8mb4_0900_as_cs", "column `status` ENUM value list change removes 'done'"},
+		{"enum('a','b')", "set('a','b')", "utf8mb4_general_ci", ""},
+		{"enum('a','b')", "varchar(10)", "utf8mb4_general_ci", ""},
+	}
+	for _, c := range cases {
+		td := tengo.NewAlterTable(makeTable(c.fromType, c.collation), makeTable(c.toType, c.collation))
+		if td == nil {
+			t.Fatalf("Expected NewAlterTable to return a diff for %s -> %s, but it returned nil", c.fromType, c.toType)
+		}
+		if actual := enumSetNote(td); actual != c.expected {
+			t.Errorf("Expected enumSetNote(%s -> %s, %s) to return %q, instead found %q", c.fromType, c.toType, c.collation, c.expected, actual)
+		}
+	}
+
+	// Non-alter diffs never have a note
+	td := tengo.NewCreateTable(makeTable("enum('a')", "utf8mb4_general_ci"))
+	if actual := enumSetNote(td); actual != "" {
+		t.Errorf("Expected no note for CREATE TABLE, instead found %q", actual)
+	}
+}
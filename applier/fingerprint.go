@@ -0,0 +1,119 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// SchemaFingerprint is the result of FingerprintSchema: a hash of each object's
+// normalized definition in one schema, along with a rolled-up hash of the
+// entire schema.
+type SchemaFingerprint struct {
+	Dir         string              `json:"dir"`
+	Instance    string              `json:"instance,omitempty"`
+	Schema      string              `json:"schema"`
+	Objects     []ObjectFingerprint `json:"objects"`
+	Fingerprint string              `json:"fingerprint"`
+}
+
+// ObjectFingerprint is a hash of one object's normalized definition.
+type ObjectFingerprint struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// FingerprintSchema returns fingerprints for t's schema. If fromServer is true,
+// the live schema on t's instance is used; otherwise the desired schema from
+// t's dir is used, in which case t must have been obtained from TargetsForDir.
+//
+// Both sides are normalized using the same code path used prior to diff'ing
+// them in push and diff, so equal fingerprints guarantee that a diff between
+// the two sides will not generate any statements, aside from changes to next
+// AUTO_INCREMENT values, which are excluded. Options which adjust one side of
+// a diff relative to the other, such as ignore-table-options or
+// partitioning=remove, are not reflected. The rolled-up schema fingerprint
+// does not depend on the schema's name, so identical shards have identical
+// fingerprints. A nil result and nil error are returned if fromServer is true
+// but the schema does not exist.
+func FingerprintSchema(t *Target, fromServer bool) (*SchemaFingerprint, error) {
+	result := &SchemaFingerprint{
+		Dir:     t.Dir.RelPath(),
+		Schema:  t.SchemaName,
+		Objects: []ObjectFingerprint{},
+	}
+	var schema *tengo.Schema
+	if fromServer {
+		var err error
+		if schema, err = t.SchemaFromInstance(); err != nil || schema == nil {
+			return nil, err
+		}
+		result.Instance = t.Instance.String()
+	} else {
+		schema = t.SchemaFromDir()
+	}
+	schema, _ = t.diffableSchema(schema)
+
+	fingerprints := definitionFingerprints(schema)
+	for key, fp := range fingerprints {
+		result.Objects = append(result.Objects, ObjectFingerprint{
+			Type:        string(key.Type),
+			Name:        key.Name,
+			Fingerprint: fp,
+		})
+	}
+	sort.Slice(result.Objects, func(i, j int) bool {
+		if result.Objects[i].Type != result.Objects[j].Type {
+			return result.Objects[i].Type < result.Objects[j].Type
+		}
+		return result.Objects[i].Name < result.Objects[j].Name
+	})
+	result.Fingerprint = rollUpFingerprints(fingerprints)
+	return result, nil
+}
+
+// diffableSchema returns schema as adjusted by applyTargetStatements prior to
+// diff'ing it: table options and column attributes which tengo doesn't handle
+// itself are modeled, and the tracking table configured for t's dir is
+// excluded. The returned bool indicates whether the tracking table was present
+// in schema.
+func (t *Target) diffableSchema(schema *tengo.Schema) (*tengo.Schema, bool) {
+	schema = modelTableOptions(schema, t.Instance.Flavor())
+	if trackingTableName := t.Dir.Config.Get("tracking-table"); trackingTableName != "" {
+		return withoutTable(schema, trackingTableName)
+	}
+	return schema, false
+}
+
+// definitionFingerprints behaves like ObjectFingerprints, except that routine
+// fingerprints also reflect the creation-time metadata which is compared when
+// diff'ing routines, but isn't part of their CREATE statements.
+func definitionFingerprints(schema *tengo.Schema) map[tengo.ObjectKey]string {
+	fingerprints := ObjectFingerprints(schema)
+	if schema == nil {
+		return fingerprints
+	}
+	for _, routine := range schema.Routines {
+		key := tengo.ObjectKey{Type: routine.Type, Name: routine.Name}
+		fingerprints[key] = fingerprint(fmt.Sprintf("%s\n-- sql_mode=%s, db_collation=%s", routine.CreateStatement, routine.SQLMode, routine.DatabaseCollation))
+	}
+	return fingerprints
+}
+
+// rollUpFingerprints returns a single hash combining all of the supplied
+// object fingerprints. The hash does not depend on map ordering, nor on the
+// name of the schema itself.
+func rollUpFingerprints(fingerprints map[tengo.ObjectKey]string) string {
+	lines := make([]string, 0, len(fingerprints))
+	for key, fp := range fingerprints {
+		if key.Type == tengo.ObjectTypeDatabase {
+			key.Name = "" // so that identical shards have identical fingerprints
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", key, fp))
+	}
+	sort.Strings(lines)
+	return fingerprint(strings.Join(lines, "\n"))
+}
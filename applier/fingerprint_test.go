@@ -0,0 +1,90 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// fingerprintTestRoutine returns a function using the supplied creation-time
+// sql_mode.
+func fingerprintTestRoutine(sqlMode string) *tengo.Routine {
+	r := &tengo.Routine{
+		Name:              "answer",
+		Type:              tengo.ObjectTypeFunc,
+		Body:              "RETURN 42",
+		ReturnDataType:    "int(11)",
+		Definer:           "root@%",
+		DatabaseCollation: "utf8mb4_general_ci",
+		SQLDataAccess:     "CONTAINS SQL",
+		SecurityType:      "DEFINER",
+		SQLMode:           sqlMode,
+	}
+	r.CreateStatement = r.Definition(tengo.FlavorMySQL57)
+	return r
+}
+
+// TestFingerprintsDiffClean confirms that, for a set of fixtures, two schemas
+// have equal fingerprints if and only if diff'ing them generates no DDL.
+func TestFingerprintsDiffClean(t *testing.T) {
+	flavor := tengo.FlavorMySQL57
+	newSchema := func(tables ...*tengo.Table) *tengo.Schema {
+		return &tengo.Schema{
+			Name:      "product",
+			CharSet:   "utf8mb4",
+			Collation: "utf8mb4_general_ci",
+			Tables:    tables,
+			Routines:  []*tengo.Routine{fingerprintTestRoutine("STRICT_TRANS_TABLES")},
+		}
+	}
+	autoInc := brokenFKTestTable("users")
+	autoInc.Columns[0].AutoIncrement = true
+	autoIncLater := *autoInc
+	autoInc.CreateStatement = autoInc.GeneratedCreateStatement(flavor)
+	autoIncLater.NextAutoIncrement = 123
+	autoIncLater.CreateStatement = autoIncLater.GeneratedCreateStatement(flavor)
+	otherMode := newSchema(brokenFKTestTable("users"))
+	otherMode.Routines[0] = fingerprintTestRoutine("")
+	otherCharSet := newSchema(brokenFKTestTable("users"))
+	otherCharSet.CharSet, otherCharSet.Collation = "latin1", "latin1_swedish_ci"
+	otherName := newSchema(brokenFKTestTable("users"))
+	otherName.Name = "product_shard2"
+
+	cases := []struct {
+		from, to *tengo.Schema
+		equal    bool
+	}{
+		{newSchema(brokenFKTestTable("users")), newSchema(brokenFKTestTable("users")), true},
+		{newSchema(brokenFKTestTable("users")), otherName, true},
+		{newSchema(autoInc), newSchema(&autoIncLater), true},
+		{newSchema(brokenFKTestTable("users")), newSchema(brokenFKTestTable("users"), brokenFKTestTable("posts")), false},
+		{newSchema(brokenFKTestTable("posts", "users")), newSchema(brokenFKTestTable("posts")), false},
+		{newSchema(renameIndexTestTable("email", "name")), newSchema(renameIndexTestTable("email", "idx_name")), false},
+		{newSchema(brokenFKTestTable("users")), otherMode, false},
+		{newSchema(brokenFKTestTable("users")), otherCharSet, false},
+	}
+	mods := tengo.StatementModifiers{
+		AllowUnsafe:     true,
+		CompareMetadata: true,
+		NextAutoInc:     tengo.NextAutoIncIgnore,
+		Flavor:          flavor,
+	}
+	for n, c := range cases {
+		from, to := modelTableOptions(c.from, flavor), modelTableOptions(c.to, flavor)
+		fromFP, toFP := rollUpFingerprints(definitionFingerprints(from)), rollUpFingerprints(definitionFingerprints(to))
+		if (fromFP == toFP) != c.equal {
+			t.Errorf("Case %d: expected fingerprint equality to be %t, but it was not", n, c.equal)
+		}
+		var stmtCount int
+		for _, objDiff := range tengo.NewSchemaDiff(from, to).ObjectDiffs() {
+			if stmt, err := objDiff.Statement(mods); err != nil {
+				t.Errorf("Case %d: unexpected error from Statement: %v", n, err)
+			} else if stmt != "" {
+				stmtCount++
+			}
+		}
+		if (stmtCount == 0) != (fromFP == toFP) {
+			t.Errorf("Case %d: fingerprint equality is %t, but diff generated %d statements", n, fromFP == toFP, stmtCount)
+		}
+	}
+}
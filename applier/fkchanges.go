@@ -0,0 +1,98 @@
+package applier
+
+import (
+	"github.com/skeema/tengo"
+)
+
+// pairForeignKeyChanges returns a copy of objDiffs, reordered so that any
+// ALTER TABLE re-adding modified foreign keys immediately follows the ALTER
+// TABLE dropping them.
+//
+// A foreign key cannot be modified in place, and MySQL does not permit
+// dropping and re-adding a foreign key with the same name in a single ALTER
+// TABLE, so the diff engine handles a changed column list or ON DELETE / ON
+// UPDATE action by dropping the foreign key in the table's main ALTER, and
+// re-adding it in a separate ALTER placed after all other diffs. That ordering
+// is necessary for new foreign keys, which may depend on tables or indexes
+// created earlier in the diff, but it needlessly leaves a modified table
+// without its constraint while every other statement executes. Re-adds are
+// only moved if nothing they depend on is changed elsewhere in the diff: the
+// parent table must either be the table itself, be in another schema, or exist
+// in instSchema without being otherwise modified.
+func pairForeignKeyChanges(objDiffs []tengo.ObjectDiff, instSchema *tengo.Schema) []tengo.ObjectDiff {
+	result := make([]tengo.ObjectDiff, len(objDiffs))
+	copy(result, objDiffs)
+	if instSchema == nil {
+		return result
+	}
+
+	// Track the position of the first diff for each table, and how many diffs
+	// affect each table
+	firstPos := make(map[string]int)
+	diffCount := make(map[string]int)
+	for n, objDiff := range result {
+		key := objDiff.ObjectKey()
+		if key.Type != tengo.ObjectTypeTable {
+			continue
+		}
+		if _, ok := firstPos[key.Name]; !ok {
+			firstPos[key.Name] = n
+		}
+		diffCount[key.Name]++
+	}
+
+	for n := 0; n < len(result); n++ {
+		td, ok := result[n].(*tengo.TableDiff)
+		if !ok || td.Type != tengo.DiffTypeAlter {
+			continue
+		}
+		first := firstPos[td.To.Name]
+		if first >= n || first+1 == n || !onlyReaddsForeignKeys(td, instSchema, diffCount) {
+			continue
+		}
+		// Move result[n] to position first+1, shifting intervening diffs later
+		copy(result[first+2:n+1], result[first+1:n])
+		result[first+1] = td
+		for name, pos := range firstPos {
+			if pos > first && pos < n {
+				firstPos[name] = pos + 1
+			}
+		}
+	}
+	return result
+}
+
+// onlyReaddsForeignKeys returns true if every foreign key added by the full
+// diff of td's tables replaces a dropped foreign key of the same name, and
+// each such foreign key's parent table is not otherwise affected by the diff.
+// diffCount should map table names to the number of diffs affecting them.
+func onlyReaddsForeignKeys(td *tengo.TableDiff, instSchema *tengo.Schema, diffCount map[string]int) bool {
+	clauses, supported := td.From.Diff(td.To)
+	if !supported {
+		return false
+	}
+	dropped := make(map[string]bool)
+	for _, clause := range clauses {
+		if dfk, ok := clause.(tengo.DropForeignKey); ok {
+			dropped[dfk.ForeignKey.Name] = true
+		}
+	}
+	var readds int
+	for _, clause := range clauses {
+		afk, ok := clause.(tengo.AddForeignKey)
+		if !ok {
+			continue
+		}
+		fk := afk.ForeignKey
+		if !dropped[fk.Name] {
+			return false
+		}
+		if parent := fk.ReferencedTableName; parent != td.To.Name && (fk.ReferencedSchemaName == "" || fk.ReferencedSchemaName == instSchema.Name) {
+			if !instSchema.HasTable(parent) || diffCount[parent] > 0 {
+				return false
+			}
+		}
+		readds++
+	}
+	return readds > 0
+}
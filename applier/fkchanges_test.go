@@ -0,0 +1,114 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// compositeFKTestTable returns a table with a two-column foreign key, whose
+// local and referenced columns are intentionally listed in a different order
+// than the columns of the referenced table's primary key.
+func compositeFKTestTable(referencedSchema, deleteRule string) *tengo.Table {
+	table := brokenFKTestTable("children")
+	pa := &tengo.Column{Name: "pa", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	pb := &tengo.Column{Name: "pb", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	table.Columns = append(table.Columns, pa, pb)
+	table.SecondaryIndexes = []*tengo.Index{{Name: "pb_pa", Columns: []*tengo.Column{pb, pa}, SubParts: []uint16{0, 0}}}
+	table.ForeignKeys = []*tengo.ForeignKey{{
+		Name:                  "fk_parent",
+		Columns:               []*tengo.Column{pb, pa},
+		ReferencedSchemaName:  referencedSchema,
+		ReferencedTableName:   "parents",
+		ReferencedColumnNames: []string{"b", "a"},
+		UpdateRule:            "RESTRICT",
+		DeleteRule:            deleteRule,
+	}}
+	table.CreateStatement = table.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	return table
+}
+
+// splitTableDiffs returns the diffs for altering from to to, in the same form
+// as tengo.SchemaDiff, with any re-added foreign keys in a separate diff.
+func splitTableDiffs(t *testing.T, from, to *tengo.Table) (*tengo.TableDiff, *tengo.TableDiff) {
+	t.Helper()
+	td := tengo.NewAlterTable(from, to)
+	if td == nil {
+		t.Fatalf("Expected tables %s to differ, but no diff was returned", from.Name)
+	}
+	return td.SplitAddForeignKeys()
+}
+
+func TestPairForeignKeyChanges(t *testing.T) {
+	parents := brokenFKTestTable("parents")
+	instSchema := &tengo.Schema{
+		Name:   "product",
+		Tables: []*tengo.Table{parents, brokenFKTestTable("users"), compositeFKTestTable("", "RESTRICT")},
+	}
+	users := brokenFKTestTable("users")
+	users.Comment = "hello world"
+	users.CreateStatement = users.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	otherAlter := tengo.NewAlterTable(instSchema.Tables[1], users)
+
+	// Changing only the ON DELETE action requires dropping and re-adding the
+	// foreign key. Column order on both sides must be preserved, and the re-add
+	// should be moved to immediately follow the drop.
+	drop, readd := splitTableDiffs(t, instSchema.Tables[2], compositeFKTestTable("", "CASCADE"))
+	if drop == nil || readd == nil {
+		t.Fatalf("Expected foreign key action change to be split into two diffs, instead found %v, %v", drop, readd)
+	}
+	objDiffs := []tengo.ObjectDiff{drop, otherAlter, readd}
+	result := pairForeignKeyChanges(objDiffs, instSchema)
+	if result[0] != drop || result[1] != readd || result[2] != otherAlter {
+		t.Errorf("Unexpected order returned by pairForeignKeyChanges: %v", result)
+	}
+	if objDiffs[1] != otherAlter {
+		t.Error("pairForeignKeyChanges unexpectedly modified its input slice")
+	}
+	expected := []string{
+		"ALTER TABLE `children` DROP FOREIGN KEY `fk_parent`",
+		"ALTER TABLE `children` ADD CONSTRAINT `fk_parent` FOREIGN KEY (`pb`, `pa`) REFERENCES `parents` (`b`, `a`) ON DELETE CASCADE",
+	}
+	for n := range expected {
+		if stmt, err := result[n].Statement(tengo.StatementModifiers{}); err != nil || stmt != expected[n] {
+			t.Errorf("Unexpected statement for diff %d: %q / %v", n, stmt, err)
+		}
+	}
+
+	// If the parent table is also being modified, the re-add must stay last
+	modifiedParents := brokenFKTestTable("parents")
+	modifiedParents.Comment = "hello world"
+	modifiedParents.CreateStatement = modifiedParents.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	parentAlter := tengo.NewAlterTable(parents, modifiedParents)
+	objDiffs = []tengo.ObjectDiff{drop, parentAlter, readd}
+	if result := pairForeignKeyChanges(objDiffs, instSchema); result[2] != readd {
+		t.Errorf("Expected re-add to remain last when parent table is modified, instead found %v", result)
+	}
+
+	// Foreign keys referencing another schema can always be moved, and their
+	// definitions must include the other schema's name
+	instSchema.Tables[2] = compositeFKTestTable("accounts", "RESTRICT")
+	drop, readd = splitTableDiffs(t, instSchema.Tables[2], compositeFKTestTable("accounts", "SET NULL"))
+	result = pairForeignKeyChanges([]tengo.ObjectDiff{drop, otherAlter, readd}, instSchema)
+	if result[1] != readd {
+		t.Errorf("Expected cross-schema re-add to be moved, instead found %v", result)
+	}
+	expectedStmt := "ALTER TABLE `children` ADD CONSTRAINT `fk_parent` FOREIGN KEY (`pb`, `pa`) REFERENCES `accounts`.`parents` (`b`, `a`) ON DELETE SET NULL"
+	if stmt, err := readd.Statement(tengo.StatementModifiers{}); err != nil || stmt != expectedStmt {
+		t.Errorf("Unexpected statement for cross-schema re-add: %q / %v", stmt, err)
+	}
+
+	// Brand new foreign keys are never moved, since they may depend on tables or
+	// indexes created elsewhere in the diff
+	withNewFK := compositeFKTestTable("accounts", "RESTRICT")
+	withNewFK.ForeignKeys = append(withNewFK.ForeignKeys, brokenFKTestTable("children", "users").ForeignKeys[0])
+	withNewFK.ForeignKeys[1].Columns = withNewFK.Columns[:1]
+	withNewFK.ForeignKeys[1].Name = "new_fk"
+	withNewFK.ForeignKeys[0].DeleteRule = "CASCADE"
+	withNewFK.CreateStatement = withNewFK.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	drop, readd = splitTableDiffs(t, instSchema.Tables[2], withNewFK)
+	result = pairForeignKeyChanges([]tengo.ObjectDiff{drop, otherAlter, readd}, instSchema)
+	if result[2] != readd {
+		t.Errorf("Expected diff adding a new foreign key to remain last, instead found %v", result)
+	}
+}
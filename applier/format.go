@@ -0,0 +1,195 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// This file contains the presentation logic for human-readable DDL output. It
+// operates only on already-generated DDLStatements, and does not affect diff
+// computation or plan files.
+
+// ANSI color codes used in output.
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+	colorUnsafe = "\x1b[31;1m" // bright red
+)
+
+// ddlTag returns the short tag used to label ddl in output, along with the
+// ANSI color code for the tag. Potentially destructive statements (only
+// possible to generate when allow-unsafe, allow-unsafe-codes, or
+// safe-below-size permitted them) are always tagged as unsafe, regardless of
+// their diff type, followed by their unsafe codes. Statements from manual
+// migrations are tagged as manual, and those from passthrough files are tagged
+// as passthrough.
+func ddlTag(ddl *DDLStatement) (tag, color string) {
+	if ddl.unsafe && len(ddl.unsafeCodes) > 0 {
+		codes := make([]string, len(ddl.unsafeCodes))
+		for n, code := range ddl.unsafeCodes {
+			codes[n] = string(code)
+		}
+		return "unsafe " + strings.Join(codes, ","), colorUnsafe
+	} else if ddl.unsafe {
+		return "unsafe", colorUnsafe
+	} else if ddl.passthrough {
+		return "passthrough", colorYellow
+	} else if ddl.migration != "" {
+		return "manual", colorYellow
+	} else if ddl.diff == nil {
+		return "", ""
+	}
+	switch ddl.diff.DiffType() {
+	case tengo.DiffTypeCreate:
+		return "create", colorGreen
+	case tengo.DiffTypeDrop:
+		return "drop", colorRed
+	default:
+		return "alter", colorYellow
+	}
+}
+
+// colorize wraps s in the supplied ANSI color code, if useColor is true.
+func colorize(s, color string, useColor bool) string {
+	if !useColor || color == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// formatTag returns ddl's tag in brackets, padded to width (which should
+// include the brackets), and colorized if requested. Padding is applied
+// outside of the color codes, so that alignment is unaffected by color.
+func formatTag(ddl *DDLStatement, width int, useColor bool) string {
+	tag, color := ddlTag(ddl)
+	if tag == "" {
+		return ""
+	}
+	bracketed := "[" + tag + "]"
+	var padding string
+	if width > len(bracketed) {
+		padding = strings.Repeat(" ", width-len(bracketed))
+	}
+	return colorize(bracketed, color, useColor) + padding
+}
+
+// formatSummary returns several lines of SQL comments summarizing the supplied
+// statements, all of which should be for the same target. The summary consists
+// of a header line identifying the dir and schema, followed by one line per
+// statement with its tag, object type, and object name, aligned into columns.
+// Manual migrations and passthrough files are listed once each, by file path. It is intended to be
+// output prior to the full DDL.
+func formatSummary(dirPath, schemaName string, ddls []*DDLStatement, useColor bool) string {
+	var tagWidth, typeWidth int
+	for _, ddl := range ddls {
+		if tag, _ := ddlTag(ddl); len(tag)+2 > tagWidth {
+			tagWidth = len(tag) + 2
+		}
+		if ddl.diff != nil && len(ddl.diff.ObjectKey().Type) > typeWidth {
+			typeWidth = len(ddl.diff.ObjectKey().Type)
+		} else if ddl.migration != "" && len("file") > typeWidth {
+			typeWidth = len("file")
+		}
+	}
+
+	var b strings.Builder
+	header := fmt.Sprintf("%s: %s", dirPath, countAndNoun(len(ddls), "change"))
+	if schemaName != "" {
+		header = fmt.Sprintf("%s (schema %s)", header, schemaName)
+	}
+	fmt.Fprintf(&b, "-- %s\n", colorize(header, colorBold, useColor))
+	seenMigrations := make(map[string]bool)
+	for _, ddl := range ddls {
+		if ddl.migration != "" && !seenMigrations[ddl.migration] {
+			fmt.Fprintf(&b, "--   %s %-*s %s\n", formatTag(ddl, tagWidth, useColor), typeWidth, "file", ddl.migration)
+			seenMigrations[ddl.migration] = true
+		}
+		if ddl.diff == nil {
+			continue
+		}
+		key := ddl.diff.ObjectKey()
+		fmt.Fprintf(&b, "--   %s %-*s %s\n", formatTag(ddl, tagWidth, useColor), typeWidth, key.Type, tengo.EscapeIdentifier(key.Name))
+	}
+	return b.String()
+}
+
+// formatDDL returns ddl's full output representation. If annotate is true, it
+// is prefixed with its tag: for SQL statements the tag is an inline comment,
+// keeping the output valid SQL, while for shell-outs the tag is placed on the
+// preceding line, since the MySQL client's \! command must begin its line. If ddl has a note explaining
+// why it is unsafe, the note is output as a comment on the preceding line, as
+// is any warning about the configured wrapper-flavor. If ddl has an execution
+// estimate, it is output as a trailing comment. Table stats, if gathered, are
+// output as a comment on the preceding line. Unsafe statements which are only
+// being written to a script in commented-out form are likewise commented out
+// here.
+func formatDDL(ddl *DDLStatement, annotate, useColor bool) string {
+	var note string
+	if ddl.note != "" {
+		note = fmt.Sprintf("-- %s\n", ddl.note)
+	}
+	if ddl.wrapperNote != "" {
+		note += fmt.Sprintf("-- Warning: %s\n", ddl.wrapperNote)
+	}
+	if ddl.stats != nil {
+		note += fmt.Sprintf("-- %s\n", ddl.stats)
+	}
+	if ddl.commentedOut {
+		return note + commentedOutDDL(ddl)
+	}
+	stmt := ddl.String()
+	if ddl.estimate != nil && !ddl.IsShellOut() {
+		stmt = fmt.Sprintf("%s -- %s\n", strings.TrimSuffix(stmt, "\n"), ddl.estimate)
+	}
+	var tag string
+	if annotate {
+		tag = formatTag(ddl, 0, useColor)
+	}
+	if tag == "" {
+		return note + stmt
+	} else if ddl.IsShellOut() {
+		return fmt.Sprintf("%s-- %s\n%s", note, tag, stmt)
+	}
+	return fmt.Sprintf("%s/* %s */ %s", note, tag, stmt)
+}
+
+// formatInstanceHeader returns the comment line which begins output for an
+// instance.
+func formatInstanceHeader(instString string, useColor bool) string {
+	return fmt.Sprintf("-- instance: %s\n", colorize(instString, colorBold, useColor))
+}
+
+// formatUse returns the USE statement which precedes DDL for a schema.
+func formatUse(schemaName string) string {
+	return fmt.Sprintf("USE %s;\n", tengo.EscapeIdentifier(schemaName))
+}
+
+// commentedOutDDL returns ddl as SQL comment lines, preceded by a line
+// explaining why it was commented out.
+func commentedOutDDL(ddl *DDLStatement) string {
+	var b strings.Builder
+	var codes string
+	if len(ddl.unsafeCodes) > 0 {
+		codes = " [" + describeUnsafeCodes(ddl.unsafeCodes) + "]"
+	}
+	hint := "--allow-unsafe, --allow-unsafe-codes, or --safe-below-size"
+	for _, code := range ddl.unsafeCodes {
+		if code == UnsafeIndexKeySize && len(ddl.unsafeCodes) == 1 {
+			hint = "--allow-oversized-keys"
+		} else if code == UnsafeIndexKeySize {
+			hint = "--allow-oversized-keys along with " + hint
+		}
+	}
+	fmt.Fprintf(&b, "-- Unsafe statement%s commented out; use %s to include it\n", codes, hint)
+	for _, line := range strings.SplitAfter(ddl.String(), "\n") {
+		if line != "" {
+			b.WriteString("-- " + line)
+		}
+	}
+	return b.String()
+}
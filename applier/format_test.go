@@ -0,0 +1,239 @@
+package applier
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// getFormatTestDDL returns a target and a set of DDLStatements for it,
+// covering each tag type, for use in output formatting tests.
+func getFormatTestDDL(t *testing.T) (*Target, []*DDLStatement) {
+	t.Helper()
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{
+		Instance:   inst,
+		Dir:        getDir(t, "testdata/simple/one", ""),
+		SchemaName: "product",
+	}
+	comments := &tengo.Table{Name: "comments"}
+	posts := &tengo.Table{Name: "posts"}
+	users := &tengo.Table{Name: "users"}
+	proc := &tengo.Routine{Name: "cleanup", Type: tengo.ObjectTypeProc}
+	makeDDL := func(diff tengo.ObjectDiff, stmt string, unsafe bool) *DDLStatement {
+		return &DDLStatement{
+			stmt:       stmt,
+			instance:   inst,
+			schemaName: "product",
+			target:     target,
+			diff:       diff,
+			unsafe:     unsafe,
+		}
+	}
+	ddls := []*DDLStatement{
+		makeDDL(tengo.NewCreateTable(comments), "CREATE TABLE `comments` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1", false),
+		makeDDL(&tengo.TableDiff{Type: tengo.DiffTypeAlter, From: posts, To: posts}, "ALTER TABLE `posts` ADD COLUMN `body` text", false),
+		makeDDL(tengo.NewDropTable(users), "DROP TABLE `users`", true),
+		makeDDL(&tengo.RoutineDiff{From: proc}, "DROP PROCEDURE `cleanup`", false),
+	}
+	return target, ddls
+}
+
+func TestPrinterFormatGolden(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	var buf bytes.Buffer
+	printer := NewPrinter(false)
+	printer.out = &buf
+	printer.Annotate(true)
+	printer.printSummary(target, ddls)
+	for _, ddl := range ddls {
+		printer.printDDL(ddl)
+	}
+
+	expected, err := ioutil.ReadFile("testdata/format.golden")
+	if err != nil {
+		t.Fatalf("Unable to read golden file: %s", err)
+	}
+	if actual := buf.String(); actual != string(expected) {
+		t.Errorf("Output does not match testdata/format.golden. Actual output:\n%s", actual)
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Error("Expected no ANSI color codes in output with color disabled")
+	}
+}
+
+func TestPrinterFormatColor(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	var buf bytes.Buffer
+	printer := NewPrinter(false)
+	printer.out = &buf
+	printer.Annotate(true)
+	printer.UseColor(true)
+	printer.printSummary(target, ddls)
+	for _, ddl := range ddls {
+		printer.printDDL(ddl)
+	}
+	output := buf.String()
+	for _, expected := range []string{colorGreen + "[create]" + colorReset + " ", colorUnsafe + "[unsafe]" + colorReset + " ", colorRed + "[drop]" + colorReset + "   "} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, but it did not. Output:\n%s", expected, output)
+		}
+	}
+
+	// Removing color codes should yield the same output as with color disabled
+	stripped := output
+	for _, code := range []string{colorReset, colorBold, colorGreen, colorYellow, colorRed, colorUnsafe} {
+		stripped = strings.Replace(stripped, code, "", -1)
+	}
+	expected, err := ioutil.ReadFile("testdata/format.golden")
+	if err != nil {
+		t.Fatalf("Unable to read golden file: %s", err)
+	}
+	if stripped != string(expected) {
+		t.Errorf("Colorized output differs from golden file beyond color codes:\n%s", stripped)
+	}
+}
+
+func TestPrinterFormatDefault(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	var buf bytes.Buffer
+	printer := NewPrinter(false)
+	printer.out = &buf
+	printer.UseColor(true) // no effect without annotations
+	printer.printSummary(target, ddls)
+	for _, ddl := range ddls {
+		printer.printDDL(ddl)
+	}
+	expected := "-- instance: 127.0.0.1:3306\nUSE `product`;\nCREATE TABLE `comments` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1;\nALTER TABLE `posts` ADD COLUMN `body` text;\nDROP TABLE `users`;\nDROP PROCEDURE `cleanup`;\n"
+	if actual := buf.String(); actual != expected {
+		t.Errorf("Unexpected output with annotations disabled:\n%s", actual)
+	}
+}
+
+func TestPrinterBriefSummary(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	var buf bytes.Buffer
+	printer := NewPrinter(true)
+	printer.out = &buf
+	printer.printSummary(target, ddls)
+	for _, ddl := range ddls {
+		printer.printDDL(ddl)
+	}
+	if actual := buf.String(); actual != "127.0.0.1:3306\n" {
+		t.Errorf("Unexpected brief mode output: %q", actual)
+	}
+}
+
+func TestPrinterCollapseSamples(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	printTarget := func(printer *Printer, t *Target, ddls []*DDLStatement) {
+		retargeted := make([]*DDLStatement, len(ddls))
+		for n, ddl := range ddls {
+			ddlCopy := *ddl
+			ddlCopy.target, ddlCopy.instance, ddlCopy.schemaName = t, t.Instance, t.SchemaName
+			retargeted[n] = &ddlCopy
+		}
+		printer.printSummary(t, retargeted)
+		for _, ddl := range retargeted {
+			printer.printDDL(ddl)
+		}
+	}
+	instB, _ := tengo.NewInstance("mysql", "root:@tcp(127.0.0.2:3306)/")
+	instA, _ := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3307)/")
+	targetB := &Target{Instance: instB, Dir: target.Dir, SchemaName: "product"}
+	targetA := &Target{Instance: instA, Dir: target.Dir, SchemaName: "product_2"}
+	targetC := &Target{Instance: instA, Dir: target.Dir, SchemaName: "product_3"}
+
+	var buf bytes.Buffer
+	printer := NewPrinter(false)
+	printer.out = &buf
+	printer.Annotate(true)
+	printer.CollapseSamples(true)
+	printTarget(printer, targetB, ddls)
+	printTarget(printer, target, ddls)
+	printTarget(printer, targetC, ddls[1:2])
+	printTarget(printer, targetA, ddls)
+	if buf.Len() > 0 {
+		t.Fatalf("Expected output to be buffered, but found %q", buf.String())
+	}
+	printer.PrintSamples()
+	output := buf.String()
+	expectHeader := "-- 3 targets with identical differences:\n--   127.0.0.1:3306 product\n--   127.0.0.1:3307 product_2\n--   127.0.0.2:3306 product\n-- applier/testdata/simple/one: 4 changes\n"
+	if !strings.HasPrefix(output, expectHeader) {
+		t.Errorf("Unexpected start of collapsed output:\n%s", output)
+	}
+	if strings.Count(output, "DROP TABLE `users`") != 1 || strings.Count(output, "ADD COLUMN `body`") != 2 {
+		t.Errorf("Expected each distinct set of differences to be output once, instead found:\n%s", output)
+	}
+	if !strings.Contains(output, "-- instance: 127.0.0.1:3307\n-- applier/testdata/simple/one: 1 change\n--   [alter] table `posts`\nUSE `product_3`;\n") {
+		t.Errorf("Expected single-target group to be output with instance header, instead found:\n%s", output)
+	}
+
+	// Buffer is cleared after printing
+	buf.Reset()
+	printer.PrintSamples()
+	if buf.Len() > 0 {
+		t.Errorf("Expected no output from second PrintSamples, instead found %q", buf.String())
+	}
+}
+
+func TestFormatDDLNote(t *testing.T) {
+	_, ddls := getFormatTestDDL(t)
+	ddl := ddls[1]
+	ddl.unsafe = true
+	ddl.note = "index `name` would have key size of 1020 bytes, exceeding limit of 767 bytes"
+	expected := "-- index `name` would have key size of 1020 bytes, exceeding limit of 767 bytes\n/* [unsafe] */ ALTER TABLE `posts` ADD COLUMN `body` text;\n"
+	if actual := formatDDL(ddl, true, false); actual != expected {
+		t.Errorf("Unexpected output from formatDDL: %q", actual)
+	}
+}
+
+func TestFormatDDLCommentedOut(t *testing.T) {
+	_, ddls := getFormatTestDDL(t)
+	ddl := ddls[1]
+	ddl.unsafe, ddl.commentedOut = true, true
+	ddl.unsafeCodes = []UnsafeCode{UnsafeIndexKeySize}
+	expected := "-- Unsafe statement [US302 index-key-size] commented out; use --allow-oversized-keys to include it\n-- ALTER TABLE `posts` ADD COLUMN `body` text;\n"
+	if actual := formatDDL(ddl, false, false); actual != expected {
+		t.Errorf("Unexpected output from formatDDL: %q", actual)
+	}
+	ddl.unsafeCodes = []UnsafeCode{UnsafeDropColumn, UnsafeIndexKeySize}
+	if actual := formatDDL(ddl, false, false); !strings.Contains(actual, "use --allow-oversized-keys along with --allow-unsafe, --allow-unsafe-codes, or --safe-below-size to include it") {
+		t.Errorf("Unexpected output from formatDDL: %q", actual)
+	}
+}
+
+func TestFormatDDLEstimate(t *testing.T) {
+	_, ddls := getFormatTestDDL(t)
+	ddl := ddls[1]
+	ddl.estimate = &ddlEstimate{Algorithm: algorithmInplace, Lock: lockNone}
+	expected := "/* [alter] */ ALTER TABLE `posts` ADD COLUMN `body` text; -- algorithm=INPLACE, lock=NONE\n"
+	if actual := formatDDL(ddl, true, false); actual != expected {
+		t.Errorf("Unexpected output from formatDDL: %q", actual)
+	}
+}
+
+func TestFormatPassthrough(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	ddl := &DDLStatement{
+		stmt:        "CREATE SEQUENCE s1",
+		instance:    ddls[0].instance,
+		schemaName:  "product",
+		target:      target,
+		migration:   "seq.passthrough.sql",
+		passthrough: true,
+	}
+	if actual, expected := formatDDL(ddl, true, false), "/* [passthrough] */ CREATE SEQUENCE s1;\n"; actual != expected {
+		t.Errorf("Unexpected output from formatDDL: %q", actual)
+	}
+	summary := formatSummary("mydb", "product", []*DDLStatement{ddls[1], ddl}, false)
+	if expected := "--   [passthrough] file  seq.passthrough.sql\n"; !strings.Contains(summary, expected) {
+		t.Errorf("Expected summary to contain %q, instead found %q", expected, summary)
+	}
+}
@@ -0,0 +1,616 @@
+package applier
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// objectTypeGrant is the object type of grant diffs. tengo does not model
+// privileges, so this type is only used within this package.
+const objectTypeGrant tengo.ObjectType = "grant"
+
+// grantUser identifies an account which holds privileges.
+type grantUser struct {
+	Name string
+	Host string // always lower-case
+}
+
+// String returns u in quoted 'name'@'host' form.
+func (u grantUser) String() string {
+	return fmt.Sprintf("'%s'@'%s'", tengo.EscapeValueForCreateTable(u.Name), tengo.EscapeValueForCreateTable(u.Host))
+}
+
+// grantLevel identifies the object which privileges are granted on.
+type grantLevel struct {
+	Type   string // "TABLE" for tables and schemas, or "PROCEDURE" or "FUNCTION"
+	Schema string // "*" for global privileges
+	Name   string // "*" for schema-level or global privileges
+}
+
+// String returns l in the form used by GRANT and REVOKE statements.
+func (l grantLevel) String() string {
+	var prefix string
+	if l.Type != "TABLE" {
+		prefix = l.Type + " "
+	}
+	schema, name := l.Schema, l.Name
+	if schema != "*" && name == "*" {
+		// Schema names in schema-level privileges are LIKE patterns, so wildcards
+		// are escaped to only match the schema itself
+		schema = tengo.EscapeIdentifier(strings.NewReplacer("_", `\_`, "%", `\%`).Replace(schema))
+	} else if schema != "*" {
+		schema = tengo.EscapeIdentifier(schema)
+	}
+	if name != "*" {
+		name = tengo.EscapeIdentifier(name)
+	}
+	return prefix + schema + "." + name
+}
+
+// unescapeSchemaPattern removes backslash escapes of the _ and % wildcards from
+// a schema name in a privilege level. SHOW GRANTS reports schema names exactly
+// as they were granted, so the same schema may appear either escaped or not.
+func unescapeSchemaPattern(schema string) string {
+	return strings.NewReplacer(`\_`, "_", `\%`, "%").Replace(schema)
+}
+
+// grantPriv represents a single privilege held by a user on an object. Column
+// privileges are represented as one grantPriv per column.
+type grantPriv struct {
+	User   grantUser
+	Level  grantLevel
+	Priv   string // upper-case privilege name, such as "SELECT" or "GRANT OPTION"
+	Column string // lower-case column name, for column privileges only
+}
+
+// grantToken is a single token of a GRANT statement or account name.
+type grantToken struct {
+	text   string
+	quoted bool // true if text was a quoted string or identifier, now unquoted
+}
+
+// lexGrant splits text into tokens. Quoted strings and identifiers become a
+// single token, with quotes removed and escapes resolved, except that escaped
+// wildcards remain escaped since they are significant in privilege levels.
+func lexGrant(text string) ([]grantToken, error) {
+	var tokens []grantToken
+	for pos := 0; pos < len(text); {
+		c := text[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+		case c == '`' || c == '\'' || c == '"':
+			var b strings.Builder
+			end := -1
+			for n := pos + 1; n < len(text); n++ {
+				if text[n] == '\\' && c != '`' && n+1 < len(text) {
+					if next := text[n+1]; next == '_' || next == '%' {
+						b.WriteByte('\\')
+					}
+					b.WriteByte(text[n+1])
+					n++
+				} else if text[n] == c && n+1 < len(text) && text[n+1] == c {
+					b.WriteByte(c)
+					n++
+				} else if text[n] == c {
+					end = n
+					break
+				} else {
+					b.WriteByte(text[n])
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote %c", c)
+			}
+			tokens = append(tokens, grantToken{text: b.String(), quoted: true})
+			pos = end + 1
+		case strings.IndexByte("(),.@*;", c) >= 0:
+			tokens = append(tokens, grantToken{text: string(c)})
+			pos++
+		default:
+			end := pos + 1
+			for end < len(text) && strings.IndexByte(" \t\n\r`'\"(),.@*;", text[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, grantToken{text: text[pos:end]})
+			pos = end
+		}
+	}
+	return tokens, nil
+}
+
+// grantParser parses the tokens of a GRANT statement or account name.
+type grantParser struct {
+	tokens []grantToken
+	pos    int
+}
+
+// done returns true if all tokens have been consumed, ignoring any trailing
+// semicolon.
+func (p *grantParser) done() bool {
+	return p.pos >= len(p.tokens) || (p.pos == len(p.tokens)-1 && p.tokens[p.pos].text == ";")
+}
+
+// peek returns the next token without consuming it.
+func (p *grantParser) peek() grantToken {
+	if p.done() {
+		return grantToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *grantParser) next() grantToken {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// punct consumes the next token and returns true if it is the unquoted
+// punctuation character s.
+func (p *grantParser) punct(s string) bool {
+	if token := p.peek(); !token.quoted && token.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// keyword consumes the next token and returns true if it is the unquoted
+// keyword word, compared case-insensitively.
+func (p *grantParser) keyword(word string) bool {
+	if token := p.peek(); !token.quoted && strings.EqualFold(token.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// ident consumes and returns the next token, which must be an identifier or
+// quoted string rather than punctuation.
+func (p *grantParser) ident(what string) (string, error) {
+	token := p.next()
+	if token.text == "" && !token.quoted {
+		return "", fmt.Errorf("expected %s, but statement ended", what)
+	} else if !token.quoted && strings.IndexByte("(),.@*;", token.text[0]) >= 0 {
+		return "", fmt.Errorf("expected %s, but found %q", what, token.text)
+	}
+	return token.text, nil
+}
+
+// user parses an account name, defaulting to a host of '%' if omitted. For
+// convenience, unquoted host names may contain dots.
+func (p *grantParser) user() (u grantUser, err error) {
+	if u.Name, err = p.ident("user name"); err != nil {
+		return u, err
+	}
+	u.Host = "%"
+	if p.punct("@") {
+		quoted := p.peek().quoted
+		if u.Host, err = p.ident("host name"); err != nil {
+			return u, err
+		}
+		for !quoted && p.punct(".") {
+			part, err := p.ident("host name")
+			if err != nil {
+				return u, err
+			}
+			u.Host += "." + part
+		}
+	}
+	u.Host = strings.ToLower(u.Host)
+	return u, nil
+}
+
+// parseGrantUser parses value as an account name, such as `app`, `app@10.%`,
+// or `'app'@'localhost'`.
+func parseGrantUser(value string) (grantUser, error) {
+	tokens, err := lexGrant(value)
+	if err != nil {
+		return grantUser{}, err
+	}
+	p := &grantParser{tokens: tokens}
+	u, err := p.user()
+	if err == nil && !p.done() {
+		err = fmt.Errorf("unexpected %q after account name", p.peek().text)
+	}
+	return u, err
+}
+
+// parseGrant parses a GRANT statement, as written in a grants file or returned
+// by SHOW GRANTS, returning one grantPriv per privilege, user, and column.
+// Privilege names are upper-cased, ALL is treated as ALL PRIVILEGES, and
+// USAGE is omitted since it means no privileges. WITH GRANT OPTION is treated
+// as a GRANT OPTION privilege. Authentication clauses, REQUIRE clauses, and
+// resource limits are ignored. Unqualified levels such as `*` or a bare table
+// name refer to defaultSchema; if defaultSchema is blank, they are an error.
+// Grants of roles, PROXY grants, and other statements lacking a privilege
+// level also return an error.
+func parseGrant(text, defaultSchema string) ([]grantPriv, error) {
+	tokens, err := lexGrant(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &grantParser{tokens: tokens}
+	if !p.keyword("GRANT") {
+		return nil, errors.New("not a GRANT statement")
+	}
+
+	// Privilege list, each optionally followed by a parenthesized column list
+	type privColumns struct {
+		priv    string
+		columns []string
+	}
+	var privs []privColumns
+	for {
+		var words []string
+		for token := p.peek(); !token.quoted && token.text != "" && strings.IndexByte("(),.@*;", token.text[0]) < 0 && !strings.EqualFold(token.text, "ON"); token = p.peek() {
+			words = append(words, strings.ToUpper(p.next().text))
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("expected privilege name, but found %q", p.peek().text)
+		}
+		pc := privColumns{priv: strings.Join(words, " ")}
+		if pc.priv == "ALL" {
+			pc.priv = "ALL PRIVILEGES"
+		}
+		if p.punct("(") {
+			for {
+				col, err := p.ident("column name")
+				if err != nil {
+					return nil, err
+				}
+				pc.columns = append(pc.columns, strings.ToLower(col))
+				if p.punct(")") {
+					break
+				} else if !p.punct(",") {
+					return nil, fmt.Errorf("expected , or ) in column list, but found %q", p.peek().text)
+				}
+			}
+		}
+		privs = append(privs, pc)
+		if !p.punct(",") {
+			break
+		}
+	}
+	if !p.keyword("ON") {
+		return nil, fmt.Errorf("expected ON, but found %q", p.peek().text)
+	}
+
+	// Privilege level, optionally preceded by an object type
+	level := grantLevel{Type: "TABLE"}
+	for _, objType := range []string{"TABLE", "PROCEDURE", "FUNCTION"} {
+		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text != "." && p.keyword(objType) {
+			level.Type = objType
+			break
+		}
+	}
+	var parts []string
+	for {
+		if p.punct("*") {
+			parts = append(parts, "*")
+		} else if part, err := p.ident("privilege level"); err != nil {
+			return nil, err
+		} else {
+			parts = append(parts, part)
+		}
+		if len(parts) > 1 || !p.punct(".") {
+			break
+		}
+	}
+	if len(parts) == 1 {
+		if defaultSchema == "" {
+			return nil, fmt.Errorf("privilege level %s is not qualified with a schema name", parts[0])
+		}
+		parts = append([]string{defaultSchema}, parts[0])
+	}
+	level.Schema, level.Name = unescapeSchemaPattern(parts[0]), parts[1]
+	if level.Schema == "*" && level.Name != "*" {
+		return nil, fmt.Errorf("invalid privilege level *.%s", level.Name)
+	} else if level.Type != "TABLE" && level.Name == "*" {
+		return nil, fmt.Errorf("%s privilege level requires a routine name", level.Type)
+	} else if level.Type != "TABLE" {
+		level.Name = strings.ToLower(level.Name) // routine names are case-insensitive
+	}
+
+	// Account list, ignoring any authentication clauses
+	if !p.keyword("TO") {
+		return nil, fmt.Errorf("expected TO, but found %q", p.peek().text)
+	}
+	var users []grantUser
+	for {
+		u, err := p.user()
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+		if p.keyword("IDENTIFIED") {
+			if p.keyword("WITH") {
+				p.next() // authentication plugin
+			}
+			if p.keyword("BY") || p.keyword("AS") {
+				p.keyword("PASSWORD")
+				p.next()
+			}
+		}
+		if !p.punct(",") {
+			break
+		}
+	}
+
+	// Only GRANT OPTION matters among the remaining clauses
+	var grantOption, afterWith bool
+	for !p.done() {
+		if p.keyword("WITH") {
+			afterWith = true
+		} else if afterWith && p.keyword("GRANT") && p.keyword("OPTION") {
+			grantOption = true
+		} else {
+			p.next()
+		}
+	}
+	if grantOption {
+		privs = append(privs, privColumns{priv: "GRANT OPTION"})
+	}
+
+	var result []grantPriv
+	for _, u := range users {
+		for _, pc := range privs {
+			if pc.priv == "USAGE" {
+				continue
+			}
+			if len(pc.columns) == 0 {
+				result = append(result, grantPriv{User: u, Level: level, Priv: pc.priv})
+			}
+			for _, col := range pc.columns {
+				result = append(result, grantPriv{User: u, Level: level, Priv: pc.priv, Column: col})
+			}
+		}
+	}
+	return result, nil
+}
+
+// grantDiff represents privileges to grant to, or revoke from, a single user
+// on a single object. It satisfies the tengo.ObjectDiff interface. Revoking
+// privileges is considered unsafe, since it may break applications.
+type grantDiff struct {
+	User   grantUser
+	Level  grantLevel
+	Privs  []grantPriv
+	Revoke bool
+}
+
+// DiffType returns the type of diff operation.
+func (gd *grantDiff) DiffType() tengo.DiffType {
+	if gd.Revoke {
+		return tengo.DiffTypeDrop
+	}
+	return tengo.DiffTypeCreate
+}
+
+// ObjectKey returns a value representing the user whose privileges change.
+func (gd *grantDiff) ObjectKey() tengo.ObjectKey {
+	return tengo.ObjectKey{Type: objectTypeGrant, Name: gd.User.Name + "@" + gd.User.Host}
+}
+
+// Statement returns the GRANT or REVOKE statement. Column privileges are
+// grouped by privilege name. For revocations, a ForbiddenDiffError is returned
+// unless mods permit unsafe operations.
+func (gd *grantDiff) Statement(mods tengo.StatementModifiers) (string, error) {
+	var privs []string
+	var grantOption bool
+	columns := make(map[string][]string)
+	for _, gp := range gd.Privs {
+		if gp.Priv == "GRANT OPTION" && !gd.Revoke {
+			grantOption = true
+			continue
+		}
+		if _, seen := columns[gp.Priv]; !seen {
+			privs = append(privs, gp.Priv)
+			columns[gp.Priv] = nil
+		}
+		if gp.Column != "" {
+			columns[gp.Priv] = append(columns[gp.Priv], tengo.EscapeIdentifier(gp.Column))
+		}
+	}
+	for n, priv := range privs {
+		if cols := columns[priv]; len(cols) > 0 {
+			privs[n] = fmt.Sprintf("%s (%s)", priv, strings.Join(cols, ", "))
+		}
+	}
+	if len(privs) == 0 {
+		privs = []string{"USAGE"}
+	}
+	if !gd.Revoke {
+		stmt := fmt.Sprintf("GRANT %s ON %s TO %s", strings.Join(privs, ", "), gd.Level, gd.User)
+		if grantOption {
+			stmt += " WITH GRANT OPTION"
+		}
+		return stmt, nil
+	}
+	stmt := fmt.Sprintf("REVOKE %s ON %s FROM %s", strings.Join(privs, ", "), gd.Level, gd.User)
+	if !mods.AllowUnsafe {
+		return stmt, &tengo.ForbiddenDiffError{
+			Reason:    "Revoking privileges not permitted",
+			Statement: stmt,
+		}
+	}
+	return stmt, nil
+}
+
+// grantSet is a set of privileges.
+type grantSet map[grantPriv]bool
+
+// diffGrants returns the diffs needed to change the privileges in from to
+// match those in to. Revocations come first, since revoking ALL PRIVILEGES
+// would otherwise also remove any privileges granted beforehand at the same
+// level. Within each group, diffs are sorted by user and level.
+func diffGrants(from, to grantSet) []tengo.ObjectDiff {
+	group := func(privs grantSet, exclude grantSet, revoke bool) []tengo.ObjectDiff {
+		type userLevel struct {
+			user  grantUser
+			level grantLevel
+		}
+		byUserLevel := make(map[userLevel]*grantDiff)
+		var diffs []*grantDiff
+		for gp := range privs {
+			if exclude[gp] {
+				continue
+			}
+			key := userLevel{gp.User, gp.Level}
+			gd := byUserLevel[key]
+			if gd == nil {
+				gd = &grantDiff{User: gp.User, Level: gp.Level, Revoke: revoke}
+				byUserLevel[key] = gd
+				diffs = append(diffs, gd)
+			}
+			gd.Privs = append(gd.Privs, gp)
+		}
+		sort.Slice(diffs, func(i, j int) bool {
+			if a, b := diffs[i].User.String(), diffs[j].User.String(); a != b {
+				return a < b
+			}
+			return diffs[i].Level.String() < diffs[j].Level.String()
+		})
+		result := make([]tengo.ObjectDiff, len(diffs))
+		for n, gd := range diffs {
+			sort.Slice(gd.Privs, func(i, j int) bool {
+				if gd.Privs[i].Priv != gd.Privs[j].Priv {
+					return gd.Privs[i].Priv < gd.Privs[j].Priv
+				}
+				return gd.Privs[i].Column < gd.Privs[j].Column
+			})
+			result[n] = gd
+		}
+		return result
+	}
+	return append(group(from, to, true), group(to, from, false)...)
+}
+
+// managedGrantUsers returns the accounts listed in the manage-grants option of
+// t's dir, or nil if the option is not set. A ConfigError is returned if any
+// value cannot be parsed as an account name.
+func (t *Target) managedGrantUsers() (map[grantUser]bool, error) {
+	if t.Dir.Config.FindOption("manage-grants") == nil {
+		return nil, nil
+	}
+	values := t.Dir.Config.GetSlice("manage-grants", ',', true)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	users := make(map[grantUser]bool, len(values))
+	for _, value := range values {
+		u, err := parseGrantUser(value)
+		if err != nil {
+			return nil, ConfigError(fmt.Sprintf("Option manage-grants: invalid account name %q: %s", value, err))
+		}
+		users[u] = true
+	}
+	return users, nil
+}
+
+// desiredGrants returns the privileges defined by the grants file of t's dir,
+// for the supplied managed users, on t's schema and objects within it. GRANT
+// statements for other users or other schemas are ignored with a warning.
+func (t *Target) desiredGrants(users map[grantUser]bool) (grantSet, error) {
+	statements, err := fs.GrantStatements(*t.Dir.GrantsFile)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(grantSet)
+	for _, stmt := range statements {
+		privs, err := parseGrant(stmt.Body(), t.SchemaName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to parse GRANT statement: %s", stmt.Location(), err)
+		} else if len(privs) > 0 && privs[0].Level.Schema != t.SchemaName {
+			t.logger().Warnf("%s: ignoring privileges on %s, since only privileges on schema %s and objects within it are managed", stmt.Location(), privs[0].Level, t.SchemaName)
+			continue
+		}
+		unlisted := make(map[grantUser]bool)
+		for _, gp := range privs {
+			if !users[gp.User] {
+				if !unlisted[gp.User] {
+					t.logger().Warnf("%s: ignoring privileges of %s, which is not listed in manage-grants", stmt.Location(), gp.User)
+					unlisted[gp.User] = true
+				}
+				continue
+			}
+			desired[gp] = true
+		}
+	}
+	return desired, nil
+}
+
+// currentGrants returns the privileges held by user on t's schema and objects
+// within it, as reported by SHOW GRANTS. If the user does not exist, exists is
+// false.
+func (t *Target) currentGrants(user grantUser) (current grantSet, exists bool, err error) {
+	db, err := t.Instance.Connect("", "")
+	if err != nil {
+		return nil, false, err
+	}
+	var lines []string
+	if err := db.Select(&lines, "SHOW GRANTS FOR "+user.String()); tengo.IsDatabaseError(err, 1141) {
+		return nil, false, nil // ER_NONEXISTING_GRANT
+	} else if err != nil {
+		return nil, false, err
+	}
+	current = make(grantSet)
+	for _, line := range lines {
+		privs, err := parseGrant(line, "")
+		if err != nil {
+			continue // role grants, PROXY grants, etc are not managed
+		}
+		for _, gp := range privs {
+			if gp.Level.Schema == t.SchemaName && gp.User == user {
+				current[gp] = true
+			}
+		}
+	}
+	return current, true, nil
+}
+
+// grantChanges returns diffs granting or revoking privileges on t's schema, so
+// that the users listed in the manage-grants option hold exactly the
+// privileges defined by the grants file of t's dir. Privileges are only
+// managed if the option is set and the dir has a grants file. Privileges of
+// unlisted users are never examined or changed, and users are never created:
+// if a listed user does not exist, a warning is logged and its privileges are
+// skipped.
+func (t *Target) grantChanges() ([]tengo.ObjectDiff, error) {
+	if t.Dir.GrantsFile == nil {
+		return nil, nil
+	}
+	users, err := t.managedGrantUsers()
+	if err != nil || users == nil {
+		return nil, err
+	}
+	desired, err := t.desiredGrants(users)
+	if err != nil {
+		return nil, err
+	}
+	current := make(grantSet)
+	for u := range users {
+		userGrants, exists, err := t.currentGrants(u)
+		if err != nil {
+			return nil, err
+		} else if !exists {
+			t.logger().Warnf("%s %s: user %s listed in manage-grants does not exist, so its privileges are skipped; users must be created separately", t.Instance, t.SchemaName, u)
+			for gp := range desired {
+				if gp.User == u {
+					delete(desired, gp)
+				}
+			}
+			continue
+		}
+		for gp := range userGrants {
+			current[gp] = true
+		}
+	}
+	return diffGrants(current, desired), nil
+}
@@ -0,0 +1,340 @@
+package applier
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// readGrantCorpus returns the groups of non-comment lines in a file in
+// testdata/grants. Groups are separated by blank lines.
+func readGrantCorpus(t *testing.T, name string) (groups [][]string) {
+	t.Helper()
+	f, err := os.Open("testdata/grants/" + name)
+	if err != nil {
+		t.Fatalf("Unable to open corpus: %s", err)
+	}
+	defer f.Close()
+	var group []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" && len(group) > 0 {
+			groups = append(groups, group)
+			group = nil
+		} else if line != "" && line[0] != '#' {
+			group = append(group, line)
+		}
+	}
+	if len(group) > 0 {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// grantStrings returns privs in a sorted human-readable form, for comparison
+// purposes.
+func grantStrings(privs []grantPriv) []string {
+	result := make([]string, len(privs))
+	for n, gp := range privs {
+		result[n] = fmt.Sprintf("%s %s %s(%s)", gp.User, gp.Level, gp.Priv, gp.Column)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestParseGrantEquivalent(t *testing.T) {
+	groups := readGrantCorpus(t, "equivalent.txt")
+	if len(groups) < 5 {
+		t.Fatalf("Expected corpus to have at least 5 groups, instead found %d", len(groups))
+	}
+	for _, group := range groups {
+		var expected []string
+		for n, line := range group {
+			privs, err := parseGrant(line, "product")
+			if err != nil {
+				t.Errorf("Unexpected error parsing %q: %s", line, err)
+				continue
+			} else if len(privs) == 0 {
+				t.Errorf("Expected at least one privilege from %q, but found none", line)
+				continue
+			}
+			actual := grantStrings(privs)
+			if n == 0 {
+				expected = actual
+			} else if strings.Join(actual, "|") != strings.Join(expected, "|") {
+				t.Errorf("Parsing %q: expected %q to match first line of group %q, instead found %q", line, expected, group[0], actual)
+			}
+		}
+	}
+
+	// Without a default schema, unqualified levels are an error, as is the case
+	// for SHOW GRANTS output
+	if _, err := parseGrant("GRANT SELECT ON * TO app", ""); err == nil {
+		t.Error("Expected error parsing unqualified level without default schema, but err was nil")
+	}
+	if privs, err := parseGrant("GRANT USAGE ON *.* TO `app`@`%`", ""); err != nil || len(privs) != 0 {
+		t.Errorf("Expected USAGE to represent no privileges, instead found %v, %v", privs, err)
+	}
+
+	// Escaped wildcards are removed from schema names for comparison, and
+	// restored for schema-level privileges in generated statements
+	privs, err := parseGrant("GRANT SELECT ON `my\\_db`.* TO app", "")
+	if err != nil || len(privs) != 1 || privs[0].Level.Schema != "my_db" {
+		t.Fatalf("Unexpected result from parseGrant with escaped schema name: %v, %v", privs, err)
+	}
+	if actual := privs[0].Level.String(); actual != "`my\\_db`.*" {
+		t.Errorf("Unexpected schema-level String(): %s", actual)
+	}
+	if actual := (grantLevel{Type: "TABLE", Schema: "my_db", Name: "users"}).String(); actual != "`my_db`.`users`" {
+		t.Errorf("Unexpected table-level String(): %s", actual)
+	}
+}
+
+func TestParseGrantInvalid(t *testing.T) {
+	for _, group := range readGrantCorpus(t, "invalid.txt") {
+		for _, line := range group {
+			if privs, err := parseGrant(line, "product"); err == nil {
+				t.Errorf("Expected error parsing %q, instead found %v", line, grantStrings(privs))
+			}
+		}
+	}
+}
+
+func TestParseGrantUser(t *testing.T) {
+	cases := map[string]grantUser{
+		"app":                 {Name: "app", Host: "%"},
+		"app@10.%":            {Name: "app", Host: "10.%"},
+		"'app'@'LocalHost'":   {Name: "app", Host: "localhost"},
+		"`app`@`%`":           {Name: "app", Host: "%"},
+		`"o'brien"@localhost`: {Name: "o'brien", Host: "localhost"},
+	}
+	for input, expected := range cases {
+		if actual, err := parseGrantUser(input); err != nil || actual != expected {
+			t.Errorf("Unexpected result from parseGrantUser(%q): %+v, %v", input, actual, err)
+		}
+	}
+	for _, input := range []string{"", "@localhost", "app@", "app@localhost extra", "'app"} {
+		if actual, err := parseGrantUser(input); err == nil {
+			t.Errorf("Expected error from parseGrantUser(%q), instead found %+v", input, actual)
+		}
+	}
+	if actual := (grantUser{Name: "o'brien", Host: "%"}).String(); actual != "'o''brien'@'%'" {
+		t.Errorf("Unexpected String() result: %s", actual)
+	}
+}
+
+func TestDiffGrants(t *testing.T) {
+	parseSet := func(statements ...string) grantSet {
+		set := make(grantSet)
+		for _, stmt := range statements {
+			privs, err := parseGrant(stmt, "product")
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %s", stmt, err)
+			}
+			for _, gp := range privs {
+				set[gp] = true
+			}
+		}
+		return set
+	}
+	from := parseSet(
+		"GRANT ALL PRIVILEGES ON `product`.* TO `app`@`%`",
+		"GRANT SELECT (`id`, `name`, `email`) ON `product`.`users` TO `reporting`@`10.%`",
+		"GRANT EXECUTE ON PROCEDURE `product`.`cleanup` TO `app`@`%`",
+	)
+	to := parseSet(
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON * TO app WITH GRANT OPTION",
+		"GRANT SELECT (id, name), UPDATE (name) ON users TO reporting@'10.%'",
+		"GRANT EXECUTE ON PROCEDURE cleanup TO app",
+	)
+	expected := []string{
+		"REVOKE ALL PRIVILEGES ON `product`.* FROM 'app'@'%'",
+		"REVOKE SELECT (`email`) ON `product`.`users` FROM 'reporting'@'10.%'",
+		"GRANT DELETE, INSERT, SELECT, UPDATE ON `product`.* TO 'app'@'%' WITH GRANT OPTION",
+		"GRANT UPDATE (`name`) ON `product`.`users` TO 'reporting'@'10.%'",
+	}
+	diffs := diffGrants(from, to)
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d diffs, instead found %d", len(expected), len(diffs))
+	}
+	safeMods := tengo.StatementModifiers{}
+	unsafeMods := tengo.StatementModifiers{AllowUnsafe: true}
+	for n, diff := range diffs {
+		gd := diff.(*grantDiff)
+		stmt, err := diff.Statement(unsafeMods)
+		if stmt != expected[n] || err != nil {
+			t.Errorf("diffs[%d]: expected %q, instead found %q, %v", n, expected[n], stmt, err)
+		}
+		_, err = diff.Statement(safeMods)
+		if gd.Revoke != tengo.IsForbiddenDiff(err) {
+			t.Errorf("diffs[%d]: expected forbidden=%t without AllowUnsafe, instead err=%v", n, gd.Revoke, err)
+		}
+		codes := diffUnsafeCodes(diff)
+		if gd.Revoke && (len(codes) != 1 || codes[0] != UnsafeRevokeGrant) {
+			t.Errorf("diffs[%d]: expected revoke to have code %s, instead found %v", n, UnsafeRevokeGrant, codes)
+		} else if !gd.Revoke && len(codes) > 0 {
+			t.Errorf("diffs[%d]: expected grant to have no unsafe codes, instead found %v", n, codes)
+		}
+		if key := diff.ObjectKey(); key.Type != objectTypeGrant || key.Name != gd.User.Name+"@"+gd.User.Host {
+			t.Errorf("diffs[%d]: unexpected ObjectKey %+v", n, key)
+		}
+	}
+
+	// Revoking only the grant option
+	diffs = diffGrants(parseSet("GRANT SELECT ON * TO app WITH GRANT OPTION"), parseSet("GRANT SELECT ON * TO app"))
+	if len(diffs) != 1 {
+		t.Fatalf("Expected 1 diff, instead found %d", len(diffs))
+	} else if stmt, _ := diffs[0].Statement(unsafeMods); stmt != "REVOKE GRANT OPTION ON `product`.* FROM 'app'@'%'" {
+		t.Errorf("Unexpected statement: %s", stmt)
+	}
+
+	// Equivalent spellings result in no diffs
+	if diffs := diffGrants(from, from); len(diffs) != 0 {
+		t.Errorf("Expected no diffs between identical sets, instead found %d", len(diffs))
+	}
+}
+
+func TestDesiredGrants(t *testing.T) {
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/.skeema", "schema=product\n")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/users.sql", "CREATE TABLE users (id int);\n")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/.grants.sql", "GRANT SELECT ON * TO app, other;\nGRANT ALL ON `analytics`.* TO app;\nGRANT INSERT (id) ON users TO `app`@`%`;\n")
+	target, _ := getFormatTestDDL(t)
+
+	// Without manage-grants, or without a grants file, nothing is managed
+	target.Dir = getDir(t, "testdata/.scratch/grants", "")
+	if diffs, err := target.grantChanges(); diffs != nil || err != nil {
+		t.Errorf("Expected no diffs or error without manage-grants, instead found %v, %v", diffs, err)
+	}
+	target.Dir = getDir(t, "testdata/simple/one", "--manage-grants=app")
+	if diffs, err := target.grantChanges(); diffs != nil || err != nil {
+		t.Errorf("Expected no diffs or error without grants file, instead found %v, %v", diffs, err)
+	}
+
+	target.Dir = getDir(t, "testdata/.scratch/grants", "--manage-grants='app@%, reporting@10.%'")
+	users, err := target.managedGrantUsers()
+	if err != nil || len(users) != 2 || !users[grantUser{Name: "reporting", Host: "10.%"}] {
+		t.Fatalf("Unexpected result from managedGrantUsers: %v, %v", users, err)
+	}
+	desired, err := target.desiredGrants(users)
+	if err != nil {
+		t.Fatalf("Unexpected error from desiredGrants: %s", err)
+	}
+	privs := make([]grantPriv, 0, len(desired))
+	for gp := range desired {
+		privs = append(privs, gp)
+	}
+	expected := []string{
+		"'app'@'%' `product`.* SELECT()",
+		"'app'@'%' `product`.`users` INSERT(id)",
+	}
+	if actual := grantStrings(privs); strings.Join(actual, "|") != strings.Join(expected, "|") {
+		t.Errorf("Unexpected desired grants: expected %q, found %q", expected, actual)
+	}
+
+	target.Dir = getDir(t, "testdata/.scratch/grants", "--manage-grants=\"'app\"")
+	if _, err := target.grantChanges(); err == nil {
+		t.Error("Expected error from invalid manage-grants, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok {
+		t.Errorf("Expected ConfigError, instead found %T: %v", err, err)
+	}
+
+	fs.WriteTestFile(t, "testdata/.scratch/grants/.grants.sql", "GRANT SELECT ON TO app;\n")
+	target.Dir = getDir(t, "testdata/.scratch/grants", "--manage-grants=app")
+	if _, err := target.grantChanges(); err == nil {
+		t.Error("Expected error from unparseable grants file, but err was nil")
+	} else if _, ok := err.(ConfigError); ok {
+		t.Errorf("Expected non-ConfigError, instead found %v", err)
+	}
+}
+
+func TestGrantsTableNotGrantsFile(t *testing.T) {
+	// A table named grants keeps its ordinary file, which is never treated as
+	// a grants file, with or without manage-grants
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/.skeema", "schema=product\n")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/grants.sql", "CREATE TABLE grants (id int);\n")
+	target, _ := getFormatTestDDL(t)
+	key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "grants"}
+	for _, flags := range []string{"", "--manage-grants=app"} {
+		target.Dir = getDir(t, "testdata/.scratch/grants", flags)
+		if diffs, err := target.grantChanges(); diffs != nil || err != nil {
+			t.Errorf("Expected no grant diffs or error with flags %q, instead found %v, %v", flags, diffs, err)
+		}
+		if ls := target.Dir.LogicalSchemas; len(ls) != 1 || ls[0].Creates[key] == nil {
+			t.Errorf("Expected table grants to be defined with flags %q, instead found %+v", flags, ls)
+		}
+	}
+}
+
+func (s ApplierIntegrationSuite) TestGrantChanges(t *testing.T) {
+	db, err := s.d[0].Connect("", "")
+	if err != nil {
+		t.Fatalf("Unable to connect to DockerizedInstance: %s", err)
+	}
+	defer func() {
+		db.Exec("DROP USER 'skeema_app'@'%'")
+		db.Exec("DROP USER 'skeema_other'@'%'")
+	}()
+	for _, query := range []string{
+		"CREATE DATABASE product",
+		"CREATE TABLE product.users (id int unsigned NOT NULL PRIMARY KEY, name varchar(30))",
+		"CREATE USER 'skeema_app'@'%'",
+		"CREATE USER 'skeema_other'@'%'",
+		"GRANT ALL PRIVILEGES ON product.* TO 'skeema_app'@'%'",
+		"GRANT SELECT ON product.* TO 'skeema_other'@'%'",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Unexpected error from %q: %s", query, err)
+		}
+	}
+
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/.skeema", "schema=product\n")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/users.sql", "CREATE TABLE users (id int unsigned NOT NULL PRIMARY KEY, name varchar(30));\n")
+	fs.WriteTestFile(t, "testdata/.scratch/grants/.grants.sql", "GRANT SELECT, INSERT ON * TO skeema_app;\nGRANT SELECT (name) ON users TO skeema_missing;\n")
+	target := &Target{
+		Instance:   s.d[0].Instance,
+		Dir:        getDir(t, "testdata/.scratch/grants", "--manage-grants=skeema_app,skeema_missing"),
+		SchemaName: "product",
+	}
+	diffs, err := target.grantChanges()
+	if err != nil {
+		t.Fatalf("Unexpected error from grantChanges: %s", err)
+	}
+	expected := []string{
+		"REVOKE ALL PRIVILEGES ON `product`.* FROM 'skeema_app'@'%'",
+		"GRANT INSERT, SELECT ON `product`.* TO 'skeema_app'@'%'",
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("Expected %d diffs, instead found %d", len(expected), len(diffs))
+	}
+	for n, diff := range diffs {
+		stmt, _ := diff.Statement(tengo.StatementModifiers{AllowUnsafe: true})
+		if stmt != expected[n] {
+			t.Errorf("diffs[%d]: expected %q, found %q", n, expected[n], stmt)
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Unexpected error executing %q: %s", stmt, err)
+		}
+	}
+
+	// After applying the diffs, no further changes are needed, and the grants of
+	// unlisted users are untouched
+	if diffs, err := target.grantChanges(); len(diffs) != 0 || err != nil {
+		t.Errorf("Expected no diffs after applying changes, instead found %d, %v", len(diffs), err)
+	}
+	var lines []string
+	if err := db.Select(&lines, "SHOW GRANTS FOR 'skeema_other'@'%'"); err != nil {
+		t.Fatalf("Unexpected error from SHOW GRANTS: %s", err)
+	} else if !strings.Contains(strings.Join(lines, "\n"), "GRANT SELECT ON `product`.*") {
+		t.Errorf("Expected grants of unlisted user to be untouched, instead found %v", lines)
+	}
+}
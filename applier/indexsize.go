@@ -0,0 +1,180 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// InnoDB index key size limits, in bytes. The prefix limit applies to each
+// column of an index; the total limit applies to all columns of an index
+// combined. These assume the default innodb_page_size of 16k.
+const (
+	innoPrefixLimitSmall = 767
+	innoPrefixLimitLarge = 3072
+	innoKeyLimitTotal    = 3072
+)
+
+// charSetMaxBytes maps character sets to their maximum bytes per character.
+// Character sets not listed here, as well as binary string types, are treated
+// as one byte per character.
+var charSetMaxBytes = map[string]int{
+	"utf8mb4": 4,
+	"utf16":   4,
+	"utf16le": 4,
+	"utf32":   4,
+	"gb18030": 4,
+	"utf8":    3,
+	"utf8mb3": 3,
+	"ujis":    3,
+	"eucjpms": 3,
+	"ucs2":    2,
+	"big5":    2,
+	"gbk":     2,
+	"gb2312":  2,
+	"sjis":    2,
+	"cp932":   2,
+	"euckr":   2,
+	"latin1":  1,
+	"ascii":   1,
+	"binary":  1,
+}
+
+var reStringTypeLength = regexp.MustCompile(`^(?:var)?(?:char|binary)\((\d+)\)`)
+
+// indexKeySizeNote examines an ALTER TABLE diff for column changes which cause
+// any index including the column to exceed InnoDB's index key size limits. If
+// so, a note describing the first such index is returned; otherwise an empty
+// string is returned. The applicable limit depends on the table's row format
+// and the server's innodb_large_prefix setting, so inst is only queried if
+// some changed column is indexed.
+func indexKeySizeNote(td *tengo.TableDiff, inst *tengo.Instance) (string, error) {
+	if td.Type != tengo.DiffTypeAlter || td.From == nil || td.To == nil || !strings.EqualFold(td.To.Engine, "InnoDB") {
+		return "", nil
+	}
+	indexes := indexesWithChangedColumns(td.From, td.To)
+	if len(indexes) == 0 {
+		return "", nil
+	}
+	largePrefix, defaultRowFormat, err := innoKeySizeSettings(inst)
+	if err != nil {
+		return "", err
+	}
+	prefixLimit := indexPrefixLimit(td.To, largePrefix, defaultRowFormat)
+	for _, idx := range indexes {
+		if size, limit := indexKeySize(idx, prefixLimit); size > limit {
+			return fmt.Sprintf("index %s would have key size of %d bytes, exceeding limit of %d bytes", tengo.EscapeIdentifier(idx.Name), size, limit), nil
+		}
+	}
+	return "", nil
+}
+
+// indexesWithChangedColumns returns indexes of to which include at least one
+// column whose type or character set differs from the same-named column in
+// from.
+func indexesWithChangedColumns(from, to *tengo.Table) []*tengo.Index {
+	fromCols := from.ColumnsByName()
+	changed := make(map[string]bool)
+	for _, col := range to.Columns {
+		if fromCol, ok := fromCols[col.Name]; ok && (fromCol.TypeInDB != col.TypeInDB || fromCol.CharSet != col.CharSet) {
+			changed[col.Name] = true
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	var result []*tengo.Index
+	allIndexes := to.SecondaryIndexes
+	if to.PrimaryKey != nil {
+		allIndexes = append([]*tengo.Index{to.PrimaryKey}, allIndexes...)
+	}
+	for _, idx := range allIndexes {
+		for _, col := range idx.Columns {
+			if changed[col.Name] {
+				result = append(result, idx)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// innoKeySizeSettings queries inst for the server settings affecting index key
+// size limits. Servers which lack innodb_large_prefix (MySQL 8.0+, MariaDB
+// 10.3+) always behave as if it is enabled. Servers which lack
+// innodb_default_row_format (prior to MySQL 5.7.9 or MariaDB 10.2.2) always
+// default to the COMPACT row format.
+func innoKeySizeSettings(inst *tengo.Instance) (largePrefix bool, defaultRowFormat string, err error) {
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return false, "", err
+	}
+	if err := db.QueryRow("SELECT @@global.innodb_large_prefix").Scan(&largePrefix); err != nil {
+		largePrefix = true
+	}
+	if err := db.QueryRow("SELECT @@global.innodb_default_row_format").Scan(&defaultRowFormat); err != nil {
+		defaultRowFormat = "compact"
+	}
+	return largePrefix, defaultRowFormat, nil
+}
+
+// indexPrefixLimit returns the maximum size in bytes of any one column of an
+// index in table.
+func indexPrefixLimit(table *tengo.Table, largePrefix bool, defaultRowFormat string) int {
+	rowFormat := defaultRowFormat
+	if def, ok := tableOptionsByName(table.CreateOptions)["ROW_FORMAT"]; ok {
+		rowFormat = strings.SplitN(def, "=", 2)[1]
+	}
+	rowFormat = strings.ToUpper(rowFormat)
+	if largePrefix && (rowFormat == "DYNAMIC" || rowFormat == "COMPRESSED") {
+		return innoPrefixLimitLarge
+	}
+	return innoPrefixLimitSmall
+}
+
+// indexKeySize returns the size in bytes of the largest column of idx, or of
+// all columns of idx combined, whichever is closer to exceeding its limit;
+// along with the corresponding limit. Only string columns are sized, so for
+// multi-column indexes which include other types, the total is a lower bound.
+func indexKeySize(idx *tengo.Index, prefixLimit int) (size, limit int) {
+	var total, largest int
+	for n, col := range idx.Columns {
+		var subPart int
+		if n < len(idx.SubParts) {
+			subPart = int(idx.SubParts[n])
+		}
+		colSize := columnKeySize(col, subPart)
+		total += colSize
+		if colSize > largest {
+			largest = colSize
+		}
+	}
+	if largest > prefixLimit {
+		return largest, prefixLimit
+	}
+	return total, innoKeyLimitTotal
+}
+
+// columnKeySize returns the size in bytes that col contributes to an index
+// key, given the index's prefix length for the column (or 0 if the full
+// column is indexed). Non-string columns return 0.
+func columnKeySize(col *tengo.Column, subPart int) int {
+	chars := subPart
+	if chars == 0 {
+		matches := reStringTypeLength.FindStringSubmatch(col.TypeInDB)
+		if matches == nil {
+			return 0
+		}
+		chars, _ = strconv.Atoi(matches[1])
+	}
+	if strings.Contains(col.TypeInDB, "binary") || strings.Contains(col.TypeInDB, "blob") {
+		return chars
+	}
+	if bytesPerChar, ok := charSetMaxBytes[col.CharSet]; ok {
+		return chars * bytesPerChar
+	}
+	return chars
+}
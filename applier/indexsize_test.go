@@ -0,0 +1,148 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// keySizeTestTable returns a table with a secondary index on a single varchar
+// column of the supplied type and character set.
+func keySizeTestTable(typeInDB, charSet, createOptions string) *tengo.Table {
+	id := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned"}
+	name := &tengo.Column{Name: "name", TypeInDB: typeInDB, CharSet: charSet}
+	return &tengo.Table{
+		Name:             "users",
+		Engine:           "InnoDB",
+		CreateOptions:    createOptions,
+		Columns:          []*tengo.Column{id, name},
+		PrimaryKey:       &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{{Name: "name", Columns: []*tengo.Column{name, id}, SubParts: []uint16{0, 0}}},
+	}
+}
+
+func TestIndexesWithChangedColumns(t *testing.T) {
+	from := keySizeTestTable("varchar(191)", "utf8mb4", "")
+	to := keySizeTestTable("varchar(255)", "utf8mb4", "")
+	if indexes := indexesWithChangedColumns(from, to); len(indexes) != 1 || indexes[0].Name != "name" {
+		t.Errorf("Expected only index name to be returned, instead found %+v", indexes)
+	}
+	if indexes := indexesWithChangedColumns(from, from); len(indexes) != 0 {
+		t.Errorf("Expected no indexes to be returned for unchanged table, instead found %+v", indexes)
+	}
+	to = keySizeTestTable("varchar(191)", "utf8", "")
+	if indexes := indexesWithChangedColumns(from, to); len(indexes) != 1 {
+		t.Errorf("Expected character set change to be detected, instead found %+v", indexes)
+	}
+}
+
+func TestIndexPrefixLimit(t *testing.T) {
+	cases := []struct {
+		createOptions    string
+		largePrefix      bool
+		defaultRowFormat string
+		expected         int
+	}{
+		{"", true, "dynamic", 3072},
+		{"", false, "dynamic", 767},
+		{"", true, "compact", 767},
+		{"row_format=COMPRESSED", true, "compact", 3072},
+		{"row_format=REDUNDANT", true, "dynamic", 767},
+		{"stats_persistent=1 row_format=DYNAMIC", true, "compact", 3072},
+	}
+	for _, c := range cases {
+		table := keySizeTestTable("varchar(10)", "utf8mb4", c.createOptions)
+		if actual := indexPrefixLimit(table, c.largePrefix, c.defaultRowFormat); actual != c.expected {
+			t.Errorf("Expected indexPrefixLimit(%q, %t, %q) to return %d, instead found %d", c.createOptions, c.largePrefix, c.defaultRowFormat, c.expected, actual)
+		}
+	}
+}
+
+func TestIndexKeySize(t *testing.T) {
+	cases := []struct {
+		typeInDB      string
+		charSet       string
+		subPart       uint16
+		prefixLimit   int
+		expectedSize  int
+		expectedLimit int
+	}{
+		{"varchar(191)", "utf8mb4", 0, 767, 764, 3072},
+		{"varchar(255)", "utf8mb4", 0, 767, 1020, 767},
+		{"varchar(255)", "utf8mb4", 0, 3072, 1020, 3072},
+		{"varchar(255)", "latin1", 0, 767, 255, 3072},
+		{"char(255)", "utf8", 0, 767, 765, 3072},
+		{"varbinary(1000)", "", 0, 767, 1000, 767},
+		{"text", "utf8mb4", 200, 767, 800, 767},
+		{"varchar(1000)", "utf8mb4", 100, 767, 400, 3072},
+		{"varchar(1000)", "utf8mb4", 800, 3072, 3200, 3072},
+	}
+	for _, c := range cases {
+		table := keySizeTestTable(c.typeInDB, c.charSet, "")
+		idx := table.SecondaryIndexes[0]
+		idx.SubParts[0] = c.subPart
+		if size, limit := indexKeySize(idx, c.prefixLimit); size != c.expectedSize || limit != c.expectedLimit {
+			t.Errorf("Expected key size of %s %s (subpart %d) to be %d with limit %d; instead found %d with limit %d", c.typeInDB, c.charSet, c.subPart, c.expectedSize, c.expectedLimit, size, limit)
+		}
+	}
+}
+
+func TestIndexKeySizeNoteNoQuery(t *testing.T) {
+	// Since no indexed column changes, and a MyISAM table is never checked, the
+	// supplied nil instance must never be used
+	from := keySizeTestTable("varchar(191)", "utf8mb4", "")
+	to := keySizeTestTable("varchar(191)", "utf8mb4", "")
+	to.Comment = "changed"
+	td := &tengo.TableDiff{Type: tengo.DiffTypeAlter, From: from, To: to}
+	if note, err := indexKeySizeNote(td, nil); note != "" || err != nil {
+		t.Errorf("Unexpected return from indexKeySizeNote: %q, %v", note, err)
+	}
+	to = keySizeTestTable("varchar(255)", "utf8mb4", "")
+	to.Engine = "MyISAM"
+	td = &tengo.TableDiff{Type: tengo.DiffTypeAlter, From: from, To: to}
+	if note, err := indexKeySizeNote(td, nil); note != "" || err != nil {
+		t.Errorf("Unexpected return from indexKeySizeNote: %q, %v", note, err)
+	}
+}
+
+func (s ApplierIntegrationSuite) TestNewDDLStatementIndexKeySize(t *testing.T) {
+	// COMPACT row format always has a 767 byte prefix limit, regardless of
+	// server settings
+	from := keySizeTestTable("varchar(191)", "utf8mb4", "ROW_FORMAT=COMPACT")
+	to := keySizeTestTable("varchar(255)", "utf8mb4", "ROW_FORMAT=COMPACT")
+	newDDL := func(flags string) (*DDLStatement, error) {
+		t.Helper()
+		target := &Target{
+			Instance:   s.d[0].Instance,
+			Dir:        getDir(t, "testdata/simple/one", "--dry-run "+flags),
+			SchemaName: "product",
+		}
+		mods := tengo.StatementModifiers{
+			AllowUnsafe: target.Dir.Config.GetBool("allow-unsafe"),
+			Flavor:      s.d[0].Flavor(),
+		}
+		return NewDDLStatement(tengo.NewAlterTable(from, to), mods, target)
+	}
+
+	// allow-unsafe does not permit oversized keys
+	for _, flags := range []string{"", "--allow-unsafe", "--safe-below-size=1G"} {
+		_, err := newDDL(flags)
+		if use, ok := err.(unsafeStatementError); !ok || len(use.codes) != 1 || use.codes[0] != UnsafeIndexKeySize || !strings.Contains(err.Error(), "--allow-oversized-keys") {
+			t.Errorf("Unexpected error from NewDDLStatement with flags %q: %v", flags, err)
+		}
+	}
+
+	ddl, err := newDDL("--allow-oversized-keys")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDDLStatement with allow-oversized-keys: %v", err)
+	}
+	if !ddl.unsafe || len(ddl.unsafeCodes) != 1 || ddl.unsafeCodes[0] != UnsafeIndexKeySize || !strings.Contains(ddl.note, "exceeding limit of 767 bytes") {
+		t.Errorf("Unexpected DDLStatement: unsafe=%t codes=%v note=%q", ddl.unsafe, ddl.unsafeCodes, ddl.note)
+	}
+
+	// Brief mode permits it, so that the target is listed as having differences
+	if _, err := newDDL("--brief"); err != nil {
+		t.Errorf("Unexpected error from NewDDLStatement with brief: %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package applier
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// journalEntry records a replacement of an object which requires multiple
+// non-atomic statements, such as the DROP and re-CREATE of a routine. It is
+// written to the state file before the first statement is run, and cleared
+// once the replacement has been verified, so that a push interrupted in
+// between can be recognized by the next run.
+type journalEntry struct {
+	Type          string `json:"type"`
+	Name          string `json:"name"`
+	OldDefinition string `json:"old_definition"`
+	NewDefinition string `json:"new_definition"`
+	StartedAt     string `json:"started_at"`
+}
+
+func (je journalEntry) key() tengo.ObjectKey {
+	return tengo.ObjectKey{Type: tengo.ObjectType(je.Type), Name: je.Name}
+}
+
+// journalStatus describes the live state of an object with a journal entry.
+type journalStatus int
+
+const (
+	journalCompleted   journalStatus = iota // object has its new definition
+	journalNotStarted                       // object still has its old definition
+	journalInterrupted                      // object is missing
+	journalDiverged                         // object has some other definition
+)
+
+// status compares the entry to the object's definition in schema, which
+// should be introspected from the live database.
+func (je journalEntry) status(schema *tengo.Schema) journalStatus {
+	def, exists := routineDefinition(schema, je.key())
+	switch {
+	case !exists:
+		return journalInterrupted
+	case def == je.NewDefinition:
+		return journalCompleted
+	case def == je.OldDefinition:
+		return journalNotStarted
+	default:
+		return journalDiverged
+	}
+}
+
+// routineDefinition returns the CREATE statement of the routine in schema with
+// the supplied key. The second return value is false if no such routine
+// exists, including if schema is nil.
+func routineDefinition(schema *tengo.Schema, key tengo.ObjectKey) (string, bool) {
+	if schema == nil {
+		return "", false
+	}
+	for _, r := range schema.Routines {
+		if r.Type == key.Type && r.Name == key.Name {
+			return r.CreateStatement, true
+		}
+	}
+	return "", false
+}
+
+// replacement tracks the pair of statements which replace one object.
+type replacement struct {
+	drop   int // index of the DROP in the slice of DDLStatements
+	create int // index of the subsequent CREATE
+	entry  journalEntry
+}
+
+// replacements finds the routines which ddls replace by dropping and then
+// re-creating them. The result is keyed by the index of both the DROP and the
+// CREATE of each replacement.
+func replacements(ddls []*DDLStatement) map[int]*replacement {
+	result := make(map[int]*replacement)
+	pending := make(map[tengo.ObjectKey]*replacement)
+	for n, ddl := range ddls {
+		rd, ok := ddl.diff.(*tengo.RoutineDiff)
+		if !ok {
+			continue
+		}
+		key := rd.ObjectKey()
+		switch rd.DiffType() {
+		case tengo.DiffTypeDrop:
+			pending[key] = &replacement{
+				drop: n,
+				entry: journalEntry{
+					Type:          string(key.Type),
+					Name:          key.Name,
+					OldDefinition: rd.From.CreateStatement,
+				},
+			}
+		case tengo.DiffTypeCreate:
+			if r := pending[key]; r != nil {
+				r.create = n
+				r.entry.NewDefinition = rd.To.CreateStatement
+				result[r.drop], result[n] = r, r
+				delete(pending, key)
+			}
+		}
+	}
+	return result
+}
+
+// readJournal returns the journal entries recorded for t in its dir's state
+// file. If no state file is configured, nothing is returned.
+func (t *Target) readJournal() ([]journalEntry, error) {
+	filePath := syncStatePath(t.Dir)
+	if filePath == "" {
+		return nil, nil
+	}
+	syncStateMutex.Lock()
+	defer syncStateMutex.Unlock()
+	sf, err := readSyncStateFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return sf.Journal[syncStateKey(t.Instance, t.SchemaName)], nil
+}
+
+// writeJournalEntry records entry for t in its dir's state file, replacing any
+// existing entry for the same object. This is a no-op if no state file is
+// configured.
+func (t *Target) writeJournalEntry(entry journalEntry) error {
+	entry.StartedAt = time.Now().UTC().Format(time.RFC3339)
+	return t.updateJournal(func(entries []journalEntry) []journalEntry {
+		return append(withoutJournalEntry(entries, entry.key()), entry)
+	})
+}
+
+// clearJournalEntry removes any entry for the object with the supplied key
+// from t's journal.
+func (t *Target) clearJournalEntry(key tengo.ObjectKey) error {
+	return t.updateJournal(func(entries []journalEntry) []journalEntry {
+		return withoutJournalEntry(entries, key)
+	})
+}
+
+func (t *Target) updateJournal(update func([]journalEntry) []journalEntry) error {
+	filePath := syncStatePath(t.Dir)
+	if filePath == "" {
+		return nil
+	}
+	syncStateMutex.Lock()
+	defer syncStateMutex.Unlock()
+	sf, err := readSyncStateFile(filePath)
+	if err != nil {
+		return err
+	}
+	stateKey := syncStateKey(t.Instance, t.SchemaName)
+	if entries := update(sf.Journal[stateKey]); len(entries) > 0 {
+		if sf.Journal == nil {
+			sf.Journal = make(map[string][]journalEntry)
+		}
+		sf.Journal[stateKey] = entries
+	} else if _, ok := sf.Journal[stateKey]; ok {
+		delete(sf.Journal, stateKey)
+	} else {
+		return nil // nothing to write
+	}
+	contents, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return err
+	}
+	t.Dir.Snapshot.Forget(filePath)
+	return fs.WriteFile(filePath, append(contents, '\n'))
+}
+
+func withoutJournalEntry(entries []journalEntry, key tengo.ObjectKey) []journalEntry {
+	result := make([]journalEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.key() != key {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// recoverJournal reports on any replacements left over from an interrupted
+// push of t, based on the live schema and the desired schema. Entries are
+// cleared if the live object no longer needs attention; otherwise the object
+// is missing, and the normal diff of t either completes the replacement or
+// rolls it back by re-creating the object from its filesystem definition, and
+// the entry is cleared by verifyJournal afterwards. When only diff'ing, entries
+// are reported but never cleared.
+func (t *Target) recoverJournal(schemaFromInstance, schemaFromDir *tengo.Schema) {
+	entries, err := t.readJournal()
+	if err != nil {
+		t.logger().Warnf("%s %s: unable to read journal from state file: %s", t.Instance, t.SchemaName, err)
+		return
+	}
+	for _, entry := range entries {
+		key := entry.key()
+		resolved := true
+		switch entry.status(schemaFromInstance) {
+		case journalCompleted:
+			t.logger().Infof("%s %s: interrupted replacement of %s had already completed", t.Instance, t.SchemaName, key)
+		case journalNotStarted:
+			t.logger().Infof("%s %s: interrupted replacement of %s had not dropped the original, which is unchanged", t.Instance, t.SchemaName, key)
+		case journalDiverged:
+			t.logger().Warnf("%s %s: %s was modified by something else after a replacement of it was interrupted; it will be diffed normally", t.Instance, t.SchemaName, key)
+		case journalInterrupted:
+			desired, inDir := routineDefinition(schemaFromDir, key)
+			switch {
+			case !inDir:
+				t.logger().Warnf("%s %s: %s was dropped by an interrupted replacement, and is no longer in the filesystem, so it remains dropped", t.Instance, t.SchemaName, key)
+			case desired == entry.NewDefinition:
+				resolved = false
+				t.logger().Warnf("%s %s: %s is missing due to an interrupted replacement; it will be completed by re-creating it with its new definition", t.Instance, t.SchemaName, key)
+			case desired == entry.OldDefinition:
+				resolved = false
+				t.logger().Warnf("%s %s: %s is missing due to an interrupted replacement; it will be rolled back by re-creating it with its previous definition", t.Instance, t.SchemaName, key)
+			default:
+				resolved = false
+				t.logger().Warnf("%s %s: %s is missing due to an interrupted replacement; it will be re-created using its current filesystem definition", t.Instance, t.SchemaName, key)
+			}
+		}
+		if resolved && !t.dryRun() {
+			if err := t.clearJournalEntry(key); err != nil {
+				t.logger().Warnf("%s %s: unable to clear journal entry for %s from state file: %s", t.Instance, t.SchemaName, key, err)
+			}
+		}
+	}
+}
+
+// verifyJournal re-introspects t after its statements have been executed, and
+// clears any journal entries whose object now matches the desired schema. Any
+// other entries are retained, so that the next run can recover them.
+func (t *Target) verifyJournal(schemaFromDir *tengo.Schema) {
+	entries, err := t.readJournal()
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	if t.schemaCache != nil {
+		t.schemaCache.Invalidate(t.Instance, t.SchemaName)
+	}
+	live, err := t.SchemaFromInstance()
+	if err != nil {
+		t.logger().Warnf("%s %s: unable to verify %s in state file journal: %s", t.Instance, t.SchemaName, countAndNoun(len(entries), "replacement"), err)
+		return
+	}
+	for _, entry := range entries {
+		key := entry.key()
+		liveDef, exists := routineDefinition(live, key)
+		desiredDef, inDir := routineDefinition(schemaFromDir, key)
+		if exists != inDir || liveDef != desiredDef {
+			t.logger().Warnf("%s %s: unable to verify replacement of %s; it remains in the state file journal", t.Instance, t.SchemaName, key)
+			continue
+		}
+		if err := t.clearJournalEntry(key); err != nil {
+			t.logger().Warnf("%s %s: unable to clear journal entry for %s from state file: %s", t.Instance, t.SchemaName, key, err)
+		}
+	}
+}
+
+// restoreReplaced attempts to re-create the previous definition of a routine
+// after the CREATE of its replacement, ddl, failed. The original was already
+// dropped by this point. If the previous definition is restored, the journal
+// entry is cleared; otherwise it is retained for the next run. Replacements
+// using a shell-out are not restored automatically.
+func (t *Target) restoreReplaced(ddl *DDLStatement, entry journalEntry) {
+	key := entry.key()
+	if ddl.IsShellOut() {
+		t.logger().Errorf("%s %s: %s was dropped, but could not be re-created; it remains in the state file journal", t.Instance, t.SchemaName, key)
+		return
+	}
+	restore := *ddl
+	restore.stmt = entry.OldDefinition
+	restore.execShellOut = nil
+	err := restore.Execute()
+	if t.schemaCache != nil {
+		t.schemaCache.Invalidate(t.Instance, t.SchemaName)
+	}
+	if err != nil {
+		t.logger().Errorf("%s %s: %s was dropped, but could not be re-created, and restoring its previous definition also failed: %s", t.Instance, t.SchemaName, key, err)
+		return
+	}
+	t.logger().Warnf("%s %s: restored previous definition of %s after failing to re-create it", t.Instance, t.SchemaName, key)
+	if err := t.clearJournalEntry(key); err != nil {
+		t.logger().Warnf("%s %s: unable to clear journal entry for %s from state file: %s", t.Instance, t.SchemaName, key, err)
+	}
+}
@@ -0,0 +1,148 @@
+package applier
+
+import (
+	"os"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// journalTestRoutine returns a copy of fingerprintTestRoutine with the
+// supplied body.
+func journalTestRoutine(body string) *tengo.Routine {
+	r := fingerprintTestRoutine("STRICT_TRANS_TABLES")
+	r.Body = body
+	r.CreateStatement = r.Definition(tengo.FlavorMySQL57)
+	return r
+}
+
+func TestReplacements(t *testing.T) {
+	oldFunc, newFunc := journalTestRoutine("RETURN 42"), journalTestRoutine("RETURN 43")
+	droppedOnly := journalTestRoutine("RETURN 1")
+	droppedOnly.Name = "dropped"
+	ddls := []*DDLStatement{
+		{diff: &tengo.RoutineDiff{From: droppedOnly}},
+		{diff: &tengo.RoutineDiff{From: oldFunc}},
+		{diff: tengo.NewCreateTable(brokenFKTestTable("users"))},
+		{diff: &tengo.RoutineDiff{To: newFunc}},
+		{migration: "migrations/001.sql"},
+	}
+	reps := replacements(ddls)
+	if len(reps) != 2 || reps[1] == nil || reps[1] != reps[3] {
+		t.Fatalf("Unexpected result from replacements: %+v", reps)
+	}
+	r := reps[1]
+	if r.drop != 1 || r.create != 3 || r.entry.key() != (tengo.ObjectKey{Type: newFunc.Type, Name: newFunc.Name}) {
+		t.Errorf("Unexpected replacement: %+v", *r)
+	}
+	if r.entry.OldDefinition != oldFunc.CreateStatement || r.entry.NewDefinition != newFunc.CreateStatement {
+		t.Errorf("Unexpected definitions in journal entry: %+v", r.entry)
+	}
+}
+
+func TestJournalEntryStatus(t *testing.T) {
+	oldFunc, newFunc := journalTestRoutine("RETURN 42"), journalTestRoutine("RETURN 43")
+	entry := journalEntry{
+		Type:          string(oldFunc.Type),
+		Name:          oldFunc.Name,
+		OldDefinition: oldFunc.CreateStatement,
+		NewDefinition: newFunc.CreateStatement,
+	}
+	newSchema := func(routines ...*tengo.Routine) *tengo.Schema {
+		return &tengo.Schema{Name: "product", Routines: routines}
+	}
+	otherType := journalTestRoutine("RETURN 43")
+	otherType.Type = tengo.ObjectTypeProc
+	cases := map[journalStatus]*tengo.Schema{
+		journalCompleted:   newSchema(newFunc),
+		journalNotStarted:  newSchema(oldFunc),
+		journalInterrupted: newSchema(otherType),
+		journalDiverged:    newSchema(journalTestRoutine("RETURN 44")),
+	}
+	for expected, schema := range cases {
+		if actual := entry.status(schema); actual != expected {
+			t.Errorf("Expected status %d, instead found %d", expected, actual)
+		}
+	}
+	if actual := entry.status(nil); actual != journalInterrupted {
+		t.Errorf("Expected status %d for nil schema, instead found %d", journalInterrupted, actual)
+	}
+}
+
+func TestJournalRecovery(t *testing.T) {
+	if err := os.MkdirAll("testdata/.scratch", 0777); err != nil {
+		t.Fatalf("Unable to create scratch dir: %s", err)
+	}
+	defer os.RemoveAll("testdata/.scratch")
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{
+		Instance:   inst,
+		Dir:        getDir(t, "testdata/simple/one", "--state-file=../../.scratch/state.json"),
+		SchemaName: "product",
+	}
+	newEntry := func(name string) journalEntry {
+		oldFunc, newFunc := journalTestRoutine("RETURN 42"), journalTestRoutine("RETURN 43")
+		return journalEntry{
+			Type:          string(tengo.ObjectTypeFunc),
+			Name:          name,
+			OldDefinition: oldFunc.CreateStatement,
+			NewDefinition: newFunc.CreateStatement,
+		}
+	}
+
+	// Entries survive recording a sync, and are tracked separately per schema
+	for _, name := range []string{"completed", "notstarted", "missing", "gone"} {
+		if err := target.writeJournalEntry(newEntry(name)); err != nil {
+			t.Fatalf("Unexpected error from writeJournalEntry: %s", err)
+		}
+	}
+	if err := recordSync(target.Dir, target.Instance, target.SchemaName, &tengo.Schema{Name: "product"}, "push"); err != nil {
+		t.Fatalf("Unexpected error from recordSync: %s", err)
+	}
+	if entries, err := target.readJournal(); err != nil || len(entries) != 4 || entries[0].StartedAt == "" {
+		t.Fatalf("Unexpected result from readJournal: %+v, %v", entries, err)
+	}
+	other := *target
+	other.SchemaName = "product_2"
+	if entries, err := other.readJournal(); err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries for other schema, instead found %+v, %v", entries, err)
+	}
+
+	// Recovery clears entries which no longer need attention, but retains ones
+	// for missing objects which the push should re-create
+	withName := func(name, body string) *tengo.Routine {
+		r := journalTestRoutine(body)
+		r.Name = name
+		r.CreateStatement = newEntry(name).OldDefinition
+		if body == "RETURN 43" {
+			r.CreateStatement = newEntry(name).NewDefinition
+		}
+		return r
+	}
+	live := &tengo.Schema{Name: "product", Routines: []*tengo.Routine{
+		withName("completed", "RETURN 43"),
+		withName("notstarted", "RETURN 42"),
+	}}
+	desired := &tengo.Schema{Name: "product", Routines: []*tengo.Routine{
+		withName("completed", "RETURN 43"),
+		withName("notstarted", "RETURN 43"),
+		withName("missing", "RETURN 43"),
+	}}
+	target.recoverJournal(live, desired)
+	entries, err := target.readJournal()
+	if err != nil || len(entries) != 1 || entries[0].Name != "missing" {
+		t.Errorf("Unexpected journal after recovery: %+v, %v", entries, err)
+	}
+
+	// Clearing the last entry removes the schema from the journal entirely
+	if err := target.clearJournalEntry(newEntry("missing").key()); err != nil {
+		t.Fatalf("Unexpected error from clearJournalEntry: %s", err)
+	}
+	sf, err := readSyncStateFile(syncStatePath(target.Dir))
+	if err != nil || len(sf.Journal) != 0 || len(sf.Targets) != 1 {
+		t.Errorf("Unexpected state file after clearing journal: %+v, %v", sf, err)
+	}
+}
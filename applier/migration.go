@@ -0,0 +1,211 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// absentFingerprint is used in manual migration headers to indicate a table
+// which does not exist.
+const absentFingerprint = "-"
+
+// tableFingerprints returns a map of table names to fingerprints for all
+// tables in schema. A nil schema results in an empty map.
+func tableFingerprints(schema *tengo.Schema) map[string]string {
+	result := make(map[string]string)
+	for key, fp := range ObjectFingerprints(schema) {
+		if key.Type == tengo.ObjectTypeTable {
+			result[key.Name] = fp
+		}
+	}
+	return result
+}
+
+// matchesState returns true if every table in expected has the corresponding
+// fingerprint in actual, or is absent from actual if its expected fingerprint
+// is absentFingerprint.
+func matchesState(expected, actual map[string]string) bool {
+	for name, fp := range expected {
+		if actualFP, ok := actual[name]; fp == absentFingerprint && ok {
+			return false
+		} else if fp != absentFingerprint && actualFP != fp {
+			return false
+		}
+	}
+	return true
+}
+
+// applicableMigrations returns the manual migrations of t's dir which need to
+// be run to transition from to to. Migrations are evaluated in order, with
+// each one's declared after state feeding into the next; a migration whose
+// after state already matches is considered previously applied. An error is
+// returned if a migration is stale: its before state does not match, or the
+// tables it declares would not end up matching the desired definitions.
+func (t *Target) applicableMigrations(from, to *tengo.Schema) ([]*fs.ManualMigration, error) {
+	migrations, err := t.Dir.ManualMigrations()
+	if err != nil || len(migrations) == 0 {
+		return nil, err
+	}
+	live, desired := tableFingerprints(from), tableFingerprints(to)
+	state := make(map[string]string, len(live))
+	for name, fp := range live {
+		state[name] = fp
+	}
+	var applicable []*fs.ManualMigration
+	for _, mm := range migrations {
+		if matchesState(mm.After, state) {
+			t.logger().Debugf("%s %s: manual migration %s already applied", t.Instance, t.SchemaName, mm)
+			continue
+		} else if !matchesState(mm.Before, state) {
+			return nil, staleMigrationError(mm, live, desired)
+		}
+		for name, fp := range mm.After {
+			if fp == absentFingerprint {
+				delete(state, name)
+			} else {
+				state[name] = fp
+			}
+		}
+		applicable = append(applicable, mm)
+	}
+	for _, mm := range applicable {
+		for _, name := range mm.Tables() {
+			if state[name] != desired[name] {
+				return nil, staleMigrationError(mm, live, desired)
+			}
+		}
+	}
+	return applicable, nil
+}
+
+// staleMigrationError returns an error explaining that mm's header does not
+// match the live and desired definitions of its tables. The error includes
+// header lines reflecting the current definitions, to aid in updating mm.
+func staleMigrationError(mm *fs.ManualMigration, live, desired map[string]string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Manual migration %s is stale: its header does not match the current or desired definitions of the tables it declares. Header lines reflecting the current definitions are:", mm)
+	for _, name := range mm.Tables() {
+		fmt.Fprintf(&b, "\n-- skeema:before %s %s", tengo.EscapeIdentifier(name), fingerprintOrAbsent(live, name))
+	}
+	for _, name := range mm.Tables() {
+		fmt.Fprintf(&b, "\n-- skeema:after %s %s", tengo.EscapeIdentifier(name), fingerprintOrAbsent(desired, name))
+	}
+	return fmt.Errorf("%s", b.String())
+}
+
+func fingerprintOrAbsent(fingerprints map[string]string, name string) string {
+	if fp, ok := fingerprints[name]; ok {
+		return fp
+	}
+	return absentFingerprint
+}
+
+// supersededBy removes any table diffs from objDiffs which affect a table
+// declared by one of migrations. The remaining diffs are returned, along with
+// the object keys of the removed ones.
+func supersededBy(objDiffs []tengo.ObjectDiff, migrations []*fs.ManualMigration) (remaining []tengo.ObjectDiff, superseded []tengo.ObjectKey) {
+	tables := make(map[string]bool)
+	for _, mm := range migrations {
+		for _, name := range mm.Tables() {
+			tables[name] = true
+		}
+	}
+	remaining = make([]tengo.ObjectDiff, 0, len(objDiffs))
+	for _, objDiff := range objDiffs {
+		if key := objDiff.ObjectKey(); key.Type == tengo.ObjectTypeTable && tables[key.Name] {
+			superseded = append(superseded, key)
+		} else {
+			remaining = append(remaining, objDiff)
+		}
+	}
+	return remaining, superseded
+}
+
+// migrationDDL returns DDLStatements for running the statements of mm against
+// t. The first statement is annotated with a note naming the file and the
+// tables whose generated DDL it supersedes. Manual migrations are never run
+// via alter-wrapper or ddl-wrapper, since they may contain arbitrary SQL.
+func migrationDDL(mm *fs.ManualMigration, t *Target) ([]*DDLStatement, error) {
+	instance := t.Instance
+	if ddlInstance, err := t.Dir.DDLInstance(t.Instance); err == nil {
+		instance = ddlInstance
+	} else if !t.dryRun() {
+		return nil, ConfigError(err.Error())
+	}
+	comment, err := t.statementComment()
+	if err != nil {
+		return nil, ConfigError(err.Error())
+	}
+	names := mm.Tables()
+	for n := range names {
+		names[n] = tengo.EscapeIdentifier(names[n])
+	}
+	ddls := make([]*DDLStatement, len(mm.Statements))
+	for n, stmt := range mm.Statements {
+		ddls[n] = &DDLStatement{
+			stmt:          stmt.Body(),
+			comment:       comment,
+			instance:      instance,
+			schemaName:    t.SchemaName,
+			connectParams: "readTimeout=0",
+			target:        t,
+			migration:     mm.RelPath,
+		}
+	}
+	ddls[0].note = fmt.Sprintf("Manual migration %s, superseding generated DDL for %s", mm, strings.Join(names, ", "))
+	return ddls, nil
+}
+
+// passthroughDDL returns DDLStatements for running the statements of the
+// passthrough files of t's dir, in file name order, except that statements
+// creating or dropping views are reordered as needed to satisfy references
+// between views. Like manual migrations, passthrough files are never run via
+// alter-wrapper or ddl-wrapper.
+func passthroughDDL(t *Target) ([]*DDLStatement, error) {
+	if len(t.Dir.PassthroughFiles) == 0 {
+		return nil, nil
+	}
+	instance := t.Instance
+	if ddlInstance, err := t.Dir.DDLInstance(t.Instance); err == nil {
+		instance = ddlInstance
+	} else if !t.dryRun() {
+		return nil, ConfigError(err.Error())
+	}
+	comment, err := t.statementComment()
+	if err != nil {
+		return nil, ConfigError(err.Error())
+	}
+	var ddls []*DDLStatement
+	var bodies []string
+	for _, sf := range t.Dir.PassthroughFiles {
+		statements, err := fs.PassthroughStatements(sf)
+		if err != nil {
+			return nil, err
+		}
+		for _, stmt := range statements {
+			bodies = append(bodies, stmt.Body())
+			ddls = append(ddls, &DDLStatement{
+				stmt:          stmt.Body(),
+				comment:       comment,
+				instance:      instance,
+				schemaName:    t.SchemaName,
+				connectParams: "readTimeout=0",
+				target:        t,
+				migration:     sf.FileName,
+				passthrough:   true,
+			})
+		}
+	}
+	order, err := orderViewStatements(bodies)
+	if err != nil {
+		return nil, err
+	}
+	ordered := make([]*DDLStatement, len(ddls))
+	for n, pos := range order {
+		ordered[n] = ddls[pos]
+	}
+	return ordered, nil
+}
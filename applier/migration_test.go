@@ -0,0 +1,117 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func TestApplicableMigrations(t *testing.T) {
+	makeTable := func(name string, columns ...string) *tengo.Table {
+		create := fmt.Sprintf("CREATE TABLE %s (\n  `id` int(11) NOT NULL,\n%s  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", tengo.EscapeIdentifier(name), strings.Join(columns, ""))
+		return &tengo.Table{Name: name, CreateStatement: create}
+	}
+	makeSchema := func(tables ...*tengo.Table) *tengo.Schema {
+		return &tengo.Schema{Name: "product", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", Tables: tables}
+	}
+	fp := func(table *tengo.Table) string {
+		return tableFingerprints(makeSchema(table))[table.Name]
+	}
+	emailCol := "  `email` varchar(100) NOT NULL,\n"
+	usersBefore, usersAfter := makeTable("users", emailCol), makeTable("users")
+	contactsBefore, contactsAfter := makeTable("contacts"), makeTable("contacts", emailCol)
+	from := makeSchema(usersBefore, contactsBefore)
+	to := makeSchema(usersAfter, contactsAfter)
+
+	dirPath := "testdata/.scratch/migrations"
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	fs.WriteTestFile(t, dirPath+"/.skeema", "schema=product\nmanual-migrations=migrations/0001_move_email.sql\n")
+	header := fmt.Sprintf("-- skeema:before users %s\n-- skeema:before contacts %s\n-- skeema:after users %s\n-- skeema:after `contacts` %s\n", fp(usersBefore), fp(contactsBefore), fp(usersAfter), fp(contactsAfter))
+	body := "ALTER TABLE contacts ADD COLUMN email varchar(100) NOT NULL;\nUPDATE contacts c JOIN users u ON c.id = u.id SET c.email = u.email;\nALTER TABLE users DROP COLUMN email;\n"
+	fs.WriteTestFile(t, dirPath+"/migrations/0001_move_email.sql", header+body)
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{Instance: inst, Dir: getDir(t, dirPath, "--dry-run"), SchemaName: "product"}
+
+	migrations, err := target.applicableMigrations(from, to)
+	if err != nil {
+		t.Fatalf("Unexpected error from applicableMigrations: %s", err)
+	} else if len(migrations) != 1 || len(migrations[0].Statements) != 3 {
+		t.Fatalf("Unexpected result from applicableMigrations: %+v", migrations)
+	}
+
+	// Generated DDL for the declared tables is superseded, but other diffs remain
+	other := makeTable("other")
+	objDiffs := []tengo.ObjectDiff{
+		&tengo.TableDiff{Type: tengo.DiffTypeAlter, From: usersBefore, To: usersAfter},
+		tengo.NewCreateTable(other),
+		&tengo.TableDiff{Type: tengo.DiffTypeAlter, From: contactsBefore, To: contactsAfter},
+	}
+	remaining, superseded := supersededBy(objDiffs, migrations)
+	if len(remaining) != 1 || remaining[0].ObjectKey().Name != "other" || len(superseded) != 2 {
+		t.Errorf("Unexpected result from supersededBy: %v, %v", remaining, superseded)
+	}
+	ddls, err := migrationDDL(migrations[0], target)
+	if err != nil {
+		t.Fatalf("Unexpected error from migrationDDL: %s", err)
+	}
+	if len(ddls) != 3 || ddls[2].stmt != "ALTER TABLE users DROP COLUMN email" || ddls[1].migration != "migrations/0001_move_email.sql" {
+		t.Errorf("Unexpected result from migrationDDL: %+v", ddls)
+	}
+	if !strings.Contains(ddls[0].note, "`contacts`, `users`") {
+		t.Errorf("Unexpected note on first statement: %q", ddls[0].note)
+	}
+	summary := formatSummary(dirPath, "product", ddls, false)
+	if strings.Count(summary, "[manual] file migrations/0001_move_email.sql") != 1 {
+		t.Errorf("Expected summary to list manual migration once, instead found:\n%s", summary)
+	}
+
+	// Once applied, the migration is no longer applicable
+	if migrations, err := target.applicableMigrations(to, to); err != nil || len(migrations) != 0 {
+		t.Errorf("Expected no applicable migrations once applied, instead found %+v, %v", migrations, err)
+	}
+
+	// If the desired or live state differs from the header, the migration is
+	// stale, and the error includes updated header lines
+	usersChanged := makeTable("users", "  `name` varchar(50) NOT NULL,\n")
+	if _, err := target.applicableMigrations(from, makeSchema(usersChanged, contactsAfter)); err == nil {
+		t.Error("Expected error from stale migration, but err was nil")
+	} else if !strings.Contains(err.Error(), "-- skeema:after `users` "+fp(usersChanged)) {
+		t.Errorf("Expected error to include updated header lines, instead found: %s", err)
+	}
+	if _, err := target.applicableMigrations(makeSchema(usersChanged, contactsBefore), to); err == nil {
+		t.Error("Expected error from stale migration, but err was nil")
+	}
+
+	// A table which does not exist is declared with a fingerprint of -
+	header = fmt.Sprintf("-- skeema:before contacts -\n-- skeema:after contacts %s\n", fp(contactsAfter))
+	fs.WriteTestFile(t, dirPath+"/migrations/0001_move_email.sql", header+"CREATE TABLE contacts (id int);\n")
+	if migrations, err := target.applicableMigrations(makeSchema(usersAfter), to); err != nil || len(migrations) != 1 {
+		t.Errorf("Unexpected result from applicableMigrations: %+v, %v", migrations, err)
+	}
+}
+
+func TestMatchesState(t *testing.T) {
+	actual := map[string]string{"users": "abc", "contacts": "def"}
+	cases := []struct {
+		expected map[string]string
+		matches  bool
+	}{
+		{map[string]string{"users": "abc"}, true},
+		{map[string]string{"users": "abc", "contacts": "def"}, true},
+		{map[string]string{"users": "abc", "contacts": "xyz"}, false},
+		{map[string]string{"widgets": "-"}, true},
+		{map[string]string{"users": "-"}, false},
+		{map[string]string{"widgets": "abc"}, false},
+	}
+	for _, c := range cases {
+		if result := matchesState(c.expected, actual); result != c.matches {
+			t.Errorf("Expected matchesState(%v) to return %t, instead found %t", c.expected, c.matches, result)
+		}
+	}
+}
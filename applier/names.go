@@ -0,0 +1,217 @@
+package applier
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// NameAudit is the result of AuditNames: a name-level reconciliation of the
+// tables in a live schema against the tables defined in the filesystem, without
+// comparing their definitions.
+type NameAudit struct {
+	Dirs           []string `json:"dirs"`
+	Instance       string   `json:"instance"`
+	Schema         string   `json:"schema"`
+	SchemaExists   bool     `json:"schema_exists"`
+	Both           []string `json:"both"`
+	FilesystemOnly []string `json:"filesystem_only"`
+	ServerOnly     []string `json:"server_only"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// Consistent returns true if the audit found the same set of table names in
+// both the filesystem and the live schema, and no error occurred.
+func (na *NameAudit) Consistent() bool {
+	return na.Error == "" && len(na.FilesystemOnly) == 0 && len(na.ServerOnly) == 0
+}
+
+// AuditNames compares the names of tables in the live schema of targets, which
+// must all have the same Instance and SchemaName, against the names of tables
+// defined by the *.sql files of each target's dir. The targets must have been
+// obtained from NameTargetsForDir. Multiple targets are only
+// supplied if several dirs share the same schema, in which case their tables
+// are combined. Tables matching the ignore-table option, as well as the
+// tracking table, are excluded on both sides. No workspace is used, and only a
+// single query is run against the instance.
+func AuditNames(targets []*Target) *NameAudit {
+	t := targets[0]
+	audit := &NameAudit{
+		Instance:       t.Instance.String(),
+		Schema:         t.SchemaName,
+		Both:           []string{},
+		FilesystemOnly: []string{},
+		ServerOnly:     []string{},
+	}
+	ignored, err := t.ignoredTableNames()
+	if err != nil {
+		audit.Error = err.Error()
+		return audit
+	}
+	fsNames := make(map[string]bool)
+	for _, target := range targets {
+		audit.Dirs = append(audit.Dirs, target.Dir.RelPath())
+		for _, name := range target.filesystemTableNames() {
+			if !ignored(name) {
+				fsNames[name] = true
+			}
+		}
+	}
+	serverNames, exists, foldCase, err := t.serverTableNames()
+	if err != nil {
+		audit.Error = err.Error()
+		return audit
+	}
+	audit.SchemaExists = exists
+
+	// With lower_case_table_names, names are compared case-insensitively
+	fold := func(name string) string {
+		if foldCase {
+			return strings.ToLower(name)
+		}
+		return name
+	}
+	onServer := make(map[string]bool, len(serverNames))
+	for _, name := range serverNames {
+		onServer[fold(name)] = true
+	}
+	inFilesystem := make(map[string]bool, len(fsNames))
+	for name := range fsNames {
+		inFilesystem[fold(name)] = true
+		if onServer[fold(name)] {
+			audit.Both = append(audit.Both, name)
+		} else {
+			audit.FilesystemOnly = append(audit.FilesystemOnly, name)
+		}
+	}
+	for _, name := range serverNames {
+		if !inFilesystem[fold(name)] && !ignored(name) {
+			audit.ServerOnly = append(audit.ServerOnly, name)
+		}
+	}
+	sort.Strings(audit.Both)
+	sort.Strings(audit.FilesystemOnly)
+	sort.Strings(audit.ServerOnly)
+	return audit
+}
+
+// NameTargetsForDir returns a Target for each instance and schema that dir and
+// its subdirectories map to, including schemas named explicitly in *.sql
+// files, for use with AuditNames. Unlike TargetsForDir, no workspace is used,
+// so the targets' DesiredSchema is nil. A count of dirs or instances that
+// could not be evaluated is also returned.
+func NameTargetsForDir(dir *fs.Dir, maxDepth int) (targets []*Target, skipCount int) {
+	if dir.ParseError != nil {
+		log.Warnf("Skipping %s: %s", dir, dir.ParseError)
+		return nil, 1
+	}
+	if dir.HasSchema() && dir.Config.Changed("host") {
+		instances, err := dir.Instances()
+		if err != nil {
+			log.Warnf("Skipping %s: %s", dir, err)
+			skipCount++
+		}
+		for _, inst := range instances {
+			schemaNames, err := dir.SchemaNames(inst)
+			if err != nil {
+				log.Warnf("Skipping %s for %s: %s", inst, dir, err)
+				skipCount++
+				continue
+			}
+			bySchema := make(map[string]*Target)
+			addTarget := func(schemaName string, logicalSchema *fs.LogicalSchema) {
+				t := bySchema[schemaName]
+				if t == nil {
+					t = &Target{Instance: inst, Dir: dir, SchemaName: schemaName}
+					bySchema[schemaName] = t
+					targets = append(targets, t)
+				}
+				t.logicalSchemas = append(t.logicalSchemas, logicalSchema)
+			}
+			for _, logicalSchema := range dir.LogicalSchemas {
+				if logicalSchema.Name != "" {
+					addTarget(logicalSchema.Name, logicalSchema)
+					continue
+				}
+				for _, schemaName := range schemaNames {
+					addTarget(schemaName, logicalSchema)
+				}
+			}
+		}
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		log.Warnf("Cannot list subdirs of %s: %s", dir, err)
+		return targets, skipCount + 1
+	} else if len(subdirs) > 0 && maxDepth <= 0 {
+		log.Warnf("Not walking subdirs of %s: max depth reached", dir)
+		return targets, skipCount + len(subdirs)
+	}
+	for _, sub := range subdirs {
+		subTargets, subSkipCount := NameTargetsForDir(sub, maxDepth-1)
+		targets = append(targets, subTargets...)
+		skipCount += subSkipCount
+	}
+	return targets, skipCount
+}
+
+// filesystemTableNames returns the names of tables defined for t's schema in
+// the *.sql files of t's dir.
+func (t *Target) filesystemTableNames() (names []string) {
+	for _, logicalSchema := range t.logicalSchemas {
+		for key := range logicalSchema.Creates {
+			if key.Type == tengo.ObjectTypeTable {
+				names = append(names, key.Name)
+			}
+		}
+	}
+	return names
+}
+
+// ignoredTableNames returns a function indicating whether a table name is
+// excluded from name audits, due to the ignore-table option of t's dir, or due
+// to being the configured tracking table.
+func (t *Target) ignoredTableNames() (func(string) bool, error) {
+	re, err := t.Dir.Config.GetRegexp("ignore-table")
+	if err != nil {
+		return nil, err
+	}
+	trackingTableName := t.Dir.Config.Get("tracking-table")
+	return func(name string) bool {
+		return (trackingTableName != "" && name == trackingTableName) || (re != nil && re.MatchString(name))
+	}, nil
+}
+
+// serverTableNames returns the names of base tables in t's schema on t's
+// instance, along with whether the schema exists, and whether the instance
+// folds table names to lowercase due to lower_case_table_names.
+func (t *Target) serverTableNames() (names []string, exists, foldCase bool, err error) {
+	db, err := t.Instance.Connect("", "")
+	if err != nil {
+		return nil, false, false, err
+	}
+	query := `
+		SELECT   @@global.lower_case_table_names AS lctn, t.table_name AS table_name
+		FROM     information_schema.schemata s
+		LEFT JOIN information_schema.tables t ON t.table_schema = s.schema_name AND t.table_type = 'BASE TABLE'
+		WHERE    s.schema_name = ?`
+	var rows []struct {
+		LowerCaseTableNames int            `db:"lctn"`
+		TableName           sql.NullString `db:"table_name"`
+	}
+	if err := db.Select(&rows, query, t.SchemaName); err != nil {
+		return nil, false, false, err
+	}
+	for _, row := range rows {
+		exists = true
+		foldCase = row.LowerCaseTableNames > 0
+		if row.TableName.Valid {
+			names = append(names, row.TableName.String)
+		}
+	}
+	return names, exists, foldCase, nil
+}
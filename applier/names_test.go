@@ -0,0 +1,50 @@
+package applier
+
+import (
+	"reflect"
+	"testing"
+)
+
+func (s ApplierIntegrationSuite) TestAuditNames(t *testing.T) {
+	setupHostList(t, s.d[0].Instance)
+	defer cleanupHostList(t)
+
+	db, err := s.d[0].Connect("", "")
+	if err != nil {
+		t.Fatalf("Unable to connect to DockerizedInstance: %s", err)
+	}
+	for _, query := range []string{
+		"CREATE DATABASE one",
+		"CREATE TABLE one.foo (id int unsigned NOT NULL PRIMARY KEY)",
+		"CREATE TABLE one.extra (id int unsigned NOT NULL PRIMARY KEY)",
+		"CREATE TABLE one._skeema_tracking (id int unsigned NOT NULL PRIMARY KEY)",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Unexpected error from %q: %s", query, err)
+		}
+	}
+
+	dir := getDir(t, "testdata/simple", "--ignore-table='^_'")
+	targets, skipCount := NameTargetsForDir(dir, 1)
+	if len(targets) != 2 || skipCount != 0 {
+		t.Fatalf("Unexpected result from NameTargetsForDir: %+v, %d", targets, skipCount)
+	}
+	for _, target := range targets {
+		audit := AuditNames([]*Target{target})
+		if audit.Error != "" {
+			t.Fatalf("Unexpected error auditing %s: %s", target.SchemaName, audit.Error)
+		}
+		switch target.SchemaName {
+		case "one":
+			if !audit.SchemaExists || !reflect.DeepEqual(audit.Both, []string{"foo"}) || len(audit.FilesystemOnly) != 0 || !reflect.DeepEqual(audit.ServerOnly, []string{"extra"}) || audit.Consistent() {
+				t.Errorf("Unexpected audit of schema one: %+v", *audit)
+			}
+		case "two":
+			if audit.SchemaExists || len(audit.Both) != 0 || !reflect.DeepEqual(audit.FilesystemOnly, []string{"bar"}) || len(audit.ServerOnly) != 0 || audit.Consistent() {
+				t.Errorf("Unexpected audit of schema two: %+v", *audit)
+			}
+		default:
+			t.Errorf("Unexpected target schema name %s", target.SchemaName)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/skeema/objdiff"
+	"github.com/skeema/tengo"
+)
+
+// TestObjDiffStatements compares DDL from package objdiff, which is generated
+// purely by tengo, against the DDL that `skeema diff` generates for the same
+// diffs. These only match for diffs which Skeema does not adjust.
+func TestObjDiffStatements(t *testing.T) {
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{
+		Instance:   inst,
+		Dir:        getDir(t, "testdata/simple/one", "--dry-run"),
+		SchemaName: "product",
+	}
+	flavor := tengo.FlavorMySQL80
+	mods := tengo.StatementModifiers{Flavor: flavor}
+	makeTable := func(createOptions, comment, charSet string) *tengo.Table {
+		table := &tengo.Table{
+			Name:               "foo",
+			Engine:             "InnoDB",
+			CharSet:            charSet,
+			Collation:          charSet + "_general_ci",
+			CollationIsDefault: true,
+			CreateOptions:      createOptions,
+			Comment:            comment,
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int unsigned"},
+			},
+		}
+		table.CreateStatement = table.GeneratedCreateStatement(flavor)
+		return table
+	}
+	statements := func(from, to *tengo.Table) (objdiffStmt, skeemaStmt string) {
+		t.Helper()
+		fromSchema := &tengo.Schema{Name: "product", Tables: []*tengo.Table{from}}
+		toSchema := &tengo.Schema{Name: "product", Tables: []*tengo.Table{to}}
+		for _, od := range objdiff.FromSchemaDiff(tengo.NewSchemaDiff(fromSchema, toSchema), flavor) {
+			if objdiffStmt, err = od.Statement(mods); err != nil {
+				t.Fatalf("Unexpected error from objdiff Statement: %s", err)
+			}
+		}
+		for _, diff := range tengo.NewSchemaDiff(normalizeCharSetAliases(fromSchema, toSchema, flavor), toSchema).ObjectDiffs() {
+			ddl, err := NewDDLStatement(diff, mods, target)
+			if err != nil {
+				t.Fatalf("Unexpected error from NewDDLStatement: %s", err)
+			}
+			skeemaStmt = ddl.stmt
+		}
+		return objdiffStmt, skeemaStmt
+	}
+
+	// Ordinary changes are identical
+	objdiffStmt, skeemaStmt := statements(makeTable("", "", "utf8mb4"), makeTable("", "hello", "utf8mb4"))
+	if objdiffStmt != skeemaStmt || objdiffStmt != "ALTER TABLE `foo` COMMENT 'hello'" {
+		t.Errorf("Expected identical statements, instead found %q vs %q", objdiffStmt, skeemaStmt)
+	}
+
+	// Resetting a table option without a valid DEFAULT value differs
+	objdiffStmt, skeemaStmt = statements(makeTable("ENCRYPTION='Y'", "", "utf8mb4"), makeTable("", "", "utf8mb4"))
+	if objdiffStmt != "ALTER TABLE `foo` ENCRYPTION=DEFAULT" || skeemaStmt != "ALTER TABLE `foo` ENCRYPTION='N'" {
+		t.Errorf("Unexpected statements resetting ENCRYPTION: %q vs %q", objdiffStmt, skeemaStmt)
+	}
+
+	// Spelling differences of the utf8mb3 alias are a diff in objdiff, but not in
+	// skeema diff
+	objdiffStmt, skeemaStmt = statements(makeTable("", "", "utf8mb3"), makeTable("", "", "utf8"))
+	if objdiffStmt == "" || skeemaStmt != "" {
+		t.Errorf("Unexpected statements for utf8mb3 alias: %q vs %q", objdiffStmt, skeemaStmt)
+	}
+}
@@ -0,0 +1,471 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// ddlAlgorithm represents the algorithm the server is expected to use for an
+// ALTER TABLE. Values are ordered from least to most impactful.
+type ddlAlgorithm int
+
+// Constants enumerating ALTER TABLE algorithms
+const (
+	algorithmInstant ddlAlgorithm = iota
+	algorithmInplace
+	algorithmCopy
+)
+
+func (alg ddlAlgorithm) String() string {
+	return [...]string{"INSTANT", "INPLACE", "COPY"}[alg]
+}
+
+// ddlLock represents the level of concurrent access the server is expected to
+// permit while an ALTER TABLE runs. Values are ordered from least to most
+// restrictive.
+type ddlLock int
+
+// Constants enumerating ALTER TABLE lock levels
+const (
+	lockNone      ddlLock = iota // concurrent reads and writes permitted
+	lockShared                   // concurrent reads permitted, writes blocked
+	lockExclusive                // concurrent reads and writes blocked
+)
+
+func (lock ddlLock) String() string {
+	return [...]string{"NONE", "SHARED", "EXCLUSIVE"}[lock]
+}
+
+// ddlEstimate describes the expected execution characteristics of an ALTER
+// TABLE statement.
+type ddlEstimate struct {
+	Algorithm ddlAlgorithm
+	Lock      ddlLock
+}
+
+func (est ddlEstimate) String() string {
+	return fmt.Sprintf("algorithm=%s, lock=%s", est.Algorithm, est.Lock)
+}
+
+// combine returns the more impactful of est and other, independently for
+// algorithm and lock.
+func (est ddlEstimate) combine(other ddlEstimate) ddlEstimate {
+	if other.Algorithm > est.Algorithm {
+		est.Algorithm = other.Algorithm
+	}
+	if other.Lock > est.Lock {
+		est.Lock = other.Lock
+	}
+	return est
+}
+
+// ddlOperation identifies a category of ALTER TABLE change, for purposes of
+// looking up its execution characteristics in onlineDDLMatrix.
+type ddlOperation string
+
+// Constants enumerating ALTER TABLE operation categories
+const (
+	opAddColumnLast        ddlOperation = "add-column-last"         // new last column
+	opAddColumnPositioned  ddlOperation = "add-column-positioned"   // new column with FIRST or AFTER
+	opAddColumnRebuild     ddlOperation = "add-column-rebuild"      // new column in a table ineligible for instant add
+	opAddColumnAutoInc     ddlOperation = "add-column-auto-inc"     // new auto_increment column
+	opAddColumnStored      ddlOperation = "add-column-stored"       // new stored generated column
+	opAddColumnVirtual     ddlOperation = "add-column-virtual"      // new virtual generated column
+	opDropColumn           ddlOperation = "drop-column"             // drop non-virtual column
+	opDropColumnVirtual    ddlOperation = "drop-column-virtual"     // drop virtual generated column
+	opRenameColumn         ddlOperation = "rename-column"           // rename column
+	opChangeColumnDefault  ddlOperation = "change-column-default"   // change only a column's default
+	opChangeColumnMetadata ddlOperation = "change-column-metadata"  // change only a column's comment
+	opChangeColumnNull     ddlOperation = "change-column-null"      // change a column's nullability
+	opReorderColumn        ddlOperation = "reorder-column"          // move a column's position
+	opExtendVarchar        ddlOperation = "extend-varchar"          // increase varchar length, within same length-byte size
+	opChangeColumnType     ddlOperation = "change-column-type"      // any other column change
+	opAddIndex             ddlOperation = "add-index"               // add secondary index
+	opAddIndexFulltext     ddlOperation = "add-index-fulltext"      // add FULLTEXT index
+	opAddIndexSpatial      ddlOperation = "add-index-spatial"       // add SPATIAL index
+	opAddPrimaryKey        ddlOperation = "add-primary-key"         // add primary key to a table without one
+	opReplacePrimaryKey    ddlOperation = "replace-primary-key"     // drop primary key and add a new one
+	opDropIndex            ddlOperation = "drop-index"              // drop secondary index
+	opRenameIndex          ddlOperation = "rename-index"            // rename secondary index
+	opDropPrimaryKey       ddlOperation = "drop-primary-key"        // drop primary key without adding a new one
+	opAddForeignKey        ddlOperation = "add-foreign-key"         // add foreign key with foreign_key_checks=0
+	opAddForeignKeyChecked ddlOperation = "add-foreign-key-checked" // add foreign key with foreign_key_checks=1
+	opDropForeignKey       ddlOperation = "drop-foreign-key"        // drop foreign key
+	opChangeAutoIncrement  ddlOperation = "change-auto-increment"   // change next auto_increment value
+	opChangeTableCharSet   ddlOperation = "change-table-charset"    // change table's default character set or collation
+	opChangeTableOptions   ddlOperation = "change-table-options"    // change ROW_FORMAT, KEY_BLOCK_SIZE, etc
+	opChangeTableComment   ddlOperation = "change-table-comment"    // change table comment
+	opChangeStorageEngine  ddlOperation = "change-storage-engine"   // change storage engine
+	opPartitionTable       ddlOperation = "partition-table"         // add or remove partitioning
+	opModifyPartitions     ddlOperation = "modify-partitions"       // add or drop partitions
+	opUnknown              ddlOperation = "unknown"                 // unrecognized clause
+)
+
+// serverVersion is a server's vendor and full version, which is needed since
+// online DDL support sometimes varies by patch release.
+type serverVersion struct {
+	vendor tengo.Vendor
+	major  int
+	minor  int
+	patch  int
+}
+
+// atLeast returns true if ver is equal to or newer than other, disregarding
+// vendor.
+func (ver serverVersion) atLeast(other serverVersion) bool {
+	if ver.major != other.major {
+		return ver.major > other.major
+	} else if ver.minor != other.minor {
+		return ver.minor > other.minor
+	}
+	return ver.patch >= other.patch
+}
+
+// onlineDDLRule indicates the expected execution characteristics of an
+// operation on minVersion and later versions of the same vendor.
+type onlineDDLRule struct {
+	op         ddlOperation
+	minVersion serverVersion
+	estimate   ddlEstimate
+}
+
+// Shorthands for the rules table below
+func mysqlVersion(major, minor, patch int) serverVersion {
+	return serverVersion{tengo.VendorMySQL, major, minor, patch}
+}
+func mariadbVersion(major, minor, patch int) serverVersion {
+	return serverVersion{tengo.VendorMariaDB, major, minor, patch}
+}
+
+var (
+	estInstant          = ddlEstimate{algorithmInstant, lockNone}
+	estInplace          = ddlEstimate{algorithmInplace, lockNone}
+	estInplaceShared    = ddlEstimate{algorithmInplace, lockShared}
+	estInplaceExclusive = ddlEstimate{algorithmInplace, lockExclusive}
+	estCopyShared       = ddlEstimate{algorithmCopy, lockShared}
+)
+
+// onlineDDLMatrix lists the expected execution characteristics of each
+// operation by vendor and version, based on the online DDL support matrices in
+// the MySQL and MariaDB manuals. For a given operation and server, the rule
+// with the newest minVersion not exceeding the server's version applies.
+// Operations without an applicable rule are assumed to copy the table while
+// blocking writes, as was the case for nearly all operations prior to MySQL
+// 5.6. Percona Server and unknown vendors use the MySQL rules.
+var onlineDDLMatrix = []onlineDDLRule{
+	{opAddColumnLast, mysqlVersion(5, 6, 0), estInplace},
+	{opAddColumnLast, mysqlVersion(8, 0, 12), estInstant},
+	{opAddColumnLast, mariadbVersion(10, 0, 0), estInplace},
+	{opAddColumnLast, mariadbVersion(10, 3, 2), estInstant},
+	{opAddColumnPositioned, mysqlVersion(5, 6, 0), estInplace},
+	{opAddColumnPositioned, mysqlVersion(8, 0, 29), estInstant},
+	{opAddColumnPositioned, mariadbVersion(10, 0, 0), estInplace},
+	{opAddColumnPositioned, mariadbVersion(10, 4, 0), estInstant},
+	{opAddColumnRebuild, mysqlVersion(5, 6, 0), estInplace},
+	{opAddColumnRebuild, mariadbVersion(10, 0, 0), estInplace},
+	{opAddColumnAutoInc, mysqlVersion(5, 6, 0), estInplaceShared},
+	{opAddColumnAutoInc, mariadbVersion(10, 0, 0), estInplaceShared},
+	{opAddColumnVirtual, mysqlVersion(5, 7, 0), estInplace},
+	{opAddColumnVirtual, mysqlVersion(8, 0, 12), estInstant},
+	{opDropColumn, mysqlVersion(5, 6, 0), estInplace},
+	{opDropColumn, mysqlVersion(8, 0, 29), estInstant},
+	{opDropColumn, mariadbVersion(10, 0, 0), estInplace},
+	{opDropColumn, mariadbVersion(10, 4, 0), estInstant},
+	{opDropColumnVirtual, mysqlVersion(5, 7, 0), estInplace},
+	{opDropColumnVirtual, mysqlVersion(8, 0, 12), estInstant},
+	{opRenameColumn, mysqlVersion(5, 6, 0), estInplace},
+	{opRenameColumn, mysqlVersion(8, 0, 28), estInstant},
+	{opRenameColumn, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeColumnDefault, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeColumnDefault, mysqlVersion(8, 0, 12), estInstant},
+	{opChangeColumnDefault, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeColumnDefault, mariadbVersion(10, 3, 2), estInstant},
+	{opChangeColumnMetadata, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeColumnMetadata, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeColumnNull, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeColumnNull, mariadbVersion(10, 0, 0), estInplace},
+	{opReorderColumn, mysqlVersion(5, 6, 0), estInplace},
+	{opReorderColumn, mariadbVersion(10, 0, 0), estInplace},
+	{opReorderColumn, mariadbVersion(10, 4, 0), estInstant},
+	{opExtendVarchar, mysqlVersion(5, 7, 0), estInplace},
+	{opExtendVarchar, mariadbVersion(10, 2, 2), estInplace},
+	{opAddIndex, mysqlVersion(5, 5, 0), estInplaceShared},
+	{opAddIndex, mysqlVersion(5, 6, 0), estInplace},
+	{opAddIndex, mariadbVersion(5, 5, 0), estInplaceShared},
+	{opAddIndex, mariadbVersion(10, 0, 0), estInplace},
+	{opAddIndexFulltext, mysqlVersion(5, 6, 0), estInplaceShared},
+	{opAddIndexFulltext, mariadbVersion(10, 0, 0), estInplaceShared},
+	{opAddIndexSpatial, mysqlVersion(5, 7, 0), estInplaceShared},
+	{opAddIndexSpatial, mariadbVersion(10, 2, 2), estInplaceShared},
+	{opAddPrimaryKey, mysqlVersion(5, 6, 0), estInplace},
+	{opAddPrimaryKey, mariadbVersion(10, 0, 0), estInplace},
+	{opReplacePrimaryKey, mysqlVersion(5, 6, 0), estInplace},
+	{opReplacePrimaryKey, mariadbVersion(10, 0, 0), estInplace},
+	{opDropIndex, mysqlVersion(5, 5, 0), estInplaceShared},
+	{opDropIndex, mysqlVersion(5, 6, 0), estInplace},
+	{opDropIndex, mariadbVersion(5, 5, 0), estInplaceShared},
+	{opDropIndex, mariadbVersion(10, 0, 0), estInplace},
+	{opRenameIndex, mysqlVersion(5, 7, 0), estInplace},
+	{opRenameIndex, mariadbVersion(10, 5, 0), estInstant},
+	{opAddForeignKey, mysqlVersion(5, 6, 0), estInplace},
+	{opAddForeignKey, mariadbVersion(10, 0, 0), estInplace},
+	{opDropForeignKey, mysqlVersion(5, 6, 0), estInplace},
+	{opDropForeignKey, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeAutoIncrement, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeAutoIncrement, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeTableCharSet, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeTableCharSet, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeTableOptions, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeTableOptions, mariadbVersion(10, 0, 0), estInplace},
+	{opChangeTableComment, mysqlVersion(5, 6, 0), estInplace},
+	{opChangeTableComment, mariadbVersion(10, 0, 0), estInplace},
+	{opModifyPartitions, mysqlVersion(5, 7, 0), estInplaceExclusive},
+}
+
+// lookupOnlineDDL returns the expected execution characteristics of op on a
+// server with version ver.
+func lookupOnlineDDL(op ddlOperation, ver serverVersion) ddlEstimate {
+	vendor := ver.vendor
+	if vendor != tengo.VendorMariaDB {
+		vendor = tengo.VendorMySQL
+	}
+	result := estCopyShared
+	var best *serverVersion
+	for n := range onlineDDLMatrix {
+		rule := &onlineDDLMatrix[n]
+		if rule.op != op || rule.minVersion.vendor != vendor || !ver.atLeast(rule.minVersion) {
+			continue
+		}
+		if best == nil || rule.minVersion.atLeast(*best) {
+			result = rule.estimate
+			best = &rule.minVersion
+		}
+	}
+	return result
+}
+
+// estimateAlter returns the expected execution characteristics of an ALTER
+// TABLE consisting of the supplied clauses from td. Any clauses that mods
+// cause to be omitted from the statement are ignored. If fkChecks is true, the
+// statement will be run with foreign_key_checks=1. Since the server uses the
+// least impactful algorithm and lock level supported by all clauses, the
+// estimate is the most impactful of any single clause; it is further raised to
+// match any explicit alter-algorithm or alter-lock. In cases where the actual
+// behavior depends on factors that cannot be determined in advance, the
+// estimate reflects the most impactful possibility.
+func estimateAlter(td *tengo.TableDiff, clauses []tengo.TableAlterClause, mods tengo.StatementModifiers, ver serverVersion, fkChecks bool) ddlEstimate {
+	var addsPK, dropsPK bool
+	for _, clause := range clauses {
+		switch clause := clause.(type) {
+		case tengo.AddIndex:
+			addsPK = addsPK || clause.Index.PrimaryKey
+		case tengo.DropIndex:
+			dropsPK = dropsPK || clause.Index.PrimaryKey
+		}
+	}
+	mods.AllowUnsafe = true
+	est := estInstant
+	for _, clause := range clauses {
+		if clause.Clause(mods) == "" {
+			continue
+		}
+		for _, op := range clauseOperations(clause, td, addsPK && dropsPK, fkChecks) {
+			est = est.combine(lookupOnlineDDL(op, ver))
+		}
+	}
+	switch strings.ToLower(mods.AlgorithmClause) {
+	case "inplace":
+		est = est.combine(estInplace)
+	case "copy":
+		est = est.combine(estCopyShared)
+	}
+	switch strings.ToLower(mods.LockClause) {
+	case "shared":
+		est = est.combine(ddlEstimate{Lock: lockShared})
+	case "exclusive":
+		est = est.combine(ddlEstimate{Lock: lockExclusive})
+	}
+	return est
+}
+
+// clauseOperations returns the operation categories performed by clause.
+// replacesPK should be true if the ALTER TABLE both drops and adds a primary
+// key.
+func clauseOperations(clause tengo.TableAlterClause, td *tengo.TableDiff, replacesPK, fkChecks bool) []ddlOperation {
+	switch clause := clause.(type) {
+	case tengo.AddColumn:
+		return []ddlOperation{addColumnOperation(clause, td.To)}
+	case tengo.DropColumn:
+		if clause.Column.Virtual {
+			return []ddlOperation{opDropColumnVirtual}
+		}
+		return []ddlOperation{opDropColumn}
+	case tengo.RenameColumn:
+		return []ddlOperation{opRenameColumn}
+	case tengo.ModifyColumn:
+		return modifyColumnOperations(clause)
+	case tengo.AddIndex:
+		if clause.Index.PrimaryKey && replacesPK {
+			return []ddlOperation{opReplacePrimaryKey}
+		} else if clause.Index.PrimaryKey {
+			return []ddlOperation{opAddPrimaryKey}
+		} else if clause.Index.Type == "FULLTEXT" {
+			return []ddlOperation{opAddIndexFulltext}
+		} else if clause.Index.Type == "SPATIAL" {
+			return []ddlOperation{opAddIndexSpatial}
+		}
+		return []ddlOperation{opAddIndex}
+	case tengo.DropIndex:
+		if clause.Index.PrimaryKey && replacesPK {
+			return []ddlOperation{opReplacePrimaryKey}
+		} else if clause.Index.PrimaryKey {
+			return []ddlOperation{opDropPrimaryKey}
+		}
+		return []ddlOperation{opDropIndex}
+	case renameIndex:
+		return []ddlOperation{opRenameIndex}
+	case tengo.AddForeignKey:
+		if fkChecks {
+			return []ddlOperation{opAddForeignKeyChecked}
+		}
+		return []ddlOperation{opAddForeignKey}
+	case tengo.DropForeignKey:
+		return []ddlOperation{opDropForeignKey}
+	case tengo.ChangeAutoIncrement:
+		return []ddlOperation{opChangeAutoIncrement}
+	case tengo.ChangeCharSet:
+		return []ddlOperation{opChangeTableCharSet}
+	case tengo.ChangeCreateOptions:
+		return []ddlOperation{opChangeTableOptions}
+	case tengo.ChangeComment:
+		return []ddlOperation{opChangeTableComment}
+	case tengo.ChangeStorageEngine:
+		return []ddlOperation{opChangeStorageEngine}
+	case tengo.PartitionBy, tengo.RemovePartitioning:
+		return []ddlOperation{opPartitionTable}
+	case tengo.ModifyPartitions:
+		return []ddlOperation{opModifyPartitions}
+	}
+	return []ddlOperation{opUnknown}
+}
+
+// addColumnOperation returns the operation category for adding a column.
+// Tables with a FULLTEXT index or compressed row format cannot use the instant
+// algorithm for adding columns.
+func addColumnOperation(clause tengo.AddColumn, table *tengo.Table) ddlOperation {
+	if clause.Column.AutoIncrement {
+		return opAddColumnAutoInc
+	} else if clause.Column.GenerationExpr != "" && clause.Column.Virtual {
+		return opAddColumnVirtual
+	} else if clause.Column.GenerationExpr != "" {
+		return opAddColumnStored
+	}
+	if table != nil {
+		if strings.Contains(strings.ToUpper(table.CreateOptions), "ROW_FORMAT=COMPRESSED") {
+			return opAddColumnRebuild
+		}
+		for _, idx := range table.SecondaryIndexes {
+			if idx.Type == "FULLTEXT" {
+				return opAddColumnRebuild
+			}
+		}
+	}
+	if clause.PositionFirst || clause.PositionAfter != nil {
+		return opAddColumnPositioned
+	}
+	return opAddColumnLast
+}
+
+// modifyColumnOperations returns the operation categories for modifying a
+// column. Any change to the column's type, character set, collation, generation
+// expression, or auto_increment status is considered a type change, except for
+// varchar length increases that do not change the number of bytes needed to
+// store the value length.
+func modifyColumnOperations(clause tengo.ModifyColumn) []ddlOperation {
+	oldCol, newCol := clause.OldColumn, clause.NewColumn
+	if oldCol.CharSet != newCol.CharSet || oldCol.Collation != newCol.Collation || oldCol.GenerationExpr != newCol.GenerationExpr || oldCol.Virtual != newCol.Virtual || oldCol.AutoIncrement != newCol.AutoIncrement || oldCol.OnUpdate != newCol.OnUpdate {
+		return []ddlOperation{opChangeColumnType}
+	}
+	var ops []ddlOperation
+	if oldCol.TypeInDB != newCol.TypeInDB {
+		if !varcharExtension(oldCol, newCol) {
+			return []ddlOperation{opChangeColumnType}
+		}
+		ops = append(ops, opExtendVarchar)
+	}
+	if oldCol.Nullable != newCol.Nullable {
+		ops = append(ops, opChangeColumnNull)
+	}
+	if clause.PositionFirst || clause.PositionAfter != nil {
+		ops = append(ops, opReorderColumn)
+	}
+	if oldCol.Default != newCol.Default {
+		ops = append(ops, opChangeColumnDefault)
+	}
+	if oldCol.Comment != newCol.Comment {
+		ops = append(ops, opChangeColumnMetadata)
+	}
+	return ops
+}
+
+var reVarcharLength = regexp.MustCompile(`^varchar\((\d+)\)$`)
+
+// maxBytesPerChar maps character sets to their maximum bytes per character.
+// Only character sets listed here are eligible for in-place varchar extension
+// estimates.
+var maxBytesPerChar = map[string]int{
+	"ascii":   1,
+	"binary":  1,
+	"latin1":  1,
+	"utf8":    3,
+	"utf8mb3": 3,
+	"utf8mb4": 4,
+}
+
+// varcharExtension returns true if newCol increases the length of varchar
+// column oldCol, without changing whether 1 or 2 bytes are needed to store the
+// length of values.
+func varcharExtension(oldCol, newCol *tengo.Column) bool {
+	oldMatch := reVarcharLength.FindStringSubmatch(oldCol.TypeInDB)
+	newMatch := reVarcharLength.FindStringSubmatch(newCol.TypeInDB)
+	bytesPerChar, ok := maxBytesPerChar[newCol.CharSet]
+	if oldMatch == nil || newMatch == nil || !ok {
+		return false
+	}
+	oldLen, _ := strconv.Atoi(oldMatch[1])
+	newLen, _ := strconv.Atoi(newMatch[1])
+	if newLen < oldLen {
+		return false
+	}
+	return (oldLen*bytesPerChar < 256) == (newLen*bytesPerChar < 256)
+}
+
+// instanceServerVersion returns the serverVersion of inst. If the full version
+// cannot be determined, the major and minor version of the instance's flavor
+// are used, which yields conservative estimates for later patch releases.
+func instanceServerVersion(inst *tengo.Instance) serverVersion {
+	flavor := inst.Flavor()
+	ver := serverVersion{vendor: flavor.Vendor}
+	if ver.major, ver.minor, ver.patch = inst.Version(); ver.major == 0 {
+		ver.major, ver.minor, ver.patch = flavor.Major, flavor.Minor, 0
+	}
+	return ver
+}
+
+// setEstimate populates ddl's execution estimate from the supplied clauses, if
+// ddl is an ALTER TABLE run directly against the database. Statements run via
+// alter-wrapper or ddl-wrapper do not receive an estimate, since the external
+// tool determines how the change is performed.
+func (ddl *DDLStatement) setEstimate(clauses []tengo.TableAlterClause, mods tengo.StatementModifiers) {
+	td, ok := ddl.diff.(*tengo.TableDiff)
+	if !ok || td.Type != tengo.DiffTypeAlter || ddl.IsShellOut() || ddl.target == nil {
+		return
+	}
+	fkChecks := strings.Contains(ddl.connectParams, "foreign_key_checks=1")
+	est := estimateAlter(td, clauses, mods, instanceServerVersion(ddl.target.Instance), fkChecks)
+	ddl.estimate = &est
+}
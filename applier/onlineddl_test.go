@@ -0,0 +1,84 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestLookupOnlineDDL(t *testing.T) {
+	cases := []struct {
+		op       ddlOperation
+		ver      serverVersion
+		expected ddlEstimate
+	}{
+		{opAddColumnLast, mysqlVersion(5, 5, 62), estCopyShared},
+		{opAddColumnLast, mysqlVersion(5, 7, 30), estInplace},
+		{opAddColumnLast, mysqlVersion(8, 0, 11), estInplace},
+		{opAddColumnLast, mysqlVersion(8, 0, 12), estInstant},
+		{opAddColumnPositioned, mysqlVersion(8, 0, 28), estInplace},
+		{opAddColumnPositioned, mysqlVersion(8, 0, 29), estInstant},
+		{opAddColumnPositioned, serverVersion{tengo.VendorPercona, 8, 0, 30}, estInstant},
+		{opAddColumnPositioned, serverVersion{tengo.VendorUnknown, 8, 0, 30}, estInstant},
+		{opAddColumnPositioned, mariadbVersion(10, 3, 20), estInplace},
+		{opAddColumnPositioned, mariadbVersion(10, 4, 0), estInstant},
+		{opAddIndex, mysqlVersion(5, 5, 62), estInplaceShared},
+		{opAddIndex, mysqlVersion(8, 0, 30), estInplace},
+		{opDropPrimaryKey, mysqlVersion(8, 0, 30), estCopyShared},
+		{opChangeColumnType, mariadbVersion(10, 6, 0), estCopyShared},
+		{opUnknown, mysqlVersion(8, 0, 30), estCopyShared},
+	}
+	for _, c := range cases {
+		if actual := lookupOnlineDDL(c.op, c.ver); actual != c.expected {
+			t.Errorf("Expected %s on %+v to be %s, instead found %s", c.op, c.ver, c.expected, actual)
+		}
+	}
+}
+
+func TestEstimateAlter(t *testing.T) {
+	assertEstimate := func(from, to *tengo.Table, mods tengo.StatementModifiers, ver serverVersion, fkChecks bool, expected ddlEstimate) {
+		t.Helper()
+		td := tengo.NewAlterTable(from, to)
+		clauses, supported := tableDiffClauses(td)
+		if !supported {
+			t.Fatalf("Unexpected unsupported diff for %s", from.Name)
+		}
+		if actual := estimateAlter(td, clauses, mods, ver, fkChecks); actual != expected {
+			t.Errorf("Expected estimate %s, instead found %s", expected, actual)
+		}
+	}
+	mysql8 := mysqlVersion(8, 0, 30)
+
+	// Adding a column, index, and foreign key: the index determines the overall
+	// estimate, unless foreign_key_checks=1 forces a copy
+	from, to := brokenFKTestTable("users"), brokenFKTestTable("users", "posts")
+	assertEstimate(from, to, tengo.StatementModifiers{}, mysql8, false, estInplace)
+	assertEstimate(from, to, tengo.StatementModifiers{}, mysql8, true, estCopyShared)
+	assertEstimate(from, to, tengo.StatementModifiers{AlgorithmClause: "copy"}, mysql8, false, estCopyShared)
+	assertEstimate(from, to, tengo.StatementModifiers{LockClause: "exclusive"}, mysql8, false, estInplaceExclusive)
+	assertEstimate(from, to, tengo.StatementModifiers{}, mysqlVersion(5, 5, 62), false, estCopyShared)
+
+	// Adding just a column is instant on recent versions, unless the table has
+	// a FULLTEXT index
+	to = brokenFKTestTable("users")
+	to.Columns = append(to.Columns, &tengo.Column{Name: "name", TypeInDB: "varchar(40)", Nullable: true, Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"})
+	to.CreateStatement = to.GeneratedCreateStatement(tengo.FlavorMySQL80)
+	assertEstimate(from, to, tengo.StatementModifiers{}, mysql8, false, estInstant)
+	assertEstimate(from, to, tengo.StatementModifiers{}, mysqlVersion(5, 7, 30), false, estInplace)
+	assertEstimate(from, to, tengo.StatementModifiers{AlgorithmClause: "inplace"}, mysql8, false, estInplace)
+	ft := *to
+	ft.SecondaryIndexes = []*tengo.Index{{Name: "ft", Columns: to.Columns[1:], SubParts: []uint16{0}, Type: "FULLTEXT"}}
+	fromFT := *from
+	fromFT.SecondaryIndexes = ft.SecondaryIndexes
+	ft.CreateStatement, fromFT.CreateStatement = ft.GeneratedCreateStatement(tengo.FlavorMySQL80), fromFT.GeneratedCreateStatement(tengo.FlavorMySQL80)
+	assertEstimate(&fromFT, &ft, tengo.StatementModifiers{}, mysql8, false, estInplace)
+
+	// Extending a varchar is in-place only if the length bytes are unchanged
+	longer := *to
+	longer.Columns = []*tengo.Column{to.Columns[0], {Name: "name", TypeInDB: "varchar(60)", Nullable: true, Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"}}
+	longer.CreateStatement = longer.GeneratedCreateStatement(tengo.FlavorMySQL80)
+	assertEstimate(to, &longer, tengo.StatementModifiers{}, mysql8, false, estInplace)
+	longer.Columns[1].TypeInDB = "varchar(80)"
+	longer.CreateStatement = longer.GeneratedCreateStatement(tengo.FlavorMySQL80)
+	assertEstimate(to, &longer, tengo.StatementModifiers{}, mysql8, false, estCopyShared)
+}
@@ -0,0 +1,151 @@
+package applier
+
+import (
+	"github.com/skeema/tengo"
+)
+
+// diffDeps summarizes the aspects of a single ObjectDiff which are relevant to
+// ordering it relative to other diffs in the same schema.
+type diffDeps struct {
+	table      string          // name of table affected, or empty string if not a table diff
+	creates    bool            // true if a CREATE TABLE
+	addsIndex  bool            // true if an ALTER TABLE adding any indexes
+	dropsIndex bool            // true if an ALTER TABLE dropping any indexes
+	addsFKTo   map[string]bool // parent tables of foreign keys added or created
+	dropsFKTo  map[string]bool // parent tables of foreign keys dropped
+}
+
+// newDiffDeps returns a diffDeps for objDiff. Foreign keys referencing other
+// schemas are ignored, since they cannot depend on other diffs for schemaName.
+func newDiffDeps(objDiff tengo.ObjectDiff, schemaName string) *diffDeps {
+	deps := &diffDeps{
+		addsFKTo:  make(map[string]bool),
+		dropsFKTo: make(map[string]bool),
+	}
+	td, ok := objDiff.(*tengo.TableDiff)
+	if !ok {
+		return deps
+	}
+	deps.table = td.ObjectKey().Name
+	sameSchema := func(fk *tengo.ForeignKey) bool {
+		return fk.ReferencedSchemaName == "" || fk.ReferencedSchemaName == schemaName
+	}
+	if td.Type == tengo.DiffTypeCreate {
+		deps.creates = true
+		for _, fk := range td.To.ForeignKeys {
+			if sameSchema(fk) {
+				deps.addsFKTo[fk.ReferencedTableName] = true
+			}
+		}
+		return deps
+	} else if td.Type != tengo.DiffTypeAlter {
+		return deps
+	}
+
+	clauses, supported := tableDiffClauses(td)
+	if !supported {
+		return deps
+	}
+	for _, clause := range clauses {
+		switch clause := clause.(type) {
+		case tengo.AddIndex:
+			deps.addsIndex = true
+		case tengo.DropIndex:
+			deps.dropsIndex = true
+		case tengo.AddForeignKey:
+			if sameSchema(clause.ForeignKey) {
+				deps.addsFKTo[clause.ForeignKey.ReferencedTableName] = true
+			}
+		case tengo.DropForeignKey:
+			if sameSchema(clause.ForeignKey) {
+				deps.dropsFKTo[clause.ForeignKey.ReferencedTableName] = true
+			}
+		}
+	}
+	return deps
+}
+
+// tableDiffClauses returns the clauses of an ALTER TABLE diff, along with
+// whether the diff is supported. The diff engine may split a table's ALTER
+// into two diffs, with the second one containing only ADD FOREIGN KEY clauses.
+// Since the clauses of each half are not exposed, the full set of clauses is
+// recomputed, and then narrowed to whichever half td is by re-splitting it.
+func tableDiffClauses(td *tengo.TableDiff) ([]tengo.TableAlterClause, bool) {
+	clauses, supported := td.From.Diff(td.To)
+	if !supported {
+		return nil, false
+	}
+	other, addFKs := td.SplitAddForeignKeys()
+	result := make([]tengo.TableAlterClause, 0, len(clauses))
+	for _, clause := range clauses {
+		_, isAddFK := clause.(tengo.AddForeignKey)
+		if (isAddFK && addFKs != nil) || (!isAddFK && other != nil) {
+			result = append(result, clause)
+		}
+	}
+	return result, true
+}
+
+// mustPrecede returns true if the diff described by deps must be executed
+// before the diff described by other. A foreign key must be dropped before
+// its parent table's indexes are dropped, since the server will not drop an
+// index needed by a foreign key. Conversely, a parent table must be created,
+// or have its indexes added, before a child's foreign key referencing it is
+// added.
+func (deps *diffDeps) mustPrecede(other *diffDeps) bool {
+	if other.table == "" || deps.table == "" {
+		return false
+	}
+	if deps.dropsFKTo[other.table] && other.dropsIndex {
+		return true
+	}
+	if other.addsFKTo[deps.table] && (deps.creates || deps.addsIndex) {
+		return true
+	}
+	return false
+}
+
+// orderByDependencies returns a copy of objDiffs, reordered as needed so that
+// each diff is executed after any diffs it depends upon, for diffs in the
+// supplied schema. Relative order is otherwise preserved. If the dependencies
+// are circular, objDiffs is returned in its original order, and ok will be
+// false.
+func orderByDependencies(objDiffs []tengo.ObjectDiff, schemaName string) (result []tengo.ObjectDiff, ok bool) {
+	deps := make([]*diffDeps, len(objDiffs))
+	for n, objDiff := range objDiffs {
+		deps[n] = newDiffDeps(objDiff, schemaName)
+	}
+
+	// predecessors[n] tracks how many unplaced diffs must precede diff n
+	predecessors := make([]int, len(objDiffs))
+	for a := range deps {
+		for b := range deps {
+			if a != b && deps[a].mustPrecede(deps[b]) {
+				predecessors[b]++
+			}
+		}
+	}
+
+	result = make([]tengo.ObjectDiff, 0, len(objDiffs))
+	placed := make([]bool, len(objDiffs))
+	for len(result) < len(objDiffs) {
+		next := -1
+		for n := range objDiffs {
+			if !placed[n] && predecessors[n] == 0 {
+				next = n
+				break
+			}
+		}
+		if next < 0 {
+			return append([]tengo.ObjectDiff{}, objDiffs...), false
+		}
+		placed[next] = true
+		result = append(result, objDiffs[next])
+		for n := range deps {
+			if !placed[n] && deps[next].mustPrecede(deps[n]) {
+				predecessors[n]--
+			}
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,77 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// orderTestTable returns a table with foreign keys referencing each of the
+// supplied table names. If indexed is true, the table also has an additional
+// secondary index.
+func orderTestTable(name string, indexed bool, referencedTables ...string) *tengo.Table {
+	table := brokenFKTestTable(name, referencedTables...)
+	if indexed {
+		table.SecondaryIndexes = append(table.SecondaryIndexes, &tengo.Index{Name: "extra", Columns: table.Columns[:1], SubParts: []uint16{0}})
+		table.CreateStatement = table.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	}
+	return table
+}
+
+func assertDiffOrder(t *testing.T, actual []tengo.ObjectDiff, expected ...tengo.ObjectDiff) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d diffs, instead found %d", len(expected), len(actual))
+	}
+	for n := range expected {
+		if actual[n] != expected[n] {
+			t.Errorf("Unexpected diff at position %d: expected %s %s, found %s %s", n, expected[n].DiffType(), expected[n].ObjectKey(), actual[n].DiffType(), actual[n].ObjectKey())
+		}
+	}
+}
+
+func TestOrderByDependencies(t *testing.T) {
+	users := brokenFKTestTable("users")
+	users.Comment = "unrelated"
+	users.CreateStatement = users.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	unrelated := tengo.NewAlterTable(brokenFKTestTable("users"), users)
+
+	// Foreign key drops must precede their parent's index drops
+	parentDropIndex := tengo.NewAlterTable(orderTestTable("parents", true), orderTestTable("parents", false))
+	childDropFK := tengo.NewAlterTable(orderTestTable("children", false, "parents"), orderTestTable("children", false))
+	result, ok := orderByDependencies([]tengo.ObjectDiff{unrelated, parentDropIndex, childDropFK}, "product")
+	if !ok {
+		t.Fatal("Unexpected cycle detected")
+	}
+	assertDiffOrder(t, result, unrelated, childDropFK, parentDropIndex)
+
+	// Foreign key adds must follow their parent's index adds, as well as CREATE
+	// TABLE of the parent
+	parentAddIndex := tengo.NewAlterTable(orderTestTable("parents", false), orderTestTable("parents", true))
+	childMain, childAddFK := tengo.NewAlterTable(orderTestTable("children", false), orderTestTable("children", false, "parents")).SplitAddForeignKeys()
+	if childMain == nil || childAddFK == nil {
+		t.Fatalf("Expected child ALTER to be split, instead found %v, %v", childMain, childAddFK)
+	}
+	result, _ = orderByDependencies([]tengo.ObjectDiff{childMain, childAddFK, unrelated, parentAddIndex}, "product")
+	assertDiffOrder(t, result, childMain, unrelated, parentAddIndex, childAddFK)
+	createParent := tengo.NewCreateTable(orderTestTable("parents", false))
+	createChild := tengo.NewCreateTable(orderTestTable("children", false, "parents"))
+	result, _ = orderByDependencies([]tengo.ObjectDiff{createChild, unrelated, createParent}, "product")
+	assertDiffOrder(t, result, unrelated, createParent, createChild)
+
+	// References to other schemas are not considered
+	crossSchema := orderTestTable("children", false, "parents")
+	crossSchema.ForeignKeys[0].ReferencedSchemaName = "other"
+	childDropFK = tengo.NewAlterTable(crossSchema, orderTestTable("children", false))
+	result, _ = orderByDependencies([]tengo.ObjectDiff{parentDropIndex, childDropFK}, "product")
+	assertDiffOrder(t, result, parentDropIndex, childDropFK)
+
+	// Circular dependencies result in the original order
+	aDrop := tengo.NewAlterTable(orderTestTable("a", true, "b"), orderTestTable("a", false))
+	bDrop := tengo.NewAlterTable(orderTestTable("b", true, "a"), orderTestTable("b", false))
+	result, ok = orderByDependencies([]tengo.ObjectDiff{bDrop, unrelated, aDrop}, "product")
+	if ok {
+		t.Error("Expected cycle to be detected, but ok was true")
+	}
+	assertDiffOrder(t, result, bDrop, unrelated, aDrop)
+}
@@ -0,0 +1,352 @@
+package applier
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// PlanFormatVersion is the version of the plan file format. It should be
+// incremented whenever the plan file structure changes incompatibly.
+const PlanFormatVersion = 1
+
+// Plan represents the DDL that would be executed by `skeema push`, captured in
+// a form that can be written to a file, reviewed, and then executed later via
+// `skeema apply`. Alongside each statement, a plan records a fingerprint of
+// the affected object's definition on the server at planning time, so that
+// drift can be detected before execution.
+type Plan struct {
+	FormatVersion int           `json:"format_version"`
+	Environment   string        `json:"environment"`
+	Targets       []*PlanTarget `json:"targets"`
+
+	// CharsetConversion indicates the plan was generated in character set
+	// conversion mode: conversion ALTERs are flagged with their table size, and
+	// each target's statements are ordered so that schema-level changes come
+	// first and the largest tables come last.
+	CharsetConversion bool `json:"charset_conversion,omitempty"`
+
+	basePath    string
+	targetIndex map[string]*PlanTarget
+	shellOuts   []string
+}
+
+// PlanTarget represents the statements to execute in a single schema on a
+// single instance.
+type PlanTarget struct {
+	Instance   string           `json:"instance"`
+	SchemaName string           `json:"schema"`
+	Dir        string           `json:"dir"`
+	Server     *ServerInfo      `json:"server,omitempty"` // snapshot of the instance's server variables at planning time, if available
+	Statements []*PlanStatement `json:"statements"`
+}
+
+// PlanStatement represents a single DDL statement in a plan.
+type PlanStatement struct {
+	ObjectType    tengo.ObjectType `json:"object_type"`
+	ObjectName    string           `json:"object_name"`
+	DiffType      string           `json:"diff_type"`
+	Unsafe        bool             `json:"unsafe"`
+	UnsafeCodes   []UnsafeCode     `json:"unsafe_codes,omitempty"`
+	Fingerprint   string           `json:"fingerprint"`
+	Statement     string           `json:"statement"`
+	ConnectParams string           `json:"connect_params,omitempty"`
+	Algorithm     string           `json:"algorithm,omitempty"` // expected ALTER TABLE algorithm, if estimated
+	Lock          string           `json:"lock,omitempty"`      // expected ALTER TABLE lock level, if estimated
+	Stats         *TableStats      `json:"stats,omitempty"`     // size of the affected table, if the stats option is enabled and stats were obtained
+	StatsUnknown  bool             `json:"stats_unknown,omitempty"`
+
+	// Fields only populated in character set conversion mode
+	Conversion        bool   `json:"charset_conversion,omitempty"` // true if the statement only converts character sets or collations
+	TableSize         int64  `json:"table_size,omitempty"`         // size of the table in bytes at planning time
+	ResultFingerprint string `json:"result_fingerprint,omitempty"` // expected fingerprint after the statement has been executed
+}
+
+// NewPlan returns a pointer to a new empty Plan for operations originating in
+// the supplied dir, typically the current working directory.
+func NewPlan(dir *fs.Dir) *Plan {
+	return &Plan{
+		FormatVersion: PlanFormatVersion,
+		Environment:   dir.Config.Get("environment"),
+		Targets:       []*PlanTarget{},
+		basePath:      dir.Path,
+		targetIndex:   make(map[string]*PlanTarget),
+	}
+}
+
+// ReadPlan parses the plan file at filePath.
+func ReadPlan(filePath string) (*Plan, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	if err := json.Unmarshal(contents, plan); err != nil {
+		return nil, fmt.Errorf("%s is not a valid plan file: %s", filePath, err)
+	}
+	if plan.FormatVersion != PlanFormatVersion {
+		return nil, fmt.Errorf("%s uses plan format version %d, but this version of Skeema only supports plan format version %d", filePath, plan.FormatVersion, PlanFormatVersion)
+	}
+	return plan, nil
+}
+
+// addStatement records ddl in the plan. Callers must ensure this method is not
+// called concurrently; Printer handles this by holding its lock.
+func (plan *Plan) addStatement(ddl *DDLStatement) {
+	key := ddl.diff.ObjectKey()
+	if ddl.IsShellOut() {
+		plan.shellOuts = append(plan.shellOuts, fmt.Sprintf("%s %s %s", ddl.target.Instance, ddl.target.SchemaName, key))
+		return
+	}
+	targetKey := fmt.Sprintf("%s:%s", ddl.target.Instance, ddl.target.SchemaName)
+	pt := plan.targetIndex[targetKey]
+	if pt == nil {
+		dirPath, err := filepath.Rel(plan.basePath, ddl.target.Dir.Path)
+		if err != nil {
+			dirPath = ddl.target.Dir.Path
+		}
+		pt = &PlanTarget{
+			Instance:   ddl.target.Instance.String(),
+			SchemaName: ddl.target.SchemaName,
+			Dir:        filepath.ToSlash(dirPath),
+		}
+		if info, err := ServerInfoForInstance(ddl.target.Instance); err == nil {
+			pt.Server = info
+		} else {
+			log.Debugf("Unable to query server variables of %s: %s", ddl.target.Instance, err)
+		}
+		plan.targetIndex[targetKey] = pt
+		plan.Targets = append(plan.Targets, pt)
+	}
+	ps := &PlanStatement{
+		ObjectType:    key.Type,
+		ObjectName:    key.Name,
+		DiffType:      ddl.diff.DiffType().String(),
+		Unsafe:        ddl.unsafe,
+		UnsafeCodes:   ddl.unsafeCodes,
+		Fingerprint:   ddl.fingerprint,
+		Statement:     ddl.stmt,
+		ConnectParams: ddl.connectParams,
+	}
+	if ddl.estimate != nil {
+		ps.Algorithm = ddl.estimate.Algorithm.String()
+		ps.Lock = ddl.estimate.Lock.String()
+	}
+	if ddl.stats != nil && ddl.stats.unknown {
+		ps.StatsUnknown = true
+	} else if ddl.stats != nil {
+		ps.Stats = ddl.stats
+	}
+	if plan.CharsetConversion {
+		plan.addConversionInfo(ps, ddl)
+	}
+	pt.Statements = append(pt.Statements, ps)
+}
+
+// addConversionInfo populates ps's conversion-mode fields. Statements which
+// only change character sets or collations record the size of the affected
+// table, along with the fingerprint the object will have once converted, so
+// that a partially-applied conversion plan can be resumed.
+func (plan *Plan) addConversionInfo(ps *PlanStatement, ddl *DDLStatement) {
+	switch diff := ddl.diff.(type) {
+	case *tengo.DatabaseDiff:
+		if diff.From != nil && diff.To != nil && diff.To.CharSet != "" && diff.To.Collation != "" {
+			ps.ResultFingerprint = fingerprint(fmt.Sprintf("CHARACTER SET %s COLLATE %s", diff.To.CharSet, diff.To.Collation))
+		}
+	case *tengo.TableDiff:
+		if !isCharsetConversion(diff) {
+			return
+		}
+		ps.Conversion = true
+		create, _ := tengo.ParseCreateAutoInc(diff.To.CreateStatement)
+		ps.ResultFingerprint = fingerprint(create)
+		size, err := getTableSize(ddl.target, diff.To.Name)
+		if err != nil {
+			log.Warnf("Unable to determine size of table %s on %s: %s", tengo.EscapeIdentifier(diff.To.Name), ddl.target.Instance, err)
+		}
+		ps.TableSize = size
+	}
+}
+
+// Write saves the plan as JSON to filePath. An error is returned without
+// writing anything if the plan includes any statements that would be executed
+// by shelling out to an external program, since these cannot be captured
+// faithfully in a plan.
+func (plan *Plan) Write(filePath string) error {
+	if len(plan.shellOuts) > 0 {
+		// Intentionally avoiding fmt.Errorf here to avoid golint complaining about capitalization
+		errorText := fmt.Sprintf("Plan files cannot include operations executed via alter-wrapper or ddl-wrapper, but these were configured for: %s", strings.Join(plan.shellOuts, ", "))
+		return errors.New(errorText)
+	}
+	if plan.CharsetConversion {
+		for _, pt := range plan.Targets {
+			pt.sortConversions()
+		}
+	}
+	contents, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(filePath, append(contents, '\n'))
+}
+
+// StatementCount returns the total number of statements in the plan.
+func (plan *Plan) StatementCount() (count int) {
+	for _, pt := range plan.Targets {
+		count += len(pt.Statements)
+	}
+	return count
+}
+
+// sortConversions reorders pt's statements for a character set conversion
+// plan: database-level statements first, followed by any statements which
+// aren't pure conversions, followed by conversions from smallest table to
+// largest. The relative order of statements is otherwise preserved.
+func (pt *PlanTarget) sortConversions() {
+	rank := func(ps *PlanStatement) int {
+		if ps.ObjectType == tengo.ObjectTypeDatabase {
+			return 0
+		} else if !ps.Conversion {
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(pt.Statements, func(i, j int) bool {
+		a, b := pt.Statements[i], pt.Statements[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return a.Conversion && a.TableSize < b.TableSize
+	})
+}
+
+// SelectTables returns a copy of pt containing only the statements affecting
+// tables whose names match at least one of the supplied glob patterns, using
+// the syntax of filepath.Match. Statements affecting other object types are
+// always kept. This permits a large plan to be applied piecemeal.
+func (pt *PlanTarget) SelectTables(patterns []string) *PlanTarget {
+	result := *pt
+	result.Statements = []*PlanStatement{}
+	for _, stmt := range pt.Statements {
+		keep := stmt.ObjectType != tengo.ObjectTypeTable
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, stmt.ObjectName); matched {
+				keep = true
+			}
+		}
+		if keep {
+			result.Statements = append(result.Statements, stmt)
+		}
+	}
+	return &result
+}
+
+// Verify confirms that the definition of every object affected by pt's
+// statements is unchanged on inst since the plan was generated. Objects not
+// affected by the plan are not examined, so changes to them do not invalidate
+// the plan. If any drift is detected, the returned error describes it.
+// Otherwise, a copy of pt is returned containing the statements which still
+// need to be executed: statements recording a ResultFingerprint which already
+// matches the object's current definition were applied by a previous run, and
+// are omitted.
+func (pt *PlanTarget) Verify(inst *tengo.Instance) (*PlanTarget, error) {
+	schema, err := inst.Schema(pt.SchemaName)
+	if err == sql.ErrNoRows {
+		schema, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := ObjectFingerprints(schema)
+	pending := *pt
+	pending.Statements = []*PlanStatement{}
+	var drifted []string
+	for _, stmt := range pt.Statements {
+		key := tengo.ObjectKey{Type: stmt.ObjectType, Name: stmt.ObjectName}
+		if stmt.ResultFingerprint != "" && fingerprints[key] == stmt.ResultFingerprint {
+			continue
+		} else if fingerprints[key] != stmt.Fingerprint {
+			drifted = append(drifted, key.String())
+		}
+		pending.Statements = append(pending.Statements, stmt)
+	}
+	if len(drifted) > 0 {
+		return nil, fmt.Errorf("Definition of %s has changed since the plan was generated", strings.Join(drifted, ", "))
+	}
+	return &pending, nil
+}
+
+// Execute runs pt's statements against inst, in order, stopping at the first
+// error. The number of successfully executed statements is returned.
+func (pt *PlanTarget) Execute(inst *tengo.Instance) (executed int, err error) {
+	for _, stmt := range pt.Statements {
+		// Don't run database-level DDL in a schema
+		schemaName := pt.SchemaName
+		if stmt.ObjectType == tengo.ObjectTypeDatabase {
+			schemaName = ""
+		}
+		db, err := inst.Connect(schemaName, stmt.ConnectParams)
+		if err != nil {
+			return executed, err
+		}
+		if _, err := db.Exec(stmt.Statement); err != nil {
+			return executed, err
+		}
+		executed++
+	}
+	return executed, nil
+}
+
+// ObjectFingerprints returns a map of object keys to hashes of the objects'
+// definitions in schema, including a key for the schema itself reflecting its
+// default character set and collation. Table definitions exclude
+// AUTO_INCREMENT values, since these change as rows are inserted. A nil schema
+// results in an empty map.
+func ObjectFingerprints(schema *tengo.Schema) map[tengo.ObjectKey]string {
+	fingerprints := make(map[tengo.ObjectKey]string)
+	if schema == nil {
+		return fingerprints
+	}
+	schemaKey := tengo.ObjectKey{Type: tengo.ObjectTypeDatabase, Name: schema.Name}
+	fingerprints[schemaKey] = fingerprint(fmt.Sprintf("CHARACTER SET %s COLLATE %s", schema.CharSet, schema.Collation))
+	for key, create := range schema.ObjectDefinitions() {
+		if key.Type == tengo.ObjectTypeTable {
+			create, _ = tengo.ParseCreateAutoInc(create)
+		}
+		fingerprints[key] = fingerprint(create)
+	}
+	return fingerprints
+}
+
+var reCharsetClause = regexp.MustCompile(`(?: DEFAULT)? (?:CHARACTER SET|CHARSET)[ =]\w+| COLLATE[ =]\w+`)
+
+// isCharsetConversion returns true if td is an ALTER TABLE whose only effect
+// is changing the character set or collation of the table and/or its columns.
+func isCharsetConversion(td *tengo.TableDiff) bool {
+	if td.Type != tengo.DiffTypeAlter || td.From == nil || td.To == nil {
+		return false
+	}
+	fromCreate, _ := tengo.ParseCreateAutoInc(td.From.CreateStatement)
+	toCreate, _ := tengo.ParseCreateAutoInc(td.To.CreateStatement)
+	if fromCreate == toCreate {
+		return false
+	}
+	return reCharsetClause.ReplaceAllString(fromCreate, "") == reCharsetClause.ReplaceAllString(toCreate, "")
+}
+
+func fingerprint(definition string) string {
+	sum := sha256.Sum256([]byte(definition))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,219 @@
+package applier
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestObjectFingerprints(t *testing.T) {
+	if fingerprints := ObjectFingerprints(nil); len(fingerprints) != 0 {
+		t.Errorf("Expected nil schema to have no fingerprints, instead found %v", fingerprints)
+	}
+
+	makeSchema := func(collation, create string) *tengo.Schema {
+		return &tengo.Schema{
+			Name:      "product",
+			CharSet:   "utf8mb4",
+			Collation: collation,
+			Tables: []*tengo.Table{
+				{Name: "foo", CreateStatement: create},
+				{Name: "bar", CreateStatement: "CREATE TABLE `bar` (\n  `id` int(11) NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"},
+			},
+		}
+	}
+	fooKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "foo"}
+	barKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "bar"}
+	dbKey := tengo.ObjectKey{Type: tengo.ObjectTypeDatabase, Name: "product"}
+	fooCreate := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	fooCreateAutoInc := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=123 DEFAULT CHARSET=utf8mb4"
+
+	orig := ObjectFingerprints(makeSchema("utf8mb4_general_ci", fooCreate))
+	if len(orig) != 3 || orig[fooKey] == "" || orig[barKey] == "" || orig[dbKey] == "" {
+		t.Fatalf("Unexpected result from ObjectFingerprints: %v", orig)
+	}
+
+	// AUTO_INCREMENT changes should not affect fingerprints
+	if other := ObjectFingerprints(makeSchema("utf8mb4_general_ci", fooCreateAutoInc)); !reflect.DeepEqual(orig, other) {
+		t.Errorf("Expected AUTO_INCREMENT to be ignored, but fingerprints differ: %v vs %v", orig, other)
+	}
+
+	// Changing one object should only affect that object's fingerprint
+	other := ObjectFingerprints(makeSchema("utf8mb4_general_ci", "CREATE TABLE `foo` (\n  `id` bigint(20) NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"))
+	if other[fooKey] == orig[fooKey] || other[barKey] != orig[barKey] || other[dbKey] != orig[dbKey] {
+		t.Errorf("Unexpected fingerprints after changing one table: %v vs %v", orig, other)
+	}
+	other = ObjectFingerprints(makeSchema("utf8mb4_unicode_ci", fooCreate))
+	if other[fooKey] != orig[fooKey] || other[dbKey] == orig[dbKey] {
+		t.Errorf("Unexpected fingerprints after changing schema collation: %v vs %v", orig, other)
+	}
+}
+
+func TestPlanWriteRead(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	filePath := filepath.Join(tempDir, "plan.json")
+
+	plan := &Plan{
+		FormatVersion: PlanFormatVersion,
+		Environment:   "production",
+		Targets: []*PlanTarget{
+			{
+				Instance:   "127.0.0.1:3306",
+				SchemaName: "product",
+				Dir:        "mydb/product",
+				Statements: []*PlanStatement{
+					{
+						ObjectType:  tengo.ObjectTypeTable,
+						ObjectName:  "foo",
+						DiffType:    "DROP",
+						Unsafe:      true,
+						Fingerprint: fingerprint("CREATE TABLE foo (id int)"),
+						Statement:   "DROP TABLE `foo`",
+					},
+					{
+						ObjectType:  tengo.ObjectTypeTable,
+						ObjectName:  "bar",
+						DiffType:    "ALTER",
+						Fingerprint: fingerprint("CREATE TABLE bar (id int)"),
+						Statement:   "ALTER TABLE `bar` ADD COLUMN `name` varchar(40)",
+						Algorithm:   "INSTANT",
+						Lock:        "NONE",
+					},
+				},
+			},
+		},
+	}
+	if err := plan.Write(filePath); err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	}
+	readPlan, err := ReadPlan(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadPlan: %s", err)
+	}
+	if !reflect.DeepEqual(plan, readPlan) {
+		t.Errorf("Plan did not survive round-trip: %+v vs %+v", plan, readPlan)
+	}
+	if count := readPlan.StatementCount(); count != 2 {
+		t.Errorf("Expected StatementCount to return 2, instead found %d", count)
+	}
+
+	// Plans containing shell-out operations cannot be written
+	plan.shellOuts = []string{"127.0.0.1:3306 product table `foo`"}
+	if err := plan.Write(filePath); err == nil {
+		t.Error("Expected error writing plan with shell-out operation, but err was nil")
+	}
+
+	// Plans from an unsupported format version cannot be read
+	if err := ioutil.WriteFile(filePath, []byte(`{"format_version": 99, "targets": []}`), 0666); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if _, err := ReadPlan(filePath); err == nil {
+		t.Error("Expected error reading plan with unsupported format version, but err was nil")
+	}
+}
+
+func TestIsCharsetConversion(t *testing.T) {
+	makeTable := func(create string) *tengo.Table {
+		return &tengo.Table{Name: "foo", CreateStatement: create}
+	}
+	utf8 := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  `name` varchar(40) CHARACTER SET latin1 DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB AUTO_INCREMENT=12 DEFAULT CHARSET=utf8"
+	utf8mb4 := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  `name` varchar(40) DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+	other := "CREATE TABLE `foo` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  `name` varchar(80) DEFAULT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+	cases := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{utf8, utf8mb4, true},
+		{utf8, other, false},
+		{utf8, strings.Replace(utf8, "AUTO_INCREMENT=12", "AUTO_INCREMENT=34", 1), false},
+	}
+	for n, c := range cases {
+		td := &tengo.TableDiff{Type: tengo.DiffTypeAlter, From: makeTable(c.from), To: makeTable(c.to)}
+		if actual := isCharsetConversion(td); actual != c.expected {
+			t.Errorf("Case %d: expected isCharsetConversion to return %t, instead found %t", n, c.expected, actual)
+		}
+	}
+	if isCharsetConversion(tengo.NewCreateTable(makeTable(utf8mb4))) {
+		t.Error("Expected CREATE TABLE to not be considered a conversion")
+	}
+}
+
+func TestPlanTargetSelectTables(t *testing.T) {
+	pt := &PlanTarget{
+		SchemaName: "product",
+		Statements: []*PlanStatement{
+			{ObjectType: tengo.ObjectTypeDatabase, ObjectName: "product"},
+			{ObjectType: tengo.ObjectTypeTable, ObjectName: "posts"},
+			{ObjectType: tengo.ObjectTypeTable, ObjectName: "users"},
+			{ObjectType: tengo.ObjectTypeTable, ObjectName: "post_tags"},
+			{ObjectType: tengo.ObjectTypeProc, ObjectName: "posts_cleanup"},
+		},
+	}
+	selected := pt.SelectTables([]string{"post*", "comments"})
+	var names []string
+	for _, stmt := range selected.Statements {
+		names = append(names, stmt.ObjectName)
+	}
+	if actual := strings.Join(names, ","); actual != "product,posts,post_tags,posts_cleanup" {
+		t.Errorf("Unexpected statements selected: %s", actual)
+	}
+	if len(pt.Statements) != 5 || selected.SchemaName != pt.SchemaName {
+		t.Errorf("Unexpected modification of original target, or bad copy: %+v vs %+v", pt, selected)
+	}
+}
+
+func TestPlanWriteConversionOrder(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	filePath := filepath.Join(tempDir, "plan.json")
+
+	plan := &Plan{
+		FormatVersion:     PlanFormatVersion,
+		Environment:       "production",
+		CharsetConversion: true,
+		Targets: []*PlanTarget{
+			{
+				Instance:   "127.0.0.1:3306",
+				SchemaName: "product",
+				Statements: []*PlanStatement{
+					{ObjectType: tengo.ObjectTypeTable, ObjectName: "big", Conversion: true, TableSize: 9000},
+					{ObjectType: tengo.ObjectTypeTable, ObjectName: "new", DiffType: "CREATE"},
+					{ObjectType: tengo.ObjectTypeTable, ObjectName: "small", Conversion: true, TableSize: 100},
+					{ObjectType: tengo.ObjectTypeDatabase, ObjectName: "product", ResultFingerprint: fingerprint("CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci")},
+					{ObjectType: tengo.ObjectTypeTable, ObjectName: "empty", Conversion: true},
+					{ObjectType: tengo.ObjectTypeTable, ObjectName: "medium", Conversion: true, TableSize: 100},
+				},
+			},
+		},
+	}
+	if err := plan.Write(filePath); err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	}
+	readPlan, err := ReadPlan(filePath)
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadPlan: %s", err)
+	}
+	if !reflect.DeepEqual(plan, readPlan) {
+		t.Errorf("Plan did not survive round-trip: %+v vs %+v", plan, readPlan)
+	}
+	var names []string
+	for _, stmt := range readPlan.Targets[0].Statements {
+		names = append(names, stmt.ObjectName)
+	}
+	if actual := strings.Join(names, ","); actual != "product,new,empty,small,medium,big" {
+		t.Errorf("Unexpected statement order in conversion plan: %s", actual)
+	}
+}
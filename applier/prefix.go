@@ -0,0 +1,99 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// reOutputPrefixVar matches variable placeholders of format "{VARNAME}" in the
+// output-prefix and script-out options.
+var reOutputPrefixVar = regexp.MustCompile(`{([^}]*)}`)
+
+// outputPrefix returns the identifier which should prefix output lines and log
+// messages for t, obtained by interpolating the output-prefix option. If
+// output-prefix is not in use, targets supplied by the targets option are
+// prefixed by their spec identifier in square brackets, and a blank string is
+// returned for all other targets. An error is returned if the option contains
+// any unknown variable placeholders.
+func (t *Target) outputPrefix() (string, error) {
+	var template string
+	if t.Dir.Config.FindOption("output-prefix") != nil {
+		template = strings.TrimSpace(t.Dir.Config.Get("output-prefix"))
+	}
+	if template == "" && t.specID != "" {
+		return "[" + t.specID + "]", nil
+	} else if template == "" {
+		return "", nil
+	}
+	return t.expandVariables("output-prefix", template)
+}
+
+// expandVariables interpolates the "{VARNAME}" placeholders in template, which
+// is the value of the named option, using information about t. An error is
+// returned if template contains any unknown variable placeholders.
+func (t *Target) expandVariables(optionName, template string) (string, error) {
+	var port string
+	if t.Instance.SocketPath == "" {
+		port = strconv.Itoa(t.Instance.Port)
+	}
+	variables := map[string]string{
+		"HOST":        t.Instance.Host,
+		"PORT":        port,
+		"SOCKET":      t.Instance.SocketPath,
+		"SCHEMA":      t.SchemaName,
+		"ENVIRONMENT": t.Dir.Config.Get("environment"),
+		"DIRNAME":     t.Dir.BaseName(),
+		"DIRPATH":     t.Dir.Path,
+		"TARGET":      t.specID,
+	}
+	var err error
+	result := reOutputPrefixVar.ReplaceAllStringFunc(template, func(input string) string {
+		value, ok := variables[strings.ToUpper(input[1:len(input)-1])]
+		if !ok {
+			err = fmt.Errorf("Option %s contains unknown variable %s", optionName, input)
+			return input
+		}
+		return value
+	})
+	return result, err
+}
+
+// logger returns a log entry for messages pertaining to t. If output-prefix is
+// in use, the entry carries the prefix in its "prefix" field, which the
+// skeema log formatter places in front of the message.
+func (t *Target) logger() *log.Entry {
+	if prefix, _ := t.outputPrefix(); prefix != "" {
+		return log.WithField("prefix", prefix)
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// prefixOutput returns s with prefix inserted into its lines in a manner that
+// keeps the output valid SQL. s should consist of zero or more comment lines,
+// optionally followed by a single statement or shell-out. Comment lines have
+// prefix inserted after the comment marker. The first line of the statement is
+// preceded by prefix in a block comment, with the exception of shell-outs,
+// which must begin their line; these are always preceded by a tag comment line
+// though. Any subsequent lines of a multi-line statement are left as-is, since
+// they may be within a string literal and since Printer never interleaves
+// statements anyway.
+func prefixOutput(s, prefix string) string {
+	if prefix == "" || s == "" {
+		return s
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for n, line := range lines {
+		if strings.HasPrefix(line, "-- ") {
+			lines[n] = "-- " + prefix + " " + line[3:]
+			continue
+		} else if line != "" && !strings.HasPrefix(line, `\!`) {
+			lines[n] = "/* " + strings.Replace(prefix, "*/", "* /", -1) + " */ " + line
+		}
+		break
+	}
+	return strings.Join(lines, "")
+}
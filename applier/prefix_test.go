@@ -0,0 +1,88 @@
+package applier
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTargetOutputPrefix(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	if prefix, err := target.outputPrefix(); prefix != "" || err != nil {
+		t.Errorf("Expected blank prefix by default, instead found %q, %v", prefix, err)
+	}
+	if entry := target.logger(); len(entry.Data) != 0 {
+		t.Errorf("Expected logger without prefix to have no fields, instead found %v", entry.Data)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--output-prefix='[{HOST}:{port}/{SCHEMA} {dirname}]'")
+	expected := "[127.0.0.1:3306/product one]"
+	if prefix, err := target.outputPrefix(); prefix != expected || err != nil {
+		t.Errorf("Expected prefix %q, instead found %q, %v", expected, prefix, err)
+	}
+	if entry := target.logger(); entry.Data["prefix"] != expected {
+		t.Errorf("Expected logger to have prefix field %q, instead found %v", expected, entry.Data)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--output-prefix='[{HOST}:{SHARD}]'")
+	if _, err := target.outputPrefix(); err == nil {
+		t.Error("Expected error from unknown variable in output-prefix, but err was nil")
+	}
+	if _, err := applyTarget(context.Background(), target, NewPrinter(false)); err == nil {
+		t.Error("Expected error from applyTarget with invalid output-prefix, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok {
+		t.Errorf("Expected ConfigError, instead found %T: %s", err, err)
+	}
+}
+
+func TestPrefixOutput(t *testing.T) {
+	cases := map[string]string{
+		"":                                     "",
+		"-- instance: db3:3306\n":              "-- [db3:app] instance: db3:3306\n",
+		"USE `app`;\n":                         "/* [db3:app] */ USE `app`;\n",
+		"-- note\n/* [alter] */ ALTER;\n":      "-- [db3:app] note\n/* [db3:app] */ /* [alter] */ ALTER;\n",
+		"CREATE TABLE `t` (\n  `id` int\n);\n": "/* [db3:app] */ CREATE TABLE `t` (\n  `id` int\n);\n",
+		"-- [alter]\n\\! pt-osc --alter 'x'\n": "-- [db3:app] [alter]\n\\! pt-osc --alter 'x'\n",
+	}
+	for input, expected := range cases {
+		if actual := prefixOutput(input, "[db3:app]"); actual != expected {
+			t.Errorf("Expected prefixOutput(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+	if actual := prefixOutput("USE `app`;\n", ""); actual != "USE `app`;\n" {
+		t.Errorf("Expected blank prefix to leave output unchanged, instead found %q", actual)
+	}
+	if actual := prefixOutput("USE `app`;\n", "*/ DROP"); actual != "/* * / DROP */ USE `app`;\n" {
+		t.Errorf("Expected block comment terminator in prefix to be neutralized, instead found %q", actual)
+	}
+}
+
+func TestPrinterOutputPrefix(t *testing.T) {
+	target, ddls := getFormatTestDDL(t)
+	target.Dir = getDir(t, "testdata/simple/one", "--output-prefix='[{HOST}:{SCHEMA}]'")
+	var buf bytes.Buffer
+	printer := NewPrinter(false)
+	printer.out = &buf
+	printer.printSummary(target, ddls)
+	for _, ddl := range ddls {
+		printer.printDDL(ddl)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if line == "" || strings.HasPrefix(line, "  ") || strings.HasPrefix(line, ")") {
+			continue // blank lines, or continuation lines of the multi-line CREATE TABLE
+		}
+		if !strings.HasPrefix(line, "-- [127.0.0.1:product] ") && !strings.HasPrefix(line, "/* [127.0.0.1:product] */ ") {
+			t.Errorf("Output line lacks expected prefix: %q", line)
+		}
+	}
+
+	// Brief mode output is unaffected
+	buf.Reset()
+	printer = NewPrinter(true)
+	printer.out = &buf
+	printer.printDDL(ddls[0])
+	if actual := buf.String(); actual != "127.0.0.1:3306\n" {
+		t.Errorf("Unexpected brief mode output: %q", actual)
+	}
+}
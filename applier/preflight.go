@@ -0,0 +1,186 @@
+package applier
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+// PreflightError indicates that an instance failed a safety check prior to
+// any DDL being executed on it.
+type PreflightError string
+
+// Error satisfies the builtin error interface.
+func (pe PreflightError) Error() string {
+	return string(pe)
+}
+
+// preflightCheck confirms that it is safe to run DDL on t's instance, based on
+// the configuration of t's dir. It returns a PreflightError if a check fails,
+// or some other error if the check could not be performed at all. Since all
+// targets in a TargetGroup share an instance, this only needs to be called
+// once per TargetGroup.
+func preflightCheck(t *Target) error {
+	if err := checkDDLCredentials(t); err != nil {
+		return err
+	}
+	if !t.Dir.Config.GetBool("allow-read-only") {
+		if err := checkReadOnly(t.Instance); err != nil {
+			return err
+		}
+	}
+	replicas := t.Dir.Config.GetSlice("replicas", ',', true)
+	if len(replicas) == 0 {
+		return nil
+	}
+	maxLag, err := util.ParseTimeout("max-replica-lag", t.Dir.Config.Get("max-replica-lag"))
+	if err != nil {
+		return ConfigError(err.Error())
+	}
+	params, err := t.Dir.InstanceDefaultParams()
+	if err != nil {
+		return ConfigError(err.Error())
+	}
+	for _, replica := range replicas {
+		if err := checkReplicaLag(t.Instance, replica, params, maxLag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDDLCredentials confirms that t's DDL credentials, if configured
+// separately via ddl-user, are able to connect to t's instance. The regular
+// credentials are only used for introspection, so any problem with them is
+// reported when introspecting each target. A ConfigError is returned if
+// ddl-user is set without ddl-password.
+func checkDDLCredentials(t *Target) error {
+	ddlInstance, err := t.Dir.DDLInstance(t.Instance)
+	if err != nil {
+		return ConfigError(err.Error())
+	} else if ddlInstance == t.Instance {
+		return nil
+	}
+	if ok, err := ddlInstance.CanConnect(); !ok {
+		return PreflightError(fmt.Sprintf("Unable to connect to %s using ddl-user %s: %s", ddlInstance, ddlInstance.User, err))
+	}
+	return nil
+}
+
+// checkReadOnly returns a PreflightError if inst has read_only or
+// super_read_only enabled, which typically indicates that it is a replica
+// rather than the intended target of DDL.
+func checkReadOnly(inst *tengo.Instance) error {
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return err
+	}
+	var readOnly bool
+	if err := db.QueryRow("SELECT @@global.read_only").Scan(&readOnly); err != nil {
+		return err
+	} else if readOnly {
+		return PreflightError(fmt.Sprintf("%s has read_only enabled, indicating it may be a replica. Use --allow-read-only to push anyway.", inst))
+	}
+
+	// super_read_only only exists in MySQL 5.7.8+ and Percona Server 5.6.21+
+	var name, value string
+	err = db.QueryRow("SHOW GLOBAL VARIABLES LIKE 'super_read_only'").Scan(&name, &value)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	} else if strings.EqualFold(value, "ON") {
+		return PreflightError(fmt.Sprintf("%s has super_read_only enabled, indicating it may be a replica. Use --allow-read-only to push anyway.", inst))
+	}
+	return nil
+}
+
+// checkReplicaLag connects to the supplied replica hostname (optionally
+// including a port), using the same credentials as primary, and returns a
+// PreflightError if its replication is not running or is lagging by more than
+// maxLag. A maxLag of 0 only requires replication to be running.
+func checkReplicaLag(primary *tengo.Instance, replica, params string, maxLag time.Duration) error {
+	host, port, err := tengo.SplitHostOptionalPort(replica)
+	if err != nil {
+		return ConfigError(fmt.Sprintf("Invalid value for option replicas: %s", err))
+	} else if port == 0 {
+		port = 3306
+	}
+	// Replicas are reached the same way as primary, for example through the same
+	// SSH tunnel, unless primary uses a socket
+	network := "tcp"
+	addr := primary.BaseDSN[strings.LastIndex(primary.BaseDSN, "@")+1:]
+	if paren := strings.Index(addr, "("); paren > 0 && addr[:paren] != "unix" {
+		network = addr[:paren]
+	}
+	dsn := fmt.Sprintf("%s:%s@%s(%s:%d)/?%s", primary.User, primary.Password, network, host, port, params)
+	inst, err := util.NewInstance("mysql", dsn)
+	if err != nil {
+		return ConfigError(fmt.Sprintf("Invalid value for option replicas: %s", err))
+	}
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return fmt.Errorf("Unable to check replication lag of %s: %s", inst, err)
+	}
+
+	// SHOW SLAVE STATUS was renamed in MySQL 8.0.22, along with several of its
+	// output columns
+	rows, err := db.Query("SHOW REPLICA STATUS")
+	if err != nil {
+		if rows, err = db.Query("SHOW SLAVE STATUS"); err != nil {
+			return fmt.Errorf("Unable to check replication lag of %s: %s", inst, err)
+		}
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("Unable to check replication lag of %s: %s", inst, err)
+		}
+		return PreflightError(fmt.Sprintf("Option replicas includes %s, but it is not configured as a replica", inst))
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	vals := make([]sql.RawBytes, len(cols))
+	dest := make([]interface{}, len(cols))
+	for n := range vals {
+		dest[n] = &vals[n]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	lag, running := secondsBehind(cols, vals)
+	if !running {
+		return PreflightError(fmt.Sprintf("Replication is not running on replica %s", inst))
+	} else if maxLag > 0 && lag > maxLag {
+		return PreflightError(fmt.Sprintf("Replica %s is lagging by %s, exceeding max-replica-lag of %s", inst, lag, maxLag))
+	}
+	log.Debugf("Replica %s is lagging by %s", inst, lag)
+	return nil
+}
+
+// secondsBehind examines a row of SHOW REPLICA STATUS or SHOW SLAVE STATUS
+// output, returning the replication lag, and whether replication is running.
+// The server reports a NULL lag when the replication SQL thread is stopped or
+// the IO thread is not connected.
+func secondsBehind(cols []string, vals []sql.RawBytes) (lag time.Duration, running bool) {
+	for n, col := range cols {
+		if col != "Seconds_Behind_Source" && col != "Seconds_Behind_Master" {
+			continue
+		}
+		if vals[n] == nil {
+			return 0, false
+		}
+		seconds, err := strconv.Atoi(string(vals[n]))
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	return 0, false
+}
@@ -0,0 +1,39 @@
+package applier
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSecondsBehind(t *testing.T) {
+	cases := []struct {
+		cols            []string
+		vals            []sql.RawBytes
+		expectedLag     time.Duration
+		expectedRunning bool
+	}{
+		{[]string{"Replica_IO_State", "Seconds_Behind_Source"}, []sql.RawBytes{sql.RawBytes("Waiting"), sql.RawBytes("0")}, 0, true},
+		{[]string{"Slave_IO_State", "Seconds_Behind_Master"}, []sql.RawBytes{sql.RawBytes("Waiting"), sql.RawBytes("125")}, 125 * time.Second, true},
+		{[]string{"Slave_IO_State", "Seconds_Behind_Master"}, []sql.RawBytes{sql.RawBytes(""), nil}, 0, false},
+		{[]string{"Slave_IO_State", "Seconds_Behind_Master"}, []sql.RawBytes{sql.RawBytes(""), sql.RawBytes("borked")}, 0, false},
+		{[]string{"Slave_IO_State"}, []sql.RawBytes{sql.RawBytes("")}, 0, false},
+	}
+	for n, c := range cases {
+		lag, running := secondsBehind(c.cols, c.vals)
+		if lag != c.expectedLag || running != c.expectedRunning {
+			t.Errorf("Case %d: expected secondsBehind to return %s,%t; instead found %s,%t", n, c.expectedLag, c.expectedRunning, lag, running)
+		}
+	}
+}
+
+func TestPreflightGroupConfigError(t *testing.T) {
+	dir := getDir(t, "testdata/simple/one", "--allow-read-only --replicas=replica1.example.com --max-replica-lag=-5s")
+	target := &Target{Dir: dir, SchemaName: "product"}
+	results := make(chan Result, 1)
+	passed, err := preflightGroup(context.Background(), TargetGroup{target}, results)
+	if _, ok := err.(ConfigError); !ok || passed {
+		t.Errorf("Expected preflightGroup to return false and a ConfigError, instead found %t, %v", passed, err)
+	}
+}
@@ -2,9 +2,11 @@ package applier
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
 	"sync"
-
-	"github.com/skeema/tengo"
 )
 
 // Printer is capable of sending output to STDOUT in a readable manner despite
@@ -14,6 +16,12 @@ type Printer struct {
 	lastStdoutInstance string
 	lastStdoutSchema   string
 	seenInstance       map[string]bool
+	plan               *Plan
+	scripts            *ScriptSet
+	annotate           bool
+	useColor           bool
+	samples            map[*Target]*sampledOutput // non-nil if collapsing identical output of targets
+	out                io.Writer
 	*sync.Mutex
 }
 
@@ -25,10 +33,60 @@ func NewPrinter(briefMode bool) *Printer {
 	return &Printer{
 		briefOutput:  briefMode,
 		seenInstance: make(map[string]bool),
+		out:          os.Stdout,
 		Mutex:        new(sync.Mutex),
 	}
 }
 
+// Annotate controls whether p precedes each target's DDL with a summary of
+// its changes, and prefixes each statement with a tag comment. This is
+// disabled by default.
+func (p *Printer) Annotate(enabled bool) {
+	p.annotate = enabled
+}
+
+// UseColor controls whether p's annotations include ANSI color codes. This has
+// no effect unless annotations are enabled. Callers should only enable this if
+// STDOUT is a terminal.
+func (p *Printer) UseColor(enabled bool) {
+	p.useColor = enabled
+}
+
+// color returns true if p's output should include ANSI color codes.
+func (p *Printer) color() bool {
+	return p.annotate && p.useColor
+}
+
+// CollapseSamples controls whether p collapses identical output of different
+// targets. If enabled, output is buffered rather than printed immediately; a
+// subsequent call to PrintSamples prints the output of each group of targets
+// having identical differences just once, along with a list of the targets in
+// the group. This has no effect in brief mode.
+func (p *Printer) CollapseSamples(enabled bool) {
+	if enabled && !p.briefOutput {
+		p.samples = make(map[*Target]*sampledOutput)
+	} else {
+		p.samples = nil
+	}
+}
+
+// SetOutput changes where p sends its output, which is STDOUT by default.
+func (p *Printer) SetOutput(w io.Writer) {
+	p.out = w
+}
+
+// RecordPlan causes all DDL subsequently printed by p to also be recorded in
+// plan.
+func (p *Printer) RecordPlan(plan *Plan) {
+	p.plan = plan
+}
+
+// RecordScripts causes all DDL subsequently printed by p for targets using the
+// script-out option to also be recorded in scripts.
+func (p *Printer) RecordScripts(scripts *ScriptSet) {
+	p.scripts = scripts
+}
+
 // printDDL outputs DDLStatement values to STDOUT in a way that prevents
 // interleaving of output from multiple workers.
 // TODO: buffer output from external commands and also prevent interleaving there
@@ -36,25 +94,144 @@ func (p *Printer) printDDL(ddl *DDLStatement) {
 	p.Lock()
 	defer p.Unlock()
 	instString := ddl.instance.String()
+	if p.plan != nil {
+		p.plan.addStatement(ddl)
+	}
+	if p.scripts != nil {
+		p.scripts.addStatement(ddl)
+	}
 
 	// Support diff --brief, which only outputs instances that have differences,
 	// rather than outputting the actual differences
 	if p.briefOutput {
 		if _, already := p.seenInstance[instString]; !already {
-			fmt.Printf("%s\n", instString)
+			fmt.Fprintf(p.out, "%s\n", instString)
 			p.seenInstance[instString] = true
 		}
 		return
 	}
 
+	if p.samples != nil && ddl.target != nil {
+		p.sampleFor(ddl.target).body.WriteString(formatDDL(ddl, p.annotate, p.color()))
+		return
+	}
+
+	var prefix string
+	if ddl.target != nil {
+		prefix, _ = ddl.target.outputPrefix()
+	}
+	p.printInstanceHeader(instString, prefix)
+	if ddl.schemaName != p.lastStdoutSchema && ddl.schemaName != "" {
+		fmt.Fprint(p.out, prefixOutput(formatUse(ddl.schemaName), prefix))
+		p.lastStdoutSchema = ddl.schemaName
+	}
+	fmt.Fprint(p.out, prefixOutput(formatDDL(ddl, p.annotate, p.color()), prefix))
+}
+
+// printSummary outputs a summary of all DDL for a single target, prior to
+// printDDL being called for each statement. This has no effect in brief mode,
+// or if annotations are disabled.
+// Output lines of both methods are prefixed according to the target's
+// output-prefix option, if set.
+func (p *Printer) printSummary(t *Target, ddls []*DDLStatement) {
+	if p.briefOutput || !p.annotate || len(ddls) == 0 {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	if p.samples != nil {
+		p.sampleFor(t).summary = formatSummary(t.Dir.RelPath(), "", ddls, p.color())
+		return
+	}
+	prefix, _ := t.outputPrefix()
+	p.printInstanceHeader(t.Instance.String(), prefix)
+	fmt.Fprint(p.out, prefixOutput(formatSummary(t.Dir.RelPath(), t.SchemaName, ddls, p.color()), prefix))
+}
+
+// printInstanceHeader outputs a header line for instString, if the previous
+// output was for a different instance. The header includes prefix, if non-
+// blank. The caller must hold p's lock.
+func (p *Printer) printInstanceHeader(instString, prefix string) {
 	if instString != p.lastStdoutInstance {
-		fmt.Printf("-- instance: %s\n", instString)
+		fmt.Fprint(p.out, prefixOutput(formatInstanceHeader(instString, p.color()), prefix))
 		p.lastStdoutInstance = instString
 		p.lastStdoutSchema = ""
 	}
-	if ddl.schemaName != p.lastStdoutSchema && ddl.schemaName != "" {
-		fmt.Printf("USE %s;\n", tengo.EscapeIdentifier(ddl.schemaName))
-		p.lastStdoutSchema = ddl.schemaName
+}
+
+// sampledOutput is the buffered output of a single target, when collapsing
+// identical output of targets.
+type sampledOutput struct {
+	target  *Target
+	summary string
+	body    strings.Builder
+}
+
+// key returns a string which is identical for targets whose output should be
+// collapsed together.
+func (so *sampledOutput) key() string {
+	return so.target.Dir.Path + "\x00" + so.summary + so.body.String()
+}
+
+// sampleFor returns the buffered output for t, creating it if necessary. The
+// caller must hold p's lock.
+func (p *Printer) sampleFor(t *Target) *sampledOutput {
+	so := p.samples[t]
+	if so == nil {
+		so = &sampledOutput{target: t}
+		p.samples[t] = so
+	}
+	return so
+}
+
+// PrintSamples prints all output buffered since CollapseSamples was enabled,
+// and then clears the buffer. Targets of the same dir having identical
+// differences are grouped together, and each group's output is printed once,
+// preceded by a list of the group's targets. Groups are printed in order of
+// their first target, and targets are listed sorted by host and then schema.
+// Output-prefix templates are not used, since the output may apply to many
+// targets.
+func (p *Printer) PrintSamples() {
+	p.Lock()
+	defer p.Unlock()
+	groups := make(map[string][]*sampledOutput)
+	for _, so := range p.samples {
+		groups[so.key()] = append(groups[so.key()], so)
+	}
+	sorted := make([][]*sampledOutput, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			return targetLess(group[i].target, group[j].target)
+		})
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return targetLess(sorted[i][0].target, sorted[j][0].target)
+	})
+	for _, group := range sorted {
+		first := group[0]
+		fmt.Fprint(p.out, formatSampleHeader(group, p.color()))
+		fmt.Fprint(p.out, first.summary)
+		if first.target.SchemaName != "" {
+			fmt.Fprint(p.out, formatUse(first.target.SchemaName))
+		}
+		fmt.Fprint(p.out, first.body.String())
+	}
+	p.samples = make(map[*Target]*sampledOutput)
+	p.lastStdoutInstance, p.lastStdoutSchema = "", ""
+}
+
+// formatSampleHeader returns header lines listing the targets of group, which
+// all have identical differences.
+func formatSampleHeader(group []*sampledOutput, useColor bool) string {
+	if len(group) == 1 {
+		return formatInstanceHeader(group[0].target.Instance.String(), useColor)
+	}
+	var b strings.Builder
+	header := fmt.Sprintf("%d targets with identical differences:", len(group))
+	fmt.Fprintf(&b, "-- %s\n", colorize(header, colorBold, useColor))
+	for _, so := range group {
+		fmt.Fprintf(&b, "--   %s %s\n", so.target.Instance, so.target.SchemaName)
 	}
-	fmt.Print(ddl.String())
+	return b.String()
 }
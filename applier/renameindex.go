@@ -0,0 +1,132 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// renameIndex is a clause renaming a secondary index, used in place of a
+// DropIndex and AddIndex pair whose indexes differ only in name. It satisfies
+// the tengo.TableAlterClause interface.
+type renameIndex struct {
+	From *tengo.Index
+	To   *tengo.Index
+}
+
+// Clause returns a RENAME KEY clause of an ALTER TABLE statement.
+func (ri renameIndex) Clause(_ tengo.StatementModifiers) string {
+	return fmt.Sprintf("RENAME KEY %s TO %s", tengo.EscapeIdentifier(ri.From.Name), tengo.EscapeIdentifier(ri.To.Name))
+}
+
+// supportsRenameIndex returns true if flavor supports ALTER TABLE ... RENAME
+// KEY, which was added in MySQL 5.7 and MariaDB 10.5.
+func supportsRenameIndex(flavor tengo.Flavor) bool {
+	return flavor.MySQLishMinVersion(5, 7) || flavor.VendorMinVersion(tengo.VendorMariaDB, 10, 5)
+}
+
+// indexRenames returns the pairs of DropIndex and AddIndex clauses in clauses
+// which may be combined into a renameIndex: the indexes must be secondary
+// indexes with identical definitions and comments, the dropped name must not be
+// retained by td.To, and the added name must not already exist in td.From. Each
+// index is paired at most once. Nothing is returned if mods.Flavor does not
+// support renaming indexes, or if index order is significant, since renaming
+// does not change an index's position.
+func indexRenames(clauses []tengo.TableAlterClause, td *tengo.TableDiff, mods tengo.StatementModifiers) (renames []renameIndex) {
+	if !supportsRenameIndex(mods.Flavor) || mods.StrictIndexOrder || td.To.ClusteredIndexKey() != td.To.PrimaryKey {
+		return nil
+	}
+	fromIndexes, toIndexes := td.From.SecondaryIndexesByName(), td.To.SecondaryIndexesByName()
+	paired := make(map[string]bool)
+	for _, clause := range clauses {
+		drop, ok := clause.(tengo.DropIndex)
+		if !ok || drop.Index.PrimaryKey || toIndexes[drop.Index.Name] != nil || drop.Clause(mods) == "" {
+			continue
+		}
+		for _, other := range clauses {
+			add, ok := other.(tengo.AddIndex)
+			if !ok || paired[add.Index.Name] || fromIndexes[add.Index.Name] != nil || add.Clause(mods) == "" {
+				continue
+			}
+			if add.Index.Comment == drop.Index.Comment && add.Index.Equivalent(drop.Index) {
+				renames = append(renames, renameIndex{From: drop.Index, To: add.Index})
+				paired[add.Index.Name] = true
+				break
+			}
+		}
+	}
+	return renames
+}
+
+// withIndexRenames returns a copy of clauses in which each pair found by
+// indexRenames is replaced by a renameIndex, in the position of the DropIndex.
+func withIndexRenames(clauses []tengo.TableAlterClause, td *tengo.TableDiff, mods tengo.StatementModifiers) []tengo.TableAlterClause {
+	renames := indexRenames(clauses, td, mods)
+	if len(renames) == 0 {
+		return clauses
+	}
+	renamedFrom := make(map[string]renameIndex, len(renames))
+	renamedTo := make(map[string]bool, len(renames))
+	for _, ri := range renames {
+		renamedFrom[ri.From.Name] = ri
+		renamedTo[ri.To.Name] = true
+	}
+	result := make([]tengo.TableAlterClause, 0, len(clauses)-len(renames))
+	for _, clause := range clauses {
+		switch clause := clause.(type) {
+		case tengo.DropIndex:
+			if ri, ok := renamedFrom[clause.Index.Name]; ok {
+				result = append(result, ri)
+				continue
+			}
+		case tengo.AddIndex:
+			if renamedTo[clause.Index.Name] {
+				continue
+			}
+		}
+		result = append(result, clause)
+	}
+	return result
+}
+
+// fixIndexRenames adjusts an ALTER TABLE statement generated by tengo for td,
+// replacing each DROP KEY and ADD KEY pair found by indexRenames with a single
+// RENAME KEY clause. This permits the server to rename the index as a
+// metadata-only change, rather than rebuilding it. If stmt is not of the
+// expected form, it is returned as-is.
+func fixIndexRenames(stmt string, td *tengo.TableDiff, mods tengo.StatementModifiers) string {
+	clauses, supported := tableDiffClauses(td)
+	if !supported {
+		return stmt
+	}
+	for _, ri := range indexRenames(clauses, td, mods) {
+		dropClause := tengo.DropIndex{Index: ri.From}.Clause(mods)
+		addClause := ", " + tengo.AddIndex{Index: ri.To}.Clause(mods)
+		if strings.Count(stmt, dropClause) != 1 || strings.Count(stmt, addClause) != 1 {
+			continue
+		}
+		stmt = strings.Replace(stmt, dropClause, ri.Clause(mods), 1)
+		stmt = strings.Replace(stmt, addClause, "", 1)
+	}
+	return stmt
+}
+
+// replacesUniqueIndex returns true if clauses drop a unique secondary index and
+// add an equivalent one, for example due to renaming it on a flavor which does
+// not support RENAME KEY. Such clauses must be executed in the same ALTER
+// TABLE, so that the uniqueness constraint is never absent.
+func replacesUniqueIndex(clauses []tengo.TableAlterClause) bool {
+	for _, clause := range clauses {
+		drop, ok := clause.(tengo.DropIndex)
+		if !ok || !drop.Index.Unique || drop.Index.PrimaryKey {
+			continue
+		}
+		for _, other := range clauses {
+			if add, ok := other.(tengo.AddIndex); ok && add.Index.Equivalent(drop.Index) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// renameIndexTestTable returns a table with a unique index on its email column,
+// and a non-unique index on its name column, using the supplied index names.
+func renameIndexTestTable(emailIndexName, nameIndexName string) *tengo.Table {
+	table := brokenFKTestTable("users")
+	email := &tengo.Column{Name: "email", TypeInDB: "varchar(100)", Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"}
+	name := &tengo.Column{Name: "name", TypeInDB: "varchar(100)", Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"}
+	table.Columns = append(table.Columns, email, name)
+	table.SecondaryIndexes = []*tengo.Index{
+		{Name: emailIndexName, Columns: []*tengo.Column{email}, SubParts: []uint16{0}, Unique: true},
+		{Name: nameIndexName, Columns: []*tengo.Column{name}, SubParts: []uint16{0}},
+	}
+	table.CreateStatement = table.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	return table
+}
+
+func TestFixIndexRenames(t *testing.T) {
+	from := renameIndexTestTable("email", "name")
+	to := renameIndexTestTable("uniq_email", "idx_name")
+	td := tengo.NewAlterTable(from, to)
+	mods := tengo.StatementModifiers{Flavor: tengo.FlavorMySQL80}
+	stmt, err := td.Statement(mods)
+	if err != nil {
+		t.Fatalf("Unexpected error from Statement: %v", err)
+	}
+	expected := "ALTER TABLE `users` RENAME KEY `email` TO `uniq_email`, RENAME KEY `name` TO `idx_name`"
+	if actual := fixIndexRenames(stmt, td, mods); actual != expected {
+		t.Errorf("Expected statement %q, instead found %q", expected, actual)
+	}
+	clauses, _ := tableDiffClauses(td)
+	if renamed := withIndexRenames(clauses, td, mods); len(renamed) != 2 {
+		t.Errorf("Expected 2 clauses, instead found %d", len(renamed))
+	} else if est := estimateAlter(td, renamed, mods, mysqlVersion(8, 0, 30), false); est != estInplace {
+		t.Errorf("Expected estimate %s, instead found %s", estInplace, est)
+	}
+	mariadbMods := tengo.StatementModifiers{Flavor: tengo.Flavor{Vendor: tengo.VendorMariaDB, Major: 10, Minor: 6}}
+	if est := estimateAlter(td, withIndexRenames(clauses, td, mariadbMods), mariadbMods, mariadbVersion(10, 6, 0), false); est != estInstant {
+		t.Errorf("Expected estimate %s, instead found %s", estInstant, est)
+	}
+
+	// Flavors without RENAME KEY support, and diffs with strict index order,
+	// retain the original DROP KEY and ADD KEY clauses
+	for _, mods := range []tengo.StatementModifiers{
+		{Flavor: tengo.FlavorMySQL56},
+		{Flavor: tengo.FlavorMariaDB103},
+		{Flavor: tengo.FlavorMySQL80, StrictIndexOrder: true},
+	} {
+		stmt, _ := td.Statement(mods)
+		if actual := fixIndexRenames(stmt, td, mods); actual != stmt {
+			t.Errorf("Expected statement with %+v to be unchanged, instead found %q", mods, actual)
+		} else if renamed := withIndexRenames(clauses, td, mods); len(renamed) != len(clauses) {
+			t.Errorf("Expected clauses with %+v to be unchanged, instead found %d", mods, len(renamed))
+		}
+	}
+
+	// Indexes which differ in anything besides name are not renamed
+	changed := renameIndexTestTable("uniq_email", "idx_name")
+	changed.SecondaryIndexes[0].Unique = false
+	changed.SecondaryIndexes[1].Comment = "hello"
+	changed.CreateStatement = changed.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	td = tengo.NewAlterTable(from, changed)
+	stmt, _ = td.Statement(mods)
+	if actual := fixIndexRenames(stmt, td, mods); actual != stmt || strings.Contains(actual, "RENAME") {
+		t.Errorf("Expected statement to be unchanged, instead found %q", actual)
+	}
+
+	// Swapping names of equivalent indexes is not a rename, since the added
+	// names already exist
+	swapped := renameIndexTestTable("email", "name")
+	swapped.SecondaryIndexes[1].Columns, swapped.SecondaryIndexes[0].Columns = swapped.SecondaryIndexes[0].Columns, swapped.SecondaryIndexes[1].Columns
+	swapped.CreateStatement = swapped.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	td = tengo.NewAlterTable(from, swapped)
+	clauses, _ = tableDiffClauses(td)
+	if renames := indexRenames(clauses, td, mods); len(renames) != 0 {
+		t.Errorf("Expected no renames, instead found %+v", renames)
+	}
+}
+
+func TestSplitClausesIndexRenames(t *testing.T) {
+	from := renameIndexTestTable("email", "name")
+	to := renameIndexTestTable("uniq_email", "idx_name")
+	to.Comment = "hello"
+	to.CreateStatement = to.GeneratedCreateStatement(tengo.FlavorMySQL57)
+
+	// With RENAME KEY support, each rename may be run separately
+	mods := tengo.StatementModifiers{Flavor: tengo.FlavorMySQL80}
+	td := tengo.NewAlterTable(from, to)
+	ddl := newSplitTestDDL(t, td, mods)
+	ddl.stmt = fixIndexRenames(ddl.stmt, td, mods)
+	if pieces := ddl.splitClauses(mods); len(pieces) != 3 {
+		t.Errorf("Expected split into 3 statements, instead found %d", len(pieces))
+	} else if _, ok := pieces[0].clause.(renameIndex); !ok {
+		t.Errorf("Expected first piece to be a renameIndex, instead found %T", pieces[0].clause)
+	}
+
+	// Without RENAME KEY support, the unique index must be dropped and re-added
+	// in the same statement
+	mods.Flavor = tengo.FlavorMySQL56
+	ddl = newSplitTestDDL(t, tengo.NewAlterTable(from, to), mods)
+	if pieces := ddl.splitClauses(mods); len(pieces) != 1 {
+		t.Errorf("Expected statement to remain unsplit, instead found %d statements", len(pieces))
+	}
+}
@@ -0,0 +1,136 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// maxRowSize is the server's limit on the combined size of all columns of a
+// row, in bytes, regardless of storage engine. BLOB and TEXT columns only
+// contribute a small pointer towards this limit.
+const maxRowSize = 65535
+
+var reColumnType = regexp.MustCompile(`^(\w+)(?:\((\d+)(?:,(\d+))?\))?`)
+
+// rowSizeNote examines an ALTER TABLE diff for column changes which cause the
+// table's maximum row size to exceed the server's limit, most commonly when
+// converting many VARCHAR columns to a character set with more bytes per
+// character. If so, a note describing the problem is returned; otherwise an
+// empty string is returned.
+func rowSizeNote(td *tengo.TableDiff) string {
+	if td.Type != tengo.DiffTypeAlter || td.From == nil || td.To == nil {
+		return ""
+	}
+	toSize := rowSize(td.To)
+	if toSize > maxRowSize && toSize > rowSize(td.From) {
+		return fmt.Sprintf("row size would be %d bytes, exceeding limit of %d bytes", toSize, maxRowSize)
+	}
+	return ""
+}
+
+// rowSize returns the maximum size in bytes of a row of table, as computed by
+// the server when checking the row size limit. Columns of unrecognized types
+// are not counted, so the result is a lower bound in that case.
+func rowSize(table *tengo.Table) (size int) {
+	var nullable int
+	for _, col := range table.Columns {
+		if col.GenerationExpr != "" && col.Virtual {
+			continue
+		}
+		if col.Nullable {
+			nullable++
+		}
+		size += columnMaxSize(col)
+	}
+	return size + (nullable+7)/8
+}
+
+// columnMaxSize returns the maximum size in bytes that col contributes towards
+// the row size limit.
+func columnMaxSize(col *tengo.Column) int {
+	matches := reColumnType.FindStringSubmatch(strings.ToLower(col.TypeInDB))
+	if matches == nil {
+		return 0
+	}
+	length, _ := strconv.Atoi(matches[2])
+	scale, _ := strconv.Atoi(matches[3])
+	switch matches[1] {
+	case "tinyint", "year":
+		return 1
+	case "smallint":
+		return 2
+	case "mediumint", "date":
+		return 3
+	case "int", "integer", "float":
+		return 4
+	case "bigint", "double", "real":
+		return 8
+	case "decimal", "numeric":
+		if matches[2] == "" {
+			length = 10
+		}
+		return decimalDigitsSize(length-scale) + decimalDigitsSize(scale)
+	case "time":
+		return 3 + (length+1)/2
+	case "timestamp":
+		return 4 + (length+1)/2
+	case "datetime":
+		return 5 + (length+1)/2
+	case "bit":
+		return (length + 7) / 8
+	case "char":
+		return length * columnBytesPerChar(col)
+	case "binary":
+		return length
+	case "varchar", "varbinary":
+		if matches[1] == "varchar" {
+			length *= columnBytesPerChar(col)
+		}
+		if length > 255 {
+			return length + 2
+		}
+		return length + 1
+	case "enum", "set":
+		_, members, ok := parseEnumSetMembers(col.TypeInDB)
+		if !ok {
+			return 0
+		} else if matches[1] == "enum" && len(members) > 255 {
+			return 2
+		} else if matches[1] == "enum" {
+			return 1
+		} else if bytes := (len(members) + 7) / 8; bytes > 4 {
+			return 8
+		} else {
+			return bytes
+		}
+	case "tinyblob", "tinytext":
+		return 9
+	case "blob", "text":
+		return 10
+	case "mediumblob", "mediumtext":
+		return 11
+	case "longblob", "longtext", "json":
+		return 12
+	}
+	return 0
+}
+
+// decimalDigitsSize returns the storage size in bytes of the given number of
+// digits in one part (integer or fractional) of a DECIMAL column.
+func decimalDigitsSize(digits int) int {
+	leftover := []int{0, 1, 1, 2, 2, 3, 3, 4, 4}
+	return (digits/9)*4 + leftover[digits%9]
+}
+
+// columnBytesPerChar returns the maximum bytes per character of col's
+// character set.
+func columnBytesPerChar(col *tengo.Column) int {
+	if bytesPerChar, ok := charSetMaxBytes[col.CharSet]; ok {
+		return bytesPerChar
+	}
+	return 1
+}
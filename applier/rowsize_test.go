@@ -0,0 +1,72 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestColumnMaxSize(t *testing.T) {
+	cases := []struct {
+		typeInDB string
+		charSet  string
+		expected int
+	}{
+		{"int(10) unsigned", "", 4},
+		{"bigint(20)", "", 8},
+		{"decimal(10,2)", "", 5},
+		{"decimal(18,9)", "", 8},
+		{"datetime(6)", "", 8},
+		{"timestamp", "", 4},
+		{"bit(9)", "", 2},
+		{"char(10)", "utf8mb4", 40},
+		{"binary(16)", "", 16},
+		{"varchar(60)", "utf8", 181},
+		{"varchar(100)", "utf8mb4", 402},
+		{"varchar(100)", "latin1", 101},
+		{"varbinary(300)", "", 302},
+		{"enum('a','b')", "utf8mb4", 1},
+		{"set('a','b','c','d','e','f','g','h','i')", "utf8mb4", 2},
+		{"mediumtext", "utf8mb4", 11},
+		{"json", "", 12},
+		{"geometry", "", 0},
+	}
+	for _, c := range cases {
+		col := &tengo.Column{Name: "col", TypeInDB: c.typeInDB, CharSet: c.charSet}
+		if actual := columnMaxSize(col); actual != c.expected {
+			t.Errorf("Expected columnMaxSize(%s %s) to return %d, instead found %d", c.typeInDB, c.charSet, c.expected, actual)
+		}
+	}
+}
+
+func TestRowSizeNote(t *testing.T) {
+	makeTable := func(charSet string) *tengo.Table {
+		table := &tengo.Table{Name: "wide", Engine: "InnoDB", CharSet: charSet}
+		table.Columns = append(table.Columns, &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned"})
+		for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+			table.Columns = append(table.Columns, &tengo.Column{Name: name, TypeInDB: "varchar(3000)", Nullable: true, CharSet: charSet})
+		}
+		table.Columns = append(table.Columns, &tengo.Column{Name: "v", TypeInDB: "varchar(5000)", GenerationExpr: "concat(a,b)", Virtual: true, CharSet: charSet})
+		return table
+	}
+	td := tengo.NewAlterTable(makeTable("utf8"), makeTable("utf8mb4"))
+	if td == nil {
+		t.Fatal("Expected NewAlterTable to return a diff, but it returned nil")
+	}
+	note := rowSizeNote(td)
+	if !strings.Contains(note, "row size would be 72017 bytes") {
+		t.Errorf("Unexpected note from rowSizeNote: %q", note)
+	}
+
+	// Not a problem if the new row size is within the limit
+	td = tengo.NewAlterTable(makeTable("latin1"), makeTable("utf8"))
+	if note := rowSizeNote(td); note != "" {
+		t.Errorf("Expected no note, instead found %q", note)
+	}
+
+	// Non-alter diffs never have a note
+	if note := rowSizeNote(tengo.NewCreateTable(makeTable("utf8mb4"))); note != "" {
+		t.Errorf("Expected no note for CREATE TABLE, instead found %q", note)
+	}
+}
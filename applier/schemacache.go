@@ -0,0 +1,90 @@
+package applier
+
+import (
+	"database/sql"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/tengo"
+)
+
+// schemaCache caches introspection results from live database servers for the
+// duration of a single run over a directory tree. When multiple targets map to
+// the same instance and schema -- for example, a shard wildcard overlapping
+// with explicitly-configured dirs -- the schema is only introspected once. Entries for a schema are invalidated whenever DDL is
+// executed in it, so subsequent targets always see the result of any changes.
+// tengo introspects all objects of a schema together, so entries are kept per
+// schema rather than per object.
+type schemaCache struct {
+	sync.Mutex
+	entries map[schemaCacheKey]*schemaCacheEntry
+	hits    int
+	misses  int
+	fetch   func(inst *tengo.Instance, schemaName string) (*tengo.Schema, error) // overridden in tests
+}
+
+type schemaCacheKey struct {
+	instance string
+	schema   string
+}
+
+type schemaCacheEntry struct {
+	sync.Mutex
+	schema *tengo.Schema
+	done   bool
+}
+
+func newSchemaCache() *schemaCache {
+	return &schemaCache{
+		entries: make(map[schemaCacheKey]*schemaCacheEntry),
+		fetch: func(inst *tengo.Instance, schemaName string) (*tengo.Schema, error) {
+			return inst.Schema(schemaName)
+		},
+	}
+}
+
+// Schema returns the introspected schema with the supplied name on inst, or nil
+// if the schema does not exist. Results are cached, but errors are not.
+// Concurrent requests for the same schema wait for a single introspection.
+func (sc *schemaCache) Schema(inst *tengo.Instance, schemaName string) (*tengo.Schema, error) {
+	key := schemaCacheKey{instance: inst.String(), schema: schemaName}
+	sc.Lock()
+	entry := sc.entries[key]
+	if entry == nil {
+		entry = &schemaCacheEntry{}
+		sc.entries[key] = entry
+	}
+	sc.Unlock()
+
+	entry.Lock()
+	defer entry.Unlock()
+	sc.Lock()
+	outcome := "miss"
+	if entry.done {
+		outcome = "hit"
+		sc.hits++
+	} else {
+		sc.misses++
+	}
+	log.Debugf("Introspection cache %s for %s %s (%d hits, %d misses so far)", outcome, inst, schemaName, sc.hits, sc.misses)
+	sc.Unlock()
+	if entry.done {
+		return entry.schema, nil
+	}
+	schema, err := sc.fetch(inst, schemaName)
+	if err == sql.ErrNoRows {
+		schema, err = nil, nil
+	}
+	if err == nil {
+		entry.schema, entry.done = schema, true
+	}
+	return schema, err
+}
+
+// Invalidate removes any cached introspection of the named schema on inst.
+// This must be called after executing any DDL in the schema.
+func (sc *schemaCache) Invalidate(inst *tengo.Instance, schemaName string) {
+	sc.Lock()
+	defer sc.Unlock()
+	delete(sc.entries, schemaCacheKey{instance: inst.String(), schema: schemaName})
+}
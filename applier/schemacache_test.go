@@ -0,0 +1,64 @@
+package applier
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestSchemaCache(t *testing.T) {
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+
+	// Stub out introspection, counting the number of times it occurs per schema
+	queries := make(map[string]int)
+	results := map[string]error{"product": nil, "missing": sql.ErrNoRows, "broken": errors.New("connection refused")}
+	cache := newSchemaCache()
+	cache.fetch = func(inst *tengo.Instance, schemaName string) (*tengo.Schema, error) {
+		queries[schemaName]++
+		if err := results[schemaName]; err != nil {
+			return nil, err
+		}
+		return &tengo.Schema{Name: schemaName}, nil
+	}
+
+	// A second dir targeting the same schema on the same instance should not
+	// perform any additional introspection
+	first := &Target{Instance: inst, SchemaName: "product", schemaCache: cache}
+	second := &Target{Instance: inst, SchemaName: "product", schemaCache: cache}
+	schema1, err := first.SchemaFromInstance()
+	if err != nil || schema1 == nil || schema1.Name != "product" {
+		t.Fatalf("Unexpected return from SchemaFromInstance: %+v, %v", schema1, err)
+	}
+	schema2, err := second.SchemaFromInstance()
+	if err != nil || schema2 != schema1 {
+		t.Errorf("Expected cached schema to be returned, instead found %+v, %v", schema2, err)
+	}
+	if queries["product"] != 1 || cache.hits != 1 || cache.misses != 1 {
+		t.Errorf("Expected 1 introspection, 1 hit, and 1 miss; instead found %d, %d, %d", queries["product"], cache.hits, cache.misses)
+	}
+
+	// Invalidating the schema, as occurs after executing DDL, should cause it to
+	// be introspected again
+	cache.Invalidate(inst, "product")
+	if schema3, err := second.SchemaFromInstance(); err != nil || schema3 == schema1 || queries["product"] != 2 {
+		t.Errorf("Expected schema to be introspected again after invalidation, instead found %+v, %v, %d queries", schema3, err, queries["product"])
+	}
+
+	// Nonexistent schemas are cached as nil, but errors are not cached
+	for n := 0; n < 2; n++ {
+		if schema, err := cache.Schema(inst, "missing"); schema != nil || err != nil {
+			t.Errorf("Expected nonexistent schema to return nil, nil; instead found %+v, %v", schema, err)
+		}
+		if _, err := cache.Schema(inst, "broken"); err == nil {
+			t.Error("Expected introspection error to be returned, but err was nil")
+		}
+	}
+	if queries["missing"] != 1 || queries["broken"] != 2 {
+		t.Errorf("Unexpected introspection counts: %v", queries)
+	}
+}
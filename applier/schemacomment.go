@@ -0,0 +1,81 @@
+package applier
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+// SupportsSchemaComment returns true if flavor permits schema-level comments,
+// which were added in MariaDB 10.5.
+func SupportsSchemaComment(flavor tengo.Flavor) bool {
+	return flavor.VendorMinVersion(tengo.VendorMariaDB, 10, 5)
+}
+
+// SchemaComment returns the comment of the schema with the supplied name on
+// inst. An empty string is returned if inst's flavor does not support schema
+// comments, or the schema does not exist.
+func SchemaComment(inst *tengo.Instance, schemaName string) (string, error) {
+	if !SupportsSchemaComment(inst.Flavor()) {
+		return "", nil
+	}
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return "", err
+	}
+	var comment string
+	err = db.QueryRow("SELECT schema_comment FROM information_schema.schemata WHERE schema_name = ?", schemaName).Scan(&comment)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return comment, err
+}
+
+// schemaCommentDiff represents a change to a schema's comment. It satisfies
+// the tengo.ObjectDiff interface. Since tengo does not support schema
+// comments, such changes are always expressed as a separate ALTER DATABASE,
+// even for new schemas.
+type schemaCommentDiff struct {
+	SchemaName string
+	Comment    string
+}
+
+// DiffType returns the type of diff operation.
+func (scd *schemaCommentDiff) DiffType() tengo.DiffType {
+	return tengo.DiffTypeAlter
+}
+
+// ObjectKey returns a value representing the type and name of the schema.
+func (scd *schemaCommentDiff) ObjectKey() tengo.ObjectKey {
+	return tengo.ObjectKey{Type: tengo.ObjectTypeDatabase, Name: scd.SchemaName}
+}
+
+// Statement returns the ALTER DATABASE statement setting the schema's comment.
+func (scd *schemaCommentDiff) Statement(_ tengo.StatementModifiers) (string, error) {
+	return fmt.Sprintf("ALTER DATABASE %s COMMENT '%s'", tengo.EscapeIdentifier(scd.SchemaName), tengo.EscapeValueForCreateTable(scd.Comment)), nil
+}
+
+// schemaCommentChange returns a diff changing the comment of t's schema to the
+// value of the schema-comment option of t's dir, or nil if no change is
+// needed. Schema comments are only managed if the option has been set, and
+// the flavor of t's instance supports them. The current comment is only
+// queried if exists indicates that the schema already exists.
+func (t *Target) schemaCommentChange(exists bool) (*schemaCommentDiff, error) {
+	if !t.Dir.Config.Supplied("schema-comment") || !SupportsSchemaComment(t.Instance.Flavor()) {
+		return nil, nil
+	}
+	desired := util.UnquoteOptionValue(t.Dir.Config.GetRaw("schema-comment"))
+	var current string
+	if exists {
+		var err error
+		if current, err = SchemaComment(t.Instance, t.SchemaName); err != nil {
+			return nil, err
+		}
+	}
+	if current == desired {
+		return nil, nil
+	}
+	return &schemaCommentDiff{SchemaName: t.SchemaName, Comment: desired}, nil
+}
@@ -0,0 +1,40 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestSupportsSchemaComment(t *testing.T) {
+	cases := map[tengo.Flavor]bool{
+		tengo.FlavorMySQL80:                                false,
+		tengo.FlavorMariaDB104:                             false,
+		{Vendor: tengo.VendorMariaDB, Major: 10, Minor: 5}: true,
+		{Vendor: tengo.VendorMariaDB, Major: 10, Minor: 6}: true,
+	}
+	for flavor, expected := range cases {
+		if actual := SupportsSchemaComment(flavor); actual != expected {
+			t.Errorf("Expected SupportsSchemaComment(%s) to return %t, instead found %t", flavor, expected, actual)
+		}
+	}
+}
+
+func TestSchemaCommentDiffStatement(t *testing.T) {
+	cases := map[string]string{
+		"":                   "ALTER DATABASE `product` COMMENT ''",
+		"hello":              "ALTER DATABASE `product` COMMENT 'hello'",
+		"it's":               "ALTER DATABASE `product` COMMENT 'it''s'",
+		"two\nlines":         "ALTER DATABASE `product` COMMENT 'two\\nlines'",
+		`back\slash "quote"`: "ALTER DATABASE `product` COMMENT 'back\\\\slash \"quote\"'",
+	}
+	for comment, expected := range cases {
+		scd := &schemaCommentDiff{SchemaName: "product", Comment: comment}
+		if key := scd.ObjectKey(); key.Type != tengo.ObjectTypeDatabase || key.Name != "product" {
+			t.Errorf("Unexpected ObjectKey: %s", key)
+		}
+		if actual, err := scd.Statement(tengo.StatementModifiers{}); err != nil || actual != expected {
+			t.Errorf("Expected Statement() to return %s, instead found %s / %v", expected, actual, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package applier
+
+import (
+	"fmt"
+
+	"github.com/skeema/tengo"
+)
+
+// SchemaState describes whether a schema exists on an instance, and if so,
+// whether it has any tables. An empty schema may still contain routines.
+type SchemaState string
+
+// Constants enumerating valid schema states
+const (
+	SchemaMissing   SchemaState = "missing"   // schema does not exist
+	SchemaEmpty     SchemaState = "empty"     // schema exists, but has no tables
+	SchemaPopulated SchemaState = "populated" // schema exists and has at least one table
+)
+
+// schemaStateOf returns the state of schema, as introspected from an instance.
+// A nil schema is considered missing.
+func schemaStateOf(schema *tengo.Schema) SchemaState {
+	if schema == nil {
+		return SchemaMissing
+	} else if len(schema.Tables) == 0 {
+		return SchemaEmpty
+	}
+	return SchemaPopulated
+}
+
+// SchemaStateForInstance returns the state of the named schema on inst, along
+// with its number of tables. Unlike introspecting the full schema, this only
+// requires inexpensive queries.
+func SchemaStateForInstance(inst *tengo.Instance, schemaName string) (state SchemaState, tableCount int, err error) {
+	if exists, err := inst.HasSchema(schemaName); err != nil {
+		return "", 0, err
+	} else if !exists {
+		return SchemaMissing, 0, nil
+	}
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return "", 0, err
+	}
+	query := `
+		SELECT COUNT(*)
+		FROM   tables
+		WHERE  table_schema = ? AND table_type = 'BASE TABLE'`
+	if err := db.QueryRow(query, schemaName).Scan(&tableCount); err != nil {
+		return "", 0, err
+	} else if tableCount == 0 {
+		return SchemaEmpty, 0, nil
+	}
+	return SchemaPopulated, tableCount, nil
+}
+
+// checkSchemaState logs the state of t's schema on its instance, if the schema
+// is missing or has no tables, since these situations call for different
+// responses despite both causing every table to be created. An empty schema
+// may indicate that its tables were dropped unexpectedly, so if the
+// empty-rebuild-min-tables option is set and the filesystem defines at least
+// that many tables, a non-nil error is returned unless allow-empty-rebuild is
+// enabled. In dry-run mode, a warning is logged instead of returning an error.
+// A ConfigError is returned if the option value is invalid.
+func (t *Target) checkSchemaState(state SchemaState, schemaFromDir *tengo.Schema) error {
+	tableCount := len(schemaFromDir.Tables)
+	if state == SchemaPopulated || tableCount == 0 {
+		return nil
+	} else if state == SchemaMissing {
+		t.logger().Infof("%s %s: schema does not exist yet, so it will be created along with %s", t.Instance, t.SchemaName, countAndNoun(tableCount, "table", "tables"))
+		return nil
+	}
+	t.logger().Warnf("%s %s: schema exists but has no tables, while %s defines %s; if its tables were dropped unexpectedly, investigate before pushing", t.Instance, t.SchemaName, t.Dir, countAndNoun(tableCount, "table", "tables"))
+
+	if t.Dir.Config.FindOption("empty-rebuild-min-tables") == nil {
+		return nil
+	}
+	minTables, err := t.Dir.Config.GetInt("empty-rebuild-min-tables")
+	if err != nil {
+		return ConfigError(err.Error())
+	} else if minTables < 1 || tableCount < minTables || t.Dir.Config.GetBool("allow-empty-rebuild") {
+		return nil
+	}
+	if t.dryRun() {
+		t.logger().Warnf("%s %s: push would refuse to rebuild this schema without --allow-empty-rebuild (empty-rebuild-min-tables=%d)", t.Instance, t.SchemaName, minTables)
+		return nil
+	}
+	return fmt.Errorf("schema exists but has no tables, and rebuilding %s requires --allow-empty-rebuild (empty-rebuild-min-tables=%d)", countAndNoun(tableCount, "table", "tables"), minTables)
+}
@@ -0,0 +1,70 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestSchemaStateOf(t *testing.T) {
+	cases := []struct {
+		schema   *tengo.Schema
+		expected SchemaState
+	}{
+		{nil, SchemaMissing},
+		{&tengo.Schema{Name: "product"}, SchemaEmpty},
+		{&tengo.Schema{Name: "product", Routines: []*tengo.Routine{{Name: "cleanup", Type: tengo.ObjectTypeProc}}}, SchemaEmpty},
+		{&tengo.Schema{Name: "product", Tables: []*tengo.Table{{Name: "users"}}}, SchemaPopulated},
+	}
+	for _, c := range cases {
+		if actual := schemaStateOf(c.schema); actual != c.expected {
+			t.Errorf("Expected state %q for %+v, instead found %q", c.expected, c.schema, actual)
+		}
+	}
+}
+
+func TestCheckSchemaState(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	desired := &tengo.Schema{Name: "product", Tables: []*tengo.Table{{Name: "comments"}, {Name: "posts"}, {Name: "users"}}}
+	check := func(flags string, state SchemaState) error {
+		t.Helper()
+		target.Dir = getDir(t, "testdata/simple/one", flags)
+		return target.checkSchemaState(state, desired)
+	}
+
+	// Without empty-rebuild-min-tables, no state is an error
+	for _, state := range []SchemaState{SchemaMissing, SchemaEmpty, SchemaPopulated} {
+		if err := check("", state); err != nil {
+			t.Errorf("Unexpected error for state %q without empty-rebuild-min-tables: %v", state, err)
+		}
+	}
+
+	// Only an empty schema with enough desired tables is an error, and only when
+	// actually pushing without allow-empty-rebuild
+	if err := check("--empty-rebuild-min-tables=3", SchemaEmpty); err == nil {
+		t.Error("Expected error for empty schema reaching empty-rebuild-min-tables, but err was nil")
+	} else if _, ok := err.(ConfigError); ok {
+		t.Errorf("Expected non-ConfigError, instead found %v", err)
+	}
+	noErrorFlags := []string{
+		"--empty-rebuild-min-tables=4",
+		"--empty-rebuild-min-tables=3 --allow-empty-rebuild",
+		"--empty-rebuild-min-tables=3 --dry-run",
+	}
+	for _, flags := range noErrorFlags {
+		if err := check(flags, SchemaEmpty); err != nil {
+			t.Errorf("Unexpected error for empty schema with %s: %v", flags, err)
+		}
+	}
+	for _, state := range []SchemaState{SchemaMissing, SchemaPopulated} {
+		if err := check("--empty-rebuild-min-tables=1", state); err != nil {
+			t.Errorf("Unexpected error for state %q: %v", state, err)
+		}
+	}
+
+	if err := check("--empty-rebuild-min-tables=many", SchemaEmpty); err == nil {
+		t.Error("Expected error from invalid empty-rebuild-min-tables, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok {
+		t.Errorf("Expected ConfigError, instead found %T: %v", err, err)
+	}
+}
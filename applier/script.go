@@ -0,0 +1,259 @@
+package applier
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+// ScriptSet accumulates the DDL that push would execute for each target using
+// the script-out option, so that it can be written to a ready-to-run SQL script
+// per target. Each script consists of a header comment, the session variables
+// that push would set upon connecting, the ordered DDL exactly as push would
+// execute it aside from any statement-comment, and a trailing verification
+// query listing the expected fingerprint of each table.
+type ScriptSet struct {
+	version   string
+	basePath  string
+	scripts   []*script
+	index     map[*Target]*script
+	shellOuts []string
+}
+
+// script represents the statements to write for a single target.
+type script struct {
+	target     *Target
+	session    map[string]string // session variables set upon connecting, excluding driver params
+	statements []*DDLStatement
+	tables     map[string]string // table name => fingerprint expected after running the script
+}
+
+// NewScriptSet returns a pointer to a new empty ScriptSet for operations
+// originating in the supplied dir, typically the current working directory.
+// The supplied version of Skeema is named in each script's header.
+func NewScriptSet(dir *fs.Dir, version string) *ScriptSet {
+	return &ScriptSet{
+		version:  version,
+		basePath: dir.Path,
+		index:    make(map[*Target]*script),
+	}
+}
+
+// scriptTemplate returns the value of the script-out option for t, or a blank
+// string if t is not writing a script.
+func (t *Target) scriptTemplate() string {
+	if t.Dir.Config.FindOption("script-out") == nil {
+		return ""
+	}
+	return strings.TrimSpace(t.Dir.Config.Get("script-out"))
+}
+
+// addStatement records ddl in the script of its target, if the target is
+// writing a script. Callers must ensure this method is not called concurrently;
+// Printer handles this by holding its lock.
+func (ss *ScriptSet) addStatement(ddl *DDLStatement) {
+	t := ddl.target
+	if t == nil || t.scriptTemplate() == "" {
+		return
+	}
+	if ddl.IsShellOut() {
+		ss.shellOuts = append(ss.shellOuts, fmt.Sprintf("%s %s %s", t.Instance, t.SchemaName, ddl.diff.ObjectKey()))
+		return
+	}
+	s := ss.index[t]
+	if s == nil {
+		// InstanceDefaultParams was already called successfully when connecting
+		// to the target, so its error can safely be ignored here
+		params, _ := t.Dir.InstanceDefaultParams()
+		s = &script{
+			target:  t,
+			session: sessionVariables(params),
+			tables:  make(map[string]string),
+		}
+		schema, _ := t.diffableSchema(t.SchemaFromDir())
+		fingerprints := ObjectFingerprints(schema)
+		for _, table := range schema.Tables {
+			s.tables[table.Name] = fingerprints[tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}]
+		}
+		ss.index[t] = s
+		ss.scripts = append(ss.scripts, s)
+	}
+	s.statements = append(s.statements, ddl)
+}
+
+// sessionVariables parses a DSN param string, returning the session variables
+// it sets. Go driver params are omitted.
+func sessionVariables(params string) map[string]string {
+	result := make(map[string]string)
+	values, _ := url.ParseQuery(params)
+	for name := range values {
+		if !util.IsDriverParam(name) {
+			result[name] = values.Get(name)
+		}
+	}
+	return result
+}
+
+// Write saves each recorded script to the path obtained by interpolating its
+// target's script-out option, returning the paths written. An error is
+// returned without writing anything if any script would include statements
+// executed by shelling out to an external program, or if multiple targets'
+// scripts would be written to the same path.
+func (ss *ScriptSet) Write() (paths []string, err error) {
+	if len(ss.shellOuts) > 0 {
+		// Intentionally avoiding fmt.Errorf here to avoid golint complaining about capitalization
+		errorText := fmt.Sprintf("Scripts cannot include operations executed via alter-wrapper or ddl-wrapper, but these were configured for: %s", strings.Join(ss.shellOuts, ", "))
+		return nil, errors.New(errorText)
+	}
+	seen := make(map[string]*Target)
+	for _, s := range ss.scripts {
+		path, err := s.target.expandVariables("script-out", s.target.scriptTemplate())
+		if err != nil {
+			return nil, ConfigError(err.Error())
+		}
+		path = filepath.Clean(path)
+		if other := seen[path]; other != nil {
+			return nil, ConfigError(fmt.Sprintf("Scripts for %s %s and %s %s would both be written to %s; use variables such as {HOST} and {SCHEMA} in option script-out", other.Instance, other.SchemaName, s.target.Instance, s.target.SchemaName, path))
+		}
+		seen[path] = s.target
+		paths = append(paths, path)
+	}
+	now := time.Now().UTC()
+	for n, s := range ss.scripts {
+		if err := fs.MakeDir(filepath.Dir(paths[n])); err != nil {
+			return nil, err
+		}
+		if err := fs.WriteFile(paths[n], []byte(s.contents(ss, now))); err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+// CommentedOutCount returns the total number of unsafe statements which were
+// written to scripts in commented-out form.
+func (ss *ScriptSet) CommentedOutCount() (count int) {
+	for _, s := range ss.scripts {
+		count += s.commentedOutCount()
+	}
+	return count
+}
+
+func (s *script) commentedOutCount() (count int) {
+	for _, ddl := range s.statements {
+		if ddl.commentedOut {
+			count++
+		}
+	}
+	return count
+}
+
+// contents returns the full text of the script, as generated at time now.
+func (s *script) contents(ss *ScriptSet, now time.Time) string {
+	var b strings.Builder
+	t := s.target
+	dirPath, err := filepath.Rel(ss.basePath, t.Dir.Path)
+	if err != nil {
+		dirPath = t.Dir.Path
+	}
+	fmt.Fprintf(&b, "-- Generated by skeema %s at %s\n", ss.version, now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "-- Host: %s\n", t.Instance)
+	fmt.Fprintf(&b, "-- Schema: %s\n", t.SchemaName)
+	fmt.Fprintf(&b, "-- Environment: %s\n", t.Dir.Config.Get("environment"))
+	fmt.Fprintf(&b, "-- Dir: %s\n", filepath.ToSlash(dirPath))
+	if count := s.commentedOutCount(); count > 0 {
+		fmt.Fprintf(&b, "-- %s commented out, since allow-unsafe is not enabled\n", countAndNoun(count, "unsafe statement is", "unsafe statements are"))
+	}
+
+	// Session variables which push sets upon connecting
+	b.WriteString("\n")
+	names := make([]string, 0, len(s.session))
+	for name := range s.session {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "SET SESSION %s = %s;\n", name, s.session[name])
+	}
+
+	// Statements, with per-statement session variables set beforehand and
+	// restored afterwards. As in Printer.printDDL, USE precedes the first
+	// statement executed in the schema, since the schema may not exist prior
+	// to an initial CREATE DATABASE.
+	var usedSchema bool
+	for _, ddl := range s.statements {
+		b.WriteString("\n")
+		if ddl.schemaName != "" && !usedSchema {
+			b.WriteString(formatUse(ddl.schemaName))
+			usedSchema = true
+		}
+		if ddl.note != "" {
+			fmt.Fprintf(&b, "-- %s\n", ddl.note)
+		}
+		if ddl.commentedOut {
+			b.WriteString(commentedOutDDL(ddl))
+			continue
+		}
+		overrides := sessionVariables(ddl.connectParams)
+		names := make([]string, 0, len(overrides))
+		for name := range overrides {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "SET SESSION %s = %s;\n", name, overrides[name])
+		}
+		b.WriteString(ddl.String())
+		for _, name := range names {
+			restore, ok := s.session[name]
+			if !ok {
+				restore = "DEFAULT"
+			}
+			fmt.Fprintf(&b, "SET SESSION %s = %s;\n", name, restore)
+		}
+	}
+
+	b.WriteString("\n")
+	b.WriteString(s.verification())
+	return b.String()
+}
+
+// verification returns a trailing comment and query for the script which lists
+// each table that should exist once the script has run, along with its
+// expected fingerprint and whether it is present.
+func (s *script) verification() string {
+	var b strings.Builder
+	b.WriteString("-- Verification: after running this script, each table listed below should be\n")
+	b.WriteString("-- present, with the fingerprint shown by `skeema fingerprint --from-server`.\n")
+	if s.commentedOutCount() > 0 {
+		b.WriteString("-- Tables affected by commented-out statements will not match.\n")
+	}
+	if len(s.tables) == 0 {
+		b.WriteString("-- No tables are expected.\n")
+		return b.String()
+	}
+	names := make([]string, 0, len(s.tables))
+	for name := range s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.WriteString("SELECT expected.table_name, expected.fingerprint, t.table_name IS NOT NULL AS present\nFROM (\n")
+	for n, name := range names {
+		if n == 0 {
+			fmt.Fprintf(&b, "  SELECT '%s' AS table_name, '%s' AS fingerprint\n", tengo.EscapeValueForCreateTable(name), s.tables[name])
+		} else {
+			fmt.Fprintf(&b, "  UNION ALL SELECT '%s', '%s'\n", tengo.EscapeValueForCreateTable(name), s.tables[name])
+		}
+	}
+	fmt.Fprintf(&b, ") AS expected\nLEFT JOIN information_schema.tables t ON t.table_schema = '%s' AND t.table_name = expected.table_name\n", tengo.EscapeValueForCreateTable(s.target.SchemaName))
+	b.WriteString("ORDER BY expected.table_name;\n")
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package applier
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
+)
+
+func TestScriptSetWrite(t *testing.T) {
+	defer os.RemoveAll("testdata/.scratch")
+	newTarget := func(dsn, flags string) *Target {
+		inst, err := tengo.NewInstance("mysql", dsn)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewInstance: %s", err)
+		}
+		inst.SetFlavor(tengo.FlavorMySQL57)
+		return &Target{
+			Instance:   inst,
+			Dir:        getDir(t, "testdata/simple/one", flags),
+			SchemaName: "product",
+			DesiredSchema: &workspace.Schema{Schema: &tengo.Schema{Tables: []*tengo.Table{
+				{Name: "foo", CreateStatement: "CREATE TABLE `foo` (\n  `id` int(10) unsigned NOT NULL,\n  PRIMARY KEY (`id`)\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+				{Name: "it's", CreateStatement: "CREATE TABLE `it's` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"},
+			}}},
+		}
+	}
+	target := newTarget("root:@tcp(127.0.0.1:3306)/", "--script-out='testdata/.scratch/{HOST}/{SCHEMA}.sql' --connect-options=\"wait_timeout=60,readTimeout=5s\"")
+	if !target.dryRun() {
+		t.Error("Expected script-out to imply dry-run, but it did not")
+	}
+	dbDiff := &tengo.DatabaseDiff{To: &tengo.Schema{Name: "product"}}
+	func1 := fingerprintTestRoutine("STRICT_TRANS_TABLES")
+	func1.Body = "BEGIN\n  RETURN 1;\nEND"
+	func1.CreateStatement = func1.Definition(tengo.FlavorMySQL57)
+	ddls := []*DDLStatement{
+		{target: target, diff: dbDiff, stmt: "CREATE DATABASE `product`"},
+		{target: target, diff: tengo.NewCreateTable(brokenFKTestTable("foo")), schemaName: "product", stmt: "ALTER TABLE `foo` ADD CONSTRAINT ...", connectParams: "readTimeout=0&foreign_key_checks=1", comment: "/* skeema:push */"},
+		{target: target, diff: &tengo.RoutineDiff{To: func1}, schemaName: "product", stmt: func1.CreateStatement, connectParams: "sql_mode=@@GLOBAL.sql_mode"},
+		{target: target, diff: tengo.NewCreateTable(brokenFKTestTable("bar")), schemaName: "product", stmt: "DROP TABLE `bar`", unsafe: true, commentedOut: true},
+	}
+	ss := &ScriptSet{version: "1.2.3", basePath: filepath.Dir(target.Dir.Path), index: make(map[*Target]*script)}
+	for _, ddl := range ddls {
+		ss.addStatement(ddl)
+	}
+	// Statements of targets not using script-out are ignored
+	other := newTarget("root:@tcp(127.0.0.2:3306)/", "")
+	ss.addStatement(&DDLStatement{target: other, diff: dbDiff, stmt: "CREATE DATABASE `product`"})
+
+	paths, err := ss.Write()
+	if err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	} else if len(paths) != 1 || paths[0] != "testdata/.scratch/127.0.0.1/product.sql" {
+		t.Fatalf("Unexpected paths from Write: %v", paths)
+	}
+	if ss.CommentedOutCount() != 1 {
+		t.Errorf("Expected 1 commented-out statement, instead found %d", ss.CommentedOutCount())
+	}
+	contents, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("Unable to read script: %s", err)
+	}
+	text := string(contents)
+	expectedInOrder := []string{
+		"-- Generated by skeema 1.2.3 at ",
+		"-- Host: 127.0.0.1:3306\n-- Schema: product\n-- Environment: production\n-- Dir: one\n",
+		"SET SESSION foreign_key_checks = 0;\n",
+		"SET SESSION sql_mode = 'ONLY_FULL_GROUP_BY,",
+		"SET SESSION wait_timeout = 60;\n",
+		"\nCREATE DATABASE `product`;\n\nUSE `product`;\n",
+		"SET SESSION foreign_key_checks = 1;\nALTER TABLE `foo` ADD CONSTRAINT ...;\nSET SESSION foreign_key_checks = 0;\n",
+		"SET SESSION sql_mode = @@GLOBAL.sql_mode;\nDELIMITER //\n",
+		"END//\nDELIMITER ;\nSET SESSION sql_mode = 'ONLY_FULL_GROUP_BY,",
+		"-- Unsafe statement commented out; use --allow-unsafe, --allow-unsafe-codes, or --safe-below-size to include it\n-- DROP TABLE `bar`;\n",
+		"  SELECT 'foo' AS table_name, '",
+		"  UNION ALL SELECT 'it''s', '",
+		"t.table_schema = 'product' AND",
+	}
+	var pos int
+	for _, expected := range expectedInOrder {
+		n := strings.Index(text[pos:], expected)
+		if n < 0 {
+			t.Fatalf("Expected script to contain %q after offset %d, but it did not. Script:\n%s", expected, pos, text)
+		}
+		pos += n + len(expected)
+	}
+	for _, unexpected := range []string{"readTimeout", "interpolateParams", "skeema:push", "127.0.0.2"} {
+		if strings.Contains(text, unexpected) {
+			t.Errorf("Script unexpectedly contains %q:\n%s", unexpected, text)
+		}
+	}
+
+	// Multiple targets may not share a path
+	target2 := newTarget("root:@tcp(127.0.0.1:3307)/", "--script-out='testdata/.scratch/{SCHEMA}.sql'")
+	target3 := newTarget("root:@tcp(127.0.0.1:3308)/", "--script-out='testdata/.scratch/{SCHEMA}.sql'")
+	ss = &ScriptSet{index: make(map[*Target]*script)}
+	ss.addStatement(&DDLStatement{target: target2, diff: dbDiff, stmt: "CREATE DATABASE `product`"})
+	ss.addStatement(&DDLStatement{target: target3, diff: dbDiff, stmt: "CREATE DATABASE `product`"})
+	if _, err := ss.Write(); err == nil {
+		t.Error("Expected error from Write with conflicting paths, but err was nil")
+	}
+
+	// Shell-outs cannot be scripted
+	ss = &ScriptSet{index: make(map[*Target]*script)}
+	ss.addStatement(&DDLStatement{target: target2, diff: dbDiff, shellOut: &util.ShellOut{Command: "/bin/true"}})
+	if _, err := ss.Write(); err == nil {
+		t.Error("Expected error from Write with shell-out, but err was nil")
+	}
+}
+
+func TestSessionVariables(t *testing.T) {
+	vars := sessionVariables("readTimeout=0&foreign_key_checks=1&interpolateParams=true&sql_mode=%27STRICT_ALL_TABLES%27")
+	if len(vars) != 2 || vars["foreign_key_checks"] != "1" || vars["sql_mode"] != "'STRICT_ALL_TABLES'" {
+		t.Errorf("Unexpected result from sessionVariables: %v", vars)
+	}
+	if vars := sessionVariables(""); len(vars) != 0 {
+		t.Errorf("Expected no session variables from blank params, instead found %v", vars)
+	}
+}
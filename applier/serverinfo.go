@@ -0,0 +1,80 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skeema/tengo"
+)
+
+// ServerInfo is a snapshot of server variables describing a database server,
+// for providing context alongside proposed changes. The set of variables is
+// intentionally fixed, so that the JSON representation remains stable.
+type ServerInfo struct {
+	Version       string `json:"version"`              // @@version
+	SQLMode       string `json:"sql_mode"`             // @@global.sql_mode
+	CharSet       string `json:"character_set_server"` // @@global.character_set_server
+	Collation     string `json:"collation_server"`     // @@global.collation_server
+	InnoDBVersion string `json:"innodb_version"`       // @@innodb_version; empty if not reported by the flavor
+}
+
+// fields maps the names of the variables captured in si to pointers to the
+// corresponding fields.
+func (si *ServerInfo) fields() map[string]*string {
+	return map[string]*string{
+		"version":              &si.Version,
+		"sql_mode":             &si.SQLMode,
+		"character_set_server": &si.CharSet,
+		"collation_server":     &si.Collation,
+		"innodb_version":       &si.InnoDBVersion,
+	}
+}
+
+var serverInfoCache = struct {
+	sync.Mutex
+	infos map[string]*ServerInfo
+}{infos: make(map[string]*ServerInfo)}
+
+// ServerInfoForInstance returns a snapshot of server variables for inst. The
+// snapshot is obtained via a single query the first time this function is
+// called for inst, and then cached for the lifetime of the process.
+func ServerInfoForInstance(inst *tengo.Instance) (*ServerInfo, error) {
+	serverInfoCache.Lock()
+	defer serverInfoCache.Unlock()
+	if info, ok := serverInfoCache.infos[inst.String()]; ok {
+		return info, nil
+	}
+	db, err := inst.Connect("", "")
+	if err != nil {
+		return nil, err
+	}
+	info := &ServerInfo{}
+	fields := info.fields()
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	query := fmt.Sprintf("SHOW GLOBAL VARIABLES WHERE Variable_name IN ('%s')", strings.Join(names, "', '"))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if field := fields[strings.ToLower(name)]; field != nil {
+			*field = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	serverInfoCache.infos[inst.String()] = info
+	return info, nil
+}
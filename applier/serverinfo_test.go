@@ -0,0 +1,20 @@
+package applier
+
+import (
+	"testing"
+)
+
+func (s ApplierIntegrationSuite) TestServerInfoForInstance(t *testing.T) {
+	info, err := ServerInfoForInstance(s.d[0].Instance)
+	if err != nil {
+		t.Fatalf("Unexpected error from ServerInfoForInstance: %s", err)
+	}
+	if info.Version == "" || info.CharSet == "" || info.Collation == "" {
+		t.Errorf("Expected version and character set variables to be populated, instead found %+v", info)
+	}
+
+	// Subsequent calls return the cached snapshot
+	if info2, err := ServerInfoForInstance(s.d[0].Instance); err != nil || info2 != info {
+		t.Errorf("Expected cached snapshot to be returned, instead found %+v, %v", info2, err)
+	}
+}
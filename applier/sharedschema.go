@@ -0,0 +1,137 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
+)
+
+// ResolveSharedSchemas looks for targets from different directories which map
+// to the same schema on the same instance. Ordinarily this is a configuration
+// mistake, such as a copy-pasted schema option, which would cause each
+// directory to revert the other's changes; a ConfigError naming the
+// directories is returned in this case. However, if every directory involved
+// has the allow-shared-schema option enabled, the targets are instead merged
+// into a single target whose desired schema combines the objects of all of the
+// directories. The merged target uses the configuration of whichever of its
+// directories has the lexicographically-first path.
+func ResolveSharedSchemas(targets []*Target) ([]*Target, error) {
+	type targetKey struct {
+		instance string
+		schema   string
+	}
+	var keys []targetKey
+	byKey := make(map[targetKey][]*Target)
+	for _, t := range targets {
+		key := targetKey{instance: t.Instance.String(), schema: t.SchemaName}
+		if _, already := byKey[key]; !already {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], t)
+	}
+	if len(keys) == len(targets) {
+		return targets, nil
+	}
+
+	result := make([]*Target, 0, len(keys))
+	for _, key := range keys {
+		group := byKey[key]
+		if len(group) == 1 || !multipleDirs(group) {
+			result = append(result, group...)
+			continue
+		}
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Dir.Path < group[j].Dir.Path
+		})
+		paths := make([]string, len(group))
+		allowed := true
+		for n, t := range group {
+			paths[n] = t.Dir.Path
+			if t.Dir.Config.FindOption("allow-shared-schema") == nil || !t.Dir.Config.GetBool("allow-shared-schema") {
+				allowed = false
+			}
+		}
+		if !allowed {
+			return nil, ConfigError(fmt.Sprintf("Schema %s on %s is claimed by multiple directories: %s. If this is intentional, enable the allow-shared-schema option for these directories.", key.schema, key.instance, strings.Join(paths, ", ")))
+		}
+		merged, err := mergeTargets(group)
+		if err != nil {
+			return nil, ConfigError(err.Error())
+		}
+		log.Debugf("Merging directories %s for %s %s due to allow-shared-schema", strings.Join(paths, ", "), key.instance, key.schema)
+		result = append(result, merged)
+	}
+	return result, nil
+}
+
+// multipleDirs returns true if group contains targets from more than one dir.
+func multipleDirs(group []*Target) bool {
+	for _, t := range group[1:] {
+		if t.Dir.Path != group[0].Dir.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeTargets returns a single Target combining the desired schemas of all
+// targets in group, which must all share the same instance and schema name. An
+// error is returned if an object is defined in more than one of the targets.
+func mergeTargets(group []*Target) (*Target, error) {
+	first := group[0]
+	logicalSchema := &fs.LogicalSchema{
+		CharSet:   first.DesiredSchema.CharSet,
+		Collation: first.DesiredSchema.Collation,
+		Creates:   make(map[tengo.ObjectKey]*fs.Statement),
+	}
+	schema := &tengo.Schema{
+		Name:      first.DesiredSchema.Name,
+		CharSet:   first.DesiredSchema.CharSet,
+		Collation: first.DesiredSchema.Collation,
+	}
+	merged := &workspace.Schema{
+		Schema:        schema,
+		LogicalSchema: logicalSchema,
+		Variables:     first.DesiredSchema.Variables,
+	}
+	definedBy := make(map[tengo.ObjectKey]string)
+	claim := func(key tengo.ObjectKey, t *Target) error {
+		if dirPath, already := definedBy[key]; already {
+			return fmt.Errorf("%s is defined in multiple directories sharing schema %s on %s: %s and %s", key, t.SchemaName, t.Instance, dirPath, t.Dir.Path)
+		}
+		definedBy[key] = t.Dir.Path
+		return nil
+	}
+	for _, t := range group {
+		for _, table := range t.DesiredSchema.Tables {
+			if err := claim(tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: table.Name}, t); err != nil {
+				return nil, err
+			}
+			schema.Tables = append(schema.Tables, table)
+		}
+		for _, routine := range t.DesiredSchema.Routines {
+			if err := claim(tengo.ObjectKey{Type: routine.Type, Name: routine.Name}, t); err != nil {
+				return nil, err
+			}
+			schema.Routines = append(schema.Routines, routine)
+		}
+		if t.DesiredSchema.LogicalSchema != nil {
+			for key, stmt := range t.DesiredSchema.LogicalSchema.Creates {
+				logicalSchema.Creates[key] = stmt
+			}
+			logicalSchema.Alters = append(logicalSchema.Alters, t.DesiredSchema.LogicalSchema.Alters...)
+		}
+		merged.Failures = append(merged.Failures, t.DesiredSchema.Failures...)
+	}
+	return &Target{
+		Instance:      first.Instance,
+		Dir:           first.Dir,
+		SchemaName:    first.SchemaName,
+		DesiredSchema: merged,
+	}, nil
+}
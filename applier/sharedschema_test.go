@@ -0,0 +1,84 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
+)
+
+func TestResolveSharedSchemas(t *testing.T) {
+	inst1, _ := tengo.NewInstance("mysql", "root@tcp(127.0.0.1:3306)/")
+	inst2, _ := tengo.NewInstance("mysql", "root@tcp(127.0.0.1:3307)/")
+	makeTarget := func(inst *tengo.Instance, dirPath, flags, schemaName string, tableNames ...string) *Target {
+		schema := &tengo.Schema{Name: schemaName}
+		for _, name := range tableNames {
+			schema.Tables = append(schema.Tables, &tengo.Table{Name: name})
+		}
+		return &Target{
+			Instance:      inst,
+			Dir:           getDir(t, dirPath, flags),
+			SchemaName:    schemaName,
+			DesiredSchema: &workspace.Schema{Schema: schema},
+		}
+	}
+
+	// Distinct instance/schema pairs, or multiple targets from the same dir, are
+	// left alone
+	targets := []*Target{
+		makeTarget(inst1, "testdata/simple/one", "", "appdb", "foo"),
+		makeTarget(inst1, "testdata/simple/two", "", "otherdb", "bar"),
+		makeTarget(inst2, "testdata/simple/two", "", "appdb", "bar"),
+		makeTarget(inst2, "testdata/simple/two", "", "appdb", "bar"),
+	}
+	if result, err := ResolveSharedSchemas(targets); err != nil || len(result) != len(targets) {
+		t.Errorf("Unexpected return from ResolveSharedSchemas: %d targets, err=%v", len(result), err)
+	}
+
+	// Two dirs claiming the same instance and schema is an error which names
+	// both dirs
+	targets = []*Target{
+		makeTarget(inst1, "testdata/simple/two", "", "appdb", "bar"),
+		makeTarget(inst1, "testdata/simple/one", "", "appdb", "foo"),
+	}
+	if _, err := ResolveSharedSchemas(targets); err == nil {
+		t.Error("Expected error from ResolveSharedSchemas, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok || !strings.Contains(err.Error(), targets[0].Dir.Path) || !strings.Contains(err.Error(), targets[1].Dir.Path) {
+		t.Errorf("Unexpected error from ResolveSharedSchemas: %v", err)
+	}
+
+	// With allow-shared-schema, the targets are merged
+	targets = []*Target{
+		makeTarget(inst1, "testdata/simple/two", "--allow-shared-schema", "appdb", "bar"),
+		makeTarget(inst1, "testdata/simple/one", "--allow-shared-schema", "appdb", "foo"),
+		makeTarget(inst2, "testdata/simple/one", "--allow-shared-schema", "appdb", "foo"),
+	}
+	result, err := ResolveSharedSchemas(targets)
+	if err != nil || len(result) != 2 {
+		t.Fatalf("Unexpected return from ResolveSharedSchemas: %d targets, err=%v", len(result), err)
+	}
+	if merged := result[0]; merged.Dir != targets[1].Dir || merged.Instance != inst1 || len(merged.DesiredSchema.Tables) != 2 {
+		t.Errorf("Unexpected merged target: dir=%s instance=%s tables=%d", merged.Dir, merged.Instance, len(merged.DesiredSchema.Tables))
+	}
+	if result[1] != targets[2] {
+		t.Error("Expected unshared target to be returned as-is")
+	}
+
+	// Merging is only permitted if all dirs involved enable allow-shared-schema,
+	// and if no object is defined in more than one of them
+	targets = []*Target{
+		makeTarget(inst1, "testdata/simple/two", "--allow-shared-schema", "appdb", "bar"),
+		makeTarget(inst1, "testdata/simple/one", "", "appdb", "foo"),
+	}
+	if _, err := ResolveSharedSchemas(targets); err == nil {
+		t.Error("Expected error from ResolveSharedSchemas, but err was nil")
+	}
+	targets = []*Target{
+		makeTarget(inst1, "testdata/simple/two", "--allow-shared-schema", "appdb", "bar"),
+		makeTarget(inst1, "testdata/simple/one", "--allow-shared-schema", "appdb", "foo", "bar"),
+	}
+	if _, err := ResolveSharedSchemas(targets); err == nil || !strings.Contains(err.Error(), "bar") {
+		t.Errorf("Expected error mentioning duplicate table, instead found %v", err)
+	}
+}
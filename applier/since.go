@@ -0,0 +1,54 @@
+package applier
+
+import (
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// NarrowTargets restricts targets to the objects affected by changes, as
+// obtained from fs.ChangesSince. Targets whose dir has no affected objects are
+// returned separately in unchanged, and should not be processed at all. Targets
+// whose dir, or any parent dir, has a changed option file are kept without any
+// narrowing, since option changes may affect any object. For all other kept
+// targets, diffs of unaffected objects are excluded when processing the
+// target, including any schema-level differences.
+func NarrowTargets(targets []*Target, changes *fs.GitChanges) (kept, unchanged []*Target) {
+	for _, t := range targets {
+		keys, narrowed := changes.ObjectKeys(t.Dir.Path)
+		if !narrowed {
+			t.onlyKeys = nil
+			kept = append(kept, t)
+			continue
+		} else if len(keys) == 0 {
+			unchanged = append(unchanged, t)
+			continue
+		}
+		t.onlyKeys = make(map[tengo.ObjectKey]bool, len(keys))
+		for _, key := range keys {
+			t.onlyKeys[key] = true
+		}
+		kept = append(kept, t)
+	}
+	return kept, unchanged
+}
+
+// includesObject returns false if NarrowTargets excluded the object with the
+// supplied key from t.
+func (t *Target) includesObject(key tengo.ObjectKey) bool {
+	return t.onlyKeys == nil || t.onlyKeys[key]
+}
+
+// narrowObjectDiffs returns the subset of objDiffs which have not been
+// excluded from t by NarrowTargets.
+func (t *Target) narrowObjectDiffs(objDiffs []tengo.ObjectDiff) []tengo.ObjectDiff {
+	if t.onlyKeys == nil {
+		return objDiffs
+	}
+	result := make([]tengo.ObjectDiff, 0, len(objDiffs))
+	for _, objDiff := range objDiffs {
+		if t.includesObject(objDiff.ObjectKey()) {
+			result = append(result, objDiff)
+		}
+	}
+	return result
+}
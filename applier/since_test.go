@@ -0,0 +1,36 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestNarrowObjectDiffs(t *testing.T) {
+	users, posts := brokenFKTestTable("users"), brokenFKTestTable("posts")
+	objDiffs := []tengo.ObjectDiff{
+		tengo.NewCreateTable(users),
+		tengo.NewDropTable(posts),
+		&schemaCommentDiff{SchemaName: "product", Comment: "hello"},
+	}
+
+	// Without narrowing, everything is kept
+	target := &Target{}
+	if narrowed := target.narrowObjectDiffs(objDiffs); len(narrowed) != len(objDiffs) {
+		t.Errorf("Expected all %d diffs to be kept, instead found %d", len(objDiffs), len(narrowed))
+	}
+
+	// With narrowing, only the included objects are kept, and schema-level diffs
+	// are excluded
+	target.onlyKeys = map[tengo.ObjectKey]bool{
+		{Type: tengo.ObjectTypeTable, Name: "posts"}:    true,
+		{Type: tengo.ObjectTypeTable, Name: "comments"}: true,
+	}
+	narrowed := target.narrowObjectDiffs(objDiffs)
+	if len(narrowed) != 1 || narrowed[0].ObjectKey().Name != "posts" || narrowed[0].DiffType() != tengo.DiffTypeDrop {
+		t.Errorf("Unexpected result from narrowObjectDiffs: %+v", narrowed)
+	}
+	if target.includesObject(tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "users"}) {
+		t.Error("Expected includesObject to return false for excluded object")
+	}
+}
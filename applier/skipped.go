@@ -0,0 +1,77 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// SkippedObject describes an object which was excluded from processing, along
+// with the reason why. This occurs for objects which use features that Skeema
+// does not support, and for objects which could not be introspected.
+type SkippedObject struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// String returns a human-readable description of the skipped object.
+func (so SkippedObject) String() string {
+	return fmt.Sprintf("%s %s: %s", so.Type, tengo.EscapeIdentifier(so.Name), so.Reason)
+}
+
+// reIntrospectionObjectError matches errors from tengo's introspection which
+// pertain to a single table or routine, capturing the object type and its
+// escaped name. reUnsupportedRoutineType matches the error for a routine of an
+// unknown type, which uses unescaped names.
+var (
+	reIntrospectionObjectError = regexp.MustCompile("SHOW CREATE (TABLE|PROCEDURE|FUNCTION) (?:for )?`(?:[^`]|``)+`\\.`((?:[^`]|``)+)`")
+	reUnsupportedRoutineType   = regexp.MustCompile(`^Unsupported routine type (\S+) found in [^.]+\.(.+)$`)
+)
+
+// introspectionFailure returns the object responsible for err, an error from
+// introspecting a schema, if err pertains to one specific object. The second
+// return value is false if err is not specific to an object, for example in
+// the case of a connection failure.
+func introspectionFailure(err error) (SkippedObject, bool) {
+	message := err.Error()
+	if matches := reIntrospectionObjectError.FindStringSubmatch(message); matches != nil {
+		return SkippedObject{
+			Type:   strings.ToLower(matches[1]),
+			Name:   strings.Replace(matches[2], "``", "`", -1),
+			Reason: message,
+		}, true
+	}
+	if matches := reUnsupportedRoutineType.FindStringSubmatch(message); matches != nil {
+		return SkippedObject{
+			Type:   strings.ToLower(matches[1]),
+			Name:   matches[2],
+			Reason: message,
+		}, true
+	}
+	return SkippedObject{}, false
+}
+
+// withoutUnsupportedDrops returns objDiffs without any diffs which would drop
+// a table whose live definition uses unsupported features. Since such tables
+// cannot be introspected accurately, dropping them automatically would be
+// risky, and any backup of their definition would be incomplete. The excluded
+// tables are returned as skipped objects.
+func withoutUnsupportedDrops(objDiffs []tengo.ObjectDiff) ([]tengo.ObjectDiff, []SkippedObject) {
+	var skipped []SkippedObject
+	kept := make([]tengo.ObjectDiff, 0, len(objDiffs))
+	for _, objDiff := range objDiffs {
+		if td, ok := objDiff.(*tengo.TableDiff); ok && td.Type == tengo.DiffTypeDrop && td.From.UnsupportedDDL {
+			skipped = append(skipped, SkippedObject{
+				Type:   string(tengo.ObjectTypeTable),
+				Name:   td.From.Name,
+				Reason: "uses unsupported features, so it is not dropped automatically",
+			})
+			continue
+		}
+		kept = append(kept, objDiff)
+	}
+	return kept, skipped
+}
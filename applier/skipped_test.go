@@ -0,0 +1,45 @@
+package applier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestIntrospectionFailure(t *testing.T) {
+	cases := map[string]SkippedObject{
+		"Error executing SHOW CREATE TABLE for `product`.`fed``erated`: Error 1429: Unable to connect to foreign data source": {Type: "table", Name: "fed`erated"},
+		"Error executing SHOW CREATE PROCEDURE for `product`.`proc1`: Error 1305: PROCEDURE proc1 does not exist":             {Type: "procedure", Name: "proc1"},
+		"Failed to parse SHOW CREATE FUNCTION `product`.`func1`: CREATE FUNCTION ...":                                         {Type: "function", Name: "func1"},
+		"Unsupported routine type PACKAGE found in product.pkg1":                                                              {Type: "package", Name: "pkg1"},
+	}
+	for message, expected := range cases {
+		actual, ok := introspectionFailure(errors.New(message))
+		if !ok || actual.Type != expected.Type || actual.Name != expected.Name || actual.Reason != message {
+			t.Errorf("Unexpected result from introspectionFailure(%q): %+v, %t", message, actual, ok)
+		}
+	}
+	if _, ok := introspectionFailure(errors.New("Error querying information_schema.tables for schema product: invalid connection")); ok {
+		t.Error("Expected introspectionFailure to return false for error which is not specific to an object")
+	}
+}
+
+func TestWithoutUnsupportedDrops(t *testing.T) {
+	supported, unsupported, created := brokenFKTestTable("users"), brokenFKTestTable("federated"), brokenFKTestTable("posts")
+	unsupported.UnsupportedDDL = true
+	objDiffs := []tengo.ObjectDiff{
+		tengo.NewDropTable(supported),
+		tengo.NewDropTable(unsupported),
+		tengo.NewCreateTable(created),
+	}
+	kept, skipped := withoutUnsupportedDrops(objDiffs)
+	if len(kept) != 2 || kept[0].ObjectKey().Name != "users" || kept[1].ObjectKey().Name != "posts" {
+		t.Errorf("Unexpected diffs kept: %+v", kept)
+	}
+	if len(skipped) != 1 || skipped[0].Type != "table" || skipped[0].Name != "federated" {
+		t.Errorf("Unexpected skipped objects: %+v", skipped)
+	} else if expected := "table `federated`: uses unsupported features, so it is not dropped automatically"; skipped[0].String() != expected {
+		t.Errorf("Expected String() to return %q, instead found %q", expected, skipped[0].String())
+	}
+}
@@ -0,0 +1,205 @@
+package applier
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// syncStateFile is the format of the file configured by the state-file option.
+// It records the fingerprint of each object as of the last successful pull or
+// push, separately for each instance and schema, keyed by syncStateKey. It
+// also journals in-progress replacements of objects, keyed the same way.
+type syncStateFile struct {
+	Targets map[string]syncState      `json:"targets"`
+	Journal map[string][]journalEntry `json:"journal,omitempty"`
+}
+
+// syncState records the fingerprints of one schema's objects as of the last
+// time a dir and instance were known to match.
+type syncState struct {
+	SyncedAt string            `json:"synced_at"`
+	Command  string            `json:"command"`
+	Objects  map[string]string `json:"objects"` // keyed by ObjectKey.String()
+}
+
+// syncStateMutex prevents concurrent targets sharing a state file from
+// clobbering each other's changes
+var syncStateMutex sync.Mutex
+
+// syncStatePath returns the path of dir's state file, or an empty string if
+// the state-file option is not configured.
+func syncStatePath(dir *fs.Dir) string {
+	if dir.Config.FindOption("state-file") == nil {
+		return ""
+	}
+	name := dir.Config.Get("state-file")
+	if name == "" {
+		return ""
+	}
+	return filepath.Join(dir.Path, name)
+}
+
+func syncStateKey(instance *tengo.Instance, schemaName string) string {
+	return fmt.Sprintf("%s/%s", instance, schemaName)
+}
+
+// syncFingerprints returns the fingerprint of each object in schema, keyed by
+// the object key's string form, excluding the tracking table if one is
+// configured for dir.
+func syncFingerprints(dir *fs.Dir, schema *tengo.Schema) map[string]string {
+	if trackingTableName := dir.Config.Get("tracking-table"); trackingTableName != "" {
+		schema, _ = withoutTable(schema, trackingTableName)
+	}
+	fingerprints := ObjectFingerprints(schema)
+	result := make(map[string]string, len(fingerprints))
+	for key, fp := range fingerprints {
+		if key.Type == tengo.ObjectTypeDatabase {
+			key.Name = "" // schema name may vary by environment or shard
+		}
+		result[key.String()] = fp
+	}
+	return result
+}
+
+func readSyncStateFile(filePath string) (*syncStateFile, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return &syncStateFile{Targets: make(map[string]syncState)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var sf syncStateFile
+	if err := json.Unmarshal(contents, &sf); err != nil {
+		return nil, fmt.Errorf("Unable to parse state file %s: %s", filePath, err)
+	}
+	if sf.Targets == nil {
+		sf.Targets = make(map[string]syncState)
+	}
+	return &sf, nil
+}
+
+// RecordSync updates the state file of dir, if the state-file option is
+// configured, to indicate that schema on instance now matches dir. This should
+// be called after a successful pull. Pushes record their own state.
+func RecordSync(dir *fs.Dir, instance *tengo.Instance, schema *tengo.Schema) error {
+	return recordSync(dir, instance, schema.Name, modelTableOptions(schema, instance.Flavor()), "pull")
+}
+
+func recordSync(dir *fs.Dir, instance *tengo.Instance, schemaName string, schema *tengo.Schema, command string) error {
+	filePath := syncStatePath(dir)
+	if filePath == "" {
+		return nil
+	}
+	syncStateMutex.Lock()
+	defer syncStateMutex.Unlock()
+	sf, err := readSyncStateFile(filePath)
+	if err != nil {
+		return err
+	}
+	sf.Targets[syncStateKey(instance, schemaName)] = syncState{
+		SyncedAt: time.Now().UTC().Format(time.RFC3339),
+		Command:  command,
+		Objects:  syncFingerprints(dir, schema),
+	}
+	contents, err := json.MarshalIndent(sf, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir.Snapshot.Forget(filePath)
+	return fs.WriteFile(filePath, append(contents, '\n'))
+}
+
+// syncConflict describes an object which has changed both on the database
+// server and in the filesystem since the last recorded sync.
+type syncConflict struct {
+	key        tengo.ObjectKey
+	serverDef  string
+	desiredDef string
+}
+
+// String returns a description of the conflict including both definitions.
+func (sc syncConflict) String() string {
+	describe := func(def string) string {
+		if def == "" {
+			return "    (does not exist)"
+		}
+		return "    " + strings.Replace(def, "\n", "\n    ", -1)
+	}
+	return fmt.Sprintf("%s was modified on the server and in the filesystem since the last pull or push.\n  Server definition:\n%s\n  Filesystem definition:\n%s", sc.key, describe(sc.serverDef), describe(sc.desiredDef))
+}
+
+// syncConflicts returns any objects in keys whose definition in from (the
+// server) and in to (the filesystem) both differ from the fingerprint recorded
+// in t's state file. Objects changed on only one side are not conflicts. If no
+// state file is configured, or no state has been recorded for t, nothing is
+// returned.
+func (t *Target) syncConflicts(keys []tengo.ObjectKey, from, to *tengo.Schema) ([]syncConflict, error) {
+	filePath := syncStatePath(t.Dir)
+	if filePath == "" || len(keys) == 0 {
+		return nil, nil
+	}
+	syncStateMutex.Lock()
+	sf, err := readSyncStateFile(filePath)
+	syncStateMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	state, ok := sf.Targets[syncStateKey(t.Instance, t.SchemaName)]
+	if !ok {
+		return nil, nil
+	}
+	fromFP, toFP := syncFingerprints(t.Dir, from), syncFingerprints(t.Dir, to)
+	fromDefs, toDefs := syncDefinitions(from), syncDefinitions(to)
+	var conflicts []syncConflict
+	seen := make(map[tengo.ObjectKey]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fpKey := key
+		if key.Type == tengo.ObjectTypeDatabase {
+			fpKey.Name = ""
+		}
+		recorded := fingerprintOrAbsent(state.Objects, fpKey.String())
+		server := fingerprintOrAbsent(fromFP, fpKey.String())
+		desired := fingerprintOrAbsent(toFP, fpKey.String())
+		if server != recorded && desired != recorded && server != desired {
+			conflicts = append(conflicts, syncConflict{
+				key:        key,
+				serverDef:  fromDefs[fpKey.String()],
+				desiredDef: toDefs[fpKey.String()],
+			})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].key.String() < conflicts[j].key.String()
+	})
+	return conflicts, nil
+}
+
+// syncDefinitions returns the definitions of all objects in schema, including
+// the schema itself, for display in conflict messages. The result is keyed in
+// the same manner as syncFingerprints.
+func syncDefinitions(schema *tengo.Schema) map[string]string {
+	defs := make(map[string]string)
+	if schema == nil {
+		return defs
+	}
+	for key, create := range schema.ObjectDefinitions() {
+		defs[key.String()] = create
+	}
+	schemaKey := tengo.ObjectKey{Type: tengo.ObjectTypeDatabase}
+	defs[schemaKey.String()] = fmt.Sprintf("CHARACTER SET %s COLLATE %s", schema.CharSet, schema.Collation)
+	return defs
+}
@@ -0,0 +1,71 @@
+package applier
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestSyncConflicts(t *testing.T) {
+	if err := os.MkdirAll("testdata/.scratch", 0777); err != nil {
+		t.Fatalf("Unable to create scratch dir: %s", err)
+	}
+	defer os.RemoveAll("testdata/.scratch")
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	target := &Target{
+		Instance:   inst,
+		Dir:        getDir(t, "testdata/simple/one", "--state-file=../../.scratch/state.json"),
+		SchemaName: "product",
+	}
+	newSchema := func(tables ...*tengo.Table) *tengo.Schema {
+		return &tengo.Schema{Name: "product", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", Tables: tables}
+	}
+	alteredTable := func(name, colName string) *tengo.Table {
+		table := brokenFKTestTable(name)
+		table.Columns = append(table.Columns, &tengo.Column{Name: colName, TypeInDB: "int(11)", Nullable: true, Default: tengo.ColumnDefaultNull})
+		table.CreateStatement = table.GeneratedCreateStatement(tengo.FlavorMySQL57)
+		return table
+	}
+	usersKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "users"}
+	postsKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "posts"}
+	commentsKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "comments"}
+	keys := []tengo.ObjectKey{usersKey, postsKey, commentsKey}
+
+	// No conflicts can be detected before any sync is recorded
+	synced := newSchema(brokenFKTestTable("users"), brokenFKTestTable("posts"))
+	server := newSchema(alteredTable("users", "server_col"), alteredTable("posts", "server_col"))
+	desired := newSchema(alteredTable("users", "fs_col"), brokenFKTestTable("posts"), brokenFKTestTable("comments"))
+	if conflicts, err := target.syncConflicts(keys, server, desired); len(conflicts) > 0 || err != nil {
+		t.Fatalf("Expected no conflicts without state file, instead found %v, %v", conflicts, err)
+	}
+
+	// After recording a sync: users changed on both sides; posts only changed on
+	// the server; comments only changed in the filesystem
+	if err := recordSync(target.Dir, target.Instance, target.SchemaName, synced, "push"); err != nil {
+		t.Fatalf("Unexpected error from recordSync: %s", err)
+	}
+	conflicts, err := target.syncConflicts(keys, server, desired)
+	if err != nil || len(conflicts) != 1 || conflicts[0].key != usersKey {
+		t.Fatalf("Unexpected result from syncConflicts: %v, %v", conflicts, err)
+	}
+	if desc := conflicts[0].String(); !strings.Contains(desc, "`server_col`") || !strings.Contains(desc, "`fs_col`") {
+		t.Errorf("Expected conflict description to include both definitions, instead found %s", desc)
+	}
+
+	// State is tracked separately for each schema on the instance
+	target.SchemaName = "product_2"
+	if conflicts, err := target.syncConflicts(keys, server, desired); len(conflicts) > 0 || err != nil {
+		t.Errorf("Expected no conflicts for schema without recorded state, instead found %v, %v", conflicts, err)
+	}
+
+	// Identical changes on both sides are not a conflict
+	target.SchemaName = "product"
+	if conflicts, err := target.syncConflicts(keys, server, server); len(conflicts) > 0 || err != nil {
+		t.Errorf("Expected no conflicts for matching changes, instead found %v, %v", conflicts, err)
+	}
+}
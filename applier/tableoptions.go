@@ -0,0 +1,250 @@
+package applier
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// tableOptionRule describes how a table creation option is handled beyond
+// tengo's generic treatment of create_options.
+type tableOptionRule struct {
+	reset   string // value which restores the default, if tengo's DEFAULT is not valid syntax
+	rebuild bool   // true if changing the option causes ALTER TABLE to rebuild the entire table
+}
+
+// tableOptionRules is the normalization table for table creation options which
+// need special handling. Options which information_schema does not report in
+// create_options are additionally modeled by modelTableOptions.
+var tableOptionRules = map[string]tableOptionRule{
+	"ROW_FORMAT":      {rebuild: true},
+	"KEY_BLOCK_SIZE":  {rebuild: true},
+	"ENCRYPTION":      {reset: "'N'", rebuild: true},
+	"TABLESPACE":      {reset: "`innodb_file_per_table`", rebuild: true},
+	"AUTOEXTEND_SIZE": {reset: "0"},
+}
+
+// reVersionedTableOption matches a table option which SHOW CREATE TABLE wraps
+// in a version-gated comment at the end of the table options, and which
+// information_schema does not report in create_options. Currently this is
+// only MySQL 8.0.23+'s AUTOEXTEND_SIZE, which is only shown when set to a
+// non-default value.
+var reVersionedTableOption = regexp.MustCompile(`/\*!\d{5} (AUTOEXTEND_SIZE=\d+) \*/`)
+
+// reTablespaceClause matches the TABLESPACE clause of SHOW CREATE TABLE, which
+// is version-gated and precedes all other table options, and which
+// information_schema does not report in create_options. It is only shown if
+// the table was created with an explicit TABLESPACE.
+var reTablespaceClause = regexp.MustCompile("\n\\) /\\*!50100 TABLESPACE (`[^`\\s]+`) \\*/ ENGINE=")
+
+// tableOption represents a single name=value pair from a table's
+// create_options.
+type tableOption struct {
+	name string // always uppercase
+	def  string // full original name=value text
+}
+
+// splitTableOptions parses a table's CreateOptions field into an ordered
+// slice of options.
+func splitTableOptions(createOptions string) []tableOption {
+	var opts []tableOption
+	for _, def := range strings.Fields(createOptions) {
+		name := strings.SplitN(def, "=", 2)[0]
+		opts = append(opts, tableOption{name: strings.ToUpper(name), def: def})
+	}
+	return opts
+}
+
+// joinTableOptions is the inverse of splitTableOptions.
+func joinTableOptions(opts []tableOption) string {
+	defs := make([]string, len(opts))
+	for n, opt := range opts {
+		defs[n] = opt.def
+	}
+	return strings.Join(defs, " ")
+}
+
+// tableOptionsByName returns a map of uppercased option name to definition
+// for the supplied CreateOptions field.
+func tableOptionsByName(createOptions string) map[string]string {
+	result := make(map[string]string)
+	for _, opt := range splitTableOptions(createOptions) {
+		result[opt.name] = opt.def
+	}
+	return result
+}
+
+// parseIgnoreTableOptions converts the supplied ignore-table-options value
+// (a comma-separated list of table option names) into a slice of uppercased
+// option names.
+func parseIgnoreTableOptions(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.ToUpper(strings.TrimSpace(name)); name != "" {
+			names = append(names, strings.Replace(name, " ", "_", -1))
+		}
+	}
+	return names
+}
+
+// ignoreTableOptions returns a shallow copy of from, in which the supplied
+// table creation options of each table are replaced with the values from the
+// corresponding table in to. This prevents differences in these options from
+// appearing in a diff between from and to. Tables only present on one side, or
+// using features unsupported by tengo, are left as-is.
+func ignoreTableOptions(from, to *tengo.Schema, names []string, flavor tengo.Flavor) *tengo.Schema {
+	if from == nil || to == nil || len(names) == 0 {
+		return from
+	}
+	ignored := make(map[string]bool, len(names))
+	for _, name := range names {
+		ignored[name] = true
+	}
+	toTables := to.TablesByName()
+	schemaCopy := *from
+	schemaCopy.Tables = make([]*tengo.Table, len(from.Tables))
+	for n, fromTable := range from.Tables {
+		schemaCopy.Tables[n] = fromTable
+		toTable, ok := toTables[fromTable.Name]
+		if !ok || fromTable.UnsupportedDDL || toTable.UnsupportedDDL {
+			continue
+		}
+		createOptions := mergeTableOptions(fromTable.CreateOptions, toTable.CreateOptions, ignored)
+		if createOptions != fromTable.CreateOptions {
+			tableCopy := *fromTable
+			tableCopy.CreateOptions = createOptions
+			tableCopy.CreateStatement = tableCopy.GeneratedCreateStatement(flavor)
+			schemaCopy.Tables[n] = &tableCopy
+		}
+	}
+	return &schemaCopy
+}
+
+// mergeTableOptions returns fromOptions, modified such that any option in
+// ignored has the value (or absence) from toOptions. Options added to the
+// result are positioned based on toOptions' ordering.
+func mergeTableOptions(fromOptions, toOptions string, ignored map[string]bool) string {
+	fromOpts := splitTableOptions(fromOptions)
+	toOpts := splitTableOptions(toOptions)
+	toDefs := tableOptionsByName(toOptions)
+
+	// Replace or remove ignored options that are present in from
+	result := make([]tableOption, 0, len(fromOpts)+len(toOpts))
+	present := make(map[string]bool, len(fromOpts))
+	for _, opt := range fromOpts {
+		present[opt.name] = true
+		if !ignored[opt.name] {
+			result = append(result, opt)
+		} else if def, ok := toDefs[opt.name]; ok {
+			result = append(result, tableOption{name: opt.name, def: def})
+		}
+	}
+
+	// Add ignored options that are only present in to, after whichever option
+	// precedes them in to
+	for n, opt := range toOpts {
+		if !ignored[opt.name] || present[opt.name] {
+			continue
+		}
+		pos := 0
+		for prev := n - 1; prev >= 0 && pos == 0; prev-- {
+			for i, existing := range result {
+				if existing.name == toOpts[prev].name {
+					pos = i + 1
+					break
+				}
+			}
+		}
+		result = append(result[:pos], append([]tableOption{opt}, result[pos:]...)...)
+	}
+	return joinTableOptions(result)
+}
+
+// rebuildTableOptionChanges returns the sorted names of any table creation
+// options changed by diff which require a full table rebuild, such as
+// ROW_FORMAT, KEY_BLOCK_SIZE, ENCRYPTION, or TABLESPACE. The result is nil if
+// diff is not an ALTER TABLE or does not change any such options.
+func rebuildTableOptionChanges(diff tengo.ObjectDiff) []string {
+	td, ok := diff.(*tengo.TableDiff)
+	if !ok || td.Type != tengo.DiffTypeAlter || td.From.CreateOptions == td.To.CreateOptions {
+		return nil
+	}
+	fromDefs := tableOptionsByName(td.From.CreateOptions)
+	toDefs := tableOptionsByName(td.To.CreateOptions)
+	var changed []string
+	for name, rule := range tableOptionRules {
+		if rule.rebuild && fromDefs[name] != toDefs[name] {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// modelTableOptions returns a shallow copy of schema, in which any table that
+// tengo considers unsupported solely due to versioned table options missing
+// from its create_options, such as AUTOEXTEND_SIZE or TABLESPACE, or due to
+// column attributes that tengo does not handle, has those options added to its
+// CreateOptions and those attributes modeled by modelColumns, and is no longer
+// marked as unsupported. This permits such options and attributes to be
+// compared and altered like any others; see also fixColumnDefinitions. Each
+// table's CreateStatement is left as-is.
+func modelTableOptions(schema *tengo.Schema, flavor tengo.Flavor) *tengo.Schema {
+	if schema == nil {
+		return nil
+	}
+	schemaCopy := *schema
+	schemaCopy.Tables = make([]*tengo.Table, len(schema.Tables))
+	for n, table := range schema.Tables {
+		schemaCopy.Tables[n] = table
+		if !table.UnsupportedDDL {
+			continue
+		}
+		tableCopy := *table
+		tableCopy.Columns = modelColumns(table)
+		actual := reVersionedTableOption.ReplaceAllString(table.CreateStatement, "$1")
+		var tablespace string
+		if matches := reTablespaceClause.FindStringSubmatch(actual); matches != nil {
+			tablespace = matches[1]
+			actual = strings.Replace(actual, matches[0], "\n) ENGINE=", 1)
+		}
+		actual, _ = tengo.ParseCreateAutoInc(actual)
+
+		// Use the generated CREATE, with a placeholder for the create options, to
+		// build a regexp that extracts the create options from the actual CREATE
+		if reVersionedTableOption.MatchString(table.CreateStatement) || tablespace != "" {
+			createOptions := tableCopy.CreateOptions
+			tableCopy.CreateOptions = "!!!CREATEOPTS!!!"
+			template, _ := tengo.ParseCreateAutoInc(generatedCreateStatement(&tableCopy, flavor))
+			template = regexp.QuoteMeta(template)
+			template = strings.Replace(template, "!!!CREATEOPTS!!!", "(.+?)", 1)
+			tableCopy.CreateOptions = createOptions
+			if matches := regexp.MustCompile("^" + template + "$").FindStringSubmatch(actual); matches != nil {
+				tableCopy.CreateOptions = matches[1]
+			}
+		}
+		if expected, _ := tengo.ParseCreateAutoInc(generatedCreateStatement(&tableCopy, flavor)); expected == actual {
+			if tablespace != "" {
+				tableCopy.CreateOptions = strings.TrimSpace(tableCopy.CreateOptions + " TABLESPACE=" + tablespace)
+			}
+			tableCopy.UnsupportedDDL = false
+			schemaCopy.Tables[n] = &tableCopy
+		}
+	}
+	return &schemaCopy
+}
+
+// fixTableOptionDefaults adjusts an ALTER TABLE statement generated by tengo,
+// so that any table options in tableOptionRules which are being reset are set
+// to their actual default values. tengo otherwise uses DEFAULT, which is not
+// valid syntax for these options.
+func fixTableOptionDefaults(stmt string) string {
+	for name, rule := range tableOptionRules {
+		if rule.reset != "" {
+			stmt = strings.Replace(stmt, " "+name+"=DEFAULT", " "+name+"="+rule.reset, -1)
+		}
+	}
+	return stmt
+}
@@ -0,0 +1,241 @@
+package applier
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestParseIgnoreTableOptions(t *testing.T) {
+	actual := parseIgnoreTableOptions(" key_block_size, STATS_PERSISTENT,,row format ")
+	expected := []string{"KEY_BLOCK_SIZE", "STATS_PERSISTENT", "ROW_FORMAT"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected result from parseIgnoreTableOptions: %v", actual)
+	}
+	if actual := parseIgnoreTableOptions(""); len(actual) != 0 {
+		t.Errorf("Expected blank value to return no names, instead found %v", actual)
+	}
+}
+
+func TestMergeTableOptions(t *testing.T) {
+	ignored := map[string]bool{"KEY_BLOCK_SIZE": true, "ENCRYPTION": true}
+	cases := []struct {
+		from     string
+		to       string
+		expected string
+	}{
+		{"ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8", "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=4", "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=4"},
+		{"ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8", "ROW_FORMAT=DYNAMIC", "ROW_FORMAT=COMPRESSED"},
+		{"ROW_FORMAT=DYNAMIC", "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=4", "ROW_FORMAT=DYNAMIC KEY_BLOCK_SIZE=4"},
+		{"STATS_PERSISTENT=1", "ENCRYPTION='Y' STATS_PERSISTENT=0", "ENCRYPTION='Y' STATS_PERSISTENT=1"},
+		{"", "KEY_BLOCK_SIZE=8", "KEY_BLOCK_SIZE=8"},
+		{"KEY_BLOCK_SIZE=8", "", ""},
+		{"MAX_ROWS=100", "MAX_ROWS=200", "MAX_ROWS=100"},
+	}
+	for _, c := range cases {
+		if actual := mergeTableOptions(c.from, c.to, ignored); actual != c.expected {
+			t.Errorf("mergeTableOptions(%q, %q): expected %q, found %q", c.from, c.to, c.expected, actual)
+		}
+	}
+}
+
+func TestIgnoreTableOptions(t *testing.T) {
+	makeTable := func(createOptions string) *tengo.Table {
+		table := &tengo.Table{
+			Name:               "foo",
+			Engine:             "InnoDB",
+			CharSet:            "utf8mb4",
+			Collation:          "utf8mb4_general_ci",
+			CollationIsDefault: true,
+			CreateOptions:      createOptions,
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int(10) unsigned"},
+			},
+		}
+		table.CreateStatement = table.GeneratedCreateStatement(tengo.FlavorUnknown)
+		return table
+	}
+	from := &tengo.Schema{Name: "product", Tables: []*tengo.Table{makeTable("ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8")}}
+	to := &tengo.Schema{Name: "product", Tables: []*tengo.Table{makeTable("ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=4")}}
+
+	if diffFrom := ignoreTableOptions(from, to, nil, tengo.FlavorUnknown); diffFrom != from {
+		t.Error("Expected ignoreTableOptions to return original schema when no options are ignored")
+	}
+	diffFrom := ignoreTableOptions(from, to, []string{"KEY_BLOCK_SIZE"}, tengo.FlavorUnknown)
+	if diffFrom.Tables[0].CreateStatement != to.Tables[0].CreateStatement {
+		t.Errorf("Expected tables to now match, but CREATEs differ:\n%s\n%s", diffFrom.Tables[0].CreateStatement, to.Tables[0].CreateStatement)
+	}
+	if from.Tables[0].CreateOptions != "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8" {
+		t.Errorf("Expected original schema to be unmodified, but CreateOptions changed to %q", from.Tables[0].CreateOptions)
+	}
+	if objDiffs := tengo.NewSchemaDiff(diffFrom, to).ObjectDiffs(); len(objDiffs) != 0 {
+		t.Errorf("Expected no differences, instead found %d", len(objDiffs))
+	}
+}
+
+func TestRebuildTableOptionChanges(t *testing.T) {
+	from := &tengo.Table{Name: "foo", CreateOptions: "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8 STATS_PERSISTENT=1"}
+	to := &tengo.Table{Name: "foo", CreateOptions: "ROW_FORMAT=DYNAMIC STATS_PERSISTENT=1 ENCRYPTION='Y'"}
+	diff := tengo.NewAlterTable(from, to)
+	expected := []string{"ENCRYPTION", "KEY_BLOCK_SIZE", "ROW_FORMAT"}
+	if actual := rebuildTableOptionChanges(diff); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected result from rebuildTableOptionChanges: %v", actual)
+	}
+
+	to = &tengo.Table{Name: "foo", CreateOptions: "ROW_FORMAT=COMPRESSED KEY_BLOCK_SIZE=8 STATS_PERSISTENT=0"}
+	if actual := rebuildTableOptionChanges(tengo.NewAlterTable(from, to)); len(actual) != 0 {
+		t.Errorf("Expected no rebuild options to be changed, instead found %v", actual)
+	}
+	if actual := rebuildTableOptionChanges(tengo.NewCreateTable(to)); len(actual) != 0 {
+		t.Errorf("Expected CREATE TABLE to not return any rebuild options, instead found %v", actual)
+	}
+}
+
+func TestModelTableOptions(t *testing.T) {
+	flavor := tengo.FlavorMySQL80
+	// makeTable simulates introspection of a table by tengo: information_schema
+	// reports createOptions, while SHOW CREATE TABLE also includes any versioned
+	// options
+	makeTable := func(createOptions, versioned string) *tengo.Table {
+		table := &tengo.Table{
+			Name:               "foo",
+			Engine:             "InnoDB",
+			CharSet:            "utf8mb4",
+			Collation:          "utf8mb4_0900_ai_ci",
+			CollationIsDefault: true,
+			CreateOptions:      createOptions,
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int unsigned"},
+			},
+		}
+		table.CreateOptions = createOptions + versioned
+		table.CreateStatement = table.GeneratedCreateStatement(flavor)
+		table.CreateOptions = createOptions
+		table.UnsupportedDDL = (versioned != "")
+		return table
+	}
+
+	withAutoextend := makeTable("STATS_PERSISTENT=1 STATS_AUTO_RECALC=0", " /*!80023 AUTOEXTEND_SIZE=8388608 */")
+	schema := modelTableOptions(&tengo.Schema{Name: "product", Tables: []*tengo.Table{withAutoextend}}, flavor)
+	modeled := schema.Tables[0]
+	if modeled.UnsupportedDDL || modeled.CreateOptions != "STATS_PERSISTENT=1 STATS_AUTO_RECALC=0 AUTOEXTEND_SIZE=8388608" || modeled.CreateStatement != withAutoextend.CreateStatement {
+		t.Errorf("Unexpected result from modelTableOptions: %+v", modeled)
+	}
+	if !withAutoextend.UnsupportedDDL {
+		t.Error("Expected original table to be unmodified, but it was changed")
+	}
+
+	// Tables which are unsupported for other reasons remain unsupported
+	other := makeTable("", " /*!80023 AUTOEXTEND_SIZE=8388608 */")
+	other.CreateStatement = strings.Replace(other.CreateStatement, "`id` int unsigned", "`id` int unsigned /*!50606 STORAGE MEMORY */", 1)
+	if schema := modelTableOptions(&tengo.Schema{Tables: []*tengo.Table{other}}, flavor); !schema.Tables[0].UnsupportedDDL {
+		t.Error("Expected table with other unsupported features to remain unsupported")
+	}
+
+	// Options present on the server but absent in the filesystem are reset to
+	// their defaults, and vice versa
+	plain := makeTable("", "")
+	cases := []struct {
+		from, to *tengo.Table
+		expected string
+	}{
+		{modeled, plain, "AUTOEXTEND_SIZE=0"},
+		{plain, modeled, "AUTOEXTEND_SIZE=8388608"},
+		{modeled, plain, "STATS_AUTO_RECALC=DEFAULT"},
+		{plain, modeled, "STATS_PERSISTENT=1"},
+	}
+	for _, c := range cases {
+		from := &tengo.Schema{Name: "product", Tables: []*tengo.Table{c.from}}
+		to := &tengo.Schema{Name: "product", Tables: []*tengo.Table{c.to}}
+		objDiffs := tengo.NewSchemaDiff(from, to).ObjectDiffs()
+		if len(objDiffs) != 1 {
+			t.Fatalf("Expected 1 diff, instead found %d", len(objDiffs))
+		}
+		stmt, err := objDiffs[0].Statement(tengo.StatementModifiers{})
+		if err != nil {
+			t.Fatalf("Unexpected error from Statement: %s", err)
+		}
+		if stmt = fixTableOptionDefaults(stmt); !strings.Contains(stmt, c.expected) || strings.Contains(stmt, "AUTOEXTEND_SIZE=DEFAULT") {
+			t.Errorf("Expected statement to contain %q, instead found %q", c.expected, stmt)
+		}
+	}
+}
+
+func TestTableOptionDiffs(t *testing.T) {
+	flavor := tengo.FlavorMySQL80
+	// makeTable simulates introspection of a table by tengo. If tablespace is
+	// non-blank, SHOW CREATE TABLE includes it, but information_schema does not.
+	makeTable := func(createOptions, tablespace string) *tengo.Table {
+		table := &tengo.Table{
+			Name:               "foo",
+			Engine:             "InnoDB",
+			CharSet:            "utf8mb4",
+			Collation:          "utf8mb4_0900_ai_ci",
+			CollationIsDefault: true,
+			CreateOptions:      createOptions,
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int unsigned"},
+			},
+		}
+		table.CreateStatement = table.GeneratedCreateStatement(flavor)
+		if tablespace != "" {
+			table.CreateStatement = strings.Replace(table.CreateStatement, "\n) ENGINE=", "\n) /*!50100 TABLESPACE "+tablespace+" */ ENGINE=", 1)
+			table.UnsupportedDDL = true
+		}
+		return table
+	}
+	diffStatement := func(from, to *tengo.Table) string {
+		t.Helper()
+		fromSchema := modelTableOptions(&tengo.Schema{Name: "product", Tables: []*tengo.Table{from}}, flavor)
+		toSchema := modelTableOptions(&tengo.Schema{Name: "product", Tables: []*tengo.Table{to}}, flavor)
+		objDiffs := tengo.NewSchemaDiff(fromSchema, toSchema).ObjectDiffs()
+		if len(objDiffs) != 1 {
+			t.Fatalf("Expected 1 diff, instead found %d", len(objDiffs))
+		}
+		stmt, err := objDiffs[0].Statement(tengo.StatementModifiers{})
+		if err != nil {
+			t.Fatalf("Unexpected error from Statement: %s", err)
+		}
+		return fixTableOptionDefaults(stmt)
+	}
+
+	plain := makeTable("STATS_PERSISTENT=1", "")
+	cases := []struct {
+		name        string
+		with        *tengo.Table
+		expectAdd   string
+		expectReset string
+	}{
+		{"ROW_FORMAT", makeTable("STATS_PERSISTENT=1 ROW_FORMAT=COMPRESSED", ""), "ROW_FORMAT=COMPRESSED", "ROW_FORMAT=DEFAULT"},
+		{"KEY_BLOCK_SIZE", makeTable("STATS_PERSISTENT=1 KEY_BLOCK_SIZE=8", ""), "KEY_BLOCK_SIZE=8", "KEY_BLOCK_SIZE=0"},
+		{"ENCRYPTION", makeTable("STATS_PERSISTENT=1 ENCRYPTION='Y'", ""), "ENCRYPTION='Y'", "ENCRYPTION='N'"},
+		{"TABLESPACE", makeTable("STATS_PERSISTENT=1", "`ts1`"), "TABLESPACE=`ts1`", "TABLESPACE=`innodb_file_per_table`"},
+	}
+	for _, c := range cases {
+		if stmt := diffStatement(plain, c.with); !strings.Contains(stmt, c.expectAdd) || strings.Contains(stmt, "STATS_PERSISTENT") {
+			t.Errorf("%s: expected statement to contain %q, instead found %q", c.name, c.expectAdd, stmt)
+		}
+		if stmt := diffStatement(c.with, plain); !strings.Contains(stmt, c.expectReset) || strings.Contains(stmt, "STATS_PERSISTENT") {
+			t.Errorf("%s: expected statement to contain %q, instead found %q", c.name, c.expectReset, stmt)
+		}
+		diff := tengo.NewAlterTable(plain, modelTableOptions(&tengo.Schema{Tables: []*tengo.Table{c.with}}, flavor).Tables[0])
+		if changed := rebuildTableOptionChanges(diff); !reflect.DeepEqual(changed, []string{c.name}) {
+			t.Errorf("%s: unexpected result from rebuildTableOptionChanges: %v", c.name, changed)
+		}
+	}
+
+	// Tables using the same tablespace have no differences, while changing the
+	// tablespace alters it directly
+	ts1, ts2 := makeTable("", "`ts1`"), makeTable("", "`ts2`")
+	if objDiffs := tengo.NewSchemaDiff(modelTableOptions(&tengo.Schema{Tables: []*tengo.Table{ts1}}, flavor), modelTableOptions(&tengo.Schema{Tables: []*tengo.Table{makeTable("", "`ts1`")}}, flavor)).ObjectDiffs(); len(objDiffs) != 0 {
+		t.Errorf("Expected no differences between tables in the same tablespace, instead found %d", len(objDiffs))
+	}
+	if stmt := diffStatement(ts1, ts2); !strings.HasSuffix(stmt, " TABLESPACE=`ts2`") {
+		t.Errorf("Unexpected statement changing tablespace: %q", stmt)
+	}
+	if modeled := modelTableOptions(&tengo.Schema{Tables: []*tengo.Table{ts1}}, flavor).Tables[0]; modeled.UnsupportedDDL || modeled.CreateOptions != "TABLESPACE=`ts1`" {
+		t.Errorf("Unexpected result from modelTableOptions: %+v", modeled)
+	}
+}
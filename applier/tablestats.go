@@ -0,0 +1,140 @@
+package applier
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/tengo"
+)
+
+// TableStats describes the size of an existing table affected by a diff, as
+// reported by the server when the diff was generated. Values other than exact
+// row counts come from information_schema and are estimates.
+type TableStats struct {
+	Rows               int64  `json:"rows"`
+	RowsExact          bool   `json:"rows_exact,omitempty"` // true if Rows is an exact count rather than an estimate
+	DataLength         int64  `json:"data_length"`
+	IndexLength        int64  `json:"index_length"`
+	LargestIndex       string `json:"largest_index,omitempty"` // empty if unknown
+	LargestIndexLength int64  `json:"largest_index_length,omitempty"`
+	unknown            bool   // true if stats could not be obtained
+}
+
+// String returns a human-readable summary of stats, suitable for use in a
+// SQL comment.
+func (stats *TableStats) String() string {
+	if stats.unknown {
+		return "stats: unknown"
+	}
+	rows := fmt.Sprintf("~%d", stats.Rows)
+	if stats.RowsExact {
+		rows = fmt.Sprintf("%d", stats.Rows)
+	}
+	result := fmt.Sprintf("stats: %s rows, %s data, %s indexes", rows, formatSize(stats.DataLength), formatSize(stats.IndexLength))
+	if stats.LargestIndex != "" {
+		result = fmt.Sprintf("%s (largest %s: %s)", result, tengo.EscapeIdentifier(stats.LargestIndex), formatSize(stats.LargestIndexLength))
+	}
+	return result
+}
+
+// formatSize returns a human-readable representation of a size in bytes.
+func formatSize(size int64) string {
+	units := []string{"KB", "MB", "GB", "TB"}
+	if size < 1024 {
+		return fmt.Sprintf("%d bytes", size)
+	}
+	value := float64(size)
+	var unit string
+	for _, unit = range units {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %s", value, unit)
+}
+
+// tableStatsForDiff returns stats for the existing table affected by diff on
+// target's instance. If the stats cannot be obtained, the returned value
+// indicates that they are unknown, rather than an error being returned, since
+// stats are purely informational. Exact row counts are only obtained for
+// InnoDB tables if the exact-counts option is enabled. If the table's status
+// was prefetched, it is not queried again.
+func tableStatsForDiff(target *Target, diff tengo.ObjectDiff) *TableStats {
+	exact := target.Dir.Config.GetBool("exact-counts")
+	var stats *TableStats
+	var err error
+	if ts, ok := target.tableStatus[diff.ObjectKey().Name]; ok {
+		stats, err = tableStatsFromStatus(target.Instance, target.SchemaName, ts, exact)
+	} else {
+		stats, err = getTableStats(target.Instance, target.SchemaName, diff.ObjectKey().Name, exact)
+	}
+	if err != nil {
+		log.Debugf("Unable to obtain stats for %s: %s", diff.ObjectKey(), err)
+		return &TableStats{unknown: true}
+	}
+	return stats
+}
+
+// getTableStats queries inst for stats about the named table. The queries used
+// are inexpensive, unless exact is true, in which case the rows of InnoDB
+// tables are counted.
+func getTableStats(inst *tengo.Instance, schemaName, tableName string, exact bool) (*TableStats, error) {
+	status, err := loadTableStatus(inst, schemaName, []string{tableName}, 1, false)
+	if err != nil {
+		return nil, err
+	} else if status[tableName] == nil {
+		return nil, sql.ErrNoRows
+	}
+	return tableStatsFromStatus(inst, schemaName, status[tableName], exact)
+}
+
+// tableStatsFromStatus returns stats for a table based on its already-obtained
+// status, querying inst only for exact row counts and index sizes.
+func tableStatsFromStatus(inst *tengo.Instance, schemaName string, ts *tableStatus, exact bool) (*TableStats, error) {
+	db, err := inst.Connect(schemaName, "")
+	if err != nil {
+		return nil, err
+	}
+	tableName := ts.Name
+	stats := &TableStats{
+		Rows:        ts.Rows.Int64,
+		DataLength:  ts.DataLength.Int64,
+		IndexLength: ts.IndexLength.Int64,
+	}
+
+	// MyISAM and Aria track exact row counts, so information_schema is already
+	// accurate for them
+	switch strings.ToLower(ts.Engine.String) {
+	case "myisam", "aria":
+		stats.RowsExact = true
+	default:
+		if exact {
+			query := fmt.Sprintf("SELECT COUNT(*) FROM %s", tengo.EscapeIdentifier(tableName))
+			if err := db.QueryRow(query).Scan(&stats.Rows); err != nil {
+				return nil, err
+			}
+			stats.RowsExact = true
+		}
+	}
+
+	// Persistent InnoDB stats track the size of each index in pages. This is
+	// not available for other storage engines, or if the user lacks privileges
+	// on the mysql schema, in which case the largest index is simply omitted.
+	query := `
+		SELECT   index_name, stat_value * @@innodb_page_size
+		FROM     mysql.innodb_index_stats
+		WHERE    database_name = ? AND table_name = ? AND stat_name = 'size'
+		ORDER BY stat_value DESC, index_name
+		LIMIT    1`
+	var indexName string
+	var indexSize int64
+	if err := db.QueryRow(query, schemaName, tableName).Scan(&indexName, &indexSize); err == nil {
+		stats.LargestIndex, stats.LargestIndexLength = indexName, indexSize
+	} else if err != sql.ErrNoRows {
+		log.Debugf("Unable to obtain index sizes for %s.%s: %s", tengo.EscapeIdentifier(schemaName), tengo.EscapeIdentifier(tableName), err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,71 @@
+package applier
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTableStatsString(t *testing.T) {
+	cases := []struct {
+		stats    TableStats
+		expected string
+	}{
+		{TableStats{unknown: true}, "stats: unknown"},
+		{TableStats{Rows: 1234, DataLength: 16384, IndexLength: 0}, "stats: ~1234 rows, 16.0 KB data, 0 bytes indexes"},
+		{TableStats{Rows: 5, RowsExact: true, DataLength: 900, IndexLength: 3 * 1024 * 1024, LargestIndex: "idx_name", LargestIndexLength: 2 * 1024 * 1024}, "stats: 5 rows, 900 bytes data, 3.0 MB indexes (largest `idx_name`: 2.0 MB)"},
+	}
+	for _, c := range cases {
+		if actual := c.stats.String(); actual != c.expected {
+			t.Errorf("Expected %+v to format as %q, instead found %q", c.stats, c.expected, actual)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	cases := map[int64]string{
+		0:                      "0 bytes",
+		1023:                   "1023 bytes",
+		1536:                   "1.5 KB",
+		5 * 1024 * 1024 * 1024: "5.0 GB",
+		1 << 50:                "1024.0 TB",
+	}
+	for input, expected := range cases {
+		if actual := formatSize(input); actual != expected {
+			t.Errorf("Expected formatSize(%d) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+}
+
+func TestFormatDDLStats(t *testing.T) {
+	_, ddls := getFormatTestDDL(t)
+	ddl := ddls[1]
+	ddl.stats = &TableStats{Rows: 10, DataLength: 16384, IndexLength: 16384}
+	expected := "-- stats: ~10 rows, 16.0 KB data, 16.0 KB indexes\n/* [alter] */ ALTER TABLE `posts` ADD COLUMN `body` text;\n"
+	if actual := formatDDL(ddl, true, false); actual != expected {
+		t.Errorf("Unexpected output from formatDDL: %q", actual)
+	}
+}
+
+func (s ApplierIntegrationSuite) TestGetTableStats(t *testing.T) {
+	if _, err := s.d[0].SourceSQL(filepath.Join("testdata", "setup.sql")); err != nil {
+		t.Fatalf("Unexpected error from SourceSQL: %s", err)
+	}
+	db, err := s.d[0].Connect("product", "")
+	if err != nil {
+		t.Fatalf("Unable to connect to DockerizedInstance: %s", err)
+	}
+	if _, err := db.Exec("INSERT INTO users (name) VALUES ('alice'), ('bob')"); err != nil {
+		t.Fatalf("Unexpected error inserting rows: %s", err)
+	}
+
+	stats, err := getTableStats(s.d[0].Instance, "product", "users", true)
+	if err != nil {
+		t.Fatalf("Unexpected error from getTableStats: %s", err)
+	}
+	if stats.Rows != 2 || !stats.RowsExact || stats.DataLength == 0 {
+		t.Errorf("Unexpected stats for users table: %+v", stats)
+	}
+	if _, err := getTableStats(s.d[0].Instance, "product", "doesnt_exist", false); err == nil {
+		t.Error("Expected error from getTableStats on nonexistent table, but err was nil")
+	}
+}
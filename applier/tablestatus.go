@@ -0,0 +1,147 @@
+package applier
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// tableStatus holds the per-table metadata used by size-related options and
+// by table stats. Field tags match the column names of SHOW TABLE STATUS, and
+// the information_schema query aliases its columns to the same names. Values
+// may be NULL for some storage engines.
+type tableStatus struct {
+	Name        string         `db:"Name"`
+	Engine      sql.NullString `db:"Engine"`
+	Rows        sql.NullInt64  `db:"Rows"`
+	DataLength  sql.NullInt64  `db:"Data_length"`
+	IndexLength sql.NullInt64  `db:"Index_length"`
+	DataFree    sql.NullInt64  `db:"Data_free"`
+}
+
+// Size returns the table's estimated size on-disk, computed the same way as
+// tengo.Instance.TableSize.
+func (ts *tableStatus) Size() int64 {
+	return ts.DataLength.Int64 + ts.IndexLength.Int64 + ts.DataFree.Int64
+}
+
+// loadTableStatus obtains the status of the named tables in schemaName on
+// inst, keyed by table name. Tables which do not exist are omitted from the
+// result. Lookups in information_schema are chunked into queries of at most
+// batchSize tables each, rather than querying all tables of the schema at once.
+// If useShow is true, SHOW TABLE STATUS is used instead, one table at a time;
+// this is typically faster on flavors without a data dictionary, where
+// information_schema.tables may need to open many tables to answer a query.
+func loadTableStatus(inst *tengo.Instance, schemaName string, names []string, batchSize int, useShow bool) (map[string]*tableStatus, error) {
+	result := make(map[string]*tableStatus, len(names))
+	if len(names) == 0 {
+		return result, nil
+	}
+	if useShow {
+		db, err := inst.Connect(schemaName, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			var rows []*tableStatus
+			if err := db.Select(&rows, "SHOW TABLE STATUS LIKE ?", escapeLikePattern(name)); err != nil {
+				return nil, err
+			}
+			for _, ts := range rows {
+				if ts.Name == name {
+					result[name] = ts
+				}
+			}
+		}
+		return result, nil
+	}
+
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return nil, err
+	}
+	for _, batch := range chunkNames(names, batchSize) {
+		query := fmt.Sprintf(`
+			SELECT table_name AS Name, engine AS Engine, table_rows AS `+"`Rows`"+`,
+			       data_length AS Data_length, index_length AS Index_length, data_free AS Data_free
+			FROM   tables
+			WHERE  table_schema = ? AND table_name IN (?%s)`,
+			strings.Repeat(", ?", len(batch)-1))
+		args := make([]interface{}, 0, len(batch)+1)
+		args = append(args, schemaName)
+		for _, name := range batch {
+			args = append(args, name)
+		}
+		var rows []*tableStatus
+		if err := db.Select(&rows, query, args...); err != nil {
+			return nil, err
+		}
+		for _, ts := range rows {
+			result[ts.Name] = ts
+		}
+	}
+	return result, nil
+}
+
+// chunkNames splits names into consecutive batches of at most size names each.
+// A size of 0 or less means no limit.
+func chunkNames(names []string, size int) [][]string {
+	if size <= 0 || size > len(names) {
+		size = len(names)
+	}
+	var chunks [][]string
+	for len(names) > 0 {
+		n := size
+		if n > len(names) {
+			n = len(names)
+		}
+		chunks = append(chunks, names[:n])
+		names = names[n:]
+	}
+	return chunks
+}
+
+// escapeLikePattern escapes any wildcard characters in name, so that it only
+// matches itself in a LIKE clause.
+func escapeLikePattern(name string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(name)
+}
+
+// prefetchTableStatus obtains the status of any existing tables affected by
+// objDiffs, if needed by size-related options or the stats option, so that
+// later lookups for individual tables do not each require a separate query.
+// Any error is returned without caching anything, in which case later lookups
+// fall back to querying individual tables.
+func (t *Target) prefetchTableStatus(objDiffs []tengo.ObjectDiff) error {
+	t.tableStatus = nil
+	config := t.Dir.Config
+	var names []string
+	seen := make(map[string]bool)
+	for _, diff := range objDiffs {
+		name := diff.ObjectKey().Name
+		if seen[name] {
+			continue
+		}
+		if needTableSize(diff, config) || (diff.ObjectKey().Type == tengo.ObjectTypeTable && diff.DiffType() != tengo.DiffTypeCreate && config.GetBool("stats")) {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	batchSize, err := config.GetInt("introspection-batch-size")
+	if err != nil || batchSize < 1 {
+		return ConfigError(fmt.Sprintf("Option introspection-batch-size must be a positive integer; found %q", config.Get("introspection-batch-size")))
+	}
+	useShow := config.GetBool("introspection-prefer-show") && !t.Instance.Flavor().HasDataDictionary()
+	status, err := loadTableStatus(t.Instance, t.SchemaName, names, batchSize, useShow)
+	if err != nil {
+		return err
+	}
+	t.tableStatus = status
+	return nil
+}
@@ -0,0 +1,97 @@
+package applier
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestChunkNames(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e"}
+	cases := map[int][][]string{
+		0:  {{"a", "b", "c", "d", "e"}},
+		1:  {{"a"}, {"b"}, {"c"}, {"d"}, {"e"}},
+		2:  {{"a", "b"}, {"c", "d"}, {"e"}},
+		5:  {{"a", "b", "c", "d", "e"}},
+		10: {{"a", "b", "c", "d", "e"}},
+	}
+	for size, expected := range cases {
+		if actual := chunkNames(names, size); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Unexpected result from chunkNames with size %d: %v", size, actual)
+		}
+	}
+	if actual := chunkNames(nil, 3); len(actual) != 0 {
+		t.Errorf("Expected no chunks for empty input, instead found %v", actual)
+	}
+}
+
+func TestEscapeLikePattern(t *testing.T) {
+	cases := map[string]string{
+		"users":      "users",
+		"user_stats": `user\_stats`,
+		"100%":       `100\%`,
+		`back\slash`: `back\\slash`,
+	}
+	for input, expected := range cases {
+		if actual := escapeLikePattern(input); actual != expected {
+			t.Errorf("Expected escapeLikePattern(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+}
+
+func TestPrefetchTableStatusConfig(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	users := &tengo.Table{Name: "users"}
+	alter := &tengo.TableDiff{Type: tengo.DiffTypeAlter, From: users, To: users}
+	create := tengo.NewCreateTable(users)
+
+	// No query is needed if no affected tables already exist, or if neither
+	// stats nor size-related options are in use
+	cases := []struct {
+		flags    string
+		objDiffs []tengo.ObjectDiff
+	}{
+		{"--stats --introspection-batch-size=0", []tengo.ObjectDiff{create}},
+		{"--introspection-batch-size=0", []tengo.ObjectDiff{alter}},
+	}
+	for _, c := range cases {
+		target.Dir = getDir(t, "testdata/simple/one", c.flags)
+		if err := target.prefetchTableStatus(c.objDiffs); err != nil || target.tableStatus != nil {
+			t.Errorf("Unexpected result from prefetchTableStatus with %s: %v, %v", c.flags, target.tableStatus, err)
+		}
+	}
+
+	for _, flags := range []string{"--stats --introspection-batch-size=0", "--safe-below-size=10m --introspection-batch-size=many"} {
+		target.Dir = getDir(t, "testdata/simple/one", flags)
+		if err := target.prefetchTableStatus([]tengo.ObjectDiff{alter}); err == nil {
+			t.Errorf("Expected error from prefetchTableStatus with %s, but err was nil", flags)
+		} else if _, ok := err.(ConfigError); !ok {
+			t.Errorf("Expected ConfigError with %s, instead found %T: %v", flags, err, err)
+		}
+	}
+}
+
+func (s ApplierIntegrationSuite) TestLoadTableStatus(t *testing.T) {
+	if _, err := s.d[0].SourceSQL(filepath.Join("testdata", "setup.sql")); err != nil {
+		t.Fatalf("Unexpected error from SourceSQL: %s", err)
+	}
+	names := []string{"users", "posts", "comments", "doesnt_exist"}
+	for _, batchSize := range []int{1, 2, 100} {
+		for _, useShow := range []bool{false, true} {
+			status, err := loadTableStatus(s.d[0].Instance, "product", names, batchSize, useShow)
+			if err != nil {
+				t.Fatalf("Unexpected error from loadTableStatus with batch size %d, useShow=%t: %s", batchSize, useShow, err)
+			}
+			if len(status) != 3 || status["doesnt_exist"] != nil {
+				t.Errorf("Unexpected result from loadTableStatus with batch size %d, useShow=%t: %v", batchSize, useShow, status)
+			}
+			for name, ts := range status {
+				if ts.Name != name || ts.Size() == 0 || ts.Engine.String == "" {
+					t.Errorf("Unexpected status for table %s with useShow=%t: %+v", name, useShow, ts)
+				}
+			}
+		}
+	}
+}
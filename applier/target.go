@@ -1,11 +1,18 @@
 package applier
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
 )
@@ -15,15 +22,32 @@ import (
 // (instances this dir maps to) x (schemas that this dir maps to on each
 // instance).
 type Target struct {
-	Instance      *tengo.Instance
-	Dir           *fs.Dir
-	SchemaName    string
-	DesiredSchema *workspace.Schema
+	Instance       *tengo.Instance
+	Dir            *fs.Dir
+	SchemaName     string
+	DesiredSchema  *workspace.Schema
+	schemaCache    *schemaCache             // shared by all targets of the same run; nil if not caching
+	specID         string                   // identifier of the target spec supplying this target, if from the targets option
+	logicalSchemas []*fs.LogicalSchema      // only set for targets from NameTargetsForDir
+	onlyKeys       map[tengo.ObjectKey]bool // if map is non-nil, only diff objects with true values; see NarrowTargets
+	tableStatus    map[string]*tableStatus  // if map is non-nil, prefetched status of existing tables; see prefetchTableStatus
+	prepared       *preparedTarget          // if non-nil, DDL generated in advance by PrepareTargets
 }
 
 // SchemaFromInstance introspects and returns the instance's version of the
-// schema, if it exists.
+// schema, if it exists. For targets obtained from TargetGroupChanForDir, the
+// result is shared with any other targets of the same run having the same
+// instance and schema, until DDL is executed in the schema. Callers must not
+// modify the returned value.
 func (t *Target) SchemaFromInstance() (*tengo.Schema, error) {
+	start := time.Now()
+	defer func() {
+		stats := util.IntrospectionStatsForInstance(t.Instance)
+		log.Debugf("Obtained schema %s from %s in %s (%d introspection queries taking %s on this server so far)", t.SchemaName, t.Instance, time.Since(start), stats.Queries, stats.Elapsed)
+	}()
+	if t.schemaCache != nil {
+		return t.schemaCache.Schema(t.Instance, t.SchemaName)
+	}
 	schema, err := t.Instance.Schema(t.SchemaName)
 	if err == sql.ErrNoRows {
 		err = nil
@@ -38,10 +62,28 @@ func (t *Target) SchemaFromDir() *tengo.Schema {
 	return &schemaCopy
 }
 
+// isQueryTimeout returns true if an introspection query against the target
+// which took the supplied amount of time should be considered to have hit the
+// read timeout configured for the target's dir, rather than having failed for
+// another reason.
+func (t *Target) isQueryTimeout(elapsed time.Duration) bool {
+	params, err := t.Dir.InstanceDefaultParams()
+	if err != nil {
+		return false
+	}
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return false
+	}
+	timeout, err := time.ParseDuration(values.Get("readTimeout"))
+	return err == nil && timeout > 0 && elapsed >= timeout
+}
+
 // dryRun returns true if this target is only being used for dry-run purposes,
-// rather than actually wanting to apply changes to this target.
+// rather than actually wanting to apply changes to this target. Writing a
+// script via the script-out option always implies dry-run.
 func (t *Target) dryRun() bool {
-	return t.Dir.Config.GetBool("dry-run")
+	return t.Dir.Config.GetBool("dry-run") || t.scriptTemplate() != ""
 }
 
 // briefOutput returns true if this target is only being evaluated for having
@@ -52,12 +94,12 @@ func (t *Target) briefOutput() bool {
 
 func (t *Target) logApplyStart() {
 	if t.dryRun() {
-		log.Infof("Generating diff of %s %s vs %s/*.sql", t.Instance, t.SchemaName, t.Dir)
+		t.logger().Infof("Generating diff of %s %s vs %s/*.sql", t.Instance, t.SchemaName, t.Dir)
 	} else {
-		log.Infof("Pushing changes from %s/*.sql to %s %s", t.Dir, t.Instance, t.SchemaName)
+		t.logger().Infof("Pushing changes from %s/*.sql to %s %s", t.Dir, t.Instance, t.SchemaName)
 	}
 	if len(t.Dir.IgnoredStatements) > 0 {
-		log.Warnf("Ignoring %d unsupported or unparseable statements found in this directory's *.sql files; run `skeema lint` for more info", len(t.Dir.IgnoredStatements))
+		t.logger().Warnf("Ignoring %d unsupported or unparseable statements found in this directory's *.sql files; run `skeema lint` for more info", len(t.Dir.IgnoredStatements))
 	}
 }
 
@@ -67,28 +109,94 @@ func (t *Target) logApplyEnd(result Result) {
 		if t.dryRun() {
 			verb = "diff"
 		}
-		log.Infof("%s %s: %s complete\n", t.Instance, t.SchemaName, verb)
+		t.logger().Infof("%s %s: %s complete\n", t.Instance, t.SchemaName, verb)
 	} else {
-		log.Infof("%s %s: No differences found\n", t.Instance, t.SchemaName)
+		t.logger().Infof("%s %s: No differences found\n", t.Instance, t.SchemaName)
 	}
 }
 
-func (t *Target) processDDL(ddls []*DDLStatement, printer *Printer) (skipCount int) {
+func (t *Target) processDDL(ctx context.Context, ddls []*DDLStatement, printer *Printer) (skipCount int, err error) {
+	if err := t.checkContext(ctx, len(ddls)); err != nil {
+		return len(ddls), err
+	}
+	printer.printSummary(t, ddls)
+	var th *throttle
+	if !t.dryRun() {
+		if th, err = throttleForDir(t.Dir); err != nil {
+			return 0, err
+		}
+	}
+	reps := replacements(ddls)
 	for i, ddl := range ddls {
 		printer.printDDL(ddl)
 		if !t.dryRun() {
-			if err := ddl.Execute(); err != nil {
-				log.Errorf("Error running DDL on %s %s: %s", t.Instance, t.SchemaName, err)
-				skipped := len(ddls) - i
-				skipCount += skipped
-				if skipped > 1 {
-					log.Warnf("Skipping %d remaining operations for %s %s due to previous error", skipped-1, t.Instance, t.SchemaName)
+			// Stop before executing the statement, or shelling out to a wrapper for
+			// it, if ctx is done
+			if err := t.checkContext(ctx, len(ddls)-i); err != nil {
+				return len(ddls) - i, err
+			}
+
+			// Before dropping a routine which is then re-created, journal the
+			// replacement, so that an interruption in between can be recovered
+			if r := reps[i]; r != nil && r.drop == i {
+				if err := t.writeJournalEntry(r.entry); err != nil {
+					t.logger().Errorf("Unable to journal replacement of %s on %s %s in state file; it will not be dropped: %s", ddl.diff.ObjectKey(), t.Instance, t.SchemaName, err)
+					return t.skipRemaining(ddls, i, true), nil
+				}
+			}
+			if t.Dir.Config.GetBool("backup") && ddl.needsBackup() {
+				backupPath, err := t.backup(ddl)
+				if err != nil {
+					t.logger().Errorf("Unable to back up %s on %s %s; destructive statement will not be run: %s", ddl.diff.ObjectKey(), t.Instance, t.SchemaName, err)
+					return t.skipRemaining(ddls, i, true), nil
+				}
+				t.logger().Debugf("Backed up %s to %s", ddl.diff.ObjectKey(), backupPath)
+			}
+			err := ddl.Execute()
+			if t.schemaCache != nil {
+				t.schemaCache.Invalidate(t.Instance, t.SchemaName)
+			}
+			if err != nil {
+				t.logger().Errorf("Error running DDL on %s %s: %s", t.Instance, t.SchemaName, err)
+				if r := reps[i]; r != nil && r.create == i {
+					t.restoreReplaced(ddl, r.entry)
 				}
-				return
+				return t.skipRemaining(ddls, i, true), nil
+			}
+			if err := th.afterStatement(t, i < len(ddls)-1); err == errInterrupted {
+				return len(ddls) - i - 1, err
+			} else if err != nil {
+				t.logger().Errorf("%s %s: %s", t.Instance, t.SchemaName, err)
+				return t.skipRemaining(ddls, i, false), nil
 			}
 		}
 	}
-	return
+	return 0, nil
+}
+
+// skipRemaining returns the number of statements in ddls which are skipped
+// due to an error processing the statement at index i, and logs a warning
+// about any after i. The statement at i is only included in the result if
+// includeCurrent is true, meaning that it was not executed.
+func (t *Target) skipRemaining(ddls []*DDLStatement, i int, includeCurrent bool) int {
+	skipped := len(ddls) - i - 1
+	if skipped > 0 {
+		t.logger().Warnf("Skipping %d remaining operations for %s %s due to previous error", skipped, t.Instance, t.SchemaName)
+	}
+	if includeCurrent {
+		skipped++
+	}
+	return skipped
+}
+
+// checkContext returns ctx's error if ctx is done, logging the number of
+// remaining statements which will be skipped as a result.
+func (t *Target) checkContext(ctx context.Context, remaining int) error {
+	err := ctx.Err()
+	if err == context.DeadlineExceeded {
+		t.logger().Errorf("Abandoning %s %s: run-timeout exceeded; skipping %s", t.Instance, t.SchemaName, countAndNoun(remaining, "remaining operation"))
+	}
+	return err
 }
 
 // TargetGroup represents a group of Targets that all have the same Instance.
@@ -98,28 +206,60 @@ type TargetGroup []*Target
 // dir maps to, and then recursively descends through dir's subdirectories to
 // do the same.
 //
-// If firstOnly is true, any directory that normally maps to multiple instances
-// and/or schemas will only use of the first of each.
+// If the first-only option is enabled, any directory that normally maps to
+// multiple instances and/or schemas will only use the first of each, in order
+// sorted by host and then by schema name. If check-consistency is also
+// enabled, the dir's full set of shards is first compared via CheckConsistency,
+// and only then narrowed to the first shard; in this case, any instance which
+// cannot be connected to counts as a skip.
+//
+// Any instances or schemas excluded by the schemas or hosts options are
+// omitted. These options are applied before first-only selection, so the first
+// of the remaining instances and schemas is used; and before any workspace
+// operations, so a dir whose targets are all excluded does not require a
+// workspace.
 //
 // Targets are returned as a slice with no guaranteed ordering. Errors are not
 // fatal; a count of skipped dirs is returned instead.
 func TargetsForDir(dir *fs.Dir, maxDepth int) (targets []*Target, skipCount int) {
+	targets, _, skipCount = targetsForDir(dir, maxDepth)
+	return targets, skipCount
+}
+
+// targetsForDir implements TargetsForDir, additionally returning the targets
+// which were excluded by the schemas or hosts options. For instances excluded
+// by the hosts option, schema names are not looked up, so one excluded target
+// is returned with a blank SchemaName for each LogicalSchema that would
+// otherwise require a schema name lookup.
+func targetsForDir(dir *fs.Dir, maxDepth int) (targets, filtered []*Target, skipCount int) {
 	if dir.ParseError != nil {
 		log.Warnf("Skipping %s: %s\n", dir.Path, dir.ParseError)
-		return nil, 1
+		return nil, nil, 1
 	}
 	if dir.Config.Changed("host") && dir.HasSchema() {
-		var instances []*tengo.Instance
-		instances, skipCount = instancesForDir(dir)
+		// check-consistency needs every shard, so first-only is applied afterwards
+		firstOnly := dir.Config.GetBool("first-only")
+		checkAll := firstOnly && dir.Config.GetBool("check-consistency")
+		instances, excluded, instSkipCount := instancesForDir(dir, firstOnly && !checkAll)
+		skipCount = instSkipCount
 
 		// For each LogicalSchema, obtain a *tengo.Schema representation and then
 		// create a Target for each instance x schema combination
-		if len(instances) > 0 {
-			for _, logicalSchema := range dir.LogicalSchemas {
-				thisTargets, thisSkipCount := targetsForLogicalSchema(logicalSchema, dir, instances)
-				targets = append(targets, thisTargets...)
-				skipCount += thisSkipCount
+		for _, logicalSchema := range dir.LogicalSchemas {
+			for _, inst := range excluded {
+				filtered = append(filtered, &Target{Instance: inst, Dir: dir, SchemaName: logicalSchema.Name})
+			}
+			if len(instances) == 0 {
+				continue
 			}
+			thisTargets, thisFiltered, thisSkipCount := targetsForLogicalSchema(logicalSchema, dir, instances, firstOnly && !checkAll)
+			if checkAll && len(thisTargets) > 1 {
+				CheckConsistency(thisTargets)
+				thisTargets = firstTarget(thisTargets)
+			}
+			targets = append(targets, thisTargets...)
+			filtered = append(filtered, thisFiltered...)
+			skipCount += thisSkipCount
 		}
 	} else if dir.HasSchema() {
 		// If we have a schema defined but no host, display a warning
@@ -128,6 +268,10 @@ func TargetsForDir(dir *fs.Dir, maxDepth int) (targets []*Target, skipCount int)
 		// If we don't have a schema defined, but we would if some other environment
 		// had been selected, display a warning
 		log.Warnf("Skipping %s: no schema defined for environment \"%s\"\n", dir, dir.Config.Get("environment"))
+	} else if len(dir.SQLFiles) > 0 {
+		// If there are *.sql files but nothing indicates which schema they belong
+		// to, the dir is ambiguous, so display a warning rather than guessing
+		log.Warnf("Skipping %s: *.sql files found, but no schema name is defined in a .skeema file or USE statement\n", dir)
 	}
 
 	subdirs, err := dir.Subdirs()
@@ -135,6 +279,11 @@ func TargetsForDir(dir *fs.Dir, maxDepth int) (targets []*Target, skipCount int)
 		log.Warnf("Skipping subdirs of %s: %s\n", dir, err)
 		skipCount++
 		return
+	} else if len(subdirs) == 0 && dir.OptionFile == nil && len(dir.SQLFiles) == 0 {
+		// A completely empty dir is never treated as a schema, since this could
+		// otherwise result in a diff that drops every table
+		log.Warnf("Skipping %s: directory is empty\n", dir)
+		return
 	} else if len(subdirs) > 0 && maxDepth < 1 {
 		log.Warnf("Skipping subdirs of %s: max depth reached\n", dir)
 		skipCount += len(subdirs)
@@ -142,8 +291,9 @@ func TargetsForDir(dir *fs.Dir, maxDepth int) (targets []*Target, skipCount int)
 	}
 
 	for _, subdir := range subdirs {
-		subTargets, subSkipCount := TargetsForDir(subdir, maxDepth-1)
+		subTargets, subFiltered, subSkipCount := targetsForDir(subdir, maxDepth-1)
 		targets = append(targets, subTargets...)
+		filtered = append(filtered, subFiltered...)
 		skipCount += subSkipCount
 	}
 	return
@@ -172,35 +322,48 @@ func checkInstanceFlavor(instance *tengo.Instance, dir *fs.Dir) {
 	}
 }
 
-func instancesForDir(dir *fs.Dir) (instances []*tengo.Instance, skipCount int) {
-	if dir.Config.GetBool("first-only") {
-		onlyInstance, err := dir.FirstInstance()
-		if onlyInstance == nil && err == nil {
-			log.Warnf("Skipping %s: dir maps to an empty list of instances\n", dir)
-			return nil, 0
-		} else if err != nil {
+// instancesForDir returns the instances that dir maps to, along with any
+// instances excluded by the hosts option. Excluded instances are not connected
+// to, and are not considered for purposes of the first-only option.
+func instancesForDir(dir *fs.Dir, firstOnly bool) (instances, excluded []*tengo.Instance, skipCount int) {
+	allInstances, err := dir.Instances()
+	if err != nil {
+		log.Warnf("Skipping %s: %s\n", dir, err)
+		return nil, nil, 1
+	} else if len(allInstances) == 0 {
+		log.Warnf("Skipping %s: dir maps to an empty list of instances\n", dir)
+		return nil, nil, 0
+	}
+	var rawInstances []*tengo.Instance
+	for _, inst := range allInstances {
+		if matchesFilter(dir, "hosts", inst.Host, inst.String()) {
+			rawInstances = append(rawInstances, inst)
+		} else {
+			excluded = append(excluded, inst)
+		}
+	}
+	if len(rawInstances) == 0 {
+		return nil, excluded, 0
+	}
+
+	if firstOnly {
+		onlyInstance, err := firstInstance(dir, rawInstances)
+		if err != nil {
 			log.Warnf("Skipping %s: %s\n", dir, err)
-			return nil, 1
+			return nil, excluded, 1
 		}
-		// dir.FirstInstance already checks for connectivity, so no need to redo that here
+		// firstInstance already checks for connectivity, so no need to redo that here
 		checkInstanceFlavor(onlyInstance, dir)
-		return []*tengo.Instance{onlyInstance}, 0
+		return []*tengo.Instance{onlyInstance}, excluded, 0
 	}
 
-	rawInstances, err := dir.Instances()
-	if err != nil {
-		log.Warnf("Skipping %s: %s\n", dir, err)
-		return nil, 1
-	} else if len(rawInstances) == 0 {
-		log.Warnf("Skipping %s: dir maps to an empty list of instances\n", dir)
-		return nil, 0
-	}
 	// dir.Instances doesn't pre-check for connectivity problems, so do that now
 	for _, inst := range rawInstances {
-		if ok, err := inst.CanConnect(); !ok {
+		if connected, err := dir.ConnectInstance(inst); err != nil {
 			log.Warnf("Skipping %s for %s: %s", inst, dir, err)
 			skipCount++
 		} else {
+			inst = connected
 			checkInstanceFlavor(inst, dir)
 			instances = append(instances, inst)
 		}
@@ -208,18 +371,74 @@ func instancesForDir(dir *fs.Dir) (instances []*tengo.Instance, skipCount int) {
 	return
 }
 
-func targetsForLogicalSchema(logicalSchema *fs.LogicalSchema, dir *fs.Dir, instances []*tengo.Instance) (targets []*Target, skipCount int) {
-	// Obtain a *tengo.Schema representation of the dir's *.sql files from a
-	// workspace
-	opts, err := workspace.OptionsForDir(dir, instances[0])
+// firstInstance returns the first of instances which can be connected to, for
+// purposes of the first-only option. Unlike dir.FirstInstance, instances are
+// tried in order sorted by host and then by port or socket, so that the choice
+// does not depend on the order of hosts returned by a host-wrapper script.
+// instances must be non-empty.
+func firstInstance(dir *fs.Dir, instances []*tengo.Instance) (*tengo.Instance, error) {
+	instances = append([]*tengo.Instance(nil), instances...)
+	sort.SliceStable(instances, func(i, j int) bool {
+		return instanceLess(instances[i], instances[j])
+	})
+	var lastErr error
+	for _, instance := range instances {
+		var connected *tengo.Instance
+		if connected, lastErr = dir.ConnectInstance(instance); lastErr == nil {
+			return connected, nil
+		}
+	}
+	if len(instances) == 1 {
+		return nil, fmt.Errorf("Unable to connect to %s for %s: %s", instances[0], dir, lastErr)
+	}
+	return nil, fmt.Errorf("Unable to connect to any of %d instances for %s; last error %s", len(instances), dir, lastErr)
+}
+
+// firstTarget returns a single-element slice containing whichever of targets
+// sorts first by instance and then by schema name, consistent with the choice
+// made by the first-only option.
+func firstTarget(targets []*Target) []*Target {
+	first := targets[0]
+	for _, t := range targets[1:] {
+		if targetLess(t, first) {
+			first = t
+		}
+	}
+	return []*Target{first}
+}
+
+// instanceLess returns true if a sorts before b, ordering by host and then by
+// port or socket.
+func instanceLess(a, b *tengo.Instance) bool {
+	if a.Host != b.Host {
+		return a.Host < b.Host
+	}
+	return a.String() < b.String()
+}
+
+// targetLess returns true if a sorts before b, ordering by instance and then by
+// schema name.
+func targetLess(a, b *Target) bool {
+	if a.Instance.String() != b.Instance.String() {
+		return instanceLess(a.Instance, b.Instance)
+	}
+	return a.SchemaName < b.SchemaName
+}
+
+// execLogicalSchema obtains a *tengo.Schema representation of logicalSchema
+// from a workspace, using inst as the basis for the workspace options. Any
+// problems are logged, in which case nil is returned and dir should be
+// skipped.
+func execLogicalSchema(logicalSchema *fs.LogicalSchema, dir *fs.Dir, inst *tengo.Instance) *workspace.Schema {
+	opts, err := workspace.OptionsForDir(dir, inst)
 	if err != nil {
 		log.Warnf("Skipping %s: %s\n", dir, err)
-		return nil, len(instances)
+		return nil
 	}
 	wsSchema, err := workspace.ExecLogicalSchema(logicalSchema, opts)
 	if err != nil {
 		log.Warnf("Skipping %s: %s\n", dir, err)
-		return nil, len(instances)
+		return nil
 	}
 	for _, stmtErr := range wsSchema.Failures {
 		log.Error(stmtErr.Error())
@@ -234,12 +453,21 @@ func targetsForLogicalSchema(logicalSchema *fs.LogicalSchema, dir *fs.Dir, insta
 			noun = "error"
 		}
 		log.Warnf("Skipping %s due to %d SQL %s\n", dir, stmtErrCount, noun)
-		return nil, len(instances)
+		return nil
 	}
+	return wsSchema
+}
 
+// targetsForLogicalSchema returns a Target for each combination of instances
+// and schema names that logicalSchema maps to, along with any targets excluded
+// by the schemas option. The schemas option is applied before first-only
+// selection, and before the workspace is used; if every target is excluded, no
+// workspace is used at all.
+func targetsForLogicalSchema(logicalSchema *fs.LogicalSchema, dir *fs.Dir, instances []*tengo.Instance, firstOnly bool) (targets, filtered []*Target, skipCount int) {
 	// Create a Target for each instance x schema combination
 	for _, inst := range instances {
 		var schemaNames []string
+		var err error
 		if logicalSchema.Name == "" { // blank means use the schema option from dir config
 			schemaNames, err = dir.SchemaNames(inst)
 			if err != nil {
@@ -247,30 +475,87 @@ func targetsForLogicalSchema(logicalSchema *fs.LogicalSchema, dir *fs.Dir, insta
 				skipCount++
 				continue
 			}
-			if len(schemaNames) > 1 && dir.Config.GetBool("first-only") {
-				schemaNames = schemaNames[0:1]
-			}
 		} else {
 			schemaNames = []string{logicalSchema.Name}
 		}
 		for _, schemaName := range schemaNames {
 			t := &Target{
-				Instance:      inst,
-				Dir:           dir,
-				SchemaName:    schemaName,
-				DesiredSchema: wsSchema,
+				Instance:   inst,
+				Dir:        dir,
+				SchemaName: schemaName,
+			}
+			if matchesFilter(dir, "schemas", schemaName) {
+				targets = append(targets, t)
+			} else {
+				filtered = append(filtered, t)
 			}
-			targets = append(targets, t)
 		}
 	}
-	return
+	if len(targets) == 0 {
+		return nil, filtered, skipCount
+	}
+	if firstOnly {
+		targets = firstTarget(targets)
+	}
+
+	wsSchema := execLogicalSchema(logicalSchema, dir, targets[0].Instance)
+	if wsSchema == nil {
+		return nil, filtered, skipCount + len(instances)
+	}
+	for _, t := range targets {
+		t.DesiredSchema = wsSchema
+	}
+	return targets, filtered, skipCount
 }
 
 // TargetGroupChanForDir returns a channel for obtaining TargetGroups for this
-// dir and its subdirs, and count of directories that were skipped due to non-
-// fatal errors.
-func TargetGroupChanForDir(dir *fs.Dir) (<-chan TargetGroup, int) {
-	targets, skipCount := TargetsForDir(dir, 5)
+// dir and its subdirs, along with the counts returned by TargetsToProcess. It
+// is equivalent to calling TargetsToProcess followed by TargetGroupChan.
+func TargetGroupChanForDir(dir *fs.Dir) (<-chan TargetGroup, int, int, error) {
+	targets, skipCount, filterCount, err := TargetsToProcess(dir)
+	if err != nil {
+		return nil, skipCount, filterCount, err
+	}
+	return TargetGroupChan(targets), skipCount, filterCount, nil
+}
+
+// TargetsToProcess returns the targets of dir and its subdirs, along with a
+// count of directories that were skipped due to non-fatal errors, and a count
+// of targets excluded by the schemas or hosts options. These options are
+// applied by TargetsForDir, before first-only selection and before any
+// workspace operations. If any dirs have check-consistency enabled, their
+// remaining shards are compared against each other before returning; for dirs
+// that also have first-only enabled, this comparison already occurred in
+// TargetsForDir. A non-nil error is returned, and no targets should be
+// processed, if multiple dirs map to the same schema on the same instance
+// without allow-shared-schema enabled; see ResolveSharedSchemas. Introspection
+// of each instance's schemas is cached across the returned targets.
+func TargetsToProcess(dir *fs.Dir) ([]*Target, int, int, error) {
+	targets, filtered, skipCount := targetsForDir(dir, 5)
+	cache := newSchemaCache()
+	for _, t := range targets {
+		t.schemaCache = cache
+	}
+	targets, err := ResolveSharedSchemas(targets)
+	if err != nil {
+		return nil, skipCount, 0, err
+	}
+	for _, t := range filtered {
+		if t.SchemaName == "" {
+			log.Debugf("Excluding %s for %s due to hosts option", t.Instance, t.Dir)
+		} else {
+			log.Debugf("Excluding %s %s due to schemas or hosts option", t.Instance, t.SchemaName)
+		}
+	}
+	CheckConsistency(targets)
+	warnUnmanagedReferences(targets, filtered)
+	return targets, skipCount, len(filtered), nil
+}
+
+// TargetGroupChan returns a channel for obtaining TargetGroups from targets,
+// grouping them by instance. Within each TargetGroup, targets are ordered so
+// that schemas referenced by other schemas' foreign keys are processed first.
+func TargetGroupChan(targets []*Target) <-chan TargetGroup {
 	groups := make(chan TargetGroup)
 	go func() {
 		byInst := make(map[string]TargetGroup)
@@ -279,11 +564,48 @@ func TargetGroupChanForDir(dir *fs.Dir) (<-chan TargetGroup, int) {
 			byInst[key] = append(byInst[key], t)
 		}
 		for _, tg := range byInst {
-			groups <- tg
+			groups <- tg.orderByReferences()
 		}
 		close(groups)
 	}()
-	return groups, skipCount
+	return groups
+}
+
+// FilterTargets narrows targets based on the schemas and hosts options of each
+// target's dir, returning the targets which should be kept, as well as the
+// targets which were filtered out. Each of these options is a comma-separated
+// list of names or glob patterns; a target is kept only if it matches both
+// options, with a blank option matching everything. Hosts are matched against
+// either the hostname alone or the full host:port (or host:socket) string.
+func FilterTargets(targets []*Target) (kept, filtered []*Target) {
+	for _, t := range targets {
+		if matchesFilter(t.Dir, "schemas", t.SchemaName) && matchesFilter(t.Dir, "hosts", t.Instance.Host, t.Instance.String()) {
+			kept = append(kept, t)
+		} else {
+			filtered = append(filtered, t)
+		}
+	}
+	return kept, filtered
+}
+
+// matchesFilter returns true if any of values matches any name or pattern in
+// dir's filter option optionName, or if the option is blank or not defined.
+func matchesFilter(dir *fs.Dir, optionName string, values ...string) bool {
+	if dir.Config.FindOption(optionName) == nil {
+		return true
+	}
+	patterns := dir.Config.GetSlice(optionName, ',', true)
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if matched, _ := filepath.Match(pattern, value); matched || pattern == value {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func isStrictModeError(err error) bool {
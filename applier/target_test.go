@@ -2,9 +2,13 @@ package applier
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
 	"github.com/skeema/skeema/util"
@@ -55,6 +59,25 @@ func (s ApplierIntegrationSuite) TestTargetsForDirSimple(t *testing.T) {
 	if len(targets) != 2 || skipCount != 0 {
 		t.Fatalf("Unexpected result from TargetsForDir: %+v, %d", targets, skipCount)
 	}
+
+	// An empty subdir should not be treated as a schema, nor cause a skip. Build
+	// this using a copy of testdata/simple in a temp dir, to avoid modifying the
+	// tracked testdata tree.
+	basePath, err := ioutil.TempDir("", "skeema-targets-empty")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(basePath)
+	for _, name := range []string{".skeema", "one/.skeema", "one/foo.sql", "two/.skeema", "two/bar.sql"} {
+		contents := fs.ReadTestFile(t, filepath.Join("testdata/simple", name))
+		fs.WriteTestFile(t, filepath.Join(basePath, name), contents)
+	}
+	fs.MakeTestDirectory(t, filepath.Join(basePath, "empty"))
+	dir = getDir(t, basePath, "")
+	targets, skipCount = TargetsForDir(dir, 1)
+	if len(targets) != 2 || skipCount != 0 {
+		t.Fatalf("Unexpected result from TargetsForDir with empty subdir: %+v, %d", targets, skipCount)
+	}
 }
 
 func (s ApplierIntegrationSuite) TestTargetsForDirSimpleFailure(t *testing.T) {
@@ -152,6 +175,119 @@ func (s ApplierIntegrationSuite) TestTargetsForDirMulti(t *testing.T) {
 	}
 }
 
+// warningHook is a logrus hook which captures the messages of warnings.
+type warningHook struct {
+	messages []string
+}
+
+func (h *warningHook) Levels() []log.Level {
+	return []log.Level{log.WarnLevel}
+}
+
+func (h *warningHook) Fire(entry *log.Entry) error {
+	h.messages = append(h.messages, entry.Message)
+	return nil
+}
+
+func (s ApplierIntegrationSuite) TestTargetsForDirFirstOnlyConsistency(t *testing.T) {
+	setupHostList(t, s.d[0].Instance, s.d[1].Instance)
+	defer cleanupHostList(t)
+
+	// Create both shards on both instances, with one outlier on the second
+	// instance, which first-only would otherwise never examine
+	for n := range s.d {
+		for _, schemaName := range []string{"one", "two"} {
+			if _, err := s.d[n].CreateSchema(schemaName, tengo.SchemaCreationOptions{}); err != nil {
+				t.Fatalf("Unexpected error from CreateSchema: %s", err)
+			}
+		}
+	}
+	db, err := s.d[1].Connect("two", "")
+	if err != nil {
+		t.Fatalf("Unexpected error from Connect: %s", err)
+	}
+	if _, err := db.Exec("CREATE TABLE outlier (id int unsigned NOT NULL PRIMARY KEY)"); err != nil {
+		t.Fatalf("Unexpected error from Exec: %s", err)
+	}
+
+	hook := &warningHook{}
+	origHooks := log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+	log.AddHook(hook)
+	defer log.StandardLogger().ReplaceHooks(origHooks)
+
+	dir := getDir(t, "testdata/multi", "--first-only --check-consistency")
+	targets, skipCount := TargetsForDir(dir, 1)
+	if len(targets) != 1 || skipCount != 0 {
+		t.Fatalf("Unexpected result from TargetsForDir: %+v, %d", targets, skipCount)
+	}
+	expectInst := s.d[0].Instance
+	if instanceLess(s.d[1].Instance, expectInst) {
+		expectInst = s.d[1].Instance
+	}
+	if targets[0].Instance.String() != expectInst.String() || targets[0].SchemaName != "one" {
+		t.Errorf("Expected first-only to select %s one, instead found %s %s", expectInst, targets[0].Instance, targets[0].SchemaName)
+	}
+	expectOutlier := fmt.Sprintf("%s:two", s.d[1].Instance)
+	var found bool
+	for _, msg := range hook.messages {
+		if strings.Contains(msg, "differs from the other shards") && strings.Contains(msg, expectOutlier) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected consistency warning naming %s, instead found warnings %v", expectOutlier, hook.messages)
+	}
+}
+
+func (s ApplierIntegrationSuite) TestTargetsForDirFilterFirstOnly(t *testing.T) {
+	setupHostList(t, s.d[0].Instance, s.d[1].Instance)
+	defer cleanupHostList(t)
+
+	// The hosts and schemas options are applied before first-only, so the first
+	// remaining instance and schema should be used
+	lastInst := s.d[1].Instance
+	if instanceLess(lastInst, s.d[0].Instance) {
+		lastInst = s.d[0].Instance
+	}
+	cliFlags := fmt.Sprintf("--first-only --hosts=%s --schemas=two", lastInst)
+	dir := getDir(t, "testdata/multi", cliFlags)
+	targets, filtered, skipCount := targetsForDir(dir, 1)
+	if len(targets) != 1 || len(filtered) != 2 || skipCount != 0 {
+		t.Fatalf("Unexpected result from targetsForDir with %s: %+v, %+v, %d", cliFlags, targets, filtered, skipCount)
+	}
+	if targets[0].Instance.String() != lastInst.String() || targets[0].SchemaName != "two" {
+		t.Errorf("Expected first-only to select %s two, instead found %s %s", lastInst, targets[0].Instance, targets[0].SchemaName)
+	}
+
+	// If every target is excluded, nothing is skipped, since no workspace is
+	// needed
+	dir = getDir(t, "testdata/multi", "--first-only --hosts=nonexistent")
+	targets, filtered, skipCount = targetsForDir(dir, 1)
+	if len(targets) != 0 || len(filtered) != 2 || skipCount != 0 {
+		t.Errorf("Unexpected result from targetsForDir with excluded hosts: %+v, %+v, %d", targets, filtered, skipCount)
+	}
+}
+
+func TestFirstTarget(t *testing.T) {
+	var insts []*tengo.Instance
+	for _, dsn := range []string{"root@tcp(db2:3306)/", "root@tcp(db1:3307)/", "root@tcp(db1:3306)/"} {
+		inst, err := tengo.NewInstance("mysql", dsn)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewInstance: %s", err)
+		}
+		insts = append(insts, inst)
+	}
+	targets := []*Target{
+		{Instance: insts[0], SchemaName: "a"},
+		{Instance: insts[1], SchemaName: "b"},
+		{Instance: insts[2], SchemaName: "d"},
+		{Instance: insts[2], SchemaName: "c"},
+	}
+	if first := firstTarget(targets); len(first) != 1 || first[0] != targets[3] {
+		t.Errorf("Unexpected result from firstTarget: %+v", first)
+	}
+}
+
 func (s ApplierIntegrationSuite) TestTargetsForDirError(t *testing.T) {
 	setupHostList(t, s.d[0].Instance, s.d[1].Instance)
 	defer cleanupHostList(t)
@@ -199,7 +335,10 @@ func (s ApplierIntegrationSuite) TestTargetGroupChanForDir(t *testing.T) {
 	// Parent dir maps to 2 instances, and schema dir maps to 2 schemas, so expect
 	// 4 targets split into 2 groups (by instance)
 	dir := getDir(t, "testdata/multi", "")
-	tgchan, skipCount := TargetGroupChanForDir(dir)
+	tgchan, skipCount, _, err := TargetGroupChanForDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error from TargetGroupChanForDir: %s", err)
+	}
 	if skipCount != 0 {
 		t.Errorf("Expected skip count of 0, instead found %d", skipCount)
 	}
@@ -224,7 +363,10 @@ func (s ApplierIntegrationSuite) TestTargetGroupChanForDir(t *testing.T) {
 	// dir two/ has no errors and should successfully yield 2 targets (1 per host,
 	// and put into different targetgroups)
 	dir = getDir(t, "testdata/sqlerror", "")
-	tgchan, skipCount = TargetGroupChanForDir(dir)
+	tgchan, skipCount, _, err = TargetGroupChanForDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error from TargetGroupChanForDir: %s", err)
+	}
 	if skipCount != 2 {
 		t.Errorf("Expected skip count of 2, instead found %d", skipCount)
 	}
@@ -245,22 +387,100 @@ func (s ApplierIntegrationSuite) TestTargetGroupChanForDir(t *testing.T) {
 	}
 }
 
+func TestFilterTargets(t *testing.T) {
+	var insts []*tengo.Instance
+	for _, dsn := range []string{"root:@tcp(db1.example.com:3306)/", "root:@tcp(db2.example.com:3306)/", "root:@tcp(db2.example.com:3307)/"} {
+		inst, err := tengo.NewInstance("mysql", dsn)
+		if err != nil {
+			t.Fatalf("Unexpected error from NewInstance: %s", err)
+		}
+		insts = append(insts, inst)
+	}
+	assertFilter := func(cliFlags string, expected ...string) {
+		t.Helper()
+		dir := getDir(t, "testdata/simple", cliFlags)
+		var targets []*Target
+		for _, inst := range insts {
+			for _, schemaName := range []string{"shard_001", "shard_002", "shard_010", "other"} {
+				targets = append(targets, &Target{Instance: inst, Dir: dir, SchemaName: schemaName})
+			}
+		}
+		kept, filtered := FilterTargets(targets)
+		if len(kept)+len(filtered) != len(targets) {
+			t.Errorf("With %s: kept and filtered targets do not sum to total", cliFlags)
+		}
+		actual := make([]string, len(kept))
+		for n, target := range kept {
+			actual[n] = fmt.Sprintf("%s %s", target.Instance, target.SchemaName)
+		}
+		if strings.Join(actual, ", ") != strings.Join(expected, ", ") {
+			t.Errorf("With %s: expected targets %v, instead found %v", cliFlags, expected, actual)
+		}
+	}
+	assertFilter("--schemas=shard_001,shard_002 --hosts=db1.example.com",
+		"db1.example.com:3306 shard_001", "db1.example.com:3306 shard_002")
+	assertFilter("--schemas='shard_00?' --hosts=db2.example.com:3307",
+		"db2.example.com:3307 shard_001", "db2.example.com:3307 shard_002")
+	assertFilter("--schemas=other --hosts='db*:3306'",
+		"db1.example.com:3306 other", "db2.example.com:3306 other")
+	assertFilter("--schemas=nonexistent")
+
+	dir := getDir(t, "testdata/simple", "")
+	targets := []*Target{{Instance: insts[0], Dir: dir, SchemaName: "other"}}
+	if kept, filtered := FilterTargets(targets); len(kept) != 1 || len(filtered) != 0 {
+		t.Errorf("Expected blank filter options to keep all targets, instead kept %d and filtered %d", len(kept), len(filtered))
+	}
+}
+
 func getBaseConfig(t *testing.T, cliFlags string) *mybase.Config {
 	cmd := mybase.NewCommand("appliertest", "", "", nil)
-	cmd.AddOption(mybase.BoolOption("verify", 0, true, "Test all generated ALTER statements on temp schema to verify correctness"))
+	cmd.AddOption(mybase.BoolOption("verify", 0, true, "Check *.sql files for syntax problems before connecting, and test all generated ALTER statements on temp schema to verify correctness"))
+	cmd.AddOption(mybase.BoolOption("verify-sequence", 0, true, "Before executing DDL, run the full statement sequence in a workspace and confirm the result matches *.sql definitions"))
 	cmd.AddOption(mybase.BoolOption("allow-unsafe", 0, false, "Permit running ALTER or DROP operations that are potentially destructive"))
+	cmd.AddOption(mybase.BoolOption("allow-oversized-keys", 0, false, "Permit ALTERs which would cause an index to exceed the server's key size limit"))
+	cmd.AddOption(mybase.StringOption("allow-unsafe-codes", 0, "", "Permit running potentially destructive operations only of these unsafe codes (comma-separated)"))
+	cmd.AddOption(mybase.BoolOption("allow-empty-rebuild", 0, false, "Permit pushing to existing schemas which have no tables, despite --empty-rebuild-min-tables"))
+	cmd.AddOption(mybase.StringOption("empty-rebuild-min-tables", 0, "0", "Refuse to push to an existing schema with no tables if *.sql files define at least this many tables (0 to disable)"))
 	cmd.AddOption(mybase.BoolOption("dry-run", 0, false, "Output DDL but don't run it; equivalent to `skeema diff`"))
 	cmd.AddOption(mybase.BoolOption("first-only", '1', false, "For dirs mapping to multiple instances or schemas, just run against the first per dir"))
+	cmd.AddOption(mybase.BoolOption("check-consistency", 0, false, "For dirs mapping to multiple schemas, compare the schemas against each other and report outliers"))
+	cmd.AddOption(mybase.BoolOption("allow-shared-schema", 0, false, "Permit multiple dirs to map to the same schema on the same host, combining their *.sql files"))
 	cmd.AddOption(mybase.BoolOption("exact-match", 0, false, "Follow *.sql table definitions exactly, even for differences with no functional impact"))
 	cmd.AddOption(mybase.BoolOption("foreign-key-checks", 0, false, "Force the server to check referential integrity of any new foreign key"))
 	cmd.AddOption(mybase.BoolOption("brief", 'q', false, "<overridden by diff command>").Hidden())
 	cmd.AddOption(mybase.StringOption("alter-wrapper", 'x', "", "External bin to shell out to for ALTER TABLE; see manual for template vars"))
 	cmd.AddOption(mybase.StringOption("alter-wrapper-min-size", 0, "0", "Ignore --alter-wrapper for tables smaller than this size in bytes"))
+	cmd.AddOption(mybase.StringOption("wrapper-flavor", 0, "custom", `Tool used by --alter-wrapper or --ddl-wrapper, for warning about unsupported tables (valid values: "custom", "pt-osc", "gh-ost")`))
+	cmd.AddOption(mybase.BoolOption("wrapper-strict", 0, false, "Run ALTER TABLE directly instead of via wrapper for tables which --wrapper-flavor cannot handle"))
 	cmd.AddOption(mybase.StringOption("alter-lock", 0, "", `Apply a LOCK clause to all ALTER TABLEs (valid values: "none", "shared", "exclusive")`))
 	cmd.AddOption(mybase.StringOption("alter-algorithm", 0, "", `Apply an ALGORITHM clause to all ALTER TABLEs (valid values: "inplace", "copy", "instant")`))
 	cmd.AddOption(mybase.StringOption("ddl-wrapper", 'X', "", "Like --alter-wrapper, but applies to all DDL types (CREATE, DROP, ALTER)"))
 	cmd.AddOption(mybase.StringOption("safe-below-size", 0, "0", "Always permit destructive operations for tables below this size in bytes"))
+	cmd.AddOption(mybase.StringOption("manage-grants", 0, "", "Comma-separated list of users whose privileges on the schema are managed by .grants.sql files"))
 	cmd.AddOption(mybase.StringOption("concurrent-instances", 'c', "1", "Perform operations on this number of instances concurrently"))
+	cmd.AddOption(mybase.StringOption("variable-mismatch", 0, "warning", `How to handle server variables affecting DDL differing between workspace and target (valid values: "ignore", "warning", "error")`))
+	cmd.AddOption(mybase.BoolOption("allow-read-only", 0, false, "Permit pushing to instances with read_only or super_read_only enabled"))
+	cmd.AddOption(mybase.StringOption("replicas", 0, "", "Comma-separated list of replica hosts whose replication lag is checked before pushing"))
+	cmd.AddOption(mybase.StringOption("max-replica-lag", 0, "60s", "Refuse to push if any host in --replicas is lagging by more than this duration"))
+	cmd.AddOption(mybase.StringOption("schemas", 0, "", "Only operate on schemas matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("hosts", 0, "", "Only operate on hosts matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("run-timeout", 0, "0", "Abandon any targets not completed within this duration (0 for no limit)"))
+	cmd.AddOption(mybase.StringOption("ignore-table-options", 0, "", "Comma-separated list of table options (e.g. KEY_BLOCK_SIZE) to exclude from comparison"))
+	cmd.AddOption(mybase.BoolOption("backup", 0, true, "Save definitions of tables to a backup dir before dropping them or any of their columns"))
+	cmd.AddOption(mybase.StringOption("backup-dir", 0, "", "Dir in which to save backups of table definitions (default .skeema-backups in repo base)"))
+	cmd.AddOption(mybase.BoolOption("backup-row-count", 0, false, "Include each table's row count in its backup file"))
+	cmd.AddOption(mybase.StringOption("label", 0, "", "Arbitrary label, such as a commit SHA, to record in tracking-table upon push"))
+	cmd.AddOption(mybase.StringOption("statement-comment", 0, "skeema:push user={USER} dir={DIR} env={ENVIRONMENT}", "Template for a comment prepended to each executed statement, for attribution in the processlist"))
+	cmd.AddOption(mybase.StringOption("statement-comment-user", 0, "", "Username for {USER} in statement-comment (default OS username)"))
+	cmd.AddOption(mybase.StringOption("sleep-between-statements", 0, "0", "Pause for this duration after each DDL statement on a target (0 for no pause)"))
+	cmd.AddOption(mybase.StringOption("sleep-between-targets", 0, "0", "Pause for this duration after finishing DDL on one target before the next (0 for no pause)"))
+	cmd.AddOption(mybase.StringOption("replica-lag-query", 0, "", "Query returning replication lag in seconds; push waits after each DDL statement until lag is within max-replica-lag"))
+	cmd.AddOption(mybase.StringOption("ddl-batching", 0, "per-table", `Granularity of generated ALTER TABLE statements (valid values: "per-table", "per-clause")`))
+	cmd.AddOption(mybase.StringOption("output-prefix", 0, "", "Prefix output lines and log messages for each target with this template, e.g. \"[{HOST}:{SCHEMA}]\""))
+	cmd.AddOption(mybase.BoolOption("stats", 0, false, "Output row count estimates and data and index sizes of each affected table"))
+	cmd.AddOption(mybase.BoolOption("exact-counts", 0, false, "With --stats, count rows of InnoDB tables exactly instead of estimating; may be slow for large tables"))
+	cmd.AddOption(mybase.BoolOption("include-passthrough", 0, false, "After all other DDL, run the statements of each dir's *.passthrough.sql files"))
+	cmd.AddOption(mybase.StringOption("script-out", 0, "", "Write the DDL to a SQL script per target instead of running it; see manual for template vars"))
 	cmd.AddArg("environment", "production", false)
 	util.AddGlobalOptions(cmd)
 	return mybase.ParseFakeCLI(t, cmd, fmt.Sprintf("appliertest %s", cliFlags))
@@ -0,0 +1,229 @@
+package applier
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// TargetSpec identifies a single schema on a single instance, as supplied by
+// the targets option rather than obtained from the host and schema options of
+// a dir's configuration.
+type TargetSpec struct {
+	ID     string `json:"id"`   // identifier to attribute results to; defaults to the spec in host:port/schema form
+	Host   string `json:"host"` // hostname or address, optionally including a port
+	Port   int    `json:"port"`
+	Schema string `json:"schema"`
+	Line   int    `json:"-"` // line number of the spec in its input
+}
+
+// hostname returns the host of spec, including its port if known.
+func (spec TargetSpec) hostname() string {
+	if spec.Port > 0 {
+		return spec.Host + ":" + strconv.Itoa(spec.Port)
+	}
+	return spec.Host
+}
+
+// String returns spec in host:port/schema form, omitting the port if unknown.
+func (spec TargetSpec) String() string {
+	return spec.hostname() + "/" + spec.Schema
+}
+
+// TargetSpecError describes an invalid line of target spec input.
+type TargetSpecError struct {
+	Line   int
+	Reason string
+}
+
+// Error satisfies the builtin error interface.
+func (tse TargetSpecError) Error() string {
+	return fmt.Sprintf("line %d: %s", tse.Line, tse.Reason)
+}
+
+// ParseTargetSpecs reads newline-delimited target specs from r. Each line is
+// either of form "host[:port]/schema", or a JSON object with "host" and
+// "schema" fields along with optional "port" and "id" fields. Blank lines and
+// lines beginning with # are ignored. Invalid lines are returned as
+// TargetSpecErrors, without stopping parsing of later lines; a non-nil error is
+// only returned if reading from r fails.
+func ParseTargetSpecs(r io.Reader) (specs []TargetSpec, invalid []TargetSpecError, err error) {
+	scanner := bufio.NewScanner(r)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		spec, err := parseTargetSpec(text)
+		if err != nil {
+			invalid = append(invalid, TargetSpecError{Line: lineNum, Reason: err.Error()})
+			continue
+		}
+		spec.Line = lineNum
+		specs = append(specs, spec)
+	}
+	return specs, invalid, scanner.Err()
+}
+
+// parseTargetSpec parses a single non-blank line of target spec input.
+func parseTargetSpec(text string) (spec TargetSpec, err error) {
+	if text[0] == '{' {
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&spec); err != nil {
+			return spec, fmt.Errorf("invalid JSON: %s", err)
+		}
+	} else if slash := strings.LastIndex(text, "/"); slash < 0 {
+		return spec, errors.New("expected a JSON object or a value of form host:port/schema")
+	} else {
+		spec.Host, spec.Schema, spec.ID = text[:slash], text[slash+1:], text
+	}
+	if spec.Host == "" || spec.Schema == "" {
+		return spec, errors.New("host and schema are both required")
+	}
+	host, port, err := tengo.SplitHostOptionalPort(spec.Host)
+	if err != nil {
+		return spec, fmt.Errorf("invalid host %q: %s", spec.Host, err)
+	} else if port > 0 && spec.Port > 0 && port != spec.Port {
+		return spec, fmt.Errorf("port supplied as %d in host %q but as %d in port field", port, spec.Host, spec.Port)
+	} else if spec.Port < 0 || spec.Port > 65535 {
+		return spec, fmt.Errorf("invalid port %d", spec.Port)
+	}
+	spec.Host = host
+	if port > 0 {
+		spec.Port = port
+	}
+	if spec.ID == "" {
+		spec.ID = spec.String()
+	}
+	return spec, nil
+}
+
+// TargetsForSpecs returns a Target for each of specs, in the same manner as
+// TargetsToProcess, along with a count of specs that were skipped due to
+// non-fatal errors and a count of targets excluded by the schemas or hosts
+// options. The targets use dir's *.sql files and configuration, but the host
+// and schema options of dir are ignored in favor of the specs. A ConfigError
+// is returned if dir does not define exactly one schema's worth of *.sql
+// files. Specs which cannot be connected to, or which refer to the same
+// instance and schema as an earlier spec, are skipped. The schemas and hosts
+// options are applied before connecting to any instance or using a workspace.
+func TargetsForSpecs(dir *fs.Dir, specs []TargetSpec) ([]*Target, int, int, error) {
+	if dir.ParseError != nil {
+		return nil, 0, 0, ConfigError(fmt.Sprintf("Unable to use targets option with %s: %s", dir, dir.ParseError))
+	} else if len(dir.LogicalSchemas) != 1 {
+		return nil, 0, 0, ConfigError(fmt.Sprintf("With the targets option, %s must contain *.sql files defining exactly one schema", dir))
+	}
+
+	// Apply the schemas and hosts options before doing any other work
+	var filterCount int
+	kept := make([]TargetSpec, 0, len(specs))
+	for _, spec := range specs {
+		if specMatchesFilters(dir, spec) {
+			kept = append(kept, spec)
+		} else {
+			log.Debugf("Excluding target %s on line %d due to schemas or hosts option", spec.ID, spec.Line)
+			filterCount++
+		}
+	}
+	specs = kept
+
+	// Connect to each distinct host only once, even if used by many specs
+	var skipCount int
+	byHost := make(map[string]*tengo.Instance)
+	specInstances := make([]*tengo.Instance, len(specs))
+	var firstInstance *tengo.Instance
+	for n, spec := range specs {
+		host := spec.hostname()
+		inst, seen := byHost[host]
+		if !seen {
+			inst = connectSpecInstance(dir, spec)
+			byHost[host] = inst
+			if firstInstance == nil {
+				firstInstance = inst
+			}
+		} else if inst == nil {
+			log.Warnf("Skipping target %s on line %d: host %s could not be used, as logged above", spec.ID, spec.Line, host)
+		}
+		if inst == nil {
+			skipCount++
+		}
+		specInstances[n] = inst
+	}
+	if firstInstance == nil {
+		return nil, skipCount, filterCount, nil
+	}
+	wsSchema := execLogicalSchema(dir.LogicalSchemas[0], dir, firstInstance)
+	if wsSchema == nil {
+		return nil, len(specs), filterCount, nil
+	}
+
+	var targets []*Target
+	cache := newSchemaCache()
+	seen := make(map[string]TargetSpec)
+	for n, spec := range specs {
+		inst := specInstances[n]
+		if inst == nil {
+			continue
+		}
+		key := inst.String() + "/" + spec.Schema
+		if prev, dupe := seen[key]; dupe {
+			log.Warnf("Skipping target %s on line %d: same instance and schema as line %d", spec.ID, spec.Line, prev.Line)
+			skipCount++
+			continue
+		}
+		seen[key] = spec
+		targets = append(targets, &Target{
+			Instance:      inst,
+			Dir:           dir,
+			SchemaName:    spec.Schema,
+			DesiredSchema: wsSchema,
+			schemaCache:   cache,
+			specID:        spec.ID,
+		})
+	}
+	return targets, skipCount, filterCount, nil
+}
+
+// specMatchesFilters returns true if spec is not excluded by the schemas or
+// hosts options of dir. Hosts are matched in the same manner as FilterTargets,
+// without connecting to the instance. If the instance of spec cannot be
+// determined, true is returned, so that the problem is logged when connecting.
+func specMatchesFilters(dir *fs.Dir, spec TargetSpec) bool {
+	if !matchesFilter(dir, "schemas", spec.Schema) {
+		return false
+	}
+	instances, err := dir.InstancesForHosts([]string{spec.hostname()})
+	if err != nil || len(instances) == 0 {
+		return true
+	}
+	return matchesFilter(dir, "hosts", instances[0].Host, instances[0].String())
+}
+
+// connectSpecInstance returns the instance of spec, using all connection
+// options of dir other than host. If the instance cannot be reached, a warning
+// is logged and nil is returned.
+func connectSpecInstance(dir *fs.Dir, spec TargetSpec) *tengo.Instance {
+	instances, err := dir.InstancesForHosts([]string{spec.hostname()})
+	if err != nil {
+		log.Warnf("Skipping target %s on line %d: %s", spec.ID, spec.Line, err)
+		return nil
+	}
+	inst, err := dir.ConnectInstance(instances[0])
+	if err != nil {
+		log.Warnf("Skipping target %s on line %d: %s", spec.ID, spec.Line, err)
+		return nil
+	}
+	checkInstanceFlavor(inst, dir)
+	return inst
+}
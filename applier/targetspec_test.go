@@ -0,0 +1,99 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTargetSpecs(t *testing.T) {
+	input := `
+# comment lines and blank lines are ignored
+db1.example.com:3307/shard1
+db2.example.com/shard2
+{"id": "s3", "host": "db3.example.com", "port": 3308, "schema": "shard3"}
+{"host": "[::1]:3309", "schema": "shard4"}
+db5.example.com:3306
+{"host": "db6.example.com", "schema": "shard6", "extra": true}
+/shard7
+{"host": "db8.example.com:3306", "port": 3307, "schema": "shard8"}
+db9.example.com:notaport/shard9
+`
+	specs, invalid, err := ParseTargetSpecs(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseTargetSpecs: %v", err)
+	}
+	expected := []TargetSpec{
+		{ID: "db1.example.com:3307/shard1", Host: "db1.example.com", Port: 3307, Schema: "shard1", Line: 3},
+		{ID: "db2.example.com/shard2", Host: "db2.example.com", Schema: "shard2", Line: 4},
+		{ID: "s3", Host: "db3.example.com", Port: 3308, Schema: "shard3", Line: 5},
+		{ID: "[::1]:3309/shard4", Host: "[::1]", Port: 3309, Schema: "shard4", Line: 6},
+	}
+	if len(specs) != len(expected) {
+		t.Fatalf("Expected %d specs, instead found %d: %+v", len(expected), len(specs), specs)
+	}
+	for n := range specs {
+		if specs[n] != expected[n] {
+			t.Errorf("Spec[%d]: expected %+v, found %+v", n, expected[n], specs[n])
+		}
+	}
+	expectedLines := []int{7, 8, 9, 10, 11}
+	if len(invalid) != len(expectedLines) {
+		t.Fatalf("Expected %d invalid lines, instead found %d: %v", len(expectedLines), len(invalid), invalid)
+	}
+	for n, tse := range invalid {
+		if tse.Line != expectedLines[n] {
+			t.Errorf("Expected invalid[%d] to be line %d, instead found %v", n, expectedLines[n], tse)
+		} else if !strings.HasPrefix(tse.Error(), "line ") {
+			t.Errorf("Unexpected error text: %q", tse.Error())
+		}
+	}
+}
+
+func TestTargetsForSpecsBadDir(t *testing.T) {
+	dir := getDir(t, "testdata/simple", "")
+	specs := []TargetSpec{{ID: "x", Host: "127.0.0.1", Port: 3306, Schema: "product", Line: 1}}
+	if _, _, _, err := TargetsForSpecs(dir, specs); err == nil {
+		t.Error("Expected error from dir without *.sql files, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok {
+		t.Errorf("Expected ConfigError, instead found %T: %s", err, err)
+	}
+}
+
+func TestSpecMatchesFilters(t *testing.T) {
+	dir := getDir(t, "testdata/simple", "--schemas='shard_00?' --hosts='db1*'")
+	cases := []struct {
+		spec     TargetSpec
+		expected bool
+	}{
+		{TargetSpec{Host: "db1.example.com", Port: 3307, Schema: "shard_001"}, true},
+		{TargetSpec{Host: "db1.example.com", Schema: "shard_010"}, false},
+		{TargetSpec{Host: "db2.example.com", Schema: "shard_002"}, false},
+	}
+	for _, c := range cases {
+		if actual := specMatchesFilters(dir, c.spec); actual != c.expected {
+			t.Errorf("Expected specMatchesFilters(%+v) to return %t, instead found %t", c.spec, c.expected, actual)
+		}
+	}
+	dir = getDir(t, "testdata/simple", "--hosts=db1.example.com:3307")
+	if !specMatchesFilters(dir, TargetSpec{Host: "db1.example.com", Port: 3307, Schema: "any"}) {
+		t.Error("Expected hosts option to match spec host:port, but it did not")
+	}
+	if specMatchesFilters(dir, TargetSpec{Host: "db1.example.com", Port: 3306, Schema: "any"}) {
+		t.Error("Expected hosts option not to match spec with a different port, but it did")
+	}
+}
+
+func TestTargetSpecAttribution(t *testing.T) {
+	target, _ := getFormatTestDDL(t)
+	target.specID = "shard12"
+	if prefix, err := target.outputPrefix(); prefix != "[shard12]" || err != nil {
+		t.Errorf("Expected default prefix to use spec identifier, instead found %q, %v", prefix, err)
+	}
+	target.Dir = getDir(t, "testdata/simple/one", "--output-prefix='<{TARGET} {SCHEMA}>'")
+	if prefix, err := target.outputPrefix(); prefix != "<shard12 product>" || err != nil {
+		t.Errorf("Unexpected prefix with {TARGET} variable: %q, %v", prefix, err)
+	}
+	if tr := newTargetResult(target, Result{}, nil); tr.Target != "shard12" {
+		t.Errorf("Expected TargetResult to record spec identifier, instead found %+v", tr)
+	}
+}
@@ -0,0 +1,6 @@
+CREATE TABLE `events` (
+  `id` bigint(20) unsigned NOT NULL,
+  `body` text /*M!100301 COMPRESSED*/ DEFAULT NULL,
+  `summary` varchar(1000) DEFAULT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1
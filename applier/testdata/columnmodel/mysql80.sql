@@ -0,0 +1,8 @@
+CREATE TABLE `places` (
+  `id` int unsigned NOT NULL,
+  `loc` point NOT NULL /*!80003 SRID 4326 */,
+  `area` polygon /*!80003 SRID 4326 */ DEFAULT NULL COMMENT 'boundary',
+  `notes` text NOT NULL DEFAULT (_utf8mb4'none'),
+  `extra` blob DEFAULT (_utf8mb4'') COMMENT 'raw',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci
@@ -0,0 +1,7 @@
+CREATE TABLE `events` (
+  `id` bigint(20) unsigned NOT NULL /*!50606 COLUMN_FORMAT FIXED */ AUTO_INCREMENT,
+  `counter` int(11) NOT NULL /*!50606 STORAGE MEMORY */ /*!50606 COLUMN_FORMAT DYNAMIC */ DEFAULT '0',
+  `label` varchar(40) /*!50606 STORAGE DISK */ DEFAULT NULL COMMENT 'display',
+  `payload` blob,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1
@@ -0,0 +1,7 @@
+CREATE TABLE `events` (
+  `id` bigint(20) unsigned NOT NULL AUTO_INCREMENT,
+  `counter` int(11) NOT NULL DEFAULT '0',
+  `label` varchar(40) DEFAULT NULL COMMENT 'display',
+  `payload` blob,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1
@@ -0,0 +1,6 @@
+CREATE TABLE `countries` (
+  `code` char(2) NOT NULL,
+  `name` varchar(60) NOT NULL,
+  `slug` varchar(60) CHARACTER SET utf8mb3 COLLATE utf8mb3_bin DEFAULT NULL,
+  PRIMARY KEY (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb3
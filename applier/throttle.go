@@ -0,0 +1,126 @@
+package applier
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+)
+
+// lagCheckInterval is how long to wait between executions of replica-lag-query
+// while lag exceeds max-replica-lag.
+var lagCheckInterval = time.Second
+
+// errInterrupted is returned if a throttle sleep was interrupted by SIGINT.
+var errInterrupted = errors.New("Interrupted while throttling DDL execution")
+
+// throttle controls pauses between DDL statements and between targets, in
+// order to limit the load that push places on replication.
+type throttle struct {
+	betweenStatements time.Duration
+	betweenTargets    time.Duration
+	lagQuery          string
+	maxLag            time.Duration
+}
+
+// throttleForDir returns a throttle based on dir's configuration. An error is
+// returned if any option value is invalid.
+func throttleForDir(dir *fs.Dir) (*throttle, error) {
+	th := &throttle{
+		lagQuery: dir.Config.Get("replica-lag-query"),
+	}
+	var err error
+	if th.betweenStatements, err = util.ParseTimeout("sleep-between-statements", dir.Config.Get("sleep-between-statements")); err != nil {
+		return nil, ConfigError(err.Error())
+	}
+	if th.betweenTargets, err = util.ParseTimeout("sleep-between-targets", dir.Config.Get("sleep-between-targets")); err != nil {
+		return nil, ConfigError(err.Error())
+	}
+	if th.maxLag, err = util.ParseTimeout("max-replica-lag", dir.Config.Get("max-replica-lag")); err != nil {
+		return nil, ConfigError(err.Error())
+	}
+	return th, nil
+}
+
+// afterStatement is called after each DDL statement is executed on t. If lag
+// checks are enabled, it waits until replica lag is within the limit. Then, if
+// more statements remain for t, it sleeps for the configured duration.
+func (th *throttle) afterStatement(t *Target, moreRemaining bool) error {
+	if th.lagQuery != "" {
+		if err := th.waitForLag(t); err != nil {
+			return err
+		}
+	}
+	if moreRemaining && th.betweenStatements > 0 {
+		t.logger().Infof("%s %s: sleeping %s before next statement", t.Instance, t.SchemaName, th.betweenStatements)
+		if !interruptibleSleep(th.betweenStatements) {
+			return errInterrupted
+		}
+	}
+	return nil
+}
+
+// beforeTarget is called before processing t, if a previous target processed
+// by the same worker executed any DDL.
+func (th *throttle) beforeTarget(t *Target) error {
+	if th.betweenTargets <= 0 {
+		return nil
+	}
+	t.logger().Infof("%s %s: sleeping %s before processing target", t.Instance, t.SchemaName, th.betweenTargets)
+	if !interruptibleSleep(th.betweenTargets) {
+		return errInterrupted
+	}
+	return nil
+}
+
+// waitForLag runs replica-lag-query on t's instance repeatedly, until it
+// returns a value no greater than max-replica-lag. The query must return a
+// single numeric value, in seconds. A NULL result is treated as lag exceeding
+// the limit, since this typically indicates replication is not running. As
+// with the preflight check of replicas, a max-replica-lag of 0 only requires
+// a non-NULL result.
+func (th *throttle) waitForLag(t *Target) error {
+	db, err := t.Instance.Connect("", "")
+	if err != nil {
+		return err
+	}
+	for {
+		var lag sql.NullFloat64
+		if err := db.QueryRow(th.lagQuery).Scan(&lag); err != nil {
+			return fmt.Errorf("Unable to check replica lag using replica-lag-query: %s", err)
+		}
+		if lag.Valid && (th.maxLag == 0 || time.Duration(lag.Float64*float64(time.Second)) <= th.maxLag) {
+			return nil
+		}
+		lagDesc := "unknown"
+		if lag.Valid {
+			lagDesc = fmt.Sprintf("%gs", lag.Float64)
+		}
+		t.logger().Infof("%s %s: replica lag is %s, exceeding max-replica-lag of %s; waiting", t.Instance, t.SchemaName, lagDesc, th.maxLag)
+		if !interruptibleSleep(lagCheckInterval) {
+			return errInterrupted
+		}
+	}
+}
+
+// interruptibleSleep sleeps for d, returning true if the full duration
+// elapsed, or false if SIGINT was received first. SIGINT is only intercepted
+// while sleeping.
+func interruptibleSleep(d time.Duration) bool {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-sigs:
+		return false
+	}
+}
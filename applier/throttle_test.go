@@ -0,0 +1,63 @@
+package applier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottleForDir(t *testing.T) {
+	dir := getDir(t, "testdata/simple/one", "")
+	th, err := throttleForDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error from throttleForDir: %v", err)
+	}
+	if th.betweenStatements != 0 || th.betweenTargets != 0 || th.lagQuery != "" || th.maxLag != time.Minute {
+		t.Errorf("Unexpected default throttle: %+v", *th)
+	}
+
+	dir = getDir(t, "testdata/simple/one", "--sleep-between-statements=500ms --sleep-between-targets=3 --replica-lag-query='SELECT 0' --max-replica-lag=5s")
+	if th, err = throttleForDir(dir); err != nil {
+		t.Fatalf("Unexpected error from throttleForDir: %v", err)
+	}
+	expected := throttle{
+		betweenStatements: 500 * time.Millisecond,
+		betweenTargets:    3 * time.Second,
+		lagQuery:          "SELECT 0",
+		maxLag:            5 * time.Second,
+	}
+	if *th != expected {
+		t.Errorf("Expected throttle %+v, instead found %+v", expected, *th)
+	}
+
+	for _, flag := range []string{"--sleep-between-statements=soon", "--sleep-between-targets=-5s", "--max-replica-lag=lots"} {
+		dir = getDir(t, "testdata/simple/one", flag)
+		if _, err := throttleForDir(dir); err == nil {
+			t.Errorf("Expected error from throttleForDir with %s, but err was nil", flag)
+		} else if _, ok := err.(ConfigError); !ok {
+			t.Errorf("Expected ConfigError from throttleForDir with %s, instead found %T", flag, err)
+		}
+	}
+}
+
+func TestInterruptibleSleep(t *testing.T) {
+	start := time.Now()
+	if !interruptibleSleep(10 * time.Millisecond) {
+		t.Error("Expected interruptibleSleep to return true, but it returned false")
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected interruptibleSleep to sleep at least 10ms, instead only slept %s", elapsed)
+	}
+
+	// Throttles with no durations configured should never sleep
+	th := &throttle{}
+	start = time.Now()
+	if err := th.beforeTarget(nil); err != nil {
+		t.Errorf("Unexpected error from beforeTarget: %v", err)
+	}
+	if err := th.afterStatement(nil, true); err != nil {
+		t.Errorf("Unexpected error from afterStatement: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected throttle without durations to return immediately, instead took %s", elapsed)
+	}
+}
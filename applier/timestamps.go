@@ -0,0 +1,253 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
+)
+
+// This file contains logic for preventing spurious diffs in TIMESTAMP and
+// DATETIME column attributes. Different server versions report equivalent
+// CURRENT_TIMESTAMP defaults and ON UPDATE clauses differently, and the
+// explicit_defaults_for_timestamp server variable affects the attributes that
+// a TIMESTAMP column receives when its definition omits them. If the workspace
+// and the target differ in version or configuration, a column that was
+// created from an identical definition may otherwise appear to have drifted.
+
+var reCurrentTimestamp = regexp.MustCompile(`(?i)^(?:CURRENT_TIMESTAMP|NOW|LOCALTIMESTAMP|LOCALTIME)\s*(?:\(\s*(\d*)\s*\))?$`)
+
+// normalizeTimestampExpr returns the canonical form of a CURRENT_TIMESTAMP
+// expression or one of its synonyms, with any fractional-seconds precision
+// compared numerically: CURRENT_TIMESTAMP for a precision of 0, or
+// CURRENT_TIMESTAMP(N) otherwise. Other expressions are returned unchanged.
+func normalizeTimestampExpr(expr string) string {
+	matches := reCurrentTimestamp.FindStringSubmatch(strings.TrimSpace(expr))
+	if matches == nil {
+		return expr
+	}
+	if precision, _ := strconv.Atoi(matches[1]); precision > 0 {
+		return fmt.Sprintf("CURRENT_TIMESTAMP(%d)", precision)
+	}
+	return "CURRENT_TIMESTAMP"
+}
+
+func isTimestampType(typeInDB string) bool {
+	return strings.HasPrefix(typeInDB, "timestamp") || strings.HasPrefix(typeInDB, "datetime")
+}
+
+// normalizeTimestampColumn returns a copy of col with its DEFAULT and ON
+// UPDATE expressions in canonical form, if col is a TIMESTAMP or DATETIME.
+func normalizeTimestampColumn(col tengo.Column) tengo.Column {
+	if !isTimestampType(col.TypeInDB) {
+		return col
+	}
+	if !col.Default.Null && !col.Default.Quoted {
+		col.Default.Value = normalizeTimestampExpr(col.Default.Value)
+	}
+	if col.OnUpdate != "" {
+		col.OnUpdate = normalizeTimestampExpr(col.OnUpdate)
+	}
+	return col
+}
+
+// timestampAttrs describes which attributes were explicitly present in the
+// definition of a TIMESTAMP column in a CREATE TABLE statement.
+type timestampAttrs struct {
+	null     bool
+	notNull  bool
+	def      bool
+	onUpdate bool
+}
+
+var (
+	reAttrNotNull  = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	reAttrDefault  = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+	reAttrNull     = regexp.MustCompile(`(?i)\bNULL\b`)
+	reAttrOnUpdate = regexp.MustCompile(`(?i)\bON\s+UPDATE\b`)
+	reDefaultNull  = regexp.MustCompile(`(?i)\bDEFAULT\s+NULL\b`)
+	reBareName     = regexp.MustCompile(`^\w+$`)
+)
+
+// parseTimestampAttrs locates the definition of the named TIMESTAMP column in
+// the supplied CREATE TABLE statement, and returns which attributes it
+// explicitly specifies. The second return value is false if the column
+// definition could not be found.
+func parseTimestampAttrs(create, colName string) (timestampAttrs, bool) {
+	names := []string{"`" + regexp.QuoteMeta(strings.Replace(colName, "`", "``", -1)) + "`"}
+	if reBareName.MatchString(colName) {
+		names = append(names, regexp.QuoteMeta(colName))
+	}
+	re := regexp.MustCompile(`(?i)[(,]\s*(?:` + strings.Join(names, "|") + `)\s+timestamp\b`)
+	loc := re.FindStringIndex(create)
+	if loc == nil {
+		return timestampAttrs{}, false
+	}
+
+	// Extract the remainder of the column definition, blanking out any quoted
+	// strings so that keywords inside of comments are not matched
+	var b strings.Builder
+	var depth int
+	var quote rune
+	for _, c := range create[loc[1]:] {
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '\'' || c == '"' {
+			quote = c
+			b.WriteString(" '' ")
+			continue
+		} else if c == '(' {
+			depth++
+		} else if c == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if c == ',' && depth == 0 {
+			break
+		}
+		b.WriteRune(c)
+	}
+	def := b.String()
+	attrs := timestampAttrs{
+		notNull:  reAttrNotNull.MatchString(def),
+		def:      reAttrDefault.MatchString(def),
+		onUpdate: reAttrOnUpdate.MatchString(def),
+	}
+	withoutNotNull := reAttrNotNull.ReplaceAllString(def, "")
+	attrs.null = reAttrNull.MatchString(reDefaultNull.ReplaceAllString(withoutNotNull, ""))
+	return attrs, true
+}
+
+// impliedTimestampColumn returns a copy of col, a TIMESTAMP column, with any
+// attributes that were omitted from its definition set to the values the
+// server would implicitly assign based on explicitDefaults (the value of the
+// explicit_defaults_for_timestamp server variable). first indicates whether
+// col is the first TIMESTAMP column of its table.
+func impliedTimestampColumn(col tengo.Column, attrs timestampAttrs, first, explicitDefaults bool) tengo.Column {
+	var precision, fraction string
+	if openParen := strings.IndexByte(col.TypeInDB, '('); openParen > -1 {
+		precision = col.TypeInDB[openParen:]
+		if n, _ := strconv.Atoi(strings.Trim(precision, "()")); n > 0 {
+			fraction = "." + strings.Repeat("0", n)
+		}
+	}
+	if explicitDefaults {
+		col.Nullable = !attrs.notNull
+		if !attrs.def {
+			col.Default = tengo.ColumnDefaultNull
+		}
+		if !attrs.onUpdate {
+			col.OnUpdate = ""
+		}
+		return col
+	}
+
+	// With explicit_defaults_for_timestamp disabled, TIMESTAMP columns are NOT
+	// NULL unless explicitly declared NULL. The first such column automatically
+	// receives DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP if it has no
+	// other attributes; subsequent ones receive a "zero" default.
+	col.Nullable = attrs.null
+	if attrs.null || attrs.def {
+		if !attrs.def {
+			col.Default = tengo.ColumnDefaultNull
+		}
+		if !attrs.onUpdate {
+			col.OnUpdate = ""
+		}
+	} else if first && !attrs.onUpdate {
+		col.Default = tengo.ColumnDefaultExpression("CURRENT_TIMESTAMP" + precision)
+		col.OnUpdate = "CURRENT_TIMESTAMP" + precision
+	} else {
+		col.Default = tengo.ColumnDefaultValue("0000-00-00 00:00:00" + fraction)
+		if !attrs.onUpdate {
+			col.OnUpdate = ""
+		}
+	}
+	return col
+}
+
+// normalizeTimestamps returns a shallow copy of from, in which any TIMESTAMP
+// or DATETIME column that is equivalent to the corresponding column in to is
+// replaced with to's version of the column. Columns are equivalent if they
+// only differ in the representation of CURRENT_TIMESTAMP expressions, or if
+// the column's definition in creates omits attributes which the target server
+// would implicitly assign the values from from. explicitDefaults is only
+// called if needed, and should return the target's value of
+// explicit_defaults_for_timestamp. Tables only present on one side, or using
+// features unsupported by tengo, are left as-is.
+func normalizeTimestamps(from, to *tengo.Schema, creates map[tengo.ObjectKey]*fs.Statement, explicitDefaults func() bool, flavor tengo.Flavor) *tengo.Schema {
+	if from == nil || to == nil {
+		return from
+	}
+	toTables := to.TablesByName()
+	schemaCopy := *from
+	schemaCopy.Tables = make([]*tengo.Table, len(from.Tables))
+	for n, fromTable := range from.Tables {
+		schemaCopy.Tables[n] = fromTable
+		toTable, ok := toTables[fromTable.Name]
+		if !ok || fromTable.UnsupportedDDL || toTable.UnsupportedDDL {
+			continue
+		}
+		var firstTimestamp string
+		toColumns := make(map[string]*tengo.Column, len(toTable.Columns))
+		for _, col := range toTable.Columns {
+			toColumns[col.Name] = col
+			if firstTimestamp == "" && strings.HasPrefix(col.TypeInDB, "timestamp") {
+				firstTimestamp = col.Name
+			}
+		}
+		var columns []*tengo.Column
+		for i, fromCol := range fromTable.Columns {
+			toCol := toColumns[fromCol.Name]
+			if toCol == nil || fromCol.Equals(toCol) || !isTimestampType(fromCol.TypeInDB) || fromCol.TypeInDB != toCol.TypeInDB {
+				continue
+			}
+			normalized := normalizeTimestampColumn(*fromCol)
+			equivalent := normalized == normalizeTimestampColumn(*toCol)
+			if stmt := creates[tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: toTable.Name}]; !equivalent && stmt != nil && strings.HasPrefix(toCol.TypeInDB, "timestamp") {
+				if attrs, ok := parseTimestampAttrs(stmt.Body(), toCol.Name); ok {
+					implied := impliedTimestampColumn(*toCol, attrs, toCol.Name == firstTimestamp, explicitDefaults())
+					equivalent = normalized == normalizeTimestampColumn(implied)
+				}
+			}
+			if equivalent {
+				if columns == nil {
+					columns = make([]*tengo.Column, len(fromTable.Columns))
+					copy(columns, fromTable.Columns)
+				}
+				columns[i] = toCol
+			}
+		}
+		if columns != nil {
+			tableCopy := *fromTable
+			tableCopy.Columns = columns
+			tableCopy.CreateStatement = tableCopy.GeneratedCreateStatement(flavor)
+			schemaCopy.Tables[n] = &tableCopy
+		}
+	}
+	return &schemaCopy
+}
+
+// explicitDefaultsForTimestamp returns the global value of the
+// explicit_defaults_for_timestamp server variable on t's instance. If it cannot
+// be queried, the default for the instance's flavor is assumed: enabled in
+// MySQL 8.0+, disabled otherwise.
+func (t *Target) explicitDefaultsForTimestamp() bool {
+	if db, err := t.Instance.Connect("", ""); err == nil {
+		if vars, err := workspace.ServerVariables(db); err == nil {
+			if value, ok := vars["explicit_defaults_for_timestamp"]; ok {
+				return strings.EqualFold(value, "ON") || value == "1"
+			}
+		}
+	}
+	return t.Instance.Flavor().MySQLishMinVersion(8, 0)
+}
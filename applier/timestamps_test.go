@@ -0,0 +1,120 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func TestNormalizeTimestampExpr(t *testing.T) {
+	cases := map[string]string{
+		"CURRENT_TIMESTAMP":        "CURRENT_TIMESTAMP",
+		"current_timestamp()":      "CURRENT_TIMESTAMP",
+		"CURRENT_TIMESTAMP(0)":     "CURRENT_TIMESTAMP",
+		"current_timestamp(6)":     "CURRENT_TIMESTAMP(6)",
+		"CURRENT_TIMESTAMP(06)":    "CURRENT_TIMESTAMP(6)",
+		"now()":                    "CURRENT_TIMESTAMP",
+		"LOCALTIMESTAMP(3)":        "CURRENT_TIMESTAMP(3)",
+		"'2020-01-01 00:00:00'":    "'2020-01-01 00:00:00'",
+		"(now() + interval 1 day)": "(now() + interval 1 day)",
+	}
+	for input, expected := range cases {
+		if actual := normalizeTimestampExpr(input); actual != expected {
+			t.Errorf("Expected normalizeTimestampExpr(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+}
+
+func TestParseTimestampAttrs(t *testing.T) {
+	create := "CREATE TABLE `posts` (\n" +
+		"  id int unsigned NOT NULL,\n" +
+		"  created_at timestamp,\n" +
+		"  `updated_at` TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),\n" +
+		"  deleted_at timestamp NULL COMMENT 'not default, not on update',\n" +
+		"  expires_at timestamp DEFAULT NULL\n" +
+		")"
+	cases := map[string]timestampAttrs{
+		"created_at": {},
+		"updated_at": {notNull: true, def: true, onUpdate: true},
+		"deleted_at": {null: true},
+		"expires_at": {def: true},
+	}
+	for colName, expected := range cases {
+		if actual, ok := parseTimestampAttrs(create, colName); !ok || actual != expected {
+			t.Errorf("Unexpected result from parseTimestampAttrs for %s: %+v, %t", colName, actual, ok)
+		}
+	}
+	if _, ok := parseTimestampAttrs(create, "id"); ok {
+		t.Error("Expected non-timestamp column to not be found, but it was")
+	}
+}
+
+func TestNormalizeTimestamps(t *testing.T) {
+	flavor := tengo.FlavorMySQL57
+	makeSchema := func(cols ...*tengo.Column) *tengo.Schema {
+		table := &tengo.Table{
+			Name:    "posts",
+			Engine:  "InnoDB",
+			CharSet: "utf8mb4",
+			Columns: append([]*tengo.Column{{Name: "id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}}, cols...),
+		}
+		table.CreateStatement = table.GeneratedCreateStatement(flavor)
+		return &tengo.Schema{Name: "product", Tables: []*tengo.Table{table}}
+	}
+	stmt := &fs.Statement{Text: "CREATE TABLE posts (\n  id int unsigned NOT NULL,\n  created_at timestamp,\n  updated_at timestamp(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),\n  expires_at timestamp\n);\n"}
+	creates := map[tengo.ObjectKey]*fs.Statement{{Type: tengo.ObjectTypeTable, Name: "posts"}: stmt}
+
+	// Fixtures: columns as introspected from 5.7 with
+	// explicit_defaults_for_timestamp=OFF, and from 8.0 with it ON
+	created57 := &tengo.Column{Name: "created_at", TypeInDB: "timestamp", Default: tengo.ColumnDefaultExpression("CURRENT_TIMESTAMP"), OnUpdate: "CURRENT_TIMESTAMP"}
+	created80 := &tengo.Column{Name: "created_at", TypeInDB: "timestamp", Nullable: true, Default: tengo.ColumnDefaultNull}
+	updated57 := &tengo.Column{Name: "updated_at", TypeInDB: "timestamp(6)", Default: tengo.ColumnDefaultExpression("CURRENT_TIMESTAMP(6)"), OnUpdate: "CURRENT_TIMESTAMP(6)"}
+	updatedMaria := &tengo.Column{Name: "updated_at", TypeInDB: "timestamp(6)", Default: tengo.ColumnDefaultExpression("current_timestamp(6)"), OnUpdate: "current_timestamp(6)"}
+	expires57 := &tengo.Column{Name: "expires_at", TypeInDB: "timestamp", Default: tengo.ColumnDefaultValue("0000-00-00 00:00:00")}
+	expires80 := &tengo.Column{Name: "expires_at", TypeInDB: "timestamp", Nullable: true, Default: tengo.ColumnDefaultNull}
+
+	var queried int
+	explicitDefaults := func(value bool) func() bool {
+		return func() bool {
+			queried++
+			return value
+		}
+	}
+
+	// Live 5.7 target with explicit_defaults_for_timestamp=OFF, 8.0 workspace:
+	// all columns are equivalent, including ones relying on implicit attributes
+	from := makeSchema(created57, updated57, expires57)
+	to := makeSchema(created80, updatedMaria, expires80)
+	result := normalizeTimestamps(from, to, creates, explicitDefaults(false), flavor)
+	if diff := tengo.NewSchemaDiff(result, to); len(diff.ObjectDiffs()) != 0 {
+		t.Errorf("Expected no differences after normalization, instead found %d", len(diff.ObjectDiffs()))
+	}
+	if from.Tables[0].Columns[1] != created57 {
+		t.Error("Expected original schema to be unmodified, but it was changed")
+	}
+	if queried == 0 {
+		t.Error("Expected explicit_defaults_for_timestamp to be queried, but it was not")
+	}
+
+	// If the target has explicit_defaults_for_timestamp=ON, the 5.7-style
+	// implicit attributes are a real difference
+	result = normalizeTimestamps(from, to, creates, explicitDefaults(true), flavor)
+	if cols := result.Tables[0].Columns; cols[1] != created57 || cols[2] != updatedMaria || cols[3] != expires57 {
+		t.Errorf("Unexpected columns after normalization: %+v", cols)
+	}
+
+	// Without the CREATE statement, only expression representation is normalized
+	result = normalizeTimestamps(from, to, nil, explicitDefaults(false), flavor)
+	if cols := result.Tables[0].Columns; cols[1] != created57 || cols[2] != updatedMaria {
+		t.Errorf("Unexpected columns after normalization: %+v", cols)
+	}
+
+	// Tables without any equivalent columns are unchanged, and don't query
+	queried = 0
+	from = makeSchema(&tengo.Column{Name: "created_at", TypeInDB: "datetime", Default: tengo.ColumnDefaultValue("2020-01-01 00:00:00")})
+	to = makeSchema(&tengo.Column{Name: "created_at", TypeInDB: "datetime", Default: tengo.ColumnDefaultExpression("CURRENT_TIMESTAMP")})
+	if result = normalizeTimestamps(from, to, creates, explicitDefaults(false), flavor); result.Tables[0] != from.Tables[0] || queried != 0 {
+		t.Errorf("Expected table to be unchanged without querying, instead found %+v, queried=%d", result.Tables[0], queried)
+	}
+}
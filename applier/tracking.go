@@ -0,0 +1,97 @@
+package applier
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/skeema/tengo"
+)
+
+// trackingTable returns a table definition for the tracking table with the
+// supplied name. The table only ever contains a single row, identified by
+// id=1, which is rewritten after each successful push.
+func trackingTable(name string) *tengo.Table {
+	create := fmt.Sprintf("CREATE TABLE %s (\n"+
+		"  `id` tinyint(3) unsigned NOT NULL,\n"+
+		"  `fingerprint` char(64) NOT NULL,\n"+
+		"  `label` varchar(255) NOT NULL DEFAULT '',\n"+
+		"  `pushed_at` datetime NOT NULL,\n"+
+		"  PRIMARY KEY (`id`)\n"+
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4", tengo.EscapeIdentifier(name))
+	return &tengo.Table{
+		Name:            name,
+		Engine:          "InnoDB",
+		CharSet:         "utf8mb4",
+		CreateStatement: create,
+	}
+}
+
+// withoutTable returns a shallow copy of schema which excludes the table with
+// the supplied name, along with whether the table was present. The tracking
+// table is excluded from both sides of the diff in this manner, so that it is
+// never dropped for being absent from the filesystem. A nil schema is returned
+// as-is.
+func withoutTable(schema *tengo.Schema, name string) (*tengo.Schema, bool) {
+	if schema == nil || !schema.HasTable(name) {
+		return schema, false
+	}
+	schemaCopy := *schema
+	schemaCopy.Tables = make([]*tengo.Table, 0, len(schema.Tables)-1)
+	for _, table := range schema.Tables {
+		if table.Name != name {
+			schemaCopy.Tables = append(schemaCopy.Tables, table)
+		}
+	}
+	return &schemaCopy, true
+}
+
+// schemaFingerprint returns a hash of the definitions of all objects in
+// schema, for recording in the tracking table. The hash does not depend on the
+// ordering of objects within schema.
+func schemaFingerprint(schema *tengo.Schema) string {
+	return rollUpFingerprints(ObjectFingerprints(schema))
+}
+
+// recordPush writes a row to the tracking table on t's instance, indicating
+// that t's schema now matches desired. The DDL credentials are used, since
+// this modifies the database. The write is a single-row REPLACE by primary key,
+// so it does not require any locking beyond that row.
+func (t *Target) recordPush(tableName string, desired *tengo.Schema) error {
+	ddlInstance, err := t.Dir.DDLInstance(t.Instance)
+	if err != nil {
+		return err
+	}
+	db, err := ddlInstance.Connect(t.SchemaName, "")
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("REPLACE INTO %s (id, fingerprint, label, pushed_at) VALUES (1, ?, ?, UTC_TIMESTAMP())", tengo.EscapeIdentifier(tableName))
+	_, err = db.Exec(query, schemaFingerprint(desired), t.Dir.Config.Get("label"))
+	return err
+}
+
+// logLastPush reads the tracking table on t's instance, and logs the label and
+// time of the last push, along with whether it matches desired. If no push has
+// been recorded yet, nothing is logged.
+func (t *Target) logLastPush(tableName string, desired *tengo.Schema) error {
+	db, err := t.Instance.Connect(t.SchemaName, "")
+	if err != nil {
+		return err
+	}
+	var fp, label, pushedAt string
+	query := fmt.Sprintf("SELECT fingerprint, label, pushed_at FROM %s WHERE id = 1", tengo.EscapeIdentifier(tableName))
+	if err := db.QueryRow(query).Scan(&fp, &label, &pushedAt); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	status := "differs from"
+	if fp == schemaFingerprint(desired) {
+		status = "matches"
+	}
+	if label == "" {
+		label = "(none)"
+	}
+	t.logger().Infof("%s %s: last pushed at %s UTC with label %s; recorded fingerprint %s %s/*.sql", t.Instance, t.SchemaName, pushedAt, label, status, t.Dir)
+	return nil
+}
@@ -0,0 +1,68 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestWithoutTable(t *testing.T) {
+	schema := &tengo.Schema{
+		Name:   "product",
+		Tables: []*tengo.Table{brokenFKTestTable("users"), trackingTable("_skeema_meta"), brokenFKTestTable("posts")},
+	}
+	result, found := withoutTable(schema, "_skeema_meta")
+	if !found || len(result.Tables) != 2 || result.HasTable("_skeema_meta") {
+		t.Errorf("Unexpected result from withoutTable: %+v, %t", result.Tables, found)
+	}
+	if len(schema.Tables) != 3 {
+		t.Error("withoutTable unexpectedly modified the original schema")
+	}
+	if result, found := withoutTable(result, "_skeema_meta"); found || len(result.Tables) != 2 {
+		t.Errorf("Unexpected result from withoutTable on schema lacking table: %+v, %t", result.Tables, found)
+	}
+	if result, found := withoutTable(nil, "_skeema_meta"); found || result != nil {
+		t.Errorf("Unexpected result from withoutTable on nil schema: %+v, %t", result, found)
+	}
+}
+
+func TestTrackingTableStatement(t *testing.T) {
+	stmt, err := tengo.NewCreateTable(trackingTable("_skeema_meta")).Statement(tengo.StatementModifiers{})
+	expected := "CREATE TABLE `_skeema_meta` (\n" +
+		"  `id` tinyint(3) unsigned NOT NULL,\n" +
+		"  `fingerprint` char(64) NOT NULL,\n" +
+		"  `label` varchar(255) NOT NULL DEFAULT '',\n" +
+		"  `pushed_at` datetime NOT NULL,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	if err != nil || stmt != expected {
+		t.Errorf("Unexpected statement for tracking table: %q / %v", stmt, err)
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	schema := &tengo.Schema{
+		Name:      "product",
+		CharSet:   "utf8mb4",
+		Collation: "utf8mb4_general_ci",
+		Tables:    []*tengo.Table{brokenFKTestTable("users"), brokenFKTestTable("posts")},
+	}
+	fp := schemaFingerprint(schema)
+	if len(fp) != 64 {
+		t.Errorf("Unexpected fingerprint %q", fp)
+	}
+
+	// Fingerprint should not depend on table order or schema name
+	shard := *schema
+	shard.Name = "product_2"
+	shard.Tables = []*tengo.Table{schema.Tables[1], schema.Tables[0]}
+	if shardFP := schemaFingerprint(&shard); shardFP != fp {
+		t.Errorf("Expected fingerprints to match, instead found %s vs %s", shardFP, fp)
+	}
+
+	// Fingerprint should change if any definition changes
+	shard.Tables = append(shard.Tables, brokenFKTestTable("comments"))
+	if shardFP := schemaFingerprint(&shard); shardFP == fp {
+		t.Error("Expected fingerprint to change after adding a table, but it did not")
+	}
+}
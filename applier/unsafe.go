@@ -0,0 +1,217 @@
+package applier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// UnsafeCode is a stable identifier for a category of potentially destructive
+// operation, permitting tools to route or approve unsafe changes by category
+// without parsing free-text notes. Codes are grouped by hundreds: US1xx for
+// operations which drop objects or data, US2xx for column changes which may
+// alter or lose existing values, and US3xx for table-level changes which
+// rebuild the table or exceed server limits. Once assigned, a code is never
+// renumbered or reused.
+type UnsafeCode string
+
+// Constants enumerating valid unsafe codes
+const (
+	UnsafeDropTable       UnsafeCode = "US101"
+	UnsafeDropColumn      UnsafeCode = "US102"
+	UnsafeDropRoutine     UnsafeCode = "US103"
+	UnsafeDropPartition   UnsafeCode = "US104"
+	UnsafeDropBrokenFK    UnsafeCode = "US105"
+	UnsafeRevokeGrant     UnsafeCode = "US106"
+	UnsafeLossyTypeChange UnsafeCode = "US201"
+	UnsafeCharsetChange   UnsafeCode = "US202"
+	UnsafeEnumSetChange   UnsafeCode = "US203"
+	UnsafeSRIDChange      UnsafeCode = "US204"
+	UnsafeRenameColumn    UnsafeCode = "US205"
+	UnsafeEngineChange    UnsafeCode = "US301"
+	UnsafeIndexKeySize    UnsafeCode = "US302"
+	UnsafeRowSize         UnsafeCode = "US303"
+)
+
+// UnsafeCodeDescriptions maps each valid UnsafeCode to a short description of
+// the category of operation it represents.
+var UnsafeCodeDescriptions = map[UnsafeCode]string{
+	UnsafeDropTable:       "drops a table",
+	UnsafeDropColumn:      "drops a column",
+	UnsafeDropRoutine:     "drops a stored procedure or function, possibly to re-create it",
+	UnsafeDropPartition:   "drops one or more partitions",
+	UnsafeDropBrokenFK:    "drops a foreign key referencing a missing table",
+	UnsafeRevokeGrant:     "revokes privileges from a user listed in manage-grants",
+	UnsafeLossyTypeChange: "changes a column's type in a way which may truncate or reject existing values",
+	UnsafeCharsetChange:   "changes a column's character set",
+	UnsafeEnumSetChange:   "removes or reorders values of an ENUM or SET column",
+	UnsafeSRIDChange:      "changes the SRID of a spatial column",
+	UnsafeRenameColumn:    "renames a column",
+	UnsafeEngineChange:    "changes a table's storage engine, rebuilding the table",
+	UnsafeIndexKeySize:    "causes an index to exceed the server's index key size limit",
+	UnsafeRowSize:         "causes a table to exceed the server's row size limit",
+}
+
+// unsafeCodeNames maps each valid UnsafeCode to its short name.
+var unsafeCodeNames = map[UnsafeCode]string{
+	UnsafeDropTable:       "drop-table",
+	UnsafeDropColumn:      "drop-column",
+	UnsafeDropRoutine:     "drop-routine",
+	UnsafeDropPartition:   "drop-partition",
+	UnsafeDropBrokenFK:    "drop-broken-foreign-key",
+	UnsafeRevokeGrant:     "revoke-grant",
+	UnsafeLossyTypeChange: "lossy-type-change",
+	UnsafeCharsetChange:   "charset-change",
+	UnsafeEnumSetChange:   "enum-set-change",
+	UnsafeSRIDChange:      "srid-change",
+	UnsafeRenameColumn:    "rename-column",
+	UnsafeEngineChange:    "engine-change",
+	UnsafeIndexKeySize:    "index-key-size",
+	UnsafeRowSize:         "row-size",
+}
+
+// Name returns the short name of c, such as "drop-table".
+func (c UnsafeCode) Name() string {
+	return unsafeCodeNames[c]
+}
+
+// String returns c along with its short name, such as "US101 drop-table".
+func (c UnsafeCode) String() string {
+	if name := c.Name(); name != "" {
+		return string(c) + " " + name
+	}
+	return string(c)
+}
+
+// withUnsafeCode returns codes with code added, if not already present, keeping
+// the codes sorted. A blank code is ignored.
+func withUnsafeCode(codes []UnsafeCode, code UnsafeCode) []UnsafeCode {
+	if code == "" {
+		return codes
+	}
+	for _, existing := range codes {
+		if existing == code {
+			return codes
+		}
+	}
+	codes = append(codes, code)
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// mergeUnsafeCodes returns the sorted union of a and b.
+func mergeUnsafeCodes(a, b []UnsafeCode) []UnsafeCode {
+	for _, code := range b {
+		a = withUnsafeCode(a, code)
+	}
+	return a
+}
+
+// describeUnsafeCodes returns a comma-separated list of codes along with their
+// names, for use in human-readable output.
+func describeUnsafeCodes(codes []UnsafeCode) string {
+	descriptions := make([]string, len(codes))
+	for n, code := range codes {
+		descriptions[n] = code.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// diffUnsafeCodes returns the codes of all potentially destructive operations
+// performed by diff, based solely on the type of diff and its ALTER TABLE
+// clauses. Unsafe operations which are only detected by a note, such as an
+// excessive index key size, are not included.
+func diffUnsafeCodes(diff tengo.ObjectDiff) (codes []UnsafeCode) {
+	switch diff := diff.(type) {
+	case *tengo.TableDiff:
+		if diff.Type == tengo.DiffTypeDrop {
+			return []UnsafeCode{UnsafeDropTable}
+		} else if diff.Type != tengo.DiffTypeAlter {
+			return nil
+		}
+		clauses, _ := tableDiffClauses(diff)
+		for _, clause := range clauses {
+			codes = withUnsafeCode(codes, clauseUnsafeCode(clause))
+		}
+	case *tengo.RoutineDiff:
+		if diff.DiffType() == tengo.DiffTypeDrop {
+			return []UnsafeCode{UnsafeDropRoutine}
+		}
+	case *grantDiff:
+		if diff.Revoke {
+			return []UnsafeCode{UnsafeRevokeGrant}
+		}
+	}
+	return codes
+}
+
+// clauseUnsafeCode returns the code of clause if tengo considers it unsafe, or
+// a blank string otherwise.
+func clauseUnsafeCode(clause tengo.TableAlterClause) UnsafeCode {
+	if unsafer, ok := clause.(tengo.Unsafer); !ok || !unsafer.Unsafe() {
+		return ""
+	}
+	switch clause := clause.(type) {
+	case tengo.DropColumn:
+		return UnsafeDropColumn
+	case tengo.RenameColumn:
+		return UnsafeRenameColumn
+	case tengo.ChangeStorageEngine:
+		return UnsafeEngineChange
+	case tengo.ModifyPartitions:
+		return UnsafeDropPartition
+	case tengo.ModifyColumn:
+		oldType, newType := clause.OldColumn.TypeInDB, clause.NewColumn.TypeInDB
+		if clause.OldColumn.CharSet != clause.NewColumn.CharSet {
+			return UnsafeCharsetChange
+		} else if oldType != newType && reColumnSRID.ReplaceAllString(oldType, "") == reColumnSRID.ReplaceAllString(newType, "") {
+			return UnsafeSRIDChange
+		}
+		oldKind, _, oldOK := parseEnumSetMembers(oldType)
+		newKind, _, newOK := parseEnumSetMembers(newType)
+		if oldOK && newOK && oldKind == newKind {
+			return UnsafeEnumSetChange
+		}
+	}
+	return UnsafeLossyTypeChange
+}
+
+// unsafeCodeSet is a set of unsafe codes permitted by the allow-unsafe-codes
+// option.
+type unsafeCodeSet map[UnsafeCode]bool
+
+// permits returns true if codes is non-empty and every code in it is in set.
+func (set unsafeCodeSet) permits(codes []UnsafeCode) bool {
+	if len(codes) == 0 {
+		return false
+	}
+	for _, code := range codes {
+		if !set[code] {
+			return false
+		}
+	}
+	return true
+}
+
+// allowedUnsafeCodes returns the set of codes permitted by t's
+// allow-unsafe-codes option, or a ConfigError if the option includes any
+// unknown codes. US302 is also rejected, since oversized index keys are only
+// permitted by the allow-oversized-keys option.
+func (t *Target) allowedUnsafeCodes() (unsafeCodeSet, error) {
+	if t.Dir.Config.FindOption("allow-unsafe-codes") == nil {
+		return nil, nil
+	}
+	set := make(unsafeCodeSet)
+	for _, value := range t.Dir.Config.GetSlice("allow-unsafe-codes", ',', true) {
+		code := UnsafeCode(strings.ToUpper(value))
+		if _, ok := UnsafeCodeDescriptions[code]; !ok {
+			return nil, ConfigError(fmt.Sprintf("Option allow-unsafe-codes contains unknown code %q", value))
+		} else if code == UnsafeIndexKeySize {
+			return nil, ConfigError(fmt.Sprintf("Option allow-unsafe-codes cannot permit %s; use allow-oversized-keys instead", code))
+		}
+		set[code] = true
+	}
+	return set, nil
+}
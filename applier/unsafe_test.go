@@ -0,0 +1,176 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// TestUnsafeCodeValues locks the value and name of every unsafe code, since
+// external tools rely on these remaining stable. Codes must never be
+// renumbered; new codes should be added to this list rather than changing it.
+func TestUnsafeCodeValues(t *testing.T) {
+	expected := map[UnsafeCode]string{
+		"US101": "drop-table",
+		"US102": "drop-column",
+		"US103": "drop-routine",
+		"US104": "drop-partition",
+		"US105": "drop-broken-foreign-key",
+		"US106": "revoke-grant",
+		"US201": "lossy-type-change",
+		"US202": "charset-change",
+		"US203": "enum-set-change",
+		"US204": "srid-change",
+		"US205": "rename-column",
+		"US301": "engine-change",
+		"US302": "index-key-size",
+		"US303": "row-size",
+	}
+	if len(UnsafeCodeDescriptions) != len(expected) || len(unsafeCodeNames) != len(expected) {
+		t.Errorf("Expected %d unsafe codes, instead found %d descriptions and %d names", len(expected), len(UnsafeCodeDescriptions), len(unsafeCodeNames))
+	}
+	for code, name := range expected {
+		if code.Name() != name {
+			t.Errorf("Expected code %s to have name %q, instead found %q", code, name, code.Name())
+		}
+		if UnsafeCodeDescriptions[code] == "" {
+			t.Errorf("Code %s has no description", code)
+		}
+	}
+	constants := []UnsafeCode{UnsafeDropTable, UnsafeDropColumn, UnsafeDropRoutine, UnsafeDropPartition, UnsafeDropBrokenFK, UnsafeRevokeGrant, UnsafeLossyTypeChange, UnsafeCharsetChange, UnsafeEnumSetChange, UnsafeSRIDChange, UnsafeRenameColumn, UnsafeEngineChange, UnsafeIndexKeySize, UnsafeRowSize}
+	for _, code := range constants {
+		if _, ok := expected[code]; !ok {
+			t.Errorf("Constant value %s is not in the locked list of codes", code)
+		}
+	}
+	if actual := UnsafeDropTable.String(); actual != "US101 drop-table" {
+		t.Errorf("Unexpected String() result: %q", actual)
+	}
+}
+
+func TestDiffUnsafeCodes(t *testing.T) {
+	from := brokenFKTestTable("orphans", "parents", "users")
+	to := brokenFKTestTable("orphans", "parents")
+	to.Engine = "MyISAM"
+	to.Columns[0].TypeInDB = "smallint(5) unsigned"
+	to.CreateStatement = to.GeneratedCreateStatement(tengo.FlavorMySQL57)
+	codes := diffUnsafeCodes(tengo.NewAlterTable(from, to))
+	expected := []UnsafeCode{UnsafeDropColumn, UnsafeLossyTypeChange, UnsafeEngineChange}
+	if len(codes) != len(expected) {
+		t.Fatalf("Expected codes %v, instead found %v", expected, codes)
+	}
+	for n := range codes {
+		if codes[n] != expected[n] {
+			t.Errorf("Expected codes %v, instead found %v", expected, codes)
+			break
+		}
+	}
+	if codes := diffUnsafeCodes(tengo.NewDropTable(from)); len(codes) != 1 || codes[0] != UnsafeDropTable {
+		t.Errorf("Unexpected codes for DROP TABLE: %v", codes)
+	}
+	if codes := diffUnsafeCodes(tengo.NewCreateTable(from)); len(codes) != 0 {
+		t.Errorf("Expected no codes for CREATE TABLE, instead found %v", codes)
+	}
+	routine := journalTestRoutine("RETURN 1")
+	if codes := diffUnsafeCodes(&tengo.RoutineDiff{From: routine}); len(codes) != 1 || codes[0] != UnsafeDropRoutine {
+		t.Errorf("Unexpected codes for DROP FUNCTION: %v", codes)
+	}
+}
+
+func TestClauseUnsafeCode(t *testing.T) {
+	modify := func(oldType, newType, oldCharSet, newCharSet string) tengo.ModifyColumn {
+		return tengo.ModifyColumn{
+			OldColumn: &tengo.Column{Name: "c", TypeInDB: oldType, CharSet: oldCharSet},
+			NewColumn: &tengo.Column{Name: "c", TypeInDB: newType, CharSet: newCharSet},
+		}
+	}
+	cases := []struct {
+		clause   tengo.TableAlterClause
+		expected UnsafeCode
+	}{
+		{modify("varchar(20)", "varchar(30)", "utf8mb4", "utf8mb4"), ""},
+		{modify("varchar(30)", "varchar(20)", "utf8mb4", "utf8mb4"), UnsafeLossyTypeChange},
+		{modify("varchar(20)", "varchar(20)", "latin1", "utf8mb4"), UnsafeCharsetChange},
+		{modify("enum('a','b')", "enum('b','a')", "utf8mb4", "utf8mb4"), UnsafeEnumSetChange},
+		{modify("point", "point /*!80003 SRID 4326 */", "", ""), UnsafeSRIDChange},
+		{tengo.DropColumn{Column: &tengo.Column{Name: "c"}}, UnsafeDropColumn},
+		{tengo.DropColumn{Column: &tengo.Column{Name: "c", Virtual: true}}, ""},
+		{tengo.AddColumn{Column: &tengo.Column{Name: "c"}}, ""},
+	}
+	for _, c := range cases {
+		if actual := clauseUnsafeCode(c.clause); actual != c.expected {
+			t.Errorf("Expected code %q for %+v, instead found %q", c.expected, c.clause, actual)
+		}
+	}
+}
+
+func TestAllowedUnsafeCodes(t *testing.T) {
+	target := &Target{Dir: getDir(t, "testdata/simple/one", "--allow-unsafe-codes=us101,US102")}
+	set, err := target.allowedUnsafeCodes()
+	if err != nil {
+		t.Fatalf("Unexpected error from allowedUnsafeCodes: %v", err)
+	}
+	if !set.permits([]UnsafeCode{UnsafeDropColumn}) || !set.permits([]UnsafeCode{UnsafeDropTable, UnsafeDropColumn}) {
+		t.Errorf("Expected set %v to permit its own codes", set)
+	}
+	if set.permits([]UnsafeCode{UnsafeDropColumn, UnsafeLossyTypeChange}) || set.permits(nil) {
+		t.Errorf("Expected set %v to only permit non-empty subsets of its own codes", set)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--allow-unsafe-codes=US101,US999")
+	if _, err := target.allowedUnsafeCodes(); err == nil || !strings.Contains(err.Error(), "US999") {
+		t.Errorf("Expected error naming unknown code, instead found %v", err)
+	}
+
+	// Oversized index keys have their own option instead
+	target.Dir = getDir(t, "testdata/simple/one", "--allow-unsafe-codes=US302")
+	if _, err := target.allowedUnsafeCodes(); err == nil || !strings.Contains(err.Error(), "allow-oversized-keys") {
+		t.Errorf("Expected error referring to allow-oversized-keys, instead found %v", err)
+	}
+}
+
+func TestNewDDLStatementUnsafeCodes(t *testing.T) {
+	inst, err := tengo.NewInstance("mysql", "root:@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	}
+	from := brokenFKTestTable("orphans", "parents", "users")
+	to := brokenFKTestTable("orphans", "parents")
+	newDDL := func(flags string) (*DDLStatement, error) {
+		t.Helper()
+		target := &Target{
+			Instance:   inst,
+			Dir:        getDir(t, "testdata/simple/one", "--dry-run "+flags),
+			SchemaName: "product",
+		}
+		mods := tengo.StatementModifiers{
+			AllowUnsafe: target.Dir.Config.GetBool("allow-unsafe"),
+			Flavor:      tengo.FlavorMySQL57,
+		}
+		return NewDDLStatement(tengo.NewAlterTable(from, to), mods, target)
+	}
+
+	// Permitted by code, and tagged with it in output
+	ddl, err := newDDL("--allow-unsafe-codes=US102")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewDDLStatement: %v", err)
+	}
+	if !ddl.unsafe || len(ddl.unsafeCodes) != 1 || ddl.unsafeCodes[0] != UnsafeDropColumn {
+		t.Errorf("Unexpected unsafe status: unsafe=%t codes=%v", ddl.unsafe, ddl.unsafeCodes)
+	}
+	if tag, _ := ddlTag(ddl); tag != "unsafe US102" {
+		t.Errorf("Unexpected tag: %q", tag)
+	}
+
+	// Refused when the code isn't permitted, with the error naming the code
+	_, err = newDDL("--allow-unsafe-codes=US101")
+	if use, ok := err.(unsafeStatementError); !ok || len(use.codes) != 1 || use.codes[0] != UnsafeDropColumn || !strings.Contains(err.Error(), "[US102 drop-column]") {
+		t.Errorf("Unexpected error from NewDDLStatement: %v", err)
+	}
+
+	// Blanket allow-unsafe still records the codes
+	if ddl, err := newDDL("--allow-unsafe"); err != nil || len(ddl.unsafeCodes) != 1 {
+		t.Errorf("Unexpected result from NewDDLStatement with allow-unsafe: %+v, %v", ddl, err)
+	}
+}
@@ -0,0 +1,69 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/skeema/workspace"
+)
+
+// checkVariables compares server variables affecting DDL semantics between
+// t's workspace and t's instance, logging a warning block listing any
+// mismatches. If the variable-mismatch option is set to "error", a non-nil
+// error is returned when mismatches are found. A ConfigError is returned if
+// the option value is invalid.
+func (t *Target) checkVariables() error {
+	mode, err := t.Dir.Config.GetEnum("variable-mismatch", "ignore", "warning", "error")
+	if err != nil {
+		return ConfigError(err.Error())
+	} else if mode == "ignore" || t.DesiredSchema == nil || t.DesiredSchema.Variables == nil {
+		return nil
+	}
+	db, err := t.Instance.Connect("", "")
+	if err != nil {
+		return err
+	}
+	targetVars, err := workspace.ServerVariables(db)
+	if err != nil {
+		t.logger().Debugf("Unable to query server variables of %s: %s", t.Instance, err)
+		return nil
+	}
+	mismatches := variableMismatches(t.DesiredSchema.Variables, targetVars)
+	if len(mismatches) == 0 {
+		return nil
+	}
+	logFunc := t.logger().Warnf
+	if mode == "error" {
+		logFunc = t.logger().Errorf
+	}
+	logFunc("Server variables affecting DDL differ between workspace and %s:", t.Instance)
+	for _, mismatch := range mismatches {
+		logFunc("  %s", mismatch)
+	}
+	if mode == "error" {
+		return fmt.Errorf("%s differs from workspace (variable-mismatch=error)", countAndNoun(len(mismatches), "server variable"))
+	}
+	return nil
+}
+
+// variableMismatches returns descriptions of variables whose values differ
+// between wsVars and targetVars, in the same order as DDLVariables. Values are compared case-
+// insensitively. A variable present on only one side is considered a mismatch.
+func variableMismatches(wsVars, targetVars map[string]string) []string {
+	var mismatches []string
+	for _, name := range workspace.DDLVariables {
+		wsValue, wsOK := wsVars[name]
+		targetValue, targetOK := targetVars[name]
+		if wsOK == targetOK && strings.EqualFold(wsValue, targetValue) {
+			continue
+		}
+		if !wsOK {
+			wsValue = "(not present)"
+		}
+		if !targetOK {
+			targetValue = "(not present)"
+		}
+		mismatches = append(mismatches, fmt.Sprintf("%s: workspace=%s target=%s", name, wsValue, targetValue))
+	}
+	return mismatches
+}
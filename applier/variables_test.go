@@ -0,0 +1,61 @@
+package applier
+
+import (
+	"testing"
+
+	"github.com/skeema/skeema/workspace"
+)
+
+func TestVariableMismatches(t *testing.T) {
+	wsVars := map[string]string{
+		"default_storage_engine":          "InnoDB",
+		"explicit_defaults_for_timestamp": "OFF",
+		"foreign_key_checks":              "ON",
+		"innodb_file_per_table":           "ON",
+		"innodb_strict_mode":              "OFF",
+	}
+	targetVars := map[string]string{
+		"default_storage_engine": "innodb",
+		"foreign_key_checks":     "OFF",
+		"innodb_file_per_table":  "ON",
+		"innodb_strict_mode":     "ON",
+	}
+	expected := []string{
+		"explicit_defaults_for_timestamp: workspace=OFF target=(not present)",
+		"foreign_key_checks: workspace=ON target=OFF",
+		"innodb_strict_mode: workspace=OFF target=ON",
+	}
+	actual := variableMismatches(wsVars, targetVars)
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d mismatches, instead found %d: %v", len(expected), len(actual), actual)
+	}
+	for n := range expected {
+		if actual[n] != expected[n] {
+			t.Errorf("Expected mismatch[%d] to be %q, instead found %q", n, expected[n], actual[n])
+		}
+	}
+	if mismatches := variableMismatches(wsVars, wsVars); len(mismatches) != 0 {
+		t.Errorf("Expected no mismatches comparing variables to themselves, instead found %v", mismatches)
+	}
+}
+
+func TestCheckVariablesNoQuery(t *testing.T) {
+	// None of these cases should attempt to connect to the nil instance
+	target := &Target{
+		Dir:           getDir(t, "testdata/simple/one", "--variable-mismatch=ignore"),
+		SchemaName:    "product",
+		DesiredSchema: &workspace.Schema{Variables: map[string]string{"innodb_strict_mode": "ON"}},
+	}
+	if err := target.checkVariables(); err != nil {
+		t.Errorf("Unexpected error from checkVariables: %s", err)
+	}
+	target.Dir = getDir(t, "testdata/simple/one", "--variable-mismatch=error")
+	target.DesiredSchema.Variables = nil
+	if err := target.checkVariables(); err != nil {
+		t.Errorf("Unexpected error from checkVariables: %s", err)
+	}
+	target.Dir = getDir(t, "testdata/simple/one", "--variable-mismatch=sometimes")
+	if _, ok := target.checkVariables().(ConfigError); !ok {
+		t.Error("Expected invalid variable-mismatch value to return a ConfigError")
+	}
+}
@@ -110,3 +110,104 @@ func VerifyDiff(diff *tengo.SchemaDiff, t *Target) error {
 func wantVerify(diff *tengo.SchemaDiff, t *Target) bool {
 	return t.Dir.Config.GetBool("verify") && len(diff.TableDiffs) > 0 && !t.briefOutput()
 }
+
+// verifySequence confirms that executing ddls, in order, would transform from
+// into to. A workspace is populated with the definitions of from, and then the
+// statements of ddls are run there sequentially. Afterwards, each object in
+// keys is compared to its definition in to, and must not require any further
+// DDL using mods. Before comparison, normalize is applied to the workspace's
+// resulting schema, to account for differences which are intentionally
+// excluded from diffs. Statements from passthrough files are not run, since
+// their effects are not modeled, nor are database-level statements or changes
+// to privileges.
+func verifySequence(ddls []*DDLStatement, from, to *tengo.Schema, keys []tengo.ObjectKey, mods tengo.StatementModifiers, normalize func(*tengo.Schema) *tengo.Schema, t *Target) error {
+	logicalSchema := &fs.LogicalSchema{
+		CharSet:   from.CharSet,
+		Collation: from.Collation,
+		Creates:   make(map[tengo.ObjectKey]*fs.Statement),
+		Alters:    make([]*fs.Statement, 0, len(ddls)),
+	}
+	currentDefs := from.ObjectDefinitions()
+	for key, create := range currentDefs {
+		logicalSchema.AddStatement(&fs.Statement{
+			Type:       fs.StatementTypeCreate,
+			Text:       create,
+			ObjectType: key.Type,
+			ObjectName: key.Name,
+		})
+	}
+	for _, ddl := range ddls {
+		if _, isGrant := ddl.diff.(*grantDiff); ddl.passthrough || ddl.schemaName == "" || isGrant {
+			continue
+		}
+		// Manual migrations have no diff, and may only manipulate tables
+		stmt := &fs.Statement{
+			Type:       fs.StatementTypeAlter,
+			Text:       ddl.stmt,
+			ObjectType: tengo.ObjectTypeTable,
+		}
+		if ddl.diff != nil {
+			key := ddl.diff.ObjectKey()
+			stmt.ObjectType, stmt.ObjectName = key.Type, key.Name
+			if ddl.diff.DiffType() == tengo.DiffTypeCreate {
+				stmt.Type = fs.StatementTypeCreate // for correct sql_mode of new routines
+			}
+		}
+		logicalSchema.Alters = append(logicalSchema.Alters, stmt)
+	}
+
+	opts, err := workspace.OptionsForDir(t.Dir, t.Instance)
+	if err != nil {
+		return err
+	}
+	wsSchema, err := workspace.ExecLogicalSchema(logicalSchema, opts)
+	if err == nil && len(wsSchema.Failures) > 0 {
+		err = wsSchema.Failures[0]
+	}
+	if err != nil {
+		return fmt.Errorf("Statement sequence verification failure: %s\nRun command again with --skip-verify-sequence if this is safe to ignore", err.Error())
+	}
+
+	// Only the modified objects are compared, since other objects may have
+	// differences that are intentionally ignored, through ignore-table or by
+	// being unsupported
+	mods.NextAutoInc = tengo.NextAutoIncIgnore
+	mods.AllowUnsafe = true
+	touched := make(map[tengo.ObjectKey]bool, len(keys))
+	for _, key := range keys {
+		touched[key] = true
+	}
+	expectedDefs, gotDefs := to.ObjectDefinitions(), wsSchema.ObjectDefinitions()
+	for _, objDiff := range tengo.NewSchemaDiff(normalize(wsSchema.Schema), to).ObjectDiffs() {
+		key := objDiff.ObjectKey()
+		if !touched[key] {
+			continue
+		}
+		stmt, err := objDiff.Statement(mods)
+		if _, unsupported := err.(*tengo.UnsupportedDiffError); unsupported {
+			expectCreate, _ := tengo.ParseCreateAutoInc(expectedDefs[key])
+			gotCreate, _ := tengo.ParseCreateAutoInc(gotDefs[key])
+			if expectCreate == gotCreate {
+				continue
+			}
+		} else if stmt == "" && err == nil {
+			continue
+		}
+		return sequenceMismatchError(key, currentDefs[key], expectedDefs[key], gotDefs[key])
+	}
+	return nil
+}
+
+// sequenceMismatchError returns an error describing an object whose definition
+// after running a statement sequence in a workspace (got) does not match the
+// desired definition (expected). Blank definitions indicate the object does
+// not exist.
+func sequenceMismatchError(key tengo.ObjectKey, current, expected, got string) error {
+	def := func(create string) string {
+		if create == "" {
+			return "(does not exist)"
+		}
+		return create
+	}
+	return fmt.Errorf("Statement sequence verification failure on %s\n\nCURRENT:\n%s\n\nEXPECTED:\n%s\n\nACTUAL AFTER RUNNING STATEMENTS IN WORKSPACE:\n%s\n\nRun command again with --skip-verify-sequence if this discrepancy is safe to ignore", key, def(current), def(expected), def(got))
+}
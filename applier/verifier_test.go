@@ -0,0 +1,85 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func (s ApplierIntegrationSuite) TestVerifySequence(t *testing.T) {
+	if _, err := s.d[0].SourceSQL("testdata/setup.sql"); err != nil {
+		t.Fatalf("Unexpected error from SourceSQL: %s", err)
+	}
+	from, err := s.d[0].Schema("analytics")
+	if err != nil {
+		t.Fatalf("Unable to obtain schema: %s", err)
+	}
+	db, err := s.d[0].Connect("analytics", "")
+	if err != nil {
+		t.Fatalf("Unable to connect to DockerizedInstance: %s", err)
+	}
+	if _, err := db.Exec("ALTER TABLE pageviews ADD COLUMN referrer varchar(100)"); err != nil {
+		t.Fatalf("Unexpected error from ALTER: %s", err)
+	}
+	to, err := s.d[0].Schema("analytics")
+	if err != nil {
+		t.Fatalf("Unable to obtain schema: %s", err)
+	}
+
+	target := &Target{
+		Instance:   s.d[0].Instance,
+		Dir:        getDir(t, "testdata/simple/one", ""),
+		SchemaName: "analytics",
+	}
+	mods := tengo.StatementModifiers{Flavor: s.d[0].Flavor()}
+	var ddls []*DDLStatement
+	var keys []tengo.ObjectKey
+	for _, objDiff := range tengo.NewSchemaDiff(from, to).ObjectDiffs() {
+		ddl, err := NewDDLStatement(objDiff, mods, target)
+		if err != nil || ddl == nil {
+			t.Fatalf("Unexpected result from NewDDLStatement: %v, %v", ddl, err)
+		}
+		ddls = append(ddls, ddl)
+		keys = append(keys, objDiff.ObjectKey())
+	}
+	if len(ddls) != 1 {
+		t.Fatalf("Expected 1 statement, instead found %d", len(ddls))
+	}
+	unchanged := func(schema *tengo.Schema) *tengo.Schema { return schema }
+	if err := verifySequence(ddls, from, to, keys, mods, unchanged, target); err != nil {
+		t.Errorf("Unexpected error from verifySequence: %s", err)
+	}
+
+	// A statement yielding a different definition should result in a report of
+	// all three definitions
+	ddls[0].stmt = "ALTER TABLE `pageviews` ADD COLUMN `referrer` varchar(99) DEFAULT NULL"
+	if err := verifySequence(ddls, from, to, keys, mods, unchanged, target); err == nil {
+		t.Error("Expected verifySequence to return an error, but it did not")
+	} else if !strings.Contains(err.Error(), "varchar(99)") || !strings.Contains(err.Error(), "varchar(100)") {
+		t.Errorf("Expected error to include actual and expected definitions, instead found %s", err)
+	}
+
+	// A statement which fails to execute should also result in an error
+	ddls[0].stmt = "ALTER TABLE `pageviews` ADD COLUMN `referrer` varchar(100) FOO BAR"
+	if err := verifySequence(ddls, from, to, keys, mods, unchanged, target); err == nil {
+		t.Error("Expected verifySequence to return an error, but it did not")
+	}
+
+	// Statements from passthrough files are not verified
+	ddls[0].stmt = "ALTER TABLE `pageviews` ADD COLUMN `referrer` varchar(100) DEFAULT NULL"
+	ddls = append(ddls, &DDLStatement{stmt: "CREATE EVENT", schemaName: "analytics", passthrough: true})
+	if err := verifySequence(ddls, from, to, keys, mods, unchanged, target); err != nil {
+		t.Errorf("Unexpected error from verifySequence: %s", err)
+	}
+}
+
+func TestSequenceMismatchError(t *testing.T) {
+	key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "posts"}
+	err := sequenceMismatchError(key, "", "CREATE TABLE `posts` (a)", "CREATE TABLE `posts` (b)")
+	for _, expected := range []string{"table `posts`", "CURRENT:\n(does not exist)\n", "EXPECTED:\nCREATE TABLE `posts` (a)\n", "WORKSPACE:\nCREATE TABLE `posts` (b)\n", "--skip-verify-sequence"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, instead found %s", expected, err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package applier
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Views are not modeled by Skeema, but may be defined in passthrough files.
+// Since passthrough files are executed in file name order, a view defined on
+// top of another view may otherwise be created before the view it references.
+// This file handles ordering passthrough view statements by their references
+// to other views. References are found by examining the view body, since
+// views defined in passthrough files do not exist yet when the order must be
+// determined. References to tables are not considered, since passthrough files
+// always run after all other DDL for the schema.
+
+const (
+	viewIdentExpr = "(?:`(?:[^`]|``)+`|[\\w$]+)"
+	viewNameExpr  = viewIdentExpr + "(?:\\s*\\.\\s*" + viewIdentExpr + ")?"
+)
+
+var (
+	reCreateView = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?(?:ALGORITHM\s*=\s*\w+\s+)?(?:DEFINER\s*=\s*(?:'[^']*'|` + "`[^`]*`" + `|[^\s@]+)(?:@(?:'[^']*'|` + "`[^`]*`" + `|\S+))?\s+)?(?:SQL\s+SECURITY\s+\w+\s+)?VIEW\s+(` + viewNameExpr + `)`)
+	reDropView   = regexp.MustCompile(`(?is)^\s*DROP\s+VIEW\s+(?:IF\s+EXISTS\s+)?(` + viewNameExpr + `(?:\s*,\s*` + viewNameExpr + `)*)`)
+	reViewName   = regexp.MustCompile(viewNameExpr)
+	reViewIdent  = regexp.MustCompile(viewIdentExpr)
+	reViewSource = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+(` + viewNameExpr + `)`)
+	reViewComma  = regexp.MustCompile(`(?i)^\s*(?:(?:AS\s+)?` + viewIdentExpr + `\s*)?,\s*(` + viewNameExpr + `)`)
+)
+
+// viewStatement describes a passthrough statement which creates or drops
+// views.
+type viewStatement struct {
+	creates    string          // name of view created, or empty string if not a CREATE VIEW
+	drops      map[string]bool // names of views dropped
+	references map[string]bool // names of views referenced by a created view's body
+}
+
+// parseViewStatement returns a viewStatement for stmt, or nil if stmt does not
+// create or drop views. Schema name qualifiers are stripped from view names.
+func parseViewStatement(stmt string) *viewStatement {
+	if m := reCreateView.FindStringSubmatchIndex(stmt); m != nil {
+		vs := &viewStatement{
+			creates:    unqualifiedViewName(stmt[m[2]:m[3]]),
+			references: make(map[string]bool),
+		}
+		body := stmt[m[1]:]
+		for _, sm := range reViewSource.FindAllStringSubmatchIndex(body, -1) {
+			vs.references[unqualifiedViewName(body[sm[2]:sm[3]])] = true
+			// Also handle comma-separated joins, e.g. "FROM a, b AS x, c"
+			for rest := body[sm[1]:]; ; {
+				cm := reViewComma.FindStringSubmatchIndex(rest)
+				if cm == nil {
+					break
+				}
+				vs.references[unqualifiedViewName(rest[cm[2]:cm[3]])] = true
+				rest = rest[cm[1]:]
+			}
+		}
+		delete(vs.references, vs.creates)
+		return vs
+	}
+	if m := reDropView.FindStringSubmatch(stmt); m != nil {
+		vs := &viewStatement{drops: make(map[string]bool)}
+		for _, name := range reViewName.FindAllString(m[1], -1) {
+			vs.drops[unqualifiedViewName(name)] = true
+		}
+		return vs
+	}
+	return nil
+}
+
+// unqualifiedViewName strips any schema name qualifier and backticks from name,
+// and lowercases it, since view names are compared case-insensitively here.
+func unqualifiedViewName(name string) string {
+	parts := reViewIdent.FindAllString(name, -1)
+	name = parts[len(parts)-1]
+	if name[0] == '`' {
+		name = strings.Replace(name[1:len(name)-1], "``", "`", -1)
+	}
+	return strings.ToLower(name)
+}
+
+// touches returns true if vs creates or drops the named view.
+func (vs *viewStatement) touches(name string) bool {
+	return vs.creates == name || vs.drops[name]
+}
+
+// orderViewStatements returns the order in which to execute statements,
+// expressed as indexes into statements. Statements which create or drop views
+// are reordered as needed: a view must be created after any views which its
+// body references, and a view must be dropped before any views which it
+// references are dropped. Statements affecting the same view otherwise retain
+// their relative order, as do all other statements. An error is returned if
+// views reference each other in a cycle.
+func orderViewStatements(statements []string) ([]int, error) {
+	parsed := make([]*viewStatement, len(statements))
+	refs := make(map[string]map[string]bool) // view name -> names of views it references
+	for n, stmt := range statements {
+		parsed[n] = parseViewStatement(stmt)
+		if vs := parsed[n]; vs != nil && vs.creates != "" {
+			if refs[vs.creates] == nil {
+				refs[vs.creates] = make(map[string]bool)
+			}
+			for name := range vs.references {
+				refs[vs.creates][name] = true
+			}
+		}
+	}
+	mustPrecede := func(a, b int) bool {
+		va, vb := parsed[a], parsed[b]
+		if va == nil || vb == nil {
+			return false
+		}
+		if va.creates != "" && vb.references[va.creates] {
+			return true
+		}
+		for dropped := range va.drops {
+			for referenced := range vb.drops {
+				if refs[dropped][referenced] {
+					return true
+				}
+			}
+		}
+		if a < b && va.creates != "" && vb.touches(va.creates) {
+			return true
+		}
+		for name := range va.drops {
+			if a < b && vb.touches(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// predecessors[n] tracks how many unplaced statements must precede
+	// statement n
+	predecessors := make([]int, len(statements))
+	for a := range statements {
+		for b := range statements {
+			if a != b && mustPrecede(a, b) {
+				predecessors[b]++
+			}
+		}
+	}
+	order := make([]int, 0, len(statements))
+	placed := make([]bool, len(statements))
+	for len(order) < len(statements) {
+		next := -1
+		for n := range statements {
+			if !placed[n] && predecessors[n] == 0 {
+				next = n
+				break
+			}
+		}
+		if next < 0 {
+			return nil, viewCycleError(parsed, placed, mustPrecede)
+		}
+		placed[next] = true
+		order = append(order, next)
+		for n := range statements {
+			if !placed[n] && mustPrecede(next, n) {
+				predecessors[n]--
+			}
+		}
+	}
+	return order, nil
+}
+
+// viewCycleError returns an error naming the views of one dependency cycle
+// among the unplaced statements.
+func viewCycleError(parsed []*viewStatement, placed []bool, mustPrecede func(a, b int) bool) error {
+	// Every unplaced statement has an unplaced predecessor, so walking
+	// predecessors from any unplaced statement must eventually revisit one
+	var current int
+	for placed[current] {
+		current++
+	}
+	visitedAt := make(map[int]int)
+	var path []int
+	for {
+		if pos, seen := visitedAt[current]; seen {
+			path = path[pos:]
+			break
+		}
+		visitedAt[current] = len(path)
+		path = append(path, current)
+		for n := range parsed {
+			if !placed[n] && n != current && mustPrecede(n, current) {
+				current = n
+				break
+			}
+		}
+	}
+	names := make([]string, 0, len(path)+1)
+	for n := len(path) - 1; n >= 0; n-- {
+		names = append(names, parsed[path[n]].String())
+	}
+	names = append(names, names[0])
+	return fmt.Errorf("Unable to order passthrough view statements, since views reference each other in a cycle: %s", strings.Join(names, " -> "))
+}
+
+// String returns a description of the statement, for use in error messages.
+func (vs *viewStatement) String() string {
+	if vs.creates != "" {
+		return fmt.Sprintf("CREATE VIEW %s", vs.creates)
+	}
+	names := make([]string, 0, len(vs.drops))
+	for name := range vs.drops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("DROP VIEW %s", strings.Join(names, ", "))
+}
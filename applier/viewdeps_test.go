@@ -0,0 +1,87 @@
+package applier
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseViewStatement(t *testing.T) {
+	vs := parseViewStatement("CREATE OR REPLACE ALGORITHM=MERGE DEFINER=`root`@`%` SQL SECURITY INVOKER VIEW `mydb`.`v3` (a, b) AS SELECT v2.a, x.b FROM `v2`, t1 AS y, `odd``name` JOIN mydb.V1 x ON v2.id = x.id WHERE y.c IN (SELECT c FROM t2)")
+	if vs == nil || vs.creates != "v3" {
+		t.Fatalf("Unexpected result from parseViewStatement: %+v", vs)
+	}
+	expected := map[string]bool{"v2": true, "v1": true, "t1": true, "odd`name": true, "t2": true}
+	if !reflect.DeepEqual(vs.references, expected) {
+		t.Errorf("Unexpected references: expected %v, found %v", expected, vs.references)
+	}
+
+	vs = parseViewStatement("drop view if exists v1, `mydb`.`v2`")
+	if vs == nil || vs.creates != "" || !reflect.DeepEqual(vs.drops, map[string]bool{"v1": true, "v2": true}) {
+		t.Errorf("Unexpected result from parseViewStatement: %+v", vs)
+	}
+
+	for _, stmt := range []string{"CREATE SEQUENCE s1", "CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO DELETE FROM v1", "DROP TABLE v1"} {
+		if vs := parseViewStatement(stmt); vs != nil {
+			t.Errorf("Expected nil from parseViewStatement(%q), instead found %+v", stmt, vs)
+		}
+	}
+}
+
+func TestOrderViewStatements(t *testing.T) {
+	assertOrder := func(statements []string, expected ...int) {
+		t.Helper()
+		order, err := orderViewStatements(statements)
+		if err != nil {
+			t.Fatalf("Unexpected error from orderViewStatements: %s", err)
+		}
+		if !reflect.DeepEqual(order, expected) {
+			t.Errorf("Expected order %v, instead found %v", expected, order)
+		}
+	}
+
+	// Views three levels deep, defined in reverse order; other statements keep
+	// their relative order
+	assertOrder([]string{
+		"CREATE VIEW v3 AS SELECT * FROM v2",
+		"CREATE SEQUENCE s1",
+		"CREATE VIEW v2 AS SELECT * FROM v1",
+		"CREATE VIEW v1 AS SELECT * FROM users",
+	}, 1, 3, 2, 0)
+
+	// Drop-and-recreate files: dependent views are dropped first, and each view
+	// is still dropped before being recreated
+	assertOrder([]string{
+		"DROP VIEW IF EXISTS v1",
+		"CREATE VIEW v1 AS SELECT * FROM users",
+		"DROP VIEW IF EXISTS v2",
+		"CREATE VIEW v2 AS SELECT * FROM v1",
+		"DROP VIEW IF EXISTS v3",
+		"CREATE VIEW v3 AS SELECT * FROM v2",
+	}, 4, 2, 0, 1, 3, 5)
+
+	// Already-correct order is unchanged
+	assertOrder([]string{
+		"CREATE VIEW v1 AS SELECT * FROM users",
+		"CREATE VIEW v2 AS SELECT * FROM v1 JOIN v1 AS other",
+	}, 0, 1)
+
+	// Cycles are an error naming each view in the cycle
+	_, err := orderViewStatements([]string{
+		"CREATE SEQUENCE s1",
+		"CREATE VIEW va AS SELECT * FROM vc",
+		"CREATE VIEW vb AS SELECT * FROM va",
+		"CREATE VIEW vc AS SELECT * FROM vb",
+	})
+	if err == nil {
+		t.Fatal("Expected error from view reference cycle, but err was nil")
+	}
+	for _, name := range []string{"va", "vb", "vc"} {
+		if !strings.Contains(err.Error(), "CREATE VIEW "+name) {
+			t.Errorf("Expected error to name view %s, instead found %s", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "s1") {
+		t.Errorf("Expected error to only include cycle members, instead found %s", err)
+	}
+}
@@ -0,0 +1,115 @@
+package applier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// wrapperConstraints describes the table features which an external online
+// schema change tool, configured via alter-wrapper or ddl-wrapper, is unable
+// to handle.
+type wrapperConstraints struct {
+	tool          string // name of the tool, for use in messages
+	noTriggers    bool   // tool refuses tables which already have triggers
+	noForeignKeys bool   // tool refuses tables which have foreign keys
+	noReferenced  bool   // tool refuses tables referenced by other tables' foreign keys
+	referencedFix string // if noReferenced is false but referencing foreign keys need special handling, explanation of that handling
+}
+
+// wrapperFlavors maps each supported value of the wrapper-flavor option to its
+// constraints. The "custom" flavor has no known constraints.
+var wrapperFlavors = map[string]wrapperConstraints{
+	"pt-osc": {
+		tool:          "pt-online-schema-change",
+		noTriggers:    true,
+		referencedFix: "requires its --alter-foreign-keys-method option",
+	},
+	"gh-ost": {
+		tool:          "gh-ost",
+		noTriggers:    true,
+		noForeignKeys: true,
+		noReferenced:  true,
+	},
+	"custom": {},
+}
+
+// wrapperTableFeatures describes the features of an existing table which are
+// relevant to wrapperConstraints.
+type wrapperTableFeatures struct {
+	triggers     int // number of triggers on the table
+	foreignKeys  int // number of foreign keys in the table
+	referencedBy int // number of foreign keys in other tables referencing the table
+}
+
+// conflicts returns a human-readable description of each way in which a table
+// with the supplied features cannot be handled by the tool.
+func (wc wrapperConstraints) conflicts(features wrapperTableFeatures) (conflicts []string) {
+	if wc.noTriggers && features.triggers > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("table has %s", countAndNoun(features.triggers, "trigger", "triggers")))
+	}
+	if wc.noForeignKeys && features.foreignKeys > 0 {
+		conflicts = append(conflicts, fmt.Sprintf("table has %s", countAndNoun(features.foreignKeys, "foreign key", "foreign keys")))
+	}
+	if features.referencedBy > 0 && (wc.noReferenced || wc.referencedFix != "") {
+		conflict := fmt.Sprintf("table is referenced by %s in other tables", countAndNoun(features.referencedBy, "foreign key", "foreign keys"))
+		if !wc.noReferenced {
+			conflict += ", which " + wc.referencedFix
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// wrapperWarning returns a warning explaining why the ALTER TABLE represented
+// by td cannot safely be executed by target's alter-wrapper or ddl-wrapper, as
+// described by the wrapper-flavor option. A blank string is returned if there
+// are no known problems, or if the flavor is "custom".
+func wrapperWarning(target *Target, td *tengo.TableDiff) (string, error) {
+	if target.Dir.Config.FindOption("wrapper-flavor") == nil {
+		return "", nil
+	}
+	flavor, err := target.Dir.Config.GetEnum("wrapper-flavor", "custom", "pt-osc", "gh-ost")
+	if err != nil {
+		return "", ConfigError(err.Error())
+	}
+	wc := wrapperFlavors[flavor]
+	if wc.tool == "" {
+		return "", nil
+	}
+	features, err := getWrapperTableFeatures(target.Instance, target.SchemaName, td.From)
+	if err != nil {
+		return "", err
+	}
+	conflicts := wc.conflicts(features)
+	if len(conflicts) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("%s may be unable to alter table %s: %s", wc.tool, tengo.EscapeIdentifier(td.From.Name), strings.Join(conflicts, "; ")), nil
+}
+
+// getWrapperTableFeatures introspects the triggers and foreign keys of the
+// supplied existing table on inst. Foreign keys referencing the table are
+// counted across all schemas.
+func getWrapperTableFeatures(inst *tengo.Instance, schemaName string, table *tengo.Table) (features wrapperTableFeatures, err error) {
+	features.foreignKeys = len(table.ForeignKeys)
+	db, err := inst.Connect("information_schema", "")
+	if err != nil {
+		return features, err
+	}
+	query := `
+		SELECT COUNT(*)
+		FROM   triggers
+		WHERE  event_object_schema = ? AND event_object_table = ?`
+	if err := db.QueryRow(query, schemaName, table.Name).Scan(&features.triggers); err != nil {
+		return features, err
+	}
+	query = `
+		SELECT COUNT(*)
+		FROM   referential_constraints
+		WHERE  unique_constraint_schema = ? AND referenced_table_name = ?
+		AND    (constraint_schema != ? OR table_name != ?)`
+	err = db.QueryRow(query, schemaName, table.Name, schemaName, table.Name).Scan(&features.referencedBy)
+	return features, err
+}
@@ -0,0 +1,59 @@
+package applier
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestWrapperConstraintsConflicts(t *testing.T) {
+	cases := []struct {
+		flavor   string
+		features wrapperTableFeatures
+		expected []string
+	}{
+		{"pt-osc", wrapperTableFeatures{}, nil},
+		{"pt-osc", wrapperTableFeatures{triggers: 2, foreignKeys: 1}, []string{"table has 2 triggers"}},
+		{"pt-osc", wrapperTableFeatures{referencedBy: 1}, []string{"table is referenced by 1 foreign key in other tables, which requires its --alter-foreign-keys-method option"}},
+		{"gh-ost", wrapperTableFeatures{triggers: 1, foreignKeys: 2, referencedBy: 3}, []string{"table has 1 trigger", "table has 2 foreign keys", "table is referenced by 3 foreign keys in other tables"}},
+		{"custom", wrapperTableFeatures{triggers: 1, foreignKeys: 1, referencedBy: 1}, nil},
+	}
+	for _, c := range cases {
+		actual := wrapperFlavors[c.flavor].conflicts(c.features)
+		if strings.Join(actual, "|") != strings.Join(c.expected, "|") {
+			t.Errorf("Unexpected conflicts for %s %+v: expected %q, found %q", c.flavor, c.features, c.expected, actual)
+		}
+	}
+}
+
+func TestWrapperWarningConfig(t *testing.T) {
+	from := brokenFKTestTable("orphans", "parents")
+	to := brokenFKTestTable("orphans", "parents")
+	to.Engine = "MyISAM"
+	td := tengo.NewAlterTable(from, to)
+
+	// The custom flavor has no constraints, so no introspection is performed
+	target := &Target{Dir: getDir(t, "testdata/simple/one", ""), SchemaName: "product"}
+	if warning, err := wrapperWarning(target, td); warning != "" || err != nil {
+		t.Errorf("Expected no warning or error with default wrapper-flavor, instead found %q, %v", warning, err)
+	}
+
+	target.Dir = getDir(t, "testdata/simple/one", "--wrapper-flavor=osc")
+	if _, err := wrapperWarning(target, td); err == nil {
+		t.Error("Expected error from invalid wrapper-flavor, but err was nil")
+	} else if _, ok := err.(ConfigError); !ok {
+		t.Errorf("Expected ConfigError from invalid wrapper-flavor, instead found %T", err)
+	}
+}
+
+func TestFormatDDLWrapperNote(t *testing.T) {
+	ddl := &DDLStatement{
+		stmt:        "ALTER TABLE `orphans` ENGINE=MyISAM",
+		wrapperNote: "gh-ost may be unable to alter table `orphans`: table has 1 trigger",
+	}
+	expected := "-- Warning: gh-ost may be unable to alter table `orphans`: table has 1 trigger\nALTER TABLE `orphans` ENGINE=MyISAM;\n"
+	if actual := formatDDL(ddl, true, false); actual != expected {
+		t.Errorf("Unexpected formatDDL output: expected %q, found %q", expected, actual)
+	}
+}
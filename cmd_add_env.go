@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -20,6 +21,10 @@ could be used to define a "staging" or "development" environment pointing at a
 different host and port, or perhaps a "local" environment pointing at localhost
 and a socket path.
 
+With --extends, the new environment inherits all options of an existing
+environment defined in the same .skeema file, aside from those set by this
+command.
+
 This command currently only handles very simple cases. For many situations,
 editing .skeema files directly is a better approach.`
 
@@ -28,6 +33,7 @@ editing .skeema files directly is a better approach.`
 	cmd.AddOption(mybase.StringOption("port", 'P', "3306", "Port to use for database host"))
 	cmd.AddOption(mybase.StringOption("socket", 'S', "/tmp/mysql.sock", "Absolute path to Unix socket file used if host is localhost"))
 	cmd.AddOption(mybase.StringOption("dir", 'd', ".", "Base dir for this host's schemas"))
+	cmd.AddOption(mybase.StringOption("extends", 0, "", "Name of an existing environment in the same .skeema file whose options the new environment inherits"))
 	cmd.AddArg("environment", "", true)
 	CommandSuite.AddSubCommand(cmd)
 }
@@ -46,6 +52,13 @@ func AddEnvHandler(cfg *mybase.Config) error {
 	if dir.OptionFile.HasSection(environment) {
 		return NewExitValue(CodeBadConfig, "Environment name \"%s\" already defined in %s", environment, dir.OptionFile.Path())
 	}
+	var extends string
+	if cfg.OnCLI("extends") {
+		extends = cfg.Get("extends")
+	}
+	if extends != "" && !dir.OptionFile.HasSection(extends) {
+		return NewExitValue(CodeBadConfig, "Cannot extend environment \"%s\", since it is not defined in %s", extends, dir.OptionFile.Path())
+	}
 	if !dir.OptionFile.SomeSectionHasOption("host") {
 		return NewExitValue(CodeBadConfig, "This command should be run against a --dir whose .skeema file already defines a host for another environment")
 	}
@@ -66,6 +79,12 @@ func AddEnvHandler(cfg *mybase.Config) error {
 		inst = instances[0]
 	}
 
+	if err := util.CheckEncryptedOptions(dir.OptionFile, dir.Config, "extends", "host", "socket", "port", "flavor", "user", "ignore-schema", "ignore-table", "connect-options"); err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	if extends != "" {
+		dir.OptionFile.SetOptionValue(environment, "extends", extends)
+	}
 	dir.OptionFile.SetOptionValue(environment, "host", inst.Host)
 	if inst.Host == "localhost" && inst.SocketPath != "" {
 		dir.OptionFile.SetOptionValue(environment, "socket", inst.SocketPath)
@@ -93,22 +112,28 @@ func AddEnvHandler(cfg *mybase.Config) error {
 }
 
 func dirForAddEnv(cfg *mybase.Config) (*fs.Dir, error) {
+	dir, err := existingDirForEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if dir.OptionFile == nil {
+		return nil, NewExitValue(CodeBadConfig, "Dir %s does not have an existing .skeema file! Can only use `skeema add-environment` on a dir previously created by `skeema init`", dir)
+	}
+	return dir, nil
+}
+
+// existingDirForEnv parses the dir specified by the dir option, which must
+// already exist. This is used by commands which add environments to existing
+// option files.
+func existingDirForEnv(cfg *mybase.Config) (*fs.Dir, error) {
 	dirPath := cfg.Get("dir")
 	fi, err := os.Stat(dirPath)
 	if err == nil && !fi.IsDir() {
 		return nil, NewExitValue(CodeBadConfig, "--dir=%s already exists but is not a directory", dirPath)
 	} else if os.IsNotExist(err) {
-		return nil, NewExitValue(CodeBadConfig, "In add-environment, --dir must refer to a directory that already exists")
+		return nil, NewExitValue(CodeBadConfig, "In %s, --dir must refer to a directory that already exists", cfg.CLI.Command.Name)
 	} else if err != nil {
 		return nil, err
 	}
-
-	dir, err := fs.ParseDir(dirPath, cfg)
-	if err != nil {
-		return nil, err
-	}
-	if dir.OptionFile == nil {
-		return nil, NewExitValue(CodeBadConfig, "Dir %s does not have an existing .skeema file! Can only use `skeema add-environment` on a dir previously created by `skeema init`", dir)
-	}
-	return dir, nil
+	return fs.ParseDir(dirPath, cfg)
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Execute the DDL in a plan file generated by `skeema plan`"
+	desc := `Executes the statements in the supplied plan file, which must have been
+previously generated by ` + "`" + `skeema plan` + "`" + `. Only the statements in the plan are
+run; the filesystem is not diff'ed again, so any *.sql changes made after the
+plan was generated have no effect.
+
+Before executing anything, the current definition of each object affected by
+the plan is compared to the fingerprint recorded when the plan was generated.
+If any of these objects have changed on the server in the meantime, no
+statements are executed at all. Changes to objects not affected by the plan do
+not prevent it from being applied.
+
+This command should be run from the same directory that ` + "`" + `skeema plan` + "`" + ` was run
+from, since .skeema files are used for obtaining connection information. The
+environment recorded in the plan file is used by default, and if an
+environment name is supplied as a CLI arg, it must match the plan's.
+
+With --tables, only statements affecting tables matching the supplied
+comma-separated list of name patterns are executed, along with any
+schema-level statements; this permits a large plan to be applied in several
+batches. Statements which a previous batch already applied are skipped,
+provided the plan was generated with ` + "`" + `skeema plan --charset-conversion` + "`" + `.`
+
+	cmd := mybase.NewCommand("apply", summary, desc, ApplyHandler)
+	cmd.AddOption(mybase.StringOption("tables", 0, "", "Only apply statements for tables matching these comma-separated name patterns"))
+	cmd.AddArg("plan", "", true)
+	cmd.AddArg("environment", "", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// ApplyHandler is the handler method for `skeema apply`
+func ApplyHandler(cfg *mybase.Config) error {
+	planPath := cfg.Get("plan")
+	plan, err := applier.ReadPlan(planPath)
+	if err != nil {
+		return NewExitValue(CodeBadInput, err.Error())
+	}
+	if environment := cfg.Get("environment"); environment != "" && environment != plan.Environment {
+		return NewExitValue(CodeBadUsage, "Environment %s does not match environment %s used to generate %s", environment, plan.Environment, planPath)
+	}
+	dirConfig := configForEnvironment(cfg, plan.Environment)
+
+	// Locate the instance for every target, and confirm that nothing affected by
+	// the plan has changed, before executing anything
+	dirs := make(map[string]*fs.Dir)
+	instances := make([]*tengo.Instance, len(plan.Targets))
+	ddlInstances := make([]*tengo.Instance, len(plan.Targets))
+	var patterns []string
+	if cfg.Changed("tables") {
+		patterns = cfg.GetSlice("tables", ',', true)
+	}
+	var problems, excluded, alreadyApplied int
+	for n, pt := range plan.Targets {
+		dir, ok := dirs[pt.Dir]
+		if !ok {
+			if dir, err = fs.ParseDir(filepath.FromSlash(pt.Dir), dirConfig); err != nil {
+				return NewExitValue(CodeBadConfig, "Unable to parse dir %s from plan: %s", pt.Dir, err)
+			}
+			dirs[pt.Dir] = dir
+		}
+		if instances[n], err = planTargetInstance(dir, pt); err != nil {
+			log.Errorf("%s %s: %s", pt.Instance, pt.SchemaName, err)
+			problems++
+			continue
+		}
+		if patterns != nil {
+			selected := pt.SelectTables(patterns)
+			excluded += len(pt.Statements) - len(selected.Statements)
+			pt = selected
+		}
+		pending, err := pt.Verify(instances[n])
+		if err != nil {
+			log.Errorf("%s %s: %s", pt.Instance, pt.SchemaName, err)
+			problems++
+			continue
+		}
+		alreadyApplied += len(pt.Statements) - len(pending.Statements)
+		plan.Targets[n] = pending
+		if ddlInstances[n], err = dir.DDLInstance(instances[n]); err != nil {
+			log.Errorf("%s %s: %s", pt.Instance, pt.SchemaName, err)
+			problems++
+		}
+	}
+	if problems > 0 {
+		return NewExitValue(CodeFatalError, "Aborting without executing %s due to %s", countAndNoun(plan.StatementCount(), "statement", "statements"), countAndNoun(problems, "problem", "problems"))
+	}
+	if excluded > 0 {
+		log.Infof("Excluding %s not matching --tables", countAndNoun(excluded, "statement", "statements"))
+	}
+	if alreadyApplied > 0 {
+		log.Infof("Skipping %s already applied previously", countAndNoun(alreadyApplied, "statement", "statements"))
+	}
+
+	var skipCount int
+	for n, pt := range plan.Targets {
+		if len(pt.Statements) == 0 {
+			continue
+		}
+		log.Infof("Applying plan to %s %s", pt.Instance, pt.SchemaName)
+		executed, err := pt.Execute(ddlInstances[n])
+		if err != nil {
+			log.Errorf("Error running DDL on %s %s: %s", pt.Instance, pt.SchemaName, err)
+			skipped := len(pt.Statements) - executed
+			skipCount += skipped
+			if skipped > 1 {
+				log.Warnf("Skipping %d remaining operations for %s %s due to previous error", skipped-1, pt.Instance, pt.SchemaName)
+			}
+			continue
+		}
+		log.Infof("%s %s: apply complete\n", pt.Instance, pt.SchemaName)
+	}
+	if skipCount > 0 {
+		return NewExitValue(CodeFatalError, "Skipped %s due to errors", countAndNoun(skipCount, "operation", "operations"))
+	}
+	return nil
+}
+
+// configForEnvironment returns a copy of cfg which uses the supplied
+// environment name, for parsing dirs with the environment recorded in a plan
+// file. cfg itself, including its parsed command-line, is not modified.
+func configForEnvironment(cfg *mybase.Config, environment string) *mybase.Config {
+	if cfg.Get("environment") == environment {
+		return cfg
+	}
+	cli := *cfg.CLI
+	cli.ArgValues = append([]string{cfg.CLI.ArgValues[0]}, environment)
+	envConfig := cfg.Clone()
+	envConfig.CLI = &cli
+	return envConfig
+}
+
+// planTargetInstance returns the instance from dir's configuration which
+// corresponds to pt.
+func planTargetInstance(dir *fs.Dir, pt *applier.PlanTarget) (*tengo.Instance, error) {
+	instances, err := dir.Instances()
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range instances {
+		if inst.String() == pt.Instance {
+			return inst, nil
+		}
+	}
+	return nil, fmt.Errorf("Instance is no longer configured in %s", dir)
+}
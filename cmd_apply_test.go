@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+func TestConfigForEnvironment(t *testing.T) {
+	cfg := mybase.ParseFakeCLI(t, CommandSuite, "skeema apply plan.json")
+	envConfig := configForEnvironment(cfg, "staging")
+	if env := envConfig.Get("environment"); env != "staging" {
+		t.Errorf("Expected environment of returned config to be staging, instead found %q", env)
+	} else if plan := envConfig.Get("plan"); plan != "plan.json" {
+		t.Errorf("Expected plan arg to be unchanged, instead found %q", plan)
+	}
+	if env := cfg.Get("environment"); env != "" {
+		t.Errorf("Expected original config to be unchanged, instead found environment %q", env)
+	} else if len(cfg.CLI.ArgValues) != 1 {
+		t.Errorf("Expected original command-line args to be unchanged, instead found %v", cfg.CLI.ArgValues)
+	}
+
+	cfg = mybase.ParseFakeCLI(t, CommandSuite, "skeema apply plan.json staging")
+	if envConfig := configForEnvironment(cfg, "staging"); envConfig != cfg {
+		t.Error("Expected config with matching environment to be returned as-is")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+)
+
+func init() {
+	summary := "Check that each table on the server has a corresponding *.sql file and vice versa"
+	desc := `Performs a lightweight, name-level reconciliation between the tables defined in
+the *.sql files of the current directory and its subdirectories, and the tables
+in the live schemas that these directories map to. For each schema, the tables
+which exist only in the filesystem, only on the server, or in both are
+reported. Table definitions are not compared, and no workspace is used, so this
+is substantially faster than ` + "`skeema diff`" + ` across a large number of shards.
+
+Tables matching the ignore-table option, as well as the tracking-table, are
+excluded. The --schemas and --hosts options may be used to narrow the set of
+audited schemas. With --format=json, the report is output as JSON for use by
+scripts.
+
+You may optionally pass an environment name as a CLI arg. This affects which
+section of .skeema config files is used. If no environment name is supplied,
+the default is "production".
+
+An exit code of 0 will be returned if every audited schema has the same set of
+table names as the filesystem, 1 if any differences were found, or 2+ if an
+error occurred.`
+
+	cmd := mybase.NewCommand("audit-names", summary, desc, AuditNamesHandler)
+	cmd.AddOption(mybase.StringOption("format", 0, "text", `Output format (valid values: "text", "json")`))
+	cmd.AddOption(mybase.StringOption("schemas", 0, "", "Only audit schemas matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("hosts", 0, "", "Only audit schemas on hosts matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("concurrent-instances", 'c', "10", "Audit this number of instances concurrently"))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// AuditNamesHandler is the handler method for `skeema audit-names`
+func AuditNamesHandler(cfg *mybase.Config) error {
+	format, err := cfg.GetEnum("format", "text", "json")
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	workerCount, err := cfg.GetInt("concurrent-instances")
+	if err == nil && workerCount < 1 {
+		err = fmt.Errorf("concurrent-instances cannot be less than 1")
+	}
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	dir, err := parseWorkingDir(cfg)
+	if err != nil {
+		return err
+	}
+
+	targets, skipCount := applier.NameTargetsForDir(dir, 5)
+	targets, _ = applier.FilterTargets(targets)
+	audits := auditNames(targets, workerCount)
+
+	var diffCount int
+	for _, audit := range audits {
+		if audit.Error != "" {
+			log.Warnf("Skipping %s %s: %s", audit.Instance, audit.Schema, audit.Error)
+			skipCount++
+		} else if !audit.Consistent() {
+			diffCount++
+		}
+	}
+	if format == "json" {
+		b, err := json.MarshalIndent(audits, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, audit := range audits {
+			printNameAudit(audit)
+		}
+	}
+
+	if diffCount > 0 {
+		return NewExitValue(CodeDifferencesFound, "Found table name differences in %s of %s audited", countAndNoun(diffCount, "schema", "schemas"), countAndNoun(len(audits), "schema", "schemas"))
+	} else if skipCount > 0 {
+		return NewExitValue(CodePartialError, "Skipped %s", countAndNoun(skipCount, "schema or directory", "schemas or directories"))
+	}
+	return nil
+}
+
+// auditNames runs applier.AuditNames for each distinct instance and schema in
+// targets, auditing up to workerCount instances concurrently. Targets of
+// different dirs sharing the same schema are audited together. The results are
+// sorted by instance and then by schema.
+func auditNames(targets []*applier.Target, workerCount int) []*applier.NameAudit {
+	byInstance := make(map[string]map[string][]*applier.Target)
+	for _, t := range targets {
+		inst := t.Instance.String()
+		if byInstance[inst] == nil {
+			byInstance[inst] = make(map[string][]*applier.Target)
+		}
+		byInstance[inst][t.SchemaName] = append(byInstance[inst][t.SchemaName], t)
+	}
+
+	var audits []*applier.NameAudit
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workerCount)
+	for _, schemas := range byInstance {
+		wg.Add(1)
+		go func(schemas map[string][]*applier.Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			for _, schemaTargets := range schemas {
+				audit := applier.AuditNames(schemaTargets)
+				mutex.Lock()
+				audits = append(audits, audit)
+				mutex.Unlock()
+			}
+		}(schemas)
+	}
+	wg.Wait()
+	sort.Slice(audits, func(i, j int) bool {
+		if audits[i].Instance != audits[j].Instance {
+			return audits[i].Instance < audits[j].Instance
+		}
+		return audits[i].Schema < audits[j].Schema
+	})
+	return audits
+}
+
+// printNameAudit outputs audit in human-readable form.
+func printNameAudit(audit *applier.NameAudit) {
+	header := fmt.Sprintf("%s %s (%s)", audit.Instance, audit.Schema, strings.Join(audit.Dirs, ", "))
+	if audit.Error != "" {
+		fmt.Printf("%s: unable to audit: %s\n", header, audit.Error)
+		return
+	} else if !audit.SchemaExists {
+		fmt.Printf("%s: schema does not exist; %s only in filesystem\n", header, countAndNoun(len(audit.FilesystemOnly), "table", "tables"))
+	} else {
+		fmt.Printf("%s: %s in both, %d only in filesystem, %d only on server\n", header, countAndNoun(len(audit.Both), "table", "tables"), len(audit.FilesystemOnly), len(audit.ServerOnly))
+	}
+	if len(audit.FilesystemOnly) > 0 {
+		fmt.Printf("  only in filesystem: %s\n", strings.Join(audit.FilesystemOnly, ", "))
+	}
+	if len(audit.ServerOnly) > 0 {
+		fmt.Printf("  only on server: %s\n", strings.Join(audit.ServerOnly, ", "))
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Copy an environment's configuration to a new environment"
+	desc := `Adds a new named environment to the .skeema files in a directory tree, based
+on the configuration of an existing environment. For example, if the "production"
+environment is already configured, ` + "`" + `skeema clone-environment production staging` + "`" + `
+will add a [staging] section to every .skeema file in the tree which has a
+[production] section, copying its options.
+
+In .skeema files where the source environment maps to a host, the new section
+points at the host supplied via --host instead, along with --port or --socket,
+and --user if supplied. Other per-directory overrides, such as schema names, are
+copied as-is.
+
+With --dry-run, the sections which would be added to each file are displayed,
+but no files are modified. With --push, ` + "`" + `skeema push` + "`" + ` is run against the new
+environment after the files are written, creating any schemas which do not yet
+exist there.
+
+Existing sections for the new environment are only replaced if --force is
+used. Note that comments and formatting of modified .skeema files are not
+preserved.`
+
+	cmd := mybase.NewCommand("clone-environment", summary, desc, CloneEnvHandler)
+	cmd.AddOption(mybase.StringOption("host", 'h', "", "Database hostname or IP address for the new environment"))
+	cmd.AddOption(mybase.StringOption("port", 'P', "3306", "Port to use for database host"))
+	cmd.AddOption(mybase.StringOption("socket", 'S', "/tmp/mysql.sock", "Absolute path to Unix socket file used if host is localhost"))
+	cmd.AddOption(mybase.StringOption("dir", 'd', ".", "Base dir of the tree of .skeema files to modify"))
+	cmd.AddOption(mybase.BoolOption("dry-run", 0, false, "Display the sections that would be added to each file, without writing anything"))
+	cmd.AddOption(mybase.BoolOption("force", 0, false, "Replace any existing sections for the new environment"))
+	cmd.AddOption(mybase.BoolOption("push", 0, false, "After writing files, run `skeema push` against the new environment"))
+	cmd.AddArg("source-environment", "", true)
+	cmd.AddArg("environment", "", true)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// clonedSection represents a new environment section to add to one option
+// file.
+type clonedSection struct {
+	file      *mybase.File
+	values    map[string]string
+	hostLevel bool // true if the new section points at the new host
+	replaces  bool // true if an existing section of the same name is replaced
+}
+
+// CloneEnvHandler is the handler method for `skeema clone-environment`
+func CloneEnvHandler(cfg *mybase.Config) error {
+	source, environment := cfg.Get("source-environment"), cfg.Get("environment")
+	for _, name := range []string{source, environment} {
+		if name == "" || strings.ContainsAny(name, "[]\n\r") {
+			return NewExitValue(CodeBadConfig, "Environment name \"%s\" is invalid", name)
+		}
+	}
+	if source == environment {
+		return NewExitValue(CodeBadConfig, "Source and new environment names must differ")
+	}
+	if !cfg.OnCLI("host") {
+		return NewExitValue(CodeBadConfig, "`skeema clone-environment` requires --host to be supplied on CLI")
+	}
+	dir, err := existingDirForEnv(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Create a tengo.Instance representing the supplied host. As with
+	// add-environment, connectivity is intentionally not tested here.
+	var inst *tengo.Instance
+	if instances, err := dir.Instances(); err != nil {
+		return err
+	} else if len(instances) == 0 {
+		return NewExitValue(CodeBadConfig, "Command line did not specify which instance to connect to")
+	} else {
+		inst = instances[0]
+	}
+
+	sections, err := cloneSections(dir, source, environment, 5)
+	if err != nil {
+		return err
+	}
+	var anyHostLevel bool
+	var conflicts []string
+	for _, cs := range sections {
+		anyHostLevel = anyHostLevel || cs.hostLevel
+		if cs.replaces {
+			conflicts = append(conflicts, cs.file.Path())
+		}
+	}
+	if len(sections) == 0 {
+		return NewExitValue(CodeBadConfig, "No .skeema files in %s define environment \"%s\"", dir, source)
+	} else if !anyHostLevel {
+		return NewExitValue(CodeBadConfig, "No .skeema files in %s define a host for environment \"%s\". This command should be run against a --dir containing host-level .skeema files.", dir, source)
+	} else if len(conflicts) > 0 && !cfg.GetBool("force") {
+		return NewExitValue(CodeBadConfig, "Environment name \"%s\" already defined in %s. Use --force to replace it.", environment, strings.Join(conflicts, ", "))
+	}
+
+	flavor := inst.Flavor()
+	for _, cs := range sections {
+		if !cs.hostLevel {
+			continue
+		}
+		for _, name := range []string{"host-wrapper", "port", "socket"} {
+			delete(cs.values, name)
+		}
+		cs.values["host"] = inst.Host
+		if inst.Host == "localhost" && inst.SocketPath != "" {
+			cs.values["socket"] = inst.SocketPath
+		} else {
+			cs.values["port"] = strconv.Itoa(inst.Port)
+		}
+		if cfg.OnCLI("user") {
+			cs.values["user"] = cfg.Get("user")
+		}
+		if flavor.Known() {
+			cs.values["flavor"] = flavor.String()
+		} else if _, ok := cs.values["flavor"]; ok {
+			log.Warnf("Unable to automatically determine database vendor or version of %s. Copying flavor from environment [%s] in %s; edit this if needed", inst, source, cs.file)
+		}
+	}
+
+	if cfg.GetBool("dry-run") {
+		for _, cs := range sections {
+			fmt.Print(cs.String(environment))
+		}
+		log.Infof("Dry run: %s would be modified", countAndNoun(len(sections), "file", "files"))
+		return nil
+	}
+	for _, cs := range sections {
+		names := make([]string, 0, len(cs.values))
+		for name := range cs.values {
+			names = append(names, name)
+		}
+		if err := util.CheckEncryptedOptions(cs.file, cfg, names...); err != nil {
+			return NewExitValue(CodeBadConfig, err.Error())
+		}
+	}
+	for _, cs := range sections {
+		if err := cs.apply(environment); err != nil {
+			return err
+		}
+		log.Infof("Added environment [%s] to %s", environment, cs.file.Path())
+	}
+
+	if !cfg.GetBool("push") {
+		return nil
+	}
+	// Pushing the entire new environment is the whole point here, so there's no
+	// need to confirm pushing to multiple schemas
+	args := []string{"skeema", "push", environment, "--all"}
+	if cfg.OnCLI("password") {
+		args = append(args, "--password="+cfg.Get("password"))
+	}
+	pushCfg, err := mybase.ParseCLI(CommandSuite, args)
+	if err != nil {
+		return err
+	}
+	util.AddGlobalConfigFiles(pushCfg)
+	if err := util.ProcessSpecialGlobalOptions(pushCfg); err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	pushRoot, err := fs.ParseDir(dir.Path, pushCfg)
+	if err != nil {
+		return err
+	}
+	log.Infof("Pushing to new environment [%s]", environment)
+	printer := applier.NewPrinter(false)
+	printer.Annotate(pushRoot.Config.GetBool("annotate"))
+	printer.UseColor(colorOutput(pushRoot))
+	sum, err := pushDir(pushRoot, printer)
+	if err != nil {
+		return err
+	}
+	return pushResultError(pushRoot, sum)
+}
+
+// cloneSections returns a clonedSection for each option file in dir and its
+// subdirs, up to maxDepth levels deep, which has a section for the source
+// environment. The new sections initially contain the same values as the
+// source sections; host-related options have not been substituted yet.
+func cloneSections(dir *fs.Dir, source, environment string, maxDepth int) ([]*clonedSection, error) {
+	if dir.ParseError != nil && dir.OptionFile == nil {
+		return nil, NewExitValue(CodeBadConfig, "Unable to parse %s: %s", dir, dir.ParseError)
+	}
+	var result []*clonedSection
+	if f := dir.OptionFile; f != nil && f.HasSection(source) {
+		cs := &clonedSection{
+			file:     f,
+			values:   make(map[string]string),
+			replaces: f.HasSection(environment),
+		}
+		chain, err := util.UseEnvironment(f, source)
+		if err != nil {
+			return nil, NewExitValue(CodeBadConfig, err.Error())
+		}
+		for _, name := range allOptionNames(CommandSuite) {
+			for _, section := range f.SectionsWithOption(name) {
+				if section == source {
+					cs.values[name], _ = f.OptionValue(name)
+				}
+				if name == "host" || name == "host-wrapper" {
+					for _, chainSection := range append(chain, "") {
+						cs.hostLevel = cs.hostLevel || section == chainSection
+					}
+				}
+			}
+		}
+		if _, err := util.UseEnvironment(f, dir.Config.Get("environment")); err != nil {
+			return nil, NewExitValue(CodeBadConfig, err.Error())
+		}
+		result = append(result, cs)
+	}
+	if maxDepth < 1 {
+		return result, nil
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		return nil, err
+	}
+	for _, subdir := range subdirs {
+		subResult, err := cloneSections(subdir, source, environment, maxDepth-1)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, subResult...)
+	}
+	return result, nil
+}
+
+// allOptionNames returns the sorted names of all options of cmd and its
+// subcommands.
+func allOptionNames(cmd *mybase.Command) []string {
+	seen := make(map[string]bool)
+	var helper func(*mybase.Command)
+	helper = func(cmd *mybase.Command) {
+		for name := range cmd.Options() {
+			seen[name] = true
+		}
+		for _, sub := range cmd.SubCommands {
+			helper(sub)
+		}
+	}
+	helper(cmd)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns a description of the section that would be added to
+// cs.file, in option file format, preceded by a comment naming the file.
+func (cs *clonedSection) String(environment string) string {
+	var b strings.Builder
+	verb := "add to"
+	if cs.replaces {
+		verb = "replace in"
+	}
+	fmt.Fprintf(&b, "# Would %s %s:\n[%s]\n", verb, cs.file.Path(), environment)
+	names := make([]string, 0, len(cs.values))
+	for name := range cs.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%s\n", name, cs.values[name])
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// apply adds the section to cs.file, removing any values previously present
+// in a section of the same name, and rewrites the file.
+func (cs *clonedSection) apply(environment string) error {
+	if cs.replaces {
+		for _, name := range allOptionNames(CommandSuite) {
+			cs.file.UnsetOptionValue(environment, name)
+		}
+	}
+	for name, value := range cs.values {
+		cs.file.SetOptionValue(environment, name, value)
+	}
+	return cs.file.Write(true)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+func TestCloneEnvHandler(t *testing.T) {
+	base := "testdata/.scratch/cloneenv"
+	hostFile := "[production]\nflavor=mysql:8.0\nhost=prod.invalid\nport=3307\nuser=produser\n\n[development]\nhost=localhost\n"
+	schemaFile := "schema=product\n\n[production]\nschema=product_prod\n"
+	fs.WriteTestFile(t, base+"/mydb/.skeema", hostFile)
+	fs.WriteTestFile(t, base+"/mydb/product/.skeema", schemaFile)
+	fs.WriteTestFile(t, base+"/mydb/other/.skeema", "schema=other\n")
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+
+	run := func(expectedCode int, commandLine string) {
+		t.Helper()
+		cfg := mybase.ParseFakeCLI(t, CommandSuite, commandLine+" --dir "+base+" --connect-options='timeout=10ms'")
+		if err := cfg.HandleCommand(); ExitCode(err) != expectedCode {
+			t.Errorf("Expected `%s` to return exit code %d, instead found %d: %v", commandLine, expectedCode, ExitCode(err), err)
+		}
+	}
+	assertFile := func(path, expected string) {
+		t.Helper()
+		if actual := fs.ReadTestFile(t, path); actual != expected {
+			t.Errorf("Unexpected contents of %s:\n%s\nExpected:\n%s", path, actual, expected)
+		}
+	}
+
+	// Invalid usage should fail without modifying any files
+	run(CodeBadConfig, "skeema clone-environment production staging")
+	run(CodeBadConfig, "skeema clone-environment --host staging.invalid production production")
+	run(CodeBadConfig, "skeema clone-environment --host staging.invalid nonexistent staging")
+	run(CodeBadConfig, "skeema clone-environment --host staging.invalid production development")
+	run(CodeSuccess, "skeema clone-environment --host staging.invalid production staging --dry-run")
+	assertFile(base+"/mydb/.skeema", hostFile)
+	assertFile(base+"/mydb/product/.skeema", schemaFile)
+
+	// Host-level file gets the new host and user, but keeps other options;
+	// schema-level overrides are copied as-is; files without the source
+	// environment are left alone
+	run(CodeSuccess, "skeema clone-environment --host staging.invalid -P 3308 -u staginguser production staging")
+	assertFile(base+"/mydb/.skeema", hostFile+"\n[staging]\nflavor=mysql:8.0\nhost=staging.invalid\nport=3308\nuser=staginguser\n")
+	assertFile(base+"/mydb/product/.skeema", schemaFile+"\n[staging]\nschema=product_prod\n")
+	assertFile(base+"/mydb/other/.skeema", "schema=other\n")
+
+	// Existing environment requires --force to replace
+	run(CodeBadConfig, "skeema clone-environment --host localhost -S /var/run/mysqld.sock production staging")
+	run(CodeSuccess, "skeema clone-environment --host localhost -S /var/run/mysqld.sock production staging --force")
+	contents := fs.ReadTestFile(t, base+"/mydb/.skeema")
+	if !strings.Contains(contents, "[staging]\nflavor=mysql:8.0\nhost=localhost\nsocket=/var/run/mysqld.sock\nuser=produser\n") {
+		t.Errorf("Unexpected contents after --force:\n%s", contents)
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Compare live schemas against each other, reporting structural outliers"
+	desc := `Compares the live definitions of all schemas that the current directory and its
+subdirectories map to, against each other rather than against the filesystem.
+This is useful for confirming that a set of shards, for example those matched
+by a schema wildcard, have not diverged structurally from one another.
+
+For each schema attribute or object which is not identical across every
+compared schema, the report lists which schemas share each distinct definition.
+Schemas whose definition differs from the baseline are reported as outliers,
+along with the DDL which would bring them in line with it. By default, the
+baseline is the most common definition of each object. With --reference, the
+named schema's definitions are used as the baseline instead.
+
+The --schemas and --hosts options may be used to narrow the set of compared
+schemas. With --format=json, the report is output as JSON for use by scripts.
+
+You may optionally pass an environment name as a CLI arg. This affects which
+section of .skeema config files is used. If no environment name is supplied,
+the default is "production".
+
+An exit code of 0 will be returned if all compared schemas are consistent, 1 if
+any outliers were found or some schemas could not be compared, or 2+ if a fatal
+error occurred.`
+
+	cmd := mybase.NewCommand("compare", summary, desc, CompareHandler)
+	cmd.AddOption(mybase.StringOption("reference", 0, "", "Use this schema's definitions as the baseline, instead of the most common definitions"))
+	cmd.AddOption(mybase.StringOption("format", 0, "text", `Output format (valid values: "text", "json")`))
+	cmd.AddOption(mybase.StringOption("schemas", 0, "", "Only compare schemas matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("hosts", 0, "", "Only compare schemas on hosts matching this comma-separated list of names or glob patterns"))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// CompareHandler is the handler method for `skeema compare`
+func CompareHandler(cfg *mybase.Config) error {
+	format, err := cfg.GetEnum("format", "text", "json")
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	dir, err := parseWorkingDir(cfg)
+	if err != nil {
+		return err
+	}
+
+	targets, skipCount := compareTargets(dir, 5)
+	targets, _ = applier.FilterTargets(targets)
+	schemas := make([]applier.ComparedSchema, 0, len(targets))
+	seen := make(map[string]bool)
+	var flavor tengo.Flavor
+	for _, t := range targets {
+		name := fmt.Sprintf("%s:%s", t.Instance, t.SchemaName)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		schema, err := t.SchemaFromInstance()
+		if err != nil {
+			log.Warnf("Skipping %s: %s", name, err)
+			skipCount++
+			continue
+		} else if schema == nil {
+			log.Warnf("Skipping %s: schema does not exist", name)
+			skipCount++
+			continue
+		}
+		if flavor == tengo.FlavorUnknown {
+			flavor = t.Instance.Flavor()
+		}
+		schemas = append(schemas, applier.ComparedSchema{Name: name, Schema: schema})
+	}
+	if len(schemas) < 2 {
+		return NewExitValue(CodeBadUsage, "Comparison requires at least 2 schemas, but only found %d", len(schemas))
+	}
+
+	names := make([]string, len(schemas))
+	for n, cs := range schemas {
+		names[n] = cs.Name
+	}
+	reference, err := resolveReference(cfg.Get("reference"), names)
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	mods := tengo.StatementModifiers{
+		AllowUnsafe: true,
+		NextAutoInc: tengo.NextAutoIncIgnore,
+		Flavor:      flavor,
+	}
+	report, err := applier.CompareSchemas(schemas, reference, mods)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		printCompareReport(report)
+	}
+
+	if !report.Consistent() {
+		return NewExitValue(CodeDifferencesFound, "Found %s among %s compared", countAndNoun(report.OutlierCount(), "outlier", "outliers"), countAndNoun(len(schemas), "schema", "schemas"))
+	} else if skipCount > 0 {
+		return NewExitValue(CodePartialError, "Skipped %s", countAndNoun(skipCount, "schema or directory", "schemas or directories"))
+	}
+	return nil
+}
+
+// compareTargets returns a Target for each instance and schema that dir and
+// its subdirectories map to, without evaluating any *.sql files. A count of
+// dirs or instances that could not be evaluated is also returned.
+func compareTargets(dir *fs.Dir, maxDepth int) (targets []*applier.Target, skipCount int) {
+	if dir.ParseError != nil {
+		log.Warnf("Skipping %s: %s", dir, dir.ParseError)
+		return nil, 1
+	}
+	if dir.HasSchema() && dir.Config.Changed("host") {
+		instances, err := dir.Instances()
+		if err != nil {
+			log.Warnf("Skipping %s: %s", dir, err)
+			skipCount++
+		}
+		for _, inst := range instances {
+			schemaNames, err := dir.SchemaNames(inst)
+			if err != nil {
+				log.Warnf("Skipping %s for %s: %s", inst, dir, err)
+				skipCount++
+				continue
+			}
+			for _, name := range schemaNames {
+				targets = append(targets, &applier.Target{Instance: inst, Dir: dir, SchemaName: name})
+			}
+		}
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		log.Warnf("Cannot list subdirs of %s: %s", dir, err)
+		return targets, skipCount + 1
+	} else if len(subdirs) > 0 && maxDepth <= 0 {
+		log.Warnf("Not walking subdirs of %s: max depth reached", dir)
+		return targets, skipCount + len(subdirs)
+	}
+	for _, sub := range subdirs {
+		subTargets, subSkipCount := compareTargets(sub, maxDepth-1)
+		targets = append(targets, subTargets...)
+		skipCount += subSkipCount
+	}
+	return targets, skipCount
+}
+
+// resolveReference returns the full name of the compared schema identified by
+// ref, which may either be a full "host:port:schema" name, or just a schema
+// name if only one compared schema has that name. A blank ref is returned
+// as-is.
+func resolveReference(ref string, names []string) (string, error) {
+	if ref == "" {
+		return "", nil
+	}
+	var matches []string
+	for _, name := range names {
+		if name == ref {
+			return name, nil
+		} else if strings.HasSuffix(name, ":"+ref) {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("Option reference=%s does not match any compared schema", ref)
+	} else if len(matches) > 1 {
+		return "", fmt.Errorf("Option reference=%s is ambiguous, matching %s; specify the full host:port:schema instead", ref, strings.Join(matches, ", "))
+	}
+	return matches[0], nil
+}
+
+// printCompareReport outputs report in human-readable form. A matrix shows
+// each differing object's definitions across all schemas, with schemas as
+// columns and each distinct definition denoted by a letter, "A" being the
+// baseline. In the matrix, "-" means the object does not exist in that schema.
+// The DDL for each outlier follows the matrix.
+func printCompareReport(report *applier.CompareReport) {
+	fmt.Printf("Compared %s: %s consistent, %s differing\n", countAndNoun(len(report.Schemas), "schema", "schemas"), countAndNoun(report.ConsistentCount, "object", "objects"), countAndNoun(len(report.Objects), "object", "objects"))
+	if report.Consistent() {
+		return
+	}
+	baseline := "most common definition"
+	if report.Reference != "" {
+		baseline = "definition in " + report.Reference
+	}
+	fmt.Printf("Baseline (A) is the %s\n\n", baseline)
+
+	fmt.Println("Schemas:")
+	for n, name := range report.Schemas {
+		fmt.Printf("  %3d  %s\n", n+1, name)
+	}
+	fmt.Println()
+
+	// Matrix header, with column numbers written vertically if necessary
+	width := 0
+	for _, obj := range report.Objects {
+		if len(obj.Object) > width {
+			width = len(obj.Object)
+		}
+	}
+	digits := len(fmt.Sprint(len(report.Schemas)))
+	for d := 0; d < digits; d++ {
+		var row strings.Builder
+		for n := range report.Schemas {
+			label := fmt.Sprintf("%*d", digits, n+1)
+			row.WriteString(" " + label[d:d+1])
+		}
+		fmt.Printf("%-*s %s\n", width, "", row.String())
+	}
+	for _, obj := range report.Objects {
+		cells := make([]string, len(report.Schemas))
+		for v, variant := range obj.Variants {
+			for _, name := range variant.Schemas {
+				for n := range report.Schemas {
+					if report.Schemas[n] == name {
+						cells[n] = variantLabel(v, variant.Definition)
+					}
+				}
+			}
+		}
+		fmt.Printf("%-*s  %s\n", width, obj.Object, strings.Join(cells, " "))
+	}
+
+	for _, obj := range report.Objects {
+		fmt.Printf("\n%s: baseline shared by %s, %s\n", obj.Object, countAndNoun(len(obj.Variants[0].Schemas), "schema", "schemas"), countAndNoun(len(obj.Outliers), "outlier", "outliers"))
+		for _, o := range obj.Outliers {
+			fmt.Printf("  %s (%s):\n", o.Schema, variantLabel(o.Variant, obj.Variants[o.Variant].Definition))
+			for _, stmt := range o.Statements {
+				fmt.Printf("    %s;\n", strings.Replace(stmt, "\n", "\n    ", -1))
+			}
+			if o.Error != "" {
+				fmt.Printf("    -- unable to generate DDL: %s\n", o.Error)
+			}
+		}
+	}
+}
+
+// variantLabel returns the matrix label for the definition variant at index
+// v. A blank definition, meaning the object does not exist, is always labeled
+// "-".
+func variantLabel(v int, definition string) string {
+	if definition == "" {
+		return "-"
+	} else if v >= 26 {
+		return "+"
+	}
+	return string(rune('A' + v))
+}
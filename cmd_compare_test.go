@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func TestResolveReference(t *testing.T) {
+	names := []string{"db1:3306:shard1", "db1:3306:shard2", "db2:3306:shard2"}
+	cases := map[string]string{
+		"":                "",
+		"shard1":          "db1:3306:shard1",
+		"db2:3306:shard2": "db2:3306:shard2",
+	}
+	for input, expected := range cases {
+		if actual, err := resolveReference(input, names); err != nil || actual != expected {
+			t.Errorf("Expected resolveReference(%q) to return %q, instead found %q, %v", input, expected, actual, err)
+		}
+	}
+	for _, input := range []string{"shard2", "shard3", "hard1"} {
+		if actual, err := resolveReference(input, names); err == nil {
+			t.Errorf("Expected resolveReference(%q) to return an error, instead found %q", input, actual)
+		}
+	}
+}
+
+func TestPrintCompareReport(t *testing.T) {
+	users := &tengo.Table{Name: "users", CreateStatement: "CREATE TABLE `users` (\n  `id` int(11) NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"}
+	schemas := make([]applier.ComparedSchema, 12)
+	for n := range schemas {
+		schemas[n] = applier.ComparedSchema{
+			Name:   "db1:3306:shard" + string(rune('a'+n)),
+			Schema: &tengo.Schema{Name: "shard", CharSet: "latin1", Collation: "latin1_swedish_ci", Tables: []*tengo.Table{users}},
+		}
+	}
+	schemas[10].Schema.Tables = nil
+	report, err := applier.CompareSchemas(schemas, "", tengo.StatementModifiers{AllowUnsafe: true})
+	if err != nil {
+		t.Fatalf("Unexpected error from CompareSchemas: %s", err)
+	}
+
+	base := "testdata/.scratch/compare"
+	fs.WriteTestFile(t, base+"/placeholder", "")
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	outPath := filepath.Join(base, "compare.out")
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		t.Fatalf("Unable to redirect stdout to a file: %s", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = outFile
+	printCompareReport(report)
+	outFile.Close()
+	os.Stdout = oldStdout
+
+	output := fs.ReadTestFile(t, outPath)
+	expected := []string{
+		"Compared 12 schemas: 2 objects consistent, 1 object differing\n",
+		"                                1 1 1\n",
+		"              1 2 3 4 5 6 7 8 9 0 1 2\n",
+		"table `users`  A A A A A A A A A A - A\n",
+		"table `users`: baseline shared by 11 schemas, 1 outlier\n",
+		"  db1:3306:shardk (-):\n    CREATE TABLE `users` (\n      `id` int(11) NOT NULL\n",
+	}
+	for _, substr := range expected {
+		if !strings.Contains(output, substr) {
+			t.Errorf("Expected output to contain %q, but it did not. Full output:\n%s", substr, output)
+		}
+	}
+}
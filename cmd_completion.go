@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+)
+
+func init() {
+	summary := "Output a shell completion script"
+	desc := `Outputs a script for enabling tab-completion of Skeema subcommands, options, and
+environment names in your shell. The shell may be supplied as an arg; valid
+values are "bash" (the default) and "zsh".
+
+For bash, add the following to your ~/.bashrc:
+    eval "$(skeema completion bash)"
+
+For zsh, add the following to your ~/.zshrc:
+    eval "$(skeema completion zsh)"
+
+Environment names are completed dynamically, by examining section names in
+the .skeema files of the current directory, its parent directories, and its
+immediate subdirectories. This never requires connecting to a database.`
+
+	cmd := mybase.NewCommand("completion", summary, desc, CompletionHandler)
+	cmd.AddOption(mybase.BoolOption("environments", 0, false, "Output environment names found in nearby .skeema files, for use by completion scripts").Hidden())
+	cmd.AddArg("shell", "bash", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// CompletionHandler is the handler method for `skeema completion`
+func CompletionHandler(cfg *mybase.Config) error {
+	if cfg.GetBool("environments") {
+		for _, name := range completionEnvironments(".") {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	shell, err := cfg.GetEnum("shell", "bash", "zsh")
+	if err != nil {
+		return NewExitValue(CodeBadUsage, err.Error())
+	}
+	suite := cfg.CLI.Command.Root()
+	if shell == "zsh" {
+		fmt.Print(zshCompletionScript(suite))
+	} else {
+		fmt.Print(bashCompletionScript(suite))
+	}
+	return nil
+}
+
+// hiddenCommands tracks subcommands intended for use by external tooling,
+// which are omitted from shell completion. Subcommands add themselves to this
+// map in their init() functions.
+var hiddenCommands = make(map[string]bool)
+
+// completionSubcommands returns a sorted list of the names of suite's
+// subcommands, excluding any hidden ones.
+func completionSubcommands(suite *mybase.Command) []string {
+	names := make([]string, 0, len(suite.SubCommands))
+	for name := range suite.SubCommands {
+		if !hiddenCommands[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionFlags returns a sorted list of long-form flags which may be used
+// on the command-line with cmd, including options inherited from cmd's parent.
+// Options which are hidden on the CLI are excluded.
+func completionFlags(cmd *mybase.Command) []string {
+	options := cmd.Options()
+	flags := make([]string, 0, len(options))
+	for name, opt := range options {
+		if opt.HiddenOnCLI {
+			continue
+		}
+		if opt.Type == mybase.OptionTypeString && opt.RequireValue {
+			flags = append(flags, "--"+name+"=")
+		} else {
+			flags = append(flags, "--"+name)
+		}
+		if opt.Type == mybase.OptionTypeBool && opt.HasNonzeroDefault() {
+			flags = append(flags, "--skip-"+name)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
+
+// completionEnvironments returns the sorted environment names defined as
+// sections in the .skeema files of dirPath, its parent dirs up to the repo
+// root, and its immediate subdirs. This is intentionally lightweight: files are
+// only scanned for section headers, and no database connections are made.
+// Sections which are not environments, such as [dir:pattern] sections, are
+// excluded.
+func completionEnvironments(dirPath string) []string {
+	var filePaths []string
+	if subdirs, err := ioutil.ReadDir(dirPath); err == nil {
+		for _, fi := range subdirs {
+			if fi.IsDir() && !fs.SkipSubdir(dirPath, fi.Name(), nil) {
+				filePaths = append(filePaths, filepath.Join(dirPath, fi.Name(), ".skeema"))
+			}
+		}
+	}
+	if abs, err := filepath.Abs(dirPath); err == nil {
+		home := filepath.Clean(os.Getenv("HOME"))
+		for {
+			filePaths = append(filePaths, filepath.Join(abs, ".skeema"))
+			if _, err := os.Stat(filepath.Join(abs, ".git")); err == nil || abs == home {
+				break
+			}
+			parent := filepath.Dir(abs)
+			if parent == abs {
+				break
+			}
+			abs = parent
+		}
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, filePath := range filePaths {
+		sections, err := fs.OptionFileSections(filePath)
+		if err != nil {
+			continue
+		}
+		for _, name := range sections {
+			if !seen[name] && isEnvironmentSection(name) {
+				names = append(names, name)
+				seen[name] = true
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isEnvironmentSection returns true if an option file section of the supplied
+// name may be selected as an environment. Currently the only sections which
+// are not environments are those applying to subdirectories by pattern.
+func isEnvironmentSection(name string) bool {
+	return !strings.HasPrefix(name, util.DirSectionPrefix)
+}
+
+// bashCompletionScript returns a bash completion script for suite.
+func bashCompletionScript(suite *mybase.Command) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s, generated by `%s completion bash`\n", suite.Name, suite.Name)
+	fmt.Fprintf(&b, "_%s_completion() {\n", suite.Name)
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("\tlocal subcommand=\"${COMP_WORDS[1]}\"\n")
+	b.WriteString("\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(completionSubcommands(suite), " "))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tcase \"$cur\" in\n")
+	b.WriteString("\t--dir=*)\n")
+	b.WriteString("\t\tCOMPREPLY=( $(compgen -d -P \"--dir=\" -- \"${cur#--dir=}\") )\n")
+	b.WriteString("\t\treturn\n\t\t;;\n")
+	b.WriteString("\t-*)\n")
+	b.WriteString("\t\tlocal flags\n")
+	b.WriteString("\t\tcase \"$subcommand\" in\n")
+	for _, name := range completionSubcommands(suite) {
+		fmt.Fprintf(&b, "\t\t%s) flags=\"%s\" ;;\n", name, strings.Join(completionFlags(suite.SubCommands[name]), " "))
+	}
+	fmt.Fprintf(&b, "\t\t*) flags=\"%s\" ;;\n", strings.Join(completionFlags(suite), " "))
+	b.WriteString("\t\tesac\n")
+	b.WriteString("\t\tCOMPREPLY=( $(compgen -W \"$flags\" -- \"$cur\") )\n")
+	b.WriteString("\t\t[[ \"${COMPREPLY[0]}\" == *= ]] && compopt -o nospace 2>/dev/null\n")
+	b.WriteString("\t\treturn\n\t\t;;\n")
+	b.WriteString("\tesac\n")
+	b.WriteString("\tcase \"$subcommand\" in\n")
+	var withEnv []string
+	for _, name := range completionSubcommands(suite) {
+		if suite.SubCommands[name].HasArg("environment") {
+			withEnv = append(withEnv, name)
+		}
+	}
+	if len(withEnv) > 0 {
+		fmt.Fprintf(&b, "\t%s)\n", strings.Join(withEnv, "|"))
+		fmt.Fprintf(&b, "\t\tCOMPREPLY=( $(compgen -W \"$(%s completion --environments 2>/dev/null)\" -- \"$cur\") )\n", suite.Name)
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\thelp)\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(completionSubcommands(suite), " "))
+	b.WriteString("\t\t;;\n")
+	b.WriteString("\tesac\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o default -F _%s_completion %s\n", suite.Name, suite.Name)
+	return b.String()
+}
+
+// zshCompletionScript returns a zsh completion script for suite. This relies
+// on zsh's bash completion compatibility layer, rather than duplicating the
+// completion logic.
+func zshCompletionScript(suite *mybase.Command) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# zsh completion for %s, generated by `%s completion zsh`\n", suite.Name, suite.Name)
+	b.WriteString("autoload -U +X compinit && compinit\n")
+	b.WriteString("autoload -U +X bashcompinit && bashcompinit\n")
+	script := bashCompletionScript(suite)
+	b.WriteString(script[strings.Index(script, "\n")+1:])
+	return b.String()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+)
+
+func TestCompletionSubcommands(t *testing.T) {
+	names := completionSubcommands(CommandSuite)
+	for _, expected := range []string{"completion", "diff", "help", "init", "push", "version"} {
+		var found bool
+		for _, name := range names {
+			found = found || (name == expected)
+		}
+		if !found {
+			t.Errorf("Expected completionSubcommands to include %s, but it did not: %v", expected, names)
+		}
+	}
+	for _, name := range names {
+		if name == "options" {
+			t.Errorf("Expected completionSubcommands to exclude hidden command %s, but it did not: %v", name, names)
+		}
+	}
+}
+
+func TestCompletionFlags(t *testing.T) {
+	flags := completionFlags(CommandSuite.SubCommands["push"])
+	expected := map[string]bool{
+		"--allow-unsafe":   true,  // command-specific bool option
+		"--skip-verify":    true,  // bool option with true default
+		"--alter-wrapper=": true,  // string option requiring value
+		"--password":       true,  // string option not requiring value
+		"--workspace=":     true,  // global option
+		"--host=":          false, // hidden option
+		"--skip-dry-run":   false, // bool option without true default
+	}
+	for flag, expectFound := range expected {
+		var found bool
+		for _, f := range flags {
+			found = found || (f == flag)
+		}
+		if found != expectFound {
+			t.Errorf("Expected presence of %s to be %t, but instead found %t", flag, expectFound, found)
+		}
+	}
+
+	// The same option registry should always be used, so hidden diff options
+	// from push should not be present
+	for _, f := range completionFlags(CommandSuite.SubCommands["diff"]) {
+		if f == "--dry-run" {
+			t.Error("Unexpectedly found hidden option --dry-run in diff flags")
+		}
+	}
+}
+
+func TestCompletionEnvironments(t *testing.T) {
+	fs.WriteTestFile(t, "testdata/.scratch/completion/.skeema", "[production]\nhost=db1\n[staging]\nhost=db2\n[dir:shard_*]\nschema=shard\n")
+	fs.WriteTestFile(t, "testdata/.scratch/completion/sub/.skeema", "schema=foo\n[development]\nhost=localhost\n[dir:archive/]\nschema=old\n")
+	fs.WriteTestFile(t, "testdata/.scratch/completion/.hidden/.skeema", "[hidden]\nhost=localhost\n")
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+
+	expected := []string{"development", "production", "staging"}
+	if actual := completionEnvironments("testdata/.scratch/completion"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected environments %v, instead found %v", expected, actual)
+	}
+	expected = []string{"development", "production", "staging"}
+	if actual := completionEnvironments("testdata/.scratch/completion/sub"); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected environments %v, instead found %v", expected, actual)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	bash := bashCompletionScript(CommandSuite)
+	if !strings.Contains(bash, "complete -o default -F _skeema_completion skeema") {
+		t.Errorf("bash completion script missing complete command:\n%s", bash)
+	}
+	if !strings.Contains(bash, "skeema completion --environments") {
+		t.Errorf("bash completion script missing dynamic environment completion:\n%s", bash)
+	}
+	zsh := zshCompletionScript(CommandSuite)
+	if !strings.Contains(zsh, "bashcompinit") || !strings.HasSuffix(zsh, bash[strings.Index(bash, "\n")+1:]) {
+		t.Errorf("zsh completion script did not contain expected contents:\n%s", zsh)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+)
+
+func init() {
+	summary := "Explain the effective configuration of a directory"
+	desc := `Displays how Skeema's configuration is resolved for a directory and
+environment, without connecting to any database. This is useful for debugging
+situations such as an unexpected host being used for a directory.
+
+The output lists the option files consulted, in order of increasing precedence,
+along with which of their sections apply to the environment. Next, the
+effective value of every option is shown, along with the file and line number
+(or command-line) which supplied it. Finally, the targets of the directory and
+its subdirectories are listed; these are the host and schema combinations that
+commands such as ` + "`skeema push`" + ` would operate on. Shell-outs in the
+host-wrapper and schema options are executed to determine targets, but schema
+wildcards and regular expressions are displayed as-is, since these require
+querying the database.
+
+With --option, only the named options are displayed, along with every source
+which sets each one, in order of decreasing precedence. This shows the full
+chain of overrides for an option.
+
+You may optionally pass an environment name as a CLI arg. This affects which
+section of .skeema config files is used. If no environment name is supplied,
+the default is "production".`
+
+	cmd := mybase.NewCommand("config", summary, desc, ConfigHandler)
+	cmd.AddOption(mybase.StringOption("dir", 'd', ".", "Directory to explain the configuration of"))
+	cmd.AddOption(mybase.StringOption("option", 0, "", "Comma-separated names of options to show the full resolution chain of"))
+	cmd.AddOption(mybase.StringOption("format", 0, "text", `Output format (valid values: "text", "json")`))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// configReport is the output of `skeema config`.
+type configReport struct {
+	Dir         string                  `json:"dir"`
+	Environment string                  `json:"environment"`
+	Files       []configFile            `json:"files"`
+	Options     []util.OptionResolution `json:"options"`
+	Targets     []configTarget          `json:"targets"`
+}
+
+// configFile describes an option file consulted by `skeema config`.
+type configFile struct {
+	Path     string   `json:"path"`
+	Sections []string `json:"sections"` // sections used, in order of decreasing precedence, excluding the top section
+}
+
+// configTarget describes the targets of a single directory and instance. If
+// the directory's schema option requires querying the instance, SchemaPattern
+// is set instead of Schemas.
+type configTarget struct {
+	Dir           string   `json:"dir"`
+	Instance      string   `json:"instance,omitempty"`
+	Schemas       []string `json:"schemas,omitempty"`
+	SchemaPattern string   `json:"schema_pattern,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// ConfigHandler is the handler method for `skeema config`
+func ConfigHandler(cfg *mybase.Config) error {
+	format, err := cfg.GetEnum("format", "text", "json")
+	if err != nil {
+		return NewExitValue(CodeBadUsage, err.Error())
+	}
+	dir, err := existingDirForEnv(cfg)
+	if dir == nil {
+		return err
+	} else if err != nil {
+		log.Warnf("Problem parsing %s: %s", dir, err)
+	}
+	report := configReport{
+		Dir:         dir.Path,
+		Environment: cfg.Get("environment"),
+	}
+
+	// Assemble the option files, in the same order as they are added as sources
+	files, problems := util.GlobalConfigFiles(cfg)
+	for _, err := range problems {
+		log.Warn(err.Error())
+	}
+	parentFiles, _, err := fs.ParentOptionFiles(dir.Path, cfg)
+	if err != nil {
+		return err
+	}
+	if dir.OptionFile != nil {
+		parentFiles = append(parentFiles, dir.OptionFile)
+	}
+	for _, f := range parentFiles {
+		included, err := util.IncludedFiles(f, cfg)
+		if err != nil {
+			return err
+		}
+		files = append(append(files, included...), f)
+	}
+	for _, f := range files {
+		report.Files = append(report.Files, configFile{
+			Path:     f.Path(),
+			Sections: util.EnvironmentSections(f, report.Environment),
+		})
+	}
+
+	// Determine which options to display
+	registry := util.OptionRegistry(cfg.CLI.Command.Root())
+	known := make(map[string]bool, len(registry))
+	for _, info := range registry {
+		known[info.Name] = true
+	}
+	onlyNames := make(map[string]bool)
+	for _, name := range cfg.GetSlice("option", ',', true) {
+		if !known[name] {
+			return NewExitValue(CodeBadUsage, "Option %s does not exist", name)
+		}
+		onlyNames[name] = true
+	}
+	for _, info := range registry {
+		if len(onlyNames) == 0 || onlyNames[info.Name] {
+			resolution := util.ResolveOption(info.Name, info.Default, cfg.CLI, files, report.Environment, dir.Path)
+			report.Options = append(report.Options, redactResolution(resolution))
+		}
+	}
+
+	report.Targets = configTargets(dir)
+
+	if format == "json" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	printConfigReport(report, cfg.Changed("option"))
+	return nil
+}
+
+// redactResolution masks password values in resolution, and describes
+// passwords obtained from the MYSQL_PWD environment variable accurately.
+func redactResolution(resolution util.OptionResolution) util.OptionResolution {
+	if resolution.Name != "password" && resolution.Name != "ddl-password" {
+		return resolution
+	}
+	settings := make([]util.OptionSetting, len(resolution.Settings))
+	for n, setting := range resolution.Settings {
+		if resolution.Name == "password" && setting.Source == "command-line" && setting.Value == os.Getenv("MYSQL_PWD") && setting.Value != "" {
+			setting.Source = "MYSQL_PWD environment variable"
+		}
+		if setting.Value != "" {
+			setting.Value = "*****"
+		}
+		settings[n] = setting
+	}
+	resolution.Settings = settings
+	resolution.Value = settings[0].Value
+	return resolution
+}
+
+// configTargets returns the targets of dir and its subdirectories, without
+// connecting to any database.
+func configTargets(dir *fs.Dir) (targets []configTarget) {
+	if dir.ParseError != nil {
+		return []configTarget{{Dir: dir.Path, Error: dir.ParseError.Error()}}
+	}
+	if dir.HasSchema() && dir.Config.Changed("host") {
+		instances, err := dir.Instances()
+		if err != nil {
+			targets = append(targets, configTarget{Dir: dir.Path, Error: err.Error()})
+		}
+		for _, inst := range instances {
+			target := configTarget{Dir: dir.Path, Instance: inst.String()}
+			if dir.SchemaNamesRequireQuery() {
+				target.SchemaPattern = dir.Config.Get("schema")
+			} else if target.Schemas, err = dir.SchemaNames(inst); err != nil {
+				target.Error = err.Error()
+			}
+			targets = append(targets, target)
+		}
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		return append(targets, configTarget{Dir: dir.Path, Error: err.Error()})
+	}
+	for _, sub := range subdirs {
+		targets = append(targets, configTargets(sub)...)
+	}
+	return targets
+}
+
+// printConfigReport displays report in text format. If fullChains is true,
+// every setting of each option is displayed, rather than just the effective
+// one.
+func printConfigReport(report configReport, fullChains bool) {
+	fmt.Printf("Directory:   %s\n", report.Dir)
+	fmt.Printf("Environment: %s\n", report.Environment)
+
+	fmt.Println("\nOption files, in order of increasing precedence:")
+	if len(report.Files) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, f := range report.Files {
+		if len(f.Sections) > 0 {
+			fmt.Printf("  %s (sections: %s)\n", f.Path, strings.Join(f.Sections, ", "))
+		} else {
+			fmt.Printf("  %s\n", f.Path)
+		}
+	}
+
+	fmt.Println("\nOptions:")
+	var width int
+	for _, resolution := range report.Options {
+		if line := resolution.Name + "=" + resolution.Value; len(line) > width && len(line) <= 40 {
+			width = len(line)
+		}
+	}
+	for _, resolution := range report.Options {
+		settings := resolution.Settings
+		if !fullChains {
+			settings = settings[:1]
+		}
+		fmt.Printf("  %-*s  # %s\n", width, resolution.Name+"="+resolution.Value, settings[0])
+		for _, setting := range settings[1:] {
+			fmt.Printf("    overridden: %s=%s  # %s\n", resolution.Name, setting.Value, setting)
+		}
+	}
+
+	fmt.Println("\nTargets:")
+	if len(report.Targets) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, target := range report.Targets {
+		switch {
+		case target.Error != "":
+			fmt.Printf("  %s: %s\n", target.Dir, target.Error)
+		case target.SchemaPattern != "":
+			fmt.Printf("  %s: %s, schemas matching %s (determined at runtime)\n", target.Dir, target.Instance, target.SchemaPattern)
+		case len(target.Schemas) == 1:
+			fmt.Printf("  %s: %s, schema %s\n", target.Dir, target.Instance, target.Schemas[0])
+		default:
+			fmt.Printf("  %s: %s, schemas %s\n", target.Dir, target.Instance, strings.Join(target.Schemas, ", "))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+func TestConfigHandler(t *testing.T) {
+	base := "testdata/.scratch/config"
+	fs.WriteTestFile(t, base+"/mydb/.skeema", "[production]\nhost=prod.invalid\nport=3307\n\n[staging]\nextends=production\nhost=staging1.invalid,staging2.invalid\n")
+	fs.WriteTestFile(t, base+"/mydb/product/.skeema", "schema=product\n")
+	fs.WriteTestFile(t, base+"/mydb/shards/.skeema", "schema=/^shard_/\n")
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+
+	run := func(expectedCode int, commandLine string) (report configReport) {
+		t.Helper()
+		outPath := filepath.Join(base, "config.out")
+		outFile, err := os.Create(outPath)
+		if err != nil {
+			t.Fatalf("Unable to redirect stdout to a file: %s", err)
+		}
+		oldStdout := os.Stdout
+		os.Stdout = outFile
+		cfg := mybase.ParseFakeCLI(t, CommandSuite, commandLine)
+		err = cfg.HandleCommand()
+		outFile.Close()
+		os.Stdout = oldStdout
+		if ExitCode(err) != expectedCode {
+			t.Fatalf("Expected `%s` to return exit code %d, instead found %d: %v", commandLine, expectedCode, ExitCode(err), err)
+		}
+		if expectedCode == CodeSuccess {
+			if err := json.Unmarshal([]byte(fs.ReadTestFile(t, outPath)), &report); err != nil {
+				t.Fatalf("Unable to unmarshal output of `%s`: %s", commandLine, err)
+			}
+		}
+		fs.RemoveTestFile(t, outPath)
+		return report
+	}
+
+	report := run(CodeSuccess, "skeema config staging --format=json --option=host,port --dir="+base+"/mydb/product")
+	if len(report.Files) != 2 || report.Files[0].Sections[0] != "staging" || report.Environment != "staging" {
+		t.Errorf("Unexpected files or environment in report: %+v", report)
+	}
+	if len(report.Options) != 2 || report.Options[0].Name != "host" || report.Options[0].Value != "staging1.invalid,staging2.invalid" {
+		t.Fatalf("Unexpected options in report: %+v", report.Options)
+	}
+	if settings := report.Options[0].Settings; len(settings) != 3 || settings[0].Line != 7 || settings[1].Section != "production" || settings[2].Source != "default" {
+		t.Errorf("Unexpected settings for host: %+v", settings)
+	}
+	if len(report.Targets) != 2 || report.Targets[1].Instance != "staging2.invalid:3307" || len(report.Targets[1].Schemas) != 1 || report.Targets[1].Schemas[0] != "product" {
+		t.Errorf("Unexpected targets in report: %+v", report.Targets)
+	}
+
+	// Targets of subdirs are included, but schema regexes are not resolved
+	report = run(CodeSuccess, "skeema config --format=json --dir="+base)
+	if len(report.Options) < 50 {
+		t.Errorf("Expected all options to be included in report, instead found %d", len(report.Options))
+	}
+	if len(report.Targets) != 2 || report.Targets[0].SchemaPattern != "" || report.Targets[1].SchemaPattern != "/^shard_/" {
+		t.Errorf("Unexpected targets in report: %+v", report.Targets)
+	}
+
+	run(CodeBadUsage, "skeema config --option=nonexistent --dir="+base)
+	run(CodeBadConfig, "skeema config --dir="+base+"/nonexistent")
+}
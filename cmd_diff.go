@@ -21,12 +21,13 @@ top of the file. If no environment name is supplied, the default is
 The ` + "`" + `skeema diff` + "`" + ` command is equivalent to ` + "`" + `skeema push --dry-run` + "`" + `.
 
 An exit code of 0 will be returned if no differences were found, 1 if some
-differences were found, or 2+ if an error occurred.`
+differences were found, or 2+ if an error occurred. The exit-code-mode option
+may be used to change this mapping.`
 
 	cmd := mybase.NewCommand("diff", summary, desc, DiffHandler)
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
-	clonePushOptionsToDiff()
+	clonePushOptions()
 }
 
 // DiffHandler is the handler method for `skeema diff`
@@ -37,42 +38,66 @@ func DiffHandler(cfg *mybase.Config) error {
 	return PushHandler(cfg)
 }
 
-// clonePushOptionsToDiff copies options from `skeema push` into `skeema diff`
-func clonePushOptionsToDiff() {
-	// Logic relies on init() having been called in both cmd_push.go AND
-	// cmd_diff.go, so we call it from both places, but only one will succeed
-	diff, ok1 := CommandSuite.SubCommands["diff"]
-	push, ok2 := CommandSuite.SubCommands["push"]
-	if !ok1 || !ok2 {
+// clonePushOptions copies options from `skeema push` into the commands which
+// are built on top of push's dry-run logic, namely `skeema diff` and
+// `skeema plan`.
+func clonePushOptions() {
+	// Logic relies on init() having been called in cmd_push.go as well as the
+	// other commands' files, so we call it from all of these places, but it only
+	// has an effect once push and the relevant command both exist
+	push, ok := CommandSuite.SubCommands["push"]
+	if !ok {
 		return
 	}
 
 	descRewrites := map[string]string{
-		"allow-unsafe":    "Permit generating ALTER or DROP operations that are potentially destructive",
-		"alter-wrapper":   "Output ALTER TABLEs as shell commands rather than just raw DDL; see manual for template vars",
-		"brief":           "Don't output DDL to STDOUT; instead output list of instances with at least one difference",
-		"safe-below-size": "Always permit generating destructive operations for tables below this size in bytes",
+		"allow-unsafe":       "Permit generating ALTER or DROP operations that are potentially destructive",
+		"allow-unsafe-codes": "Permit generating potentially destructive operations only of these unsafe codes (comma-separated)",
+		"alter-wrapper":      "Output ALTER TABLEs as shell commands rather than just raw DDL; see manual for template vars",
+		"brief":              "Don't output DDL to STDOUT; instead output list of instances with at least one difference",
+		"sample":             "Output each distinct set of differences once, listing all instances and schemas sharing it",
+		"safe-below-size":    "Always permit generating destructive operations for tables below this size in bytes",
+		"script-out":         "Also write the DDL to a SQL script per target; see manual for template vars",
 	}
-	hiddenRewrites := map[string]bool{
-		"brief":              false,
-		"dry-run":            true,
-		"foreign-key-checks": true,
+	hiddenRewrites := map[string]map[string]bool{
+		"diff": {
+			"all":                 true,
+			"max-altered-objects": true,
+			"max-statements":      true,
+			"brief":               false,
+			"sample":              false,
+			"dry-run":             true,
+			"force-conflicts":     true,
+			"foreign-key-checks":  true,
+		},
+		"plan": {
+			"all":                 true,
+			"dry-run":             true,
+			"max-altered-objects": true,
+			"max-statements":      true,
+			"script-out":          true,
+		},
 	}
-
-	diffOptions := diff.Options()
 	pushOptions := push.Options()
 
-	for name, pushOpt := range pushOptions {
-		if _, already := diffOptions[name]; already {
+	for _, cmdName := range []string{"diff", "plan"} {
+		cmd, ok := CommandSuite.SubCommands[cmdName]
+		if !ok {
 			continue
 		}
-		diffOpt := *pushOpt
-		if newDesc, ok := descRewrites[name]; ok {
-			diffOpt.Description = newDesc
-		}
-		if newHiddenStatus, ok := hiddenRewrites[name]; ok {
-			diffOpt.HiddenOnCLI = newHiddenStatus
+		cmdOptions := cmd.Options()
+		for name, pushOpt := range pushOptions {
+			if _, already := cmdOptions[name]; already {
+				continue
+			}
+			cmdOpt := *pushOpt
+			if newDesc, ok := descRewrites[name]; ok {
+				cmdOpt.Description = newDesc
+			}
+			if newHiddenStatus, ok := hiddenRewrites[cmdName][name]; ok {
+				cmdOpt.HiddenOnCLI = newHiddenStatus
+			}
+			cmd.AddOption(&cmdOpt)
 		}
-		diff.AddOption(&diffOpt)
 	}
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/workspace"
+)
+
+func init() {
+	summary := "Output a hash of each object's normalized definition"
+	desc := `Outputs a SHA-256 fingerprint of the normalized CREATE statement of each object
+defined in the *.sql files of the current directory and its subdirectories,
+along with a rolled-up fingerprint of each schema as a whole. With
+--from-server, the live schemas that these directories map to are fingerprinted
+instead. Objects are listed in sorted order. With --format=json, the output is
+JSON for use by scripts, for example as cache keys or for drift alerting.
+
+Definitions are normalized using the same logic as ` + "`skeema diff`" + `, so if a
+directory and a live schema have identical fingerprints, diffing them will not
+generate any DDL. Next AUTO_INCREMENT values are excluded from table
+fingerprints, since these change as rows are inserted. Options which only
+adjust a diff relative to the other side, such as ignore-table-options, are
+not reflected in fingerprints. The schema's own name does not affect its
+rolled-up fingerprint, so identical shards have identical fingerprints. The
+tracking-table, if configured, is excluded.
+
+The --schemas and --hosts options may be used to narrow the set of
+fingerprinted schemas.
+
+You may optionally pass an environment name as a CLI arg. This affects which
+section of .skeema config files is used. If no environment name is supplied,
+the default is "production".
+
+An exit code of 0 will be returned if every schema was fingerprinted
+successfully, 1 if some schemas or directories were skipped, or 2+ if a fatal
+error occurred.`
+
+	cmd := mybase.NewCommand("fingerprint", summary, desc, FingerprintHandler)
+	cmd.AddOption(mybase.BoolOption("from-server", 0, false, "Fingerprint live schemas instead of the filesystem"))
+	cmd.AddOption(mybase.StringOption("format", 0, "text", `Output format (valid values: "text", "json")`))
+	cmd.AddOption(mybase.StringOption("schemas", 0, "", "Only fingerprint schemas matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("hosts", 0, "", "Only fingerprint schemas on hosts matching this comma-separated list of names or glob patterns"))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// FingerprintHandler is the handler method for `skeema fingerprint`
+func FingerprintHandler(cfg *mybase.Config) error {
+	format, err := cfg.GetEnum("format", "text", "json")
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	dir, err := parseWorkingDir(cfg)
+	if err != nil {
+		return err
+	}
+	fromServer := cfg.GetBool("from-server")
+
+	var targets []*applier.Target
+	var skipCount int
+	if fromServer {
+		targets, skipCount = compareTargets(dir, 5)
+	} else {
+		targets, skipCount = applier.TargetsForDir(dir, 5)
+	}
+	targets, _ = applier.FilterTargets(targets)
+
+	// Each live schema is only fingerprinted once. In the filesystem, all targets
+	// of the same dir and logical schema share the same desired schema, so only
+	// one of them is fingerprinted.
+	results := []*applier.SchemaFingerprint{}
+	seenSchemas := make(map[string]bool)
+	seenDesired := make(map[*workspace.Schema]bool)
+	for _, t := range targets {
+		if fromServer {
+			name := fmt.Sprintf("%s:%s", t.Instance, t.SchemaName)
+			if seenSchemas[name] {
+				continue
+			}
+			seenSchemas[name] = true
+		} else {
+			if seenDesired[t.DesiredSchema] {
+				continue
+			}
+			seenDesired[t.DesiredSchema] = true
+		}
+		result, err := applier.FingerprintSchema(t, fromServer)
+		if err != nil {
+			log.Warnf("Skipping %s %s: %s", t.Instance, t.SchemaName, err)
+			skipCount++
+			continue
+		} else if result == nil {
+			log.Warnf("Skipping %s %s: schema does not exist", t.Instance, t.SchemaName)
+			skipCount++
+			continue
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Dir != results[j].Dir {
+			return results[i].Dir < results[j].Dir
+		} else if results[i].Instance != results[j].Instance {
+			return results[i].Instance < results[j].Instance
+		}
+		return results[i].Schema < results[j].Schema
+	})
+
+	if format == "json" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, result := range results {
+			printSchemaFingerprint(result)
+		}
+	}
+
+	if skipCount > 0 {
+		return NewExitValue(CodePartialError, "Skipped %s", countAndNoun(skipCount, "schema or directory", "schemas or directories"))
+	}
+	return nil
+}
+
+// printSchemaFingerprint outputs result in human-readable form: a header line
+// with the rolled-up schema fingerprint, followed by one line per object.
+func printSchemaFingerprint(result *applier.SchemaFingerprint) {
+	if result.Instance != "" {
+		fmt.Printf("%s %s %s (%s)\n", result.Fingerprint, result.Instance, result.Schema, result.Dir)
+	} else {
+		fmt.Printf("%s %s (%s)\n", result.Fingerprint, result.Schema, result.Dir)
+	}
+	for _, obj := range result.Objects {
+		fmt.Printf("  %s %s %s\n", obj.Fingerprint, obj.Type, obj.Name)
+	}
+}
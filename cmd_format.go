@@ -38,7 +38,7 @@ occurred.`
 
 // FormatHandler is the handler method for `skeema format`
 func FormatHandler(cfg *mybase.Config) error {
-	dir, err := fs.ParseDir(".", cfg)
+	dir, err := parseWorkingDir(cfg)
 	if err != nil {
 		return err
 	}
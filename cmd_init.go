@@ -38,6 +38,9 @@ section of the file.`
 	cmd.AddOption(mybase.BoolOption("include-auto-inc", 0, false, "Include starting auto-inc values in table files"))
 	cmd.AddOption(mybase.StringOption("ignore-schema", 0, "", "Ignore schemas that match regex"))
 	cmd.AddOption(mybase.StringOption("ignore-table", 0, "", "Ignore tables that match regex"))
+	cmd.AddOption(mybase.StringOption("combine-tables", 0, "", "Comma-separated glob patterns of table names to write to a single combined file"))
+	cmd.AddOption(mybase.StringOption("combine-file", 0, "lookups.sql", "Name of file used for tables matching combine-tables"))
+	cmd.AddOption(mybase.BoolOption("encode-case-collisions", 0, false, "Percent-encode uppercase letters in file and dir names which would otherwise differ only by letter case"))
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
 }
@@ -93,8 +96,18 @@ func InitHandler(cfg *mybase.Config) error {
 	}
 
 	// Iterate over the schemas. For each one, create a dir with .skeema and *.sql files
+	subdirNames := make(map[string]string)
+	if separateSchemaSubdir {
+		names := make([]string, len(schemas))
+		for n, s := range schemas {
+			names[n] = s.Name
+		}
+		if subdirNames, err = schemaSubdirNames(hostDir, names); err != nil {
+			return err
+		}
+	}
 	for _, s := range schemas {
-		if err := PopulateSchemaDir(s, hostDir, separateSchemaSubdir); err != nil {
+		if err := PopulateSchemaDir(s, hostDir, subdirNames[s.Name]); err != nil {
 			return err
 		}
 	}
@@ -140,6 +153,9 @@ func createHostDir(cfg *mybase.Config) (*fs.Dir, error) {
 func createHostOptionFile(cfg *mybase.Config, hostDir *fs.Dir, inst *tengo.Instance, schemas []*tengo.Schema) error {
 	environment := cfg.Get("environment")
 	hostOptionFile := mybase.NewFile(hostDir.Path, ".skeema")
+	if !hostDir.Config.Changed("format-version") {
+		hostOptionFile.SetOptionValue("", "format-version", strconv.Itoa(fs.FormatVersion))
+	}
 	hostOptionFile.SetOptionValue(environment, "host", inst.Host)
 	if inst.Host == "localhost" && inst.SocketPath != "" {
 		hostOptionFile.SetOptionValue(environment, "socket", inst.SocketPath)
@@ -151,7 +167,7 @@ func createHostOptionFile(cfg *mybase.Config, hostDir *fs.Dir, inst *tengo.Insta
 	} else {
 		hostOptionFile.SetOptionValue(environment, "flavor", flavor.String())
 	}
-	for _, persistOpt := range []string{"user", "ignore-schema", "ignore-table", "connect-options"} {
+	for _, persistOpt := range []string{"user", "ignore-schema", "ignore-table", "connect-options", "combine-tables", "combine-file"} {
 		if cfg.OnCLI(persistOpt) {
 			hostOptionFile.SetOptionValue(environment, persistOpt, cfg.Get(persistOpt))
 		}
@@ -198,32 +214,25 @@ func createHostOptionFile(cfg *mybase.Config, hostDir *fs.Dir, inst *tengo.Insta
 }
 
 // PopulateSchemaDir writes out *.sql files for all tables in the specified
-// schema. If makeSubdir==true, a subdir with name matching the schema name
-// will be created, and a .skeema option file will be created. Otherwise, the
-// *.sql files will be put in parentDir, and it will be the caller's
-// responsibility to ensure its .skeema option file exists and maps to the
-// correct schema name.
-func PopulateSchemaDir(s *tengo.Schema, parentDir *fs.Dir, makeSubdir bool) error {
-	// Ignore any attempt to populate a dir for the temp schema
-	if s.Name == parentDir.Config.Get("temp-schema") {
-		return nil
-	}
-
-	if ignoreSchema, err := parentDir.Config.GetRegexp("ignore-schema"); err != nil {
-		return NewExitValue(CodeBadConfig, err.Error())
-	} else if ignoreSchema != nil && ignoreSchema.MatchString(s.Name) {
-		log.Debugf("Skipping schema %s because ignore-schema='%s'", s.Name, ignoreSchema)
+// schema. If subdirName is non-empty, a subdir with that name will be created,
+// and a .skeema option file will be created. Otherwise, the *.sql files will
+// be put in parentDir, and it will be the caller's responsibility to ensure its
+// .skeema option file exists and maps to the correct schema name.
+func PopulateSchemaDir(s *tengo.Schema, parentDir *fs.Dir, subdirName string) error {
+	if ignored, err := schemaIgnored(s.Name, parentDir); err != nil {
+		return err
+	} else if ignored {
 		return nil
 	}
 
 	var dir *fs.Dir
 	var err error
-	if makeSubdir {
-		optionFile := mybase.NewFile(path.Join(parentDir.Path, s.Name), ".skeema")
+	if subdirName != "" {
+		optionFile := mybase.NewFile(path.Join(parentDir.Path, subdirName), ".skeema")
 		optionFile.SetOptionValue("", "schema", s.Name)
 		optionFile.SetOptionValue("", "default-character-set", s.CharSet)
 		optionFile.SetOptionValue("", "default-collation", s.Collation)
-		dir, err = parentDir.CreateSubdir(s.Name, optionFile)
+		dir, err = parentDir.CreateSubdir(subdirName, optionFile)
 		if err != nil {
 			return NewExitValue(CodeCantCreate, "Unable to create subdirectory for schema %s: %s", s.Name, err)
 		}
@@ -233,16 +242,97 @@ func PopulateSchemaDir(s *tengo.Schema, parentDir *fs.Dir, makeSubdir bool) erro
 	log.Infof("Populating %s", dir)
 
 	dumpOpts := dumper.Options{
-		IncludeAutoInc: dir.Config.GetBool("include-auto-inc"),
+		IncludeAutoInc:       dir.Config.GetBool("include-auto-inc"),
+		SkipDelimiters:       !dir.Config.GetBool("routine-delimiter"),
+		EncodeCaseCollisions: dir.Config.GetBool("encode-case-collisions"),
 	}
 	dumpOpts.IgnoreTable, err = dir.Config.GetRegexp("ignore-table")
 	if err != nil {
 		return NewExitValue(CodeBadConfig, err.Error())
 	}
+	if trackingTable := dir.Config.Get("tracking-table"); trackingTable != "" {
+		dumpOpts.IgnoreKeys([]tengo.ObjectKey{{Type: tengo.ObjectTypeTable, Name: trackingTable}})
+	}
+	if err := setCombineOptions(dir.Config, &dumpOpts); err != nil {
+		return err
+	}
 
 	if _, err = dumper.DumpSchema(s, dir, dumpOpts); err != nil {
+		if _, ok := err.(*dumper.FileNameCollisionError); ok {
+			return dumpError(dir, err)
+		}
 		return NewExitValue(CodeCantCreate, "Unable to write in %s: %s", dir, err)
 	}
 	os.Stderr.WriteString("\n")
 	return nil
 }
+
+// schemaIgnored returns true if init or pull should skip the named schema,
+// due to it being the temp-schema or matching ignore-schema.
+func schemaIgnored(name string, dir *fs.Dir) (bool, error) {
+	if name == dir.Config.Get("temp-schema") {
+		return true, nil
+	}
+	if ignoreSchema, err := dir.Config.GetRegexp("ignore-schema"); err != nil {
+		return false, NewExitValue(CodeBadConfig, err.Error())
+	} else if ignoreSchema != nil && ignoreSchema.MatchString(name) {
+		log.Debugf("Skipping schema %s because ignore-schema='%s'", name, ignoreSchema)
+		return true, nil
+	}
+	return false, nil
+}
+
+// schemaSubdirNames returns a map of schema name to the name of the new
+// subdirectory of parentDir to create for that schema. Ordinarily this is just
+// the schema name. However, schema names which differ only in letter case from
+// each other, or from an existing entry in parentDir, cannot safely be used as
+// directory names on case-insensitive filesystems. This is an error, unless
+// the encode-case-collisions option is enabled, in which case uppercase letters
+// in the colliding names are percent-encoded.
+func schemaSubdirNames(parentDir *fs.Dir, schemaNames []string) (map[string]string, error) {
+	var wanted []string
+	for _, name := range schemaNames {
+		if ignored, err := schemaIgnored(name, parentDir); err != nil {
+			return nil, err
+		} else if !ignored {
+			wanted = append(wanted, name)
+		}
+	}
+	entries, err := parentDir.Snapshot.ReadDir(parentDir.Path)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string][]string, len(entries))
+	for _, entry := range entries {
+		folded := strings.ToLower(entry.Name())
+		existing[folded] = append(existing[folded], entry.Name())
+	}
+	collides := func(name string, others []string) bool {
+		for _, other := range others {
+			if other != name && strings.EqualFold(other, name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	encode := parentDir.Config.GetBool("encode-case-collisions")
+	result := make(map[string]string, len(wanted))
+	var collisions []string
+	for _, name := range wanted {
+		dirName := fs.PortableDirName(name)
+		if collides(name, wanted) || collides(name, existing[strings.ToLower(name)]) {
+			if encode {
+				dirName = fs.CaseSafeDirName(name)
+			}
+			if !encode || collides(dirName, existing[strings.ToLower(dirName)]) {
+				collisions = append(collisions, name)
+			}
+		}
+		result[name] = dirName
+	}
+	if len(collisions) > 0 {
+		return nil, NewExitValue(CodeCantCreate, "Unable to create subdirectories in %s for schemas %s: directory names would differ only in letter case from each other or from existing entries, which is unsafe on case-insensitive filesystems. Use ignore-schema to skip some of these schemas, or --encode-case-collisions to percent-encode uppercase letters in the new directory names.", parentDir, strings.Join(collisions, ", "))
+	}
+	return result, nil
+}
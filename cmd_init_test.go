@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+func TestSchemaSubdirNames(t *testing.T) {
+	base := "testdata/.scratch/subdirnames"
+	fs.WriteTestFile(t, base+"/Analytics/.skeema", "schema=Analytics\n")
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+
+	getParentDir := func(commandLine string) *fs.Dir {
+		t.Helper()
+		cfg := mybase.ParseFakeCLI(t, CommandSuite, commandLine)
+		dir, err := fs.ParseDir(base, cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error from ParseDir: %s", err)
+		}
+		return dir
+	}
+
+	// No collisions: names used as-is
+	names, err := schemaSubdirNames(getParentDir("skeema pull"), []string{"product", "Product2"})
+	if err != nil || len(names) != 2 || names["product"] != "product" || names["Product2"] != "Product2" {
+		t.Errorf("Unexpected return from schemaSubdirNames: %v, %v", names, err)
+	}
+
+	// Collisions between new schemas, or with existing entries, are an error
+	// unless encoding is enabled. Ignored schemas don't count.
+	for _, input := range [][]string{{"product", "Product"}, {"analytics"}} {
+		if _, err := schemaSubdirNames(getParentDir("skeema pull"), input); ExitCode(err) != CodeCantCreate {
+			t.Errorf("Expected schemaSubdirNames(%v) to return exit code %d, instead found %v", input, CodeCantCreate, err)
+		}
+	}
+	if names, err := schemaSubdirNames(getParentDir("skeema pull --ignore-schema=^Product$"), []string{"product", "Product"}); err != nil || len(names) != 1 || names["product"] != "product" {
+		t.Errorf("Unexpected return from schemaSubdirNames with ignore-schema: %v, %v", names, err)
+	}
+	names, err = schemaSubdirNames(getParentDir("skeema pull --encode-case-collisions"), []string{"product", "Product", "other"})
+	if err != nil || names["product"] != "product" || names["Product"] != "%50roduct" || names["other"] != "other" {
+		t.Errorf("Unexpected return from schemaSubdirNames with encoding: %v, %v", names, err)
+	}
+
+	// Encoding can't help if the colliding new name has no uppercase letters
+	if _, err := schemaSubdirNames(getParentDir("skeema pull --encode-case-collisions"), []string{"analytics"}); ExitCode(err) != CodeCantCreate {
+		t.Errorf("Expected exit code %d, instead found %v", CodeCantCreate, err)
+	}
+}
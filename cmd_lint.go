@@ -16,9 +16,11 @@ func init() {
 	summary := "Check for problems in filesystem representation of database objects"
 	desc := `Checks for problems in filesystem representation of database objects. A set of
 linter rules are run against all objects. Each rule may be configured to
-generate an error, a warning, or be ignored entirely. Statements that contain
-invalid SQL, or otherwise return an error from the database, are always flagged
-as linter errors.
+generate an error, a warning, or be ignored entirely, using its lint-* option;
+"warn" and "off" are accepted as shorthand for "warning" and "ignore". Each
+problem found is reported with its file, line number, and the name of the
+option controlling the rule. Statements that contain invalid SQL, or otherwise
+return an error from the database, are always flagged as linter errors.
 
 By default, this command also reformats statements to their canonical form,
 just like ` + "`skeema format`" + `.
@@ -40,18 +42,27 @@ some files were reformatted; or 2+ if any errors were emitted for any reason.`
 	cmd := mybase.NewCommand("lint", summary, desc, LintHandler)
 	linter.AddCommandOptions(cmd)
 	cmd.AddOption(mybase.BoolOption("format", 0, true, "Reformat SQL statements to match canonical SHOW CREATE"))
+	cmd.AddOption(mybase.StringOption("summary-file", 0, "", "Write a JSON summary of the run to this file, for consumption by CI systems"))
+	cmd.AddOption(mybase.StringOption("since", 0, "", "Only lint objects whose *.sql files changed since this git revision"))
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
 }
 
 // LintHandler is the handler method for `skeema lint`
-func LintHandler(cfg *mybase.Config) error {
-	dir, err := fs.ParseDir(".", cfg)
+func LintHandler(cfg *mybase.Config) (err error) {
+	summary := startSummary(cfg)
+	defer func() { summary.finish(err) }()
+
+	dir, err := parseWorkingDir(cfg)
 	if err != nil {
 		return err
 	}
 
-	result := lintWalker(dir, 5)
+	changes, err := gitChangesSince(dir)
+	if err != nil {
+		return err
+	}
+	result := lintWalker(dir, 5, summary, changes)
 	switch {
 	case len(result.Exceptions) > 0:
 		exitCode := CodeFatalError
@@ -82,26 +93,40 @@ func LintHandler(cfg *mybase.Config) error {
 	return nil
 }
 
-func lintWalker(dir *fs.Dir, maxDepth int) *linter.Result {
+// lintWalker lints dir and its subdirs, up to maxDepth levels deep. If
+// changes is non-nil, only objects affected by changes are linted, and dirs
+// without any affected objects are skipped entirely.
+func lintWalker(dir *fs.Dir, maxDepth int, summary *runSummary, changes *fs.GitChanges) *linter.Result {
 	if dir.ParseError != nil {
 		log.Error(fmt.Sprintf("Skipping directory %s due to error: %s", dir.RelPath(), dir.ParseError))
-		return linter.BadConfigResult(dir, dir.ParseError)
-	}
-	log.Infof("Linting %s", dir)
-	result := lintDir(dir)
-	for _, err := range result.Exceptions {
-		log.Error(fmt.Sprintf("Skipping directory %s due to error: %s", dir.RelPath(), err))
-	}
-	for _, annotation := range result.Annotations {
-		annotation.Log()
-	}
-	for _, dl := range result.DebugLogs {
-		log.Debug(dl)
+		result := linter.BadConfigResult(dir, dir.ParseError)
+		summary.addLintResult(dir, result)
+		return result
 	}
+	var result *linter.Result
+	if onlyKeys, narrowed := changes.ObjectKeys(dir.Path); narrowed && len(onlyKeys) == 0 {
+		log.Debugf("Skipping %s: no changes since %s", dir, changes.Ref)
+		result = &linter.Result{}
+	} else {
+		log.Infof("Linting %s", dir)
+		result = lintDir(dir, onlyKeys)
+		for _, err := range result.Exceptions {
+			log.Error(fmt.Sprintf("Skipping directory %s due to error: %s", dir.RelPath(), err))
+		}
+		for _, annotation := range result.Annotations {
+			annotation.Log()
+		}
+		for _, dl := range result.DebugLogs {
+			log.Debug(dl)
+		}
+		if len(dir.LogicalSchemas) > 0 || len(result.Exceptions) > 0 {
+			summary.addLintResult(dir, result)
+		}
 
-	// Don't recurse into subdirs if there was something fatally wrong
-	if len(result.Exceptions) > 0 {
-		return result
+		// Don't recurse into subdirs if there was something fatally wrong
+		if len(result.Exceptions) > 0 {
+			return result
+		}
 	}
 
 	var subdirErr error
@@ -111,7 +136,7 @@ func lintWalker(dir *fs.Dir, maxDepth int) *linter.Result {
 		subdirErr = fmt.Errorf("Not walking subdirs of %s: max depth reached", dir)
 	} else {
 		for _, sub := range subdirs {
-			result.Merge(lintWalker(sub, maxDepth-1))
+			result.Merge(lintWalker(sub, maxDepth-1, summary, changes))
 		}
 	}
 	if subdirErr != nil {
@@ -123,12 +148,16 @@ func lintWalker(dir *fs.Dir, maxDepth int) *linter.Result {
 
 // lintDir lints all logical schemas in dir, optionally also reformatting
 // SQL statements along the way. A combined result for the directory is
-// returned. This function does not recurse into subdirs.
-func lintDir(dir *fs.Dir) *linter.Result {
+// returned. If onlyKeys is non-nil, only those objects are linted and
+// reformatted. This function does not recurse into subdirs.
+func lintDir(dir *fs.Dir, onlyKeys []tengo.ObjectKey) *linter.Result {
 	opts, err := linter.OptionsForDir(dir)
 	if err != nil && len(dir.LogicalSchemas) > 0 {
 		return linter.BadConfigResult(dir, err)
 	}
+	if onlyKeys != nil {
+		opts.OnlyKeys(onlyKeys)
+	}
 
 	// Get workspace options for dir. This involves connecting to the first
 	// defined instance, unless configured to use local Docker.
@@ -163,6 +192,9 @@ func lintDir(dir *fs.Dir) *linter.Result {
 				IncludeAutoInc: true,
 				IgnoreTable:    opts.IgnoreTable,
 			}
+			if onlyKeys != nil {
+				dumpOpts.OnlyKeys(onlyKeys)
+			}
 			dumpOpts.IgnoreKeys(wsSchema.FailedKeys())
 			result.ReformatCount, err = dumper.DumpSchema(wsSchema.Schema, dir, dumpOpts)
 			if err != nil {
@@ -187,6 +219,23 @@ func lintDir(dir *fs.Dir) *linter.Result {
 		}
 	}
 
+	// Passthrough files are never parsed, but they must at least be tokenizable
+	// in order for push --include-passthrough to execute them. Likewise, grants
+	// files must only contain GRANT statements. Problems are counted as errors,
+	// but don't prevent linting of other dirs.
+	for _, sf := range dir.PassthroughFiles {
+		if _, err := fs.PassthroughStatements(sf); err != nil {
+			log.Error(err)
+			result.ErrorCount++
+		}
+	}
+	if dir.GrantsFile != nil {
+		if _, err := fs.GrantStatements(*dir.GrantsFile); err != nil {
+			log.Error(err)
+			result.ErrorCount++
+		}
+	}
+
 	// Make sure the problem messages have a deterministic order.
 	result.SortByFile()
 	return result
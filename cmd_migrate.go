@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+func init() {
+	summary := "Update .skeema files to the current format version"
+	desc := `Rewrites .skeema files in the current directory and its subdirectories to use
+the current version of the option file format. This handles any options which
+have been renamed or moved between sections since the repo was created. The
+format-version option in the current directory's .skeema file is then updated
+to reflect the current format version.
+
+Comments and formatting in the .skeema files are preserved. This command should
+be run from the top-level directory of your schema repo, which must already
+contain a .skeema file.`
+
+	cmd := mybase.NewCommand("migrate", summary, desc, MigrateHandler)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// MigrateHandler is the handler method for `skeema migrate`
+func MigrateHandler(cfg *mybase.Config) error {
+	rootFilePath, err := filepath.Abs(".skeema")
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(rootFilePath); os.IsNotExist(err) {
+		return NewExitValue(CodeNoInput, "No .skeema file found in current directory. This command should be run from the top-level directory of your schema repo.")
+	} else if err != nil {
+		return NewExitValue(CodeNoInput, err.Error())
+	}
+	fromVersion, err := fs.ReadFormatVersion(rootFilePath)
+	if err != nil {
+		return NewExitValue(CodeBadConfig, "%s: %s", rootFilePath, err)
+	} else if fromVersion > fs.FormatVersion {
+		return NewExitValue(CodeBadConfig, "%s uses .skeema format version %d, but this version of Skeema only supports format version %d. Please upgrade Skeema.", rootFilePath, fromVersion, fs.FormatVersion)
+	}
+	migrations := fs.PendingMigrations(fromVersion)
+
+	var updated int
+	err = filepath.Walk(filepath.Dir(rootFilePath), func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if filePath != filepath.Dir(rootFilePath) && fs.SkipSubdir(filepath.Dir(filePath), info.Name(), nil) {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if info.Name() != ".skeema" || !info.Mode().IsRegular() {
+			return nil
+		}
+		var formatVersion int
+		if filePath == rootFilePath {
+			formatVersion = fs.FormatVersion
+		}
+		changed, err := fs.MigrateOptionFile(filePath, migrations, formatVersion)
+		if err != nil {
+			return err
+		} else if changed {
+			log.Infof("Updated %s", filePath)
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return NewExitValue(CodeCantCreate, err.Error())
+	}
+	if updated == 0 {
+		log.Infof("All .skeema files already use format version %d", fs.FormatVersion)
+	} else {
+		log.Infof("Migrated %s to format version %d", countAndNoun(updated, "file", "files"), fs.FormatVersion)
+	}
+	return nil
+}
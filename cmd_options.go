@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/util"
+)
+
+func init() {
+	summary := "Output information about all options, for use by tooling"
+	desc := `Outputs the name, type, default value, and placement restrictions of every
+option understood by Skeema, along with the commands that accept each option.
+This is intended for use by external tools, such as documentation generators
+or policy checkers. Output is sorted by option name, so that it may be compared
+meaningfully between releases.
+
+The placement of each option is one of "any" (command-line, global option
+files, or .skeema files), "global" (command-line or global option files),
+"cli" (command-line only), "skeema-file" (.skeema files only, aside from
+specific commands such as init which accept it on the command-line), or
+"section" (environment sections of global option files or .skeema files only,
+aside from specific commands such as add-environment).
+
+This command is not intended for interactive use, and is omitted from shell
+completion.`
+
+	cmd := mybase.NewCommand("options", summary, desc, OptionsHandler)
+	cmd.AddOption(mybase.StringOption("format", 0, "text", `Output format (valid values: "text", "json")`))
+	CommandSuite.AddSubCommand(cmd)
+	hiddenCommands["options"] = true
+}
+
+// OptionsHandler is the handler method for `skeema options`
+func OptionsHandler(cfg *mybase.Config) error {
+	format, err := cfg.GetEnum("format", "text", "json")
+	if err != nil {
+		return NewExitValue(CodeBadUsage, err.Error())
+	}
+	registry := util.OptionRegistry(cfg.CLI.Command.Root())
+	if format == "json" {
+		b, err := json.MarshalIndent(registry, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	for _, info := range registry {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", info.Name, info.Type, info.Default, info.Placement, strings.Join(info.Commands, ","))
+	}
+	return nil
+}
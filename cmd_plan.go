@@ -0,0 +1,103 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+)
+
+func init() {
+	summary := "Save the DDL that push would run to a plan file"
+	desc := `Compares the schemas on database instance(s) to the corresponding filesystem
+representation of them, in the same manner as ` + "`" + `skeema diff` + "`" + `, and writes the
+resulting DDL to a machine-readable JSON plan file. The plan file can then be
+reviewed and subsequently executed using ` + "`" + `skeema apply` + "`" + `.
+
+The plan file records the target instances and schemas, the ordered DDL for
+each, whether each statement is potentially destructive, and a fingerprint of
+the current definition of each affected object. Prior to execution,
+` + "`" + `skeema apply` + "`" + ` compares these fingerprints to the live definitions, and aborts
+if any affected object has changed since the plan was generated.
+
+With --charset-conversion, the plan is tailored for converting a large number
+of tables to a new character set or collation, such as utf8 to utf8mb4. ALTER
+TABLEs which only change character sets or collations are flagged in the plan
+along with each table's current size, and statements are ordered so that
+schema-level default character set changes come first and the largest tables
+come last. Conversions which would exceed index key length or row size limits
+are reported as errors. The resulting plan may be applied in several batches
+using ` + "`" + `skeema apply --tables` + "`" + `; statements already applied by a previous batch
+are skipped.
+
+Plans cannot include operations which would be executed using alter-wrapper or
+ddl-wrapper. If any errors occur while generating the plan, no plan file is
+written.
+
+You may optionally pass an environment name as a CLI option. This will affect
+which section of .skeema config files is used for processing. If no
+environment name is supplied, the default is "production".
+
+An exit code of 0 will be returned if the plan was written and no differences
+were found, 1 if the plan was written and contains some differences, or 2+ if
+an error occurred. The exit-code-mode option may be used to change this mapping.`
+
+	cmd := mybase.NewCommand("plan", summary, desc, PlanHandler)
+	cmd.AddOption(mybase.StringOption("out", 0, "plan.json", "Path of the plan file to write"))
+	cmd.AddOption(mybase.BoolOption("charset-conversion", 0, false, "Order and annotate the plan for a multi-step character set conversion"))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+	clonePushOptions()
+}
+
+// PlanHandler is the handler method for `skeema plan`
+func PlanHandler(cfg *mybase.Config) error {
+	// Plans are generated using push's dry-run logic
+	cfg.CLI.OptionValues["dry-run"] = "1"
+	cfg.CLI.OptionValues["brief"] = "0"
+	cfg.CLI.OptionValues["script-out"] = ""
+	cfg.MarkDirty()
+	if _, err := cfg.GetEnum("exit-code-mode", "strict", "zero", "drift-only"); err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+
+	dir, err := parseWorkingDir(cfg)
+	if err != nil {
+		return err
+	}
+	plan := applier.NewPlan(dir)
+	plan.CharsetConversion = dir.Config.GetBool("charset-conversion")
+	printer := applier.NewPrinter(false)
+	printer.Annotate(dir.Config.GetBool("annotate"))
+	printer.UseColor(colorOutput(dir))
+	printer.RecordPlan(plan)
+	sum, err := pushDir(dir, printer)
+	if err != nil {
+		return err
+	}
+	if sum.SkipCount+unsupportedCount(dir, sum)+sum.TimeoutCount > 0 {
+		return NewExitValue(CodeFatalError, "%s; plan file not written", sum.Summary())
+	}
+
+	outPath := dir.Config.Get("out")
+	if err := plan.Write(outPath); err != nil {
+		return NewExitValue(CodeCantCreate, err.Error())
+	}
+	log.Infof("Wrote plan containing %s to %s", countAndNoun(plan.StatementCount(), "statement", "statements"), outPath)
+	if plan.CharsetConversion {
+		var conversions int
+		var totalSize int64
+		for _, pt := range plan.Targets {
+			for _, stmt := range pt.Statements {
+				if stmt.Conversion {
+					conversions++
+					totalSize += stmt.TableSize
+				}
+			}
+		}
+		log.Infof("Plan converts %s, totaling %d bytes", countAndNoun(conversions, "table", "tables"), totalSize)
+	}
+	if sum.Differences {
+		return NewExitValue(CodeDifferencesFound, "")
+	}
+	return nil
+}
@@ -4,12 +4,16 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
 	"github.com/skeema/skeema/dumper"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
 )
@@ -21,6 +25,10 @@ instance. Use this command when changes have been applied to the database
 without using skeema, and the filesystem representation needs to be updated to
 reflect those changes.
 
+If a schema no longer exists, its directory is deleted, unless the directory
+contains files other than *.sql and .skeema files. In that case, the directory
+is skipped with a warning listing the unexpected files.
+
 You may optionally pass an environment name as a CLI option. This will affect
 which section of .skeema config files is used for processing. For example,
 running ` + "`" + `skeema pull staging` + "`" + ` will apply config directives from the
@@ -33,20 +41,27 @@ top of the file. If no environment name is supplied, the default is
 	cmd.AddOption(mybase.BoolOption("format", 0, true, "Reformat SQL statements to match canonical SHOW CREATE"))
 	cmd.AddOption(mybase.BoolOption("normalize", 0, true, "(deprecated alias for format)").Hidden())
 	cmd.AddOption(mybase.BoolOption("new-schemas", 0, true, "Detect any new schemas and populate new dirs for them"))
+	cmd.AddOption(mybase.StringOption("combine-tables", 0, "", "Comma-separated glob patterns of table names to write to a single combined file when new"))
+	cmd.AddOption(mybase.StringOption("combine-file", 0, "lookups.sql", "Name of file used for new tables matching combine-tables"))
+	cmd.AddOption(mybase.BoolOption("encode-case-collisions", 0, false, "Percent-encode uppercase letters in new file and dir names which would otherwise differ only by letter case"))
+	cmd.AddOption(mybase.StringOption("summary-file", 0, "", "Write a JSON summary of the run to this file, for consumption by CI systems"))
 	cmd.AddOption(mybase.StringOption("partitioning", 0, "keep", "(slight pull impact of having partitioning=remove in .skeema file for diff/push)").Hidden())
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
 }
 
 // PullHandler is the handler method for `skeema pull`
-func PullHandler(cfg *mybase.Config) error {
-	dir, err := fs.ParseDir(".", cfg)
+func PullHandler(cfg *mybase.Config) (err error) {
+	summary := startSummary(cfg)
+	defer func() { summary.finish(err) }()
+
+	dir, err := parseWorkingDir(cfg)
 	if err != nil {
 		return err
 	}
 
 	var skipCount int
-	if skipCount, err = pullWalker(dir, 5); err != nil {
+	if skipCount, err = pullWalker(dir, 5, summary); err != nil {
 		return err
 	}
 	if skipCount == 0 {
@@ -62,8 +77,8 @@ func PullHandler(cfg *mybase.Config) error {
 // pullWalker processes dir, and recursively calls itself on any subdirs. An
 // error is only returned if something fatal occurs. skipCount reflects the
 // number of non-fatal failed operations that were skipped for dir and its
-// subdirectories.
-func pullWalker(dir *fs.Dir, maxDepth int) (skipCount int, err error) {
+// subdirectories. The outcome of each schema dir is recorded in summary.
+func pullWalker(dir *fs.Dir, maxDepth int, summary *runSummary) (skipCount int, err error) {
 	var instance *tengo.Instance
 	if dir.Config.Changed("host") {
 		instance, err = dir.FirstInstance()
@@ -76,7 +91,10 @@ func pullWalker(dir *fs.Dir, maxDepth int) (skipCount int, err error) {
 	// "flat" dir defining both host and schema
 	if instance != nil && dir.HasSchema() {
 		updateFlavor(dir, instance)
-		_, err = pullSchemaDir(dir, instance)
+		if _, err = pullSchemaDir(dir, instance, summary); isUnexpectedFiles(err) {
+			log.Warnf("Skipping %s: %s", dir, err)
+			return skipCount + 1, nil
+		}
 		return skipCount, err
 	}
 
@@ -101,7 +119,7 @@ func pullWalker(dir *fs.Dir, maxDepth int) (skipCount int, err error) {
 
 		// If dir does not define host, simply recurse into subdirs.
 		if instance == nil {
-			subSkipCount, subErr := pullWalker(sub, maxDepth-1)
+			subSkipCount, subErr := pullWalker(sub, maxDepth-1, summary)
 			skipCount += subSkipCount
 			if subErr != nil {
 				return skipCount, subErr
@@ -112,8 +130,12 @@ func pullWalker(dir *fs.Dir, maxDepth int) (skipCount int, err error) {
 		// Otherwise, dir defines host but not schema. Treat subdirs as schema dirs,
 		// and use the combined list of handled schemas to figure out whether any
 		// new schema dirs need to be created (if requested).
-		subSchemaNames, subErr := pullSchemaDir(sub, instance)
-		if subErr != nil {
+		subSchemaNames, subErr := pullSchemaDir(sub, instance, summary)
+		if isUnexpectedFiles(subErr) {
+			log.Warnf("Skipping %s: %s", sub, subErr)
+			skipCount++
+			continue
+		} else if subErr != nil {
 			return skipCount, subErr
 		}
 		allSchemaNames = append(allSchemaNames, subSchemaNames...)
@@ -128,12 +150,20 @@ func pullWalker(dir *fs.Dir, maxDepth int) (skipCount int, err error) {
 	return skipCount, err
 }
 
+// isUnexpectedFiles returns true if err indicates that a schema dir could not
+// be deleted, due to containing files not managed by Skeema. This is not
+// considered a fatal error.
+func isUnexpectedFiles(err error) bool {
+	_, ok := err.(fs.UnexpectedFilesError)
+	return ok
+}
+
 // pullSchemaDir updates all logical schemas in dir to reflect the actual
 // definitions found in instance. A slice of handled schema names is returned,
 // along with any error encountered.
-func pullSchemaDir(dir *fs.Dir, instance *tengo.Instance) (schemaNames []string, err error) {
+func pullSchemaDir(dir *fs.Dir, instance *tengo.Instance, summary *runSummary) (schemaNames []string, err error) {
 	for _, logicalSchema := range dir.LogicalSchemas {
-		names, err := pullLogicalSchema(dir, instance, logicalSchema)
+		names, err := pullLogicalSchema(dir, instance, logicalSchema, summary)
 		if err != nil {
 			return nil, err
 		}
@@ -144,8 +174,8 @@ func pullSchemaDir(dir *fs.Dir, instance *tengo.Instance) (schemaNames []string,
 
 // pullLogicalSchema performs appropriate pull logic on a dir that maps to one or
 // more schemas. A slice of handled schema names is returned, along with any
-// error encountered.
-func pullLogicalSchema(dir *fs.Dir, instance *tengo.Instance, logicalSchema *fs.LogicalSchema) (schemaNames []string, err error) {
+// error encountered. The outcome is recorded in summary.
+func pullLogicalSchema(dir *fs.Dir, instance *tengo.Instance, logicalSchema *fs.LogicalSchema, summary *runSummary) (schemaNames []string, err error) {
 	if logicalSchema.Name != "" {
 		// TODO: support pull for case where multiple explicitly-named schemas per
 		// dir. For example, ability to convert a multi-schema single-file mysqldump
@@ -160,10 +190,27 @@ func pullLogicalSchema(dir *fs.Dir, instance *tengo.Instance, logicalSchema *fs.
 		log.Warnf("Ignoring directory %s -- did not map to any schema names for environment \"%s\"\n", dir, dir.Config.Get("environment"))
 		return
 	}
+	tr := applier.TargetResult{
+		Dir:      dir.RelPath(),
+		Instance: instance.String(),
+		Schema:   schemaNames[0],
+	}
+	defer func() {
+		if isUnexpectedFiles(err) {
+			tr.Status, tr.Error = "skipped", err.Error()
+		} else if err != nil {
+			tr.Status, tr.Error = "error", err.Error()
+		}
+		summary.addTarget(tr)
+	}()
 	instSchema, err := instance.Schema(schemaNames[0])
 	if err == sql.ErrNoRows {
+		if err := dir.Delete(); err != nil {
+			return nil, err
+		}
 		log.Infof("Deleted directory %s -- schema %s no longer exists\n", dir, schemaNames[0])
-		return nil, dir.Delete()
+		tr.Status = "deleted"
+		return nil, nil
 	} else if err != nil {
 		return nil, fmt.Errorf("%s: Unable to fetch schema %s from %s: %s", dir, schemaNames[0], instance, err)
 	}
@@ -171,26 +218,82 @@ func pullLogicalSchema(dir *fs.Dir, instance *tengo.Instance, logicalSchema *fs.
 	log.Infof("Updating %s to reflect %s %s", dir, instance, instSchema.Name)
 
 	// Handle changes in schema's default character set and/or collation by
-	// persisting changes to the dir's option file.
+	// persisting changes to the dir's option file. With environment-overrides,
+	// these are written to the environment's section instead of the sectionless
+	// portion shared by all environments.
+	overridesPath := dir.OverridesPath()
 	if dir.Config.Get("default-character-set") != instSchema.CharSet || dir.Config.Get("default-collation") != instSchema.Collation {
-		dir.OptionFile.SetOptionValue("", "default-character-set", instSchema.CharSet)
-		dir.OptionFile.SetOptionValue("", "default-collation", instSchema.Collation)
+		var section string
+		if overridesPath != "" {
+			section = dir.Config.Get("environment")
+		}
+		if err := util.CheckEncryptedOptions(dir.OptionFile, dir.Config, "default-character-set", "default-collation"); err != nil {
+			return nil, err
+		}
+		dir.OptionFile.SetOptionValue(section, "default-character-set", instSchema.CharSet)
+		dir.OptionFile.SetOptionValue(section, "default-collation", instSchema.Collation)
 		if err := dir.OptionFile.Write(true); err != nil {
 			return nil, fmt.Errorf("Unable to update character set and collation for %s: %s", dir.OptionFile.Path(), err)
 		}
 		log.Infof("Wrote %s -- updated schema-level default-character-set and default-collation", dir.OptionFile.Path())
 	}
 
+	// Schema comments are handled the same way, but are only persisted if the
+	// server supports them and the comment is non-blank or was already set
+	comment, err := applier.SchemaComment(instance, instSchema.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Unable to fetch comment of schema %s from %s: %s", dir, instSchema.Name, instance, err)
+	}
+	if (comment != "" || dir.Config.Supplied("schema-comment")) && comment != util.UnquoteOptionValue(dir.Config.GetRaw("schema-comment")) {
+		var section string
+		if overridesPath != "" {
+			section = dir.Config.Get("environment")
+		}
+		if err := util.CheckEncryptedOptions(dir.OptionFile, dir.Config, "schema-comment"); err != nil {
+			return nil, err
+		}
+		dir.OptionFile.SetOptionValue(section, "schema-comment", util.QuoteOptionValue(comment))
+		if err := dir.OptionFile.Write(true); err != nil {
+			return nil, fmt.Errorf("Unable to update schema comment for %s: %s", dir.OptionFile.Path(), err)
+		}
+		log.Infof("Wrote %s -- updated schema-comment", dir.OptionFile.Path())
+	}
+
 	dumpOpts := dumper.Options{
-		IncludeAutoInc: dir.Config.GetBool("include-auto-inc"),
+		IncludeAutoInc:       dir.Config.GetBool("include-auto-inc"),
+		SkipDelimiters:       !dir.Config.GetBool("routine-delimiter"),
+		EncodeCaseCollisions: dir.Config.GetBool("encode-case-collisions"),
 	}
 	if dumpOpts.IgnoreTable, err = dir.Config.GetRegexp("ignore-table"); err != nil {
 		return nil, NewExitValue(CodeBadConfig, err.Error())
 	}
+	if trackingTable := dir.Config.Get("tracking-table"); trackingTable != "" {
+		dumpOpts.IgnoreKeys([]tengo.ObjectKey{{Type: tengo.ObjectTypeTable, Name: trackingTable}})
+	}
+	if err := setCombineOptions(dir.Config, &dumpOpts); err != nil {
+		return nil, err
+	}
 	if partitioning, _ := dir.Config.GetEnum("partitioning", "keep", "remove", "modify"); partitioning == "remove" {
 		dumpOpts.RetainPartitioning = true
 	}
 
+	// With environment-overrides, differences from the shared definitions are
+	// written to the environment's overrides dir instead of the shared files
+	if overridesPath != "" {
+		tr.ObjectCount, err = pullOverrides(dir, instance, logicalSchema, instSchema, overridesPath, dumpOpts)
+		if err == nil && tr.ObjectCount > 0 {
+			tr.Status = "updated"
+			log.Infof("Wrote %s -- %s", overridesPath, countAndNoun(tr.ObjectCount, "override changed", "overrides changed"))
+		} else if err == nil {
+			tr.Status = "no-differences"
+		}
+		if err == nil {
+			recordPullSync(dir, instance, instSchema)
+		}
+		os.Stderr.WriteString("\n")
+		return
+	}
+
 	// When --skip-format is in use, we only want to update objects that have
 	// actual functional modifications, NOT just cosmetic/formatting differences.
 	// To make this distinction, we need to actually execute the *.sql files in a
@@ -208,11 +311,96 @@ func pullLogicalSchema(dir *fs.Dir, instance *tengo.Instance, logicalSchema *fs.
 		dumpOpts.OnlyKeys(inDiff)
 	}
 
-	_, err = dumper.DumpSchema(instSchema, dir, dumpOpts)
+	if tr.ObjectCount, err = dumper.DumpSchema(instSchema, dir, dumpOpts); err != nil {
+		err = dumpError(dir, err)
+	} else if tr.ObjectCount > 0 {
+		tr.Status = "updated"
+	} else {
+		tr.Status = "no-differences"
+	}
+	if err == nil {
+		recordPullSync(dir, instance, instSchema)
+	}
 	os.Stderr.WriteString("\n")
 	return
 }
 
+// recordPullSync updates dir's state file, if one is configured, to reflect
+// that dir now matches instSchema. Failure to do so is not fatal, since the
+// state file only affects conflict detection in subsequent pushes.
+func recordPullSync(dir *fs.Dir, instance *tengo.Instance, instSchema *tengo.Schema) {
+	if err := applier.RecordSync(dir, instance, instSchema); err != nil {
+		log.Warnf("%s: unable to record pull in state file: %s", dir, err)
+	}
+}
+
+// pullOverrides computes which objects in instSchema differ functionally from
+// the shared definitions in logicalSchema, and rewrites the overrides dir so
+// that it contains exactly those objects. Overrides for objects which now
+// match their shared definition are removed. The shared *.sql files are never
+// modified. Objects defined in the shared files but absent from instSchema
+// cannot be expressed as overrides, and are logged with a warning. The return
+// value is the number of overrides added, changed, or removed.
+func pullOverrides(dir *fs.Dir, instance *tengo.Instance, logicalSchema *fs.LogicalSchema, instSchema *tengo.Schema, overridesPath string, dumpOpts dumper.Options) (int, error) {
+	mods := statementModifiersForPull(dir.Config, instance, dumpOpts.IgnoreTable)
+	opts, err := workspace.OptionsForDir(dir, instance)
+	if err != nil {
+		return 0, NewExitValue(CodeBadConfig, err.Error())
+	}
+	inDiff, err := objectsInDiff(logicalSchema.Shared(), instSchema, opts, mods)
+	if err != nil {
+		return 0, err
+	}
+	trackingTable := dir.Config.Get("tracking-table")
+	defs := instSchema.ObjectDefinitions()
+	creates := make(map[tengo.ObjectKey]string, len(inDiff))
+	for _, key := range inDiff {
+		if key.Type == tengo.ObjectTypeTable && key.Name == trackingTable {
+			continue
+		}
+		create, ok := defs[key]
+		if !ok {
+			log.Warnf("%s: %s is defined in shared *.sql files but does not exist in %s %s; environment overrides cannot express its absence", dir, key, instance, instSchema.Name)
+			continue
+		}
+		if key.Type == tengo.ObjectTypeTable && !dumpOpts.IncludeAutoInc {
+			create, _ = tengo.ParseCreateAutoInc(create)
+		}
+		creates[key] = create
+	}
+	count, err := fs.WriteOverrides(overridesPath, creates)
+	dir.Snapshot.Forget(overridesPath)
+	return count, err
+}
+
+// dumpError converts a *dumper.FileNameCollisionError into an ExitValue with
+// guidance on resolving the problem. Other errors are returned unchanged.
+func dumpError(dir *fs.Dir, err error) error {
+	if _, ok := err.(*dumper.FileNameCollisionError); ok {
+		return NewExitValue(CodeCantCreate, "Unable to write in %s: %s. Use ignore-table to skip some of these objects, or --encode-case-collisions to percent-encode uppercase letters in the new file names.", dir, err)
+	}
+	return err
+}
+
+// setCombineOptions populates opts with the combine-tables and combine-file
+// options from config, returning an ExitValue if the options are invalid.
+func setCombineOptions(config *mybase.Config, opts *dumper.Options) error {
+	opts.CombineTables = config.GetSlice("combine-tables", ',', true)
+	if len(opts.CombineTables) == 0 {
+		return nil
+	}
+	for _, pattern := range opts.CombineTables {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return NewExitValue(CodeBadConfig, "Invalid value for option combine-tables: %s: %s", pattern, err)
+		}
+	}
+	opts.CombinedFile = config.Get("combine-file")
+	if strings.ContainsAny(opts.CombinedFile, "/\\") || !strings.HasSuffix(opts.CombinedFile, ".sql") || opts.CombinedFile == ".sql" {
+		return NewExitValue(CodeBadConfig, "Invalid value for option combine-file: must be a file name ending in .sql, without any directory")
+	}
+	return nil
+}
+
 func statementModifiersForPull(config *mybase.Config, instance *tengo.Instance, ignoreTable *regexp.Regexp) tengo.StatementModifiers {
 	// We're permissive of unsafe operations here since we don't ever actually
 	// execute the generated statement! We just examine its type.
@@ -289,6 +477,10 @@ func updateFlavor(dir *fs.Dir, instance *tengo.Instance) {
 	if !instFlavor.Known() || instFlavor.String() == dir.Config.Get("flavor") {
 		return
 	}
+	if err := util.CheckEncryptedOptions(dir.OptionFile, dir.Config, "flavor"); err != nil {
+		log.Warnf("Unable to update flavor: %s", err)
+		return
+	}
 	dir.OptionFile.SetOptionValue(dir.Config.Get("environment"), "flavor", instFlavor.String())
 	if err := dir.OptionFile.Write(true); err != nil {
 		log.Warnf("Unable to update flavor in %s: %s", dir.OptionFile.Path(), err)
@@ -307,17 +499,28 @@ func findNewSchemas(dir *fs.Dir, instance *tengo.Instance, seenNames []string) e
 	if err != nil {
 		return err
 	}
+	var newNames []string
 	for _, name := range schemaNames {
 		// If no existing subdir maps to the schema, we need to create and populate new dir
 		if !subdirHasSchema[name] {
-			s, err := instance.Schema(name)
-			if err != nil {
-				return err
-			}
-			// use same logic from init command
-			if err := PopulateSchemaDir(s, dir, true); err != nil {
-				return err
-			}
+			newNames = append(newNames, name)
+		}
+	}
+	if len(newNames) == 0 {
+		return nil
+	}
+	subdirNames, err := schemaSubdirNames(dir, newNames)
+	if err != nil {
+		return err
+	}
+	for _, name := range newNames {
+		s, err := instance.Schema(name)
+		if err != nil {
+			return err
+		}
+		// use same logic from init command
+		if err := PopulateSchemaDir(s, dir, subdirNames[name]); err != nil {
+			return err
 		}
 	}
 
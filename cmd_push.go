@@ -1,13 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/applier"
 	"github.com/skeema/skeema/fs"
 	"github.com/skeema/skeema/linter"
+	"github.com/skeema/skeema/util"
+	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -26,50 +34,320 @@ top of the file. If no environment name is supplied, the default is
 "production".`
 
 	cmd := mybase.NewCommand("push", summary, desc, PushHandler)
-	cmd.AddOption(mybase.BoolOption("verify", 0, true, "Test all generated ALTER statements on temp schema to verify correctness"))
+	cmd.AddOption(mybase.BoolOption("verify", 0, true, "Check *.sql files for syntax problems before connecting, and test all generated ALTER statements on temp schema to verify correctness"))
+	cmd.AddOption(mybase.BoolOption("verify-sequence", 0, true, "Before executing DDL, run the full statement sequence in a workspace and confirm the result matches *.sql definitions"))
 	cmd.AddOption(mybase.BoolOption("allow-unsafe", 0, false, "Permit running ALTER or DROP operations that are potentially destructive"))
+	cmd.AddOption(mybase.BoolOption("allow-oversized-keys", 0, false, "Permit ALTERs which would cause an index to exceed the server's key size limit"))
+	cmd.AddOption(mybase.StringOption("allow-unsafe-codes", 0, "", "Permit running potentially destructive operations only of these unsafe codes (comma-separated)"))
+	cmd.AddOption(mybase.BoolOption("allow-empty-rebuild", 0, false, "Permit pushing to existing schemas which have no tables, despite --empty-rebuild-min-tables"))
+	cmd.AddOption(mybase.StringOption("empty-rebuild-min-tables", 0, "0", "Refuse to push to an existing schema with no tables if *.sql files define at least this many tables (0 to disable)"))
 	cmd.AddOption(mybase.BoolOption("dry-run", 0, false, "Output DDL but don't run it; equivalent to `skeema diff`"))
 	cmd.AddOption(mybase.BoolOption("first-only", '1', false, "For dirs mapping to multiple instances or schemas, just run against the first per dir"))
+	cmd.AddOption(mybase.BoolOption("check-consistency", 0, false, "For dirs mapping to multiple schemas, compare the schemas against each other and report outliers"))
+	cmd.AddOption(mybase.BoolOption("allow-shared-schema", 0, false, "Permit multiple dirs to map to the same schema on the same host, combining their *.sql files"))
 	cmd.AddOption(mybase.BoolOption("exact-match", 0, false, "Follow *.sql table definitions exactly, even for differences with no functional impact"))
 	cmd.AddOption(mybase.BoolOption("foreign-key-checks", 0, false, "Force the server to check referential integrity of any new foreign key"))
 	cmd.AddOption(mybase.BoolOption("compare-metadata", 0, false, "For stored programs, detect changes to creation-time sql_mode or DB collation"))
 	cmd.AddOption(mybase.BoolOption("lint", 0, true, "Check modified objects for problems before proceeding"))
 	cmd.AddOption(mybase.BoolOption("brief", 'q', false, "<overridden by diff command>").Hidden())
+	cmd.AddOption(mybase.BoolOption("sample", 0, false, "<overridden by diff command>").Hidden())
 	cmd.AddOption(mybase.BoolOption("alter-validate-virtual", 0, false, "Apply a WITH VALIDATION clause to ALTER TABLEs affecting virtual columns"))
 	cmd.AddOption(mybase.StringOption("alter-wrapper", 'x', "", "External bin to shell out to for ALTER TABLE; see manual for template vars"))
 	cmd.AddOption(mybase.StringOption("alter-wrapper-min-size", 0, "0", "Ignore --alter-wrapper for tables smaller than this size in bytes"))
+	cmd.AddOption(mybase.StringOption("wrapper-flavor", 0, "custom", `Tool used by --alter-wrapper or --ddl-wrapper, for warning about unsupported tables (valid values: "custom", "pt-osc", "gh-ost")`))
+	cmd.AddOption(mybase.BoolOption("wrapper-strict", 0, false, "Run ALTER TABLE directly instead of via wrapper for tables which --wrapper-flavor cannot handle"))
 	cmd.AddOption(mybase.StringOption("alter-lock", 0, "", `Apply a LOCK clause to all ALTER TABLEs (valid values: "none", "shared", "exclusive")`))
 	cmd.AddOption(mybase.StringOption("alter-algorithm", 0, "", `Apply an ALGORITHM clause to all ALTER TABLEs (valid values: "inplace", "copy", "instant")`))
 	cmd.AddOption(mybase.StringOption("ddl-wrapper", 'X', "", "Like --alter-wrapper, but applies to all DDL types (CREATE, DROP, ALTER)"))
 	cmd.AddOption(mybase.StringOption("safe-below-size", 0, "0", "Always permit destructive operations for tables below this size in bytes"))
+	cmd.AddOption(mybase.StringOption("manage-grants", 0, "", "Comma-separated list of users whose privileges on the schema are managed by .grants.sql files"))
 	cmd.AddOption(mybase.StringOption("concurrent-instances", 'c', "1", "Perform operations on this number of instances concurrently"))
+	cmd.AddOption(mybase.StringOption("variable-mismatch", 0, "warning", `How to handle server variables affecting DDL differing between workspace and target (valid values: "ignore", "warning", "error")`))
+	cmd.AddOption(mybase.BoolOption("allow-read-only", 0, false, "Permit pushing to instances with read_only or super_read_only enabled"))
+	cmd.AddOption(mybase.BoolOption("allow-skipped-objects", 0, false, "Don't treat objects skipped due to unsupported features as an error in the exit code"))
+	cmd.AddOption(mybase.StringOption("replicas", 0, "", "Comma-separated list of replica hosts whose replication lag is checked before pushing"))
+	cmd.AddOption(mybase.StringOption("max-replica-lag", 0, "60s", "Refuse to push if any host in --replicas is lagging by more than this duration"))
+	cmd.AddOption(mybase.StringOption("schemas", 0, "", "Only operate on schemas matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("hosts", 0, "", "Only operate on hosts matching this comma-separated list of names or glob patterns"))
+	cmd.AddOption(mybase.StringOption("targets", 0, "", `Read host:port/schema target specs from this file, or "-" for STDIN, instead of using host and schema options`))
+	cmd.AddOption(mybase.StringOption("since", 0, "", "Only operate on objects whose *.sql files changed since this git revision"))
+	cmd.AddOption(mybase.StringOption("run-timeout", 0, "0", "Abandon any targets not completed within this duration (0 for no limit)"))
+	cmd.AddOption(mybase.StringOption("ignore-table-options", 0, "", "Comma-separated list of table options (e.g. KEY_BLOCK_SIZE) to exclude from comparison"))
+	cmd.AddOption(mybase.BoolOption("stats", 0, false, "Output row count estimates and data and index sizes of each affected table"))
+	cmd.AddOption(mybase.BoolOption("exact-counts", 0, false, "With --stats, count rows of InnoDB tables exactly instead of estimating; may be slow for large tables"))
+	cmd.AddOption(mybase.BoolOption("include-passthrough", 0, false, "After all other DDL, run the statements of each dir's *.passthrough.sql files"))
+	cmd.AddOption(mybase.BoolOption("annotate", 0, false, "Precede DDL output with a summary of each schema's changes, and tag each statement (e.g. [create], [unsafe])"))
+	cmd.AddOption(mybase.BoolOption("no-color", 0, false, "With --annotate, disable colorized output even if STDOUT is a terminal"))
+	cmd.AddOption(mybase.StringOption("output-prefix", 0, "", "Prefix output lines and log messages for each target with this template, e.g. \"[{HOST}:{SCHEMA}]\""))
+	cmd.AddOption(mybase.BoolOption("backup", 0, true, "Save definitions of tables to a backup dir before dropping them or any of their columns"))
+	cmd.AddOption(mybase.StringOption("backup-dir", 0, "", "Dir in which to save backups of table definitions (default .skeema-backups in repo base)"))
+	cmd.AddOption(mybase.BoolOption("backup-row-count", 0, false, "Include each table's row count in its backup file"))
+	cmd.AddOption(mybase.StringOption("label", 0, "", "Arbitrary label, such as a commit SHA, to record in tracking-table upon push"))
+	cmd.AddOption(mybase.StringOption("statement-comment", 0, "skeema:push user={USER} dir={DIR} env={ENVIRONMENT}", "Template for a comment prepended to each executed statement, for attribution in the processlist"))
+	cmd.AddOption(mybase.StringOption("statement-comment-user", 0, "", "Username for {USER} in statement-comment (default OS username)"))
+	cmd.AddOption(mybase.StringOption("sleep-between-statements", 0, "0", "Pause for this duration after each DDL statement on a target (0 for no pause)"))
+	cmd.AddOption(mybase.StringOption("sleep-between-targets", 0, "0", "Pause for this duration after finishing DDL on one target before the next (0 for no pause)"))
+	cmd.AddOption(mybase.StringOption("replica-lag-query", 0, "", "Query returning replication lag in seconds; push waits after each DDL statement until lag is within max-replica-lag"))
+	cmd.AddOption(mybase.StringOption("ddl-batching", 0, "per-table", `Granularity of generated ALTER TABLE statements (valid values: "per-table", "per-clause")`))
 	cmd.AddOption(mybase.StringOption("partitioning", 0, "keep", `Specify handling of partitioning status on the database side (valid values: "keep", "remove", "modify")`))
+	cmd.AddOption(mybase.StringOption("summary-file", 0, "", "Write a JSON summary of the run to this file, for consumption by CI systems"))
+	cmd.AddOption(mybase.StringOption("exit-code-mode", 0, "strict", `Controls how differences and errors map to exit codes (valid values: "strict", "zero", "drift-only")`))
+	cmd.AddOption(mybase.StringOption("max-statements", 0, "0", "Refuse to push if the run would execute more than this many DDL statements in total (0 for no limit)"))
+	cmd.AddOption(mybase.StringOption("max-altered-objects", 0, "0", "Refuse to push if the run would alter more than this many objects in total (0 for no limit)"))
+	cmd.AddOption(mybase.BoolOption("force-conflicts", 0, false, "Push objects changed on the server since the last recorded pull or push, even if also changed in the filesystem"))
+	cmd.AddOption(mybase.StringOption("script-out", 0, "", "Write the DDL to a SQL script per target instead of running it; see manual for template vars"))
+	cmd.AddOption(mybase.BoolOption("all", 0, false, "Permit pushing to multiple schemas from a directory containing subdirectories, without confirmation"))
 	linter.AddCommandOptions(cmd)
 	cmd.AddArg("environment", "production", false)
 	CommandSuite.AddSubCommand(cmd)
-	clonePushOptionsToDiff()
+	clonePushOptions()
 }
 
 // PushHandler is the handler method for `skeema push`
-func PushHandler(cfg *mybase.Config) error {
-	dir, err := fs.ParseDir(".", cfg)
+func PushHandler(cfg *mybase.Config) (err error) {
+	if _, err := cfg.GetEnum("exit-code-mode", "strict", "zero", "drift-only"); err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	// Scripts are generated using push's dry-run logic
+	if cfg.Get("script-out") != "" {
+		cfg.CLI.OptionValues["dry-run"] = "1"
+		cfg.CLI.OptionValues["brief"] = "0"
+		cfg.MarkDirty()
+	}
+	summary := startSummary(cfg)
+	defer func() { summary.finish(err) }()
+
+	dir, err := parseWorkingDir(cfg)
 	if err != nil {
 		return err
 	}
 
 	briefMode := dir.Config.GetBool("dry-run") && dir.Config.GetBool("brief")
 	printer := applier.NewPrinter(briefMode)
-	g, ctx := errgroup.WithContext(context.Background())
-	tgchan, skipCount := applier.TargetGroupChanForDir(dir)
-	results := make(chan applier.Result)
+	printer.Annotate(dir.Config.GetBool("annotate"))
+	printer.UseColor(colorOutput(dir))
+	sampleMode := dir.Config.GetBool("dry-run") && dir.Config.GetBool("sample")
+	printer.CollapseSamples(sampleMode)
+	scripts := applier.NewScriptSet(dir, version)
+	printer.RecordScripts(scripts)
+	sum, err := pushDir(dir, printer)
+	if sampleMode {
+		printer.PrintSamples()
+	}
+	summary.addPushResult(sum)
+	if err != nil {
+		return err
+	}
+	if err := writeScripts(scripts); err != nil {
+		return err
+	}
+	return pushResultError(dir, sum)
+}
 
+// writeScripts writes the scripts recorded for any targets using the
+// script-out option, logging the path of each.
+func writeScripts(scripts *applier.ScriptSet) error {
+	paths, err := scripts.Write()
+	if _, ok := err.(applier.ConfigError); ok {
+		return NewExitValue(CodeBadConfig, err.Error())
+	} else if err != nil {
+		return NewExitValue(CodeCantCreate, err.Error())
+	}
+	for _, path := range paths {
+		log.Infof("Wrote script %s", path)
+	}
+	if count := scripts.CommentedOutCount(); count > 0 {
+		log.Warnf("Commented out %s in scripts; use --allow-unsafe, --allow-unsafe-codes, or --safe-below-size to include them", countAndNoun(count, "unsafe statement", "unsafe statements"))
+	}
+	return nil
+}
+
+// changeLimits returns the max-statements and max-altered-objects limits of
+// dir which are enabled, keyed by option name. These limit the total amount of
+// change made by a single push, summed across all targets. Limits are not
+// enforced in dry-run mode, or for commands lacking these options.
+func changeLimits(dir *fs.Dir) (map[string]int, error) {
+	limits := make(map[string]int)
+	if dir.Config.GetBool("dry-run") {
+		return limits, nil
+	}
+	for _, name := range []string{"max-statements", "max-altered-objects"} {
+		if dir.Config.FindOption(name) == nil {
+			continue
+		}
+		value, err := dir.Config.GetInt(name)
+		if err == nil && value < 0 {
+			err = fmt.Errorf("%s cannot be negative", name)
+		}
+		if err != nil {
+			return nil, NewExitValue(CodeBadConfig, err.Error())
+		} else if value > 0 {
+			limits[name] = value
+		}
+	}
+	return limits, nil
+}
+
+// checkChangeLimits returns an error if sum, the combined result of preparing
+// all targets via applier.PrepareTargets, exceeds any of limits. In this case
+// each target's counts are logged; no DDL has been executed.
+func checkChangeLimits(limits map[string]int, sum applier.Result) error {
+	counts := map[string]int{
+		"max-statements":      sum.StatementCount,
+		"max-altered-objects": sum.ObjectCount,
+	}
+	var exceeded, raise []string
+	for _, name := range sortedKeys(limits) {
+		if counts[name] > limits[name] {
+			exceeded = append(exceeded, fmt.Sprintf("%s=%d", name, limits[name]))
+			raise = append(raise, fmt.Sprintf("--%s=%d", name, counts[name]))
+		}
+	}
+	if len(exceeded) == 0 {
+		return nil
+	}
+	for _, tr := range sum.Targets {
+		if tr.StatementCount > 0 {
+			log.Errorf("%s %s (%s): %s altering %s", tr.Instance, tr.Schema, tr.Dir, countAndNoun(tr.StatementCount, "statement", "statements"), countAndNoun(tr.ObjectCount, "object", "objects"))
+		}
+	}
+	return NewExitValue(CodeNotPermitted, "This push would run %s altering %s, exceeding %s; no database operations were attempted. Review these changes using a plan file workflow (`skeema plan` followed by `skeema apply`), or raise the limit for this run only by supplying %s on the command-line.",
+		countAndNoun(sum.StatementCount, "statement", "statements"), countAndNoun(sum.ObjectCount, "object", "objects"), strings.Join(exceeded, " and "), strings.Join(raise, " "))
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pushResultError returns an error with an appropriate exit code for the
+// combined result of pushDir, or nil if the result indicates success.
+func pushResultError(dir *fs.Dir, sum applier.Result) error {
+	if sum.SkipCount+unsupportedCount(dir, sum)+sum.TimeoutCount == 0 {
+		if dir.Config.GetBool("dry-run") && sum.Differences {
+			return NewExitValue(CodeDifferencesFound, "")
+		}
+		return nil
+	}
+	code := CodeFatalError
+	if sum.SkipCount+sum.TimeoutCount == 0 {
+		code = CodePartialError
+	}
+	return NewExitValue(code, sum.Summary())
+}
+
+// unsupportedCount returns the number of operations in sum which were skipped
+// due to use of unsupported features, for purposes of determining the exit
+// code. With allow-skipped-objects, these are not treated as errors, so 0 is
+// returned.
+func unsupportedCount(dir *fs.Dir, sum applier.Result) int {
+	if dir.Config.FindOption("allow-skipped-objects") != nil && dir.Config.GetBool("allow-skipped-objects") {
+		return 0
+	}
+	return sum.UnsupportedCount
+}
+
+// logSkippedObjects logs a section listing every object which was skipped in
+// sum, along with the reason why, so that these are not buried among the
+// output of all other objects.
+func logSkippedObjects(sum applier.Result) {
+	var count int
+	for _, tr := range sum.Targets {
+		count += len(tr.SkippedObjects)
+	}
+	if count == 0 {
+		return
+	}
+	log.Infof("Skipped objects (%d):", count)
+	for _, tr := range sum.Targets {
+		for _, obj := range tr.SkippedObjects {
+			log.Infof("  %s %s: %s", tr.Instance, tr.Schema, obj)
+		}
+	}
+}
+
+// colorOutput returns true if DDL output should be colorized. This requires
+// STDOUT to be a terminal, and may be disabled by the no-color option or the
+// NO_COLOR environment variable.
+func colorOutput(dir *fs.Dir) bool {
+	if dir.Config.GetBool("no-color") || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return terminal.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// pushDir performs diff/push operations on all targets of dir and its
+// subdirectories, sending output to printer, and returns the combined result.
+// A non-nil error is only returned if a fatal error occurred, in which case
+// the result may still reflect any targets completed prior to the error.
+func pushDir(dir *fs.Dir, printer *applier.Printer) (applier.Result, error) {
+	runTimeout, err := util.ParseTimeout("run-timeout", dir.Config.Get("run-timeout"))
+	if err != nil {
+		return applier.Result{}, NewExitValue(CodeBadConfig, err.Error())
+	}
+	runCtx := context.Background()
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(runCtx, runTimeout)
+		defer cancel()
+	}
+	if dir.Config.GetBool("verify") {
+		if problems := fs.ValidateTree(dir, 5); len(problems) > 0 {
+			for _, problem := range problems {
+				log.Error(problem)
+			}
+			return applier.Result{}, NewExitValue(CodeBadInput, "Found %s in *.sql files or option files; no database operations were attempted. Fix these problems, or run again with --skip-verify to bypass this check", countAndNoun(len(problems), "problem", "problems"))
+		}
+	}
+	g, ctx := errgroup.WithContext(runCtx)
+	targets, skipCount, filterCount, err := targetsToProcess(dir)
+	if _, ok := err.(applier.ConfigError); ok {
+		return applier.Result{}, NewExitValue(CodeBadConfig, err.Error())
+	} else if err != nil {
+		return applier.Result{}, err
+	}
+	if changes, err := gitChangesSince(dir); err != nil {
+		return applier.Result{}, err
+	} else if changes != nil {
+		var unchanged []*applier.Target
+		if targets, unchanged = applier.NarrowTargets(targets, changes); len(unchanged) > 0 {
+			log.Infof("Skipping %s with no changes since %s", countAndNoun(len(unchanged), "target", "targets"), changes.Ref)
+		}
+	}
+	if err := confirmTargets(dir, targets); err != nil {
+		return applier.Result{}, err
+	}
 	workerCount, err := dir.Config.GetInt("concurrent-instances")
 	if err == nil && workerCount < 1 {
 		err = fmt.Errorf("concurrent-instances cannot be less than 1")
 	}
 	if err != nil {
-		return NewExitValue(CodeBadConfig, err.Error())
+		return applier.Result{}, NewExitValue(CodeBadConfig, err.Error())
 	}
+
+	// With max-statements or max-altered-objects, all DDL is generated up-front
+	// and checked against the limits; the same DDL is then executed below
+	limits, err := changeLimits(dir)
+	if err != nil {
+		return applier.Result{}, err
+	} else if len(limits) > 0 {
+		log.Infof("Generating DDL for all targets to enforce %s", strings.Join(sortedKeys(limits), " and "))
+		sum, err := applier.PrepareTargets(targets, printer, workerCount)
+		if _, ok := err.(applier.ConfigError); ok {
+			return sum, NewExitValue(CodeBadConfig, err.Error())
+		} else if err != nil {
+			return sum, err
+		} else if err := checkChangeLimits(limits, sum); err != nil {
+			return sum, err
+		}
+	}
+
+	tgchan := applier.TargetGroupChan(targets)
+	results := make(chan applier.Result)
 	for n := 0; n < workerCount; n++ {
 		g.Go(func() error {
 			return applier.Worker(ctx, tgchan, results, printer)
@@ -84,24 +362,101 @@ func PushHandler(cfg *mybase.Config) error {
 	for r := range results {
 		allResults = append(allResults, r)
 	}
+
+	// Upon a fatal error, the results of any completed targets are still
+	// returned, for use in the summary file
+	sum := applier.SumResults(allResults)
 	if err := g.Wait(); err != nil {
 		if _, ok := err.(applier.ConfigError); ok {
-			return NewExitValue(CodeBadConfig, err.Error())
+			return sum, NewExitValue(CodeBadConfig, err.Error())
 		}
-		return err
+		return sum, err
 	}
-	sum := applier.SumResults(allResults)
 	sum.SkipCount += skipCount
+	logSkippedObjects(sum)
+	if filterCount > 0 {
+		log.Warnf("Partial run: %s excluded by schemas or hosts option", countAndNoun(filterCount, "target", "targets"))
+	}
+	return sum, nil
+}
 
-	if sum.SkipCount+sum.UnsupportedCount == 0 {
-		if dir.Config.GetBool("dry-run") && sum.Differences {
-			return NewExitValue(CodeDifferencesFound, "")
+// stdinTargetSpecs caches the result of reading target specs from STDIN, since
+// STDIN can only be read once.
+var stdinTargetSpecs struct {
+	sync.Once
+	specs   []applier.TargetSpec
+	invalid []applier.TargetSpecError
+	err     error
+}
+
+// targetsToProcess returns the targets of dir, along with counts of skipped and
+// filtered targets, as with applier.TargetsToProcess. If the targets option is
+// set, the targets instead come from the target specs that it supplies, using
+// the configuration and *.sql files of dir itself. Invalid target spec lines
+// are logged and skipped, unless the strict option is enabled, in which case
+// they are a fatal error.
+func targetsToProcess(dir *fs.Dir) ([]*applier.Target, int, int, error) {
+	if dir.Config.FindOption("targets") == nil || dir.Config.Get("targets") == "" {
+		return applier.TargetsToProcess(dir)
+	}
+	var specs []applier.TargetSpec
+	var invalid []applier.TargetSpecError
+	var err error
+	if source := dir.Config.Get("targets"); source == "-" {
+		stdinTargetSpecs.Do(func() {
+			stdinTargetSpecs.specs, stdinTargetSpecs.invalid, stdinTargetSpecs.err = applier.ParseTargetSpecs(os.Stdin)
+		})
+		specs, invalid, err = stdinTargetSpecs.specs, stdinTargetSpecs.invalid, stdinTargetSpecs.err
+	} else if f, openErr := os.Open(source); openErr != nil {
+		return nil, 0, 0, NewExitValue(CodeBadConfig, "Unable to read option targets: %s", openErr)
+	} else {
+		specs, invalid, err = applier.ParseTargetSpecs(f)
+		f.Close()
+	}
+	if err != nil {
+		return nil, 0, 0, NewExitValue(CodeBadInput, "Unable to read target specs: %s", err)
+	}
+	if len(invalid) > 0 && dir.Config.GetBool("strict") {
+		for _, tse := range invalid {
+			log.Errorf("Invalid target spec on %s", tse)
 		}
+		return nil, 0, 0, NewExitValue(CodeBadInput, "Found %s in target specs; no database operations were attempted", countAndNoun(len(invalid), "invalid line", "invalid lines"))
+	}
+	for _, tse := range invalid {
+		log.Warnf("Skipping invalid target spec on %s", tse)
+	}
+	targets, skipCount, filterCount, err := applier.TargetsForSpecs(dir, specs)
+	return targets, skipCount + len(invalid), filterCount, err
+}
+
+// confirmTargets guards against accidentally pushing to many schemas at once,
+// typically by running push from a higher-level directory than intended. If
+// dir is not a leaf, and its subdirectories map to more than one target, the
+// user must either confirm interactively or supply the all option. Otherwise,
+// an error is returned. This check does not apply to dry-run mode.
+func confirmTargets(dir *fs.Dir, targets []*applier.Target) error {
+	if len(targets) < 2 || dir.Config.GetBool("dry-run") || dir.Config.GetBool("all") {
 		return nil
 	}
-	code := CodeFatalError
-	if sum.SkipCount == 0 {
-		code = CodePartialError
+	var nonLeaf bool
+	for _, t := range targets {
+		if t.Dir.Path != dir.Path {
+			nonLeaf = true
+			break
+		}
 	}
-	return NewExitValue(code, sum.Summary())
+	if !nonLeaf {
+		return nil
+	}
+
+	desc := fmt.Sprintf("%s in %s and its subdirectories", countAndNoun(len(targets), "schema", "schemas"), dir)
+	if !terminal.IsTerminal(int(os.Stdin.Fd())) || !terminal.IsTerminal(int(os.Stderr.Fd())) {
+		return NewExitValue(CodeBadUsage, "Refusing to push to %s without confirmation; no database operations were attempted. Run again with --all to push to all of them, or run from the directory of a single schema, or narrow the targets using --schemas or --hosts.", desc)
+	}
+	fmt.Fprintf(os.Stderr, "This will push to %s. Continue? [y/N] ", desc)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return NewExitValue(CodeBadUsage, "Push cancelled; no database operations were attempted")
+	}
+	return nil
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/util"
+)
+
+func init() {
+	summary := "Serve diff results over HTTP as a long-running process"
+	desc := `Runs a long-lived HTTP server which reports drift between a directory tree and
+its database servers, for consumption by dashboards and other tools. The tree is
+parsed once per directory and environment, and re-parsed automatically whenever
+*.sql files or option files change. Database connection pools are kept open
+between requests.
+
+The following endpoints return JSON:
+
+  GET  /targets?dir=...&environment=...  list the instances and schemas of a dir
+  GET  /diff?dir=...&environment=...     diff a dir, as with ` + "`skeema diff`" + `
+  POST /refresh                          discard all parsed directory trees
+
+The dir parameter is relative to --dir, defaulting to its top level. The
+environment parameter defaults to "production". Diffs use the configuration of
+each dir's .skeema files for the requested environment, along with any global
+options supplied on the command-line, such as --user or --password.
+
+If --auth-token is set, every request must include the header
+"Authorization: Bearer <token>".
+
+Changes are detected by polling rather than by filesystem notifications, which
+behave inconsistently across platforms, network filesystems, and editors that
+replace files on save, and which require a watch per subdirectory. Every
+--poll-interval, the whole tree is walked and each relevant file is stat'ed.
+This is inexpensive for typical trees, but on a tree with many thousands of
+files it costs that many stat calls per poll; use a longer --poll-interval for
+very large trees, along with POST /refresh after deploying changes.`
+
+	cmd := mybase.NewCommand("serve", summary, desc, ServeHandler)
+	cmd.AddOption(mybase.StringOption("dir", 'd', ".", "Base dir of the directory tree to serve"))
+	cmd.AddOption(mybase.StringOption("listen", 0, "127.0.0.1:9284", "Address and port to listen on for HTTP requests"))
+	cmd.AddOption(mybase.StringOption("auth-token", 0, "", "Require requests to supply this bearer token (default no authentication)"))
+	cmd.AddOption(mybase.StringOption("poll-interval", 0, "2s", "How often to check the directory tree for changed files"))
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// ServeHandler is the handler method for `skeema serve`
+func ServeHandler(cfg *mybase.Config) error {
+	// The strict option does not apply to requests, which each report their own
+	// errors, so there is no reason to collect warnings for the process's life
+	if processWarnings != nil {
+		processWarnings.stop()
+	}
+	basePath, err := filepath.Abs(cfg.Get("dir"))
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(basePath); err != nil || !fi.IsDir() {
+		return NewExitValue(CodeBadConfig, "In serve, --dir must refer to a directory that already exists")
+	}
+	pollInterval, err := util.ParseTimeout("poll-interval", cfg.Get("poll-interval"))
+	if err == nil && pollInterval == 0 {
+		err = NewExitValue(CodeBadConfig, "Option poll-interval must be greater than 0")
+	}
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	token := cfg.Get("auth-token")
+	if token == "" {
+		log.Warn("No auth-token configured; any client able to connect can view schema drift and DDL")
+	}
+
+	srv := newServer(basePath, token, diffConfigFactory(cfg))
+	stopWatching := srv.watch(pollInterval)
+	defer stopWatching()
+	httpServer := &http.Server{
+		Addr:    cfg.Get("listen"),
+		Handler: srv,
+	}
+
+	// Shut down gracefully upon SIGINT or SIGTERM, permitting in-progress
+	// requests to finish
+	done := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		<-sigs
+		log.Info("Shutting down")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		httpServer.Shutdown(ctx)
+		close(done)
+	}()
+
+	log.Infof("Serving %s on http://%s", basePath, httpServer.Addr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		return NewExitValue(CodeFatalError, err.Error())
+	}
+	<-done
+	return nil
+}
+
+// diffConfigFactory returns a function which builds the configuration of
+// `skeema diff` for the supplied environment, as needed by the server. Global
+// options supplied on the command-line of serveCfg, such as user or password,
+// are carried over.
+func diffConfigFactory(serveCfg *mybase.Config) func(environment string) (*mybase.Config, error) {
+	globalOptions := CommandSuite.Options()
+	return func(environment string) (*mybase.Config, error) {
+		cfg, err := mybase.ParseCLI(CommandSuite, []string{"skeema", "diff", "--", environment})
+		if err != nil {
+			return nil, err
+		}
+		cfg.IsTest = serveCfg.IsTest
+		for name, value := range serveCfg.CLI.OptionValues {
+			if opt := serveCfg.FindOption(name); opt != nil && opt == globalOptions[name] {
+				cfg.CLI.OptionValues[name] = value
+			}
+		}
+		util.AddGlobalConfigFiles(cfg)
+		cfg.CLI.OptionValues["dry-run"] = "1"
+		cfg.CLI.OptionValues["brief"] = "0"
+		cfg.CLI.OptionValues["script-out"] = ""
+		cfg.MarkDirty()
+		return cfg, nil
+	}
+}
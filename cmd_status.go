@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	summary := "Display the database servers used by a directory tree"
+	desc := `Connects to each database server that a directory (by default the current
+directory) and its subdirectories map to, and displays the server's version, or
+the error encountered when connecting. Each server is only displayed once, along
+with the directories which map to it.
+
+With --variables, a snapshot of server variables is also displayed for each
+server: version, sql_mode, character_set_server, collation_server, and
+innodb_version. These are the same variables recorded for each target in plan
+files written by ` + "`skeema plan`" + `.
+
+With --schema-states, each schema that the directories map to on each server is
+also displayed, along with whether it is missing, exists but has no tables, or
+exists and has tables. An empty schema may indicate that its tables were dropped
+unexpectedly.
+
+With --format=json, the output is a JSON array with one object per server.
+
+You may optionally pass an environment name as a CLI arg. This affects which
+section of .skeema config files is used. If no environment name is supplied,
+the default is "production".`
+
+	cmd := mybase.NewCommand("status", summary, desc, StatusHandler)
+	cmd.AddOption(mybase.StringOption("dir", 'd', ".", "Directory to display the database servers of"))
+	cmd.AddOption(mybase.BoolOption("variables", 0, false, "Display a snapshot of server variables for each database server"))
+	cmd.AddOption(mybase.BoolOption("schema-states", 0, false, "Display whether each schema exists on each database server and has any tables"))
+	cmd.AddOption(mybase.StringOption("format", 0, "text", `Output format (valid values: "text", "json")`))
+	cmd.AddArg("environment", "production", false)
+	CommandSuite.AddSubCommand(cmd)
+}
+
+// statusHost tracks a database server displayed by `skeema status`, along with
+// the dirs which map to it.
+type statusHost struct {
+	Instance  string              `json:"instance"`
+	Version   string              `json:"version,omitempty"`
+	Error     string              `json:"error,omitempty"`
+	Dirs      []string            `json:"dirs"`
+	Variables *applier.ServerInfo `json:"variables,omitempty"`
+	Schemas   []statusSchema      `json:"schemas,omitempty"`
+	instance  *tengo.Instance
+	fsDirs    []*fs.Dir
+}
+
+// statusSchema describes the state of a single schema on a database server,
+// as displayed by `skeema status --schema-states`.
+type statusSchema struct {
+	Dir        string              `json:"dir"`
+	Schema     string              `json:"schema"`
+	State      applier.SchemaState `json:"state,omitempty"`
+	TableCount int                 `json:"tables"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// String returns a human-readable description of ss.
+func (ss statusSchema) String() string {
+	var desc string
+	switch {
+	case ss.Error != "":
+		desc = "unable to determine state: " + ss.Error
+	case ss.State == applier.SchemaMissing:
+		desc = "does not exist"
+	case ss.State == applier.SchemaEmpty:
+		desc = "exists but has no tables"
+	default:
+		desc = "exists with " + countAndNoun(ss.TableCount, "table", "tables")
+	}
+	return fmt.Sprintf("schema %s (%s): %s", ss.Schema, ss.Dir, desc)
+}
+
+// StatusHandler is the handler method for `skeema status`
+func StatusHandler(cfg *mybase.Config) error {
+	format, err := cfg.GetEnum("format", "text", "json")
+	if err != nil {
+		return NewExitValue(CodeBadConfig, err.Error())
+	}
+	dir, err := existingDirForEnv(cfg)
+	if err != nil {
+		return err
+	}
+	hosts := []*statusHost{}
+	skipCount := statusHosts(dir, &hosts, make(map[string]*statusHost))
+	if len(hosts) == 0 && skipCount == 0 {
+		log.Warnf("No database servers are configured for %s or its subdirectories", dir)
+	}
+	for _, host := range hosts {
+		info, err := applier.ServerInfoForInstance(host.instance)
+		if err != nil {
+			host.Error = "unable to connect: " + err.Error()
+			skipCount++
+			continue
+		}
+		host.Version = info.Version
+		if cfg.GetBool("variables") {
+			host.Variables = info
+		}
+		if cfg.GetBool("schema-states") {
+			skipCount += host.populateSchemas()
+		}
+	}
+
+	if format == "json" {
+		b, err := json.MarshalIndent(hosts, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		for _, host := range hosts {
+			printStatusHost(host)
+		}
+	}
+	if skipCount > 0 {
+		return NewExitValue(CodePartialError, "Unable to obtain status of %s", countAndNoun(skipCount, "database server or directory", "database servers or directories"))
+	}
+	return nil
+}
+
+// statusHosts recursively appends the database servers that dir and its
+// subdirectories map to onto hosts, using seen to track servers already
+// found. A count of dirs that could not be evaluated is returned.
+func statusHosts(dir *fs.Dir, hosts *[]*statusHost, seen map[string]*statusHost) (skipCount int) {
+	if dir.ParseError != nil {
+		log.Warnf("Skipping %s: %s", dir, dir.ParseError)
+		return 1
+	}
+	if dir.HasSchema() && dir.Config.Changed("host") {
+		instances, err := dir.Instances()
+		if err != nil {
+			log.Warnf("Skipping %s: %s", dir, err)
+			skipCount++
+		}
+		for _, inst := range instances {
+			host := seen[inst.String()]
+			if host == nil {
+				host = &statusHost{Instance: inst.String(), instance: inst}
+				seen[inst.String()] = host
+				*hosts = append(*hosts, host)
+			}
+			host.Dirs = append(host.Dirs, dir.RelPath())
+			host.fsDirs = append(host.fsDirs, dir)
+		}
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		log.Warnf("Cannot list subdirs of %s: %s", dir, err)
+		return skipCount + 1
+	}
+	for _, sub := range subdirs {
+		skipCount += statusHosts(sub, hosts, seen)
+	}
+	return skipCount
+}
+
+// populateSchemas determines the state of each schema that host's dirs map to
+// on host. A count of schemas whose state could not be determined is returned.
+func (host *statusHost) populateSchemas() (skipCount int) {
+	for _, dir := range host.fsDirs {
+		schemaNames, err := dir.SchemaNames(host.instance)
+		if err != nil {
+			host.Schemas = append(host.Schemas, statusSchema{Dir: dir.RelPath(), Schema: dir.Config.Get("schema"), Error: err.Error()})
+			skipCount++
+			continue
+		}
+		for _, name := range schemaNames {
+			ss := statusSchema{Dir: dir.RelPath(), Schema: name}
+			if ss.State, ss.TableCount, err = applier.SchemaStateForInstance(host.instance, name); err != nil {
+				ss.Error = err.Error()
+				skipCount++
+			}
+			host.Schemas = append(host.Schemas, ss)
+		}
+	}
+	return skipCount
+}
+
+// printStatusHost outputs host in human-readable form.
+func printStatusHost(host *statusHost) {
+	if host.Error != "" {
+		fmt.Printf("%s: %s (dirs: %s)\n", host.Instance, host.Error, strings.Join(host.Dirs, ", "))
+		return
+	}
+	fmt.Printf("%s: version %s (dirs: %s)\n", host.Instance, host.Version, strings.Join(host.Dirs, ", "))
+	if info := host.Variables; info != nil {
+		fmt.Printf("  version:              %s\n", info.Version)
+		fmt.Printf("  sql_mode:             %s\n", info.SQLMode)
+		fmt.Printf("  character_set_server: %s\n", info.CharSet)
+		fmt.Printf("  collation_server:     %s\n", info.Collation)
+		fmt.Printf("  innodb_version:       %s\n", info.InnoDBVersion)
+	}
+	for _, ss := range host.Schemas {
+		fmt.Printf("  %s\n", ss)
+	}
+}
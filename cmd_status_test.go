@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+func TestStatusHandler(t *testing.T) {
+	base := "testdata/.scratch/status"
+	fs.WriteTestFile(t, base+"/mydb/.skeema", "host=127.0.0.1\nport=1\n")
+	fs.WriteTestFile(t, base+"/mydb/product/.skeema", "schema=product\n")
+	fs.WriteTestFile(t, base+"/mydb/analytics/.skeema", "schema=analytics\n")
+	fs.WriteTestFile(t, base+"/unconfigured/.skeema", "schema=other\n")
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+
+	outPath := filepath.Join(base, "status.out")
+	outFile, err := os.Create(outPath)
+	if err != nil {
+		t.Fatalf("Unable to redirect stdout to a file: %s", err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = outFile
+	cfg := mybase.ParseFakeCLI(t, CommandSuite, "skeema status --variables --connect-options='timeout=10ms' --dir="+base)
+	err = cfg.HandleCommand()
+	outFile.Close()
+	os.Stdout = oldStdout
+
+	// The host cannot be connected to, so status is a partial error, and the
+	// host is only displayed once for both dirs which map to it
+	if ExitCode(err) != CodePartialError {
+		t.Errorf("Expected exit code %d, instead found %d: %v", CodePartialError, ExitCode(err), err)
+	}
+	output := fs.ReadTestFile(t, outPath)
+	if strings.Count(output, "127.0.0.1:1: unable to connect") != 1 || !strings.Contains(output, "mydb/analytics, ") || !strings.Contains(output, "mydb/product)") {
+		t.Errorf("Unexpected output from status:\n%s", output)
+	}
+
+	// JSON output includes the same information, with the connection error
+	// recorded in the host's error field
+	outFile, err = os.Create(outPath)
+	if err != nil {
+		t.Fatalf("Unable to redirect stdout to a file: %s", err)
+	}
+	os.Stdout = outFile
+	cfg = mybase.ParseFakeCLI(t, CommandSuite, "skeema status --format=json --schema-states --connect-options='timeout=10ms' --dir="+base)
+	err = cfg.HandleCommand()
+	outFile.Close()
+	os.Stdout = oldStdout
+	if ExitCode(err) != CodePartialError {
+		t.Errorf("Expected exit code %d, instead found %d: %v", CodePartialError, ExitCode(err), err)
+	}
+	var hosts []statusHost
+	if err := json.Unmarshal([]byte(fs.ReadTestFile(t, outPath)), &hosts); err != nil {
+		t.Fatalf("Unable to parse JSON output of status: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].Instance != "127.0.0.1:1" || !strings.HasPrefix(hosts[0].Error, "unable to connect") || len(hosts[0].Dirs) != 2 || len(hosts[0].Schemas) != 0 {
+		t.Errorf("Unexpected JSON output from status: %+v", hosts)
+	}
+
+	cfg = mybase.ParseFakeCLI(t, CommandSuite, "skeema status --format=yaml --dir="+base)
+	if err := cfg.HandleCommand(); ExitCode(err) != CodeBadConfig {
+		t.Errorf("Expected exit code %d, instead found %d: %v", CodeBadConfig, ExitCode(err), err)
+	}
+
+	cfg = mybase.ParseFakeCLI(t, CommandSuite, "skeema status --dir="+base+"/nonexistent")
+	if err := cfg.HandleCommand(); ExitCode(err) != CodeBadConfig {
+		t.Errorf("Expected exit code %d, instead found %d: %v", CodeBadConfig, ExitCode(err), err)
+	}
+}
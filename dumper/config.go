@@ -1,6 +1,7 @@
 package dumper
 
 import (
+	"path/filepath"
 	"regexp"
 
 	"github.com/skeema/tengo"
@@ -8,12 +9,16 @@ import (
 
 // Options controls dumper behavior.
 type Options struct {
-	IncludeAutoInc     bool                     // if false, strip AUTO_INCREMENT clauses from CREATE TABLE
-	RetainPartitioning bool                     // if true, and fs stmt has partitioning, but db doesn't, retain fs partitioning clause
-	CountOnly          bool                     // if true, skip writing files, just report count of rewrites
-	IgnoreTable        *regexp.Regexp           // skip tables with names matching this regex
-	skipKeys           map[tengo.ObjectKey]bool // skip objects with true values
-	onlyKeys           map[tengo.ObjectKey]bool // if map is non-nil, only format objects with true values
+	IncludeAutoInc       bool                     // if false, strip AUTO_INCREMENT clauses from CREATE TABLE
+	RetainPartitioning   bool                     // if true, and fs stmt has partitioning, but db doesn't, retain fs partitioning clause
+	CountOnly            bool                     // if true, skip writing files, just report count of rewrites
+	SkipDelimiters       bool                     // if true, don't wrap new multi-statement routines in DELIMITER commands when written to their own file
+	IgnoreTable          *regexp.Regexp           // skip tables with names matching this regex
+	CombineTables        []string                 // glob patterns of table names to write to CombinedFile, if new
+	CombinedFile         string                   // file name (without dir) for new tables matching CombineTables
+	EncodeCaseCollisions bool                     // if true, percent-encode uppercase letters in new file names that would otherwise differ only by letter case
+	skipKeys             map[tengo.ObjectKey]bool // skip objects with true values
+	onlyKeys             map[tengo.ObjectKey]bool // if map is non-nil, only format objects with true values
 }
 
 // OnlyKeys specifies a list of tengo.ObjectKeys that the dump should
@@ -55,3 +60,18 @@ func (opts *Options) shouldIgnore(key tengo.ObjectKey) bool {
 	}
 	return false
 }
+
+// shouldCombine returns true if the option configuration indicates the
+// supplied tengo.ObjectKey should be written to the combined file, if it does
+// not already exist in the filesystem.
+func (opts *Options) shouldCombine(key tengo.ObjectKey) bool {
+	if key.Type != tengo.ObjectTypeTable || opts.CombinedFile == "" {
+		return false
+	}
+	for _, pattern := range opts.CombineTables {
+		if matched, _ := filepath.Match(pattern, key.Name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -58,3 +58,25 @@ func TestOptionsIgnore(t *testing.T) {
 	assertIgnore(tengo.ObjectTypeTable, "horses", true)
 	assertIgnore(tengo.ObjectTypeTable, "dogs", false)
 }
+
+func TestOptionsCombine(t *testing.T) {
+	opts := Options{
+		CombineTables: []string{"lookup_*", "states"},
+		CombinedFile:  "lookups.sql",
+	}
+	cases := map[tengo.ObjectKey]bool{
+		{Type: tengo.ObjectTypeTable, Name: "lookup_colors"}: true,
+		{Type: tengo.ObjectTypeTable, Name: "states"}:        true,
+		{Type: tengo.ObjectTypeTable, Name: "states2"}:       false,
+		{Type: tengo.ObjectTypeProc, Name: "lookup_proc"}:    false,
+	}
+	for key, expected := range cases {
+		if actual := opts.shouldCombine(key); actual != expected {
+			t.Errorf("Unexpected result from shouldCombine(%s): expected %t, found %t", key, expected, actual)
+		}
+	}
+	opts.CombinedFile = ""
+	if opts.shouldCombine(tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "states"}) {
+		t.Error("Expected shouldCombine to always return false with blank CombinedFile")
+	}
+}
@@ -6,9 +6,14 @@ package dumper
 
 import (
 	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -27,7 +32,25 @@ type statement struct {
 // is true, no actual filesystem writes occur, but a count is still returned.
 func DumpSchema(schema *tengo.Schema, dir *fs.Dir, opts Options) (count int, err error) {
 	filesToRewrite := make(map[*fs.TokenizedSQLFile]bool)
-	for key, s := range getStatementMap(schema, dir, opts) {
+	var combinedFile *fs.TokenizedSQLFile
+	statementMap := getStatementMap(schema, dir, opts)
+	if dir.Template != nil {
+		var templateChanged bool
+		if count, templateChanged, err = applyTemplate(statementMap, dir, opts); err != nil {
+			return count, err
+		} else if templateChanged {
+			filesToRewrite[dir.Template.Statement.FromFile] = true
+		}
+	}
+	newPaths, err := newObjectPaths(statementMap, dir, opts)
+	if err != nil {
+		return count, err
+	}
+	newFileCounts := make(map[string]int, len(newPaths))
+	for _, filePath := range newPaths {
+		newFileCounts[filePath]++
+	}
+	for key, s := range statementMap {
 		if opts.shouldIgnore(key) || s.canonicalCreate == s.filesystemCreate {
 			continue
 		}
@@ -40,12 +63,26 @@ func DumpSchema(schema *tengo.Schema, dir *fs.Dir, opts Options) (count int, err
 			continue
 		}
 
-		if s.fsStatement == nil { // exists in live db schema but not yet in filesystem
+		if s.fsStatement == nil && opts.shouldCombine(key) { // new table, to be added to the combined file
+			if combinedFile == nil {
+				if combinedFile, err = getCombinedFile(dir, opts.CombinedFile); err != nil {
+					return count, err
+				}
+			}
+			combinedFile.InsertSorted(key, fs.AddDelimiter(s.canonicalCreate))
+			filesToRewrite[combinedFile] = true
+		} else if s.fsStatement == nil { // exists in live db schema but not yet in filesystem
+			filePath := newPaths[key]
 			contents := fs.AddDelimiter(s.canonicalCreate)
-			filePath := fs.PathForObject(dir.Path, key.Name)
+			if opts.SkipDelimiters && newFileCounts[filePath] == 1 {
+				if _, err := os.Stat(filePath); os.IsNotExist(err) {
+					contents = fs.AddSemicolon(s.canonicalCreate)
+				}
+			}
 			if err := appendToFile(filePath, contents); err != nil {
 				return count, err
 			}
+			dir.Snapshot.Forget(filePath)
 		} else if s.canonicalCreate == "" { // already exists in filesystem, but does not exist in live db schema
 			s.fsStatement.Remove()
 		} else { // exists in live db schema AND filesystem, but needs reformat/update
@@ -59,6 +96,8 @@ func DumpSchema(schema *tengo.Schema, dir *fs.Dir, opts Options) (count int, err
 			log.Infof("File %s requires formatting changes", file)
 		} else if err := rewriteSQLFile(file); err != nil {
 			return count, err
+		} else {
+			dir.Snapshot.Forget(file.Path())
 		}
 	}
 
@@ -123,6 +162,241 @@ func getStatementMap(schema *tengo.Schema, dir *fs.Dir, opts Options) map[tengo.
 	return statementMap
 }
 
+// applyTemplate reconciles the tables generated from dir's table template with
+// the live schema, and removes them from statementMap so that the rest of
+// DumpSchema does not write them to their own files. Generated tables that no
+// longer exist in the live schema are removed from the template-tables list.
+// If all generated tables changed in the same way, the template's CREATE
+// statement is updated to match, and the returned bool is true to indicate its
+// file must be rewritten. Otherwise, any generated tables which diverge from
+// the template are removed from the list and put back into statementMap as new
+// objects, so that they get their own files. New tables in the live schema
+// which exactly match the template are added to the list, instead of being
+// written to files. The returned count reflects the number of changes made.
+func applyTemplate(statementMap map[tengo.ObjectKey]statement, dir *fs.Dir, opts Options) (count int, templateChanged bool, err error) {
+	tt := dir.Template
+	templateKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: tt.Name()}
+	if s, ok := statementMap[templateKey]; ok {
+		if s.canonicalCreate != "" && !opts.shouldIgnore(templateKey) {
+			log.Warnf("Skipping %s: its name conflicts with the table-template in %s", templateKey, dir.OptionFile.Path())
+		}
+		delete(statementMap, templateKey)
+	}
+
+	templateBody, templateDelim := tt.Statement.SplitTextBody()
+	tables := make([]string, 0, len(tt.Tables))
+	diverged := make(map[string]string)
+	var divergedNames []string
+	for _, name := range tt.Tables {
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: name}
+		s := statementMap[key]
+		if opts.shouldIgnore(key) {
+			tables = append(tables, name)
+			continue
+		}
+		delete(statementMap, key)
+		if s.canonicalCreate == "" {
+			count++
+			continue
+		}
+		body := fs.RenameCreateTable(s.canonicalCreate, tt.Name())
+		if body == templateBody {
+			tables = append(tables, name)
+		} else {
+			diverged[name] = s.canonicalCreate
+			divergedNames = append(divergedNames, name)
+		}
+	}
+
+	// If every remaining generated table changed identically, the template
+	// itself changed. Otherwise, diverging tables are detached from the template.
+	if len(divergedNames) > 0 && len(tables) == 0 {
+		newBody := fs.RenameCreateTable(diverged[divergedNames[0]], tt.Name())
+		allSame := true
+		for _, name := range divergedNames[1:] {
+			if fs.RenameCreateTable(diverged[name], tt.Name()) != newBody {
+				allSame = false
+				break
+			}
+		}
+		if allSame {
+			count++
+			templateChanged = true
+			templateBody = newBody
+			if !opts.CountOnly {
+				tt.Statement.Text = fmt.Sprintf("%s%s", newBody, templateDelim)
+			}
+			tables = divergedNames
+			divergedNames = nil
+		}
+	}
+	for _, name := range divergedNames {
+		log.Infof("Table %s no longer matches table-template %s, so it will be written to its own file", tengo.EscapeIdentifier(name), tengo.EscapeIdentifier(tt.Name()))
+		key := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: name}
+		statementMap[key] = statement{canonicalCreate: diverged[name]}
+	}
+
+	// Add any new tables which match the template exactly
+	newKeys := make([]tengo.ObjectKey, 0)
+	for key, s := range statementMap {
+		if key.Type == tengo.ObjectTypeTable && s.fsStatement == nil && s.canonicalCreate != "" && !opts.shouldIgnore(key) && !tt.Has(key.Name) {
+			if fs.RenameCreateTable(s.canonicalCreate, tt.Name()) == templateBody {
+				newKeys = append(newKeys, key)
+			}
+		}
+	}
+	sort.Slice(newKeys, func(i, j int) bool { return newKeys[i].Name < newKeys[j].Name })
+	for _, key := range newKeys {
+		tables = append(tables, key.Name)
+		delete(statementMap, key)
+	}
+
+	if len(tables) == len(tt.Tables) && len(newKeys) == 0 {
+		return count, templateChanged, nil
+	}
+	count += len(newKeys) // detached tables are counted later, as new objects
+	if opts.CountOnly {
+		log.Infof("File %s requires changes to template-tables", dir.OptionFile.Path())
+		return count, templateChanged, nil
+	}
+	tt.Tables = tables
+	return count, templateChanged, writeTemplateTables(dir)
+}
+
+// writeTemplateTables updates the template-tables option in dir's option file
+// to match dir.Template.Tables, and rewrites the file.
+func writeTemplateTables(dir *fs.Dir) error {
+	var section string
+	if dir.Config.FindOption("environment") != nil {
+		for _, name := range dir.OptionFile.SectionsWithOption("template-tables") {
+			if name == dir.Config.Get("environment") {
+				section = name
+			}
+		}
+	}
+	if err := util.CheckEncryptedOptions(dir.OptionFile, dir.Config, "template-tables"); err != nil {
+		return err
+	}
+	if len(dir.Template.Tables) == 0 {
+		dir.OptionFile.UnsetOptionValue(section, "template-tables")
+	} else {
+		dir.OptionFile.SetOptionValue(section, "template-tables", strings.Join(dir.Template.Tables, ","))
+	}
+	if err := dir.OptionFile.Write(true); err != nil {
+		return err
+	}
+	dir.Snapshot.Forget(dir.OptionFile.Path())
+	log.Infof("Wrote %s -- updated template-tables", dir.OptionFile.Path())
+	return nil
+}
+
+// newObjectPaths returns a map of object key to file path, for objects which
+// DumpSchema will append to their own file because they exist in schema but
+// not yet in the filesystem. If any of these paths differ only in letter case
+// from each other or from an existing *.sql file in dir, a
+// *FileNameCollisionError is returned, since the files could not coexist on a
+// case-insensitive filesystem. However, if opts.EncodeCaseCollisions is true,
+// the colliding objects use fs.CaseSafePathForObject instead, and an error is
+// only returned if this does not resolve the collision.
+func newObjectPaths(statementMap map[tengo.ObjectKey]statement, dir *fs.Dir, opts Options) (map[tengo.ObjectKey]string, error) {
+	paths := make(map[tengo.ObjectKey]string)
+	for key, s := range statementMap {
+		if s.fsStatement == nil && s.canonicalCreate != "" && !opts.shouldIgnore(key) && !opts.shouldCombine(key) {
+			paths[key] = fs.PathForObject(dir.Path, key.Name)
+		}
+	}
+	collisions := findCaseCollisions(paths, dir)
+	if len(collisions) > 0 && opts.EncodeCaseCollisions {
+		for key := range collisions {
+			paths[key] = fs.CaseSafePathForObject(dir.Path, key.Name)
+		}
+		collisions = findCaseCollisions(paths, dir)
+	}
+	if len(collisions) > 0 {
+		return nil, &FileNameCollisionError{Objects: collisions, existing: dir.SQLFiles}
+	}
+	for key, filePath := range paths {
+		for _, problem := range fs.PathProblems(filePath, dir.RepoBase()) {
+			log.Warnf("File for %s may not be usable on all operating systems: %s", key, problem)
+		}
+	}
+	return paths, nil
+}
+
+// findCaseCollisions returns the subset of paths whose values differ only in
+// letter case from another value in paths, or from the path of an existing
+// *.sql file in dir.
+func findCaseCollisions(paths map[tengo.ObjectKey]string, dir *fs.Dir) map[tengo.ObjectKey]string {
+	byFolded := make(map[string]map[string]bool)
+	add := func(filePath string) {
+		folded := strings.ToLower(filePath)
+		if byFolded[folded] == nil {
+			byFolded[folded] = make(map[string]bool)
+		}
+		byFolded[folded][filePath] = true
+	}
+	for _, sf := range dir.SQLFiles {
+		add(sf.Path())
+	}
+	for _, filePath := range paths {
+		add(filePath)
+	}
+	collisions := make(map[tengo.ObjectKey]string)
+	for key, filePath := range paths {
+		if len(byFolded[strings.ToLower(filePath)]) > 1 {
+			collisions[key] = filePath
+		}
+	}
+	return collisions
+}
+
+// FileNameCollisionError is returned by DumpSchema if new objects would be
+// written to files whose names differ only in letter case, either from each
+// other or from existing *.sql files.
+type FileNameCollisionError struct {
+	Objects  map[tengo.ObjectKey]string // colliding objects -> file path they would be written to
+	existing []fs.SQLFile
+}
+
+// Error satisfies the builtin error interface.
+func (fce *FileNameCollisionError) Error() string {
+	descriptions := make([]string, 0, len(fce.Objects))
+	folded := make(map[string]bool)
+	for key, filePath := range fce.Objects {
+		descriptions = append(descriptions, fmt.Sprintf("%s (%s)", key, path.Base(filePath)))
+		folded[strings.ToLower(filePath)] = true
+	}
+	for _, sf := range fce.existing {
+		if folded[strings.ToLower(sf.Path())] {
+			descriptions = append(descriptions, fmt.Sprintf("existing file %s", sf.FileName))
+		}
+	}
+	sort.Strings(descriptions)
+	return fmt.Sprintf("file names would differ only in letter case, which is unsafe on case-insensitive filesystems: %s", strings.Join(descriptions, ", "))
+}
+
+// getCombinedFile returns a TokenizedSQLFile for the file in dir with the
+// supplied name. If any statements in dir were already parsed from this file,
+// their TokenizedSQLFile is returned, so that any other modifications to those
+// statements are retained. Otherwise, the file is tokenized from scratch if it
+// exists, or a new empty TokenizedSQLFile is returned if not.
+func getCombinedFile(dir *fs.Dir, fileName string) (*fs.TokenizedSQLFile, error) {
+	for _, logicalSchema := range dir.LogicalSchemas {
+		for _, stmt := range logicalSchema.Creates {
+			if stmt.FromFile != nil && stmt.FromFile.FileName == fileName {
+				return stmt.FromFile, nil
+			}
+		}
+	}
+	sf := fs.SQLFile{Dir: dir.Path, FileName: fileName}
+	if exists, err := sf.Exists(); err != nil {
+		return nil, err
+	} else if exists {
+		return sf.Tokenize()
+	}
+	return fs.NewTokenizedSQLFile(sf, nil), nil
+}
+
 // appendToFile appends contents to filePath.
 func appendToFile(filePath, contents string) error {
 	if bytesWritten, wasNew, err := fs.AppendToFile(filePath, contents); err != nil {
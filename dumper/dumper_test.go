@@ -2,6 +2,7 @@ package dumper
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/testutil"
 	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
@@ -25,22 +27,10 @@ func TestMain(m *testing.M) {
 }
 
 func TestIntegration(t *testing.T) {
-	images := tengo.SplitEnv("SKEEMA_TEST_IMAGES")
-	if len(images) == 0 {
-		fmt.Println("SKEEMA_TEST_IMAGES env var is not set, so integration tests will be skipped!")
-		fmt.Println("To run integration tests, you may set SKEEMA_TEST_IMAGES to a comma-separated")
-		fmt.Println("list of Docker images. Example:\n# SKEEMA_TEST_IMAGES=\"mysql:5.6,mysql:5.7\" go test")
-	}
-	manager, err := tengo.NewDockerClient(tengo.DockerClientOptions{})
-	if err != nil {
-		t.Errorf("Unable to create sandbox manager: %s", err)
-	}
-	suite := &IntegrationSuite{manager: manager}
-	tengo.RunSuite(suite, t, images)
+	testutil.RunSuite(t, &IntegrationSuite{})
 }
 
 type IntegrationSuite struct {
-	manager         *tengo.DockerClient
 	d               *tengo.DockerizedInstance
 	schema          *tengo.Schema
 	scratchDir      *fs.Dir
@@ -122,11 +112,7 @@ func (s IntegrationSuite) TestFormatPull(t *testing.T) {
 }
 
 func (s *IntegrationSuite) Setup(backend string) (err error) {
-	s.d, err = s.manager.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
-		Name:         fmt.Sprintf("skeema-test-%s", strings.Replace(backend, ":", "-", -1)),
-		Image:        backend,
-		RootPassword: "fakepw",
-	})
+	s.d, err = testutil.DockerInstance(backend, "")
 	return err
 }
 
@@ -247,8 +233,275 @@ func (s *IntegrationSuite) verifyFormat(t *testing.T) {
 func getDir(dirPath string) (*fs.Dir, error) {
 	cmd := mybase.NewCommand("dumpertest", "", "", nil)
 	util.AddGlobalOptions(cmd)
+	cmd.AddArg("environment", "production", false)
 	cfg := &mybase.Config{
 		CLI: &mybase.CommandLine{Command: cmd},
 	}
 	return fs.ParseDir(dirPath, cfg)
 }
+
+func TestDumpSchemaCombineTables(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "skeema-dumper-combine")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	createFor := func(name string) string {
+		return fmt.Sprintf("CREATE TABLE `%s` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1", name)
+	}
+	fs.WriteTestFile(t, filepath.Join(dirPath, "lookups.sql"), "-- lookup tables\n"+fs.AddDelimiter(createFor("currencies"))+fs.AddDelimiter(createFor("states")))
+	fs.WriteTestFile(t, filepath.Join(dirPath, "users.sql"), fs.AddDelimiter(createFor("users")))
+	dir, err := getDir(dirPath)
+	if err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+
+	schema := &tengo.Schema{Name: "product"}
+	for _, name := range []string{"users", "currencies", "states", "countries", "zones", "orders"} {
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name, CreateStatement: createFor(name)})
+	}
+	opts := Options{
+		CombineTables: []string{"c*", "states", "zones"},
+		CombinedFile:  "lookups.sql",
+	}
+	if count, err := DumpSchema(schema, dir, opts); count != 3 || err != nil {
+		t.Errorf("Expected DumpSchema to return (3, nil); instead found (%d, %v)", count, err)
+	}
+
+	expected := "-- lookup tables\n"
+	for _, name := range []string{"countries", "currencies", "states", "zones"} {
+		expected += fs.AddDelimiter(createFor(name))
+	}
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "lookups.sql")); actual != expected {
+		t.Errorf("Unexpected contents of combined file:\n%s", actual)
+	}
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "orders.sql")); actual != fs.AddDelimiter(createFor("orders")) {
+		t.Errorf("Unexpected contents of orders.sql:\n%s", actual)
+	}
+
+	// Combined file should be created if it does not already exist
+	fs.RemoveTestFile(t, filepath.Join(dirPath, "lookups.sql"))
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+	if count, err := DumpSchema(schema, dir, opts); count != 4 || err != nil {
+		t.Errorf("Expected DumpSchema to return (4, nil); instead found (%d, %v)", count, err)
+	}
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "lookups.sql")); actual != strings.TrimPrefix(expected, "-- lookup tables\n") {
+		t.Errorf("Unexpected contents of combined file:\n%s", actual)
+	}
+}
+
+func TestDumpSchemaSkipDelimiters(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "skeema-dumper-delimiters")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dirPath)
+	dir, err := getDir(dirPath)
+	if err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+
+	procFor := func(name string) string {
+		return fmt.Sprintf("CREATE DEFINER=`root`@`%%` PROCEDURE `%s`()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND", name)
+	}
+	tableCreate := "CREATE TABLE `dupe` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"
+	schema := &tengo.Schema{
+		Name:   "product",
+		Tables: []*tengo.Table{{Name: "dupe", CreateStatement: tableCreate}},
+		Routines: []*tengo.Routine{
+			{Name: "whatever", Type: tengo.ObjectTypeProc, CreateStatement: procFor("whatever")},
+			{Name: "dupe", Type: tengo.ObjectTypeProc, CreateStatement: procFor("dupe")},
+		},
+	}
+	opts := Options{SkipDelimiters: true}
+	if count, err := DumpSchema(schema, dir, opts); count != 3 || err != nil {
+		t.Errorf("Expected DumpSchema to return (3, nil); instead found (%d, %v)", count, err)
+	}
+
+	// Routine in its own file should lack DELIMITER commands, but a routine
+	// sharing a file with another object still requires them
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "whatever.sql")); actual != fs.AddSemicolon(procFor("whatever")) {
+		t.Errorf("Unexpected contents of whatever.sql:\n%s", actual)
+	}
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "dupe.sql")); !strings.Contains(actual, fs.AddDelimiter(procFor("dupe"))) {
+		t.Errorf("Unexpected contents of dupe.sql:\n%s", actual)
+	}
+
+	// Re-reading the files should not result in any differences, regardless of
+	// whether DELIMITER commands were used
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+	for _, skip := range []bool{true, false} {
+		opts.SkipDelimiters = skip
+		if count, err := DumpSchema(schema, dir, opts); count != 0 || err != nil {
+			t.Errorf("Expected DumpSchema to return (0, nil) with SkipDelimiters=%t; instead found (%d, %v)", skip, count, err)
+		}
+	}
+}
+
+func TestDumpSchemaHostileIdentifiers(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "skeema-dumper-hostile")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dirPath)
+	dir, err := getDir(dirPath)
+	if err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+
+	names := []string{"order", "my table", "emoji😀", "back`tick", `a/b\c:d*e?f"g<h>i|j`, "..", "100%", "con"}
+	schema := &tengo.Schema{Name: "product"}
+	for _, name := range names {
+		create := fmt.Sprintf("CREATE TABLE %s (\n  `group` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1", tengo.EscapeIdentifier(name))
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name, CreateStatement: create})
+	}
+	if count, err := DumpSchema(schema, dir, Options{}); count != len(names) || err != nil {
+		t.Fatalf("Expected DumpSchema to return (%d, nil); instead found (%d, %v)", len(names), count, err)
+	}
+
+	// Each table should be in its own file directly in dirPath, and re-reading
+	// the dir should not result in any differences
+	for _, name := range names {
+		filePath := fs.PathForObject(dirPath, name)
+		if filepath.Dir(filePath) != filepath.Clean(dirPath) {
+			t.Errorf("Table %q unexpectedly written outside of dir: %s", name, filePath)
+		} else if decoded, ok := fs.ObjectNameForPath(filePath); !ok || decoded != name {
+			t.Errorf("File name %s unexpectedly decoded to %q, %t", filePath, decoded, ok)
+		}
+	}
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+	if creates := dir.LogicalSchemas[0].Creates; len(creates) != len(names) {
+		t.Errorf("Expected %d CREATEs after re-reading dir, instead found %d", len(names), len(creates))
+	}
+	if count, err := DumpSchema(schema, dir, Options{}); count != 0 || err != nil {
+		t.Errorf("Expected DumpSchema to return (0, nil) after re-reading dir; instead found (%d, %v)", count, err)
+	}
+}
+
+func TestDumpSchemaCaseCollisions(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "skeema-dumper-case")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dirPath)
+	dir, err := getDir(dirPath)
+	if err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+
+	schema := &tengo.Schema{Name: "product"}
+	for _, name := range []string{"Users", "users", "posts"} {
+		create := fmt.Sprintf("CREATE TABLE %s (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1", tengo.EscapeIdentifier(name))
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name, CreateStatement: create})
+	}
+
+	// Without encoding, collisions are an error, and nothing is written
+	_, err = DumpSchema(schema, dir, Options{})
+	if _, ok := err.(*FileNameCollisionError); !ok {
+		t.Fatalf("Expected DumpSchema to return a *FileNameCollisionError; instead found %v", err)
+	} else if msg := err.Error(); !strings.Contains(msg, "table `Users` (Users.sql), table `users` (users.sql)") {
+		t.Errorf("Unexpected error message: %s", msg)
+	}
+	if entries, err := ioutil.ReadDir(dirPath); err != nil || len(entries) > 0 {
+		t.Errorf("Expected no files to be written; instead found %d (err=%v)", len(entries), err)
+	}
+
+	// With encoding, only the colliding names are encoded
+	if count, err := DumpSchema(schema, dir, Options{EncodeCaseCollisions: true}); count != 3 || err != nil {
+		t.Fatalf("Expected DumpSchema to return (3, nil); instead found (%d, %v)", count, err)
+	}
+	for _, fileName := range []string{"%55sers.sql", "users.sql", "posts.sql"} {
+		if _, err := os.Stat(filepath.Join(dirPath, fileName)); err != nil {
+			t.Errorf("Expected file %s to exist, but stat returned %v", fileName, err)
+		}
+	}
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+	if creates := dir.LogicalSchemas[0].Creates; len(creates) != 3 {
+		t.Errorf("Expected 3 CREATEs after re-reading dir, instead found %d", len(creates))
+	}
+
+	// A new object colliding with an existing file can't be resolved by encoding
+	// if the new name has no uppercase letters
+	fs.WriteTestFile(t, filepath.Join(dirPath, "POSTS.sql"), "CREATE TABLE `POSTS` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1;\n")
+	fs.RemoveTestFile(t, filepath.Join(dirPath, "posts.sql"))
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+	schema.Tables = append(schema.Tables, &tengo.Table{Name: "POSTS", CreateStatement: "CREATE TABLE `POSTS` (\n  `id` int(10) unsigned NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1"})
+	_, err = DumpSchema(schema, dir, Options{EncodeCaseCollisions: true})
+	if _, ok := err.(*FileNameCollisionError); !ok || !strings.Contains(err.Error(), "existing file POSTS.sql, table `posts` (posts.sql)") {
+		t.Errorf("Expected DumpSchema to return a *FileNameCollisionError mentioning existing file; instead found %v", err)
+	}
+}
+
+func TestDumpSchemaTemplate(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "skeema-dumper-template")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dirPath)
+
+	createFor := func(name, colType string) string {
+		return fmt.Sprintf("CREATE TABLE `%s` (\n  `id` %s NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1", name, colType)
+	}
+	fs.WriteTestFile(t, filepath.Join(dirPath, ".skeema"), "schema=logs\ntable-template=logtable\ntemplate-tables=events_01,events_02,events_03\n")
+	fs.WriteTestFile(t, filepath.Join(dirPath, "logtable.sql"), fs.AddDelimiter(createFor("logtable", "int(10) unsigned")))
+	dir, err := getDir(dirPath)
+	if err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+
+	// events_03 dropped, events_04 added matching template, events_02 diverges,
+	// and a table with the template's own name is skipped
+	schema := &tengo.Schema{Name: "logs"}
+	tables := map[string]string{
+		"events_01": "int(10) unsigned",
+		"events_02": "bigint(20) unsigned",
+		"events_04": "int(10) unsigned",
+		"logtable":  "int(10) unsigned",
+	}
+	for name, colType := range tables {
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name, CreateStatement: createFor(name, colType)})
+	}
+	if count, err := DumpSchema(schema, dir, Options{}); count != 3 || err != nil {
+		t.Errorf("Expected DumpSchema to return (3, nil); instead found (%d, %v)", count, err)
+	}
+	if entries, err := ioutil.ReadDir(dirPath); err != nil || len(entries) != 3 {
+		t.Errorf("Expected 3 files in dir; instead found %d (err=%v)", len(entries), err)
+	}
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "events_02.sql")); actual != fs.AddDelimiter(createFor("events_02", "bigint(20) unsigned")) {
+		t.Errorf("Unexpected contents of events_02.sql:\n%s", actual)
+	}
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	}
+	if strings.Join(dir.Template.Tables, ",") != "events_01,events_04" {
+		t.Errorf("Unexpected template-tables after pull: %v", dir.Template.Tables)
+	}
+
+	// If all generated tables change identically, the template is updated
+	schema.Tables = nil
+	for _, name := range []string{"events_01", "events_04"} {
+		schema.Tables = append(schema.Tables, &tengo.Table{Name: name, CreateStatement: createFor(name, "bigint(20) unsigned")})
+	}
+	if count, err := DumpSchema(schema, dir, Options{}); count != 2 || err != nil {
+		t.Errorf("Expected DumpSchema to return (2, nil); instead found (%d, %v)", count, err)
+	}
+	if actual := fs.ReadTestFile(t, filepath.Join(dirPath, "logtable.sql")); actual != fs.AddDelimiter(createFor("logtable", "bigint(20) unsigned")) {
+		t.Errorf("Unexpected contents of logtable.sql:\n%s", actual)
+	}
+	if dir, err = getDir(dirPath); err != nil {
+		t.Fatalf("Unexpected error from getDir: %s", err)
+	} else if strings.Join(dir.Template.Tables, ",") != "events_01,events_04" {
+		t.Errorf("Unexpected template-tables after pull: %v", dir.Template.Tables)
+	}
+}
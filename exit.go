@@ -30,6 +30,7 @@ const (
 	CodeBadInput         = 65
 	CodeNoInput          = 66
 	CodeCantCreate       = 73
+	CodeNotPermitted     = 77
 	CodeBadConfig        = 78
 )
 
@@ -61,6 +62,47 @@ func ExitCode(err error) int {
 	return CodeFatalError
 }
 
+// DifferencesOnly returns true if err indicates that differences were found,
+// without any error occurring. By convention, such an *ExitValue has code
+// CodeDifferencesFound and a blank message, whereas partial errors sharing the
+// same code always have a message describing the problem.
+func DifferencesOnly(err error) bool {
+	ev, ok := err.(*ExitValue)
+	return ok && ev != nil && ev.Code == CodeDifferencesFound && ev.message == ""
+}
+
+// ApplyExitCodeMode remaps err, the final result of a diff, push, or plan
+// command, according to the supplied value of the exit-code-mode option.
+// With "strict" (or a blank mode), err is returned unchanged. With "zero", the
+// exit code is 0 if differences were found, but unchanged if any error
+// occurred. With "drift-only", any error is remapped to CodeDifferencesFound,
+// so that the exit code is only ever 0 or 1.
+func ApplyExitCodeMode(mode string, err error) error {
+	if err == nil {
+		return nil
+	} else if mode == "zero" && DifferencesOnly(err) {
+		return nil
+	} else if mode == "drift-only" && ExitCode(err) != CodeDifferencesFound {
+		return NewExitValue(CodeDifferencesFound, err.Error())
+	}
+	return err
+}
+
+// OutcomeLine returns a final line for human-readable output, summarizing err,
+// the final result of a diff, push, or plan command prior to any remapping by
+// ApplyExitCodeMode. The line distinguishes between no differences, differences
+// found, and errors occurring.
+func OutcomeLine(err error, dryRun bool) string {
+	if err == nil && dryRun {
+		return "Result: no differences found"
+	} else if err == nil {
+		return "Result: completed without errors"
+	} else if DifferencesOnly(err) {
+		return "Result: differences found"
+	}
+	return "Result: errors occurred"
+}
+
 // Exit terminates the program with the appropriate exit code and log output.
 func Exit(err error) {
 	exitCode := ExitCode(err)
@@ -81,6 +123,7 @@ func Exit(err error) {
 	// Gracefully close all connection pools, to avoid aborted connection counter/
 	// logging in some versions of MySQL
 	util.CloseCachedConnectionPools()
+	util.CloseSSHTunnels()
 
 	os.Exit(exitCode)
 }
@@ -32,3 +32,58 @@ func TestExitValueError(t *testing.T) {
 		t.Errorf("Found message %v, expected %v", actual, expected)
 	}
 }
+
+func TestApplyExitCodeMode(t *testing.T) {
+	differences := NewExitValue(CodeDifferencesFound, "")
+	partial := NewExitValue(CodePartialError, "Skipped 1 operation due to problem")
+	fatal := NewExitValue(CodeFatalError, "fatal")
+	badConfig := NewExitValue(CodeBadConfig, "bad config")
+	cases := []struct {
+		mode     string
+		err      error
+		expected int
+	}{
+		{"strict", nil, CodeSuccess},
+		{"strict", differences, CodeDifferencesFound},
+		{"strict", partial, CodePartialError},
+		{"strict", fatal, CodeFatalError},
+		{"strict", badConfig, CodeBadConfig},
+		{"zero", nil, CodeSuccess},
+		{"zero", differences, CodeSuccess},
+		{"zero", partial, CodePartialError},
+		{"zero", fatal, CodeFatalError},
+		{"zero", errors.New("plain error"), CodeFatalError},
+		{"drift-only", nil, CodeSuccess},
+		{"drift-only", differences, CodeDifferencesFound},
+		{"drift-only", partial, CodeDifferencesFound},
+		{"drift-only", fatal, CodeDifferencesFound},
+		{"drift-only", badConfig, CodeDifferencesFound},
+	}
+	for _, c := range cases {
+		if actual := ExitCode(ApplyExitCodeMode(c.mode, c.err)); actual != c.expected {
+			t.Errorf("ApplyExitCodeMode(%q, %v): expected exit code %d, instead found %d", c.mode, c.err, c.expected, actual)
+		}
+	}
+	if err := ApplyExitCodeMode("drift-only", fatal); err.Error() != "fatal" {
+		t.Errorf("Expected remapped error to retain its message, instead found %q", err.Error())
+	}
+}
+
+func TestOutcomeLine(t *testing.T) {
+	cases := []struct {
+		err      error
+		dryRun   bool
+		expected string
+	}{
+		{nil, true, "Result: no differences found"},
+		{nil, false, "Result: completed without errors"},
+		{NewExitValue(CodeDifferencesFound, ""), true, "Result: differences found"},
+		{NewExitValue(CodePartialError, "Skipped 1 operation due to problem"), true, "Result: errors occurred"},
+		{NewExitValue(CodeFatalError, "fatal"), false, "Result: errors occurred"},
+	}
+	for _, c := range cases {
+		if actual := OutcomeLine(c.err, c.dryRun); actual != c.expected {
+			t.Errorf("OutcomeLine(%v, %t): expected %q, instead found %q", c.err, c.dryRun, c.expected, actual)
+		}
+	}
+}
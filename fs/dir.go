@@ -2,7 +2,6 @@ package fs
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/url"
 	"os"
 	"path"
@@ -25,10 +24,17 @@ type Dir struct {
 	Config            *mybase.Config
 	OptionFile        *mybase.File
 	SQLFiles          []SQLFile
+	PassthroughFiles  []SQLFile        // *.passthrough.sql files, sorted by name; never parsed, and excluded from SQLFiles
+	GrantsFile        *SQLFile         // .grants.sql file, if present; never parsed, and excluded from SQLFiles
 	LogicalSchemas    []*LogicalSchema // for now, always 0 or 1 elements; 2+ in same dir to be supported in future
 	ParseError        error            // any fatal error found parsing dir's config or contents
 	IgnoredStatements []*Statement     // statements with unknown type / not supported by this package
+	Snapshot          *TreeSnapshot    // cached dir listings, shared with all Dirs obtained from this one
+	Template          *TableTemplate   // non-nil if dir's .skeema file configures a table-template
 	repoBase          string           // absolute path of containing repo, or topmost-found .skeema file
+	gitignore         gitignore        // patterns from .gitignore files; nil if not respected
+	baseConfig        *mybase.Config   // config prior to adding any option files from the dir tree
+	parentFiles       []*mybase.File   // option files of dir's ancestors, as originally parsed, ordered parent-most first
 }
 
 // LogicalSchema represents a set of statements from *.sql files in a directory
@@ -41,7 +47,9 @@ type LogicalSchema struct {
 	CharSet   string
 	Collation string
 	Creates   map[tengo.ObjectKey]*Statement
-	Alters    []*Statement // Alterations that are run after the Creates
+	Alters    []*Statement                   // Alterations that are run after the Creates
+	Overrides map[tengo.ObjectKey]*Statement // environment-specific statements which were applied to Creates, if any
+	shared    map[tengo.ObjectKey]*Statement // shared statements replaced by Overrides; nil values for objects only in Overrides
 }
 
 // AddStatement adds the supplied statement into the appropriate data structure
@@ -84,21 +92,28 @@ func ParseDir(dirPath string, globalConfig *mybase.Config) (*Dir, error) {
 		return nil, err
 	}
 	dir := &Dir{
-		Path:   cleaned,
-		Config: globalConfig.Clone(),
+		Path:       cleaned,
+		Snapshot:   NewTreeSnapshot(),
+		baseConfig: globalConfig,
 	}
 
 	// Apply the parent option files
-	var parentFiles []*mybase.File
-	parentFiles, dir.repoBase, err = ParentOptionFiles(dirPath, globalConfig)
+	dir.parentFiles, dir.repoBase, err = parentOptionFiles(dirPath, globalConfig, dir.Snapshot)
 	if err != nil {
 		return nil, err
 	}
-	for _, optionFile := range parentFiles {
-		dir.Config.AddSource(optionFile)
+	if dir.Config, err = configForDir(globalConfig, dir.parentFiles, cleaned); err != nil {
+		return nil, err
 	}
 
 	dir.parseContents()
+	dir.loadGitignore()
+
+	// The format-version option is defined in util.AddGlobalOptions, which some
+	// callers (such as tests) may not use
+	if dir.ParseError == nil && dir.Config.FindOption("format-version") != nil {
+		checkFormatVersion(dir)
+	}
 	return dir, dir.ParseError
 }
 
@@ -123,41 +138,280 @@ func (dir *Dir) RelPath() string {
 	return rel
 }
 
-// Delete unlinks the directory and all files within.
+// RepoBase returns the absolute path of the dir's containing repo, or of the
+// topmost-found .skeema file if the dir is not within a git repo. If this
+// cannot be determined, the dir's own Path is returned.
+func (dir *Dir) RepoBase() string {
+	if dir.repoBase == "" {
+		return dir.Path
+	}
+	return dir.repoBase
+}
+
+// Managed returns true if dir is part of a directory tree managed by Skeema:
+// a .skeema option file exists in dir, in any of its ancestors up to the repo
+// base, or in any subdirectory up to maxDepth levels below dir. If dir's
+// subdirectories cannot be listed, this method also returns true, leaving the
+// underlying problem to be reported by whatever operation is performed next.
+func (dir *Dir) Managed(maxDepth int) bool {
+	if dir.OptionFile != nil || len(dir.parentFiles) > 0 {
+		return true
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		return true
+	}
+	for _, sub := range subdirs {
+		if sub.ParseError != nil || sub.OptionFile != nil || (maxDepth > 0 && sub.Managed(maxDepth-1)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete unlinks the directory and all files within, but only if all of its
+// contents are managed by Skeema: *.sql files other than passthrough and
+// grants files, .skeema files, and subdirectories meeting the same criteria.
+// Otherwise, an UnexpectedFilesError is returned, and nothing is removed.
 func (dir *Dir) Delete() error {
+	unexpected, err := unmanagedEntries(dir.Path, "")
+	if err != nil {
+		return err
+	}
+	// The state file, if configured, is also managed by Skeema
+	if dir.Config != nil && dir.Config.FindOption("state-file") != nil {
+		if stateFile := dir.Config.Get("state-file"); stateFile != "" {
+			kept := unexpected[:0]
+			for _, p := range unexpected {
+				if p != filepath.ToSlash(filepath.Clean(stateFile)) {
+					kept = append(kept, p)
+				}
+			}
+			unexpected = kept
+		}
+	}
+	if len(unexpected) > 0 {
+		return UnexpectedFilesError{Dir: dir.Path, Paths: unexpected}
+	}
+	return dir.DeleteForce()
+}
+
+// DeleteForce unlinks the directory and all files within, regardless of
+// whether they are managed by Skeema.
+func (dir *Dir) DeleteForce() error {
+	defer dir.Snapshot.Forget(dir.Path)
 	return os.RemoveAll(dir.Path)
 }
 
-// HasFile returns true if the specified filename exists in dir.
+// sidecarFiles lists file names which may be present in a directory without
+// preventing Dir.Delete from removing it. These are metadata files created
+// automatically by operating systems.
+var sidecarFiles = map[string]bool{
+	".DS_Store":   true,
+	"Thumbs.db":   true,
+	"desktop.ini": true,
+}
+
+// unmanagedEntries recursively returns the paths, relative to the original
+// dir and in sorted order, of any entries in dirPath which are not managed by
+// Skeema. relPath is the path of dirPath relative to the original dir.
+func unmanagedEntries(dirPath, relPath string) (unexpected []string, err error) {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		entryRelPath := path.Join(relPath, name)
+		if entry.IsDir() {
+			sub, err := unmanagedEntries(filepath.Join(dirPath, name), entryRelPath)
+			if err != nil {
+				return nil, err
+			}
+			unexpected = append(unexpected, sub...)
+		} else if !entry.Type().IsRegular() || IsPassthroughFile(name) || IsGrantsFile(name) || (name != ".skeema" && !strings.HasSuffix(name, ".sql") && !sidecarFiles[name]) {
+			unexpected = append(unexpected, entryRelPath)
+		}
+	}
+	return unexpected, nil
+}
+
+// FileStatus indicates whether a file exists, as determined by
+// Dir.FileStatus.
+type FileStatus int
+
+// Constants enumerating possible FileStatus values
+const (
+	FileNotExist FileStatus = iota // no entry with the name, or entry is not a regular file
+	FileExists                     // regular file, or symlink to a regular file or nonexistent destination
+	FileDenied                     // dir or symlink destination cannot be read due to permissions
+)
+
+// FileStatus returns whether the specified filename exists in dir, as either
+// a regular file or a symlink to a regular file. Entries of any other type,
+// such as a directory, result in FileNotExist. A dangling symlink results in
+// FileExists, so that the problem is reported upon attempting to read it. If dir's listing or the
+// symlink's destination cannot be read due to insufficient permissions,
+// FileDenied is returned along with the underlying error. Other errors are
+// returned with FileNotExist.
+func (dir *Dir) FileStatus(name string) (FileStatus, error) {
+	return entryStatus(dir, name, false)
+}
+
+// HasFile returns true if the specified filename exists in dir as a regular
+// file or a symlink to a regular file. An error is returned if dir's listing
+// cannot be read.
 func (dir *Dir) HasFile(name string) (bool, error) {
-	_, err := os.Lstat(path.Join(dir.Path, name))
-	if err == nil {
-		return true, nil
-	} else if os.IsNotExist(err) {
-		return false, nil
+	status, err := dir.FileStatus(name)
+	return status == FileExists, err
+}
+
+// HasDir returns true if the specified name exists in dir as a subdirectory or
+// a symlink to a directory. An error is returned if dir's listing cannot be
+// read.
+func (dir *Dir) HasDir(name string) (bool, error) {
+	status, err := entryStatus(dir, name, true)
+	return status == FileExists, err
+}
+
+// entryStatus returns FileExists if name exists in dir and is of the wanted
+// type -- a directory if wantDir is true, or a regular file otherwise -- after
+// following any symlink.
+func entryStatus(dir *Dir, name string, wantDir bool) (FileStatus, error) {
+	entry, err := dir.Snapshot.Entry(dir.Path, name)
+	if os.IsPermission(err) {
+		return FileDenied, err
+	} else if err != nil || entry == nil {
+		return FileNotExist, err
 	}
-	return false, err
+	mode := entry.Type()
+	if mode&os.ModeSymlink != 0 {
+		fi, err := dir.Snapshot.Stat(filepath.Join(dir.Path, name))
+		if os.IsPermission(err) {
+			return FileDenied, err
+		} else if err != nil {
+			// Dangling symlinks are reported as existing files, so that callers
+			// attempting to read them surface the problem
+			return boolToFileStatus(!wantDir), nil
+		}
+		mode = fi.Mode()
+	}
+	return boolToFileStatus((wantDir && mode.IsDir()) || (!wantDir && mode.IsRegular())), nil
+}
+
+func boolToFileStatus(exists bool) FileStatus {
+	if exists {
+		return FileExists
+	}
+	return FileNotExist
 }
 
-// Subdirs reads the list of direct, non-hidden subdirectories of dir, parses
-// them (*.sql and .skeema files), and returns them. An error will be returned
-// if there are problems reading dir's the directory list. Otherwise, err is
-// nil, but some of the returned Dir values will have a non-nil ParseError if
-// any problems were encountered in that subdir.
+// DefaultIgnoredDirs lists the names of subdirectories which are skipped when
+// walking a directory tree, unless they contain a .skeema file. These
+// typically contain third-party dependencies, and can be very large. The
+// ignore-dirs option may be used to skip additional names.
+var DefaultIgnoredDirs = []string{"node_modules", "vendor"}
+
+// skipSubdir returns true if a subdirectory with the supplied name should be
+// skipped when walking a directory tree: it is hidden (dot-prefixed), or its
+// name is in extraIgnored, or its name is in DefaultIgnoredDirs and
+// hasOptionFile returns false.
+func skipSubdir(name string, extraIgnored []string, hasOptionFile func() bool) bool {
+	if name[0] == '.' {
+		return true
+	}
+	for _, ignored := range extraIgnored {
+		if name == ignored {
+			return true
+		}
+	}
+	for _, ignored := range DefaultIgnoredDirs {
+		if name == ignored {
+			return !hasOptionFile()
+		}
+	}
+	return false
+}
+
+// SkipSubdir returns true if the subdirectory with the supplied name in
+// parentPath should be skipped when walking a directory tree, using the same
+// policy as Dir.Subdirs: hidden subdirectories are always skipped, as are
+// names in extraIgnored; names in DefaultIgnoredDirs are skipped unless the
+// subdirectory contains a .skeema file. Walkers which do not otherwise parse
+// the tree should use this to remain consistent with Subdirs.
+func SkipSubdir(parentPath, name string, extraIgnored []string) bool {
+	return skipSubdir(name, extraIgnored, func() bool {
+		fi, err := os.Stat(filepath.Join(parentPath, name, ".skeema"))
+		return err == nil && fi.Mode().IsRegular()
+	})
+}
+
+// ignoredDirs returns the additional subdirectory names configured to be
+// skipped by the ignore-dirs option of dir.
+func (dir *Dir) ignoredDirs() []string {
+	if dir.Config == nil || dir.Config.FindOption("ignore-dirs") == nil {
+		return nil
+	}
+	return dir.Config.GetSlice("ignore-dirs", ',', true)
+}
+
+// Subdirs reads the list of direct subdirectories of dir, parses them (*.sql
+// and .skeema files), and returns them. Hidden (dot-prefixed) subdirectories
+// are skipped, as are subdirectories matching a .gitignore pattern, those
+// named by the ignore-dirs option, and those named in DefaultIgnoredDirs
+// which lack a .skeema file. An error will be returned if there are problems
+// reading dir's the directory list. Otherwise, err is nil, but some of the
+// returned Dir values will have a non-nil ParseError if any problems were
+// encountered in that subdir.
 func (dir *Dir) Subdirs() ([]*Dir, error) {
-	fileInfos, err := ioutil.ReadDir(dir.Path)
+	return dir.subdirs(true)
+}
+
+// SubdirsAll behaves like Subdirs, but returns every direct subdirectory of
+// dir, including hidden, ignored, and .gitignore-matched ones.
+func (dir *Dir) SubdirsAll() ([]*Dir, error) {
+	return dir.subdirs(false)
+}
+
+func (dir *Dir) subdirs(filtered bool) ([]*Dir, error) {
+	entries, err := dir.Snapshot.ReadDir(dir.Path)
 	if err != nil {
 		return nil, err
 	}
-	result := make([]*Dir, 0, len(fileInfos))
-	for _, fi := range fileInfos {
-		if fi.IsDir() && fi.Name()[0] != '.' {
+	var extraIgnored []string
+	if filtered {
+		extraIgnored = dir.ignoredDirs()
+	}
+	result := make([]*Dir, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			subPath := path.Join(dir.Path, entry.Name())
+			if filtered {
+				hasOptionFile := func() bool {
+					status, _ := entryStatus(&Dir{Path: subPath, Snapshot: dir.Snapshot}, ".skeema", false)
+					return status == FileExists
+				}
+				if skipSubdir(entry.Name(), extraIgnored, hasOptionFile) {
+					if entry.Name()[0] != '.' {
+						log.Debugf("Skipping %s: directory name is ignored; see ignore-dirs option", subPath)
+					}
+					continue
+				} else if dir.gitignore.ignored(subPath, true) {
+					log.Debugf("Skipping %s: matches a .gitignore pattern", subPath)
+					continue
+				}
+			}
 			sub := &Dir{
-				Path:     path.Join(dir.Path, fi.Name()),
-				Config:   dir.Config.Clone(),
+				Path:     subPath,
+				Snapshot: dir.Snapshot,
 				repoBase: dir.repoBase,
 			}
-			sub.parseContents()
+			if dir.gitignore != nil {
+				sub.gitignore = dir.gitignore.readGitignore(subPath, dir.Snapshot)
+			}
+			if sub.ParseError = dir.inheritConfig(sub); sub.ParseError == nil {
+				sub.parseContents()
+			}
 			result = append(result, sub)
 		}
 	}
@@ -171,12 +425,15 @@ func (dir *Dir) CreateSubdir(name string, optionFile *mybase.File) (*Dir, error)
 	dirPath := path.Join(dir.Path, name)
 	if dir.OptionFile != nil && dir.OptionFile.SomeSectionHasOption("schema") {
 		return nil, fmt.Errorf("Cannot use dir %s: parent option file %s defines schema option", dirPath, dir.OptionFile)
-	} else if _, ok := dir.Config.Source("schema").(*mybase.File); ok {
+	}
+	switch dir.Config.Source("schema").(type) {
+	case *mybase.File, *util.DirSectionFile:
 		return nil, fmt.Errorf("Cannot use dir %s: an ancestor option file defines schema option", dirPath)
 	}
 
-	if fi, err := os.Stat(dirPath); os.IsNotExist(err) {
-		err = os.MkdirAll(dirPath, 0777)
+	if fi, err := dir.Snapshot.Stat(dirPath); os.IsNotExist(err) {
+		err = MakeDir(dirPath)
+		dir.Snapshot.Forget(dirPath)
 		if err != nil {
 			return nil, fmt.Errorf("Unable to create directory %s: %s", dirPath, err)
 		}
@@ -186,14 +443,14 @@ func (dir *Dir) CreateSubdir(name string, optionFile *mybase.File) (*Dir, error)
 		return nil, fmt.Errorf("Path %s already exists but is not a directory", dirPath)
 	} else {
 		// Existing dir: confirm it doesn't already have .skeema or *.sql files
-		fileInfos, err := ioutil.ReadDir(dirPath)
+		entries, err := dir.Snapshot.ReadDir(dirPath)
 		if err != nil {
 			return nil, err
 		}
-		for _, fi := range fileInfos {
-			if fi.Name() == ".skeema" {
+		for _, entry := range entries {
+			if entry.Name() == ".skeema" {
 				return nil, fmt.Errorf("Cannot use dir %s: already has .skeema file", dirPath)
-			} else if strings.HasSuffix(fi.Name(), ".sql") {
+			} else if strings.HasSuffix(entry.Name(), ".sql") {
 				return nil, fmt.Errorf("Cannot use dir %s: Already contains *.sql files", dirPath)
 			}
 		}
@@ -201,20 +458,102 @@ func (dir *Dir) CreateSubdir(name string, optionFile *mybase.File) (*Dir, error)
 
 	if optionFile != nil {
 		optionFile.Dir = dirPath
+		dir.Snapshot.Forget(optionFile.Path())
 		if err := optionFile.Write(false); err != nil {
 			return nil, fmt.Errorf("Cannot use dir %s: Unable to write to %s: %s", dirPath, optionFile.Path(), err)
+		} else if err := os.Chmod(optionFile.Path(), util.FileMode); err != nil {
+			return nil, fmt.Errorf("Cannot use dir %s: Unable to set permissions of %s: %s", dirPath, optionFile.Path(), err)
 		}
 	}
 
 	sub := &Dir{
-		Path:     dirPath,
-		Config:   dir.Config.Clone(),
-		repoBase: dir.repoBase,
+		Path:      dirPath,
+		Snapshot:  dir.Snapshot,
+		repoBase:  dir.repoBase,
+		gitignore: dir.gitignore,
+	}
+	if err := dir.inheritConfig(sub); err != nil {
+		return nil, err
 	}
 	sub.parseContents()
 	return sub, sub.ParseError
 }
 
+// inheritConfig populates the Config of sub, a subdirectory of dir, prior to
+// sub's own option file being parsed. Typically this is just a copy of dir's
+// Config, but any dir-pattern sections of ancestor option files which apply
+// to sub are also taken into account.
+func (dir *Dir) inheritConfig(sub *Dir) (err error) {
+	sub.baseConfig = dir.baseConfig
+	sub.parentFiles = make([]*mybase.File, len(dir.parentFiles), len(dir.parentFiles)+1)
+	copy(sub.parentFiles, dir.parentFiles)
+	if dir.OptionFile != nil {
+		sub.parentFiles = append(sub.parentFiles, dir.OptionFile)
+	}
+	if dir.baseConfig == nil {
+		sub.Config = dir.Config.Clone()
+		return nil
+	}
+	sub.Config, err = configForDir(dir.baseConfig, sub.parentFiles, sub.Path)
+	return err
+}
+
+// LeafOptions specifies optional contents of the .skeema file written by
+// InitLeaf, in addition to the schema option.
+type LeafOptions struct {
+	Options      map[string]string            // sectionless options
+	Environments map[string]map[string]string // environment name -> options for that environment's section
+}
+
+// InitLeaf creates a subdirectory of dir with the supplied name, representing
+// a single schema. The subdirectory's .skeema file sets the schema option to
+// schemaName, along with any options in opts. The new Dir is returned; callers
+// may modify its OptionFile further.
+//
+// If the subdirectory already has a .skeema file setting the schema option to
+// schemaName, the existing Dir is returned without rewriting the file, so
+// InitLeaf may safely be called repeatedly; any comments or manual edits in
+// the file are left as-is. It is an error if the existing .skeema file sets a
+// different schema name, or no schema name at all. Other restrictions are the
+// same as for CreateSubdir.
+func (dir *Dir) InitLeaf(name, schemaName string, opts LeafOptions) (*Dir, error) {
+	dirPath := path.Join(dir.Path, name)
+	sub := &Dir{Path: dirPath, Snapshot: dir.Snapshot}
+	if status, _ := sub.FileStatus(".skeema"); status == FileDenied {
+		return nil, fmt.Errorf("Cannot use dir %s: permission denied", dirPath)
+	} else if status == FileExists {
+		sub = &Dir{
+			Path:      dirPath,
+			Snapshot:  dir.Snapshot,
+			repoBase:  dir.repoBase,
+			gitignore: dir.gitignore,
+		}
+		if err := dir.inheritConfig(sub); err != nil {
+			return nil, err
+		}
+		sub.parseContents()
+		if sub.OptionFile == nil {
+			return nil, sub.ParseError
+		}
+		if existing, _ := sub.OptionFile.OptionValue("schema"); existing != schemaName {
+			return nil, fmt.Errorf("Cannot use dir %s: already has .skeema file with schema \"%s\" instead of \"%s\"", dirPath, existing, schemaName)
+		}
+		return sub, sub.ParseError
+	}
+
+	optionFile := mybase.NewFile(dirPath, ".skeema")
+	optionFile.SetOptionValue("", "schema", schemaName)
+	for optionName, value := range opts.Options {
+		optionFile.SetOptionValue("", optionName, value)
+	}
+	for environment, options := range opts.Environments {
+		for optionName, value := range options {
+			optionFile.SetOptionValue(environment, optionName, value)
+		}
+	}
+	return dir.CreateSubdir(name, optionFile)
+}
+
 // CreateOptionFile adds the supplied option file to dir. It is an error if dir
 // already has an option file.
 func (dir *Dir) CreateOptionFile(optionFile *mybase.File) (err error) {
@@ -222,14 +561,16 @@ func (dir *Dir) CreateOptionFile(optionFile *mybase.File) (err error) {
 		return fmt.Errorf("Directory %s already has an option file", dir)
 	}
 	optionFile.Dir = dir.Path
+	dir.Snapshot.Forget(optionFile.Path())
 	if err := optionFile.Write(false); err != nil {
 		return fmt.Errorf("Unable to write to %s: %s", optionFile.Path(), err)
+	} else if err := os.Chmod(optionFile.Path(), util.FileMode); err != nil {
+		return fmt.Errorf("Unable to set permissions of %s: %s", optionFile.Path(), err)
 	}
-	if dir.OptionFile, err = parseOptionFile(dir.Path, dir.repoBase, dir.Config); err != nil {
+	if dir.OptionFile, err = parseOptionFile(dir.Path, dir.repoBase, dir.Config, dir.Snapshot); err != nil {
 		return err
 	}
-	dir.Config.AddSource(dir.OptionFile)
-	return nil
+	return util.AddOptionFile(dir.Config, dir.OptionFile)
 }
 
 // Hostnames returns 0 or more hosts that the directory maps to. This properly
@@ -269,7 +610,14 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 		// to do
 		return nil, nil
 	}
+	return dir.InstancesForHosts(hosts)
+}
 
+// InstancesForHosts behaves like Instances, but uses the supplied hostnames
+// instead of the directory's host option. Each hostname may include a port.
+// All other connection options, such as user and password, still come from the
+// directory's configuration.
+func (dir *Dir) InstancesForHosts(hosts []string) ([]*tengo.Instance, error) {
 	// Before looping over hostnames, do a single lookup of user, password,
 	// connect-options, port, socket.
 	var userAndPass string
@@ -282,19 +630,32 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Invalid connection options: %s", err)
 	}
+	throttle, err := util.IntrospectionThrottle(dir.Config)
+	if err != nil {
+		return nil, err
+	}
 	portValue := dir.Config.GetIntOrDefault("port")
 	portWasSupplied := dir.Config.Supplied("port")
 	portIsntDefault := dir.Config.Changed("port")
 	socketValue := dir.Config.Get("socket")
 	socketWasSupplied := dir.Config.Supplied("socket")
 
+	// If configured to use an SSH bastion host, all connections are made over
+	// TCP through the tunnel, even for localhost
+	network := "tcp"
+	if tunnelConfig, ok := util.SSHTunnelConfigForOptions(dir.Config); ok {
+		if network, err = util.SSHNetwork(tunnelConfig); err != nil {
+			return nil, fmt.Errorf("Invalid SSH tunnel configuration for %s: %s", dir, err)
+		}
+	}
+
 	// For each hostname, construct a DSN and use it to create an Instance
 	var instances []*tengo.Instance
 	for _, host := range hosts {
 		var dsn string
 		thisPortValue := portValue
 		// TODO also support cloudsql DSNs
-		if host == "localhost" && (socketWasSupplied || !portWasSupplied) {
+		if host == "localhost" && network == "tcp" && (socketWasSupplied || !portWasSupplied) {
 			dsn = fmt.Sprintf("%s@unix(%s)/?%s", userAndPass, socketValue, params)
 		} else {
 			splitHost, splitPort, err := tengo.SplitHostOptionalPort(host)
@@ -308,9 +669,9 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 				host = splitHost
 				thisPortValue = splitPort
 			}
-			dsn = fmt.Sprintf("%s@tcp(%s:%d)/?%s", userAndPass, host, thisPortValue, params)
+			dsn = fmt.Sprintf("%s@%s(%s:%d)/?%s", userAndPass, network, host, thisPortValue, params)
 		}
-		instance, err := util.NewInstance("mysql", dsn)
+		instance, err := util.NewInstance(util.IntrospectionDriver, dsn)
 		if err != nil {
 			if dir.Config.Changed("password") {
 				safeUserPass := fmt.Sprintf("%s:*****", dir.Config.Get("user"))
@@ -318,11 +679,90 @@ func (dir *Dir) Instances() ([]*tengo.Instance, error) {
 			}
 			return nil, fmt.Errorf("Invalid connection information for %s (DSN=%s): %s", dir, dsn, err)
 		}
+		util.SetIntrospectionThrottle(instance, throttle)
 		instances = append(instances, instance)
 	}
 	return instances, nil
 }
 
+// DDLInstance returns the tengo.Instance that should be used for executing
+// DDL on inst, which must have been obtained from dir.Instances. If the
+// ddl-user option is not set, inst is returned as-is. Otherwise, the returned
+// instance has the same address and connection params as inst, but connects
+// using ddl-user and ddl-password. An error is returned if ddl-user is set but
+// ddl-password was not supplied, since the DDL credentials are typically
+// supplied separately from the introspection credentials. The instance is NOT
+// checked for connectivity.
+func (dir *Dir) DDLInstance(inst *tengo.Instance) (*tengo.Instance, error) {
+	if dir.Config.FindOption("ddl-user") == nil || dir.Config.Get("ddl-user") == "" {
+		return inst, nil
+	}
+	if !dir.Config.Supplied("ddl-password") {
+		return nil, fmt.Errorf("Option ddl-user is set to %s for %s, but ddl-password has not been supplied", dir.Config.Get("ddl-user"), dir)
+	}
+	params, err := dir.InstanceDefaultParams()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid connection options: %s", err)
+	}
+	userAndPass := fmt.Sprintf("%s:%s", dir.Config.Get("ddl-user"), dir.Config.Get("ddl-password"))
+	addr := inst.BaseDSN[strings.LastIndex(inst.BaseDSN, "@")+1:]
+	dsn := fmt.Sprintf("%s@%s?%s", userAndPass, addr, params)
+	ddlInst, err := util.NewInstance("mysql", dsn)
+	if err != nil {
+		safeUserPass := fmt.Sprintf("%s:*****", dir.Config.Get("ddl-user"))
+		dsn = strings.Replace(dsn, userAndPass, safeUserPass, 1)
+		return nil, fmt.Errorf("Invalid DDL connection information for %s (DSN=%s): %s", dir, dsn, err)
+	}
+	return ddlInst, nil
+}
+
+// ConnectInstance checks connectivity to inst, which must have been obtained
+// from dir.Instances. If inst cannot be reached but the fallback-ports option
+// is set, the same host is tried on each fallback port in order, returning the
+// first reachable instance. Fallback ports are not used with socket
+// connections. If every attempt fails, the returned error lists each attempted
+// port along with the reason it failed.
+func (dir *Dir) ConnectInstance(inst *tengo.Instance) (*tengo.Instance, error) {
+	ok, err := inst.CanConnect()
+	if ok {
+		return inst, nil
+	}
+	err = util.ExplainConnectError(err, inst.Port)
+	if inst.SocketPath != "" {
+		return nil, err
+	}
+	ports, portErr := util.FallbackPorts(dir.Config)
+	if portErr != nil {
+		return nil, portErr
+	} else if len(ports) == 0 {
+		return nil, err
+	}
+	params, paramErr := dir.InstanceDefaultParams()
+	if paramErr != nil {
+		return nil, fmt.Errorf("Invalid connection options: %s", paramErr)
+	}
+
+	attempts := []string{fmt.Sprintf("port %d: %s", inst.Port, err)}
+	hostEnd := strings.LastIndex(inst.BaseDSN, ":")
+	for _, port := range ports {
+		if port == inst.Port {
+			continue
+		}
+		dsn := fmt.Sprintf("%s:%d)/?%s", inst.BaseDSN[:hostEnd], port, params)
+		fallbackInst, err := util.NewInstance(inst.Driver, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid fallback connection information for %s on port %d: %s", dir, port, err)
+		}
+		if ok, err := fallbackInst.CanConnect(); ok {
+			log.Infof("Unable to connect to %s, so using fallback port %d instead", inst, port)
+			return fallbackInst, nil
+		} else if err != nil {
+			attempts = append(attempts, fmt.Sprintf("port %d: %s", port, util.ExplainConnectError(err, port)))
+		}
+	}
+	return nil, fmt.Errorf("Unable to connect to %s on any port; %s", inst.Host, strings.Join(attempts, "; "))
+}
+
 // FirstInstance returns at most one tengo.Instance based on the directory's
 // configuration. If the config maps to multiple instances, only the first will
 // be returned. If the config maps to no instances, nil will be returned. The
@@ -337,9 +777,9 @@ func (dir *Dir) FirstInstance() (*tengo.Instance, error) {
 
 	var lastErr error
 	for _, instance := range instances {
-		var ok bool
-		if ok, lastErr = instance.CanConnect(); ok {
-			return instance, nil
+		var connected *tengo.Instance
+		if connected, lastErr = dir.ConnectInstance(instance); lastErr == nil {
+			return connected, nil
 		}
 	}
 	if len(instances) == 1 {
@@ -433,6 +873,14 @@ func (dir *Dir) SchemaNames(instance *tengo.Instance) (names []string, err error
 	return keepNames, nil
 }
 
+// SchemaNamesRequireQuery returns true if the dir's schema option is a
+// wildcard or regular expression, meaning that SchemaNames must query the
+// instance to determine the schema names.
+func (dir *Dir) SchemaNamesRequireQuery() bool {
+	schemaValue := dir.Config.Get("schema")
+	return schemaValue == "*" || looksLikeRegex(schemaValue)
+}
+
 func looksLikeRegex(input string) bool {
 	return len(input) > 2 && input[0] == '/' && input[len(input)-1] == '/'
 }
@@ -493,6 +941,13 @@ func (dir *Dir) InstanceDefaultParams() (string, error) {
 	// Set overridable options
 	v.Set("timeout", "5s")
 	v.Set("readTimeout", "20s")
+	if dir.Config.FindOption("query-timeout") != nil { // defined in util.AddGlobalOptions, which some callers (such as tests) may not use
+		queryTimeout, err := util.ParseTimeout("query-timeout", dir.Config.Get("query-timeout"))
+		if err != nil {
+			return "", err
+		}
+		v.Set("readTimeout", queryTimeout.String())
+	}
 	v.Set("writeTimeout", "5s")
 	v.Set("sql_mode", "'ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_ENGINE_SUBSTITUTION'")
 	v.Set("innodb_strict_mode", "1")
@@ -530,20 +985,43 @@ func (dir *Dir) InstanceDefaultParams() (string, error) {
 // error will populate dir.ParseError.
 func (dir *Dir) parseContents() {
 	// Parse the option file, if one exists
-	var has bool
-	if has, dir.ParseError = dir.HasFile(".skeema"); dir.ParseError != nil {
+	var status FileStatus
+	if status, dir.ParseError = dir.FileStatus(".skeema"); status == FileDenied {
+		dir.ParseError = fmt.Errorf("Unable to read directory %s: permission denied", dir.Path)
+		return
+	} else if dir.ParseError != nil {
+		return
+	} else if isDir, _ := dir.HasDir(".skeema"); isDir {
+		dir.ParseError = fmt.Errorf("%s is a directory, but should be an option file", path.Join(dir.Path, ".skeema"))
 		return
-	} else if has {
-		if dir.OptionFile, dir.ParseError = parseOptionFile(dir.Path, dir.repoBase, dir.Config); dir.ParseError != nil {
+	} else if status == FileExists {
+		if dir.OptionFile, dir.ParseError = parseOptionFile(dir.Path, dir.repoBase, dir.Config, dir.Snapshot); dir.ParseError != nil {
+			return
+		}
+		if dir.ParseError = util.AddOptionFile(dir.Config, dir.OptionFile); dir.ParseError != nil {
 			return
 		}
-		dir.Config.AddSource(dir.OptionFile)
 	}
 
-	// Tokenize and parse any *.sql files
-	if dir.SQLFiles, dir.ParseError = sqlFiles(dir.Path, dir.repoBase); dir.ParseError != nil {
+	// Tokenize and parse any *.sql files, other than passthrough and grants files
+	var files []SQLFile
+	if files, dir.ParseError = sqlFiles(dir.Path, dir.repoBase, dir.Snapshot); dir.ParseError != nil {
 		return
 	}
+	dir.SQLFiles = make([]SQLFile, 0, len(files))
+	for _, sf := range files {
+		if IsPassthroughFile(sf.FileName) {
+			dir.PassthroughFiles = append(dir.PassthroughFiles, sf)
+		} else if IsGrantsFile(sf.FileName) {
+			grantsFile := sf
+			dir.GrantsFile = &grantsFile
+		} else {
+			dir.SQLFiles = append(dir.SQLFiles, sf)
+		}
+	}
+	sort.Slice(dir.PassthroughFiles, func(i, j int) bool {
+		return dir.PassthroughFiles[i].FileName < dir.PassthroughFiles[j].FileName
+	})
 	logicalSchemasByName := make(map[string]*LogicalSchema)
 	for _, sf := range dir.SQLFiles {
 		tokenizedFile, err := sf.Tokenize()
@@ -579,6 +1057,12 @@ func (dir *Dir) parseContents() {
 
 	dir.LogicalSchemas = make([]*LogicalSchema, 0, len(logicalSchemasByName))
 	if ls, ok := logicalSchemasByName[""]; ok {
+		if dir.ParseError = dir.expandTemplate(ls); dir.ParseError != nil {
+			return
+		}
+		if dir.ParseError = dir.applyOverrides(ls); dir.ParseError != nil {
+			return
+		}
 		ls.CharSet = dir.Config.Get("default-character-set")
 		ls.Collation = dir.Config.Get("default-collation")
 		dir.LogicalSchemas = append([]*LogicalSchema{ls}, dir.LogicalSchemas...)
@@ -589,6 +1073,31 @@ func (dir *Dir) parseContents() {
 	}
 }
 
+// loadGitignore populates dir.gitignore from the .gitignore files in the repo
+// base dir and each dir between it and dir, inclusive. This only occurs if the
+// respect-gitignore option is enabled and the repo base is the root of a git
+// repo; otherwise dir.gitignore is left nil.
+func (dir *Dir) loadGitignore() {
+	if dir.Config.FindOption("respect-gitignore") == nil || !dir.Config.GetBool("respect-gitignore") {
+		return
+	}
+	if _, err := dir.Snapshot.Stat(filepath.Join(dir.repoBase, ".git")); err != nil {
+		return
+	}
+	rel, err := filepath.Rel(dir.repoBase, dir.Path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return
+	}
+	curPath := dir.repoBase
+	dir.gitignore = gitignore{}.readGitignore(curPath, dir.Snapshot)
+	if rel != "." {
+		for _, component := range strings.Split(rel, string(os.PathSeparator)) {
+			curPath = filepath.Join(curPath, component)
+			dir.gitignore = dir.gitignore.readGitignore(curPath, dir.Snapshot)
+		}
+	}
+}
+
 // ParentOptionFiles returns a slice of *mybase.File, corresponding to the
 // option files in the specified path's parent dir hierarchy. Evaluation of
 // parent dirs stops once we hit either a directory containing .git, the
@@ -602,38 +1111,41 @@ func (dir *Dir) parseContents() {
 // typically be either a dir containing a .git subdir, or the rootmost dir
 // containing a .skeema file; failing that, it will be the supplied dirPath.
 func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.File, string, error) {
+	return parentOptionFiles(dirPath, baseConfig, nil)
+}
+
+// parentOptionFiles behaves like ParentOptionFiles, but obtains dir listings
+// from snapshot.
+func parentOptionFiles(dirPath string, baseConfig *mybase.Config, snapshot *TreeSnapshot) ([]*mybase.File, string, error) {
 	cleaned, err := filepath.Abs(filepath.Clean(dirPath))
 	if err != nil {
 		return nil, "", err
 	}
-	cleaned = strings.TrimRight(cleaned, "/") // Prevent strings.Split from spitting out 2 blank strings for root dir
-
-	components := strings.Split(cleaned, string(os.PathSeparator))
-	filePaths := make([]string, 0, len(components)-1)
-
+	var filePaths []string
 	home := filepath.Clean(os.Getenv("HOME"))
 	repoBase := cleaned
 
 	// Examine dirs, starting with dirPath and going up one level at a time,
 	// stopping early if we hit either the user's home directory or a directory
-	// containing a .git subdir.
-	var atRepoBase bool
-	for n := len(components) - 1; n >= 0 && !atRepoBase; n-- {
-		curPath := "/" + path.Join(components[0:n+1]...)
+	// containing a .git subdir. Walking via filepath.Dir, rather than splitting
+	// on path separators, ensures the root of the filesystem (or of a volume) is
+	// handled properly.
+	for curPath := cleaned; ; curPath = filepath.Dir(curPath) {
 		if curPath == home {
 			// We already read ~/.skeema as a global file
 			break
 		}
-		fileInfos, err := ioutil.ReadDir(curPath)
+		entries, err := snapshot.ReadDir(curPath)
 		// If we hit a dir we cannot read, halt early but don't consider this fatal
 		if err != nil {
 			break
 		}
-		for _, fi := range fileInfos {
-			if fi.Name() == ".git" {
+		var atRepoBase bool
+		for _, entry := range entries {
+			if entry.Name() == ".git" {
 				repoBase = curPath
 				atRepoBase = true
-			} else if fi.Name() == ".skeema" && n < len(components)-1 {
+			} else if entry.Name() == ".skeema" && !entry.IsDir() && curPath != cleaned {
 				// The second part of the above conditional ensures we ignore dirPath's own
 				// .skeema file, since that is handled in Dir.parseContents() to save as
 				// dir.OptionFile.
@@ -641,6 +1153,9 @@ func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.Fil
 				repoBase = curPath
 			}
 		}
+		if atRepoBase || filepath.Dir(curPath) == curPath {
+			break
+		}
 	}
 
 	// Now that we have the list of dirs with .skeema files, iterate over it in
@@ -649,7 +1164,7 @@ func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.Fil
 	// subdirs.
 	files := make([]*mybase.File, 0, len(filePaths))
 	for n := len(filePaths) - 1; n >= 0; n-- {
-		f, err := parseOptionFile(filePaths[n], repoBase, baseConfig)
+		f, err := parseOptionFile(filePaths[n], repoBase, baseConfig, snapshot)
 		if err != nil {
 			return nil, repoBase, err
 		}
@@ -659,13 +1174,17 @@ func ParentOptionFiles(dirPath string, baseConfig *mybase.Config) ([]*mybase.Fil
 	return files, repoBase, nil
 }
 
-func parseOptionFile(dirPath, repoBase string, baseConfig *mybase.Config) (*mybase.File, error) {
+func parseOptionFile(dirPath, repoBase string, baseConfig *mybase.Config, snapshot *TreeSnapshot) (*mybase.File, error) {
 	f := mybase.NewFile(dirPath, ".skeema")
-	fi, err := os.Lstat(f.Path())
+	entry, err := snapshot.Entry(dirPath, ".skeema")
 	if err != nil {
 		return nil, err
-	} else if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
-		dest, err := os.Readlink(f.Path())
+	} else if entry == nil {
+		return nil, &os.PathError{Op: "lstat", Path: f.Path(), Err: os.ErrNotExist}
+	}
+	mode := entry.Type()
+	if mode&os.ModeSymlink == os.ModeSymlink {
+		dest, err := snapshot.Readlink(f.Path())
 		if err != nil {
 			return nil, err
 		}
@@ -678,41 +1197,121 @@ func parseOptionFile(dirPath, repoBase string, baseConfig *mybase.Config) (*myba
 		if !strings.HasPrefix(dest, repoBase) {
 			return nil, fmt.Errorf("%s is a symlink pointing outside of its repo", f.Path())
 		}
-		if fi, err = os.Lstat(dest); err != nil { // using Lstat here to prevent symlinks-to-symlinks
+		fi, err := snapshot.Lstat(dest) // using Lstat here to prevent symlinks-to-symlinks
+		if err != nil {
 			return nil, err
 		}
+		mode = fi.Mode()
 	}
-	if !fi.Mode().IsRegular() {
+	if !mode.IsRegular() {
 		return nil, fmt.Errorf("%s is not a regular file, nor a symlink to a regular file", f.Path())
 	}
 	if err := f.Read(); err != nil {
 		return nil, err
 	}
 	if err := f.Parse(baseConfig); err != nil {
+		return nil, diagnoseOptionFile(f, baseConfig, err)
+	}
+	for _, section := range dirSections(f, baseConfig) {
+		if err := util.CheckDirSection(f, section); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := util.UseEnvironment(f, baseConfig.Get("environment")); err != nil {
 		return nil, err
 	}
-	_ = f.UseSection(baseConfig.Get("environment")) // we don't care if the section doesn't exist
 	return f, nil
 }
 
+// dirSections returns the names of all dir-pattern sections in option file f.
+func dirSections(f *mybase.File, cfg *mybase.Config) (sections []string) {
+	seen := make(map[string]bool)
+	for name := range cfg.CLI.Command.Options() {
+		for _, section := range f.SectionsWithOption(name) {
+			if strings.HasPrefix(section, util.DirSectionPrefix) && !seen[section] {
+				seen[section] = true
+				sections = append(sections, section)
+			}
+		}
+	}
+	sort.Strings(sections)
+	return sections
+}
+
+// configForDir returns a copy of baseConfig with the supplied option files of
+// an ancestor of dirPath added as sources, in order. Any option file with
+// dir-pattern sections which apply to dirPath is added as a util.DirSectionFile,
+// in which those sections' values take precedence over the file's top
+// section, but not over its environment sections. The values are obtained from
+// the already-parsed files, so nothing is read from disk again, aside from any
+// option files included by each file, which are added immediately before it.
+func configForDir(baseConfig *mybase.Config, files []*mybase.File, dirPath string) (*mybase.Config, error) {
+	cfg := baseConfig.Clone()
+	for _, f := range files {
+		rel, err := filepath.Rel(f.Dir, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		chain := util.EnvironmentSections(f, baseConfig.Get("environment"))
+		dsf := &util.DirSectionFile{
+			File:     f,
+			Values:   make(map[string]string),
+			Sections: make(map[string]string),
+		}
+		for name := range baseConfig.CLI.Command.Options() {
+			setBy := f.SectionsWithOption(name)
+			matches := util.MatchingDirSections(setBy, filepath.ToSlash(rel))
+			if len(matches) == 0 || sectionsOverlap(setBy, chain) {
+				continue
+			}
+			_ = f.UseSection(matches[0])
+			dsf.Values[name], _ = f.OptionValue(name)
+			dsf.Sections[name] = matches[0]
+		}
+		if len(dsf.Values) == 0 {
+			err = util.AddOptionFile(cfg, f)
+		} else {
+			_ = f.UseSection(chain...) // restore the selection for environment
+			err = util.AddDirSectionFile(cfg, dsf)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// sectionsOverlap returns true if any section name is present in both a and b.
+func sectionsOverlap(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // sqlFiles returns a slice of SQLFile for all *.sql files found in the supplied
 // path. This function does not recursively search subdirs, and does not parse
 // or validate the SQLFile contents in any way. An error will only be returned
 // if the directory cannot be read.
 // The repoBase affects evaluation of symlinks; any link destinations outside
-// of the repoBase are ignored.
-func sqlFiles(dirPath, repoBase string) ([]SQLFile, error) {
-	fileInfos, err := ioutil.ReadDir(dirPath)
+// of the repoBase are ignored. The dir listing is obtained from snapshot, and
+// only symlinks require any additional filesystem calls.
+func sqlFiles(dirPath, repoBase string, snapshot *TreeSnapshot) ([]SQLFile, error) {
+	entries, err := snapshot.ReadDir(dirPath)
 	if err != nil {
 		return nil, err
 	}
-	result := make([]SQLFile, 0, len(fileInfos))
-	for _, fi := range fileInfos {
-		name := fi.Name()
+	result := make([]SQLFile, 0, len(entries))
+	for _, entry := range entries {
+		name, destName, mode := entry.Name(), entry.Name(), entry.Type()
 		// symlinks: verify it points to an existing file within repoBase. If it
 		// does not, or if any error occurs in any step in checking, skip it.
-		if fi.Mode()&os.ModeSymlink == os.ModeSymlink {
-			dest, err := os.Readlink(path.Join(dirPath, name))
+		if mode&os.ModeSymlink == os.ModeSymlink {
+			dest, err := snapshot.Readlink(path.Join(dirPath, name))
 			if err != nil {
 				continue
 			}
@@ -725,12 +1324,13 @@ func sqlFiles(dirPath, repoBase string) ([]SQLFile, error) {
 			if !strings.HasPrefix(dest, repoBase) {
 				continue
 			}
-			if fi, err = os.Lstat(dest); err != nil { // using Lstat here to prevent symlinks-to-symlinks
+			fi, err := snapshot.Lstat(dest) // using Lstat here to prevent symlinks-to-symlinks
+			if err != nil {
 				continue
 			}
+			destName, mode = fi.Name(), fi.Mode()
 		}
-		destName := fi.Name()
-		if strings.HasSuffix(destName, ".sql") && fi.Mode().IsRegular() {
+		if strings.HasSuffix(destName, ".sql") && mode.IsRegular() {
 			sf := SQLFile{
 				Dir:      dirPath,
 				FileName: name, // name relative to dirPath, NOT symlink destination!
@@ -759,3 +1359,15 @@ func (dde DuplicateDefinitionError) Error() string {
 		dde.DupeFile, dde.DupeLine,
 	)
 }
+
+// UnexpectedFilesError is an error returned when Dir.Delete() encounters
+// files which are not managed by Skeema.
+type UnexpectedFilesError struct {
+	Dir   string
+	Paths []string // relative to Dir
+}
+
+// Error satisfies the builtin error interface.
+func (ufe UnexpectedFilesError) Error() string {
+	return fmt.Sprintf("Refusing to delete directory %s, since it contains files not managed by Skeema: %s", ufe.Dir, strings.Join(ufe.Paths, ", "))
+}
@@ -1,6 +1,9 @@
 package fs
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -76,6 +79,267 @@ func TestParseDirErrors(t *testing.T) {
 	if _, err := ParseDir("../testdata/golden/init/mydb/product", cfg); err == nil {
 		t.Error("Expected error from ParseDir(), but instead err is nil")
 	}
+
+	// Relative paths cannot be resolved if the working directory was removed
+	origWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unable to obtain working directory: %v", err)
+	}
+	MakeTestDirectory(t, "../testdata/.scratch/removedcwd")
+	if err := os.Chdir("../testdata/.scratch/removedcwd"); err != nil {
+		t.Fatalf("Unable to change working directory: %v", err)
+	}
+	defer func() {
+		if err := os.Chdir(origWd); err != nil {
+			t.Fatalf("Unable to restore working directory: %v", err)
+		}
+		RemoveTestDirectory(t, "../testdata/.scratch")
+	}()
+	if err := os.Remove(filepath.Join(origWd, "../testdata/.scratch/removedcwd")); err != nil {
+		t.Fatalf("Unable to remove working directory: %v", err)
+	}
+	if dir, err := ParseDir(".", getValidConfig(t)); err == nil {
+		t.Errorf("Expected ParseDir to return an error for removed working directory, instead found %+v", dir)
+	}
+	if _, _, err := ParentOptionFiles(".", getValidConfig(t)); err == nil {
+		t.Error("Expected ParentOptionFiles to return an error for removed working directory, but err was nil")
+	}
+}
+
+func TestParentOptionFilesRoot(t *testing.T) {
+	files, repoBase, err := ParentOptionFiles("/", getValidConfig(t))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParentOptionFiles: %v", err)
+	}
+	if repoBase != "/" || len(files) != 0 {
+		t.Errorf("Unexpected result from ParentOptionFiles on root dir: %v, %q", files, repoBase)
+	}
+}
+
+func TestParseDirEnvironmentInheritance(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/inherit/.skeema", "schema=product\n[production]\nhost=prod.invalid\nport=3307\n[staging]\nextends=production\nhost=staging.invalid\n")
+	defer os.RemoveAll("../testdata/.scratch")
+
+	dir, err := ParseDir("../testdata/.scratch/inherit", getValidConfig(t, "staging"))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	if host, port, schema := dir.Config.Get("host"), dir.Config.Get("port"), dir.Config.Get("schema"); host != "staging.invalid" || port != "3307" || schema != "product" {
+		t.Errorf("Unexpected config values with inheritance: host=%s port=%s schema=%s", host, port, schema)
+	}
+
+	// A section extending an undefined section is an error, even if some other
+	// environment is selected
+	WriteTestFile(t, "../testdata/.scratch/inherit/.skeema", "[production]\nhost=prod.invalid\n[broken]\nextends=missing\n")
+	if _, err := ParseDir("../testdata/.scratch/inherit", getValidConfig(t, "production")); err == nil {
+		t.Error("Expected error from ParseDir with invalid inheritance, but err was nil")
+	}
+}
+
+func TestParseDirIncludes(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/ops/conn.cnf", "port=3307\n[production]\nhost=prod.invalid\n")
+	WriteTestFile(t, "../testdata/.scratch/repo/.skeema", "include=../ops/conn.cnf\n")
+	WriteTestFile(t, "../testdata/.scratch/repo/product/.skeema", "schema=product\ninclude-optional=missing.cnf\n")
+	defer os.RemoveAll("../testdata/.scratch")
+
+	dir, err := ParseDir("../testdata/.scratch/repo/product", getValidConfig(t))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	if host, port := dir.Config.Get("host"), dir.Config.Get("port"); host != "prod.invalid" || port != "3307" {
+		t.Errorf("Unexpected config values with include: host=%s port=%s", host, port)
+	}
+	if source := dir.Config.Source("host").(*mybase.File); source.Name != "conn.cnf" {
+		t.Errorf("Expected host option to come from included file, instead found %s", source)
+	}
+
+	// Writing the dir's option file must not affect the included file
+	dir.OptionFile.SetOptionValue("", "default-character-set", "utf8mb4")
+	if err := dir.OptionFile.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	}
+	if contents := ReadTestFile(t, "../testdata/.scratch/ops/conn.cnf"); contents != "port=3307\n[production]\nhost=prod.invalid\n" {
+		t.Errorf("Included file unexpectedly modified: %q", contents)
+	}
+	if contents := ReadTestFile(t, "../testdata/.scratch/repo/product/.skeema"); !strings.Contains(contents, "include-optional=missing.cnf") {
+		t.Errorf("Include directive unexpectedly removed from option file: %q", contents)
+	}
+
+	// Missing non-optional includes are an error
+	WriteTestFile(t, "../testdata/.scratch/repo/product/.skeema", "schema=product\ninclude=missing.cnf\n")
+	if _, err := ParseDir("../testdata/.scratch/repo/product", getValidConfig(t)); err == nil {
+		t.Error("Expected error from ParseDir with missing include, but err was nil")
+	}
+}
+
+func TestParseDirDirSections(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	base := "../testdata/.scratch/dirsections"
+	WriteTestFile(t, base+"/.skeema", "host=h.invalid\nflavor=mysql:5.7\n[dir:shard_special]\nflavor=mariadb:10.3\n[dir:shard_*]\nflavor=mysql:8.0\n[dir:*/nested]\nport=3310\n[staging]\nport=3311\n[production]\nflavor=percona:5.7\n")
+	WriteTestFile(t, base+"/shard_1/nested/.skeema", "schema=product\n")
+	WriteTestFile(t, base+"/shard_2/.skeema", "flavor=mysql:5.6\n")
+	WriteTestFile(t, base+"/shard_special/.skeema", "schema=product\n")
+	WriteTestFile(t, base+"/other/.skeema", "schema=product\n")
+
+	// Environment section takes precedence over dir-pattern sections; the most
+	// specific pattern takes precedence regardless of its order in the file; a
+	// subdir's own option file takes precedence over everything in its parents
+	expected := map[string]map[string][2]string{ // environment -> dir -> flavor, port
+		"development": {
+			"":                {"mysql:5.7", "3306"},
+			"shard_1":         {"mysql:8.0", "3306"},
+			"shard_1/nested":  {"mysql:8.0", "3310"},
+			"shard_2":         {"mysql:5.6", "3306"},
+			"shard_special":   {"mariadb:10.3", "3306"},
+			"other":           {"mysql:5.7", "3306"},
+			"other/../other/": {"mysql:5.7", "3306"},
+		},
+		"staging": {
+			"shard_1/nested": {"mysql:8.0", "3311"},
+			"shard_special":  {"mariadb:10.3", "3311"},
+		},
+		"production": {
+			"shard_1/nested": {"percona:5.7", "3310"},
+			"shard_2":        {"mysql:5.6", "3306"},
+		},
+	}
+	for environment, dirs := range expected {
+		cfg := getValidConfig(t, environment)
+		for relPath, values := range dirs {
+			// Confirm the same values are obtained whether the dir is parsed directly,
+			// or obtained via Subdirs of its parent
+			dir, err := ParseDir(filepath.Join(base, relPath), cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error from ParseDir: %s", err)
+			}
+			configs := []*mybase.Config{dir.Config}
+			if relPath != "" {
+				parent, err := ParseDir(filepath.Dir(dir.Path), cfg)
+				if err != nil {
+					t.Fatalf("Unexpected error from ParseDir: %s", err)
+				}
+				subs, err := parent.Subdirs()
+				if err != nil {
+					t.Fatalf("Unexpected error from Subdirs: %s", err)
+				}
+				for _, sub := range subs {
+					if sub.Path == dir.Path {
+						configs = append(configs, sub.Config)
+					}
+				}
+				if len(configs) != 2 {
+					t.Fatalf("Unable to find %s in Subdirs of its parent", relPath)
+				}
+			}
+			for _, dirCfg := range configs {
+				if flavor, port := dirCfg.Get("flavor"), dirCfg.Get("port"); flavor != values[0] || port != values[1] {
+					t.Errorf("Environment %s, dir %s: expected flavor=%s port=%s, instead found flavor=%s port=%s", environment, relPath, values[0], values[1], flavor, port)
+				}
+			}
+		}
+	}
+
+	// Subdirs use the values of the parent's already-parsed option file, even if
+	// the file has since changed on disk
+	parent, err := ParseDir(base, getValidConfig(t, "development"))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	WriteTestFile(t, base+"/.skeema", "host=h.invalid\nflavor=mysql:5.7\n[dir:shard_*]\nflavor=mysql:5.5\n")
+	subs, err := parent.Subdirs()
+	if err != nil {
+		t.Fatalf("Unexpected error from Subdirs: %s", err)
+	}
+	for _, sub := range subs {
+		if sub.BaseName() == "shard_1" {
+			if flavor := sub.Config.Get("flavor"); flavor != "mysql:8.0" {
+				t.Errorf("Expected shard_1 to have flavor mysql:8.0 from parent's parsed option file, instead found %s", flavor)
+			}
+			if desc := util.SourceDescription(sub.Config, "flavor"); !strings.Contains(desc, "[dir:shard_*]") {
+				t.Errorf("Unexpected SourceDescription for flavor: %s", desc)
+			}
+		}
+	}
+
+	// Malformed patterns are an error
+	WriteTestFile(t, base+"/.skeema", "host=h.invalid\n[dir:[abc]\nflavor=mysql:8.0\n")
+	if _, err := ParseDir(base, getValidConfig(t)); err == nil {
+		t.Error("Expected error from malformed dir pattern, but err was nil")
+	}
+}
+
+func TestParseDirListOptions(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	base := "../testdata/.scratch/listoptions"
+	WriteTestFile(t, base+"/.skeema", "host=h.invalid\nignore-table=^_\n[dir:shard_*]\nignore-table=^tmp\n")
+	WriteTestFile(t, base+"/shard_1/.skeema", "schema=product\nignore-table=^bak|^_\n")
+	WriteTestFile(t, base+"/shard_2/.skeema", "schema=product\nignore-table=!reset\n")
+	WriteTestFile(t, base+"/other/.skeema", "schema=product\nignore-table='!reset|^old'\n")
+
+	// Values accumulate across option files, but within a single option file, a
+	// matching dir-pattern section replaces the top section's value
+	expected := map[string]string{
+		"":        "^_",
+		"shard_1": "^tmp|^bak|^_",
+		"shard_2": "",
+		"other":   "^old",
+	}
+	parent, err := ParseDir(base, getValidConfig(t))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	subs, err := parent.Subdirs()
+	if err != nil {
+		t.Fatalf("Unexpected error from Subdirs: %s", err)
+	}
+	for _, dir := range append(subs, parent) {
+		relPath, _ := filepath.Rel(parent.Path, dir.Path)
+		if relPath == "." {
+			relPath = ""
+		}
+		if actual := dir.Config.Get("ignore-table"); actual != expected[relPath] {
+			t.Errorf("Dir %s: expected ignore-table=%q, instead found %q", relPath, expected[relPath], actual)
+		}
+	}
+}
+
+func TestParseDirOptionFileErrors(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	contents := "schema=product\n<<<<<<< HEAD\nhost=a.invalid\n=======\nhost=b.invalid\n>>>>>>> branch\n[production\n  flavor='mysql:8.0\nfoo=bar\n"
+	WriteTestFile(t, "../testdata/.scratch/parent/.skeema", contents)
+	WriteTestFile(t, "../testdata/.scratch/parent/child/.skeema", "schema=product\n")
+
+	// All malformed lines should be reported, with positions
+	_, err := ParseDir("../testdata/.scratch/parent", getValidConfig(t))
+	ofe, ok := err.(OptionFileError)
+	if !ok {
+		t.Fatalf("Expected error to be an OptionFileError, instead found %T %v", err, err)
+	}
+	expected := []OptionFileProblem{
+		{LineNumber: 2, Column: 1, Text: "<<<<<<< HEAD", Problem: "unresolved git merge conflict"},
+		{LineNumber: 4, Column: 1, Text: "=======", Problem: "unresolved git merge conflict"},
+		{LineNumber: 6, Column: 1, Text: ">>>>>>> branch", Problem: "unresolved git merge conflict"},
+		{LineNumber: 7, Column: 1, Text: "[production", Problem: "unterminated section name"},
+		{LineNumber: 8, Column: 10, Text: "  flavor='mysql:8.0", Problem: "quoted value has no terminating quote"},
+		{LineNumber: 9, Column: 1, Text: "foo=bar", Problem: `unknown option "foo"`},
+	}
+	if len(ofe.Problems) != len(expected) {
+		t.Fatalf("Expected %d problems, instead found %d: %v", len(expected), len(ofe.Problems), ofe)
+	}
+	for n := range expected {
+		if ofe.Problems[n] != expected[n] {
+			t.Errorf("Expected problem[%d] to be %+v, instead found %+v", n, expected[n], ofe.Problems[n])
+		}
+	}
+	if !strings.Contains(err.Error(), `line 2, column 1: unresolved git merge conflict: "<<<<<<< HEAD"`) {
+		t.Errorf("Unexpected error message: %s", err)
+	}
+
+	// A malformed parent option file should be identified as the culprit
+	_, err = ParseDir("../testdata/.scratch/parent/child", getValidConfig(t))
+	if ofe, ok := err.(OptionFileError); !ok || !strings.HasSuffix(ofe.FilePath, "parent/.skeema") {
+		t.Errorf("Expected error to identify parent option file, instead found %v", err)
+	}
 }
 
 func TestParseDirSymlinks(t *testing.T) {
@@ -150,6 +414,41 @@ func TestDirRelPath(t *testing.T) {
 	}
 }
 
+func TestDirManaged(t *testing.T) {
+	for _, dirPath := range []string{"../testdata/golden/init/mydb/product", "../testdata/golden/init/mydb", "../testdata/golden/init"} {
+		if dir := getDir(t, dirPath); !dir.Managed(5) {
+			t.Errorf("Expected %s to be managed, but Managed returned false", dirPath)
+		}
+	}
+
+	// A tree with *.sql files but no .skeema files anywhere is not managed
+	tempDir, err := ioutil.TempDir("", "skeema-unmanaged")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	if err := os.MkdirAll(filepath.Join(tempDir, "a", "b"), 0777); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "a", "b", "foo.sql"), []byte("CREATE TABLE foo (id int);\n"), 0666); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if dir := getDir(t, tempDir); dir.Managed(5) {
+		t.Error("Expected tree without .skeema files to be unmanaged, but Managed returned true")
+	}
+
+	// Once a .skeema file exists in a descendant within maxDepth, the tree is
+	// managed
+	if err := ioutil.WriteFile(filepath.Join(tempDir, "a", "b", ".skeema"), []byte("schema=foo\n"), 0666); err != nil {
+		t.Fatalf("Unable to write file: %s", err)
+	}
+	if dir := getDir(t, tempDir); !dir.Managed(1) {
+		t.Error("Expected tree with .skeema file in descendant to be managed, but Managed returned false")
+	} else if dir.Managed(0) {
+		t.Error("Expected Managed to obey maxDepth, but it returned true")
+	}
+}
+
 func TestDirSubdirs(t *testing.T) {
 	dir := getDir(t, "../testdata/golden/init/mydb")
 	subs, err := dir.Subdirs()
@@ -168,6 +467,105 @@ func TestDirSubdirs(t *testing.T) {
 	}
 }
 
+func TestDirSubdirsIgnored(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "skeema-subdirs")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	WriteTestFile(t, filepath.Join(tempDir, ".skeema"), "host=localhost\nignore-dirs=build\n")
+	WriteTestFile(t, filepath.Join(tempDir, ".git", ".skeema"), "schema=git\n")
+	WriteTestFile(t, filepath.Join(tempDir, ".git", "foo.sql"), "CREATE TABLE foo (id int);\n")
+	WriteTestFile(t, filepath.Join(tempDir, "node_modules", "foo.sql"), "CREATE TABLE foo (id int);\n")
+	WriteTestFile(t, filepath.Join(tempDir, "vendor", ".skeema"), "schema=vendor\n")
+	WriteTestFile(t, filepath.Join(tempDir, "build", ".skeema"), "schema=build\n")
+	WriteTestFile(t, filepath.Join(tempDir, "product", ".skeema"), "schema=product\n")
+	dir := getDir(t, tempDir)
+
+	// Hidden dirs such as .git are never treated as schema dirs, nor are dirs
+	// named by ignore-dirs. Default ignored names are only skipped if they lack
+	// a .skeema file, so the schema dir named vendor is kept.
+	subs, err := dir.Subdirs()
+	if err != nil {
+		t.Fatalf("Unexpected error from Subdirs: %v", err)
+	}
+	var names []string
+	for _, sub := range subs {
+		names = append(names, sub.BaseName())
+	}
+	if strings.Join(names, ",") != "product,vendor" {
+		t.Errorf("Unexpected result from Subdirs: %v", names)
+	}
+	if subs, err := dir.SubdirsAll(); err != nil || len(subs) != 5 {
+		t.Errorf("Unexpected result from SubdirsAll: %d subdirs, err=%v", len(subs), err)
+	}
+
+	// A tree whose only .skeema files are in hidden dirs is not managed
+	if err := os.Remove(filepath.Join(tempDir, ".skeema")); err != nil {
+		t.Fatalf("Unable to remove file: %s", err)
+	}
+	for _, name := range []string{"vendor", "build", "product"} {
+		if err := os.RemoveAll(filepath.Join(tempDir, name)); err != nil {
+			t.Fatalf("Unable to remove dir: %s", err)
+		}
+	}
+	if dir := getDir(t, tempDir); dir.Managed(5) {
+		t.Error("Expected tree with .skeema file only in .git to be unmanaged, but Managed returned true")
+	}
+}
+
+func TestSkipSubdir(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "skeema-skipsubdir")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	WriteTestFile(t, filepath.Join(tempDir, "vendor", ".skeema"), "schema=vendor\n")
+	cases := map[string]bool{
+		".git":         true,
+		".idea":        true,
+		"node_modules": true,
+		"vendor":       false, // has a .skeema file
+		"build":        true,  // in extraIgnored
+		"product":      false,
+	}
+	for name, expected := range cases {
+		if actual := SkipSubdir(tempDir, name, []string{"build"}); actual != expected {
+			t.Errorf("Expected SkipSubdir(%q) to return %t, instead found %t", name, expected, actual)
+		}
+	}
+}
+
+func TestDirSubdirsEmpty(t *testing.T) {
+	// An empty subdir, with no .skeema file or *.sql files, must never be treated
+	// as mapping to a schema, even if a parent dir's .skeema defines one
+	basePath, err := ioutil.TempDir("", "skeema-subdirs-empty")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(basePath)
+	WriteTestFile(t, filepath.Join(basePath, ".skeema"), "host=127.0.0.1\nschema=product\n")
+	MakeTestDirectory(t, filepath.Join(basePath, "empty"))
+	dir := getDir(t, basePath)
+	subs, err := dir.Subdirs()
+	if err != nil {
+		t.Fatalf("Unexpected error from Subdirs(): %s", err)
+	}
+	var found bool
+	for _, sub := range subs {
+		if sub.BaseName() != "empty" {
+			continue
+		}
+		found = true
+		if sub.ParseError != nil || sub.HasSchema() || len(sub.LogicalSchemas) > 0 || sub.OptionFile != nil {
+			t.Errorf("Unexpected state for empty subdir: ParseError=%v HasSchema=%t LogicalSchemas=%d OptionFile=%v", sub.ParseError, sub.HasSchema(), len(sub.LogicalSchemas), sub.OptionFile)
+		}
+	}
+	if !found {
+		t.Error("Empty subdir not returned by Subdirs()")
+	}
+}
+
 func TestDirInstances(t *testing.T) {
 	assertInstances := func(optionValues map[string]string, expectError bool, expectedInstances ...string) []*tengo.Instance {
 		cmd := mybase.NewCommand("test", "1.0", "this is for testing", nil)
@@ -212,6 +610,14 @@ func TestDirInstances(t *testing.T) {
 	assertInstances(map[string]string{"host": "localhost", "socket": "/var/run/mysql.sock"}, false, "localhost:/var/run/mysql.sock")
 	assertInstances(map[string]string{"host": "localhost", "port": "1234", "socket": "/var/lib/mysql/mysql.sock"}, false, "localhost:/var/lib/mysql/mysql.sock")
 
+	// SSH tunnel: always uses TCP through the tunnel, even for localhost
+	sshOptions := map[string]string{"host": "localhost,some.db.host:3307", "ssh-host": "bastion.example.com", "ssh-user": "deploy", "ssh-key": "/path/to/key"}
+	for _, inst := range assertInstances(sshOptions, false, "localhost:3306", "some.db.host:3307") {
+		if !strings.Contains(inst.BaseDSN, "@skeema-ssh-") {
+			t.Errorf("Expected instance %s to use SSH tunnel network, instead found DSN %s", inst, inst.BaseDSN)
+		}
+	}
+
 	// list of static hosts
 	assertInstances(map[string]string{"host": "some.db.host,other.db.host"}, false, "some.db.host:3306", "other.db.host:3306")
 	assertInstances(map[string]string{"host": `"some.db.host, other.db.host"`, "port": "3307"}, false, "some.db.host:3307", "other.db.host:3307")
@@ -232,6 +638,124 @@ func TestDirInstances(t *testing.T) {
 	assertInstances(map[string]string{"host-wrapper": "/bin/echo -n", "host": "ignored"}, false)
 }
 
+func TestDirDDLInstance(t *testing.T) {
+	getDDLInstance := func(optionValues map[string]string) (*tengo.Instance, *tengo.Instance, error) {
+		t.Helper()
+		cmd := mybase.NewCommand("test", "1.0", "this is for testing", nil)
+		cmd.AddArg("environment", "production", false)
+		util.AddGlobalOptions(cmd)
+		cli := &mybase.CommandLine{
+			Command: cmd,
+		}
+		cfg := mybase.NewConfig(cli, mybase.SimpleSource(optionValues))
+		dir := &Dir{
+			Path:   "/tmp/dummydir",
+			Config: cfg,
+		}
+		instances, err := dir.Instances()
+		if err != nil || len(instances) != 1 {
+			t.Fatalf("Unexpected result from Instances: %v, %v", instances, err)
+		}
+		ddlInst, err := dir.DDLInstance(instances[0])
+		return instances[0], ddlInst, err
+	}
+
+	// Without ddl-user, the same instance is used
+	inst, ddlInst, err := getDDLInstance(map[string]string{"host": "some.db.host", "user": "reader", "password": "abc"})
+	if err != nil || ddlInst != inst {
+		t.Errorf("Expected DDLInstance to return same instance, instead found %v, %v", ddlInst, err)
+	}
+
+	// With ddl-user but no ddl-password, an error is returned
+	if _, _, err := getDDLInstance(map[string]string{"host": "some.db.host", "user": "reader", "ddl-user": "writer"}); err == nil {
+		t.Error("Expected error from ddl-user without ddl-password, but err was nil")
+	}
+
+	// With both, a different instance with the same address is used
+	inst, ddlInst, err = getDDLInstance(map[string]string{"host": "some.db.host:3307", "user": "reader", "password": "abc", "ddl-user": "writer", "ddl-password": "x@y", "connect-options": "wait_timeout=3"})
+	if err != nil {
+		t.Fatalf("Unexpected error from DDLInstance: %s", err)
+	}
+	if ddlInst == inst || ddlInst.String() != inst.String() || ddlInst.User != "writer" || ddlInst.Password != "x@y" {
+		t.Errorf("Unexpected DDL instance %s with user %s, password %s", ddlInst, ddlInst.User, ddlInst.Password)
+	}
+	if inst.User != "reader" || inst.Password != "abc" {
+		t.Errorf("Introspection instance unexpectedly has user %s, password %s", inst.User, inst.Password)
+	}
+}
+
+func TestDirConnectInstance(t *testing.T) {
+	// Fake server which sends an X protocol notice frame instead of a classic
+	// MySQL handshake
+	xListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	defer xListener.Close()
+	go func() {
+		for {
+			conn, err := xListener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte{5, 0, 0, 0, 0x0b, 0x08, 0x05, 0x1a, 0x00})
+			conn.Close()
+		}
+	}()
+	xPort := xListener.Addr().(*net.TCPAddr).Port
+
+	// Port with nothing listening on it
+	closedListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	closedPort := closedListener.Addr().(*net.TCPAddr).Port
+	closedListener.Close()
+
+	connectInstance := func(optionValues map[string]string) (*tengo.Instance, error) {
+		t.Helper()
+		cmd := mybase.NewCommand("test", "1.0", "this is for testing", nil)
+		cmd.AddArg("environment", "production", false)
+		util.AddGlobalOptions(cmd)
+		cli := &mybase.CommandLine{
+			Command: cmd,
+		}
+		cfg := mybase.NewConfig(cli, mybase.SimpleSource(optionValues))
+		dir := &Dir{
+			Path:   "/tmp/dummydir",
+			Config: cfg,
+		}
+		instances, err := dir.Instances()
+		if err != nil || len(instances) != 1 {
+			t.Fatalf("Unexpected result from Instances: %v, %v", instances, err)
+		}
+		return dir.ConnectInstance(instances[0])
+	}
+
+	host := fmt.Sprintf("127.0.0.1:%d", xPort)
+	_, err = connectInstance(map[string]string{"host": host})
+	if err == nil || !strings.Contains(err.Error(), "X protocol") {
+		t.Errorf("Expected error mentioning X protocol, instead found %v", err)
+	}
+
+	_, err = connectInstance(map[string]string{"host": host, "fallback-ports": fmt.Sprintf("%d,%d", xPort, closedPort)})
+	if err == nil {
+		t.Fatal("Expected error connecting to fallback ports, but err was nil")
+	}
+	for _, expected := range []string{fmt.Sprintf("port %d: ", xPort), fmt.Sprintf("port %d: ", closedPort), "X protocol"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, instead found %s", expected, err)
+		}
+	}
+	if strings.Count(err.Error(), fmt.Sprintf("port %d: ", xPort)) != 1 {
+		t.Errorf("Expected configured port to only be attempted once, instead found %s", err)
+	}
+
+	if _, err = connectInstance(map[string]string{"host": host, "fallback-ports": "3307,abc"}); err == nil || !strings.Contains(err.Error(), "invalid port") {
+		t.Errorf("Expected invalid fallback-ports error, instead found %v", err)
+	}
+}
+
 func TestDirInstanceDefaultParams(t *testing.T) {
 	getDir := func(connectOptions, flavor string) *Dir {
 		return &Dir{
@@ -287,7 +811,62 @@ func TestDirInstanceDefaultParams(t *testing.T) {
 	}
 }
 
-func getValidConfig(t *testing.T) *mybase.Config {
+func TestDirInitLeaf(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/initleaf/.skeema", "host=127.0.0.1\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	dir := getDir(t, "../testdata/.scratch/initleaf")
+
+	opts := LeafOptions{
+		Options:      map[string]string{"default-character-set": "utf8mb4"},
+		Environments: map[string]map[string]string{"staging": {"schema": "orders_staging"}},
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		leaf, err := dir.InitLeaf("orders", "orders", opts)
+		if err != nil {
+			t.Fatalf("Unexpected error from InitLeaf on attempt %d: %s", attempt, err)
+		}
+		if !leaf.HasSchema() || leaf.OptionFile == nil || leaf.Config.Get("schema") != "orders" || leaf.Config.Get("default-character-set") != "utf8mb4" {
+			t.Errorf("Unexpected state of leaf dir on attempt %d: HasSchema=%t schema=%q", attempt, leaf.HasSchema(), leaf.Config.Get("schema"))
+		}
+		expected := "default-character-set=utf8mb4\nschema=orders\n\n[staging]\nschema=orders_staging\n"
+		if contents := ReadTestFile(t, "../testdata/.scratch/initleaf/orders/.skeema"); !strings.HasSuffix(contents, expected) {
+			t.Errorf("Unexpected .skeema contents on attempt %d: %q", attempt, contents)
+		}
+	}
+
+	// Existing files are not rewritten, so manual edits are kept
+	WriteTestFile(t, "../testdata/.scratch/initleaf/orders/.skeema", "# hand-edited\nschema=orders\n")
+	if _, err := dir.InitLeaf("orders", "orders", LeafOptions{}); err != nil {
+		t.Errorf("Unexpected error from InitLeaf: %s", err)
+	} else if contents := ReadTestFile(t, "../testdata/.scratch/initleaf/orders/.skeema"); contents != "# hand-edited\nschema=orders\n" {
+		t.Errorf("Expected existing .skeema file to be left as-is, instead found %q", contents)
+	}
+
+	// Leaf with a different schema name, or dir which isn't a leaf
+	if _, err := dir.InitLeaf("orders", "payments", LeafOptions{}); err == nil {
+		t.Error("Expected error from InitLeaf with different schema name, but err was nil")
+	}
+	WriteTestFile(t, "../testdata/.scratch/initleaf/other/.skeema", "default-character-set=latin1\n")
+	if _, err := dir.InitLeaf("other", "other", LeafOptions{}); err == nil {
+		t.Error("Expected error from InitLeaf on dir without schema option, but err was nil")
+	}
+	WriteTestFile(t, "../testdata/.scratch/initleaf/sqlonly/foo.sql", "CREATE TABLE foo (id int);\n")
+	if _, err := dir.InitLeaf("sqlonly", "sqlonly", LeafOptions{}); err == nil {
+		t.Error("Expected error from InitLeaf on dir with *.sql files but no .skeema, but err was nil")
+	}
+}
+
+func getValidConfig(t *testing.T, cliArgs ...string) *mybase.Config {
+	commandLine := "fstest"
+	if len(cliArgs) > 0 {
+		commandLine = fmt.Sprintf("fstest %s", strings.Join(cliArgs, " "))
+	}
+	return mybase.ParseFakeCLI(t, getValidCommand(), commandLine)
+}
+
+// getValidCommand returns the command used by getValidConfig. This is useful
+// in benchmarks, which cannot use mybase.ParseFakeCLI.
+func getValidCommand() *mybase.Command {
 	cmd := mybase.NewCommand("fstest", "", "", nil)
 	cmd.AddOption(mybase.StringOption("schema", 0, "", "Database schema name").Hidden())
 	cmd.AddOption(mybase.StringOption("default-character-set", 0, "", "Schema-level default character set").Hidden())
@@ -295,8 +874,19 @@ func getValidConfig(t *testing.T) *mybase.Config {
 	cmd.AddOption(mybase.StringOption("host", 0, "", "Database hostname or IP address").Hidden())
 	cmd.AddOption(mybase.StringOption("port", 0, "3306", "Port to use for database host").Hidden())
 	cmd.AddOption(mybase.StringOption("flavor", 0, "", "Database server expressed in format vendor:major.minor, for use in vendor/version specific syntax").Hidden())
+	cmd.AddOption(mybase.StringOption("format-version", 0, "", "Version of .skeema file format used in this repo; set automatically by init").Hidden())
+	cmd.AddOption(mybase.StringOption("extends", 0, "", "Name of another environment section in the same option file whose options this section inherits").Hidden())
+	cmd.AddOption(mybase.StringOption("include", 0, "", "Comma-separated paths of other option files whose options this file includes").Hidden())
+	cmd.AddOption(mybase.StringOption("include-optional", 0, "", "Same as include, but paths which do not exist are skipped").Hidden())
+	cmd.AddOption(mybase.StringOption("table-template", 0, "", "Name of a CREATE TABLE in this dir used as a template for template-tables, rather than as a table").Hidden())
+	cmd.AddOption(mybase.StringOption("template-tables", 0, "", "Comma-separated names of tables generated from table-template").Hidden())
+	cmd.AddOption(mybase.StringOption("manual-migrations", 0, "", "Comma-separated paths of manual migration files which supersede generated DDL for the tables they declare").Hidden())
+	cmd.AddOption(mybase.BoolOption("respect-gitignore", 0, true, "Skip subdirectories matching .gitignore patterns, if the repo base is a git repo root"))
+	cmd.AddOption(mybase.BoolOption("environment-overrides", 0, false, "Apply environment-specific object definitions from .overrides/<environment>"))
+	cmd.AddOption(mybase.StringOption("ignore-table", 0, "", "Ignore tables whose names match this regular expression").Hidden())
+	cmd.AddOption(mybase.StringOption("ignore-dirs", 0, "", "Comma-separated names of subdirectories to skip, in addition to node_modules and vendor").Hidden())
 	cmd.AddArg("environment", "production", false)
-	return mybase.ParseFakeCLI(t, cmd, "fstest")
+	return cmd
 }
 
 func getDir(t *testing.T, dirPath string) *Dir {
@@ -316,3 +906,105 @@ func countParseErrors(subs []*Dir) (count int) {
 	}
 	return
 }
+
+func TestDirFileStatus(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	WriteTestFile(t, "../testdata/.scratch/status/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/status/notes.txt", "hello\n")
+	WriteTestFile(t, "../testdata/.scratch/status/sub/.skeema", "schema=foo\n")
+	if err := os.Symlink("notes.txt", "../testdata/.scratch/status/link"); err != nil {
+		t.Fatalf("Unable to create symlink: %s", err)
+	}
+	dir := getDir(t, "../testdata/.scratch/status")
+	cases := []struct {
+		name    string
+		isFile  bool
+		isDir   bool
+		status  FileStatus
+		comment string
+	}{
+		{"users.sql", true, false, FileExists, "regular file"},
+		{"link", true, false, FileExists, "symlink to regular file"},
+		{"sub", false, true, FileNotExist, "directory"},
+		{"nope.sql", false, false, FileNotExist, "nonexistent"},
+	}
+	for _, c := range cases {
+		if status, err := dir.FileStatus(c.name); status != c.status || err != nil {
+			t.Errorf("Expected FileStatus for %s to return %v, nil; instead found %v, %v", c.comment, c.status, status, err)
+		}
+		if has, err := dir.HasFile(c.name); has != c.isFile || err != nil {
+			t.Errorf("Expected HasFile for %s to return %t, nil; instead found %t, %v", c.comment, c.isFile, has, err)
+		}
+		if has, err := dir.HasDir(c.name); has != c.isDir || err != nil {
+			t.Errorf("Expected HasDir for %s to return %t, nil; instead found %t, %v", c.comment, c.isDir, has, err)
+		}
+	}
+
+	// A directory named .skeema is an error, not an option file
+	if err := os.MkdirAll("../testdata/.scratch/status/baddir/.skeema", 0755); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	if _, err := ParseDir("../testdata/.scratch/status/baddir", getValidConfig(t)); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("Expected ParseDir to return an error about .skeema being a directory; instead found %v", err)
+	}
+
+	// Unreadable directories are reported as permission errors. This cannot be
+	// tested when running as root, since root bypasses permission checks.
+	if os.Geteuid() == 0 {
+		t.Log("Skipping unreadable directory tests when running as root")
+		return
+	}
+	WriteTestFile(t, "../testdata/.scratch/status/unreadable/.skeema", "schema=foo\n")
+	if err := os.Chmod("../testdata/.scratch/status/unreadable", 0); err != nil {
+		t.Fatalf("Unable to chmod dir: %s", err)
+	}
+	defer os.Chmod("../testdata/.scratch/status/unreadable", 0755)
+	unreadable := &Dir{Path: "../testdata/.scratch/status/unreadable"}
+	if status, err := unreadable.FileStatus(".skeema"); status != FileDenied || !os.IsPermission(err) {
+		t.Errorf("Expected FileStatus on unreadable dir to return FileDenied and a permission error; instead found %v, %v", status, err)
+	}
+	if _, err := ParseDir("../testdata/.scratch/status/unreadable", getValidConfig(t)); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("Expected ParseDir on unreadable dir to return a permission error; instead found %v", err)
+	}
+}
+
+func TestDirDelete(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+
+	// Empty dir, and dir with only Skeema-managed files and OS metadata files
+	if err := os.MkdirAll("../testdata/.scratch/delete/empty", 0755); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	WriteTestFile(t, "../testdata/.scratch/delete/managed/.skeema", "schema=foo\n")
+	WriteTestFile(t, "../testdata/.scratch/delete/managed/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/delete/managed/.DS_Store", "")
+	WriteTestFile(t, "../testdata/.scratch/delete/managed/sub/posts.sql", "CREATE TABLE posts (id int);\n")
+	for _, name := range []string{"empty", "managed"} {
+		dir := &Dir{Path: "../testdata/.scratch/delete/" + name}
+		if err := dir.Delete(); err != nil {
+			t.Errorf("Unexpected error from Delete on %s: %s", name, err)
+		} else if _, err := os.Stat(dir.Path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted, but Stat returned %v", name, err)
+		}
+	}
+
+	// Unexpected files, including nested ones, prevent deletion
+	WriteTestFile(t, "../testdata/.scratch/delete/unmanaged/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/delete/unmanaged/sub/notes.txt", "scratch notes\n")
+	WriteTestFile(t, "../testdata/.scratch/delete/unmanaged/sub/deeper/todo.md", "- stuff\n")
+	dir := &Dir{Path: "../testdata/.scratch/delete/unmanaged"}
+	err := dir.Delete()
+	if ufe, ok := err.(UnexpectedFilesError); !ok {
+		t.Errorf("Expected Delete to return UnexpectedFilesError, instead found %v", err)
+	} else if expected := []string{"sub/deeper/todo.md", "sub/notes.txt"}; !reflect.DeepEqual(ufe.Paths, expected) {
+		t.Errorf("Expected unexpected paths %v, instead found %v", expected, ufe.Paths)
+	}
+	if _, err := os.Stat("../testdata/.scratch/delete/unmanaged/users.sql"); err != nil {
+		t.Errorf("Expected refused Delete to leave files in place, but Stat returned %v", err)
+	}
+	if err := dir.DeleteForce(); err != nil {
+		t.Errorf("Unexpected error from DeleteForce: %s", err)
+	} else if _, err := os.Stat(dir.Path); !os.IsNotExist(err) {
+		t.Errorf("Expected DeleteForce to remove dir, but Stat returned %v", err)
+	}
+}
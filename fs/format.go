@@ -0,0 +1,281 @@
+package fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FormatVersion is the current major version of the .skeema file format. It
+// is written to the format-version option by `skeema init`, and should be
+// incremented whenever an entry is added to OptionMigrations.
+const FormatVersion = 1
+
+// OptionMigration describes a change to the name or placement of an option in
+// .skeema files, introduced in a particular version of the file format.
+type OptionMigration struct {
+	FormatVersion int    // format version which introduced the change
+	OldName       string // option name as used in prior format versions
+	NewName       string // option name in the newer format; blank if not renamed
+	Section       string // if non-blank, sectionless occurrences move into this section
+}
+
+// OptionMigrations lists all changes to option names or placement, in order.
+// Supporting a future rename only requires adding an entry here, along with
+// incrementing FormatVersion.
+var OptionMigrations = []OptionMigration{}
+
+// ParseFormatVersion converts the supplied format-version option value to an
+// int major version. A blank value indicates a repo created before format
+// versions were tracked, which is treated as version 0.
+func ParseFormatVersion(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	major := strings.SplitN(value, ".", 2)[0]
+	version, err := strconv.Atoi(major)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("Option format-version has invalid value \"%s\"", value)
+	}
+	return version, nil
+}
+
+// ReadFormatVersion returns the format version configured in the sectionless
+// portion of the option file at filePath. This function intentionally does not
+// use mybase's option file parsing, since files using an older format version
+// may contain option names which are no longer valid.
+func ReadFormatVersion(filePath string) (int, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(contents), "\n") {
+		name, prefix, isHeader := parseOptionLine(line)
+		if isHeader {
+			break
+		} else if name == "format-version" && prefix == "" {
+			var value string
+			if pos := strings.IndexByte(line, '='); pos >= 0 {
+				value = strings.Trim(strings.TrimSpace(line[pos+1:]), "\"'")
+			}
+			return ParseFormatVersion(value)
+		}
+	}
+	return 0, nil
+}
+
+// formatVersionChecked tracks which repo base paths have already had their
+// format version checked, so that each warning is only logged once per repo,
+// rather than once per parsed dir.
+var (
+	formatVersionChecked   = make(map[string]bool)
+	formatVersionCheckedMu sync.Mutex
+)
+
+// checkFormatVersion logs a warning if dir's configuration indicates it was
+// generated using a newer major format version than this version of Skeema
+// understands, or if it predates an option migration. Only the first dir
+// checked in each repo is examined.
+func checkFormatVersion(dir *Dir) {
+	formatVersionCheckedMu.Lock()
+	defer formatVersionCheckedMu.Unlock()
+	if formatVersionChecked[dir.RepoBase()] {
+		return
+	}
+	formatVersionChecked[dir.RepoBase()] = true
+	version, err := ParseFormatVersion(dir.Config.Get("format-version"))
+	if err != nil {
+		log.Warn(err.Error())
+		return
+	}
+	if version > FormatVersion {
+		log.Warnf("%s was configured using .skeema format version %d, but this version of Skeema only supports format version %d. Please upgrade Skeema to avoid errors or unexpected behavior.", dir.Config.Source("format-version"), version, FormatVersion)
+	} else if len(PendingMigrations(version)) > 0 {
+		log.Warnf("Your .skeema files use an outdated format version. Please run `skeema migrate` from the top-level directory of your schema repo.")
+	}
+}
+
+// PendingMigrations returns the subset of OptionMigrations that apply to
+// files configured using the supplied format version.
+func PendingMigrations(fromVersion int) []OptionMigration {
+	var pending []OptionMigration
+	for _, m := range OptionMigrations {
+		if m.FormatVersion > fromVersion {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// MigrateOptionFile rewrites the .skeema file at filePath, applying the
+// supplied migrations. If formatVersion is positive, the file's sectionless
+// format-version option is also set to that value. The file is manipulated
+// line-by-line, so that comments and formatting are preserved. The return
+// value indicates whether the file was modified.
+func MigrateOptionFile(filePath string, migrations []OptionMigration, formatVersion int) (bool, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return false, err
+	}
+	newContents := migrateOptionLines(string(contents), migrations, formatVersion)
+	if newContents == string(contents) {
+		return false, nil
+	}
+	return true, WriteFile(filePath, []byte(newContents))
+}
+
+// migrateOptionLines implements the rewrite logic of MigrateOptionFile on the
+// supplied file contents.
+func migrateOptionLines(contents string, migrations []OptionMigration, formatVersion int) string {
+	lines := splitOptionLines(contents)
+
+	var section string
+	result := make([]string, 0, len(lines)+1)
+	moved := make(map[string][]string)
+	var movedOrder []string
+	sectionHeaders := make(map[string]int) // section name -> index in result
+	firstHeader, versionLine := -1, -1
+	for _, line := range lines {
+		name, prefix, isHeader := parseOptionLine(line)
+		if isHeader {
+			section = name
+			if firstHeader < 0 {
+				firstHeader = len(result)
+			}
+			sectionHeaders[section] = len(result)
+			result = append(result, line)
+			continue
+		} else if name == "" {
+			result = append(result, line)
+			continue
+		}
+		if name == "format-version" && prefix == "" && section == "" {
+			versionLine = len(result)
+		}
+		var moveTo string
+		for _, m := range migrations {
+			if m.OldName != name {
+				continue
+			}
+			if m.NewName != "" {
+				start := strings.Index(strings.ToLower(line), prefix+name)
+				line = line[:start] + prefix + m.NewName + line[start+len(prefix+name):]
+				name = m.NewName
+			}
+			if m.Section != "" && section == "" {
+				moveTo = m.Section
+			}
+		}
+		if moveTo != "" {
+			if _, already := moved[moveTo]; !already {
+				movedOrder = append(movedOrder, moveTo)
+			}
+			moved[moveTo] = append(moved[moveTo], line)
+		} else {
+			result = append(result, line)
+		}
+	}
+
+	if formatVersion > 0 {
+		versionDef := fmt.Sprintf("format-version=%d\n", formatVersion)
+		if versionLine >= 0 {
+			result[versionLine] = versionDef
+		} else {
+			pos := len(result)
+			if firstHeader >= 0 {
+				pos = firstHeader
+			}
+			result = append(result[:pos], append([]string{versionDef}, result[pos:]...)...)
+			for section, idx := range sectionHeaders {
+				if idx >= pos {
+					sectionHeaders[section] = idx + 1
+				}
+			}
+		}
+	}
+
+	// Insert moved lines directly after their section's header, or in a new
+	// section at the end of the file if the section does not exist yet
+	for _, section := range movedOrder {
+		if idx, ok := sectionHeaders[section]; ok {
+			result = append(result[:idx+1], append(moved[section], result[idx+1:]...)...)
+			for other, otherIdx := range sectionHeaders {
+				if otherIdx > idx {
+					sectionHeaders[other] = otherIdx + len(moved[section])
+				}
+			}
+		} else {
+			if len(result) > 0 && strings.TrimSpace(result[len(result)-1]) != "" {
+				result = append(result, "\n")
+			}
+			result = append(result, fmt.Sprintf("[%s]\n", section))
+			result = append(result, moved[section]...)
+		}
+	}
+	return strings.Join(result, "")
+}
+
+// splitOptionLines splits option file contents into lines, each retaining its
+// trailing newline. A newline is added to the final line if it lacks one.
+func splitOptionLines(contents string) []string {
+	lines := strings.SplitAfter(contents, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
+
+// OptionFileSections returns the names of all non-blank sections in the option
+// file at filePath, in order of appearance. Like ReadFormatVersion, this does
+// not require the file to be parseable by mybase.
+func OptionFileSections(filePath string) ([]string, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var sections []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(contents), "\n") {
+		if name, _, isHeader := parseOptionLine(line); isHeader && name != "" && !seen[name] {
+			sections = append(sections, name)
+			seen[name] = true
+		}
+	}
+	return sections, nil
+}
+
+// parseOptionLine examines a single line of an option file. If the line is a
+// section header, the section name is returned and isHeader is true. If the
+// line sets an option, the option name is returned, along with any modifier
+// prefix such as "skip-" or "loose-". Otherwise, a blank name is returned.
+func parseOptionLine(line string) (name, prefix string, isHeader bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || line[0] == '#' || line[0] == ';' {
+		return "", "", false
+	}
+	if line[0] == '[' {
+		if end := strings.IndexByte(line, ']'); end > 0 {
+			return strings.TrimSpace(line[1:end]), "", true
+		}
+		return "", "", false
+	}
+	name = line
+	if end := strings.IndexAny(line, "= \t#"); end >= 0 {
+		name = line[:end]
+	}
+	name = strings.ToLower(name)
+	for _, p := range []string{"loose-", "skip-", "disable-", "enable-"} {
+		if strings.HasPrefix(name, p) {
+			prefix += p
+			name = name[len(p):]
+		}
+	}
+	return name, prefix, false
+}
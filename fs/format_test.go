@@ -0,0 +1,116 @@
+package fs
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestParseFormatVersion(t *testing.T) {
+	cases := map[string]int{
+		"":    0,
+		"0":   0,
+		"1":   1,
+		"2.3": 2,
+	}
+	for input, expected := range cases {
+		if actual, err := ParseFormatVersion(input); err != nil || actual != expected {
+			t.Errorf("Expected ParseFormatVersion(%q) to return %d, nil; instead found %d, %v", input, expected, actual, err)
+		}
+	}
+	for _, input := range []string{"one", "-1", ".5"} {
+		if _, err := ParseFormatVersion(input); err == nil {
+			t.Errorf("Expected ParseFormatVersion(%q) to return an error, but it did not", input)
+		}
+	}
+}
+
+func TestReadFormatVersion(t *testing.T) {
+	WriteTestFile(t, "testdata/.scratch/.skeema", "# comment\nformat-version='3'\n[production]\nformat-version=4\n")
+	defer RemoveTestDirectory(t, "testdata/.scratch")
+	if version, err := ReadFormatVersion("testdata/.scratch/.skeema"); version != 3 || err != nil {
+		t.Errorf("Unexpected return from ReadFormatVersion: %d, %v", version, err)
+	}
+	WriteTestFile(t, "testdata/.scratch/.skeema", "[production]\nformat-version=4\n")
+	if version, err := ReadFormatVersion("testdata/.scratch/.skeema"); version != 0 || err != nil {
+		t.Errorf("Unexpected return from ReadFormatVersion: %d, %v", version, err)
+	}
+}
+
+func TestCheckFormatVersionOncePerRepo(t *testing.T) {
+	repoPath, err := ioutil.TempDir("", "skeema-format-version")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(repoPath)
+	WriteTestFile(t, filepath.Join(repoPath, ".skeema"), "format-version=99\n")
+	WriteTestFile(t, filepath.Join(repoPath, "one", ".skeema"), "schema=one\n")
+	WriteTestFile(t, filepath.Join(repoPath, "two", ".skeema"), "schema=two\n")
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	for _, subPath := range []string{"", "one", "two", ""} {
+		getDir(t, filepath.Join(repoPath, subPath))
+	}
+	if count := strings.Count(buf.String(), "format version 99"); count != 1 {
+		t.Errorf("Expected format version warning to be logged once, instead found %d times in output:\n%s", count, buf.String())
+	}
+}
+
+func TestMigrateOptionLines(t *testing.T) {
+	migrations := []OptionMigration{
+		{FormatVersion: 1, OldName: "old-name", NewName: "new-name"},
+		{FormatVersion: 1, OldName: "old-flag", NewName: "new-flag"},
+		{FormatVersion: 1, OldName: "host", Section: "production"},
+	}
+	input := `# top comment
+old-name=foo # trailing comment
+host=localhost
+loose-skip-Old-Flag
+
+[production]
+# production comment
+port=3306
+
+[staging]
+host=staging.example.com
+old-name=bar
+`
+	expected := `# top comment
+new-name=foo # trailing comment
+loose-skip-new-flag
+
+format-version=2
+[production]
+host=localhost
+# production comment
+port=3306
+
+[staging]
+host=staging.example.com
+new-name=bar
+`
+	if actual := migrateOptionLines(input, migrations, 2); actual != expected {
+		t.Errorf("Unexpected result from migrateOptionLines:\n%s", actual)
+	}
+
+	// Test moving into a section that doesn't exist yet, and replacing an
+	// existing format-version
+	input = "format-version=1\nhost=localhost\nport=3306"
+	expected = "format-version=2\nport=3306\n\n[production]\nhost=localhost\n"
+	if actual := migrateOptionLines(input, migrations, 2); actual != expected {
+		t.Errorf("Unexpected result from migrateOptionLines:\n%s", actual)
+	}
+
+	// Test no-op situation
+	input = "format-version=2\n[production]\nhost=localhost\n"
+	if actual := migrateOptionLines(input, migrations, 2); actual != input {
+		t.Errorf("Unexpected result from migrateOptionLines:\n%s", actual)
+	}
+}
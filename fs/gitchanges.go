@@ -0,0 +1,177 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// GitChanges describes the *.sql files and option files which differ between a
+// git revision and the current working tree, including uncommitted and
+// untracked files. It is used to narrow operations to only the objects
+// affected by those changes.
+type GitChanges struct {
+	Ref         string   // git revision supplied to ChangesSince
+	SQLFiles    []string // repo-relative paths of changed *.sql files, at either revision
+	OptionFiles []string // repo-relative paths of changed .skeema option files
+	objects     map[string]map[tengo.ObjectKey]bool
+	optionDirs  []string
+}
+
+// ChangesSince shells out to git to determine which files have changed in the
+// repository containing dirPath since the supplied revision. For each changed
+// *.sql file, both its current version and its version as of ref are
+// tokenized, so that objects which were removed or renamed are also included.
+// A renamed file is treated as a removal plus an addition. An error is
+// returned if git is not installed, if dirPath is not inside of a git
+// repository, or if ref is not a valid revision.
+func ChangesSince(dirPath, ref string) (*GitChanges, error) {
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("Invalid git revision %q", ref)
+	}
+	out, err := runGit(dirPath, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to use git revision %s: %s is not inside of a git repository: %s", ref, dirPath, err)
+	}
+	repoBase := canonicalPath(strings.TrimSpace(out))
+	if _, err := runGit(repoBase, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("Unable to use git revision %s: unknown revision", ref)
+	}
+
+	// Changes to tracked files, including uncommitted ones, are obtained from
+	// git diff. Untracked files are new relative to any revision.
+	out, err = runGit(repoBase, "diff", "--name-status", "--no-renames", "-z", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	var changed []gitChange
+	fields := strings.Split(strings.TrimSuffix(out, "\000"), "\000")
+	for n := 0; n+1 < len(fields); n += 2 {
+		changed = append(changed, gitChange{status: fields[n][0], path: fields[n+1]})
+	}
+	out, err = runGit(repoBase, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, untracked := range strings.Split(strings.TrimSuffix(out, "\000"), "\000") {
+		if untracked != "" {
+			changed = append(changed, gitChange{status: 'A', path: untracked})
+		}
+	}
+
+	gc := &GitChanges{
+		Ref:     ref,
+		objects: make(map[string]map[tengo.ObjectKey]bool),
+	}
+	for _, change := range changed {
+		absPath := filepath.Join(repoBase, filepath.FromSlash(change.path))
+		name := filepath.Base(absPath)
+		if name == ".skeema" || name == ".skeema.enc" {
+			gc.OptionFiles = append(gc.OptionFiles, change.path)
+			gc.optionDirs = append(gc.optionDirs, filepath.Dir(absPath))
+			continue
+		} else if !strings.HasSuffix(name, ".sql") || IsPassthroughFile(name) || IsGrantsFile(name) {
+			continue
+		}
+		gc.SQLFiles = append(gc.SQLFiles, change.path)
+		keys := gc.objects[filepath.Dir(absPath)]
+		if keys == nil {
+			keys = make(map[tengo.ObjectKey]bool)
+			gc.objects[filepath.Dir(absPath)] = keys
+		}
+
+		// Tokenization errors are ignored here, since they will be reported by the
+		// normal handling of the current version, and an old version which can't be
+		// tokenized can't be diff'ed against anyway
+		var statements []*Statement
+		if change.status != 'D' {
+			sf := SQLFile{Dir: filepath.Dir(absPath), FileName: name}
+			if tokenized, err := sf.Tokenize(); err == nil {
+				statements = tokenized.Statements
+			}
+		}
+		if change.status != 'A' {
+			if old, err := runGit(repoBase, "show", ref+":"+change.path); err == nil {
+				tokenizer := newStatementTokenizer(absPath, ";")
+				oldStatements, _ := tokenizer.statementsFrom(strings.NewReader(old))
+				statements = append(statements, oldStatements...)
+			}
+		}
+		for _, stmt := range statements {
+			if stmt.Type == StatementTypeCreate && stmt.ObjectName != "" {
+				keys[stmt.ObjectKey()] = true
+			}
+		}
+	}
+	return gc, nil
+}
+
+// gitChange represents one line of output from git diff --name-status.
+type gitChange struct {
+	status byte // 'A' for added, 'D' for deleted, 'M' for modified, etc
+	path   string
+}
+
+// ObjectKeys returns the keys of objects defined in the *.sql files of the
+// supplied dir that are affected by gc, at either revision. If the second
+// return value is false, the dir cannot be narrowed, because the option file
+// of the dir or one of its parent dirs has changed, and option changes may
+// affect any object; in this case the first return value is nil. A nil gc
+// never narrows any dir.
+func (gc *GitChanges) ObjectKeys(dirPath string) ([]tengo.ObjectKey, bool) {
+	if gc == nil {
+		return nil, false
+	}
+	dirPath = canonicalPath(dirPath)
+	for _, optionDir := range gc.optionDirs {
+		if rel, err := filepath.Rel(optionDir, dirPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, false
+		}
+	}
+	keys := make([]tengo.ObjectKey, 0, len(gc.objects[dirPath]))
+	for key := range gc.objects[dirPath] {
+		keys = append(keys, key)
+	}
+	return keys, true
+}
+
+// runGit runs git with the supplied args in dirPath, returning its STDOUT. If
+// git exits non-zero, the returned error includes its STDERR.
+func runGit(dirPath string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dirPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// canonicalPath returns an absolute version of p with any symlinks resolved,
+// so that it may be compared to paths reported by git. If resolution fails, a
+// cleaned absolute path is returned instead.
+func canonicalPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(p); err == nil {
+		return resolved
+	} else if _, statErr := os.Stat(p); statErr != nil {
+		// For paths which no longer exist, resolve the nearest existing parent
+		if parent, err := filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+			return filepath.Join(parent, filepath.Base(p))
+		}
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,119 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestChangesSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repoDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(repoDir)
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("Unexpected error from git %v: %s\n%s", args, err, out)
+		}
+	}
+
+	// Before the repo is initialized, an error should be returned
+	if _, err := ChangesSince(repoDir, "HEAD"); err == nil {
+		t.Error("Expected error from ChangesSince outside of a git repo, but err is nil")
+	}
+
+	createTable := func(name string) string {
+		return "CREATE TABLE " + name + " (id int unsigned NOT NULL, PRIMARY KEY (id));\n"
+	}
+	WriteTestFile(t, filepath.Join(repoDir, ".skeema"), "host=127.0.0.1\n")
+	WriteTestFile(t, filepath.Join(repoDir, "product", ".skeema"), "schema=product\n")
+	WriteTestFile(t, filepath.Join(repoDir, "product", "users.sql"), createTable("users"))
+	WriteTestFile(t, filepath.Join(repoDir, "product", "posts.sql"), createTable("posts"))
+	WriteTestFile(t, filepath.Join(repoDir, "product", "old.sql"), createTable("old_name"))
+	WriteTestFile(t, filepath.Join(repoDir, "product", "gone.sql"), createTable("gone"))
+	WriteTestFile(t, filepath.Join(repoDir, "analytics", ".skeema"), "schema=analytics\n")
+	WriteTestFile(t, filepath.Join(repoDir, "analytics", "events.sql"), createTable("events"))
+	git("init", "-q")
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	if _, err := ChangesSince(repoDir, "doesnotexist"); err == nil {
+		t.Error("Expected error from ChangesSince with invalid revision, but err is nil")
+	}
+	if _, err := ChangesSince(repoDir, "--output=foo"); err == nil {
+		t.Error("Expected error from ChangesSince with option-like revision, but err is nil")
+	}
+
+	// A committed modification, a committed rename of both file and table, an
+	// uncommitted deletion, and an untracked new file
+	WriteTestFile(t, filepath.Join(repoDir, "product", "users.sql"), "CREATE TABLE users (id int unsigned NOT NULL, name varchar(30), PRIMARY KEY (id));\n")
+	git("mv", "product/old.sql", "product/new.sql")
+	WriteTestFile(t, filepath.Join(repoDir, "product", "new.sql"), createTable("new_name"))
+	git("commit", "-q", "-a", "-m", "second")
+	RemoveTestFile(t, filepath.Join(repoDir, "product", "gone.sql"))
+	WriteTestFile(t, filepath.Join(repoDir, "product", "comments.sql"), createTable("comments"))
+
+	gc, err := ChangesSince(filepath.Join(repoDir, "product"), "HEAD~1")
+	if err != nil {
+		t.Fatalf("Unexpected error from ChangesSince: %s", err)
+	}
+	if len(gc.OptionFiles) != 0 || len(gc.SQLFiles) != 5 {
+		t.Errorf("Unexpected changed files: option files %v, sql files %v", gc.OptionFiles, gc.SQLFiles)
+	}
+	keys, narrowed := gc.ObjectKeys(filepath.Join(repoDir, "product"))
+	var names []string
+	for _, key := range keys {
+		if key.Type != tengo.ObjectTypeTable {
+			t.Errorf("Unexpected object type in key %s", key)
+		}
+		names = append(names, key.Name)
+	}
+	sort.Strings(names)
+	if !narrowed || len(names) != 5 || names[0] != "comments" || names[1] != "gone" || names[2] != "new_name" || names[3] != "old_name" || names[4] != "users" {
+		t.Errorf("Unexpected return from ObjectKeys: %v, %t", names, narrowed)
+	}
+	if keys, narrowed := gc.ObjectKeys(filepath.Join(repoDir, "analytics")); !narrowed || len(keys) != 0 {
+		t.Errorf("Unexpected return from ObjectKeys for unchanged dir: %v, %t", keys, narrowed)
+	}
+
+	// Changing an option file prevents narrowing in its dir and subdirs only
+	WriteTestFile(t, filepath.Join(repoDir, "product", ".skeema"), "schema=product\ndefault-character-set=utf8mb4\n")
+	if gc, err = ChangesSince(repoDir, "HEAD"); err != nil {
+		t.Fatalf("Unexpected error from ChangesSince: %s", err)
+	}
+	if len(gc.OptionFiles) != 1 || gc.OptionFiles[0] != "product/.skeema" {
+		t.Errorf("Unexpected changed option files: %v", gc.OptionFiles)
+	}
+	if _, narrowed := gc.ObjectKeys(filepath.Join(repoDir, "product")); narrowed {
+		t.Error("Expected dir with changed option file to not be narrowed")
+	}
+	if _, narrowed := gc.ObjectKeys(filepath.Join(repoDir, "analytics")); !narrowed {
+		t.Error("Expected sibling of dir with changed option file to still be narrowed")
+	}
+	WriteTestFile(t, filepath.Join(repoDir, ".skeema"), "host=127.0.0.2\n")
+	if gc, err = ChangesSince(repoDir, "HEAD"); err != nil {
+		t.Fatalf("Unexpected error from ChangesSince: %s", err)
+	}
+	if _, narrowed := gc.ObjectKeys(filepath.Join(repoDir, "analytics")); narrowed {
+		t.Error("Expected subdir of dir with changed option file to not be narrowed")
+	}
+
+	// A nil *GitChanges never narrows
+	gc = nil
+	if keys, narrowed := gc.ObjectKeys(repoDir); narrowed || keys != nil {
+		t.Errorf("Unexpected return from ObjectKeys on nil receiver: %v, %t", keys, narrowed)
+	}
+}
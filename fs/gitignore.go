@@ -0,0 +1,114 @@
+package fs
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignorePattern represents a single pattern line from a .gitignore file.
+// Only a subset of git's pattern syntax is supported: literal paths, globs
+// using *, ?, and [...], a trailing slash to only match directories, a leading
+// or interior slash to anchor the pattern to the .gitignore's directory, a
+// leading **/ to match in any directory, and a leading ! to negate a previous
+// match.
+type gitignorePattern struct {
+	base     string // absolute path of the dir containing the .gitignore file
+	glob     string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseGitignorePattern parses a single line of a .gitignore file located in
+// base. The boolean return value will be false if the line is blank or a
+// comment.
+func parseGitignorePattern(base, line string) (gitignorePattern, bool) {
+	p := gitignorePattern{base: base}
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return p, false
+	}
+	if line[0] == '!' {
+		p.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:] // escaped leading # or !
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	for strings.HasPrefix(line, "**/") {
+		line = line[3:]
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return p, false
+	}
+	p.glob = line
+	return p, true
+}
+
+// matches returns true if the supplied absolute path matches the pattern. The
+// path is ignored if it is not within the pattern's base dir.
+func (p gitignorePattern) matches(absPath string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(p.base, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if !p.anchored {
+		rel = path.Base(rel)
+	}
+	matched, _ := path.Match(p.glob, rel)
+	return matched
+}
+
+// gitignore is an ordered list of patterns from one or more .gitignore files,
+// with patterns from parent dirs' files preceding those of subdirs' files.
+type gitignore []gitignorePattern
+
+// readGitignore returns a copy of gi with any patterns from dirPath's
+// .gitignore file appended. If dirPath has no .gitignore file, or it cannot be
+// read, gi is returned unchanged. The file is only opened if snapshot's listing
+// of dirPath includes it.
+func (gi gitignore) readGitignore(dirPath string, snapshot *TreeSnapshot) gitignore {
+	if entry, _ := snapshot.Entry(dirPath, ".gitignore"); entry == nil {
+		return gi
+	}
+	f, err := os.Open(filepath.Join(dirPath, ".gitignore"))
+	if err != nil {
+		return gi
+	}
+	defer f.Close()
+	result := make(gitignore, len(gi), len(gi)+10)
+	copy(result, gi)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if p, ok := parseGitignorePattern(dirPath, scanner.Text()); ok {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// ignored returns true if the supplied absolute path is ignored by gi. As with
+// git, the last matching pattern takes precedence, so a negated pattern may
+// un-ignore a path matched by an earlier pattern.
+func (gi gitignore) ignored(absPath string, isDir bool) (ignored bool) {
+	for _, p := range gi {
+		if p.matches(absPath, isDir) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
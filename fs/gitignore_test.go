@@ -0,0 +1,133 @@
+package fs
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseGitignorePattern(t *testing.T) {
+	cases := []struct {
+		line     string
+		ok       bool
+		expected gitignorePattern
+	}{
+		{"", false, gitignorePattern{}},
+		{"   ", false, gitignorePattern{}},
+		{"# comment", false, gitignorePattern{}},
+		{"build", true, gitignorePattern{glob: "build"}},
+		{"build/  ", true, gitignorePattern{glob: "build", dirOnly: true}},
+		{"/build", true, gitignorePattern{glob: "build", anchored: true}},
+		{"gen/*.sql", true, gitignorePattern{glob: "gen/*.sql", anchored: true}},
+		{"!keep/", true, gitignorePattern{glob: "keep", negate: true, dirOnly: true}},
+		{"\\#notcomment", true, gitignorePattern{glob: "#notcomment"}},
+		{"\\!notnegated", true, gitignorePattern{glob: "!notnegated"}},
+		{"**/fixtures", true, gitignorePattern{glob: "fixtures"}},
+		{"**/out/tmp", true, gitignorePattern{glob: "out/tmp", anchored: true}},
+		{"/", false, gitignorePattern{}},
+	}
+	for _, c := range cases {
+		p, ok := parseGitignorePattern("/repo", c.line)
+		if ok != c.ok {
+			t.Errorf("Expected parseGitignorePattern(%q) to return ok=%t, instead found %t", c.line, c.ok, ok)
+			continue
+		} else if !ok {
+			continue
+		}
+		c.expected.base = "/repo"
+		if p != c.expected {
+			t.Errorf("Expected parseGitignorePattern(%q) to return %+v, instead found %+v", c.line, c.expected, p)
+		}
+	}
+}
+
+func TestGitignoreIgnored(t *testing.T) {
+	var gi gitignore
+	for _, line := range []string{"build/", "*.tmp", "/gen", "docs/out", "!build/keep", "!/important.tmp"} {
+		p, _ := parseGitignorePattern("/repo", line)
+		gi = append(gi, p)
+	}
+	p, _ := parseGitignorePattern("/repo/sub", "local")
+	gi = append(gi, p)
+
+	cases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"/repo/build", true, true},
+		{"/repo/nested/build", true, true},
+		{"/repo/build", false, false}, // dir-only pattern
+		{"/repo/build/keep", true, false},
+		{"/repo/x.tmp", false, true},
+		{"/repo/deep/er/x.tmp", true, true},
+		{"/repo/important.tmp", false, false},
+		{"/repo/deep/important.tmp", false, true}, // negation is anchored
+		{"/repo/gen", true, true},
+		{"/repo/nested/gen", true, false}, // anchored
+		{"/repo/docs/out", true, true},
+		{"/repo/nested/docs/out", true, false},
+		{"/repo/sub/local", true, true},
+		{"/repo/local", true, false}, // pattern only applies within its own dir
+		{"/repo", true, false},
+		{"/elsewhere/build", true, false},
+		{"/repo/schemas", true, false},
+	}
+	for _, c := range cases {
+		if actual := gi.ignored(c.path, c.isDir); actual != c.expected {
+			t.Errorf("Expected ignored(%q, %t) to return %t, instead found %t", c.path, c.isDir, c.expected, actual)
+		}
+	}
+}
+
+func TestDirSubdirsGitignore(t *testing.T) {
+	base := "../testdata/.scratch/gitrepo"
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	MakeTestDirectory(t, filepath.Join(base, ".git"))
+	WriteTestFile(t, filepath.Join(base, ".gitignore"), "build/\n/tmp*\n")
+	WriteTestFile(t, filepath.Join(base, "schemas", ".gitignore"), "fixtures\n!build\n")
+	for _, name := range []string{"build", "tmp1", "schemas/fixtures", "schemas/build", "schemas/product", "schemas/product/tmp2"} {
+		MakeTestDirectory(t, filepath.Join(base, name))
+	}
+
+	subdirNames := func(dir *Dir) map[string]bool {
+		t.Helper()
+		subs, err := dir.Subdirs()
+		if err != nil {
+			t.Fatalf("Unexpected error from Subdirs: %s", err)
+		}
+		names := make(map[string]bool, len(subs))
+		for _, sub := range subs {
+			names[sub.BaseName()] = true
+		}
+		return names
+	}
+
+	dir := getDir(t, base)
+	if names := subdirNames(dir); len(names) != 1 || !names["schemas"] {
+		t.Errorf("Unexpected subdirs of repo root: %v", names)
+	}
+	schemasDir := getDir(t, filepath.Join(base, "schemas"))
+	if names := subdirNames(schemasDir); len(names) != 2 || !names["build"] || !names["product"] {
+		t.Errorf("Unexpected subdirs of schemas: %v", names)
+	}
+	productDir := getDir(t, filepath.Join(base, "schemas", "product"))
+	if names := subdirNames(productDir); len(names) != 1 || !names["tmp2"] {
+		t.Errorf("Unexpected subdirs of schemas/product: %v", names)
+	}
+
+	// Subdirs of subdirs must inherit and extend the patterns
+	subs, _ := dir.Subdirs()
+	if names := subdirNames(subs[0]); len(names) != 2 || !names["build"] || !names["product"] {
+		t.Errorf("Unexpected subdirs of schemas via walk: %v", names)
+	}
+
+	// With respect-gitignore disabled, everything should be returned
+	dir, err := ParseDir(base, getValidConfig(t, "--skip-respect-gitignore"))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	if names := subdirNames(dir); len(names) != 3 {
+		t.Errorf("Unexpected subdirs with respect-gitignore disabled: %v", names)
+	}
+
+}
@@ -0,0 +1,43 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GrantsFileName is the name of the optional file in a leaf dir which defines
+// the privileges held on the dir's schema by the users listed in the
+// manage-grants option. It contains only GRANT statements. It is never parsed
+// into a dir's logical schemas, and never modified by `skeema pull` or
+// `skeema format`. The leading dot ensures it cannot collide with the file of
+// any object, since PathForObject always encodes dots.
+const GrantsFileName = ".grants.sql"
+
+// IsGrantsFile returns true if name is GrantsFileName.
+func IsGrantsFile(name string) bool {
+	return strings.ToLower(name) == GrantsFileName
+}
+
+// GrantStatements tokenizes sf, which should be a grants file, and returns its
+// GRANT statements, excluding comments and DELIMITER commands. An error is
+// returned if the file cannot be tokenized, or if it contains any other type
+// of statement.
+func GrantStatements(sf SQLFile) ([]*Statement, error) {
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	var statements []*Statement
+	for _, stmt := range tokenizedFile.Statements {
+		body := strings.TrimSpace(stmt.Body())
+		if stmt.Type == StatementTypeNoop {
+			continue
+		} else if stmt.Type == StatementTypeCommand && strings.HasPrefix(strings.ToLower(body), "delimiter") {
+			continue
+		} else if words := strings.Fields(body); len(words) < 2 || !strings.EqualFold(words[0], "grant") {
+			return nil, fmt.Errorf("%s: only GRANT statements are permitted in %s", stmt.Location(), GrantsFileName)
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"path"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestDirGrantsFile(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	WriteTestFile(t, "../testdata/.scratch/mydb/.skeema", "schema=mydb\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/.grants.sql", "-- app privileges\nGRANT SELECT, INSERT ON * TO app;\nGRANT SELECT (id) ON users TO 'reporting'@'10.%';\n")
+	dir := getDir(t, "../testdata/.scratch/mydb")
+	if len(dir.SQLFiles) != 1 || dir.SQLFiles[0].FileName != "users.sql" {
+		t.Errorf("Expected SQLFiles to only contain users.sql, instead found %v", dir.SQLFiles)
+	}
+	if dir.GrantsFile == nil || dir.GrantsFile.FileName != ".grants.sql" {
+		t.Fatalf("Unexpected GrantsFile: %v", dir.GrantsFile)
+	}
+	if len(dir.IgnoredStatements) > 0 {
+		t.Errorf("Expected grants file to not be parsed, but found ignored statements: %v", dir.IgnoredStatements)
+	}
+	statements, err := GrantStatements(*dir.GrantsFile)
+	if err != nil {
+		t.Fatalf("Unexpected error from GrantStatements: %s", err)
+	}
+	if len(statements) != 2 || statements[1].Body() != "GRANT SELECT (id) ON users TO 'reporting'@'10.%'" {
+		t.Errorf("Unexpected statements from GrantStatements: %+v", statements)
+	}
+	if problems := ValidateTree(dir, 0); len(problems) > 0 {
+		t.Errorf("Expected no problems from ValidateTree, instead found %v", problems)
+	}
+
+	// Statements other than GRANT are errors
+	for _, contents := range []string{"REVOKE SELECT ON * FROM app;\n", "USE mydb;\nGRANT SELECT ON * TO app;\n", "GRANT SELECT ON * TO 'app;\n"} {
+		WriteTestFile(t, "../testdata/.scratch/mydb/.grants.sql", contents)
+		if _, err := GrantStatements(*dir.GrantsFile); err == nil {
+			t.Errorf("Expected error from GrantStatements with contents %q, but err was nil", contents)
+		}
+		if problems := ValidateTree(dir, 0); len(problems) != 1 {
+			t.Errorf("Expected 1 problem from ValidateTree with contents %q, instead found %v", contents, problems)
+		}
+	}
+
+	// Dirs containing grants files are not deleted, since they are not managed
+	// by pull
+	if err := dir.Delete(); err == nil {
+		t.Error("Expected Delete to refuse deleting dir containing grants file, but err was nil")
+	} else if ufe, ok := err.(UnexpectedFilesError); !ok || len(ufe.Paths) != 1 {
+		t.Errorf("Unexpected error from Delete: %v", err)
+	}
+}
+
+func TestDirGrantsTable(t *testing.T) {
+	// A file for a table named grants is an ordinary table file, regardless of
+	// whether any option refers to grants
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	WriteTestFile(t, "../testdata/.scratch/mydb/.skeema", "schema=mydb\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/grants.sql", "CREATE TABLE grants (id int);\n")
+	dir := getDir(t, "../testdata/.scratch/mydb")
+	if dir.GrantsFile != nil {
+		t.Errorf("Expected no GrantsFile, instead found %v", dir.GrantsFile)
+	}
+	if len(dir.SQLFiles) != 1 || dir.SQLFiles[0].FileName != "grants.sql" {
+		t.Errorf("Expected SQLFiles to contain grants.sql, instead found %v", dir.SQLFiles)
+	}
+	if len(dir.LogicalSchemas) != 1 || dir.LogicalSchemas[0].Creates[tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "grants"}] == nil {
+		t.Errorf("Expected table grants to be parsed, instead found %+v", dir.LogicalSchemas)
+	}
+	if PathForObject(dir.Path, ".grants") == path.Join(dir.Path, GrantsFileName) {
+		t.Error("Expected file for object named .grants to not collide with GrantsFileName")
+	}
+}
@@ -0,0 +1,148 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ManualMigration represents a hand-written SQL file which transitions a set
+// of tables from one state to another, for situations where the generated DDL
+// would be undesirable, such as moving a column between tables without losing
+// its data. Manual migrations are configured by the manual-migrations option in
+// a dir's .skeema file.
+//
+// The file's header declares the expected state of each affected table before
+// and after the migration, using comment lines of the form
+//
+//	-- skeema:before table_name fingerprint
+//	-- skeema:after table_name fingerprint
+//
+// where fingerprint is a table definition hash as computed by package applier,
+// or "-" to indicate the table does not exist in that state.
+type ManualMigration struct {
+	File       SQLFile
+	RelPath    string            // path relative to the dir, as configured in manual-migrations
+	Before     map[string]string // table name => expected fingerprint prior to migration
+	After      map[string]string // table name => expected fingerprint after migration
+	Statements []*Statement      // SQL statements to execute, excluding comments and DELIMITER commands
+}
+
+// Tables returns the sorted names of all tables declared in mm's header.
+func (mm *ManualMigration) Tables() []string {
+	names := make([]string, 0, len(mm.Before))
+	for name := range mm.Before {
+		names = append(names, name)
+	}
+	for name := range mm.After {
+		if _, ok := mm.Before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (mm *ManualMigration) String() string {
+	return mm.RelPath
+}
+
+var reMigrationHeader = regexp.MustCompile("^--\\s*skeema:(before|after)\\s+(`(?:[^`]|``)+`|\\S+)\\s+([0-9a-f]{64}|-)\\s*$")
+
+// ManualMigrations parses the files listed in the manual-migrations option of
+// the dir's own option file, returning them in the configured order. An error
+// is returned if a file cannot be read, has an invalid or missing header, or
+// contains a USE command.
+func (dir *Dir) ManualMigrations() ([]*ManualMigration, error) {
+	if dir.OptionFile == nil {
+		return nil, nil
+	}
+	value, _ := dir.OptionFile.OptionValue("manual-migrations")
+	var migrations []*ManualMigration
+	for _, relPath := range strings.Split(value, ",") {
+		if relPath = strings.TrimSpace(relPath); relPath == "" {
+			continue
+		}
+		mm, err := parseManualMigration(dir.Path, relPath)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, mm)
+	}
+	return migrations, nil
+}
+
+func parseManualMigration(dirPath, relPath string) (*ManualMigration, error) {
+	fullPath := filepath.Join(dirPath, filepath.FromSlash(relPath))
+	mm := &ManualMigration{
+		File:    SQLFile{Dir: filepath.Dir(fullPath), FileName: filepath.Base(fullPath)},
+		RelPath: relPath,
+		Before:  make(map[string]string),
+		After:   make(map[string]string),
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read manual migration %s: %s", relPath, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "-- skeema:") && !strings.HasPrefix(line, "--skeema:") {
+			continue
+		}
+		matches := reMigrationHeader.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("%s:%d: malformed manual migration header line", fullPath, lineNo)
+		}
+		name := matches[2]
+		if name[0] == '`' {
+			name = strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+		}
+		states := mm.Before
+		if matches[1] == "after" {
+			states = mm.After
+		}
+		if _, already := states[name]; already {
+			return nil, fmt.Errorf("%s:%d: table %s declared multiple times in %s state", fullPath, lineNo, name, matches[1])
+		}
+		states[name] = matches[3]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read manual migration %s: %s", relPath, err)
+	}
+	for _, name := range mm.Tables() {
+		if _, ok := mm.Before[name]; !ok {
+			return nil, fmt.Errorf("%s: table %s is missing a skeema:before header line", fullPath, name)
+		} else if _, ok := mm.After[name]; !ok {
+			return nil, fmt.Errorf("%s: table %s is missing a skeema:after header line", fullPath, name)
+		}
+	}
+	if len(mm.Before) == 0 {
+		return nil, fmt.Errorf("%s: manual migration has no skeema:before or skeema:after header lines", fullPath)
+	}
+
+	tokenizedFile, err := mm.File.Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range tokenizedFile.Statements {
+		if stmt.Type == StatementTypeNoop {
+			continue
+		} else if stmt.Type == StatementTypeCommand {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(stmt.Text)), "use") {
+				return nil, fmt.Errorf("%s: USE commands are not permitted in manual migrations", stmt.Location())
+			}
+			continue
+		}
+		mm.Statements = append(mm.Statements, stmt)
+	}
+	if len(mm.Statements) == 0 {
+		return nil, fmt.Errorf("%s: manual migration does not contain any statements", fullPath)
+	}
+	return mm, nil
+}
@@ -0,0 +1,53 @@
+package fs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDirManualMigrations(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	fpA, fpB := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	WriteTestFile(t, "../testdata/.scratch/mydb/.skeema", "schema=mydb\nmanual-migrations=migrations/0001.sql, migrations/0002.sql\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/migrations/0001.sql", "-- skeema:before users "+fpA+"\n-- skeema:after users "+fpB+"\n-- skeema:before `contacts` -\n-- skeema:after contacts "+fpA+"\n\nCREATE TABLE contacts (id int);\nINSERT INTO contacts SELECT id FROM users;\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/migrations/0002.sql", "-- skeema:before users "+fpB+"\n-- skeema:after users -\nDELIMITER //\nDROP TABLE users//\n")
+	dir := getDir(t, "../testdata/.scratch/mydb")
+	migrations, err := dir.ManualMigrations()
+	if err != nil {
+		t.Fatalf("Unexpected error from ManualMigrations: %s", err)
+	}
+	if len(migrations) != 2 || migrations[0].String() != "migrations/0001.sql" {
+		t.Fatalf("Unexpected result from ManualMigrations: %+v", migrations)
+	}
+	if tables := migrations[0].Tables(); len(tables) != 2 || tables[0] != "contacts" {
+		t.Errorf("Unexpected tables for %s: %v", migrations[0], tables)
+	}
+	if mm := migrations[0]; mm.Before["contacts"] != "-" || mm.After["contacts"] != fpA || mm.Before["users"] != fpA || len(mm.Statements) != 2 {
+		t.Errorf("Unexpected contents of %s: %+v", mm, mm)
+	}
+	if mm := migrations[1]; len(mm.Statements) != 1 || mm.Statements[0].Body() != "DROP TABLE users" {
+		t.Errorf("Unexpected statements in %s: %+v", mm, mm.Statements)
+	}
+
+	// Error cases: missing file, malformed header, missing after state, no
+	// header, USE command, no statements
+	badContents := []string{
+		"",
+		"-- skeema:before users xyz\n-- skeema:after users " + fpB + "\nDROP TABLE users;\n",
+		"-- skeema:before users " + fpA + "\nDROP TABLE users;\n",
+		"DROP TABLE users;\n",
+		"-- skeema:before users " + fpA + "\n-- skeema:after users -\nUSE mydb;\nDROP TABLE users;\n",
+		"-- skeema:before users " + fpA + "\n-- skeema:after users -\n",
+	}
+	for n, contents := range badContents {
+		if n == 0 {
+			RemoveTestFile(t, "../testdata/.scratch/mydb/migrations/0002.sql")
+		} else {
+			WriteTestFile(t, "../testdata/.scratch/mydb/migrations/0002.sql", contents)
+		}
+		if _, err := dir.ManualMigrations(); err == nil {
+			t.Errorf("Expected error from manual migration contents %q, but err was nil", contents)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/skeema/skeema/util"
+)
+
+// WriteFile writes contents to filePath. If the file does not exist yet, it is
+// created with permissions util.FileMode, regardless of the process umask.
+// Otherwise, the existing file's permissions are left as-is.
+func WriteFile(filePath string, contents []byte) error {
+	_, err := os.Stat(filePath)
+	isNew := os.IsNotExist(err)
+	if err := ioutil.WriteFile(filePath, contents, util.FileMode); err != nil {
+		return err
+	}
+	if isNew {
+		return os.Chmod(filePath, util.FileMode)
+	}
+	return nil
+}
+
+// MakeDir creates dirPath, along with any missing parent dirs. Each created
+// dir has permissions util.DirMode, regardless of the process umask. Any dirs
+// which already existed are left as-is.
+func MakeDir(dirPath string) error {
+	var missing []string
+	for p := filepath.Clean(dirPath); ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			break
+		}
+		missing = append(missing, p)
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	if err := os.MkdirAll(dirPath, util.DirMode); err != nil {
+		return err
+	}
+	for _, p := range missing {
+		if err := os.Chmod(p, util.DirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+//go:build !windows
+// +build !windows
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/skeema/skeema/util"
+)
+
+func TestWriteFileMakeDirModes(t *testing.T) {
+	origDirMode, origFileMode := util.DirMode, util.FileMode
+	origUmask := syscall.Umask(0)
+	defer func() {
+		util.DirMode, util.FileMode = origDirMode, origFileMode
+		syscall.Umask(origUmask)
+	}()
+
+	assertMode := func(path string, expected os.FileMode) {
+		t.Helper()
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Unexpected error from Stat: %s", err)
+		}
+		if actual := fi.Mode().Perm(); actual != expected {
+			t.Errorf("Expected %s to have mode %#o, instead found %#o", path, expected, actual)
+		}
+	}
+
+	for _, umask := range []int{0, 022, 077} {
+		syscall.Umask(umask)
+		for _, modes := range [][2]os.FileMode{{0755, 0644}, {0700, 0600}, {0777, 0666}} {
+			util.DirMode, util.FileMode = modes[0], modes[1]
+			MakeTestDirectory(t, "testdata/.modetest")
+			path := filepath.Join("testdata/.modetest", "a", "b")
+			if err := MakeDir(path); err != nil {
+				t.Fatalf("Unexpected error from MakeDir: %s", err)
+			}
+			assertMode(path, util.DirMode)
+			assertMode(filepath.Dir(path), util.DirMode)
+
+			filePath := filepath.Join(path, "foo.sql")
+			if err := WriteFile(filePath, []byte("hello\n")); err != nil {
+				t.Fatalf("Unexpected error from WriteFile: %s", err)
+			}
+			assertMode(filePath, util.FileMode)
+
+			// Rewriting an existing file should retain its mode
+			if err := os.Chmod(filePath, 0640); err != nil {
+				t.Fatalf("Unexpected error from Chmod: %s", err)
+			}
+			if err := WriteFile(filePath, []byte("hello again\n")); err != nil {
+				t.Fatalf("Unexpected error from WriteFile: %s", err)
+			}
+			assertMode(filePath, 0640)
+			if _, _, err := AppendToFile(filePath, "more\n"); err != nil {
+				t.Fatalf("Unexpected error from AppendToFile: %s", err)
+			}
+			assertMode(filePath, 0640)
+			RemoveTestDirectory(t, "testdata/.modetest")
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	"github.com/skeema/mybase"
+)
+
+// OptionFileError is an error returned when a .skeema file cannot be parsed.
+// Rather than only describing the first malformed line, it describes every
+// malformed line in the file.
+type OptionFileError struct {
+	FilePath string
+	Problems []OptionFileProblem
+}
+
+// Error satisfies the builtin error interface.
+func (ofe OptionFileError) Error() string {
+	descriptions := make([]string, len(ofe.Problems))
+	for n, p := range ofe.Problems {
+		descriptions[n] = p.String()
+	}
+	return fmt.Sprintf("Invalid option file %s: %s", ofe.FilePath, strings.Join(descriptions, "; "))
+}
+
+// OptionFileProblem describes a single malformed line of an option file.
+type OptionFileProblem struct {
+	LineNumber int
+	Column     int    // 1-based position in the line where the problem begins
+	Text       string // full text of the offending line
+	Problem    string
+}
+
+// String returns a description of the problem, including its location within
+// the file and the offending text.
+func (p OptionFileProblem) String() string {
+	return fmt.Sprintf("line %d, column %d: %s: %q", p.LineNumber, p.Column, p.Problem, p.Text)
+}
+
+// diagnoseOptionFile is called after f fails to parse with error parseErr. It
+// re-examines the entire file, returning an OptionFileError describing every
+// malformed line. If the problem can't be attributed to any specific line,
+// parseErr is returned as-is.
+func diagnoseOptionFile(f *mybase.File, cfg *mybase.Config, parseErr error) error {
+	contents, err := ioutil.ReadFile(f.Path())
+	if err != nil {
+		return parseErr
+	}
+	loose := f.IgnoreUnknownOptions || cfg.LooseFileOptions
+	var problems []OptionFileProblem
+	var lineNumber int
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		lineNumber++
+		if problem, column := lineProblem(scanner.Text(), cfg, loose); problem != "" {
+			problems = append(problems, OptionFileProblem{
+				LineNumber: lineNumber,
+				Column:     column,
+				Text:       scanner.Text(),
+				Problem:    problem,
+			})
+		}
+	}
+	if len(problems) == 0 {
+		return parseErr
+	}
+	return OptionFileError{FilePath: f.Path(), Problems: problems}
+}
+
+// lineProblem returns a description of the problem with a single line of an
+// option file, along with the 1-based column where the problem begins. The
+// rules mirror those of mybase.File.Parse. An empty string is returned if the
+// line is valid.
+func lineProblem(line string, cfg *mybase.Config, loose bool) (problem string, column int) {
+	for _, marker := range []string{"<<<<<<<", "|||||||", "=======", ">>>>>>>"} {
+		if strings.HasPrefix(line, marker) {
+			return "unresolved git merge conflict", 1
+		}
+	}
+	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+	offset := len(line) - len(trimmed) + 1
+	if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' {
+		return "", 0
+	}
+
+	if trimmed[0] == '[' {
+		endIndex := strings.Index(trimmed, "]")
+		hashIndex := strings.Index(trimmed, "#")
+		if endIndex == -1 || (hashIndex > -1 && hashIndex < endIndex) {
+			return "unterminated section name", offset
+		}
+		after := trimmed[endIndex+1:]
+		if hashIndex > -1 {
+			after = trimmed[endIndex+1 : hashIndex]
+		}
+		if extra := strings.TrimLeftFunc(after, unicode.IsSpace); extra != "" {
+			return "extra characters after section name", offset + endIndex + 1 + len(after) - len(extra)
+		}
+		return "", 0
+	}
+
+	var inValue, escapeNext bool
+	var inQuote rune
+	var quoteIndex int
+	for n, c := range trimmed {
+		if escapeNext {
+			escapeNext = false
+			continue
+		}
+		if c == '#' && inQuote == 0 {
+			trimmed = trimmed[:n]
+			break
+		}
+		if !inValue {
+			switch c {
+			case '=':
+				inValue = true
+			case '\'', '"', '`', '\\':
+				return fmt.Sprintf("illegal character %c in option name", c), offset + n
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			if c == inQuote {
+				inQuote = 0
+			} else if inQuote == 0 {
+				inQuote, quoteIndex = c, n
+			}
+		case '\\':
+			escapeNext = true
+		}
+	}
+	if inQuote != 0 {
+		return "quoted value has no terminating quote", offset + quoteIndex
+	} else if escapeNext {
+		return "value ends in a single backslash", offset + len(trimmed) - 1
+	}
+
+	key, _, hasValue, isLoose := mybase.NormalizeOptionToken(trimmed)
+	if key == "" {
+		return "missing option name", offset
+	}
+	opt := cfg.FindOption(key)
+	if opt == nil {
+		if isLoose || loose {
+			return "", 0
+		}
+		return fmt.Sprintf("unknown option %q", key), offset
+	} else if !hasValue && opt.RequireValue {
+		return fmt.Sprintf("missing required value for option %s", opt.Name), offset
+	}
+	return "", 0
+}
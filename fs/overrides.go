@@ -0,0 +1,191 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// OverridesDirName is the name of the hidden subdirectory of a schema dir which
+// contains environment-specific overrides. Overrides for each environment are
+// stored in a further subdirectory named after the environment, for example
+// ".overrides/staging". Each *.sql file in it contains CREATE statements which
+// replace the shared definition of the same object when operating on that
+// environment, or define objects which only exist in that environment.
+const OverridesDirName = ".overrides"
+
+// OverridesPath returns the path of the dir containing environment-specific
+// overrides for the current environment, or an empty string if the
+// environment-overrides option is not enabled for dir. The returned dir may
+// not exist.
+func (dir *Dir) OverridesPath() string {
+	if dir.Config.FindOption("environment-overrides") == nil || !dir.Config.GetBool("environment-overrides") {
+		return ""
+	}
+	return filepath.Join(dir.Path, OverridesDirName, dir.Config.Get("environment"))
+}
+
+// applyOverrides replaces statements in logicalSchema with their counterparts
+// from the current environment's overrides dir, if environment-overrides is
+// enabled and the overrides dir exists.
+func (dir *Dir) applyOverrides(logicalSchema *LogicalSchema) error {
+	overridesPath := dir.OverridesPath()
+	if overridesPath == "" {
+		return nil
+	}
+	if env := dir.Config.Get("environment"); env == "" || env[0] == '.' || strings.ContainsAny(env, `/\`) {
+		return fmt.Errorf("Environment name %q cannot be used with option environment-overrides", env)
+	}
+	if fi, err := dir.Snapshot.Stat(overridesPath); err != nil || !fi.IsDir() {
+		return nil
+	}
+	files, err := sqlFiles(overridesPath, dir.repoBase, dir.Snapshot)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[tengo.ObjectKey]*Statement)
+	for _, sf := range files {
+		tokenizedFile, err := sf.Tokenize()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range tokenizedFile.Statements {
+			if stmt.Type == StatementTypeNoop || stmt.Type == StatementTypeCommand {
+				continue
+			} else if stmt.Type != StatementTypeCreate {
+				return fmt.Errorf("%s: Only CREATE statements are permitted in environment override files", stmt.Location())
+			}
+			key := stmt.ObjectKey()
+			if origStmt, already := overrides[key]; already {
+				return DuplicateDefinitionError{
+					ObjectKey: key,
+					FirstFile: origStmt.File,
+					FirstLine: origStmt.LineNo,
+					DupeFile:  stmt.File,
+					DupeLine:  stmt.LineNo,
+				}
+			}
+			overrides[key] = stmt
+		}
+	}
+	logicalSchema.shared = make(map[tengo.ObjectKey]*Statement, len(overrides))
+	for key, stmt := range overrides {
+		logicalSchema.shared[key] = logicalSchema.Creates[key] // nil if only defined for this environment
+		logicalSchema.Creates[key] = stmt
+	}
+	logicalSchema.Overrides = overrides
+	return nil
+}
+
+// Shared returns a copy of logicalSchema reflecting only the shared
+// definitions, excluding any environment-specific overrides. If logicalSchema
+// has no overrides, it is returned as-is.
+func (logicalSchema *LogicalSchema) Shared() *LogicalSchema {
+	if len(logicalSchema.Overrides) == 0 {
+		return logicalSchema
+	}
+	shared := *logicalSchema
+	shared.Creates = make(map[tengo.ObjectKey]*Statement, len(logicalSchema.Creates))
+	for key, stmt := range logicalSchema.Creates {
+		if _, overridden := logicalSchema.shared[key]; !overridden {
+			shared.Creates[key] = stmt
+		} else if sharedStmt := logicalSchema.shared[key]; sharedStmt != nil {
+			shared.Creates[key] = sharedStmt
+		}
+	}
+	shared.Overrides, shared.shared = nil, nil
+	return &shared
+}
+
+// WriteOverrides rewrites the *.sql files in overridesPath to contain exactly
+// the supplied CREATE statements, keyed by object. Each object is written to a
+// file named after it. Files which are no longer needed are removed, as is
+// overridesPath itself (and its parent, if it then becomes empty) once no
+// overrides remain. The number of objects whose override was added, changed,
+// or removed is returned.
+func WriteOverrides(overridesPath string, creates map[tengo.ObjectKey]string) (count int, err error) {
+	existing := make(map[tengo.ObjectKey]string)
+	if files, err := sqlFiles(overridesPath, overridesPath, NewTreeSnapshot()); err == nil {
+		for _, sf := range files {
+			tokenizedFile, err := sf.Tokenize()
+			if err != nil {
+				return 0, err
+			}
+			for _, stmt := range tokenizedFile.Statements {
+				if stmt.Type == StatementTypeCreate {
+					existing[stmt.ObjectKey()], _ = stmt.SplitTextBody()
+				}
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+	for key, create := range creates {
+		if existing[key] != create {
+			count++
+		}
+	}
+	for key := range existing {
+		if _, ok := creates[key]; !ok {
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	// Group objects by file, since objects of different types may share a name
+	keysByPath := make(map[string][]tengo.ObjectKey)
+	for key := range creates {
+		filePath := PathForObject(overridesPath, key.Name)
+		keysByPath[filePath] = append(keysByPath[filePath], key)
+	}
+	if len(keysByPath) > 0 {
+		if err := MakeDir(overridesPath); err != nil {
+			return count, err
+		}
+	}
+	for filePath, keys := range keysByPath {
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		var b strings.Builder
+		for _, key := range keys {
+			b.WriteString(AddDelimiter(creates[key]))
+		}
+		if err := WriteFile(filePath, []byte(b.String())); err != nil {
+			return count, err
+		}
+	}
+
+	// Remove files which no longer contain any overrides
+	entries, err := os.ReadDir(overridesPath)
+	if err != nil && !os.IsNotExist(err) {
+		return count, err
+	}
+	for _, entry := range entries {
+		filePath := path.Join(overridesPath, entry.Name())
+		if _, keep := keysByPath[filePath]; !keep && strings.HasSuffix(entry.Name(), ".sql") {
+			if err := os.Remove(filePath); err != nil {
+				return count, err
+			}
+		}
+	}
+	if len(keysByPath) == 0 {
+		removeIfEmpty(overridesPath)
+		removeIfEmpty(filepath.Dir(overridesPath))
+	}
+	return count, nil
+}
+
+// removeIfEmpty removes dirPath if it is an empty directory.
+func removeIfEmpty(dirPath string) {
+	if entries, err := os.ReadDir(dirPath); err == nil && len(entries) == 0 {
+		os.Remove(dirPath)
+	}
+}
@@ -0,0 +1,98 @@
+package fs
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestParseDirOverrides(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	WriteTestFile(t, "../testdata/.scratch/overrides/.skeema", "schema=product\n[staging]\nenvironment-overrides\n")
+	WriteTestFile(t, "../testdata/.scratch/overrides/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/overrides/posts.sql", "CREATE TABLE posts (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/overrides/.overrides/staging/users.sql", "CREATE TABLE users (id int, debug_info text);\n")
+	WriteTestFile(t, "../testdata/.scratch/overrides/.overrides/staging/debug_log.sql", "CREATE TABLE debug_log (id int);\n")
+	usersKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "users"}
+	debugKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "debug_log"}
+	postsKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "posts"}
+
+	// Overrides are not applied in environments which don't enable them
+	dir, err := ParseDir("../testdata/.scratch/overrides", getValidConfig(t, "production"))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %v", err)
+	}
+	if dir.OverridesPath() != "" {
+		t.Errorf("Expected blank OverridesPath for production, instead found %s", dir.OverridesPath())
+	}
+	ls := dir.LogicalSchemas[0]
+	if len(ls.Creates) != 2 || len(ls.Overrides) != 0 || ls.Shared() != ls {
+		t.Errorf("Unexpected logical schema for production: %+v", ls)
+	}
+
+	dir, err = ParseDir("../testdata/.scratch/overrides", getValidConfig(t, "staging"))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %v", err)
+	}
+	ls = dir.LogicalSchemas[0]
+	if len(ls.Creates) != 3 || len(ls.Overrides) != 2 {
+		t.Fatalf("Unexpected logical schema for staging: %+v", ls)
+	}
+	if stmt := ls.Creates[usersKey]; !strings.Contains(stmt.Text, "debug_info") || !strings.Contains(stmt.File, ".overrides") {
+		t.Errorf("Expected users table to use override, instead found %s at %s", stmt.Text, stmt.Location())
+	}
+	shared := ls.Shared()
+	if len(shared.Creates) != 2 || shared.Creates[debugKey] != nil || shared.Creates[postsKey] != ls.Creates[postsKey] || strings.Contains(shared.Creates[usersKey].Text, "debug_info") {
+		t.Errorf("Unexpected result from Shared(): %+v", shared.Creates)
+	}
+	if len(ls.Creates) != 3 {
+		t.Error("Shared() unexpectedly modified the original logical schema")
+	}
+
+	// Only CREATE statements are permitted in override files
+	WriteTestFile(t, "../testdata/.scratch/overrides/.overrides/staging/debug_log.sql", "ALTER TABLE posts ADD COLUMN foo int;\n")
+	if _, err := ParseDir("../testdata/.scratch/overrides", getValidConfig(t, "staging")); err == nil {
+		t.Error("Expected error from ALTER in override file, but err was nil")
+	}
+}
+
+func TestWriteOverrides(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	overridesPath := "../testdata/.scratch/product/.overrides/staging"
+	usersKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "users"}
+	procKey := tengo.ObjectKey{Type: tengo.ObjectTypeProc, Name: "users"}
+	creates := map[tengo.ObjectKey]string{
+		usersKey: "CREATE TABLE `users` (\n  `id` int NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1",
+		procKey:  "CREATE PROCEDURE `users`() SELECT 1",
+	}
+	if count, err := WriteOverrides(overridesPath, creates); count != 2 || err != nil {
+		t.Fatalf("Expected WriteOverrides to return 2, nil; instead found %d, %v", count, err)
+	}
+	contents := ReadTestFile(t, overridesPath+"/users.sql")
+	if !strings.HasPrefix(contents, "CREATE PROCEDURE") || !strings.Contains(contents, "CREATE TABLE `users`") {
+		t.Errorf("Unexpected file contents:\n%s", contents)
+	}
+
+	// Rewriting the same overrides is a no-op
+	if count, err := WriteOverrides(overridesPath, creates); count != 0 || err != nil {
+		t.Errorf("Expected WriteOverrides to return 0, nil; instead found %d, %v", count, err)
+	}
+
+	// Removing all overrides also removes the overrides dirs
+	if count, err := WriteOverrides(overridesPath, nil); count != 2 || err != nil {
+		t.Errorf("Expected WriteOverrides to return 2, nil; instead found %d, %v", count, err)
+	}
+	if _, err := os.Stat("../testdata/.scratch/product/.overrides"); !os.IsNotExist(err) {
+		t.Errorf("Expected overrides dir to be removed, but Stat returned %v", err)
+	}
+	if _, err := os.Stat("../testdata/.scratch/product"); err != nil {
+		t.Errorf("Expected schema dir to remain, but Stat returned %v", err)
+	}
+
+	// No-op when the dir does not exist
+	if count, err := WriteOverrides(overridesPath, nil); count != 0 || err != nil {
+		t.Errorf("Expected WriteOverrides to return 0, nil; instead found %d, %v", count, err)
+	}
+}
@@ -0,0 +1,44 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PassthroughSuffix is the file name suffix of passthrough files. These contain
+// definitions of objects which Skeema does not model, such as events or
+// sequences, so that they may be versioned alongside everything else. They are
+// never parsed into a dir's logical schemas, never diffed, and never modified
+// by `skeema pull` or `skeema format`. `skeema push --include-passthrough`
+// executes them after all other DDL for the dir's schema.
+const PassthroughSuffix = ".passthrough.sql"
+
+// IsPassthroughFile returns true if name has PassthroughSuffix.
+func IsPassthroughFile(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), PassthroughSuffix)
+}
+
+// PassthroughStatements tokenizes sf, which should be a passthrough file, and
+// returns the SQL statements to execute, excluding comments and DELIMITER
+// commands. An error is returned if the file cannot be tokenized, or if it
+// contains a USE command, since passthrough files are always executed in the
+// schema of the dir containing them.
+func PassthroughStatements(sf SQLFile) ([]*Statement, error) {
+	tokenizedFile, err := sf.Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	var statements []*Statement
+	for _, stmt := range tokenizedFile.Statements {
+		if stmt.Type == StatementTypeNoop {
+			continue
+		} else if stmt.Type == StatementTypeCommand {
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(stmt.Text)), "use") {
+				return nil, fmt.Errorf("%s: USE commands are not permitted in passthrough files", stmt.Location())
+			}
+			continue
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"testing"
+)
+
+func TestDirPassthroughFiles(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	WriteTestFile(t, "../testdata/.scratch/mydb/.skeema", "schema=mydb\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/users.sql", "CREATE TABLE users (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/seq.passthrough.sql", "CREATE SEQUENCE s1 START WITH 100;\n")
+	WriteTestFile(t, "../testdata/.scratch/mydb/events.passthrough.sql", "-- nightly cleanup\nDELIMITER //\nCREATE EVENT cleanup ON SCHEDULE EVERY 1 DAY DO BEGIN DELETE FROM users; END//\nDELIMITER ;\nCREATE EVENT other ON SCHEDULE EVERY 1 HOUR DO DELETE FROM users;\n")
+	dir := getDir(t, "../testdata/.scratch/mydb")
+	if len(dir.SQLFiles) != 1 || dir.SQLFiles[0].FileName != "users.sql" {
+		t.Errorf("Expected SQLFiles to only contain users.sql, instead found %v", dir.SQLFiles)
+	}
+	if len(dir.PassthroughFiles) != 2 || dir.PassthroughFiles[0].FileName != "events.passthrough.sql" || dir.PassthroughFiles[1].FileName != "seq.passthrough.sql" {
+		t.Fatalf("Unexpected PassthroughFiles: %v", dir.PassthroughFiles)
+	}
+	if len(dir.IgnoredStatements) > 0 {
+		t.Errorf("Expected passthrough files to not be parsed, but found ignored statements: %v", dir.IgnoredStatements)
+	}
+	statements, err := PassthroughStatements(dir.PassthroughFiles[0])
+	if err != nil {
+		t.Fatalf("Unexpected error from PassthroughStatements: %s", err)
+	}
+	if len(statements) != 2 || statements[0].Body() != "CREATE EVENT cleanup ON SCHEDULE EVERY 1 DAY DO BEGIN DELETE FROM users; END" {
+		t.Errorf("Unexpected statements from PassthroughStatements: %+v", statements)
+	}
+	if problems := ValidateTree(dir, 0); len(problems) > 0 {
+		t.Errorf("Expected no problems from ValidateTree, instead found %v", problems)
+	}
+
+	// Untokenizable passthrough files, or ones with USE commands, are errors
+	for _, contents := range []string{"CREATE SEQUENCE s1 COMMENT 'foo;\n", "USE mydb;\nCREATE SEQUENCE s1;\n"} {
+		WriteTestFile(t, "../testdata/.scratch/mydb/seq.passthrough.sql", contents)
+		if _, err := PassthroughStatements(dir.PassthroughFiles[1]); err == nil {
+			t.Errorf("Expected error from PassthroughStatements with contents %q, but err was nil", contents)
+		}
+		if problems := ValidateTree(dir, 0); len(problems) != 1 {
+			t.Errorf("Expected 1 problem from ValidateTree with contents %q, instead found %v", contents, problems)
+		}
+	}
+
+	// Dirs containing passthrough files are not deleted, since they are not
+	// managed by pull
+	if err := dir.Delete(); err == nil {
+		t.Error("Expected Delete to refuse deleting dir containing passthrough files, but err was nil")
+	} else if ufe, ok := err.(UnexpectedFilesError); !ok || len(ufe.Paths) != 2 {
+		t.Errorf("Unexpected error from Delete: %v", err)
+	}
+}
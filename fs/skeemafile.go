@@ -0,0 +1,135 @@
+package fs
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// SkeemaFile is a line-based representation of a .skeema file, used for
+// programmatic edits which must preserve comments and formatting. Unlike
+// mybase.File, it does not interpret option values, so it can also operate on
+// files containing option names which are no longer valid.
+type SkeemaFile struct {
+	FilePath string
+	lines    []string // each line retains its trailing newline
+}
+
+// ReadSkeemaFile reads the option file at filePath.
+func ReadSkeemaFile(filePath string) (*SkeemaFile, error) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &SkeemaFile{
+		FilePath: filePath,
+		lines:    splitOptionLines(string(contents)),
+	}, nil
+}
+
+// Contents returns the file's current contents, reflecting any edits.
+func (sf *SkeemaFile) Contents() string {
+	return strings.Join(sf.lines, "")
+}
+
+// Write writes the file's current contents to disk.
+func (sf *SkeemaFile) Write() error {
+	return WriteFile(sf.FilePath, []byte(sf.Contents()))
+}
+
+// DeleteKey removes all lines setting the option key in the named section,
+// including lines where key has a modifier prefix such as "skip-" or "loose-".
+// Supply a blank section to operate on the sectionless portion of the file.
+// Any comment lines immediately preceding a removed line, without a blank line
+// in between, are considered attached to it and are removed as well. The
+// return value indicates whether any lines were removed.
+func (sf *SkeemaFile) DeleteKey(section, key string) bool {
+	key = strings.ToLower(key)
+	remove := make([]bool, len(sf.lines))
+	var current string
+	for n, line := range sf.lines {
+		name, _, isHeader := parseOptionLine(line)
+		if isHeader {
+			current = name
+		} else if name == key && current == section {
+			remove[n] = true
+			sf.markAttachedComment(remove, n)
+		}
+	}
+	return sf.removeLines(remove)
+}
+
+// DeleteSection removes the named section's header and all of its lines, along
+// with any comment attached to the header. A comment attached to the header of
+// the following section is retained. If the file contains multiple headers for
+// the same section, all of them are removed. The return value indicates whether
+// any lines were removed. The sectionless portion of the file cannot be
+// removed with this method.
+func (sf *SkeemaFile) DeleteSection(name string) bool {
+	if name == "" {
+		return false
+	}
+	remove := make([]bool, len(sf.lines))
+	var inSection bool
+	for n, line := range sf.lines {
+		sectionName, _, isHeader := parseOptionLine(line)
+		if isHeader {
+			if inSection {
+				// The previous section's trailing comment lines actually belong to
+				// this header
+				for prev := n - 1; prev >= 0 && isCommentLine(sf.lines[prev]); prev-- {
+					remove[prev] = false
+				}
+			}
+			inSection = (sectionName == name)
+			if inSection {
+				sf.markAttachedComment(remove, n)
+			}
+		}
+		if inSection {
+			remove[n] = true
+		}
+	}
+	return sf.removeLines(remove)
+}
+
+// markAttachedComment flags the block of comment lines directly preceding line
+// n for removal.
+func (sf *SkeemaFile) markAttachedComment(remove []bool, n int) {
+	for prev := n - 1; prev >= 0 && isCommentLine(sf.lines[prev]); prev-- {
+		remove[prev] = true
+	}
+}
+
+// removeLines removes the flagged lines. Blank lines are then tidied wherever
+// lines were removed: doubled blank lines are collapsed, and blank lines at
+// the start or end of the file are dropped. Blank lines elsewhere in the file
+// are left as-is.
+func (sf *SkeemaFile) removeLines(remove []bool) (removed bool) {
+	result := make([]string, 0, len(sf.lines))
+	var atSeam bool
+	for n, line := range sf.lines {
+		if remove[n] {
+			removed, atSeam = true, true
+			continue
+		}
+		blank := strings.TrimSpace(line) == ""
+		if blank && atSeam && (len(result) == 0 || strings.TrimSpace(result[len(result)-1]) == "") {
+			continue
+		} else if !blank {
+			atSeam = false
+		}
+		result = append(result, line)
+	}
+	if atSeam {
+		for len(result) > 0 && strings.TrimSpace(result[len(result)-1]) == "" {
+			result = result[:len(result)-1]
+		}
+	}
+	sf.lines = result
+	return removed
+}
+
+func isCommentLine(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && (line[0] == '#' || line[0] == ';')
+}
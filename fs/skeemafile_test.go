@@ -0,0 +1,82 @@
+package fs
+
+import (
+	"testing"
+)
+
+func TestSkeemaFileRoundTrip(t *testing.T) {
+	sf, err := ReadSkeemaFile("testdata/skeemafile/original.skeema")
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadSkeemaFile: %s", err)
+	}
+	original := ReadTestFile(t, "testdata/skeemafile/original.skeema")
+	if sf.Contents() != original {
+		t.Errorf("Contents unexpectedly differ from original file:\n%s", sf.Contents())
+	}
+
+	// Deleting things which don't exist has no effect
+	if sf.DeleteKey("", "host") || sf.DeleteKey("nonexistent", "host") || sf.DeleteKey("production", "extends") {
+		t.Error("Expected DeleteKey to return false for nonexistent keys, but it did not")
+	}
+	if sf.DeleteSection("nonexistent") || sf.DeleteSection("") {
+		t.Error("Expected DeleteSection to return false for nonexistent sections, but it did not")
+	}
+	if sf.Contents() != original {
+		t.Errorf("Contents unexpectedly changed by no-op deletions:\n%s", sf.Contents())
+	}
+
+	defer RemoveTestDirectory(t, "testdata/.scratch")
+	WriteTestFile(t, "testdata/.scratch/.skeema", "placeholder\n")
+	sf.FilePath = "testdata/.scratch/.skeema"
+	if err := sf.Write(); err != nil {
+		t.Fatalf("Unexpected error from Write: %s", err)
+	}
+	if actual := ReadTestFile(t, sf.FilePath); actual != original {
+		t.Errorf("Written file unexpectedly differs from original:\n%s", actual)
+	}
+}
+
+func TestSkeemaFileDeleteKey(t *testing.T) {
+	sf, err := ReadSkeemaFile("testdata/skeemafile/original.skeema")
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadSkeemaFile: %s", err)
+	}
+	deletions := [][2]string{
+		{"", "Default-Collation"},
+		{"production", "alter-wrapper"}, // also removes skip- line and attached comment
+		{"development", "alter-wrapper"},
+		{"staging", "port"},
+	}
+	for _, d := range deletions {
+		if !sf.DeleteKey(d[0], d[1]) {
+			t.Errorf("Expected DeleteKey(%q, %q) to return true, but it did not", d[0], d[1])
+		}
+	}
+	if expected := ReadTestFile(t, "testdata/skeemafile/deletekey.skeema"); sf.Contents() != expected {
+		t.Errorf("Contents after DeleteKey do not match golden file; found:\n%s", sf.Contents())
+	}
+}
+
+func TestSkeemaFileDeleteSection(t *testing.T) {
+	sf, err := ReadSkeemaFile("testdata/skeemafile/original.skeema")
+	if err != nil {
+		t.Fatalf("Unexpected error from ReadSkeemaFile: %s", err)
+	}
+	for _, name := range []string{"production", "staging"} {
+		if !sf.DeleteSection(name) {
+			t.Errorf("Expected DeleteSection(%q) to return true, but it did not", name)
+		}
+	}
+	if expected := ReadTestFile(t, "testdata/skeemafile/deletesection.skeema"); sf.Contents() != expected {
+		t.Errorf("Contents after DeleteSection do not match golden file; found:\n%s", sf.Contents())
+	}
+
+	// Deleting every section leaves just the sectionless options, with no
+	// trailing blank line
+	if !sf.DeleteSection("development") {
+		t.Error("Expected DeleteSection to return true, but it did not")
+	}
+	if expected := "# Top-level options apply to all environments\nformat-version=1\ndefault-character-set=utf8mb4\ndefault-collation=utf8mb4_general_ci  # inline comment\nschema=product\n"; sf.Contents() != expected {
+		t.Errorf("Unexpected contents after deleting all sections:\n%s", sf.Contents())
+	}
+}
@@ -0,0 +1,170 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TreeSnapshot caches directory listings for the duration of a single command
+// invocation. Each directory in a tree is only read from the filesystem once,
+// regardless of how many times its contents are examined, which matters for
+// trees with a very large number of subdirectories. A snapshot is created by
+// ParseDir and shared by all Dirs derived from the resulting Dir.
+// Entries are not invalidated by outside changes, so any code which creates or
+// deletes files in a parsed tree must call Forget to keep the snapshot
+// accurate. A nil *TreeSnapshot is valid, and simply reads from the filesystem
+// without caching. TreeSnapshot is safe for concurrent use.
+type TreeSnapshot struct {
+	mu       sync.Mutex
+	listings map[string][]os.DirEntry
+	stats    map[statKey]statResult
+}
+
+// statKey identifies a cached result of Stat or Lstat.
+type statKey struct {
+	path   string
+	follow bool // true for Stat, false for Lstat
+}
+
+// statResult is a cached result of os.Stat or os.Lstat.
+type statResult struct {
+	fi  os.FileInfo
+	err error
+}
+
+// NewTreeSnapshot returns an empty TreeSnapshot.
+func NewTreeSnapshot() *TreeSnapshot {
+	return &TreeSnapshot{
+		listings: make(map[string][]os.DirEntry),
+		stats:    make(map[statKey]statResult),
+	}
+}
+
+// fsOps counts filesystem metadata operations performed via TreeSnapshot
+// methods, including those of nil snapshots, over the lifetime of the
+// process.
+var fsOps int64
+
+// FilesystemOps returns the total number of filesystem metadata operations
+// (directory listings, stats, and symlink reads) performed by this package
+// while inspecting directories so far. This is useful in debug logging, to
+// diagnose slowness on filesystems with expensive metadata lookups, such as
+// network mounts.
+func FilesystemOps() int64 {
+	return atomic.LoadInt64(&fsOps)
+}
+
+func countOp() {
+	atomic.AddInt64(&fsOps, 1)
+}
+
+// ReadDir returns the entries of dirPath, sorted by file name. The listing is
+// obtained from the filesystem upon first request for dirPath, and from the
+// snapshot afterwards. Errors are not cached.
+func (ts *TreeSnapshot) ReadDir(dirPath string) ([]os.DirEntry, error) {
+	if ts == nil {
+		countOp()
+		return os.ReadDir(dirPath)
+	}
+	dirPath = filepath.Clean(dirPath)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if entries, ok := ts.listings[dirPath]; ok {
+		return entries, nil
+	}
+	countOp()
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	ts.listings[dirPath] = entries
+	return entries, nil
+}
+
+// Entry returns the entry for name within dirPath, or nil if no such entry
+// exists. An error is returned only if dirPath cannot be read.
+func (ts *TreeSnapshot) Entry(dirPath, name string) (os.DirEntry, error) {
+	entries, err := ts.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Name() == name {
+			return entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// Stat returns the result of os.Stat for filePath, following symlinks. The
+// result is obtained from the filesystem upon first request for filePath, and
+// from the snapshot afterwards. Unlike ReadDir, errors indicating filePath
+// does not exist are cached, since callers typically stat symlink
+// destinations, which may be dangling. Other errors are not cached.
+func (ts *TreeSnapshot) Stat(filePath string) (os.FileInfo, error) {
+	return ts.stat(filePath, true)
+}
+
+// Lstat behaves like Stat, but does not follow symlinks.
+func (ts *TreeSnapshot) Lstat(filePath string) (os.FileInfo, error) {
+	return ts.stat(filePath, false)
+}
+
+func (ts *TreeSnapshot) stat(filePath string, follow bool) (os.FileInfo, error) {
+	statFunc := os.Lstat
+	if follow {
+		statFunc = os.Stat
+	}
+	if ts == nil {
+		countOp()
+		return statFunc(filePath)
+	}
+	key := statKey{path: filepath.Clean(filePath), follow: follow}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if result, ok := ts.stats[key]; ok {
+		return result.fi, result.err
+	}
+	countOp()
+	fi, err := statFunc(filePath)
+	if err == nil || os.IsNotExist(err) {
+		ts.stats[key] = statResult{fi: fi, err: err}
+	}
+	return fi, err
+}
+
+// Readlink returns the destination of the symlink at filePath. Results are
+// not cached, since each symlink is typically only read once per command.
+func (ts *TreeSnapshot) Readlink(filePath string) (string, error) {
+	countOp()
+	return os.Readlink(filePath)
+}
+
+// Forget updates the snapshot to reflect that filePath has been created,
+// modified in type, or deleted. The cached listing of filePath's parent dir is
+// discarded, as are any cached listings of filePath itself and of dirs within
+// it, along with any cached stat results for filePath or paths within it.
+// Discarded listings are re-read from the filesystem on next request.
+func (ts *TreeSnapshot) Forget(filePath string) {
+	if ts == nil {
+		return
+	}
+	filePath = filepath.Clean(filePath)
+	prefix := filePath + string(os.PathSeparator)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	delete(ts.listings, filepath.Dir(filePath))
+	for dirPath := range ts.listings {
+		if dirPath == filePath || strings.HasPrefix(dirPath, prefix) {
+			delete(ts.listings, dirPath)
+		}
+	}
+	for key := range ts.stats {
+		if key.path == filePath || strings.HasPrefix(key.path, prefix) {
+			delete(ts.stats, key)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package fs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+func TestTreeSnapshot(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/snapshot/one/a.sql", "CREATE TABLE a (id int);\n")
+	WriteTestFile(t, "../testdata/.scratch/snapshot/two/b.sql", "CREATE TABLE b (id int);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	basePath, _ := filepath.Abs("../testdata/.scratch/snapshot")
+
+	ts := NewTreeSnapshot()
+	assertNames := func(dirPath string, expected ...string) {
+		t.Helper()
+		entries, err := ts.ReadDir(dirPath)
+		if err != nil {
+			t.Fatalf("Unexpected error from ReadDir(%s): %s", dirPath, err)
+		}
+		names := make([]string, len(entries))
+		for n := range entries {
+			names[n] = entries[n].Name()
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Errorf("Expected ReadDir(%s) to return %v, instead found %v", dirPath, expected, names)
+		}
+	}
+	assertNames(basePath, "one", "two")
+	assertNames(filepath.Join(basePath, "one"), "a.sql")
+
+	// Changes made outside of the snapshot are not reflected until forgotten
+	WriteTestFile(t, "../testdata/.scratch/snapshot/one/c.sql", "CREATE TABLE c (id int);\n")
+	assertNames(filepath.Join(basePath, "one"), "a.sql")
+	ts.Forget(filepath.Join(basePath, "one", "c.sql"))
+	assertNames(filepath.Join(basePath, "one"), "a.sql", "c.sql")
+	if entry, err := ts.Entry(filepath.Join(basePath, "one"), "c.sql"); entry == nil || err != nil {
+		t.Errorf("Unexpected return from Entry: %v, %v", entry, err)
+	}
+	if entry, err := ts.Entry(filepath.Join(basePath, "one"), "d.sql"); entry != nil || err != nil {
+		t.Errorf("Unexpected return from Entry: %v, %v", entry, err)
+	}
+
+	// Forgetting a dir also forgets its subdirs and its parent
+	RemoveTestDirectory(t, filepath.Join(basePath, "two"))
+	ts.Forget(filepath.Join(basePath, "two"))
+	assertNames(basePath, "one")
+	if _, err := ts.ReadDir(filepath.Join(basePath, "two")); err == nil {
+		t.Error("Expected error from ReadDir on deleted dir, but err was nil")
+	}
+
+	// A nil snapshot reads directly from the filesystem
+	var nilSnapshot *TreeSnapshot
+	if entries, err := nilSnapshot.ReadDir(basePath); len(entries) != 1 || err != nil {
+		t.Errorf("Unexpected return from ReadDir on nil snapshot: %v, %v", entries, err)
+	}
+	nilSnapshot.Forget(basePath)
+}
+
+func TestDirSnapshotShared(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/shared/.skeema", "host=localhost\n")
+	WriteTestFile(t, "../testdata/.scratch/shared/one/.skeema", "schema=one\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+
+	dir := getDir(t, "../testdata/.scratch/shared")
+	subs, err := dir.Subdirs()
+	if err != nil || len(subs) != 1 {
+		t.Fatalf("Unexpected return from Subdirs: %v, %v", subs, err)
+	}
+	if subs[0].Snapshot != dir.Snapshot {
+		t.Error("Expected subdir to share parent dir's snapshot")
+	}
+
+	// Creating and deleting subdirs must be reflected in later calls to Subdirs
+	if _, err := dir.CreateSubdir("two", nil); err != nil {
+		t.Fatalf("Unexpected error from CreateSubdir: %s", err)
+	}
+	if subs, err = dir.Subdirs(); err != nil || len(subs) != 2 {
+		t.Fatalf("Expected 2 subdirs after CreateSubdir, instead found %d (err=%v)", len(subs), err)
+	}
+	if err := subs[0].Delete(); err != nil {
+		t.Fatalf("Unexpected error from Delete: %s", err)
+	}
+	if subs, err = dir.Subdirs(); err != nil || len(subs) != 1 || subs[0].BaseName() != "two" {
+		t.Errorf("Unexpected return from Subdirs after Delete: %v, %v", subs, err)
+	}
+}
+
+func TestTreeSnapshotStat(t *testing.T) {
+	WriteTestFile(t, "../testdata/.scratch/snapshotstat/a.sql", "CREATE TABLE a (id int);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	basePath, _ := filepath.Abs("../testdata/.scratch/snapshotstat")
+
+	ts := NewTreeSnapshot()
+	before := FilesystemOps()
+	for n := 0; n < 3; n++ {
+		if fi, err := ts.Stat(filepath.Join(basePath, "a.sql")); err != nil || !fi.Mode().IsRegular() {
+			t.Errorf("Unexpected return from Stat: %v, %v", fi, err)
+		}
+		if _, err := ts.Lstat(filepath.Join(basePath, "b.sql")); !os.IsNotExist(err) {
+			t.Errorf("Expected not-exist error from Lstat, instead found %v", err)
+		}
+	}
+	if ops := FilesystemOps() - before; ops != 2 {
+		t.Errorf("Expected 2 filesystem operations, instead found %d", ops)
+	}
+
+	// Forgetting a path discards its cached stat result
+	WriteTestFile(t, "../testdata/.scratch/snapshotstat/b.sql", "CREATE TABLE b (id int);\n")
+	if _, err := ts.Lstat(filepath.Join(basePath, "b.sql")); !os.IsNotExist(err) {
+		t.Errorf("Expected cached not-exist error from Lstat, instead found %v", err)
+	}
+	ts.Forget(filepath.Join(basePath, "b.sql"))
+	if _, err := ts.Lstat(filepath.Join(basePath, "b.sql")); err != nil {
+		t.Errorf("Unexpected error from Lstat: %s", err)
+	}
+}
+
+func TestTreeSnapshotOps(t *testing.T) {
+	basePath := makeDeepTree(t, 4, 3)
+	defer os.RemoveAll(basePath)
+	numDirs := 1 + 3 + 9 + 27 + 81
+
+	// With a snapshot, each dir is listed exactly once. The only other operation
+	// is a stat of the repo base's .git dir.
+	before := FilesystemOps()
+	if walked := walkTree(t, getDir(t, basePath)); walked != numDirs {
+		t.Fatalf("Expected to walk %d dirs, instead walked %d", numDirs, walked)
+	}
+	cachedOps := FilesystemOps() - before
+	if expected := int64(numDirs + 1); cachedOps != expected {
+		t.Errorf("Expected %d filesystem operations, instead found %d", expected, cachedOps)
+	}
+
+	// Without a snapshot, every inspection of a dir lists it again
+	before = FilesystemOps()
+	dir := getDir(t, basePath)
+	dir.Snapshot = nil
+	walkTree(t, dir)
+	if uncachedOps := FilesystemOps() - before; uncachedOps < 5*cachedOps {
+		t.Errorf("Expected uncached walk to require many more filesystem operations than cached walk's %d, instead found %d", cachedOps, uncachedOps)
+	}
+}
+
+// makeDeepTree creates a temporary dir with a .git subdir, and a tree of
+// subdirs of the supplied depth, in which each non-leaf dir has fanout
+// subdirs. Every dir in the tree contains a .skeema file, and each leaf dir
+// also contains a *.sql file. The caller should remove the returned path when
+// done.
+func makeDeepTree(tb testing.TB, depth, fanout int) string {
+	tb.Helper()
+	basePath, err := ioutil.TempDir("", "skeema-deep-tree")
+	if err != nil {
+		tb.Fatalf("Unable to create temp dir: %s", err)
+	}
+	if err := os.Mkdir(filepath.Join(basePath, ".git"), 0777); err != nil {
+		tb.Fatalf("Unable to create dir: %s", err)
+	}
+	var populate func(dirPath string, level int)
+	populate = func(dirPath string, level int) {
+		contents := "host=localhost\n"
+		if level == depth {
+			contents = "schema=product\n"
+			if err := ioutil.WriteFile(filepath.Join(dirPath, "widgets.sql"), []byte("CREATE TABLE widgets (id int);\n"), 0666); err != nil {
+				tb.Fatalf("Unable to write file: %s", err)
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(dirPath, ".skeema"), []byte(contents), 0666); err != nil {
+			tb.Fatalf("Unable to write file: %s", err)
+		}
+		if level == depth {
+			return
+		}
+		for n := 0; n < fanout; n++ {
+			subPath := filepath.Join(dirPath, fmt.Sprintf("sub%d", n))
+			if err := os.Mkdir(subPath, 0777); err != nil {
+				tb.Fatalf("Unable to create dir: %s", err)
+			}
+			populate(subPath, level+1)
+		}
+	}
+	populate(basePath, 0)
+	return basePath
+}
+
+// walkTree recursively examines dir and its subdirs in the manner of a typical
+// command, returning the number of dirs examined.
+func walkTree(tb testing.TB, dir *Dir) int {
+	tb.Helper()
+	if ok, err := dir.HasFile(".skeema"); !ok || err != nil {
+		tb.Fatalf("Unexpected return from HasFile: %t, %v", ok, err)
+	}
+	subs, err := dir.Subdirs()
+	if err != nil {
+		tb.Fatalf("Unexpected error from Subdirs: %s", err)
+	}
+	walked := 1
+	for _, sub := range subs {
+		if sub.ParseError != nil {
+			tb.Fatalf("Unexpected parse error in %s: %s", sub, sub.ParseError)
+		}
+		walked += walkTree(tb, sub)
+	}
+	return walked
+}
+
+// makeLargeTree creates a temporary dir with the supplied number of subdirs,
+// each of which contains a .skeema file and a *.sql file. The caller should
+// remove the returned path when done.
+func makeLargeTree(b *testing.B, numDirs int) string {
+	b.Helper()
+	basePath, err := ioutil.TempDir("", "skeemabench")
+	if err != nil {
+		b.Fatalf("Unable to create temp dir: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(basePath, ".skeema"), []byte("host=localhost\n"), 0666); err != nil {
+		b.Fatalf("Unable to write file: %s", err)
+	}
+	for n := 0; n < numDirs; n++ {
+		subPath := filepath.Join(basePath, fmt.Sprintf("schema%04d", n))
+		if err := os.Mkdir(subPath, 0777); err != nil {
+			b.Fatalf("Unable to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(subPath, ".skeema"), []byte(fmt.Sprintf("schema=schema%04d\n", n)), 0666); err != nil {
+			b.Fatalf("Unable to write file: %s", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(subPath, "widgets.sql"), []byte("CREATE TABLE widgets (id int);\n"), 0666); err != nil {
+			b.Fatalf("Unable to write file: %s", err)
+		}
+	}
+	return basePath
+}
+
+// BenchmarkTreeScan compares the filesystem calls needed to examine a tree of
+// 5,000 schema dirs. The "ioutil" case uses the approach Dir used prior to the
+// introduction of TreeSnapshot: a stat of every entry via ioutil.ReadDir, for
+// both the parent dir (to find subdirs) and each subdir (to find *.sql files),
+// plus a separate Lstat for each subdir's .skeema file. The "snapshot" case
+// uses a single os.ReadDir per dir, shared by all of these checks.
+func BenchmarkTreeScan(b *testing.B) {
+	basePath := makeLargeTree(b, 5000)
+	defer os.RemoveAll(basePath)
+
+	b.Run("ioutil", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fileInfos, err := ioutil.ReadDir(basePath)
+			if err != nil {
+				b.Fatalf("Unexpected error: %s", err)
+			}
+			for _, fi := range fileInfos {
+				if !fi.IsDir() {
+					continue
+				}
+				subPath := filepath.Join(basePath, fi.Name())
+				if _, err := os.Lstat(filepath.Join(subPath, ".skeema")); err != nil {
+					b.Fatalf("Unexpected error: %s", err)
+				}
+				if _, err := ioutil.ReadDir(subPath); err != nil {
+					b.Fatalf("Unexpected error: %s", err)
+				}
+			}
+		}
+	})
+	b.Run("snapshot", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ts := NewTreeSnapshot()
+			entries, err := ts.ReadDir(basePath)
+			if err != nil {
+				b.Fatalf("Unexpected error: %s", err)
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+				subPath := filepath.Join(basePath, entry.Name())
+				if entry, err := ts.Entry(subPath, ".skeema"); entry == nil || err != nil {
+					b.Fatalf("Unexpected return from Entry: %v, %v", entry, err)
+				}
+				if _, err := sqlFiles(subPath, basePath, ts); err != nil {
+					b.Fatalf("Unexpected error: %s", err)
+				}
+			}
+		}
+	})
+}
+
+// BenchmarkSubdirsLargeTree measures parsing a tree of 5,000 schema dirs.
+func BenchmarkSubdirsLargeTree(b *testing.B) {
+	basePath := makeLargeTree(b, 5000)
+	defer os.RemoveAll(basePath)
+	cfg, err := mybase.ParseCLI(getValidCommand(), []string{"fstest"})
+	if err != nil {
+		b.Fatalf("Unexpected error from ParseCLI: %s", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dir, err := ParseDir(basePath, cfg)
+		if err != nil {
+			b.Fatalf("Unexpected error from ParseDir: %s", err)
+		}
+		subs, err := dir.Subdirs()
+		if err != nil || len(subs) != 5000 {
+			b.Fatalf("Unexpected return from Subdirs: %d subdirs, err=%v", len(subs), err)
+		}
+	}
+}
+
+// BenchmarkDeepTreeWalk measures walking a tree of depth 5 with a fanout of 4,
+// with and without a TreeSnapshot. The number of filesystem operations per
+// walk is reported as the fsops/op metric.
+func BenchmarkDeepTreeWalk(b *testing.B) {
+	basePath := makeDeepTree(b, 5, 4)
+	defer os.RemoveAll(basePath)
+	cfg, err := mybase.ParseCLI(getValidCommand(), []string{"fstest"})
+	if err != nil {
+		b.Fatalf("Unexpected error from ParseCLI: %s", err)
+	}
+	for _, useSnapshot := range []bool{false, true} {
+		name := "uncached"
+		if useSnapshot {
+			name = "snapshot"
+		}
+		b.Run(name, func(b *testing.B) {
+			before := FilesystemOps()
+			for i := 0; i < b.N; i++ {
+				dir, err := ParseDir(basePath, cfg)
+				if err != nil {
+					b.Fatalf("Unexpected error from ParseDir: %s", err)
+				}
+				if !useSnapshot {
+					dir.Snapshot = nil
+				}
+				walkTree(b, dir)
+			}
+			b.ReportMetric(float64(FilesystemOps()-before)/float64(b.N), "fsops/op")
+		})
+	}
+}
@@ -3,11 +3,14 @@ package fs
 import (
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"unicode"
+
+	"github.com/skeema/tengo"
 )
 
 // SQLFile represents a file containing zero or more SQL statements.
@@ -50,7 +53,7 @@ func (sf SQLFile) Create(contents string) error {
 	} else if exists {
 		return fmt.Errorf("Cannot create %s: already exists", sf)
 	}
-	return ioutil.WriteFile(sf.Path(), []byte(contents), 0666)
+	return WriteFile(sf.Path(), []byte(contents))
 }
 
 // Delete unlinks the file.
@@ -99,6 +102,13 @@ func (sf SQLFile) Tokenize() (*TokenizedSQLFile, error) {
 		if statements2, err2 := tokenizer.statements(); err2 == nil {
 			statements = statements2
 			err = nil
+			// Treat the routine's trailing semicolon as its delimiter, so that its
+			// body matches what it would be if DELIMITER commands had been used
+			for _, stmt := range statements {
+				if stmt.isCreateWithBegin() {
+					stmt.delimiter = ";"
+				}
+			}
 		}
 	}
 	return NewTokenizedSQLFile(sf, statements), err
@@ -112,7 +122,7 @@ func (sf SQLFile) WriteStatements(statements []*Statement) (int, error) {
 		lines[n] = string(statements[n].Text)
 	}
 	value := strings.Join(lines, "")
-	err := ioutil.WriteFile(sf.Path(), []byte(value), 0666)
+	err := WriteFile(sf.Path(), []byte(value))
 	if err != nil {
 		return 0, err
 	}
@@ -133,6 +143,37 @@ func NewTokenizedSQLFile(sf SQLFile, statements []*Statement) *TokenizedSQLFile
 	return result
 }
 
+// InsertSorted adds a new CREATE statement for key to tsf, with the supplied
+// text, which should already include its delimiter. The statement is placed
+// before the first existing CREATE for the same type of object whose name sorts
+// after key's name, or at the end of the file if there is no such statement.
+// This does not rewrite the file.
+func (tsf *TokenizedSQLFile) InsertSorted(key tengo.ObjectKey, text string) *Statement {
+	stmt := &Statement{
+		File:       tsf.Path(),
+		Text:       text,
+		Type:       StatementTypeCreate,
+		ObjectType: key.Type,
+		ObjectName: key.Name,
+		FromFile:   tsf,
+		delimiter:  ";",
+	}
+	pos := len(tsf.Statements)
+	for n, existing := range tsf.Statements {
+		if existing.Type == StatementTypeCreate && existing.ObjectType == key.Type && existing.ObjectName > key.Name {
+			pos = n
+			break
+		}
+	}
+	if pos == len(tsf.Statements) && pos > 0 && !strings.HasSuffix(tsf.Statements[pos-1].Text, "\n") {
+		tsf.Statements[pos-1].Text += "\n"
+	}
+	tsf.Statements = append(tsf.Statements, nil)
+	copy(tsf.Statements[pos+1:], tsf.Statements[pos:])
+	tsf.Statements[pos] = stmt
+	return stmt
+}
+
 // Rewrite rewrites the SQLFile with the current statements, returning the
 // number of bytes written. If the file's statements now only consist of
 // comments, whitespace, and commands (e.g. USE, DELIMITER) then the file will
@@ -152,34 +193,153 @@ func (tsf *TokenizedSQLFile) Rewrite() (int, error) {
 }
 
 // PathForObject returns a string containing a path to use for the SQLFile
-// representing the supplied object name. Special characters in the objectName
-// will be removed; however, there is no risk of "conflicts" since a single
-// SQLFile can store definitions for multiple objects.
+// representing the supplied object name. Characters in objectName which are
+// problematic in file names on common filesystems are percent-encoded, so that
+// the object name can be recovered via ObjectNameForPath. Names which are
+// reserved device names on Windows have their first character encoded as well.
+// Since file names are case-insensitive on some filesystems, object names
+// which differ only in letter case may still map to the same file; callers
+// should use CaseSafePathForObject for such objects.
 func PathForObject(dirPath, objectName string) string {
-	objectName = strings.Map(removeSpecialChars, objectName)
+	return path.Join(dirPath, fmt.Sprintf("%s.sql", encodeFileName(objectName, false)))
+}
+
+// CaseSafePathForObject behaves like PathForObject, but also percent-encodes
+// any uppercase ASCII letters in objectName. This way, object names which
+// differ only in letter case map to distinct files, even on case-insensitive
+// filesystems. The object name can still be recovered via ObjectNameForPath.
+func CaseSafePathForObject(dirPath, objectName string) string {
+	return path.Join(dirPath, fmt.Sprintf("%s.sql", encodeFileName(objectName, true)))
+}
+
+// PortableDirName returns a subdirectory name for the supplied schema name.
+// Typically this is just the schema name as-is. However, if the schema name
+// could not be used as a directory name on all common operating systems, for
+// example because it is a reserved device name on Windows, ends in a dot or
+// space, or contains path separators or characters which are invalid in file
+// names on Windows, it is encoded in the same manner as PathForObject instead.
+// Either way, the dir's .skeema file records the actual schema name.
+func PortableDirName(schemaName string) string {
+	if schemaName == "" || schemaName[0] == '.' || isReservedFileName(strings.SplitN(schemaName, ".", 2)[0]) {
+		return encodeFileName(schemaName, false)
+	}
+	if last := schemaName[len(schemaName)-1]; last == '.' || last == ' ' {
+		return encodeFileName(schemaName, false)
+	}
+	for n := 0; n < len(schemaName); n++ {
+		if c := schemaName[n]; c < 0x20 || c == 0x7F || strings.IndexByte("/\\\":*?|<>", c) >= 0 {
+			return encodeFileName(schemaName, false)
+		}
+	}
+	return schemaName
+}
+
+// CaseSafeDirName returns a subdirectory name for the supplied schema name,
+// using the same encoding as CaseSafePathForObject.
+func CaseSafeDirName(schemaName string) string {
+	return encodeFileName(schemaName, true)
+}
+
+// encodeFileName returns a file name (without extension) for objectName,
+// percent-encoding problematic characters, as well as uppercase ASCII letters
+// if encodeUpper is true.
+func encodeFileName(objectName string, encodeUpper bool) string {
 	if objectName == "" {
 		objectName = "symbols"
 	}
-	return path.Join(dirPath, fmt.Sprintf("%s.sql", objectName))
+	var b strings.Builder
+	for n := 0; n < len(objectName); n++ {
+		c := objectName[n]
+		if (n == 0 && isReservedFileName(objectName)) || needsFileNameEncoding(c) || (encodeUpper && c >= 'A' && c <= 'Z') {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// ObjectNameForPath returns the object name corresponding to the supplied file
+// path, reversing the encoding performed by PathForObject. If the file name
+// does not have a .sql extension, or contains invalid percent-encoding, ok
+// will be false.
+func ObjectNameForPath(filePath string) (objectName string, ok bool) {
+	base := path.Base(filepath.ToSlash(filePath))
+	if !strings.HasSuffix(base, ".sql") {
+		return "", false
+	}
+	objectName, err := url.PathUnescape(strings.TrimSuffix(base, ".sql"))
+	return objectName, err == nil
 }
 
-func removeSpecialChars(r rune) rune {
-	if unicode.IsSpace(r) {
-		return -1
+// needsFileNameEncoding returns true if the supplied byte must be encoded in
+// file names generated by PathForObject. This includes characters which are
+// invalid in file names on Windows, path separators, quote characters, dots
+// (to avoid hidden files and special names such as ".."), whitespace (which is
+// invalid at the end of file names on Windows), control characters, and the
+// percent sign, since it is used for the encoding. Multi-byte UTF-8
+// characters are left as-is.
+func needsFileNameEncoding(c byte) bool {
+	if c < 0x20 || c == 0x7F || c == ' ' {
+		return true
 	}
-	banned := []rune{
-		'.',
-		'\\', '/',
-		'"', '\'', '`',
-		':', '*', '?', '|', '~', '#', '&', '-',
-		'<', '>', '{', '}', '[', ']', '(', ')',
+	return strings.IndexByte("%./\\\"'`:*?|<>", c) >= 0
+}
+
+// reReservedFileName matches names which cannot be used as file names on
+// Windows, regardless of extension.
+var reReservedFileName = regexp.MustCompile(`(?i)^(con|prn|aux|nul|com[1-9]|lpt[1-9])$`)
+
+func isReservedFileName(name string) bool {
+	return reReservedFileName.MatchString(name)
+}
+
+// Limits on path lengths checked by PathProblems. Most filesystems limit each
+// path component to 255 bytes. Windows historically limits entire paths to
+// 260 characters (MAX_PATH); since the location of the repo's checkout is not
+// known, paths within the repo are limited to a shorter length, leaving room
+// for the checkout's own path.
+const (
+	MaxFileNameLength     = 255
+	MaxPortablePathLength = 200
+)
+
+// PathProblems returns a description of each aspect of filePath which would
+// prevent it from being checked out on some common operating systems or
+// filesystems, or nil if there are no such problems. Only the components of
+// filePath within repoBase are examined, and the length limit applies to the
+// portion of filePath relative to repoBase. If filePath is not within
+// repoBase, only its final component is examined. The checks include names
+// which are reserved device names on Windows (regardless of extension), names
+// ending in a dot or space (which Windows silently strips), and components or
+// paths exceeding the length limits.
+// Files written by PathForObject and CaseSafePathForObject already avoid
+// reserved names and trailing dots and spaces, but paths may still be too long
+// if the repo's directories are deeply nested.
+func PathProblems(filePath, repoBase string) (problems []string) {
+	relPath, err := filepath.Rel(repoBase, filePath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		relPath = filepath.Base(filePath)
 	}
-	for _, bad := range banned {
-		if r == bad {
-			return -1
+	relPath = filepath.ToSlash(relPath)
+	for _, component := range strings.Split(relPath, "/") {
+		if component == "" || component == "." {
+			continue
+		}
+		if base := strings.SplitN(component, ".", 2)[0]; isReservedFileName(strings.TrimRight(base, " ")) {
+			problems = append(problems, fmt.Sprintf("%q is a reserved device name on Windows", component))
+		}
+		if last := component[len(component)-1]; last == '.' || last == ' ' {
+			problems = append(problems, fmt.Sprintf("%q ends in a dot or space, which Windows removes", component))
 		}
+		if len(component) > MaxFileNameLength {
+			problems = append(problems, fmt.Sprintf("%q is %d bytes long, exceeding the %d-byte limit of most filesystems", component, len(component), MaxFileNameLength))
+		}
+	}
+	if length := len([]rune(relPath)); length > MaxPortablePathLength {
+		problems = append(problems, fmt.Sprintf("path %s is %d characters long, which may exceed the Windows path length limit once combined with the checkout location (repo-relative paths should not exceed %d characters)", relPath, length, MaxPortablePathLength))
 	}
-	return r
+	return problems
 }
 
 // AppendToFile appends the supplied string to the file at the given path. If the
@@ -188,7 +348,7 @@ func removeSpecialChars(r rune) rune {
 func AppendToFile(filePath, contents string) (bytesWritten int, created bool, err error) {
 	_, err = os.Stat(filePath)
 	if os.IsNotExist(err) {
-		return len(contents), true, ioutil.WriteFile(filePath, []byte(contents), 0666)
+		return len(contents), true, WriteFile(filePath, []byte(contents))
 	} else if err != nil {
 		return
 	}
@@ -202,7 +362,7 @@ func AppendToFile(filePath, contents string) (bytesWritten int, created bool, er
 		whitespace = "\n"
 	}
 	newContents := fmt.Sprintf("%s%s%s", string(byteContents), whitespace, contents)
-	return len(newContents), false, ioutil.WriteFile(filePath, []byte(newContents), 0666)
+	return len(newContents), false, WriteFile(filePath, []byte(newContents))
 }
 
 var reIsMultiStatement = regexp.MustCompile(`(?is)begin.*;.*end`)
@@ -217,3 +377,10 @@ func AddDelimiter(stmt string) string {
 	}
 	return fmt.Sprintf("%s;\n", stmt)
 }
+
+// AddSemicolon takes the supplied string and appends a semicolon to the end,
+// without ever adding delimiter commands. Multi-statement routines are only
+// parsed correctly in this form if they are the sole statement in their file.
+func AddSemicolon(stmt string) string {
+	return fmt.Sprintf("%s;\n", stmt)
+}
@@ -1,8 +1,10 @@
 package fs
 
 import (
+	"path"
 	"strings"
 	"testing"
+	"testing/quick"
 
 	"github.com/skeema/tengo"
 )
@@ -211,6 +213,73 @@ func TestTokenizedSQLFileRewrite(t *testing.T) {
 	}
 }
 
+// TestSQLFileTokenizeComments confirms that tables with arbitrary table-level
+// and column-level comments, as generated by tengo, are tokenized into a single
+// statement with the correct text, type, and name.
+func TestSQLFileTokenizeComments(t *testing.T) {
+	sf := SQLFile{
+		Dir:      "testdata",
+		FileName: "comments.sql",
+	}
+	defer sf.Delete()
+
+	tokenizesCorrectly := func(tableComment, colComment string) bool {
+		table := &tengo.Table{
+			Name:    "has_comments",
+			Engine:  "InnoDB",
+			CharSet: "utf8mb4",
+			Comment: tableComment,
+			Columns: []*tengo.Column{
+				{Name: "id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull, Comment: colComment},
+			},
+		}
+		create := table.GeneratedCreateStatement(tengo.FlavorMySQL57) + ";\n"
+		if err := sf.Create(create); err != nil {
+			t.Fatalf("Unexpected error from Create(): %s", err)
+		}
+		defer sf.Delete()
+		tokenizedFile, err := sf.Tokenize()
+		if err != nil {
+			t.Logf("Unexpected error from Tokenize() with table comment %q, column comment %q: %s", tableComment, colComment, err)
+			return false
+		} else if len(tokenizedFile.Statements) != 1 {
+			t.Logf("Expected 1 statement with table comment %q, column comment %q; instead found %d", tableComment, colComment, len(tokenizedFile.Statements))
+			return false
+		}
+		stmt := tokenizedFile.Statements[0]
+		if stmt.Text != create || stmt.Type != StatementTypeCreate || stmt.ObjectType != tengo.ObjectTypeTable || stmt.ObjectName != table.Name {
+			t.Logf("Unexpected statement with table comment %q, column comment %q: %+v", tableComment, colComment, *stmt)
+			return false
+		}
+		return true
+	}
+
+	comments := []string{
+		"",
+		"it's",
+		`say "hi"`,
+		"`backticks`",
+		"semicolon; here",
+		"-- not a comment",
+		"# not a comment",
+		"/* not a comment */",
+		"/* unterminated",
+		"multiple\nlines\r\nhere",
+		`trailing backslash\`,
+		`\'`,
+		"nul\x00byte",
+		"multi-byte ✓ 日本語 🙂",
+	}
+	for _, comment := range comments {
+		if !tokenizesCorrectly(comment, comment) {
+			t.Errorf("Comment %q did not tokenize correctly", comment)
+		}
+	}
+	if err := quick.Check(tokenizesCorrectly, nil); err != nil {
+		t.Error(err)
+	}
+}
+
 // expectedStatements returns the expected contents of testdata/statements.sql
 // in the form of a slice of statement pointers
 func expectedStatements(filePath string) []*Statement {
@@ -251,16 +320,125 @@ func TestPathForObject(t *testing.T) {
 		{"", "foobar", "foobar.sql"},
 		{"/foo/bar", "baz", "/foo/bar/baz.sql"},
 		{"/var/schemas", "", "/var/schemas/symbols.sql"},
-		{"/var/schemas", "[*]. ({`'\"})", "/var/schemas/symbols.sql"},
+		{"/var/schemas", "[*]. ({`'\"})", "/var/schemas/[%2A]%2E%20({%60%27%22}).sql"},
 		{"/var/schemas", "foo_bar", "/var/schemas/foo_bar.sql"},
-		{"/var/schemas", "foo-bar", "/var/schemas/foobar.sql"},
-		{"/var/schemas", "../../etc/passwd", "/var/schemas/etcpasswd.sql"},
+		{"/var/schemas", "foo-bar", "/var/schemas/foo-bar.sql"},
+		{"/var/schemas", "../../etc/passwd", "/var/schemas/%2E%2E%2F%2E%2E%2Fetc%2Fpasswd.sql"},
+		{"/var/schemas", "100%", "/var/schemas/100%25.sql"},
+		{"/var/schemas", "emoji😀", "/var/schemas/emoji😀.sql"},
+		{"/var/schemas", "con", "/var/schemas/%63on.sql"},
+		{"/var/schemas", "console", "/var/schemas/console.sql"},
 	}
 	for _, c := range cases {
 		if actual := PathForObject(c.DirPath, c.ObjectName); actual != c.Expected {
 			t.Errorf("Expected PathForObject(%q, %q) to return %q, instead found %q", c.DirPath, c.ObjectName, c.Expected, actual)
 		}
 	}
+
+	// Case-safe variant also encodes uppercase letters, and still round-trips
+	for objectName, expected := range map[string]string{"users": "users.sql", "Users": "%55sers.sql", "USERS": "%55%53%45%52%53.sql", "My Table": "%4Dy%20%54able.sql", "CON": "%43%4F%4E.sql"} {
+		actual := CaseSafePathForObject("/var/schemas", objectName)
+		if actual != "/var/schemas/"+expected {
+			t.Errorf("Expected CaseSafePathForObject(%q) to return %q, instead found %q", objectName, "/var/schemas/"+expected, actual)
+		} else if name, ok := ObjectNameForPath(actual); !ok || name != objectName {
+			t.Errorf("Expected ObjectNameForPath(%q) to return %q, true; instead found %q, %t", actual, objectName, name, ok)
+		}
+	}
+	if actual := CaseSafeDirName("Product"); actual != "%50roduct" {
+		t.Errorf("Unexpected return from CaseSafeDirName: %q", actual)
+	}
+}
+
+func TestObjectNameForPath(t *testing.T) {
+	// Every object name in the hostile identifier fixture must round-trip
+	// through PathForObject and ObjectNameForPath
+	tokenizedFile, err := SQLFile{Dir: "testdata", FileName: "hostile.sql"}.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing hostile.sql: %s", err)
+	}
+	for _, stmt := range tokenizedFile.Statements {
+		if stmt.Type != StatementTypeCreate {
+			continue
+		}
+		filePath := PathForObject("/var/schemas", stmt.ObjectName)
+		if path.Dir(filePath) != "/var/schemas" {
+			t.Errorf("PathForObject(%q) unexpectedly returned path outside of dir: %s", stmt.ObjectName, filePath)
+		}
+		if name, ok := ObjectNameForPath(filePath); !ok || name != stmt.ObjectName {
+			t.Errorf("Expected ObjectNameForPath(%q) to return %q, true; instead found %q, %t", filePath, stmt.ObjectName, name, ok)
+		}
+	}
+
+	for _, filePath := range []string{"/var/schemas/foo.txt", "/var/schemas/bad%zz.sql"} {
+		if name, ok := ObjectNameForPath(filePath); ok {
+			t.Errorf("Expected ObjectNameForPath(%q) to return ok=false, instead found %q, %t", filePath, name, ok)
+		}
+	}
+}
+
+func TestPortableDirName(t *testing.T) {
+	cases := map[string]string{
+		"product":     "product",
+		"my db":       "my db",
+		"v1.2":        "v1.2",
+		"100%":        "100%",
+		"con":         "%63on",
+		"AUX":         "%41UX",
+		"nul.archive": "nul%2Earchive",
+		"trailing.":   "trailing%2E",
+		"trailing ":   "trailing%20",
+		".hidden":     "%2Ehidden",
+		"a/b":         "a%2Fb",
+		"what?":       "what%3F",
+	}
+	for schemaName, expected := range cases {
+		actual := PortableDirName(schemaName)
+		if actual != expected {
+			t.Errorf("Expected PortableDirName(%q) to return %q, instead found %q", schemaName, expected, actual)
+		}
+		if actual != schemaName {
+			if problems := PathProblems("/repo/"+actual+"/foo.sql", "/repo"); len(problems) > 0 {
+				t.Errorf("Unexpected problems with encoded dir name %q: %v", actual, problems)
+			}
+			if decoded, ok := ObjectNameForPath(actual + ".sql"); !ok || decoded != schemaName {
+				t.Errorf("Expected encoded dir name %q to decode to %q, instead found %q", actual, schemaName, decoded)
+			}
+		}
+	}
+}
+
+func TestPathProblems(t *testing.T) {
+	cases := map[string]int{
+		"/repo/product/users.sql":                          0,
+		"/repo/product/con.sql":                            1,
+		"/repo/Aux/users.sql":                              1,
+		"/repo/product/com1.tar.sql":                       1,
+		"/repo/product/users.sql.":                         1,
+		"/repo/product /users.sql":                         1,
+		"/repo/nul/lpt1.sql":                               2,
+		"/elsewhere/con/users.sql":                         0, // outside of repo base, only file name is checked
+		"/repo/" + strings.Repeat("x", 256) + ".sql":       2,
+		"/repo/" + strings.Repeat("dir/", 50) + "foo.sql":  1,
+		"/repo/" + strings.Repeat("dir/", 48) + "abcd.sql": 0,
+		"/repo/" + strings.Repeat("ü", 100) + "/users.sql": 0, // 200 bytes but 100 characters
+	}
+	for filePath, expected := range cases {
+		if problems := PathProblems(filePath, "/repo"); len(problems) != expected {
+			t.Errorf("Expected PathProblems(%q) to return %d problems, instead found %d: %v", filePath, expected, len(problems), problems)
+		}
+	}
+
+	// Names generated by PathForObject never have problems other than length,
+	// and still round-trip
+	for _, name := range []string{"con", "PRN", "com9", "lpt1", "nul", "foo.", "foo ", "aux.bar"} {
+		filePath := PathForObject("/repo/product", name)
+		if problems := PathProblems(filePath, "/repo"); len(problems) > 0 {
+			t.Errorf("Unexpected problems for PathForObject(%q) = %s: %v", name, filePath, problems)
+		}
+		if decoded, ok := ObjectNameForPath(filePath); !ok || decoded != name {
+			t.Errorf("Expected ObjectNameForPath(%q) to return %q, true; instead found %q, %t", filePath, name, decoded, ok)
+		}
+	}
 }
 
 func TestAppendToFile(t *testing.T) {
@@ -311,3 +489,38 @@ END;`
 		t.Errorf("Unexpected result from AddDelimiter: %s", result)
 	}
 }
+
+func TestTokenizeHostileIdentifiers(t *testing.T) {
+	tokenizedFile, err := SQLFile{Dir: "testdata", FileName: "hostile.sql"}.Tokenize()
+	if err != nil {
+		t.Fatalf("Unexpected error parsing hostile.sql: %s", err)
+	}
+	expected := []tengo.ObjectKey{
+		{Type: tengo.ObjectTypeTable, Name: "order"},
+		{Type: tengo.ObjectTypeTable, Name: "my table"},
+		{Type: tengo.ObjectTypeTable, Name: "emoji😀"},
+		{Type: tengo.ObjectTypeTable, Name: "back`tick"},
+		{Type: tengo.ObjectTypeTable, Name: `a/b\c:d*e?f"g<h>i|j`},
+		{Type: tengo.ObjectTypeTable, Name: ".."},
+		{Type: tengo.ObjectTypeTable, Name: "100%"},
+		{Type: tengo.ObjectTypeTable, Name: "con"},
+		{Type: tengo.ObjectTypeProc, Name: "proc;name"},
+		{Type: tengo.ObjectTypeFunc, Name: "key"},
+	}
+	var actual []tengo.ObjectKey
+	for _, stmt := range tokenizedFile.Statements {
+		if stmt.Type == StatementTypeCreate {
+			actual = append(actual, stmt.ObjectKey())
+		} else if stmt.Type != StatementTypeNoop {
+			t.Errorf("Unexpected statement type %d at %s: %s", stmt.Type, stmt.Location(), stmt.Text)
+		}
+	}
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %d CREATE statements, instead found %d: %v", len(expected), len(actual), actual)
+	}
+	for n := range expected {
+		if actual[n] != expected[n] {
+			t.Errorf("Expected statement %d to have key %s, instead found %s", n, expected[n], actual[n])
+		}
+	}
+}
@@ -44,7 +44,9 @@ type Statement struct {
 	ObjectName      string
 	ObjectQualifier string
 	FromFile        *TokenizedSQLFile
+	FromTemplate    *TableTemplate // only non-nil if generated from a table template, in which case FromFile is nil
 	delimiter       string
+	parenProblem    string // location and description of first unbalanced parenthesis, if any
 }
 
 // Location returns the file, line number, and character number where the
@@ -140,11 +142,14 @@ type statementTokenizer struct {
 	stmt   *Statement   // tracking current (not yet completely tokenized) statement
 	buf    bytes.Buffer // tracking text to eventually put into stmt
 
-	lineNo          int    // human-readable line number, starting at 1
-	inRelevant      bool   // true if current statement contains something other than just whitespace and comments
-	inCComment      bool   // true if in a C-style comment
-	inQuote         rune   // nonzero if inside of a quoted string; value indicates which quote rune
-	defaultDatabase string // tracks most recent USE command
+	lineNo          int      // human-readable line number, starting at 1
+	inRelevant      bool     // true if current statement contains something other than just whitespace and comments
+	inCComment      bool     // true if in a C-style comment
+	inQuote         rune     // nonzero if inside of a quoted string; value indicates which quote rune
+	openedAt        string   // line:char where the current quote or C-style comment began
+	openParens      []string // line:char of each unclosed parenthesis in current statement
+	extraParenAt    string   // line:char of first unmatched closing parenthesis in current statement
+	defaultDatabase string   // tracks most recent USE command
 }
 
 type lineState struct {
@@ -169,8 +174,15 @@ func (st *statementTokenizer) statements() ([]*Statement, error) {
 		return nil, err
 	}
 	defer file.Close()
-	reader := bufio.NewReader(file)
+	return st.statementsFrom(file)
+}
 
+// statementsFrom behaves like statements, but reads the file contents from r
+// instead of from st's file path. The file path is still used in the returned
+// statements and in any error messages.
+func (st *statementTokenizer) statementsFrom(r io.Reader) ([]*Statement, error) {
+	reader := bufio.NewReader(r)
+	var err error
 	for err != io.EOF {
 		var line string
 		line, err = reader.ReadString('\n')
@@ -180,9 +192,9 @@ func (st *statementTokenizer) statements() ([]*Statement, error) {
 		st.processLine(line, err == io.EOF)
 	}
 	if st.inQuote != 0 {
-		err = fmt.Errorf("File %s has unterminated quote %c", st.filePath, st.inQuote)
+		err = fmt.Errorf("%s:%s: unterminated quote %c", st.filePath, st.openedAt, st.inQuote)
 	} else if st.inCComment {
-		err = fmt.Errorf("File %s has unterminated C-style comment", st.filePath)
+		err = fmt.Errorf("%s:%s: unterminated C-style comment", st.filePath, st.openedAt)
 	} else {
 		err = nil
 	}
@@ -223,6 +235,7 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 		// C-style comment can be multi-line
 		if c == '/' && ls.peekRune() == '*' {
 			ls.inCComment = true
+			ls.openedAt = ls.position()
 			ls.nextRune()
 			continue
 		}
@@ -262,6 +275,15 @@ func (st *statementTokenizer) processLine(line string, eof bool) {
 			}
 		case '"', '`', '\'':
 			ls.inQuote = c
+			ls.openedAt = ls.position()
+		case '(':
+			ls.openParens = append(ls.openParens, ls.position())
+		case ')':
+			if len(ls.openParens) > 0 {
+				ls.openParens = ls.openParens[:len(ls.openParens)-1]
+			} else if ls.extraParenAt == "" {
+				ls.extraParenAt = ls.position()
+			}
 		case delimFirstRune:
 			// Multi-rune delimiter: peek ahead to see if we've matched the full
 			// delimiter. If so, slurp up the rest of the delimiter's runes.
@@ -300,6 +322,12 @@ func (ls *lineState) nextRune() (rune, int) {
 	return c, cLen
 }
 
+// position returns the human-readable line and column number of the most
+// recently consumed rune, in format "line:char".
+func (ls *lineState) position() string {
+	return fmt.Sprintf("%d:%d", ls.lineNo, ls.charNo)
+}
+
 // peekRune returns the rune at the current position, without advancing.
 func (ls *lineState) peekRune() rune {
 	if ls.pos >= len(ls.line) {
@@ -331,6 +359,8 @@ func (ls *lineState) beginStatement() {
 		DefaultDatabase: ls.defaultDatabase,
 		delimiter:       ls.delimiter,
 	}
+	ls.openParens = nil
+	ls.extraParenAt = ""
 }
 
 // doneStatement finalizes the current statement by filling in its text
@@ -343,6 +373,11 @@ func (ls *lineState) doneStatement(omitEndBytes int) {
 		return
 	}
 	ls.stmt.Text = fmt.Sprintf("%s", ls.buf.Next(bufLen-omitEndBytes))
+	if ls.extraParenAt != "" {
+		ls.stmt.parenProblem = ls.extraParenAt + ": closing parenthesis without matching opening parenthesis"
+	} else if len(ls.openParens) > 0 {
+		ls.stmt.parenProblem = ls.openParens[0] + ": opening parenthesis is never closed"
+	}
 	ls.parseStatement()
 	ls.result = append(ls.result, ls.stmt)
 	ls.stmt = nil
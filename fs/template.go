@@ -0,0 +1,112 @@
+package fs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// TableTemplate represents a CREATE TABLE statement which serves as a shared
+// definition for a list of identically-structured tables. This is configured
+// by the table-template and template-tables options in a dir's .skeema file.
+// The template itself is not a table in the schema; only the tables generated
+// from it are.
+type TableTemplate struct {
+	Statement *Statement // CREATE TABLE statement of the template
+	Tables    []string   // names of tables generated from the template
+}
+
+// Name returns the table name used in the template's CREATE TABLE statement.
+func (tt *TableTemplate) Name() string {
+	return tt.Statement.ObjectName
+}
+
+// CreateFor returns the template's CREATE TABLE statement, without any
+// trailing delimiter, modified to create a table with the supplied name.
+func (tt *TableTemplate) CreateFor(name string) string {
+	return RenameCreateTable(tt.Statement.Body(), name)
+}
+
+// Has returns true if name is in the list of tables generated from the
+// template.
+func (tt *TableTemplate) Has(name string) bool {
+	for _, table := range tt.Tables {
+		if table == name {
+			return true
+		}
+	}
+	return false
+}
+
+var reCreateTableName = regexp.MustCompile("(?is)^(\\s*CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?)(`(?:[^`]|``)+`|\\w+)")
+
+// RenameCreateTable returns a modified version of the supplied CREATE TABLE
+// statement, which creates a table with the supplied name instead. If create
+// is not a CREATE TABLE statement with an unqualified table name, it is
+// returned unchanged.
+func RenameCreateTable(create, name string) string {
+	loc := reCreateTableName.FindStringSubmatchIndex(create)
+	if loc == nil {
+		return create
+	}
+	return create[:loc[3]] + tengo.EscapeIdentifier(name) + create[loc[5]:]
+}
+
+// expandTemplate looks for the table-template and template-tables options in
+// the dir's own option file. If set, the template's CREATE TABLE statement is
+// removed from logicalSchema, and replaced with one generated CREATE TABLE
+// statement for each table name in template-tables. An error is returned if
+// the template cannot be found, or if a generated table conflicts with another
+// CREATE TABLE statement.
+func (dir *Dir) expandTemplate(logicalSchema *LogicalSchema) error {
+	if dir.OptionFile == nil {
+		return nil
+	}
+	templateName, _ := dir.OptionFile.OptionValue("table-template")
+	tableList, _ := dir.OptionFile.OptionValue("template-tables")
+	if templateName == "" {
+		if tableList != "" {
+			return fmt.Errorf("%s: option template-tables requires option table-template to also be set", dir.OptionFile.Path())
+		}
+		return nil
+	}
+	templateKey := tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: templateName}
+	stmt, ok := logicalSchema.Creates[templateKey]
+	if !ok {
+		return fmt.Errorf("%s: table-template %s does not match any CREATE TABLE statement in this directory", dir.OptionFile.Path(), templateName)
+	}
+	if !reCreateTableName.MatchString(stmt.Text) {
+		return fmt.Errorf("%s: unable to determine table name position in table-template %s", stmt.Location(), templateName)
+	}
+	delete(logicalSchema.Creates, templateKey)
+	dir.Template = &TableTemplate{Statement: stmt}
+
+	_, suffix := stmt.SplitTextBody()
+	for _, name := range strings.Split(tableList, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if name == templateName {
+			return fmt.Errorf("%s: template-tables cannot include the name of the table-template itself (%s)", dir.OptionFile.Path(), name)
+		}
+		generated := &Statement{
+			File:            stmt.File,
+			LineNo:          stmt.LineNo,
+			CharNo:          stmt.CharNo,
+			Text:            dir.Template.CreateFor(name) + suffix,
+			DefaultDatabase: stmt.DefaultDatabase,
+			Type:            StatementTypeCreate,
+			ObjectType:      tengo.ObjectTypeTable,
+			ObjectName:      name,
+			FromTemplate:    dir.Template,
+			delimiter:       stmt.delimiter,
+		}
+		if err := logicalSchema.AddStatement(generated); err != nil {
+			return err
+		}
+		dir.Template.Tables = append(dir.Template.Tables, name)
+	}
+	return nil
+}
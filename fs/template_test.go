@@ -0,0 +1,67 @@
+package fs
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestRenameCreateTable(t *testing.T) {
+	cases := map[string]string{
+		"CREATE TABLE `logtable` (id int)":             "CREATE TABLE `events_2024_01` (id int)",
+		"create table logtable (id int)":               "create table `events_2024_01` (id int)",
+		"CREATE TABLE IF NOT EXISTS `log``table` (id)": "CREATE TABLE IF NOT EXISTS `events_2024_01` (id)",
+		"CREATE VIEW logtable AS SELECT 1":             "CREATE VIEW logtable AS SELECT 1",
+	}
+	for input, expected := range cases {
+		if actual := RenameCreateTable(input, "events_2024_01"); actual != expected {
+			t.Errorf("Expected RenameCreateTable(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+}
+
+func TestDirTemplate(t *testing.T) {
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+	WriteTestFile(t, "../testdata/.scratch/logs/.skeema", "schema=logs\ntable-template=logtable\ntemplate-tables=events_2024_01, events_2024_02\n")
+	WriteTestFile(t, "../testdata/.scratch/logs/logtable.sql", "CREATE TABLE logtable (\n  id int unsigned NOT NULL\n);\n")
+	WriteTestFile(t, "../testdata/.scratch/logs/other.sql", "CREATE TABLE other (id int);\n")
+	dir := getDir(t, "../testdata/.scratch/logs")
+	if dir.ParseError != nil {
+		t.Fatalf("Unexpected parse error: %s", dir.ParseError)
+	}
+	if dir.Template == nil || dir.Template.Name() != "logtable" || len(dir.Template.Tables) != 2 || !dir.Template.Has("events_2024_02") {
+		t.Fatalf("Unexpected template: %+v", dir.Template)
+	}
+	creates := dir.LogicalSchemas[0].Creates
+	if len(creates) != 3 {
+		t.Errorf("Expected 3 CREATEs, instead found %d", len(creates))
+	}
+	if _, ok := creates[tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "logtable"}]; ok {
+		t.Error("Expected template to not be included as a table, but it was")
+	}
+	stmt := creates[tengo.ObjectKey{Type: tengo.ObjectTypeTable, Name: "events_2024_01"}]
+	if stmt == nil {
+		t.Fatal("Expected generated table events_2024_01 to be present, but it was not")
+	}
+	if expected := "CREATE TABLE `events_2024_01` (\n  id int unsigned NOT NULL\n);\n"; stmt.Text != expected {
+		t.Errorf("Unexpected text of generated statement: %q", stmt.Text)
+	}
+	if stmt.FromTemplate != dir.Template || stmt.FromFile != nil || stmt.File != dir.Template.Statement.File {
+		t.Errorf("Unexpected origin fields of generated statement: %+v", stmt)
+	}
+
+	// Error cases: missing template, list without template, generated name
+	// conflicting with template or with another table
+	badOptionFiles := []string{
+		"schema=logs\ntable-template=nonexistent\n",
+		"schema=logs\ntemplate-tables=events_2024_01\n",
+		"schema=logs\ntable-template=logtable\ntemplate-tables=logtable\n",
+		"schema=logs\ntable-template=logtable\ntemplate-tables=other\n",
+	}
+	for _, contents := range badOptionFiles {
+		WriteTestFile(t, "../testdata/.scratch/logs/.skeema", contents)
+		if _, err := ParseDir("../testdata/.scratch/logs", getValidConfig(t)); err == nil {
+			t.Errorf("Expected parse error from .skeema contents %q, but err was nil", contents)
+		}
+	}
+}
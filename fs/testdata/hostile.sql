@@ -0,0 +1,42 @@
+-- Object names in this file are intentionally hostile: reserved words,
+-- whitespace, non-ASCII characters, backticks, and characters that are
+-- invalid in file names on common filesystems
+
+CREATE TABLE `order` (
+  `group` int NOT NULL,
+  `select` varchar(10) DEFAULT NULL,
+  PRIMARY KEY (`group`)
+);
+
+CREATE TABLE `my table` (
+  `my column` int NOT NULL
+);
+
+CREATE TABLE `emoji😀` (
+  `naïve` int NOT NULL,
+  `日本語` varchar(10) DEFAULT NULL
+);
+
+CREATE TABLE `back``tick` (
+  `a``b` int NOT NULL
+);
+
+CREATE TABLE `a/b\c:d*e?f"g<h>i|j` (
+  `id` int NOT NULL
+);
+
+CREATE TABLE `..` (
+  `id` int NOT NULL
+);
+
+CREATE TABLE `100%` (
+  `id` int NOT NULL
+);
+
+CREATE TABLE `con` (
+  `id` int NOT NULL
+);
+
+CREATE PROCEDURE `proc;name`() SELECT 1;
+
+CREATE FUNCTION `key`() RETURNS int RETURN 1;
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"fmt"
+)
+
+// ValidateTree examines dir and its subdirectories, up to maxDepth levels
+// below dir, for problems which can be detected without connecting to a
+// database server: unparseable option files, duplicate object definitions,
+// *.sql files containing unterminated quotes or comments, CREATE statements
+// with unbalanced parentheses, and invalid passthrough or grants files. All
+// problems found are returned, rather than stopping at the first one. Each
+// error's message begins with the location of the problem, as a
+// file:line:char position where possible.
+func ValidateTree(dir *Dir, maxDepth int) (problems []error) {
+	if dir.ParseError != nil {
+		if dde, ok := dir.ParseError.(DuplicateDefinitionError); ok {
+			problems = append(problems, fmt.Errorf("%s:%d: %s", dde.DupeFile, dde.DupeLine, dde))
+		} else if ofe, ok := dir.ParseError.(OptionFileError); ok {
+			for _, p := range ofe.Problems {
+				problems = append(problems, fmt.Errorf("%s:%d:%d: %s: %q", ofe.FilePath, p.LineNumber, p.Column, p.Problem, p.Text))
+			}
+		} else {
+			problems = append(problems, fmt.Errorf("%s: %s", dir, dir.ParseError))
+		}
+	}
+	for _, sf := range dir.SQLFiles {
+		tokenizedFile, err := sf.Tokenize()
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		for _, stmt := range tokenizedFile.Statements {
+			if stmt.Type == StatementTypeCreate && stmt.parenProblem != "" {
+				problems = append(problems, fmt.Errorf("%s:%s", stmt.File, stmt.parenProblem))
+			}
+		}
+	}
+	for _, sf := range dir.PassthroughFiles {
+		if _, err := PassthroughStatements(sf); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if dir.GrantsFile != nil {
+		if _, err := GrantStatements(*dir.GrantsFile); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if maxDepth < 1 {
+		return problems
+	}
+	subdirs, err := dir.Subdirs()
+	if err != nil {
+		return append(problems, fmt.Errorf("%s: %s", dir, err))
+	}
+	for _, subdir := range subdirs {
+		problems = append(problems, ValidateTree(subdir, maxDepth-1)...)
+	}
+	return problems
+}
@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTree(t *testing.T) {
+	base, _ := filepath.Abs("../testdata/.scratch/validate")
+	WriteTestFile(t, base+"/.skeema", "schema=product\n")
+	WriteTestFile(t, base+"/ok.sql", "CREATE TABLE ok (\n  id int, -- comment with ( paren\n  name varchar(10) DEFAULT ')'\n);\n")
+	defer RemoveTestDirectory(t, "../testdata/.scratch")
+
+	dir, err := ParseDir(base, getValidConfig(t))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	if problems := ValidateTree(dir, 5); len(problems) > 0 {
+		t.Fatalf("Expected no problems in valid tree, instead found %v", problems)
+	}
+
+	// Introduce problems in several files and subdirs; all should be reported,
+	// with their positions
+	WriteTestFile(t, base+"/parens.sql", "CREATE TABLE parens (\n  id int,\n  name varchar(10\n);\n")
+	WriteTestFile(t, base+"/extra.sql", "CREATE TABLE extra (id int));\n")
+	WriteTestFile(t, base+"/sub/quote.sql", "CREATE TABLE quote (\n  name varchar(10) DEFAULT 'foo\n);\n")
+	WriteTestFile(t, base+"/sub/sub2/dupe1.sql", "CREATE TABLE dupe (id int);\n")
+	WriteTestFile(t, base+"/sub/sub2/dupe2.sql", "\nCREATE TABLE dupe (id int);\n")
+	WriteTestFile(t, base+"/sub/sub2/.skeema", "bad option file\n")
+	WriteTestFile(t, base+"/sub/sub3/ignored.sql", "INSERT INTO foo (id VALUES (1);\n")
+	if dir, err = ParseDir(base, getValidConfig(t)); err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	problems := ValidateTree(dir, 5)
+	expected := []string{
+		base + "/extra.sql:1:28: closing parenthesis",
+		base + "/parens.sql:1:21: opening parenthesis",
+		base + "/sub/quote.sql:2:28: unterminated quote '",
+		base + "/sub/sub2/.skeema:1:1: unknown option",
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("Expected %d problems, instead found %d: %v", len(expected), len(problems), problems)
+	}
+	for n, problem := range problems {
+		if !strings.HasPrefix(problem.Error(), expected[n]) {
+			t.Errorf("Expected problem[%d] to begin with %q, instead found %q", n, expected[n], problem)
+		}
+	}
+
+	// Duplicate definitions are reported with the position of the duplicate
+	RemoveTestFile(t, base+"/sub/sub2/.skeema")
+	if dir, err = ParseDir(base, getValidConfig(t)); err != nil {
+		t.Fatalf("Unexpected error from ParseDir: %s", err)
+	}
+	problems = ValidateTree(dir, 5)
+	if len(problems) != 4 || !strings.HasPrefix(problems[3].Error(), base+"/sub/sub2/dupe2.sql:2: ") {
+		t.Errorf("Unexpected problems: %v", problems)
+	}
+
+	// Subdirs beyond maxDepth are not examined
+	if problems = ValidateTree(dir, 0); len(problems) != 2 {
+		t.Errorf("Expected 2 problems with maxDepth 0, instead found %d: %v", len(problems), problems)
+	}
+}
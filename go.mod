@@ -5,6 +5,8 @@ go 1.12
 require (
 	github.com/VividCortex/mysqlerr v0.0.0-20170204212430-6c6b55f8796f
 	github.com/alecthomas/participle v0.3.0
+	github.com/fsouza/go-dockerclient v1.2.1
+	github.com/go-sql-driver/mysql v1.4.1-0.20190510102335-877a9775f068
 	github.com/jmoiron/sqlx v0.0.0-20180406164412-2aeb6a910c2b
 	github.com/mattn/goveralls v0.0.3-0.20190605103025-4d9899298d21
 	github.com/mitchellh/go-wordwrap v1.0.0
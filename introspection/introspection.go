@@ -0,0 +1,189 @@
+// Package introspection provides a simplified view of the tables in a live
+// database schema, for use by other programs which want to reuse Skeema's
+// introspection logic without going through its command-line interface. For
+// example, a code generator may use this package to obtain the columns and
+// indexes of each table.
+//
+// The types in this package are plain data structures, with no methods which
+// interact with the database. They intentionally expose less detail than the
+// corresponding types in package tengo, in exchange for a smaller surface area
+// which is less likely to change between releases. Only minimal backwards
+// compatibility is promised, but fields will not be renamed or removed without
+// good reason.
+package introspection
+
+import (
+	"github.com/skeema/tengo"
+)
+
+// Schema represents a database schema and the tables within it.
+type Schema struct {
+	Name      string
+	CharSet   string
+	Collation string
+	Tables    []*Table
+}
+
+// Table represents a single table, including its canonical CREATE TABLE
+// statement.
+type Table struct {
+	Name            string
+	Engine          string
+	CharSet         string
+	Collation       string
+	Comment         string
+	Columns         []*Column // in the order they appear in the table
+	PrimaryKey      *Index    // nil if table has no primary key
+	Indexes         []*Index  // secondary indexes, in the order they appear in the table
+	ForeignKeys     []*ForeignKey
+	Partitioned     bool
+	CreateStatement string // canonical CREATE TABLE, as written by `skeema pull`
+}
+
+// Column represents a single column of a table.
+type Column struct {
+	Name          string
+	Type          string // full type, for example "varchar(40)" or "int(10) unsigned"
+	Nullable      bool
+	AutoIncrement bool
+	Default       string // SQL expression, for example "NULL" or "'foo'"; blank if none
+	OnUpdate      string // SQL expression; blank if none
+	Generated     bool   // true if the column is a virtual or stored generated column
+	CharSet       string // only populated for textual types
+	Collation     string // only populated for textual types
+	Comment       string
+}
+
+// Index represents a primary key or secondary index of a table.
+type Index struct {
+	Name     string
+	Columns  []string // column names, in index order
+	SubParts []uint16 // prefix length for each column; 0 means the full column
+	Primary  bool
+	Unique   bool
+	Type     string // "BTREE", "FULLTEXT", "SPATIAL", etc
+	Comment  string
+}
+
+// ForeignKey represents a foreign key constraint of a table.
+type ForeignKey struct {
+	Name              string
+	Columns           []string
+	ReferencedSchema  string // blank if the referenced table is in the same schema
+	ReferencedTable   string
+	ReferencedColumns []string
+	UpdateRule        string
+	DeleteRule        string
+}
+
+// GetSchema introspects the schema with the supplied name on inst. If the
+// schema does not exist, a nil Schema and a nil error are returned.
+func GetSchema(inst *tengo.Instance, name string) (*Schema, error) {
+	exists, err := inst.HasSchema(name)
+	if err != nil || !exists {
+		return nil, err
+	}
+	schema, err := inst.Schema(name)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertSchema(schema), nil
+}
+
+// ConvertSchema converts a *tengo.Schema, which may have been obtained by other
+// means, into a *Schema.
+func ConvertSchema(schema *tengo.Schema) *Schema {
+	result := &Schema{
+		Name:      schema.Name,
+		CharSet:   schema.CharSet,
+		Collation: schema.Collation,
+		Tables:    make([]*Table, len(schema.Tables)),
+	}
+	for n, table := range schema.Tables {
+		result.Tables[n] = convertTable(table)
+	}
+	return result
+}
+
+func convertTable(table *tengo.Table) *Table {
+	createStatement, _ := tengo.ParseCreateAutoInc(table.CreateStatement)
+	result := &Table{
+		Name:            table.Name,
+		Engine:          table.Engine,
+		CharSet:         table.CharSet,
+		Collation:       table.Collation,
+		Comment:         table.Comment,
+		Columns:         make([]*Column, len(table.Columns)),
+		Indexes:         make([]*Index, len(table.SecondaryIndexes)),
+		ForeignKeys:     make([]*ForeignKey, len(table.ForeignKeys)),
+		Partitioned:     table.Partitioning != nil,
+		CreateStatement: createStatement,
+	}
+	for n, col := range table.Columns {
+		result.Columns[n] = convertColumn(col)
+	}
+	if table.PrimaryKey != nil {
+		result.PrimaryKey = convertIndex(table.PrimaryKey)
+	}
+	for n, idx := range table.SecondaryIndexes {
+		result.Indexes[n] = convertIndex(idx)
+	}
+	for n, fk := range table.ForeignKeys {
+		result.ForeignKeys[n] = &ForeignKey{
+			Name:              fk.Name,
+			Columns:           columnNames(fk.Columns),
+			ReferencedSchema:  fk.ReferencedSchemaName,
+			ReferencedTable:   fk.ReferencedTableName,
+			ReferencedColumns: append([]string{}, fk.ReferencedColumnNames...),
+			UpdateRule:        fk.UpdateRule,
+			DeleteRule:        fk.DeleteRule,
+		}
+	}
+	return result
+}
+
+func convertColumn(col *tengo.Column) *Column {
+	result := &Column{
+		Name:          col.Name,
+		Type:          col.TypeInDB,
+		Nullable:      col.Nullable,
+		AutoIncrement: col.AutoIncrement,
+		OnUpdate:      col.OnUpdate,
+		Generated:     col.GenerationExpr != "",
+		CharSet:       col.CharSet,
+		Collation:     col.Collation,
+		Comment:       col.Comment,
+	}
+	if !result.AutoIncrement && !result.Generated {
+		if col.Default.Null {
+			if col.Nullable {
+				result.Default = "NULL"
+			}
+		} else if col.Default.Quoted {
+			result.Default = "'" + tengo.EscapeValueForCreateTable(col.Default.Value) + "'"
+		} else {
+			result.Default = col.Default.Value
+		}
+	}
+	return result
+}
+
+func convertIndex(idx *tengo.Index) *Index {
+	return &Index{
+		Name:     idx.Name,
+		Columns:  columnNames(idx.Columns),
+		SubParts: append([]uint16{}, idx.SubParts...),
+		Primary:  idx.PrimaryKey,
+		Unique:   idx.Unique,
+		Type:     idx.Type,
+		Comment:  idx.Comment,
+	}
+}
+
+func columnNames(cols []*tengo.Column) []string {
+	names := make([]string, len(cols))
+	for n, col := range cols {
+		names[n] = col.Name
+	}
+	return names
+}
@@ -0,0 +1,141 @@
+package introspection
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// testSchema returns a tengo.Schema with two tables, one of which has a foreign
+// key referencing the other.
+func testSchema() *tengo.Schema {
+	userID := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned", AutoIncrement: true, Default: tengo.ColumnDefaultNull}
+	userName := &tengo.Column{Name: "name", TypeInDB: "varchar(40)", Nullable: true, Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", CollationIsDefault: true}
+	users := &tengo.Table{
+		Name:               "users",
+		Engine:             "InnoDB",
+		CharSet:            "utf8mb4",
+		Collation:          "utf8mb4_general_ci",
+		CollationIsDefault: true,
+		Columns:            []*tengo.Column{userID, userName},
+		PrimaryKey:         &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{userID}, SubParts: []uint16{0}, PrimaryKey: true, Unique: true, Type: "BTREE"},
+		SecondaryIndexes:   []*tengo.Index{{Name: "name", Columns: []*tengo.Column{userName}, SubParts: []uint16{10}, Type: "BTREE"}},
+		NextAutoIncrement:  12,
+	}
+	users.CreateStatement = users.GeneratedCreateStatement(tengo.FlavorMySQL57)
+
+	postID := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	postUserID := &tengo.Column{Name: "user_id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	postStatus := &tengo.Column{Name: "status", TypeInDB: "varchar(20)", Default: tengo.ColumnDefaultValue("it's new"), CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", CollationIsDefault: true}
+	posts := &tengo.Table{
+		Name:               "posts",
+		Engine:             "InnoDB",
+		CharSet:            "utf8mb4",
+		Collation:          "utf8mb4_general_ci",
+		CollationIsDefault: true,
+		Columns:            []*tengo.Column{postID, postUserID, postStatus},
+		PrimaryKey:         &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{postID}, SubParts: []uint16{0}, PrimaryKey: true, Unique: true, Type: "BTREE"},
+		SecondaryIndexes:   []*tengo.Index{{Name: "user_id", Columns: []*tengo.Column{postUserID}, SubParts: []uint16{0}, Type: "BTREE"}},
+		ForeignKeys: []*tengo.ForeignKey{{
+			Name:                  "posts_user",
+			Columns:               []*tengo.Column{postUserID},
+			ReferencedTableName:   "users",
+			ReferencedColumnNames: []string{"id"},
+			UpdateRule:            "RESTRICT",
+			DeleteRule:            "CASCADE",
+		}},
+	}
+	posts.CreateStatement = posts.GeneratedCreateStatement(tengo.FlavorMySQL57)
+
+	return &tengo.Schema{
+		Name:      "product",
+		CharSet:   "utf8mb4",
+		Collation: "utf8mb4_general_ci",
+		Tables:    []*tengo.Table{posts, users},
+	}
+}
+
+func TestConvertSchema(t *testing.T) {
+	schema := ConvertSchema(testSchema())
+	if schema.Name != "product" || schema.CharSet != "utf8mb4" || len(schema.Tables) != 2 {
+		t.Fatalf("Unexpected schema: %+v", schema)
+	}
+	posts, users := schema.Tables[0], schema.Tables[1]
+
+	if users.PrimaryKey == nil || !users.PrimaryKey.Primary || fmt.Sprint(users.PrimaryKey.Columns) != "[id]" {
+		t.Errorf("Unexpected primary key: %+v", users.PrimaryKey)
+	}
+	if len(users.Indexes) != 1 || users.Indexes[0].SubParts[0] != 10 || users.Indexes[0].Unique {
+		t.Errorf("Unexpected secondary indexes: %+v", users.Indexes)
+	}
+	if strings.Contains(users.CreateStatement, "AUTO_INCREMENT=") {
+		t.Errorf("Expected canonical CREATE TABLE to omit next auto-increment value, instead found %s", users.CreateStatement)
+	}
+	if !strings.HasPrefix(users.CreateStatement, "CREATE TABLE `users`") {
+		t.Errorf("Unexpected CREATE TABLE: %s", users.CreateStatement)
+	}
+
+	expectDefaults := map[string]string{
+		"users.id":      "",
+		"users.name":    "NULL",
+		"posts.id":      "",
+		"posts.user_id": "",
+		"posts.status":  "'it''s new'",
+	}
+	for _, table := range schema.Tables {
+		for _, col := range table.Columns {
+			if expected := expectDefaults[table.Name+"."+col.Name]; col.Default != expected {
+				t.Errorf("Expected default of %s.%s to be %q, instead found %q", table.Name, col.Name, expected, col.Default)
+			}
+		}
+	}
+
+	if len(posts.ForeignKeys) != 1 {
+		t.Fatalf("Expected 1 foreign key, instead found %d", len(posts.ForeignKeys))
+	}
+	fk := posts.ForeignKeys[0]
+	if fk.Name != "posts_user" || fmt.Sprint(fk.Columns) != "[user_id]" || fk.ReferencedSchema != "" || fk.ReferencedTable != "users" || fmt.Sprint(fk.ReferencedColumns) != "[id]" || fk.DeleteRule != "CASCADE" {
+		t.Errorf("Unexpected foreign key: %+v", fk)
+	}
+}
+
+func ExampleGetSchema() {
+	inst, err := tengo.NewInstance("mysql", "root:password@tcp(127.0.0.1:3306)/")
+	if err != nil {
+		panic(err)
+	}
+	schema, err := GetSchema(inst, "product")
+	if err != nil {
+		panic(err)
+	} else if schema == nil {
+		fmt.Println("Schema product does not exist")
+		return
+	}
+	for _, table := range schema.Tables {
+		fmt.Printf("Table %s has %d columns\n", table.Name, len(table.Columns))
+	}
+}
+
+func ExampleConvertSchema() {
+	schema := ConvertSchema(testSchema())
+	for _, table := range schema.Tables {
+		fmt.Printf("%s:\n", table.Name)
+		for _, col := range table.Columns {
+			fmt.Printf("  %s %s\n", col.Name, col.Type)
+		}
+		for _, fk := range table.ForeignKeys {
+			fmt.Printf("  (%s) references %s(%s)\n", strings.Join(fk.Columns, ", "), fk.ReferencedTable, strings.Join(fk.ReferencedColumns, ", "))
+		}
+	}
+	// Output:
+	// posts:
+	//   id int(10) unsigned
+	//   user_id int(10) unsigned
+	//   status varchar(20)
+	//   (user_id) references users(id)
+	// users:
+	//   id int(10) unsigned
+	//   name varchar(40)
+}
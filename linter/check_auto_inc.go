@@ -3,7 +3,6 @@ package linter
 import (
 	"fmt"
 	"math"
-	"regexp"
 	"strings"
 
 	"github.com/skeema/tengo"
@@ -59,7 +58,7 @@ func autoIncChecker(table *tengo.Table, createStatement string, _ *tengo.Schema,
 	if matches != nil {                                        // to remove the display width from the type
 		colType = fmt.Sprintf("%s%s", matches[1], matches[3])
 	}
-	re := regexp.MustCompile(fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(col.Name)))
+	re := columnNameRegexp(col.Name)
 
 	// Return a Note if the auto-inc col's type not found in --allow-auto-inc
 	if !opts.IsAllowed("auto-inc", colType) {
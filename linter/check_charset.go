@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -28,7 +29,7 @@ func charsetChecker(table *tengo.Table, createStatement string, _ *tengo.Schema,
 	// Check the table's default charset. If it fails, return a single
 	// Note without checking individual columns, as we don't want a bunch
 	// of redundant messages for columns using the table default charset.
-	if !opts.IsAllowed("charset", table.CharSet) {
+	if !charSetAllowed(table.CharSet, opts) {
 		re := regexp.MustCompile(fmt.Sprintf(`(?i)(default)?\s*(character\s+set|charset|collate)\s*=?\s*(%s|%s)`, table.CharSet, table.Collation))
 		note := Note{
 			LineOffset: FindLastLineOffset(re, createStatement),
@@ -41,8 +42,8 @@ func charsetChecker(table *tengo.Table, createStatement string, _ *tengo.Schema,
 	// Now check individual columns
 	var results []Note
 	for _, col := range table.Columns {
-		if col.CharSet != "" && !opts.IsAllowed("charset", col.CharSet) {
-			re := regexp.MustCompile(fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(col.Name)))
+		if col.CharSet != "" && !charSetAllowed(col.CharSet, opts) {
+			re := columnNameRegexp(col.Name)
 			results = append(results, Note{
 				LineOffset: FindFirstLineOffset(re, createStatement),
 				Summary:    "Character set not permitted",
@@ -53,6 +54,19 @@ func charsetChecker(table *tengo.Table, createStatement string, _ *tengo.Schema,
 	return results
 }
 
+// charSetAllowed returns true if charSet is listed in allow-charset. The utf8
+// and utf8mb3 spellings are treated as equivalent, since servers report one or
+// the other depending on version.
+func charSetAllowed(charSet string, opts Options) bool {
+	charSet = strings.ToLower(charSet)
+	for _, allowed := range opts.AllowList("charset") {
+		if util.EquivalentCharSets(charSet, strings.ToLower(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
 func makeCharsetMessage(table *tengo.Table, column *tengo.Column, opts Options) string {
 	var subject, charSet, using, allowedList, moreInfo string
 	if column == nil {
@@ -70,7 +84,7 @@ func makeCharsetMessage(table *tengo.Table, column *tengo.Column, opts Options)
 	} else {
 		allowedList = fmt.Sprintf(" The following character sets are listed in option allow-charset: %s.", strings.Join(allowedCharSets, ", "))
 	}
-	if charSet == "utf8" && opts.IsAllowed("charset", "utf8mb4") {
+	if util.CanonicalCharSet(charSet) == "utf8" && opts.IsAllowed("charset", "utf8mb4") {
 		moreInfo = "\nTo permit storage of all valid four-byte UTF-8 characters, use the utf8mb4 character set instead of the legacy three-byte utf8 character set."
 	} else if charSet == "binary" {
 		moreInfo = "\nUsing equivalent binary column types (e.g. BINARY, VARBINARY, BLOB) is preferred for readability."
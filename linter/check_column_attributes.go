@@ -0,0 +1,109 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(columnAttributesChecker),
+		Name:            "column-attributes",
+		Description:     "Flag column attributes which the target server flavor does not support",
+		DefaultSeverity: SeverityError,
+	})
+}
+
+// columnAttribute describes a storage-related column attribute which only some
+// flavors support.
+type columnAttribute struct {
+	Name      string // description of the attribute, e.g. "STORAGE"
+	Detect    func(def columnDef) bool
+	Supported func(flavor tengo.Flavor) bool
+}
+
+// Regular expressions used in detecting column attributes, all matched against
+// the type portion of a column definition as written in the filesystem, with
+// comments and quoted strings blanked out but the contents of executable
+// comments retained
+var (
+	reColumnFormatCompressed = regexp.MustCompile(`(?i)\bcolumn_format\s+compressed\b`)
+	reColumnFormat           = regexp.MustCompile(`(?i)\bcolumn_format\s+(fixed|dynamic|default)\b`)
+	reColumnStorage          = regexp.MustCompile(`(?i)\bstorage\s+(disk|memory|default)\b`)
+	reColumnCompressed       = regexp.MustCompile(`(?i)\bcompressed\b`)
+)
+
+// columnAttributes is the list of attributes checked by the column-attributes
+// rule. At most one unsupported attribute is reported for each column.
+var columnAttributes = []columnAttribute{
+	{
+		Name: "COLUMN_FORMAT COMPRESSED",
+		Detect: func(def columnDef) bool {
+			return reColumnFormatCompressed.MatchString(def.ExecText)
+		},
+		Supported: func(flavor tengo.Flavor) bool {
+			return flavor.VendorMinVersion(tengo.VendorPercona, 5, 6)
+		},
+	},
+	{
+		Name: "COLUMN_FORMAT",
+		Detect: func(def columnDef) bool {
+			return reColumnFormat.MatchString(def.ExecText)
+		},
+		Supported: func(flavor tengo.Flavor) bool {
+			return flavor.MySQLishMinVersion(5, 6)
+		},
+	},
+	{
+		Name: "STORAGE",
+		Detect: func(def columnDef) bool {
+			return reColumnStorage.MatchString(def.ExecText)
+		},
+		Supported: func(flavor tengo.Flavor) bool {
+			return flavor.MySQLishMinVersion(5, 6)
+		},
+	},
+	{
+		Name: "COMPRESSED",
+		Detect: func(def columnDef) bool {
+			// MariaDB's standalone attribute, as opposed to Percona's COLUMN_FORMAT
+			// COMPRESSED
+			text := reColumnFormatCompressed.ReplaceAllString(def.ExecText, "")
+			return reColumnCompressed.MatchString(text)
+		},
+		Supported: func(flavor tengo.Flavor) bool {
+			return flavor.VendorMinVersion(tengo.VendorMariaDB, 10, 3)
+		},
+	},
+}
+
+func columnAttributesChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) []Note {
+	results := make([]Note, 0)
+	if !opts.Flavor.Known() {
+		return results
+	}
+	defs := columnDefs(createStatement)
+	for _, col := range table.Columns {
+		def, ok := defs[strings.ToLower(col.Name)]
+		if !ok {
+			continue
+		}
+		for _, attr := range columnAttributes {
+			if attr.Detect(def) && !attr.Supported(opts.Flavor) {
+				results = append(results, Note{
+					LineOffset: def.LineOffset,
+					Summary:    "Unsupported column attribute",
+					Message: fmt.Sprintf(
+						"Column %s of table %s uses the %s attribute, which %s does not support. The server may reject this definition or silently discard the attribute, causing a persistent difference between the *.sql file and the database.",
+						col.Name, table.Name, attr.Name, opts.Flavor,
+					),
+				})
+				break
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,44 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(datetimeDefaultChecker),
+		Name:            "datetime-default",
+		Description:     "Flag DATETIME columns that lack a DEFAULT clause",
+		DefaultSeverity: SeverityIgnore,
+	})
+}
+
+var reDefaultKeyword = regexp.MustCompile(`(?i)\bdefault\b`)
+
+func datetimeDefaultChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, _ Options) []Note {
+	results := make([]Note, 0)
+	defs := columnDefs(createStatement)
+	for _, col := range table.Columns {
+		if !strings.HasPrefix(col.TypeInDB, "datetime") || col.GenerationExpr != "" {
+			continue
+		}
+		def, ok := defs[strings.ToLower(col.Name)]
+		if !ok || reDefaultKeyword.MatchString(def.Text) {
+			continue
+		}
+		message := fmt.Sprintf(
+			"Column %s of table %s is using type %s without a DEFAULT clause. Specify a default explicitly, for example DEFAULT CURRENT_TIMESTAMP, or DEFAULT NULL for a nullable column.",
+			col.Name, table.Name, col.TypeInDB,
+		)
+		results = append(results, Note{
+			LineOffset: def.LineOffset,
+			Summary:    "DATETIME column without default",
+			Message:    message,
+		})
+	}
+	return results
+}
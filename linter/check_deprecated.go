@@ -0,0 +1,177 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(deprecatedChecker),
+		Name:            "deprecated",
+		Description:     "Flag column definitions using features deprecated or removed in the target server version",
+		DefaultSeverity: SeverityWarning,
+	})
+}
+
+// deprecatedFeature describes a column definition feature which has been
+// deprecated, and possibly later removed, in MySQL. Versions are full MySQL
+// version strings, but since flavors only track major and minor versions, a
+// feature is considered deprecated or removed for any flavor in the same
+// release series or later.
+type deprecatedFeature struct {
+	Name        string // description of the feature, e.g. "the YEAR(2) type"
+	Deprecated  string // MySQL version deprecating the feature
+	Removed     string // MySQL version removing the feature; blank if not removed yet
+	Replacement string // suggested alternative, phrased as an imperative
+	Detect      func(col *tengo.Column, def columnDef) bool
+}
+
+// Regular expressions used in detecting deprecated features, all matched
+// against the type portion of a column definition as written in the
+// filesystem, with comments and quoted strings blanked out
+var (
+	reYear2          = regexp.MustCompile(`(?i)^year\s*\(\s*2\s*\)`)
+	reZerofill       = regexp.MustCompile(`(?i)\bzerofill\b`)
+	reIntWidth       = regexp.MustCompile(`(?i)^(tinyint|smallint|mediumint|int|integer|bigint)\s*\(\s*(\d+)\s*\)`)
+	reFloatPrecision = regexp.MustCompile(`(?i)^(float|double(\s+precision)?|real)\s*\(\s*\d+\s*,\s*\d+\s*\)`)
+	reUtf8mb3        = regexp.MustCompile(`(?i)\b(char(acter)?\s+set|charset)\s*=?\s*utf8(mb3)?\b`)
+)
+
+// deprecatedFeatures is the matrix of deprecated features checked by the
+// deprecated rule. Features should be listed in order of MySQL version.
+var deprecatedFeatures = []deprecatedFeature{
+	{
+		Name:        "the YEAR(2) type",
+		Deprecated:  "5.6.6",
+		Removed:     "5.7.5",
+		Replacement: "use YEAR or YEAR(4) instead, converting any existing values",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			return reYear2.MatchString(def.TypeText) || col.TypeInDB == "year(2)"
+		},
+	},
+	{
+		Name:        "the zerofill attribute",
+		Deprecated:  "8.0.17",
+		Replacement: "pad values in the application instead, for example using LPAD()",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			return reZerofill.MatchString(def.TypeText)
+		},
+	},
+	{
+		Name:        "an integer display width",
+		Deprecated:  "8.0.17",
+		Replacement: "omit the display width, which does not affect the range of values the column can store",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			matches := reIntWidth.FindStringSubmatch(def.TypeText)
+			// tinyint(1) is still permitted, since BOOL is an alias for it
+			return matches != nil && !(strings.EqualFold(matches[1], "tinyint") && matches[2] == "1")
+		},
+	},
+	{
+		Name:        "a nonstandard FLOAT(M,D) or DOUBLE(M,D) precision",
+		Deprecated:  "8.0.17",
+		Replacement: "use DECIMAL(M,D) for exact values, or omit the precision",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			return reFloatPrecision.MatchString(def.TypeText)
+		},
+	},
+	{
+		Name:        "the unsigned attribute on a DECIMAL, FLOAT, or DOUBLE column",
+		Deprecated:  "8.0.17",
+		Replacement: "use a CHECK constraint to restrict the column to non-negative values",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			return strings.HasSuffix(col.TypeInDB, " unsigned") && isFractionalType(col.TypeInDB)
+		},
+	},
+	{
+		Name:        "AUTO_INCREMENT on a FLOAT or DOUBLE column",
+		Deprecated:  "8.0.17",
+		Replacement: "use an integer type for the AUTO_INCREMENT column",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			return col.AutoIncrement && isFractionalType(col.TypeInDB) && !strings.HasPrefix(col.TypeInDB, "decimal")
+		},
+	},
+	{
+		Name:        "the utf8mb3 character set (also called utf8)",
+		Deprecated:  "8.0.0",
+		Replacement: "use utf8mb4 instead",
+		Detect: func(col *tengo.Column, def columnDef) bool {
+			return col.CharSet == "utf8" || col.CharSet == "utf8mb3" || reUtf8mb3.MatchString(def.TypeText)
+		},
+	},
+}
+
+// isFractionalType returns true if typeInDB is a DECIMAL, FLOAT, or DOUBLE
+// type.
+func isFractionalType(typeInDB string) bool {
+	for _, prefix := range []string{"decimal", "float", "double"} {
+		if strings.HasPrefix(typeInDB, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// status returns whether df is deprecated and/or removed in flavor. Features
+// are only tracked for MySQL and Percona Server.
+func (df deprecatedFeature) status(flavor tengo.Flavor) (deprecated, removed bool) {
+	minVersion := func(version string) bool {
+		parts := tengo.ParseVersion(version)
+		return flavor.MySQLishMinVersion(parts[0], parts[1])
+	}
+	deprecated = minVersion(df.Deprecated)
+	removed = df.Removed != "" && minVersion(df.Removed)
+	return deprecated, removed
+}
+
+// deprecatedColumnFeatures calls f for each combination of column of table
+// and deprecated feature which the column uses in flavor. createStatement
+// should be the table's definition as written in the filesystem.
+func deprecatedColumnFeatures(table *tengo.Table, createStatement string, flavor tengo.Flavor, f func(col *tengo.Column, def columnDef, df deprecatedFeature, removed bool)) {
+	defs := columnDefs(createStatement)
+	for _, col := range table.Columns {
+		def := defs[strings.ToLower(col.Name)]
+		for _, df := range deprecatedFeatures {
+			if deprecated, removed := df.status(flavor); deprecated && df.Detect(col, def) {
+				f(col, def, df, removed)
+			}
+		}
+	}
+}
+
+func deprecatedChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) []Note {
+	results := make([]Note, 0)
+	deprecatedColumnFeatures(table, createStatement, opts.Flavor, func(col *tengo.Column, def columnDef, df deprecatedFeature, removed bool) {
+		summary, status := "Deprecated feature detected", "deprecated as of MySQL "+df.Deprecated
+		if removed {
+			summary, status = "Removed feature detected", "removed as of MySQL "+df.Removed
+		}
+		results = append(results, Note{
+			LineOffset: def.LineOffset,
+			Summary:    summary,
+			Message: fmt.Sprintf(
+				"Column %s of table %s is using %s, which is %s. The server may reject this definition or silently rewrite it, causing a persistent difference between the *.sql file and the database. Instead, %s.",
+				col.Name, table.Name, df.Name, status, df.Replacement,
+			),
+		})
+	})
+	return results
+}
+
+// RemovedFeatures returns a description of each column of table which uses a
+// feature that has been removed in flavor, and would therefore cause DDL to
+// fail. createStatement should be the table's definition as written in the
+// filesystem.
+func RemovedFeatures(table *tengo.Table, createStatement string, flavor tengo.Flavor) []string {
+	var problems []string
+	deprecatedColumnFeatures(table, createStatement, flavor, func(col *tengo.Column, _ columnDef, df deprecatedFeature, removed bool) {
+		if removed {
+			problems = append(problems, fmt.Sprintf("column %s of table %s is using %s, which was removed in MySQL %s; %s", tengo.EscapeIdentifier(col.Name), tengo.EscapeIdentifier(table.Name), df.Name, df.Removed, df.Replacement))
+		}
+	})
+	return problems
+}
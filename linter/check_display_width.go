@@ -34,8 +34,9 @@ var signedDefaultWidths = map[string]int{
 	"bigint":    20, // unsigned also 20
 }
 
-func displayWidthChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, _ Options) []Note {
+func displayWidthChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) []Note {
 	results := make([]Note, 0)
+	deprecated := opts.Flavor.MySQLishMinVersion(8, 0)
 	for _, col := range table.Columns {
 		if !strings.Contains(col.TypeInDB, "int(") {
 			continue
@@ -44,7 +45,19 @@ func displayWidthChecker(table *tengo.Table, createStatement string, _ *tengo.Sc
 		rawType, displayWidth := matches[1], matches[2]
 		unsigned, zerofill := (matches[3] != ""), (matches[4] != "")
 		if zerofill {
-			continue // non-default display width may be intentional with zerofill
+			// Non-default display width may be intentional with zerofill, but MySQL
+			// 8.0 deprecates zerofill entirely
+			if deprecated {
+				results = append(results, Note{
+					LineOffset: FindFirstLineOffset(columnNameRegexp(col.Name), createStatement),
+					Summary:    "Deprecated zerofill attribute detected",
+					Message: fmt.Sprintf(
+						"Column %s of table %s is using the zerofill attribute, which is deprecated as of MySQL 8.0.17 along with integer display widths. Consider padding values in the application instead.",
+						col.Name, table.Name,
+					),
+				})
+			}
+			continue
 		}
 		if rawType == "tinyint" && displayWidth == "1" {
 			continue // allow tinyint(1) since bool is an alias for this
@@ -55,16 +68,17 @@ func displayWidthChecker(table *tengo.Table, createStatement string, _ *tengo.Sc
 		}
 		defaultWidth := strconv.Itoa(defaultWidthInt)
 		if displayWidth != defaultWidth {
-			colWithSpace := fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(col.Name))
-			re := regexp.MustCompile(colWithSpace)
 			message := fmt.Sprintf(
 				"Column %s of table %s is using display width %s, but the default for %s%s is %s.\nInteger display widths do not control what range of values may be stored in a column. Typically they have no effect whatsoever. If in doubt, omit the width entirely, or use the default of %s(%s)%s.",
 				col.Name, table.Name, displayWidth,
 				rawType, matches[3], defaultWidth,
 				rawType, defaultWidth, matches[3],
 			)
+			if deprecated {
+				message += " Integer display widths are also deprecated as of MySQL 8.0.17, and are omitted from SHOW CREATE TABLE as of MySQL 8.0.19."
+			}
 			results = append(results, Note{
-				LineOffset: FindFirstLineOffset(re, createStatement),
+				LineOffset: FindFirstLineOffset(columnNameRegexp(col.Name), createStatement),
 				Summary:    "Non-default display width detected",
 				Message:    message,
 			})
@@ -72,3 +86,30 @@ func displayWidthChecker(table *tengo.Table, createStatement string, _ *tengo.Sc
 	}
 	return results
 }
+
+// columnNameRegexp returns a regular expression matching name as a whole
+// identifier.
+func columnNameRegexp(name string) *regexp.Regexp {
+	return regexp.MustCompile(identifierPattern(name, true))
+}
+
+// identBoundary matches a single character which cannot be part of an
+// unquoted identifier, or a backtick.
+const identBoundary = "[^\\pL\\pM\\pN_$`]"
+
+// identifierPattern returns a regular expression pattern matching name as an
+// identifier, either quoted with backticks or unquoted. Go's \b only considers
+// ASCII word characters, but MySQL permits many other characters in unquoted
+// identifiers, so explicit boundaries are used for the unquoted form instead.
+// If leadingBoundary is false, the unquoted form does not need to be preceded
+// by a boundary, for use after another pattern which already ensures this.
+func identifierPattern(name string, leadingBoundary bool) string {
+	quoted := regexp.QuoteMeta(tengo.EscapeIdentifier(name))
+	bare := regexp.QuoteMeta(name) + "(?:$|" + identBoundary + ")"
+	if leadingBoundary {
+		// Newlines are excluded from the leading boundary, so that a match never
+		// begins on the previous line
+		bare = "(?:^|" + strings.Replace(identBoundary, "]", "\\n]", 1) + ")" + bare
+	}
+	return "(?m)(?:" + quoted + "|" + bare + ")"
+}
@@ -0,0 +1,47 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(explicitNullabilityChecker),
+		Name:            "explicit-nullability",
+		Description:     "Flag column definitions that omit both NULL and NOT NULL",
+		DefaultSeverity: SeverityIgnore,
+	})
+}
+
+// reNullKeyword matches NULL, NOT NULL, or DEFAULT NULL, any of which indicate
+// the column definition explicitly specifies its nullability.
+var reNullKeyword = regexp.MustCompile(`(?i)\bnull\b`)
+
+func explicitNullabilityChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, _ Options) []Note {
+	results := make([]Note, 0)
+	defs := columnDefs(createStatement)
+	for _, col := range table.Columns {
+		def, ok := defs[strings.ToLower(col.Name)]
+		if !ok || col.GenerationExpr != "" || reNullKeyword.MatchString(def.Text) {
+			continue
+		}
+		nullability := "NULL"
+		if !col.Nullable {
+			nullability = "NOT NULL"
+		}
+		message := fmt.Sprintf(
+			"Column %s of table %s does not specify NULL or NOT NULL, and is %s by default. Explicitly stating nullability makes column definitions unambiguous to readers.",
+			col.Name, table.Name, nullability,
+		)
+		results = append(results, Note{
+			LineOffset: def.LineOffset,
+			Summary:    "Column nullability not explicit",
+			Message:    message,
+		})
+	}
+	return results
+}
@@ -0,0 +1,31 @@
+package linter
+
+import (
+	"fmt"
+
+	"github.com/skeema/skeema/fs"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     FileChecker(fileNameChecker),
+		Name:            "file-name",
+		Description:     "Flag *.sql file paths which cannot be checked out on some operating systems",
+		DefaultSeverity: SeverityWarning,
+	})
+}
+
+// fileNameChecker flags paths which are reserved device names on Windows, end
+// in a dot or space, or are too long for common filesystems or the Windows
+// path length limit. Files written by Skeema avoid most of these problems, but
+// files created manually, or dirs nested deeply within the repo, may not.
+func fileNameChecker(filePath string, opts Options) []Note {
+	var results []Note
+	for _, problem := range fs.PathProblems(filePath, opts.repoBase) {
+		results = append(results, Note{
+			Summary: "Problematic file path",
+			Message: fmt.Sprintf("This file may not be usable on all operating systems: %s. Rename or move the file to avoid problems on other systems, such as Windows workstations. Its name does not affect which object it defines.", problem),
+		})
+	}
+	return results
+}
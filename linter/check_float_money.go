@@ -0,0 +1,61 @@
+package linter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	rule := Rule{
+		CheckerFunc:     TableChecker(floatMoneyChecker),
+		Name:            "no-float-money",
+		Description:     "Flag FLOAT or DOUBLE columns with names matching --money-columns",
+		DefaultSeverity: SeverityIgnore,
+	}
+	rule.RelatedListOption(
+		"money-columns",
+		"*_amount",
+		"List of column name patterns (supporting * and ? wildcards) representing monetary values for --lint-no-float-money",
+		true, // must specify at least 1 pattern if --lint-no-float-money is "warning" or "error"
+	)
+	RegisterRule(rule)
+}
+
+func floatMoneyChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) []Note {
+	results := make([]Note, 0)
+	for _, col := range table.Columns {
+		if !strings.HasPrefix(col.TypeInDB, "float") && !strings.HasPrefix(col.TypeInDB, "double") {
+			continue
+		}
+		pattern := matchingPattern(col.Name, opts.AllowList("no-float-money"))
+		if pattern == "" {
+			continue
+		}
+		re := columnNameRegexp(col.Name)
+		message := fmt.Sprintf(
+			"Column %s of table %s is using type %s, but its name matches pattern %s for monetary values. Floating-point types cannot exactly represent most decimal amounts; use the decimal type instead.",
+			col.Name, table.Name, col.TypeInDB, pattern,
+		)
+		results = append(results, Note{
+			LineOffset: FindFirstLineOffset(re, createStatement),
+			Summary:    "Monetary column using floating point type",
+			Message:    message,
+		})
+	}
+	return results
+}
+
+// matchingPattern returns the first of patterns which matches name case-
+// insensitively, or an empty string if none match.
+func matchingPattern(name string, patterns []string) string {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), name); matched {
+			return pattern
+		}
+	}
+	return ""
+}
@@ -2,7 +2,6 @@ package linter
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	"github.com/skeema/tengo"
@@ -21,7 +20,7 @@ func hasFloatChecker(table *tengo.Table, createStatement string, _ *tengo.Schema
 	results := make([]Note, 0)
 	for _, col := range table.Columns {
 		if strings.Contains(col.TypeInDB, "float") || strings.Contains(col.TypeInDB, "double") {
-			re := regexp.MustCompile(fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(col.Name)))
+			re := columnNameRegexp(col.Name)
 			message := fmt.Sprintf(
 				"Column %s of table %s is using type %s. Floating-point types can only store approximate values. For use-cases requiring exact precision, such as monetary data, use the decimal type instead.",
 				col.Name, table.Name, col.TypeInDB,
@@ -2,7 +2,6 @@ package linter
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	"github.com/skeema/tengo"
@@ -21,7 +20,7 @@ func hasTimeChecker(table *tengo.Table, createStatement string, _ *tengo.Schema,
 	results := make([]Note, 0)
 	for _, col := range table.Columns {
 		if strings.Contains(col.TypeInDB, "time") {
-			re := regexp.MustCompile(fmt.Sprintf(`\b%s\b`, regexp.QuoteMeta(col.Name)))
+			re := columnNameRegexp(col.Name)
 			message := fmt.Sprintf(
 				"Column %s of table %s is using type %s. Temporal data types can be problematic when dealing with timezone conversions, daylight savings time transitions, and leap seconds. Some companies prefer to store time-related values using unsigned ints or unsigned bigints for this reason.",
 				col.Name, table.Name, col.TypeInDB,
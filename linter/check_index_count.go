@@ -0,0 +1,59 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableBinaryChecker(indexCountChecker),
+		Name:            "index-count",
+		Description:     "Flag tables with more than --max-indexes secondary indexes",
+		DefaultSeverity: SeverityIgnore,
+		RelatedOption:   mybase.StringOption("max-indexes", 0, "10", "Maximum number of secondary indexes per table for --lint-index-count"),
+		ConfigFunc:      RuleConfigFunc(indexCountConfig),
+	})
+}
+
+func indexCountConfig(config *mybase.Config) interface{} {
+	value, err := strconv.Atoi(config.Get("max-indexes"))
+	if err != nil || value < 0 {
+		return fmt.Errorf("Option max-indexes must be a non-negative integer; instead found %q", config.Get("max-indexes"))
+	}
+	return value
+}
+
+var reIndexDef = regexp.MustCompile(`(?i)^\s*(unique\s+|fulltext\s+|spatial\s+)?(key|index)\b`)
+
+func indexCountChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) *Note {
+	maxIndexes := opts.RuleConfig["index-count"].(int)
+	if len(table.SecondaryIndexes) <= maxIndexes {
+		return nil
+	}
+
+	// Point at the first index beyond the limit, if it can be located
+	var lineOffset, seen int
+	for n, line := range strings.Split(createStatement, "\n") {
+		if reIndexDef.MatchString(line) {
+			if seen++; seen > maxIndexes {
+				lineOffset = n
+				break
+			}
+		}
+	}
+	message := fmt.Sprintf(
+		"Table %s has %d secondary indexes, exceeding the limit of %d. Each index adds overhead to writes and consumes memory and storage.",
+		table.Name, len(table.SecondaryIndexes), maxIndexes,
+	)
+	return &Note{
+		LineOffset: lineOffset,
+		Summary:    "Too many indexes",
+		Message:    message,
+	}
+}
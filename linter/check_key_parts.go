@@ -0,0 +1,113 @@
+package linter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(keyPartsChecker),
+		Name:            "key-parts",
+		Description:     "Flag index key parts which the server stores differently than defined",
+		DefaultSeverity: SeverityIgnore,
+	})
+}
+
+// keyPartsChecker compares the key parts of each index definition in the
+// *.sql file to the index as stored by the server. The server may silently
+// change a key part's prefix length; for example, older versions clamp
+// prefixes of non-unique indexes to the maximum key length with only a
+// warning. Since diffs compare against the server's stored definition, such
+// adjustments would otherwise go unnoticed. Key parts declared as descending
+// are also flagged for flavors which parse but ignore DESC.
+func keyPartsChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) []Note {
+	results := make([]Note, 0)
+	ignoresDesc := opts.Flavor.Known() && !opts.Flavor.MySQLishMinVersion(8, 0) && !opts.Flavor.VendorMinVersion(tengo.VendorMariaDB, 10, 8)
+	matched := make(map[*tengo.Index]bool)
+	for _, def := range indexDefs(createStatement) {
+		idx := storedIndex(table, def, matched)
+		if idx == nil {
+			continue
+		}
+		matched[idx] = true
+		for n, part := range def.Parts {
+			if part.Descending && ignoresDesc {
+				results = append(results, Note{
+					LineOffset: def.LineOffset,
+					Summary:    "Descending key part ignored by server",
+					Message: fmt.Sprintf(
+						"Index %s of table %s declares %s as DESC, but %s does not support descending indexes: DESC is parsed but ignored, so the index is stored in ascending order. Descending indexes require MySQL 8.0+ or MariaDB 10.8+.",
+						idx.Name, table.Name, keyPartDescription(part, n), opts.Flavor,
+					),
+				})
+			}
+			if part.Column == "" || n >= len(idx.SubParts) || !strings.EqualFold(part.Column, idx.Columns[n].Name) {
+				continue
+			}
+			stored := int(idx.SubParts[n])
+			if stored == 0 || stored == part.PrefixLength {
+				// A requested prefix covering the entire column is stored as a
+				// full-column key part, which is functionally equivalent
+				continue
+			}
+			requested := "the full column"
+			if part.PrefixLength > 0 {
+				requested = fmt.Sprintf("a prefix length of %d", part.PrefixLength)
+			}
+			results = append(results, Note{
+				LineOffset: def.LineOffset,
+				Summary:    "Index prefix length clamped by server",
+				Message: fmt.Sprintf(
+					"Index %s of table %s requests %s for column %s, but the server stored a prefix length of %d. This typically means the requested length exceeded the maximum index key length for the table's row format and character set, and the server silently truncated it. Diffs are based on the stored prefix length, so specify %s(%d) explicitly to avoid confusion, or adjust the column or index so that the requested length is permitted.",
+					idx.Name, table.Name, requested, part.Column, stored, part.Column, stored,
+				),
+			})
+		}
+	}
+	return results
+}
+
+// storedIndex returns the index of table corresponding to the index definition
+// def, or nil if none is found. Indexes without an explicit name in the file
+// are matched by their column names, skipping any indexes already matched.
+func storedIndex(table *tengo.Table, def indexDef, matched map[*tengo.Index]bool) *tengo.Index {
+	if def.PrimaryKey {
+		return table.PrimaryKey
+	}
+	for _, idx := range table.SecondaryIndexes {
+		if def.Name != "" && strings.EqualFold(def.Name, idx.Name) {
+			return idx
+		}
+	}
+	if def.Name != "" {
+		return nil
+	}
+	for _, idx := range table.SecondaryIndexes {
+		if matched[idx] || len(idx.Columns) != len(def.Parts) {
+			continue
+		}
+		same := true
+		for n, part := range def.Parts {
+			if !strings.EqualFold(part.Column, idx.Columns[n].Name) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return idx
+		}
+	}
+	return nil
+}
+
+// keyPartDescription returns a description of the key part at position n, for
+// use in messages.
+func keyPartDescription(part keyPart, n int) string {
+	if part.Column == "" {
+		return fmt.Sprintf("expression key part %d", n+1)
+	}
+	return "column " + part.Column
+}
@@ -0,0 +1,69 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(nameCaseChecker),
+		Name:            "name-case",
+		Description:     "Flag table, column, and index names not following --name-case-style",
+		DefaultSeverity: SeverityIgnore,
+		RelatedOption:   mybase.StringOption("name-case-style", 0, "lower", `Identifier style required by --lint-name-case (valid values: "lower", "snake", "camel")`),
+		ConfigFunc:      RuleConfigFunc(nameCaseConfig),
+	})
+}
+
+// nameCaseStyles maps each valid name-case-style value to a regular expression
+// which identifiers must match.
+var nameCaseStyles = map[string]*regexp.Regexp{
+	"lower": regexp.MustCompile(`^[^A-Z]*$`),
+	"snake": regexp.MustCompile(`^[a-z0-9_]+$`),
+	"camel": regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+}
+
+func nameCaseConfig(config *mybase.Config) interface{} {
+	value, err := config.GetEnum("name-case-style", "lower", "snake", "camel")
+	if err != nil {
+		return err
+	}
+	return value
+}
+
+func nameCaseChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, opts Options) []Note {
+	style := opts.RuleConfig["name-case"].(string)
+	re := nameCaseStyles[style]
+	results := make([]Note, 0)
+	makeNote := func(kind, name string, lineOffset int) Note {
+		return Note{
+			LineOffset: lineOffset,
+			Summary:    "Identifier does not follow naming convention",
+			Message:    fmt.Sprintf("%s name %s of table %s does not follow name-case-style=%s.", kind, name, table.Name, style),
+		}
+	}
+	if !re.MatchString(table.Name) {
+		results = append(results, Note{
+			Summary: "Identifier does not follow naming convention",
+			Message: fmt.Sprintf("Table name %s does not follow name-case-style=%s.", table.Name, style),
+		})
+	}
+	defs := columnDefs(createStatement)
+	for _, col := range table.Columns {
+		if !re.MatchString(col.Name) {
+			results = append(results, makeNote("Column", col.Name, defs[strings.ToLower(col.Name)].LineOffset))
+		}
+	}
+	for _, idx := range table.SecondaryIndexes {
+		if !re.MatchString(idx.Name) {
+			reIdx := regexp.MustCompile(fmt.Sprintf("(?i)(key|index)\\s+%s", identifierPattern(idx.Name, false)))
+			results = append(results, makeNote("Index", idx.Name, FindFirstLineOffset(reIdx, createStatement)))
+		}
+	}
+	return results
+}
@@ -0,0 +1,118 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(typeAliasChecker),
+		Name:            "type-alias",
+		Description:     "Flag column definitions using an alias of a data type or attribute",
+		DefaultSeverity: SeverityIgnore,
+	})
+}
+
+// typeAliases maps column type aliases, lowercased and with single spaces, to
+// the canonical form that the server converts them to. Any display width,
+// precision, or length following the alias is kept by the server as-is.
+var typeAliases = map[string]string{
+	"serial":                     "bigint unsigned NOT NULL AUTO_INCREMENT UNIQUE",
+	"bool":                       "tinyint(1)",
+	"boolean":                    "tinyint(1)",
+	"integer":                    "int",
+	"int1":                       "tinyint",
+	"int2":                       "smallint",
+	"int3":                       "mediumint",
+	"middleint":                  "mediumint",
+	"int4":                       "int",
+	"int8":                       "bigint",
+	"dec":                        "decimal",
+	"fixed":                      "decimal",
+	"numeric":                    "decimal",
+	"float4":                     "float",
+	"float8":                     "double",
+	"real":                       "double",
+	"double precision":           "double",
+	"character":                  "char",
+	"char varying":               "varchar",
+	"character varying":          "varchar",
+	"national char":              "char CHARACTER SET utf8",
+	"national character":         "char CHARACTER SET utf8",
+	"nchar":                      "char CHARACTER SET utf8",
+	"national varchar":           "varchar CHARACTER SET utf8",
+	"national char varying":      "varchar CHARACTER SET utf8",
+	"national character varying": "varchar CHARACTER SET utf8",
+	"nvarchar":                   "varchar CHARACTER SET utf8",
+	"long varbinary":             "mediumblob",
+	"long varchar":               "mediumtext",
+	"long":                       "mediumtext",
+}
+
+// reTypeAlias matches a type alias at the start of a column definition's type.
+// Multi-word aliases must be listed before any alias that is a prefix of them.
+var reTypeAlias = regexp.MustCompile(`^(?i)(serial|bool(?:ean)?|integer|int[12348]|middleint|dec|fixed|numeric|float[48]|real|double\s+precision|national\s+char(?:acter)?(?:\s+varying)?|national\s+varchar|nvarchar|nchar|char(?:acter)?\s+varying|character|long\s+varbinary|long\s+varchar|long)\b`)
+
+// reSerialDefaultValue matches the SERIAL DEFAULT VALUE attribute alias.
+var reSerialDefaultValue = regexp.MustCompile(`(?i)\bserial\s+default\s+value\b`)
+
+// reBinaryCharSetType matches a textual type with a CHARACTER SET binary
+// clause, which the server converts to the equivalent binary type; for example
+// VARCHAR(10) CHARACTER SET binary becomes VARBINARY(10).
+var reBinaryCharSetType = regexp.MustCompile(`(?i)^((?:var)?char(?:acter)?|(?:tiny|medium|long)?text)\b.*?\b(?:char(?:acter)?\s+set|charset)\s+binary\b`)
+
+// canonicalType returns the canonical form of the supplied type alias, or an
+// empty string if alias is not a known alias.
+func canonicalType(alias string) string {
+	return typeAliases[strings.ToLower(strings.Join(strings.Fields(alias), " "))]
+}
+
+func typeAliasChecker(table *tengo.Table, createStatement string, _ *tengo.Schema, _ Options) []Note {
+	results := make([]Note, 0)
+	defs := columnDefs(createStatement)
+	for _, col := range table.Columns {
+		def, ok := defs[strings.ToLower(col.Name)]
+		if !ok {
+			continue
+		}
+		if matches := reTypeAlias.FindStringSubmatch(def.TypeText); matches != nil {
+			alias := strings.Join(strings.Fields(matches[1]), " ")
+			message := fmt.Sprintf(
+				"Column %s of table %s is defined using type alias %s, which the server converts to %s. Using the canonical type in the *.sql file avoids confusion between the file and SHOW CREATE TABLE; `skeema format` or `skeema lint` can rewrite this automatically.",
+				col.Name, table.Name, strings.ToUpper(alias), canonicalType(alias),
+			)
+			results = append(results, Note{
+				LineOffset: def.LineOffset,
+				Summary:    "Column type alias detected",
+				Message:    message,
+			})
+		}
+		if matches := reBinaryCharSetType.FindStringSubmatch(def.TypeText); matches != nil && col.CharSet == "" {
+			message := fmt.Sprintf(
+				"Column %s of table %s is defined using type %s with CHARACTER SET binary, which the server converts to %s. Using the equivalent binary type in the *.sql file avoids confusion between the file and SHOW CREATE TABLE; `skeema format` or `skeema lint` can rewrite this automatically.",
+				col.Name, table.Name, strings.ToUpper(matches[1]), col.TypeInDB,
+			)
+			results = append(results, Note{
+				LineOffset: def.LineOffset,
+				Summary:    "Column type alias detected",
+				Message:    message,
+			})
+		}
+		if reSerialDefaultValue.MatchString(def.Text) {
+			message := fmt.Sprintf(
+				"Column %s of table %s uses attribute alias SERIAL DEFAULT VALUE, which the server converts to NOT NULL AUTO_INCREMENT UNIQUE. Using the canonical attributes in the *.sql file avoids confusion between the file and SHOW CREATE TABLE; `skeema format` or `skeema lint` can rewrite this automatically.",
+				col.Name, table.Name,
+			)
+			results = append(results, Note{
+				LineOffset: def.LineOffset,
+				Summary:    "Column attribute alias detected",
+				Message:    message,
+			})
+		}
+	}
+	return results
+}
@@ -0,0 +1,49 @@
+package linter
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/skeema/skeema/util"
+	"github.com/skeema/tengo"
+)
+
+func init() {
+	RegisterRule(Rule{
+		CheckerFunc:     TableChecker(utf8mb3Checker),
+		Name:            "utf8mb3",
+		Description:     "Flag tables or columns using the utf8mb3 character set, also called utf8",
+		DefaultSeverity: SeverityIgnore,
+	})
+}
+
+// utf8mb3Checker flags any use of the three-byte utf8 character set, regardless
+// of which spelling the server uses for it. Unlike lint-deprecated, this is not
+// limited to flavors which deprecate utf8mb3, since teams migrating to utf8mb4
+// will want to find remaining usage before upgrading.
+func utf8mb3Checker(table *tengo.Table, createStatement string, _ *tengo.Schema, _ Options) []Note {
+	// If the table's default charset is utf8mb3, return a single Note without
+	// checking individual columns, as we don't want a bunch of redundant
+	// messages for columns using the table default charset.
+	if util.CanonicalCharSet(table.CharSet) == "utf8" {
+		re := regexp.MustCompile(fmt.Sprintf(`(?i)(default)?\s*(character\s+set|charset|collate)\s*=?\s*(%s|%s)`, table.CharSet, table.Collation))
+		return []Note{{
+			LineOffset: FindLastLineOffset(re, createStatement),
+			Summary:    "Table using utf8mb3 character set",
+			Message:    fmt.Sprintf("Table %s is using default character set %s. %s", table.Name, table.CharSet, utf8mb3Explanation),
+		}}
+	}
+	var results []Note
+	for _, col := range table.Columns {
+		if util.CanonicalCharSet(col.CharSet) == "utf8" {
+			results = append(results, Note{
+				LineOffset: FindFirstLineOffset(columnNameRegexp(col.Name), createStatement),
+				Summary:    "Column using utf8mb3 character set",
+				Message:    fmt.Sprintf("Column %s of table %s is using character set %s. %s", col.Name, table.Name, col.CharSet, utf8mb3Explanation),
+			})
+		}
+	}
+	return results
+}
+
+const utf8mb3Explanation = "The utf8mb3 character set, which is also called utf8, cannot store four-byte UTF-8 characters such as emoji, and is deprecated in MySQL 8.0. Use utf8mb4 instead."
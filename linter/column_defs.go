@@ -0,0 +1,296 @@
+package linter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// columnDef represents the original text of a single column definition within
+// a CREATE TABLE statement, as written in the filesystem. This is needed by
+// checkers which care about how a column was defined, rather than just its
+// resulting introspected form. For example, a column's nullability is the same
+// whether NULL is specified explicitly or omitted entirely.
+type columnDef struct {
+	Text       string // definition text with comments and quoted strings blanked out
+	TypeText   string // portion of Text following the column name
+	ExecText   string // like TypeText, but retaining the contents of executable comments such as /*!50606 ... */
+	LineOffset int    // line offset of the start of the definition
+}
+
+// reColumnDefName matches the column name at the start of a definition, with
+// optional backtick quoting.
+var reColumnDefName = regexp.MustCompile("^(?:`((?:[^`]|``)+)`|([^\\s`(]+))")
+
+// Keywords which begin a definition within a CREATE TABLE that is not a column.
+var nonColumnDefKeywords = map[string]bool{
+	"PRIMARY":    true,
+	"KEY":        true,
+	"INDEX":      true,
+	"UNIQUE":     true,
+	"FULLTEXT":   true,
+	"SPATIAL":    true,
+	"CONSTRAINT": true,
+	"FOREIGN":    true,
+	"CHECK":      true,
+	"PERIOD":     true,
+}
+
+// columnDefs parses the column definitions out of createStatement, returning a
+// map keyed by lowercased column name. The parser does not attempt to validate
+// the statement; it only splits the parenthesized body on top-level commas,
+// while correctly skipping over quoted strings, identifiers, and comments. If
+// createStatement is not a parseable CREATE TABLE, the result may be
+// empty.
+func columnDefs(createStatement string) map[string]columnDef {
+	defs := make(map[string]columnDef)
+	blanked := blankCommentsAndStrings(createStatement)
+	executable := blankCommentsAndStrings(unwrapExecutableComments(createStatement))
+	for _, span := range definitionSpans(blanked) {
+		addColumnDef(defs, createStatement, blanked, executable, span[0], span[1])
+	}
+	return defs
+}
+
+// reExecutableComment matches a comment whose contents are executed by some
+// servers, such as /*!50606 ... */ or MariaDB's /*M!100301 ... */. [1] is the
+// contents.
+var reExecutableComment = regexp.MustCompile(`(?s)/\*M?!\d*(.*?)\*/`)
+
+// unwrapExecutableComments returns a copy of s in which the delimiters and
+// version numbers of executable comments are replaced with spaces, leaving their
+// contents in place. The returned string is the same length as s.
+func unwrapExecutableComments(s string) string {
+	b := []byte(s)
+	for _, loc := range reExecutableComment.FindAllStringSubmatchIndex(s, -1) {
+		for _, span := range [][2]int{{loc[0], loc[2]}, {loc[3], loc[1]}} {
+			for n := span[0]; n < span[1]; n++ {
+				b[n] = ' '
+			}
+		}
+	}
+	return string(b)
+}
+
+// definitionSpans returns the [from, to) positions of each definition in the
+// parenthesized body of a CREATE TABLE, found by splitting on commas at depth
+// 1 and stopping at the closing paren. blanked must have had its comments and
+// quoted strings blanked out.
+func definitionSpans(blanked string) (spans [][2]int) {
+	start := strings.IndexByte(blanked, '(')
+	if start < 0 {
+		return nil
+	}
+	depth := 0
+	defStart := start + 1
+	for pos := start; pos < len(blanked); pos++ {
+		switch blanked[pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if (blanked[pos] == ',' && depth == 1) || depth == 0 {
+			spans = append(spans, [2]int{defStart, pos})
+			defStart = pos + 1
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	return spans
+}
+
+// addColumnDef adds the definition spanning [from, to) to defs, if it is a
+// column definition.
+func addColumnDef(defs map[string]columnDef, orig, blanked, executable string, from, to int) {
+	// Skip leading whitespace and comments, which are both blank in blanked.
+	// Quoted identifiers are also blank there, so names are parsed from orig.
+	from = skipBlank(orig, blanked, from, to)
+	if from == to {
+		return
+	}
+	matches := reColumnDefName.FindStringSubmatch(orig[from:to])
+	if matches == nil {
+		return
+	}
+	name := matches[2]
+	if matches[1] != "" {
+		name = strings.Replace(matches[1], "``", "`", -1)
+	} else if nonColumnDefKeywords[strings.ToUpper(name)] {
+		return
+	}
+	defs[strings.ToLower(name)] = columnDef{
+		Text:       strings.TrimSpace(blanked[from:to]),
+		TypeText:   strings.TrimSpace(blanked[from+len(matches[0]) : to]),
+		ExecText:   strings.TrimSpace(executable[from+len(matches[0]) : to]),
+		LineOffset: strings.Count(orig[:from], "\n"),
+	}
+}
+
+// blankCommentsAndStrings returns a copy of s in which the contents of
+// comments, quoted strings, and quoted identifiers are replaced with spaces
+// (preserving newlines), so that keyword searches and delimiter parsing won't
+// be affected by their contents. The returned string is the same length as s.
+func blankCommentsAndStrings(s string) string {
+	b := []byte(s)
+	for pos := 0; pos < len(b); pos++ {
+		var end int
+		switch {
+		case b[pos] == '\'' || b[pos] == '"' || b[pos] == '`':
+			end = closingQuote(b, pos)
+		case b[pos] == '#' || (b[pos] == '-' && pos+2 < len(b) && b[pos+1] == '-' && (b[pos+2] == ' ' || b[pos+2] == '\t')):
+			end = pos
+			for end < len(b) && b[end] != '\n' {
+				end++
+			}
+		case b[pos] == '/' && pos+1 < len(b) && b[pos+1] == '*':
+			if idx := strings.Index(s[pos+2:], "*/"); idx < 0 {
+				end = len(b)
+			} else {
+				end = pos + 2 + idx + 2
+			}
+		default:
+			continue
+		}
+		for n := pos; n < end; n++ {
+			if b[n] != '\n' {
+				b[n] = ' '
+			}
+		}
+		pos = end - 1
+	}
+	return string(b)
+}
+
+// closingQuote returns the position just after the quote which closes the
+// quoted string or identifier beginning at b[start].
+func closingQuote(b []byte, start int) int {
+	quote := b[start]
+	for pos := start + 1; pos < len(b); pos++ {
+		if b[pos] == '\\' && quote != '`' {
+			pos++
+		} else if b[pos] == quote {
+			if pos+1 < len(b) && b[pos+1] == quote {
+				pos++ // doubled quote is an escaped quote
+			} else {
+				return pos + 1
+			}
+		}
+	}
+	return len(b)
+}
+
+// indexDef represents the original text of a single index definition within a
+// CREATE TABLE statement, as written in the filesystem. This is needed by
+// checkers which compare what was requested to what the server actually
+// stored, since the server may silently adjust some index attributes.
+type indexDef struct {
+	Name       string // blank for a primary key, or if no name was specified
+	PrimaryKey bool
+	Parts      []keyPart
+	LineOffset int // line offset of the start of the definition
+}
+
+// keyPart represents a single part of an index definition.
+type keyPart struct {
+	Column       string // blank if the key part is an expression
+	PrefixLength int    // 0 if no prefix length was specified
+	Descending   bool
+}
+
+// identExpr matches an identifier with optional backtick quoting.
+const identExpr = "(?:`(?:[^`]|``)+`|[^\\s`(),]+)"
+
+// reIndexDefStart matches the portion of an index definition prior to its
+// parenthesized key parts. [1] is the constraint symbol, [2] is the index type
+// keywords, and [3] is the index name.
+var reIndexDefStart = regexp.MustCompile("(?is)^(?:CONSTRAINT(?:\\s+(" + identExpr + "))?\\s+)?(PRIMARY\\s+KEY|(?:UNIQUE|FULLTEXT|SPATIAL)(?:\\s+(?:KEY|INDEX))?|KEY|INDEX)(?:\\s+(" + identExpr + "))??(?:\\s+USING\\s+\\w+)?\\s*$")
+
+// reKeyPart matches a single column key part. [1] is the column name, [2] is
+// the prefix length, and [3] is the direction.
+var reKeyPart = regexp.MustCompile("(?is)^(" + identExpr + ")\\s*(?:\\(\\s*(\\d+)\\s*\\))?(?:\\s+(ASC|DESC))?\\s*$")
+
+var reTrailingDesc = regexp.MustCompile(`(?i)\bDESC\s*$`)
+
+// indexDefs parses the primary key, unique, and secondary index definitions out
+// of createStatement, in order of appearance. Foreign keys and check
+// constraints are not included. Like columnDefs, this does not attempt to
+// validate the statement.
+func indexDefs(createStatement string) []indexDef {
+	var defs []indexDef
+	blanked := blankCommentsAndStrings(createStatement)
+	for _, span := range definitionSpans(blanked) {
+		if def, ok := parseIndexDef(createStatement, blanked, span[0], span[1]); ok {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// parseIndexDef parses the definition spanning [from, to), returning false if
+// it is not an index definition.
+func parseIndexDef(orig, blanked string, from, to int) (def indexDef, ok bool) {
+	from = skipBlank(orig, blanked, from, to)
+	open := strings.IndexByte(blanked[from:to], '(')
+	if open < 0 {
+		return def, false
+	}
+	open += from
+	matches := reIndexDefStart.FindStringSubmatch(orig[from:open])
+	if matches == nil {
+		return def, false
+	}
+	def.LineOffset = strings.Count(orig[:from], "\n")
+	if strings.HasPrefix(strings.ToUpper(matches[2]), "PRIMARY") {
+		def.PrimaryKey = true
+	} else if matches[3] != "" {
+		def.Name = unquoteIdent(matches[3])
+	} else if matches[1] != "" {
+		def.Name = unquoteIdent(matches[1])
+	}
+
+	// Split the key parts on commas at depth 1
+	depth := 0
+	partStart := open + 1
+	for pos := open; pos < to; pos++ {
+		switch blanked[pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if (blanked[pos] == ',' && depth == 1) || depth == 0 {
+			partFrom := skipBlank(orig, blanked, partStart, pos)
+			part := keyPart{Descending: reTrailingDesc.MatchString(blanked[partFrom:pos])}
+			if m := reKeyPart.FindStringSubmatch(orig[partFrom:pos]); m != nil && blanked[partFrom] != '(' {
+				part.Column = unquoteIdent(m[1])
+				part.PrefixLength, _ = strconv.Atoi(m[2])
+			}
+			def.Parts = append(def.Parts, part)
+			partStart = pos + 1
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	return def, true
+}
+
+// skipBlank returns the position of the first character in [from, to) which is
+// not whitespace or part of a comment. Quoted identifiers are blank in blanked,
+// so their opening backtick is checked for in orig.
+func skipBlank(orig, blanked string, from, to int) int {
+	for from < to && strings.ContainsRune(" \t\r\n", rune(blanked[from])) && orig[from] != '`' {
+		from++
+	}
+	return from
+}
+
+// unquoteIdent strips backtick quoting from ident, if present.
+func unquoteIdent(ident string) string {
+	if len(ident) >= 2 && ident[0] == '`' && ident[len(ident)-1] == '`' {
+		return strings.Replace(ident[1:len(ident)-1], "``", "`", -1)
+	}
+	return ident
+}
@@ -0,0 +1,221 @@
+package linter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestColumnDefs(t *testing.T) {
+	createStatement := "CREATE TABLE `foo` ( -- comment, with (parens\n" +
+		"  `id` int unsigned NOT NULL,\n" +
+		"  name varchar(30) COMMENT 'null, (or not' , /* a comment, like this */\n" +
+		"  `weird``name` decimal(10,2) DEFAULT NULL,\n" +
+		"  # another comment\n" +
+		"  status enum('a,b','c') NOT NULL DEFAULT 'a,b',\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `name` (name),\n" +
+		"  CONSTRAINT fk FOREIGN KEY (id) REFERENCES bar (id)\n" +
+		") ENGINE=InnoDB COMMENT='not, a column'"
+	defs := columnDefs(createStatement)
+	expected := map[string]int{ // column name -> line offset
+		"id":         1,
+		"name":       2,
+		"weird`name": 3,
+		"status":     5,
+	}
+	if len(defs) != len(expected) {
+		t.Errorf("Expected %d column definitions, instead found %d: %+v", len(expected), len(defs), defs)
+	}
+	for name, lineOffset := range expected {
+		if def, ok := defs[name]; !ok {
+			t.Errorf("Expected column definition for %s, but it was not found", name)
+		} else if def.LineOffset != lineOffset {
+			t.Errorf("Expected column %s to have line offset %d, instead found %d", name, lineOffset, def.LineOffset)
+		}
+	}
+	if reNullKeyword.MatchString(defs["name"].Text) {
+		t.Errorf("Expected quoted strings to be blanked out in column definition, but found %q", defs["name"].Text)
+	}
+	if !reNullKeyword.MatchString(defs["weird`name"].Text) || !reDefaultKeyword.MatchString(defs["status"].Text) {
+		t.Errorf("Expected keywords to remain in column definitions, but found %+v", defs)
+	}
+
+	if defs := columnDefs("CREATE TABLE borked"); len(defs) != 0 {
+		t.Errorf("Expected no column definitions from statement lacking parens, instead found %+v", defs)
+	}
+}
+
+func TestMatchingPattern(t *testing.T) {
+	patterns := []string{"*_amount", "price?"}
+	cases := map[string]string{
+		"total_amount": "*_amount",
+		"Tax_AMOUNT":   "*_amount",
+		"amount":       "",
+		"price1":       "price?",
+		"price":        "",
+	}
+	for name, expected := range cases {
+		if actual := matchingPattern(name, patterns); actual != expected {
+			t.Errorf("Expected matchingPattern(%q) to return %q, instead found %q", name, expected, actual)
+		}
+	}
+}
+
+func TestTypeAliasChecker(t *testing.T) {
+	createStatement := "CREATE TABLE `aliases` (\n" +
+		"  `id` SERIAL,\n" +
+		"  flag BOOL NOT NULL,\n" +
+		"  `price` dec(10,2) NOT NULL,\n" +
+		"  name national  varchar(20) NOT NULL,\n" +
+		"  code character varying(10) NOT NULL,\n" +
+		"  body LONG,\n" +
+		"  seq int SERIAL DEFAULT VALUE,\n" +
+		"  amount decimal(10,2) NOT NULL,\n" +
+		"  `real` longtext COMMENT 'bool',\n" +
+		"  created datetime NOT NULL\n" +
+		") ENGINE=InnoDB"
+	names := []string{"id", "flag", "price", "name", "code", "body", "seq", "amount", "real", "created"}
+	table := &tengo.Table{Name: "aliases"}
+	for _, name := range names {
+		table.Columns = append(table.Columns, &tengo.Column{Name: name})
+	}
+	notes := typeAliasChecker(table, createStatement, nil, Options{})
+	expectedOffsets := []int{1, 2, 3, 4, 5, 6, 7}
+	if len(notes) != len(expectedOffsets) {
+		t.Fatalf("Expected %d notes, instead found %d: %+v", len(expectedOffsets), len(notes), notes)
+	}
+	for n, note := range notes {
+		if note.LineOffset != expectedOffsets[n] {
+			t.Errorf("Expected note %d to have line offset %d, instead found %d", n, expectedOffsets[n], note.LineOffset)
+		}
+	}
+	if !strings.Contains(notes[3].Message, "NATIONAL VARCHAR") || !strings.Contains(notes[3].Message, "varchar CHARACTER SET utf8") {
+		t.Errorf("Unexpected message: %s", notes[3].Message)
+	}
+	if notes[6].Summary != "Column attribute alias detected" {
+		t.Errorf("Unexpected summary for SERIAL DEFAULT VALUE: %s", notes[6].Summary)
+	}
+
+	cases := map[string]string{
+		"SERIAL":            "bigint unsigned NOT NULL AUTO_INCREMENT UNIQUE",
+		"Boolean":           "tinyint(1)",
+		"double  precision": "double",
+		"float8":            "double",
+		"NCHAR":             "char CHARACTER SET utf8",
+		"decimal":           "",
+	}
+	for alias, expected := range cases {
+		if actual := canonicalType(alias); actual != expected {
+			t.Errorf("Expected canonicalType(%q) to return %q, instead found %q", alias, expected, actual)
+		}
+	}
+
+	// Textual types with CHARACTER SET binary are converted to binary types, but
+	// other types with a binary character set or collation are not
+	createStatement = "CREATE TABLE `bin` (\n" +
+		"  `token` varchar(20) CHARACTER SET binary NOT NULL,\n" +
+		"  `body` TEXT charset binary,\n" +
+		"  `kind` enum('a','b') CHARACTER SET binary,\n" +
+		"  `name` varchar(20) BINARY COMMENT 'CHARACTER SET binary'\n" +
+		") ENGINE=InnoDB"
+	table = &tengo.Table{
+		Name: "bin",
+		Columns: []*tengo.Column{
+			{Name: "token", TypeInDB: "varbinary(20)"},
+			{Name: "body", TypeInDB: "blob"},
+			{Name: "kind", TypeInDB: "enum('a','b')", CharSet: "binary", Collation: "binary"},
+			{Name: "name", TypeInDB: "varchar(20)", CharSet: "utf8mb4", Collation: "utf8mb4_bin"},
+		},
+	}
+	notes = typeAliasChecker(table, createStatement, nil, Options{})
+	if len(notes) != 2 || notes[0].LineOffset != 1 || notes[1].LineOffset != 2 {
+		t.Fatalf("Unexpected notes for CHARACTER SET binary columns: %+v", notes)
+	}
+	if !strings.Contains(notes[0].Message, "VARCHAR with CHARACTER SET binary, which the server converts to varbinary(20)") {
+		t.Errorf("Unexpected message: %s", notes[0].Message)
+	}
+}
+
+func TestIndexDefs(t *testing.T) {
+	createStatement := "CREATE TABLE `foo` (\n" +
+		"  `id` int unsigned NOT NULL,\n" +
+		"  name varchar(300) NOT NULL,\n" +
+		"  `key` varchar(30) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  /* comment, KEY x (y) */ KEY `name` ( name (20) DESC, `key`),\n" +
+		"  UNIQUE (`key`(10)),\n" +
+		"  CONSTRAINT uk_name UNIQUE KEY USING BTREE (name(191) ASC),\n" +
+		"  INDEX expr ((lower(name)) DESC),\n" +
+		"  CONSTRAINT fk FOREIGN KEY (id) REFERENCES bar (id)\n" +
+		") ENGINE=InnoDB COMMENT='KEY fake (a)'"
+	expected := []indexDef{
+		{PrimaryKey: true, Parts: []keyPart{{Column: "id"}}, LineOffset: 4},
+		{Name: "name", Parts: []keyPart{{Column: "name", PrefixLength: 20, Descending: true}, {Column: "key"}}, LineOffset: 5},
+		{Parts: []keyPart{{Column: "key", PrefixLength: 10}}, LineOffset: 6},
+		{Name: "uk_name", Parts: []keyPart{{Column: "name", PrefixLength: 191}}, LineOffset: 7},
+		{Name: "expr", Parts: []keyPart{{Descending: true}}, LineOffset: 8},
+	}
+	if actual := indexDefs(createStatement); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Unexpected result from indexDefs:\nexpected %+v\nfound    %+v", expected, actual)
+	}
+}
+
+func TestKeyPartsChecker(t *testing.T) {
+	createStatement := "CREATE TABLE `parts` (\n" +
+		"  `id` int unsigned NOT NULL,\n" +
+		"  `name` varchar(1000) NOT NULL,\n" +
+		"  `code` varchar(20) NOT NULL,\n" +
+		"  PRIMARY KEY (`id`),\n" +
+		"  KEY `name` (`name`(1000)),\n" +
+		"  KEY (code(20) DESC),\n" +
+		"  KEY `name_code` (name(191), code DESC)\n" +
+		") ENGINE=InnoDB"
+	id := &tengo.Column{Name: "id"}
+	name := &tengo.Column{Name: "name"}
+	code := &tengo.Column{Name: "code"}
+	table := &tengo.Table{
+		Name:       "parts",
+		Columns:    []*tengo.Column{id, name, code},
+		PrimaryKey: &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{id}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{
+			{Name: "name", Columns: []*tengo.Column{name}, SubParts: []uint16{191}},
+			{Name: "code", Columns: []*tengo.Column{code}, SubParts: []uint16{0}}, // prefix covering whole column is dropped
+			{Name: "name_code", Columns: []*tengo.Column{name, code}, SubParts: []uint16{191, 0}},
+		},
+	}
+
+	// MySQL 8.0 supports DESC, so only the clamped prefix is flagged
+	notes := keyPartsChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL80})
+	if len(notes) != 1 || notes[0].LineOffset != 5 || notes[0].Summary != "Index prefix length clamped by server" {
+		t.Fatalf("Unexpected notes for MySQL 8.0: %+v", notes)
+	}
+	if !strings.Contains(notes[0].Message, "prefix length of 1000") || !strings.Contains(notes[0].Message, "prefix length of 191") {
+		t.Errorf("Unexpected message: %s", notes[0].Message)
+	}
+
+	// MySQL 5.7 ignores DESC
+	notes = keyPartsChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL57})
+	expectedOffsets := []int{5, 6, 7}
+	if len(notes) != len(expectedOffsets) {
+		t.Fatalf("Expected %d notes for MySQL 5.7, instead found %d: %+v", len(expectedOffsets), len(notes), notes)
+	}
+	for n, note := range notes {
+		if note.LineOffset != expectedOffsets[n] {
+			t.Errorf("Expected note %d to have line offset %d, instead found %d", n, expectedOffsets[n], note.LineOffset)
+		}
+	}
+	if notes[1].Summary != "Descending key part ignored by server" || !strings.Contains(notes[1].Message, "Index code ") {
+		t.Errorf("Unexpected note for unnamed DESC index: %+v", notes[1])
+	}
+
+	// A full-column key part which the server converted to a prefix is also
+	// flagged
+	table.SecondaryIndexes[2].SubParts[1] = 10
+	notes = keyPartsChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL80})
+	if len(notes) != 2 || !strings.Contains(notes[1].Message, "requests the full column") {
+		t.Errorf("Unexpected notes after full-column clamp: %+v", notes)
+	}
+}
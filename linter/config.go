@@ -40,7 +40,9 @@ type Options struct {
 	RuleSeverity map[string]Severity
 	RuleConfig   map[string]interface{}
 	IgnoreTable  *regexp.Regexp
+	Flavor       tengo.Flavor
 	onlyKeys     map[tengo.ObjectKey]bool // if map is non-nil, only format objects with true values
+	repoBase     string                   // base dir for checks of file paths; if empty, only file names are checked
 }
 
 // AllowList returns a slice of configured allowed values for the given rule.
@@ -83,7 +85,7 @@ func (opts *Options) Equals(other *Options) bool {
 	if !reflect.DeepEqual(opts.RuleConfig, other.RuleConfig) {
 		return false
 	}
-	if !reflect.DeepEqual(opts.onlyKeys, other.onlyKeys) {
+	if !reflect.DeepEqual(opts.onlyKeys, other.onlyKeys) || opts.Flavor != other.Flavor {
 		return false
 	}
 	if opts.IgnoreTable == nil || other.IgnoreTable == nil {
@@ -110,6 +112,8 @@ func OptionsForDir(dir *fs.Dir) (Options, error) {
 	opts := Options{
 		RuleSeverity: make(map[string]Severity),
 		RuleConfig:   make(map[string]interface{}),
+		Flavor:       tengo.NewFlavor(dir.Config.Get("flavor")),
+		repoBase:     dir.RepoBase(),
 	}
 
 	var err error
@@ -125,9 +129,11 @@ func OptionsForDir(dir *fs.Dir) (Options, error) {
 			opts.RuleSeverity[name] = SeverityIgnore
 			continue
 		}
-		val, err := dir.Config.GetEnum(r.optionName(), string(SeverityIgnore), string(SeverityWarning), string(SeverityError))
+		val, err := dir.Config.GetEnum(r.optionName(), string(SeverityIgnore), string(SeverityWarning), string(SeverityError), "warn")
 		if err != nil {
 			return Options{}, ConfigError{Dir: dir, err: err}
+		} else if val == "warn" { // permitted as shorthand for "warning"
+			val = string(SeverityWarning)
 		}
 		opts.RuleSeverity[name] = Severity(val)
 	}
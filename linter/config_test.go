@@ -54,6 +54,20 @@ func TestOptionsForDir(t *testing.T) {
 		}
 	}
 
+	// Confirm "warn" is accepted as shorthand for "warning", and "off" for
+	// "ignore"; also confirm configuration of rules with non-list related options
+	dir = getDir(t, "testdata/validcfg", "--lint-name-case=warn --name-case-style=snake --lint-index-count=error --max-indexes=4 --lint-charset=off")
+	if opts, err := OptionsForDir(dir); err != nil {
+		t.Errorf("Unexpected error from OptionsForDir: %s", err)
+	} else {
+		if opts.RuleSeverity["name-case"] != SeverityWarning || opts.RuleSeverity["index-count"] != SeverityError || opts.RuleSeverity["charset"] != SeverityIgnore {
+			t.Errorf("Unexpected RuleSeverity: %v", opts.RuleSeverity)
+		}
+		if opts.RuleConfig["name-case"] != "snake" || opts.RuleConfig["index-count"] != 4 {
+			t.Errorf("Unexpected RuleConfig: %v", opts.RuleConfig)
+		}
+	}
+
 	// Coverage for error conditions
 	badOptions := []string{
 		"--errors=made-up-problem",
@@ -64,6 +78,9 @@ func TestOptionsForDir(t *testing.T) {
 		"--allow-engine=''",
 		"--lint-engine=gentle-nudge",
 		"--allow-definer=''",
+		"--lint-no-float-money=error --money-columns=''",
+		"--lint-name-case=warning --name-case-style=shouting",
+		"--lint-index-count=warn --max-indexes=lots",
 	}
 	confirmError := func(cliArgs string) {
 		t.Helper()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
 )
@@ -47,9 +48,35 @@ func CheckSchema(wsSchema *workspace.Schema, opts Options) *Result {
 			}
 		}
 	}
+	checkFiles(wsSchema, opts, result)
 	return result
 }
 
+// checkFiles runs any FileChecker rules once per *.sql file, annotating the
+// first checked statement in each file.
+func checkFiles(wsSchema *workspace.Schema, opts Options, result *Result) {
+	firstStatements := make(map[string]*fs.Statement)
+	for key, stmt := range wsSchema.LogicalSchema.Creates {
+		if opts.shouldIgnore(key) || stmt.File == "" {
+			continue
+		}
+		if first := firstStatements[stmt.File]; first == nil || stmt.LineNo < first.LineNo {
+			firstStatements[stmt.File] = stmt
+		}
+	}
+	for ruleName, severity := range opts.RuleSeverity {
+		fc, ok := rulesByName[ruleName].CheckerFunc.(FileChecker)
+		if !ok || severity == SeverityIgnore {
+			continue
+		}
+		for filePath, stmt := range firstStatements {
+			for _, note := range fc(filePath, opts) {
+				result.Annotate(stmt, severity, ruleName, note)
+			}
+		}
+	}
+}
+
 // ObjectChecker values may be used to check for problems in database objects.
 type ObjectChecker interface {
 	CheckObject(object interface{}, createStatement string, schema *tengo.Schema, opts Options) []Note
@@ -83,6 +110,18 @@ func (tbc TableBinaryChecker) CheckObject(object interface{}, createStatement st
 	return nil
 }
 
+// FileChecker is a function that looks for problems in the path of a *.sql
+// file, rather than in the objects it defines. It is called once per file,
+// with any notes attached to the file's first statement.
+type FileChecker func(filePath string, opts Options) []Note
+
+// CheckObject satisfies the ObjectChecker interface. FileChecker functions do
+// not examine individual objects, so this always returns nil; instead,
+// CheckSchema calls FileChecker functions directly for each file.
+func (fc FileChecker) CheckObject(object interface{}, createStatement string, schema *tengo.Schema, opts Options) []Note {
+	return nil
+}
+
 // RoutineChecker is a function that looks for problems in a stored procedure
 // or function. Routine checks are always strictly binary; in other words, for
 // each routine, either a single note is found (non-nil return), or no note is
@@ -9,6 +9,7 @@ import (
 
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/testutil"
 	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 	"github.com/skeema/tengo"
@@ -23,22 +24,10 @@ func TestMain(m *testing.M) {
 }
 
 func TestIntegration(t *testing.T) {
-	images := tengo.SplitEnv("SKEEMA_TEST_IMAGES")
-	if len(images) == 0 {
-		fmt.Println("SKEEMA_TEST_IMAGES env var is not set, so integration tests will be skipped!")
-		fmt.Println("To run integration tests, you may set SKEEMA_TEST_IMAGES to a comma-separated")
-		fmt.Println("list of Docker images. Example:\n# SKEEMA_TEST_IMAGES=\"mysql:5.6,mysql:5.7\" go test")
-	}
-	manager, err := tengo.NewDockerClient(tengo.DockerClientOptions{})
-	if err != nil {
-		t.Errorf("Unable to create sandbox manager: %s", err)
-	}
-	suite := &IntegrationSuite{manager: manager}
-	tengo.RunSuite(suite, t, images)
+	testutil.RunSuite(t, &IntegrationSuite{})
 }
 
 type IntegrationSuite struct {
-	manager       *tengo.DockerClient
 	d             *tengo.DockerizedInstance
 	schema        *tengo.Schema
 	logicalSchema *fs.LogicalSchema
@@ -72,12 +61,7 @@ func (s IntegrationSuite) TestCheckSchema(t *testing.T) {
 }
 
 func (s *IntegrationSuite) Setup(backend string) (err error) {
-	s.d, err = s.manager.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
-		Name:              fmt.Sprintf("skeema-test-%s", strings.Replace(backend, ":", "-", -1)),
-		Image:             backend,
-		RootPassword:      "fakepw",
-		DefaultConnParams: "foreign_key_checks=0",
-	})
+	s.d, err = testutil.DockerInstance(backend, "foreign_key_checks=0")
 	return err
 }
 
@@ -170,9 +154,275 @@ func compareAnnotations(t *testing.T, expected []*Annotation, actualResult *Resu
 
 // forceRulesWarning sets all linter rules to SeverityWarning, regardless of
 // what they were previously set to. Useful when testing checkers that aren't
-// enabled by default.
+// enabled by default. The deprecated rule is left disabled, since its results
+// depend on the flavor of the test image; it is tested separately.
 func forceRulesWarning(opts Options) {
 	for key := range opts.RuleSeverity {
 		opts.RuleSeverity[key] = SeverityWarning
 	}
+	opts.RuleSeverity["deprecated"] = SeverityIgnore
+}
+
+func TestDisplayWidthCheckerFlavor(t *testing.T) {
+	createStatement := "CREATE TABLE `widths` (\n" +
+		"  `id` int(10) unsigned NOT NULL,\n" +
+		"  `badint` int(100) NOT NULL,\n" +
+		"  `padded` int(5) unsigned zerofill NOT NULL\n" +
+		") ENGINE=InnoDB"
+	table := &tengo.Table{
+		Name: "widths",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(10) unsigned"},
+			{Name: "badint", TypeInDB: "int(100)"},
+			{Name: "padded", TypeInDB: "int(5) unsigned zerofill"},
+		},
+	}
+
+	notes := displayWidthChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL57})
+	if len(notes) != 1 || notes[0].LineOffset != 2 || strings.Contains(notes[0].Message, "deprecated") {
+		t.Errorf("Unexpected notes for MySQL 5.7: %+v", notes)
+	}
+
+	notes = displayWidthChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL80})
+	if len(notes) != 2 {
+		t.Fatalf("Expected 2 notes for MySQL 8.0, instead found %d: %+v", len(notes), notes)
+	}
+	if notes[0].LineOffset != 2 || !strings.Contains(notes[0].Message, "deprecated") {
+		t.Errorf("Unexpected display width note for MySQL 8.0: %+v", notes[0])
+	}
+	if notes[1].LineOffset != 3 || notes[1].Summary != "Deprecated zerofill attribute detected" {
+		t.Errorf("Unexpected zerofill note for MySQL 8.0: %+v", notes[1])
+	}
+}
+
+func TestDeprecatedFeatureStatus(t *testing.T) {
+	features := make(map[string]deprecatedFeature, len(deprecatedFeatures))
+	for _, df := range deprecatedFeatures {
+		features[df.Name] = df
+	}
+	year2, zerofill, utf8mb3 := features["the YEAR(2) type"], features["the zerofill attribute"], features["the utf8mb3 character set (also called utf8)"]
+	cases := []struct {
+		feature    deprecatedFeature
+		flavor     tengo.Flavor
+		deprecated bool
+		removed    bool
+	}{
+		{year2, tengo.FlavorMySQL55, false, false},
+		{year2, tengo.FlavorMySQL56, true, false},
+		{year2, tengo.FlavorMySQL57, true, true},
+		{year2, tengo.FlavorPercona80, true, true},
+		{year2, tengo.FlavorMariaDB103, false, false},
+		{zerofill, tengo.FlavorMySQL57, false, false},
+		{zerofill, tengo.FlavorMySQL80, true, false},
+		{zerofill, tengo.NewFlavor("mysql:8.4"), true, false},
+		{utf8mb3, tengo.FlavorMySQL57, false, false},
+		{utf8mb3, tengo.FlavorMySQL80, true, false},
+		{utf8mb3, tengo.FlavorUnknown, false, false},
+	}
+	for _, c := range cases {
+		if deprecated, removed := c.feature.status(c.flavor); deprecated != c.deprecated || removed != c.removed {
+			t.Errorf("Expected status of %s in %s to be deprecated=%t removed=%t, instead found %t %t", c.feature.Name, c.flavor, c.deprecated, c.removed, deprecated, removed)
+		}
+	}
+	for _, df := range deprecatedFeatures {
+		if df.Name == "" || df.Replacement == "" || df.Detect == nil || tengo.ParseVersion(df.Deprecated)[0] == 0 {
+			t.Errorf("Feature %+v is missing required fields", df)
+		}
+	}
+}
+
+func TestDeprecatedChecker(t *testing.T) {
+	createStatement := "CREATE TABLE `legacy` (\n" +
+		"  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,\n" +
+		"  `flag` tinyint(1) NOT NULL,\n" +
+		"  `yr` year(2) NOT NULL,\n" +
+		"  `padded` int unsigned zerofill NOT NULL,\n" +
+		"  `price` float(7,2) unsigned NOT NULL,\n" +
+		"  `name` varchar(20) CHARACTER SET utf8 NOT NULL,\n" +
+		"  `notes` text COMMENT 'zerofill year(2)',\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB"
+	table := &tengo.Table{
+		Name: "legacy",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(10) unsigned", AutoIncrement: true},
+			{Name: "flag", TypeInDB: "tinyint(1)"},
+			{Name: "yr", TypeInDB: "year(2)"},
+			{Name: "padded", TypeInDB: "int(10) unsigned zerofill"},
+			{Name: "price", TypeInDB: "float(7,2) unsigned"},
+			{Name: "name", TypeInDB: "varchar(20)", CharSet: "utf8"},
+			{Name: "notes", TypeInDB: "text", CharSet: "latin1"},
+		},
+	}
+
+	if notes := deprecatedChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL55}); len(notes) != 0 {
+		t.Errorf("Expected no notes for MySQL 5.5, instead found %+v", notes)
+	}
+	notes := deprecatedChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL57})
+	if len(notes) != 1 || notes[0].LineOffset != 3 || notes[0].Summary != "Removed feature detected" {
+		t.Errorf("Unexpected notes for MySQL 5.7: %+v", notes)
+	}
+
+	// Expected line offsets for MySQL 8.0: id has a display width; yr is removed;
+	// padded uses zerofill; price has precision and unsigned; name uses utf8mb3
+	notes = deprecatedChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL80})
+	expectedOffsets := []int{1, 3, 4, 5, 5, 6}
+	if len(notes) != len(expectedOffsets) {
+		t.Fatalf("Expected %d notes for MySQL 8.0, instead found %d: %+v", len(expectedOffsets), len(notes), notes)
+	}
+	for n, note := range notes {
+		if note.LineOffset != expectedOffsets[n] {
+			t.Errorf("Expected note[%d] to have line offset %d, instead found %+v", n, expectedOffsets[n], note)
+		}
+	}
+	if !strings.Contains(notes[0].Message, "Column id") || !strings.Contains(notes[0].Message, "omit the display width") {
+		t.Errorf("Expected message to name column and replacement, instead found %q", notes[0].Message)
+	}
+
+	problems := RemovedFeatures(table, createStatement, tengo.FlavorMySQL80)
+	if len(problems) != 1 || !strings.Contains(problems[0], "`yr`") {
+		t.Errorf("Unexpected result from RemovedFeatures: %v", problems)
+	}
+	if problems := RemovedFeatures(table, createStatement, tengo.FlavorMySQL56); len(problems) != 0 {
+		t.Errorf("Expected no removed features for MySQL 5.6, instead found %v", problems)
+	}
+}
+
+func TestColumnAttributesChecker(t *testing.T) {
+	createStatement := "CREATE TABLE `events` (\n" +
+		"  `id` int unsigned NOT NULL /*!50606 STORAGE DISK */,\n" +
+		"  `kind` varchar(20) NOT NULL COLUMN_FORMAT DYNAMIC,\n" +
+		"  `payload` blob /*!50633 COLUMN_FORMAT COMPRESSED */,\n" +
+		"  `body` text /*M!100301 COMPRESSED*/,\n" +
+		"  `compressed` tinyint(1) NOT NULL COMMENT 'storage disk',\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=ndbcluster"
+	table := &tengo.Table{
+		Name: "events",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int(10) unsigned"},
+			{Name: "kind", TypeInDB: "varchar(20)"},
+			{Name: "payload", TypeInDB: "blob"},
+			{Name: "body", TypeInDB: "text"},
+			{Name: "compressed", TypeInDB: "tinyint(1)"},
+		},
+	}
+	cases := map[tengo.Flavor][]int{ // flavor => expected line offsets
+		tengo.FlavorUnknown:    {},
+		tengo.FlavorMySQL55:    {1, 2, 3, 4},
+		tengo.FlavorMySQL57:    {3, 4},
+		tengo.FlavorPercona57:  {4},
+		tengo.FlavorMariaDB102: {1, 2, 3, 4},
+		tengo.FlavorMariaDB103: {1, 2, 3},
+	}
+	for flavor, expectedOffsets := range cases {
+		notes := columnAttributesChecker(table, createStatement, nil, Options{Flavor: flavor})
+		if len(notes) != len(expectedOffsets) {
+			t.Errorf("Expected %d notes for %s, instead found %d: %+v", len(expectedOffsets), flavor, len(notes), notes)
+			continue
+		}
+		for n, note := range notes {
+			if note.LineOffset != expectedOffsets[n] {
+				t.Errorf("Expected note[%d] for %s to have line offset %d, instead found %+v", n, flavor, expectedOffsets[n], note)
+			}
+		}
+	}
+	notes := columnAttributesChecker(table, createStatement, nil, Options{Flavor: tengo.FlavorMySQL57})
+	if len(notes) != 2 || !strings.Contains(notes[0].Message, "COLUMN_FORMAT COMPRESSED attribute") || !strings.Contains(notes[1].Message, "Column body") {
+		t.Errorf("Unexpected notes for MySQL 5.7: %+v", notes)
+	}
+}
+
+func TestUtf8mb3Checker(t *testing.T) {
+	createStatement := "CREATE TABLE `spellings` (\n" +
+		"  `id` int unsigned NOT NULL,\n" +
+		"  `legacy` varchar(20) CHARACTER SET utf8 DEFAULT NULL,\n" +
+		"  `renamed` varchar(20) CHARACTER SET utf8mb3 COLLATE utf8mb3_bin DEFAULT NULL,\n" +
+		"  `modern` varchar(20) DEFAULT NULL\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4"
+	table := &tengo.Table{
+		Name:      "spellings",
+		CharSet:   "utf8mb4",
+		Collation: "utf8mb4_0900_ai_ci",
+		Columns: []*tengo.Column{
+			{Name: "id", TypeInDB: "int unsigned"},
+			{Name: "legacy", TypeInDB: "varchar(20)", CharSet: "utf8mb3", Collation: "utf8mb3_general_ci"},
+			{Name: "renamed", TypeInDB: "varchar(20)", CharSet: "utf8mb3", Collation: "utf8mb3_bin"},
+			{Name: "modern", TypeInDB: "varchar(20)", CharSet: "utf8mb4", Collation: "utf8mb4_0900_ai_ci"},
+		},
+	}
+	notes := utf8mb3Checker(table, createStatement, nil, Options{})
+	if len(notes) != 2 || notes[0].LineOffset != 2 || notes[1].LineOffset != 3 {
+		t.Errorf("Unexpected notes for column charsets: %+v", notes)
+	}
+
+	// A table default of utf8 results in a single note for the table
+	table.CharSet, table.Collation = "utf8", "utf8_general_ci"
+	createStatement = strings.Replace(createStatement, "CHARSET=utf8mb4", "CHARSET=utf8", 1)
+	notes = utf8mb3Checker(table, createStatement, nil, Options{})
+	if len(notes) != 1 || notes[0].LineOffset != 5 || !strings.Contains(notes[0].Message, "Table spellings") {
+		t.Errorf("Unexpected notes for table charset: %+v", notes)
+	}
+}
+
+func TestFileNameChecker(t *testing.T) {
+	opts := Options{repoBase: "/repo"}
+	if notes := fileNameChecker("/repo/product/users.sql", opts); len(notes) != 0 {
+		t.Errorf("Expected no notes for ordinary file name, instead found %+v", notes)
+	}
+	notes := fileNameChecker("/repo/product/con.sql", opts)
+	if len(notes) != 1 || !strings.Contains(notes[0].Message, "con") {
+		t.Errorf("Unexpected notes for reserved file name: %+v", notes)
+	}
+	notes = fileNameChecker("/repo/aux/trailing .sql", opts)
+	if len(notes) != 1 {
+		t.Errorf("Expected 1 note for reserved dir name, instead found %+v", notes)
+	}
+}
+
+func TestCharsetCheckerAlias(t *testing.T) {
+	createStatement := "CREATE TABLE `legacy` (\n" +
+		"  `name` varchar(20) CHARACTER SET utf8mb3 DEFAULT NULL\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb3"
+	table := &tengo.Table{
+		Name:      "legacy",
+		CharSet:   "utf8mb3",
+		Collation: "utf8mb3_general_ci",
+		Columns:   []*tengo.Column{{Name: "name", TypeInDB: "varchar(20)", CharSet: "utf8mb3", Collation: "utf8mb3_general_ci"}},
+	}
+	opts := Options{RuleConfig: map[string]interface{}{"charset": []string{"utf8mb4", "UTF8"}}}
+	if notes := charsetChecker(table, createStatement, nil, opts); len(notes) != 0 {
+		t.Errorf("Expected utf8mb3 to be permitted by allow-charset containing utf8, instead found notes %+v", notes)
+	}
+	opts.RuleConfig["charset"] = []string{"utf8mb4"}
+	notes := charsetChecker(table, createStatement, nil, opts)
+	if len(notes) != 1 || !strings.Contains(notes[0].Message, "use the utf8mb4 character set instead") {
+		t.Errorf("Unexpected notes with utf8mb3 not permitted: %+v", notes)
+	}
+}
+
+func TestColumnNameRegexp(t *testing.T) {
+	createStatement := "CREATE TABLE `order` (\n" +
+		"  `group` int NOT NULL,\n" +
+		"  naïve int NOT NULL,\n" +
+		"  `a``b` int NOT NULL,\n" +
+		"  `😀` int NOT NULL,\n" +
+		"  `my group` int NOT NULL,\n" +
+		"  $price float NOT NULL\n" +
+		")"
+	cases := map[string]int{
+		"group":    1,
+		"naïve":    2,
+		"a`b":      3,
+		"😀":        4,
+		"my group": 5,
+		"$price":   6,
+		"price":    0, // not a match, since $ is part of the identifier
+		"aïve":     0, // likewise for ï
+	}
+	for name, expected := range cases {
+		if actual := FindFirstLineOffset(columnNameRegexp(name), createStatement); actual != expected {
+			t.Errorf("Expected line offset %d for column %q, instead found %d", expected, name, actual)
+		}
+	}
 }
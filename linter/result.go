@@ -62,8 +62,13 @@ func (a *Annotation) Location() string {
 }
 
 // Log logs the annotation, with a log level based on the annotation's severity.
+// The name of the corresponding lint option is included, if the annotation was
+// generated by a rule.
 func (a *Annotation) Log() {
 	message := a.MessageWithLocation()
+	if a.RuleName != "" {
+		message = fmt.Sprintf("%s [lint-%s]", message, a.RuleName)
+	}
 	switch a.Severity {
 	case SeverityError:
 		log.Error(message)
@@ -133,6 +138,8 @@ func (r *Result) Annotate(stmt *fs.Statement, sev Severity, ruleName string, not
 
 var reSyntaxErrorLine = regexp.MustCompile(`(?s) the right syntax to use near '.*' at line (\d+)`)
 
+var reSpatialIndexLine = regexp.MustCompile(`(?i)\bSPATIAL\s+(?:KEY|INDEX)\b`)
+
 // AnnotateStatementErrors converts any supplied workspace.StatementError values
 // into annotations, unless the statement affects an object that the options
 // indicate should be ignored.
@@ -151,6 +158,9 @@ func (r *Result) AnnotateStatementErrors(statementErrors []*workspace.StatementE
 			if lineNumber, _ := strconv.Atoi(matches[1]); lineNumber > 0 {
 				note.LineOffset = lineNumber - 1 // convert from 1-based line number to 0-based offset
 			}
+		} else if strings.Contains(note.Message, "SPATIAL index must be NOT NULL") {
+			// Server does not indicate which index is the problem, so use the first one
+			note.LineOffset = FindFirstLineOffset(reSpatialIndexLine, stmtErr.Text)
 		}
 		r.Annotate(stmtErr.Statement, SeverityError, "", note)
 	}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/skeema/skeema/fs"
 	"github.com/skeema/skeema/workspace"
+	"github.com/skeema/tengo"
 )
 
 func TestFindFirstLineOffset(t *testing.T) {
@@ -142,3 +143,26 @@ func (s IntegrationSuite) TestResultAnnotateStatementErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestResultAnnotateSpatialError(t *testing.T) {
+	stmt := &fs.Statement{
+		File:       "geo.sql",
+		LineNo:     1,
+		Text:       "CREATE TABLE geo (\n  id int NOT NULL,\n  pt point,\n  PRIMARY KEY (id),\n  SPATIAL KEY pt (pt)\n);\n",
+		Type:       fs.StatementTypeCreate,
+		ObjectType: tengo.ObjectTypeTable,
+		ObjectName: "geo",
+	}
+	stmtErr := &workspace.StatementError{
+		Statement: stmt,
+		Err:       fmt.Errorf("Error executing DDL in workspace: Error 1252: All parts of a SPATIAL index must be NOT NULL"),
+	}
+	r := &Result{}
+	r.AnnotateStatementErrors([]*workspace.StatementError{stmtErr}, Options{})
+	if r.ErrorCount != 1 || len(r.Annotations) != 1 {
+		t.Fatalf("Expected 1 error annotation, instead found %+v", r.Annotations)
+	}
+	if a := r.Annotations[0]; a.LineOffset != 4 {
+		t.Errorf("Expected SPATIAL index error with line offset 4, instead found line offset %d", a.LineOffset)
+	}
+}
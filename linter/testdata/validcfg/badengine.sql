@@ -1,5 +1,5 @@
 CREATE TABLE badengine (
 	id int unsigned NOT NULL,
-	name varchar(30),
+	name varchar(30), /* annotations: explicit-nullability */
 	PRIMARY KEY (id)
 ) ENGINE=MEMORY DEFAULT CHARSET=utf8mb4 /* annotations:engine */;
@@ -2,29 +2,29 @@ CREATE TABLE `displaywidth` (
   `id` int(10) unsigned NOT NULL,
   
   -- confirm the defaults are correct in the checker
-  deftinyint tinyint,
-  defsmint smallint,
-  defmedint mediumint,
-  defint int,
-  defbigint bigint,
-  deftinyintu tinyint unsigned,
-  defsmintu smallint unsigned,
-  defmedintu mediumint unsigned,
-  defintu int unsigned,
-  defbigintu bigint unsigned,
+  deftinyint tinyint, /* annotations: explicit-nullability */
+  defsmint smallint, /* annotations: explicit-nullability */
+  defmedint mediumint, /* annotations: explicit-nullability */
+  defint int, /* annotations: explicit-nullability */
+  defbigint bigint, /* annotations: explicit-nullability */
+  deftinyintu tinyint unsigned, /* annotations: explicit-nullability */
+  defsmintu smallint unsigned, /* annotations: explicit-nullability */
+  defmedintu mediumint unsigned, /* annotations: explicit-nullability */
+  defintu int unsigned, /* annotations: explicit-nullability */
+  defbigintu bigint unsigned, /* annotations: explicit-nullability */
 
   -- annotations expected here
-  badtinyint tinyint(2),            /* annotations: display-width */
-  `badmedintu` mediumint(9) unsigned, /* annotations: display-width */
-  badint    int(100),                  /* annotations: display-width */
-  badbigintu bigint(15) unsigned,   /* annotations: display-width */
+  badtinyint tinyint(2),            /* annotations: display-width, explicit-nullability */
+  `badmedintu` mediumint(9) unsigned, /* annotations: display-width, explicit-nullability */
+  badint    int(100),                  /* annotations: display-width, explicit-nullability */
+  badbigintu bigint(15) unsigned,   /* annotations: display-width, explicit-nullability */
   
   -- confirm special-cases don't generate annotations
-  booly bool,
-  alsobool tinyint(1),
-  alsoboolu tinyint(1) unsigned,
-  padded int(5) zerofill,
-  paddedu int(4) unsigned zerofill,
+  booly bool, /* annotations: explicit-nullability, type-alias */
+  alsobool tinyint(1), /* annotations: explicit-nullability */
+  alsoboolu tinyint(1) unsigned, /* annotations: explicit-nullability */
+  padded int(5) zerofill, /* annotations: explicit-nullability */
+  paddedu int(4) unsigned zerofill, /* annotations: explicit-nullability */
   
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4
\ No newline at end of file
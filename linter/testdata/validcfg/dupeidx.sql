@@ -1,11 +1,11 @@
 CREATE TABLE `dupeidx` (
   `id` int(10) unsigned NOT NULL,
   `name` varchar(30) DEFAULT NULL,
-  `one` int,
-  `two` int,
-  `three` int,
-  `four` int,
-  `five` int,
+  `one` int, /* annotations: explicit-nullability */
+  `two` int, /* annotations: explicit-nullability */
+  `three` int, /* annotations: explicit-nullability */
+  `four` int, /* annotations: explicit-nullability */
+  `five` int, /* annotations: explicit-nullability */
   PRIMARY KEY (`id`),
   KEY onetwo (one, two), /* annotations: dupe-index */
   KEY onetwothree (one, two, three),
@@ -9,7 +9,7 @@ CREATE TABLE hasfk (
 CREATE TABLE hasfks (
   id int unsigned NOT NULL,
   customer_id int unsigned DEFAULT NULL,
-  product_id int unsigned,
+  product_id int unsigned, /* annotations: explicit-nullability */
   PRIMARY KEY (id),
   KEY customer (customer_id),
   KEY product (product_id),
@@ -1,8 +1,8 @@
 CREATE TABLE `hastime` (
   id int(10) unsigned NOT NULL,
-  time_is_bad time, /*  annotations: has-time */
-  date_is_fine date,
-  timestamp_is_bad timestamp, /*  annotations: has-time */
-  datetime_is_bad datetime, /*  annotations: has-time */
+  time_is_bad time, /*  annotations: has-time, explicit-nullability */
+  date_is_fine date, /* annotations: explicit-nullability */
+  timestamp_is_bad timestamp, /*  annotations: has-time, explicit-nullability */
+  datetime_is_bad datetime, /*  annotations: has-time, explicit-nullability, datetime-default */
   PRIMARY KEY (`id`)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
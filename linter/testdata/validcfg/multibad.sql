@@ -1,4 +1,4 @@
 CREATE TABLE multibad ( /* annotations:pk */
 	id int unsigned NOT NULL,
-	name varchar(30)
+	name varchar(30) /* annotations: explicit-nullability */
 ) ENGINE=MEMORY DEFAULT CHARSET=latin1 /* annotations: charset, engine */;
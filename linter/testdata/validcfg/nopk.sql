@@ -1,4 +1,4 @@
 CREATE TABLE nopk ( /* annotations:pk */
 	id int unsigned NOT NULL,
-	name varchar(30)
+	name varchar(30) /* annotations: explicit-nullability */
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,40 @@
+CREATE TABLE `payments` (
+  `id` int(10) unsigned NOT NULL,
+  `total_amount` double NOT NULL, /* annotations: has-float, no-float-money */
+  `tax_amount` decimal(10,2) NOT NULL,
+  `weight` float NOT NULL, /* annotations: has-float */
+  `createdAt` datetime NOT NULL DEFAULT '2000-01-01 00:00:00', /* annotations: has-time, name-case */
+  `paid_at` datetime NULL, /* annotations: has-time, datetime-default */
+  `note` varchar(100) COMMENT 'NOT NULL here is just a comment', /* annotations: explicit-nullability */
+  `code` character varying(10) NOT NULL, /* annotations: type-alias */
+  `discount` dec(10,2) NOT NULL, /* annotations: type-alias */
+  PRIMARY KEY (`id`),
+  KEY `byPaidAt` (`paid_at`) /* annotations: name-case */
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `manyindexes` (
+  `id` int(10) unsigned NOT NULL,
+  `c1` int(11) NOT NULL,
+  `c2` int(11) NOT NULL,
+  `c3` int(11) NOT NULL,
+  `c4` int(11) NOT NULL,
+  `c5` int(11) NOT NULL,
+  `c6` int(11) NOT NULL,
+  `c7` int(11) NOT NULL,
+  `c8` int(11) NOT NULL,
+  `c9` int(11) NOT NULL,
+  `c10` int(11) NOT NULL,
+  `c11` int(11) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `c1` (`c1`),
+  KEY `c2` (`c2`),
+  KEY `c3` (`c3`),
+  KEY `c4` (`c4`),
+  KEY `c5` (`c5`),
+  KEY `c6` (`c6`),
+  KEY `c7` (`c7`),
+  KEY `c8` (`c8`),
+  KEY `c9` (`c9`),
+  KEY `c10` (`c10`),
+  KEY `c11` (`c11`) /* annotations: index-count */
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
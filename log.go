@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
 	"github.com/mitchellh/go-wordwrap"
 	log "github.com/sirupsen/logrus"
@@ -64,6 +65,9 @@ func (f *customFormatter) Format(entry *log.Entry) ([]byte, error) {
 	}
 	levelText := fmt.Sprintf("[%s%s%s]%s ", startColor, levelName, endColor, spacing)
 	message := entry.Message
+	if prefix, ok := entry.Data["prefix"]; ok {
+		message = fmt.Sprintf("%s %s", prefix, message)
+	}
 	if f.isTerminal && f.width > 0 {
 		headerLen := 28 // length of line header, e.g. "2019-08-20 16:53:57 [INFO]  "
 		message = wordwrap.WrapString(message, uint(f.width-headerLen))
@@ -83,3 +87,68 @@ func countAndNoun(n int, singular, plural string) string {
 	}
 	return fmt.Sprintf("%d %s", n, plural)
 }
+
+// warningCollector is a logrus hook which records the message of every
+// warning-level log entry, for use with the strict option.
+type warningCollector struct {
+	mu       sync.Mutex
+	messages []string
+	stopped  bool
+}
+
+// processWarnings is the warningCollector installed by main, or nil if none.
+var processWarnings *warningCollector
+
+// collectWarnings installs and returns a warningCollector for the standard
+// logger. Warnings are still logged normally.
+func collectWarnings() *warningCollector {
+	wc := &warningCollector{}
+	log.AddHook(wc)
+	processWarnings = wc
+	return wc
+}
+
+// stop discards any warnings collected so far, and causes wc to ignore any
+// subsequent warnings. Long-running commands call this, since their warnings
+// pertain to many separate operations rather than a single exit code, and
+// would otherwise accumulate for the life of the process.
+func (wc *warningCollector) stop() {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	wc.messages = nil
+	wc.stopped = true
+}
+
+// Levels returns the log levels that wc records, satisfying logrus.Hook.
+func (wc *warningCollector) Levels() []log.Level {
+	return []log.Level{log.WarnLevel}
+}
+
+// Fire records entry's message, satisfying logrus.Hook.
+func (wc *warningCollector) Fire(entry *log.Entry) error {
+	message := entry.Message
+	if prefix, ok := entry.Data["prefix"]; ok {
+		message = fmt.Sprintf("%s %s", prefix, message)
+	}
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if !wc.stopped {
+		wc.messages = append(wc.messages, message)
+	}
+	return nil
+}
+
+// promote returns an error with a fatal exit code if any warnings were
+// collected, listing each of them, unless err already has a fatal exit code.
+// Otherwise err is returned unchanged.
+func (wc *warningCollector) promote(err error) error {
+	if ExitCode(err) >= CodeFatalError {
+		return err
+	}
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if len(wc.messages) == 0 {
+		return err
+	}
+	return NewExitValue(CodeFatalError, "Exiting with an error due to --strict, since %s logged:\n- %s", countAndNoun(len(wc.messages), "warning was", "warnings were"), strings.Join(wc.messages, "\n- "))
+}
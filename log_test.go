@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestWarningCollector(t *testing.T) {
+	wc := &warningCollector{}
+	logger := log.New()
+	logger.Out = ioutil.Discard
+	logger.AddHook(wc)
+
+	// No warnings: errors pass through as-is
+	logger.Info("just info")
+	logger.Error("an error")
+	partial := NewExitValue(CodePartialError, "")
+	if err := wc.promote(partial); err != partial {
+		t.Errorf("Expected error to be returned unchanged, instead found %v", err)
+	}
+	if err := wc.promote(nil); err != nil {
+		t.Errorf("Expected nil error to be returned unchanged, instead found %v", err)
+	}
+
+	logger.Warn("first warning")
+	logger.WithField("prefix", "[prod]").Warnf("second %s", "warning")
+	for _, input := range []error{nil, partial} {
+		err := wc.promote(input)
+		if ExitCode(err) != CodeFatalError {
+			t.Errorf("Expected exit code %d, instead found %d", CodeFatalError, ExitCode(err))
+		} else if msg := err.Error(); !strings.Contains(msg, "2 warnings were logged") || !strings.HasSuffix(msg, "\n- first warning\n- [prod] second warning") {
+			t.Errorf("Unexpected message: %q", msg)
+		}
+	}
+
+	// Fatal errors are returned as-is, even if warnings were logged
+	fatal := NewExitValue(CodeBadConfig, "bad config")
+	if err := wc.promote(fatal); err != fatal {
+		t.Errorf("Expected fatal error to be returned unchanged, instead found %v", err)
+	}
+
+	// Once stopped, previous and subsequent warnings are ignored
+	wc.stop()
+	logger.Warn("third warning")
+	if err := wc.promote(nil); err != nil {
+		t.Errorf("Expected stopped collector to return nil error unchanged, instead found %v", err)
+	}
+}
@@ -0,0 +1,451 @@
+// Package objdiff provides a structured representation of the differences
+// between two versions of a database object, for use by programs which embed
+// Skeema as a library. Each ObjectDiff wraps a tengo.ObjectDiff, exposing the
+// individual column, index, foreign key, and option changes that the diff
+// engine computed, along with their before and after values. All types are
+// suitable for marshaling to JSON.
+//
+// DDL generated via the methods in this package is obtained purely from the
+// wrapped tengo values. This is not always identical to the DDL that Skeema's
+// own commands generate, since those commands also normalize schemas before
+// diffing them, and adjust tengo's output afterwards. For example, `skeema
+// diff` ignores differences solely in spelling of the utf8mb3 alias or in
+// equivalent TIMESTAMP attributes; models table options and column attributes
+// which tengo does not handle, such as AUTOEXTEND_SIZE or SRID; resets table
+// options such as ENCRYPTION using valid values instead of DEFAULT; and may
+// split an ALTER TABLE into one statement per clause.
+package objdiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// Change describes the nature of an individual change within an ObjectDiff.
+type Change string
+
+// Constants enumerating valid Change values
+const (
+	ChangeAdd    Change = "add"
+	ChangeDrop   Change = "drop"
+	ChangeModify Change = "modify"
+	ChangeRename Change = "rename"
+)
+
+// ObjectDiff is a structured representation of a tengo.ObjectDiff. For ALTER
+// TABLE diffs, the Columns, Indexes, ForeignKeys, and Options fields each list
+// the individual alter clauses computed by the diff engine; entries within each
+// field are in the diff engine's order. For routine and database diffs, Options
+// lists each changed attribute. CREATE and DROP diffs of tables have no
+// individual entries, since the entire object is affected.
+type ObjectDiff struct {
+	DiffType    string            `json:"diff_type"`
+	ObjectType  tengo.ObjectType  `json:"object_type"`
+	ObjectName  string            `json:"object_name"`
+	Supported   bool              `json:"supported"` // false if some changes could not be expressed by the diff engine
+	Columns     []*ColumnDiff     `json:"columns,omitempty"`
+	Indexes     []*IndexDiff      `json:"indexes,omitempty"`
+	ForeignKeys []*ForeignKeyDiff `json:"foreign_keys,omitempty"`
+	Options     []*OptionDiff     `json:"options,omitempty"`
+	diff        tengo.ObjectDiff
+}
+
+// New returns a structured representation of diff. The flavor is used for
+// generating the definitions of columns, indexes, and foreign keys.
+func New(diff tengo.ObjectDiff, flavor tengo.Flavor) *ObjectDiff {
+	key := diff.ObjectKey()
+	od := &ObjectDiff{
+		DiffType:   diff.DiffType().String(),
+		ObjectType: key.Type,
+		ObjectName: key.Name,
+		Supported:  true,
+		diff:       diff,
+	}
+	switch d := diff.(type) {
+	case *tengo.TableDiff:
+		if d.Type == tengo.DiffTypeAlter {
+			clauses, supported := alterClauses(d)
+			od.Supported = supported
+			for _, clause := range clauses {
+				od.addClause(clause, d, flavor)
+			}
+		}
+	case *tengo.RoutineDiff:
+		if d.From != nil && d.To != nil {
+			od.Options = routineOptionDiffs(d.From, d.To)
+		}
+	case *tengo.DatabaseDiff:
+		if d.From != nil && d.To != nil {
+			od.Options = charSetOptionDiffs(d.From.CharSet, d.To.CharSet, d.From.Collation, d.To.Collation, nil)
+		}
+	}
+	return od
+}
+
+// FromSchemaDiff returns structured representations of all object diffs in
+// sd, in the same order as sd.ObjectDiffs().
+func FromSchemaDiff(sd *tengo.SchemaDiff, flavor tengo.Flavor) []*ObjectDiff {
+	objDiffs := sd.ObjectDiffs()
+	result := make([]*ObjectDiff, len(objDiffs))
+	for n, diff := range objDiffs {
+		result[n] = New(diff, flavor)
+	}
+	return result
+}
+
+// Statement returns the DDL statement for the diff, exactly as generated by
+// the wrapped tengo.ObjectDiff with the supplied mods. See the package
+// documentation for how this may differ from the output of `skeema diff`.
+func (od *ObjectDiff) Statement(mods tengo.StatementModifiers) (string, error) {
+	return od.diff.Statement(mods)
+}
+
+// Diff returns the wrapped tengo.ObjectDiff.
+func (od *ObjectDiff) Diff() tengo.ObjectDiff {
+	return od.diff
+}
+
+// alterClauses returns the clauses of td, along with whether td is supported.
+// Package tengo does not expose these directly, so they are recomputed from
+// td's tables. Since tengo.SchemaDiff splits an ALTER TABLE into separate
+// diffs for adding foreign keys, and separately generates diffs for dropping
+// partitions prior to a DROP TABLE, the recomputed clauses are narrowed to
+// whichever subset generates the same statement as td.
+func alterClauses(td *tengo.TableDiff) ([]tengo.TableAlterClause, bool) {
+	clauses, supported := td.From.Diff(td.To)
+	if !supported {
+		return clauses, false
+	}
+	mods := tengo.StatementModifiers{AllowUnsafe: true}
+	want, _ := td.Statement(mods)
+	inStatement := func(subset []tengo.TableAlterClause) bool {
+		for _, clause := range subset {
+			if clauseString := clause.Clause(mods); clauseString != "" && strings.Contains(want, clauseString) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var addFKs, others []tengo.TableAlterClause
+	for _, clause := range clauses {
+		if _, ok := clause.(tengo.AddForeignKey); ok {
+			addFKs = append(addFKs, clause)
+		} else {
+			others = append(others, clause)
+		}
+	}
+	if len(addFKs) > 0 && len(others) > 0 {
+		if hasFKs, hasOthers := inStatement(addFKs), inStatement(others); hasFKs && !hasOthers {
+			return addFKs, true
+		} else if hasOthers && !hasFKs {
+			return others, true
+		}
+	}
+
+	// Pre-drop partition removal: the statement consists of a single DROP
+	// PARTITION clause, absent from the recomputed clauses
+	if td.From.Partitioning != nil && td.To.Partitioning == nil {
+		prefix := fmt.Sprintf("%s DROP PARTITION ", td.From.AlterStatement())
+		if strings.HasPrefix(want, prefix) {
+			name := strings.TrimPrefix(want, prefix)
+			for _, p := range td.From.Partitioning.Partitions {
+				if p.Name == name {
+					return []tengo.TableAlterClause{tengo.ModifyPartitions{Drop: []*tengo.Partition{p}, ForDropTable: true}}, true
+				}
+			}
+		}
+	}
+	return clauses, true
+}
+
+// addClause adds a structured entry to od for the supplied clause from td.
+func (od *ObjectDiff) addClause(clause tengo.TableAlterClause, td *tengo.TableDiff, flavor tengo.Flavor) {
+	switch c := clause.(type) {
+	case tengo.AddColumn:
+		cd := &ColumnDiff{Change: ChangeAdd, Name: c.Column.Name, After: newColumn(c.Column, td.To, flavor), clause: c}
+		cd.setPosition(c.PositionFirst, c.PositionAfter)
+		od.Columns = append(od.Columns, cd)
+	case tengo.DropColumn:
+		od.Columns = append(od.Columns, &ColumnDiff{Change: ChangeDrop, Name: c.Column.Name, Before: newColumn(c.Column, td.From, flavor), clause: c})
+	case tengo.ModifyColumn:
+		cd := &ColumnDiff{Change: ChangeModify, Name: c.NewColumn.Name, Before: newColumn(c.OldColumn, td.From, flavor), After: newColumn(c.NewColumn, td.To, flavor), clause: c}
+		cd.setPosition(c.PositionFirst, c.PositionAfter)
+		od.Columns = append(od.Columns, cd)
+	case tengo.RenameColumn:
+		renamed := *c.OldColumn
+		renamed.Name = c.NewName
+		od.Columns = append(od.Columns, &ColumnDiff{Change: ChangeRename, Name: c.NewName, Before: newColumn(c.OldColumn, td.From, flavor), After: newColumn(&renamed, td.To, flavor), clause: c})
+	case tengo.AddIndex:
+		od.Indexes = append(od.Indexes, &IndexDiff{Change: ChangeAdd, Name: c.Index.Name, After: newIndex(c.Index, flavor), clause: c})
+	case tengo.DropIndex:
+		od.Indexes = append(od.Indexes, &IndexDiff{Change: ChangeDrop, Name: c.Index.Name, Before: newIndex(c.Index, flavor), clause: c})
+	case tengo.AddForeignKey:
+		od.ForeignKeys = append(od.ForeignKeys, &ForeignKeyDiff{Change: ChangeAdd, Name: c.ForeignKey.Name, After: newForeignKey(c.ForeignKey, flavor), clause: c})
+	case tengo.DropForeignKey:
+		od.ForeignKeys = append(od.ForeignKeys, &ForeignKeyDiff{Change: ChangeDrop, Name: c.ForeignKey.Name, Before: newForeignKey(c.ForeignKey, flavor), clause: c})
+	case tengo.ChangeAutoIncrement:
+		od.addOption("auto_increment", strconv.FormatUint(c.OldNextAutoIncrement, 10), strconv.FormatUint(c.NewNextAutoIncrement, 10), c)
+	case tengo.ChangeCharSet:
+		collation := c.Collation
+		if collation == "" {
+			collation = td.To.Collation
+		}
+		od.Options = append(od.Options, charSetOptionDiffs(td.From.CharSet, c.CharSet, td.From.Collation, collation, c)...)
+	case tengo.ChangeCreateOptions:
+		od.addOption("create_options", c.OldCreateOptions, c.NewCreateOptions, c)
+	case tengo.ChangeComment:
+		od.addOption("comment", td.From.Comment, c.NewComment, c)
+	case tengo.ChangeStorageEngine:
+		od.addOption("engine", td.From.Engine, c.NewStorageEngine, c)
+	case tengo.PartitionBy:
+		var before string
+		if td.From.Partitioning != nil {
+			before = td.From.Partitioning.Definition(flavor)
+		}
+		od.addOption("partitioning", before, c.Partitioning.Definition(flavor), c)
+	case tengo.RemovePartitioning:
+		var before string
+		if td.From.Partitioning != nil {
+			before = td.From.Partitioning.Definition(flavor)
+		}
+		od.addOption("partitioning", before, "", c)
+	case tengo.ModifyPartitions:
+		od.addOption("partitions", partitionNames(td.From.Partitioning), partitionNames(td.To.Partitioning), c)
+	default:
+		od.addOption(fmt.Sprintf("%T", clause), "", "", clause)
+	}
+}
+
+func (od *ObjectDiff) addOption(name, before, after string, clause tengo.TableAlterClause) {
+	od.Options = append(od.Options, &OptionDiff{Name: name, Before: before, After: after, clause: clause})
+}
+
+// ColumnDiff represents a single column being added, dropped, modified, or
+// renamed. Before is nil for added columns, and After is nil for dropped
+// columns. First and AfterColumn indicate the requested position of an added
+// or modified column, if any.
+type ColumnDiff struct {
+	Change      Change  `json:"change"`
+	Name        string  `json:"name"`
+	Before      *Column `json:"before,omitempty"`
+	After       *Column `json:"after,omitempty"`
+	First       bool    `json:"first,omitempty"`
+	AfterColumn string  `json:"after_column,omitempty"`
+	clause      tengo.TableAlterClause
+}
+
+// Clause returns the ALTER TABLE clause for this change, or an empty string
+// if mods cause this change to be omitted.
+func (cd *ColumnDiff) Clause(mods tengo.StatementModifiers) string {
+	return cd.clause.Clause(mods)
+}
+
+func (cd *ColumnDiff) setPosition(first bool, after *tengo.Column) {
+	cd.First = first
+	if after != nil {
+		cd.AfterColumn = after.Name
+	}
+}
+
+// Column is a snapshot of a column's attributes. Default is the column's
+// default value clause without the DEFAULT keyword (e.g. "NULL" or "'abc'"),
+// or an empty string if the column has no default.
+type Column struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Nullable       bool   `json:"nullable"`
+	Default        string `json:"default,omitempty"`
+	AutoIncrement  bool   `json:"auto_increment,omitempty"`
+	OnUpdate       string `json:"on_update,omitempty"`
+	GenerationExpr string `json:"generation_expr,omitempty"`
+	Virtual        bool   `json:"virtual,omitempty"`
+	CharSet        string `json:"charset,omitempty"`
+	Collation      string `json:"collation,omitempty"`
+	Comment        string `json:"comment,omitempty"`
+	Definition     string `json:"definition"`
+}
+
+func newColumn(col *tengo.Column, table *tengo.Table, flavor tengo.Flavor) *Column {
+	return &Column{
+		Name:           col.Name,
+		Type:           col.TypeInDB,
+		Nullable:       col.Nullable,
+		Default:        strings.TrimPrefix(col.Default.Clause(flavor, col), " DEFAULT "),
+		AutoIncrement:  col.AutoIncrement,
+		OnUpdate:       col.OnUpdate,
+		GenerationExpr: col.GenerationExpr,
+		Virtual:        col.Virtual,
+		CharSet:        col.CharSet,
+		Collation:      col.Collation,
+		Comment:        col.Comment,
+		Definition:     col.Definition(flavor, table),
+	}
+}
+
+// IndexDiff represents a single index being added or dropped. The diff engine
+// expresses a modified index as a drop followed by an add of the same name.
+type IndexDiff struct {
+	Change Change `json:"change"`
+	Name   string `json:"name"`
+	Before *Index `json:"before,omitempty"`
+	After  *Index `json:"after,omitempty"`
+	clause tengo.TableAlterClause
+}
+
+// Clause returns the ALTER TABLE clause for this change, or an empty string
+// if mods cause this change to be omitted.
+func (id *IndexDiff) Clause(mods tengo.StatementModifiers) string {
+	return id.clause.Clause(mods)
+}
+
+// Index is a snapshot of an index's attributes. Columns includes any prefix
+// length, e.g. "name(20)".
+type Index struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	PrimaryKey bool     `json:"primary_key,omitempty"`
+	Unique     bool     `json:"unique,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	Comment    string   `json:"comment,omitempty"`
+	Definition string   `json:"definition"`
+}
+
+func newIndex(idx *tengo.Index, flavor tengo.Flavor) *Index {
+	cols := make([]string, len(idx.Columns))
+	for n, col := range idx.Columns {
+		cols[n] = col.Name
+		if n < len(idx.SubParts) && idx.SubParts[n] > 0 {
+			cols[n] = fmt.Sprintf("%s(%d)", col.Name, idx.SubParts[n])
+		}
+	}
+	return &Index{
+		Name:       idx.Name,
+		Columns:    cols,
+		PrimaryKey: idx.PrimaryKey,
+		Unique:     idx.Unique,
+		Type:       idx.Type,
+		Comment:    idx.Comment,
+		Definition: idx.Definition(flavor),
+	}
+}
+
+// ForeignKeyDiff represents a single foreign key being added or dropped. The
+// diff engine expresses a modified foreign key as a drop followed by an add.
+type ForeignKeyDiff struct {
+	Change Change      `json:"change"`
+	Name   string      `json:"name"`
+	Before *ForeignKey `json:"before,omitempty"`
+	After  *ForeignKey `json:"after,omitempty"`
+	clause tengo.TableAlterClause
+}
+
+// Clause returns the ALTER TABLE clause for this change, or an empty string
+// if mods cause this change to be omitted.
+func (fkd *ForeignKeyDiff) Clause(mods tengo.StatementModifiers) string {
+	return fkd.clause.Clause(mods)
+}
+
+// ForeignKey is a snapshot of a foreign key's attributes. ReferencedSchema is
+// an empty string if the referenced table is in the same schema.
+type ForeignKey struct {
+	Name              string   `json:"name"`
+	Columns           []string `json:"columns"`
+	ReferencedSchema  string   `json:"referenced_schema,omitempty"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	UpdateRule        string   `json:"update_rule"`
+	DeleteRule        string   `json:"delete_rule"`
+	Definition        string   `json:"definition"`
+}
+
+func newForeignKey(fk *tengo.ForeignKey, flavor tengo.Flavor) *ForeignKey {
+	cols := make([]string, len(fk.Columns))
+	for n, col := range fk.Columns {
+		cols[n] = col.Name
+	}
+	return &ForeignKey{
+		Name:              fk.Name,
+		Columns:           cols,
+		ReferencedSchema:  fk.ReferencedSchemaName,
+		ReferencedTable:   fk.ReferencedTableName,
+		ReferencedColumns: fk.ReferencedColumnNames,
+		UpdateRule:        fk.UpdateRule,
+		DeleteRule:        fk.DeleteRule,
+		Definition:        fk.Definition(flavor),
+	}
+}
+
+// OptionDiff represents a change to a single object-level attribute, such as
+// a table's storage engine or a routine's body.
+type OptionDiff struct {
+	Name   string `json:"name"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+	clause tengo.TableAlterClause
+}
+
+// Clause returns the ALTER TABLE clause for this change, or an empty string
+// if mods cause this change to be omitted. Changes to routines and databases
+// do not have individual clauses, so an empty string is always returned for
+// them.
+func (optd *OptionDiff) Clause(mods tengo.StatementModifiers) string {
+	if optd.clause == nil {
+		return ""
+	}
+	return optd.clause.Clause(mods)
+}
+
+// charSetOptionDiffs returns OptionDiffs for a change in default character set
+// and/or collation, all with the supplied clause.
+func charSetOptionDiffs(fromCharSet, toCharSet, fromCollation, toCollation string, clause tengo.TableAlterClause) (result []*OptionDiff) {
+	if fromCharSet != toCharSet {
+		result = append(result, &OptionDiff{Name: "charset", Before: fromCharSet, After: toCharSet, clause: clause})
+		clause = nil // only include the clause once
+	}
+	if fromCollation != toCollation {
+		result = append(result, &OptionDiff{Name: "collation", Before: fromCollation, After: toCollation, clause: clause})
+	}
+	return result
+}
+
+// routineOptionDiffs returns OptionDiffs for each changed attribute between
+// two versions of a routine.
+func routineOptionDiffs(from, to *tengo.Routine) (result []*OptionDiff) {
+	attributes := []struct {
+		name     string
+		from, to string
+	}{
+		{"param_string", from.ParamString, to.ParamString},
+		{"return_type", from.ReturnDataType, to.ReturnDataType},
+		{"body", from.Body, to.Body},
+		{"definer", from.Definer, to.Definer},
+		{"comment", from.Comment, to.Comment},
+		{"deterministic", strconv.FormatBool(from.Deterministic), strconv.FormatBool(to.Deterministic)},
+		{"sql_data_access", from.SQLDataAccess, to.SQLDataAccess},
+		{"security_type", from.SecurityType, to.SecurityType},
+		{"sql_mode", from.SQLMode, to.SQLMode},
+		{"database_collation", from.DatabaseCollation, to.DatabaseCollation},
+	}
+	for _, attr := range attributes {
+		if attr.from != attr.to {
+			result = append(result, &OptionDiff{Name: attr.name, Before: attr.from, After: attr.to})
+		}
+	}
+	return result
+}
+
+// partitionNames returns a comma-separated list of partition names in tp.
+func partitionNames(tp *tengo.TablePartitioning) string {
+	if tp == nil {
+		return ""
+	}
+	names := make([]string, len(tp.Partitions))
+	for n, p := range tp.Partitions {
+		names[n] = p.Name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,192 @@
+package objdiff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+// usersTables returns two versions of a users table: the second widens the
+// email column, adds a column and a foreign key, changes an index, and changes
+// the table comment.
+func usersTables() (from, to *tengo.Table) {
+	fromID := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	fromEmail := &tengo.Column{Name: "email", TypeInDB: "varchar(100)", Nullable: true, Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", CollationIsDefault: true}
+	from = &tengo.Table{
+		Name:               "users",
+		Engine:             "InnoDB",
+		CharSet:            "utf8mb4",
+		Collation:          "utf8mb4_general_ci",
+		CollationIsDefault: true,
+		Columns:            []*tengo.Column{fromID, fromEmail},
+		PrimaryKey:         &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{fromID}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes:   []*tengo.Index{{Name: "email", Columns: []*tengo.Column{fromEmail}, SubParts: []uint16{0}}},
+	}
+
+	toID := &tengo.Column{Name: "id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	toEmail := &tengo.Column{Name: "email", TypeInDB: "varchar(255)", Nullable: true, Default: tengo.ColumnDefaultNull, CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", CollationIsDefault: true}
+	toOrgID := &tengo.Column{Name: "org_id", TypeInDB: "int(10) unsigned", Default: tengo.ColumnDefaultNull}
+	to = &tengo.Table{
+		Name:               "users",
+		Engine:             "InnoDB",
+		CharSet:            "utf8mb4",
+		Collation:          "utf8mb4_general_ci",
+		CollationIsDefault: true,
+		Comment:            "registered users",
+		Columns:            []*tengo.Column{toID, toOrgID, toEmail},
+		PrimaryKey:         &tengo.Index{Name: "PRIMARY", Columns: []*tengo.Column{toID}, SubParts: []uint16{0}, PrimaryKey: true},
+		SecondaryIndexes: []*tengo.Index{
+			{Name: "email", Columns: []*tengo.Column{toEmail}, SubParts: []uint16{20}, Unique: true},
+			{Name: "org_id", Columns: []*tengo.Column{toOrgID}, SubParts: []uint16{0}},
+		},
+		ForeignKeys: []*tengo.ForeignKey{{
+			Name:                  "users_org",
+			Columns:               []*tengo.Column{toOrgID},
+			ReferencedTableName:   "orgs",
+			ReferencedColumnNames: []string{"id"},
+			UpdateRule:            "RESTRICT",
+			DeleteRule:            "CASCADE",
+		}},
+	}
+	return from, to
+}
+
+func TestNewAlterTable(t *testing.T) {
+	from, to := usersTables()
+	td := tengo.NewAlterTable(from, to)
+	od := New(td, tengo.FlavorMySQL57)
+	if od.DiffType != "ALTER" || od.ObjectType != tengo.ObjectTypeTable || od.ObjectName != "users" || !od.Supported {
+		t.Errorf("Unexpected top-level fields in %+v", od)
+	}
+
+	if len(od.Columns) != 2 {
+		t.Fatalf("Expected 2 column diffs, instead found %d", len(od.Columns))
+	}
+	modify, add := od.Columns[0], od.Columns[1]
+	if modify.Change != ChangeModify || modify.Name != "email" || modify.Before.Type != "varchar(100)" || modify.After.Type != "varchar(255)" {
+		t.Errorf("Unexpected column diff %+v", modify)
+	}
+	if add.Change != ChangeAdd || add.Name != "org_id" || add.Before != nil || add.AfterColumn != "id" {
+		t.Errorf("Unexpected column diff %+v", add)
+	}
+
+	if len(od.Indexes) != 3 {
+		t.Fatalf("Expected 3 index diffs, instead found %d", len(od.Indexes))
+	}
+	if idx := od.Indexes[0]; idx.Change != ChangeDrop || idx.Name != "email" || idx.Before.Unique {
+		t.Errorf("Unexpected index diff %+v", idx)
+	}
+	if idx := od.Indexes[1]; idx.Change != ChangeAdd || !idx.After.Unique || idx.After.Columns[0] != "email(20)" {
+		t.Errorf("Unexpected index diff %+v", idx)
+	}
+
+	if len(od.ForeignKeys) != 1 || od.ForeignKeys[0].Change != ChangeAdd || od.ForeignKeys[0].After.ReferencedTable != "orgs" {
+		t.Errorf("Unexpected foreign key diffs %+v", od.ForeignKeys)
+	}
+	if len(od.Options) != 1 || od.Options[0].Name != "comment" || od.Options[0].Before != "" || od.Options[0].After != "registered users" {
+		t.Errorf("Unexpected option diffs %+v", od.Options)
+	}
+
+	// Generated DDL must match the wrapped diff with any mods, and each entry's
+	// clause must appear in that DDL
+	for _, mods := range []tengo.StatementModifiers{{}, {AllowUnsafe: true, LockClause: "none"}} {
+		expected, expectedErr := td.Statement(mods)
+		actual, actualErr := od.Statement(mods)
+		if actual != expected || actualErr != expectedErr {
+			t.Errorf("Statement mismatch: expected %q / %v, found %q / %v", expected, expectedErr, actual, actualErr)
+		}
+	}
+	mods := tengo.StatementModifiers{AllowUnsafe: true}
+	stmt, _ := od.Statement(mods)
+	clauses := []string{modify.Clause(mods), add.Clause(mods), od.ForeignKeys[0].Clause(mods), od.Options[0].Clause(mods)}
+	for _, idx := range od.Indexes {
+		clauses = append(clauses, idx.Clause(mods))
+	}
+	for _, clause := range clauses {
+		if clause == "" || !strings.Contains(stmt, clause) {
+			t.Errorf("Expected clause %q to be present in statement %q", clause, stmt)
+		}
+	}
+}
+
+func TestFromSchemaDiff(t *testing.T) {
+	from, to := usersTables()
+	fromSchema := &tengo.Schema{Name: "product", CharSet: "latin1", Collation: "latin1_swedish_ci", Tables: []*tengo.Table{from}}
+	toSchema := &tengo.Schema{Name: "product", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci", Tables: []*tengo.Table{to}}
+	sd := tengo.NewSchemaDiff(fromSchema, toSchema)
+	objDiffs := sd.ObjectDiffs()
+	ods := FromSchemaDiff(sd, tengo.FlavorMySQL57)
+	if len(ods) != len(objDiffs) || len(ods) != 3 {
+		t.Fatalf("Expected 3 ObjectDiffs, instead found %d", len(ods))
+	}
+	for n, od := range ods {
+		expected, _ := objDiffs[n].Statement(tengo.StatementModifiers{})
+		if actual, _ := od.Statement(tengo.StatementModifiers{}); actual != expected {
+			t.Errorf("Statement mismatch at position %d: expected %q, found %q", n, expected, actual)
+		}
+	}
+
+	// Database diff should report charset and collation changes
+	if opts := ods[0].Options; ods[0].ObjectType != tengo.ObjectTypeDatabase || len(opts) != 2 || opts[0].Name != "charset" || opts[1].After != "utf8mb4_general_ci" {
+		t.Errorf("Unexpected database ObjectDiff %+v", ods[0])
+	}
+
+	// SchemaDiff splits out adding the FK into a separate ALTER, so the
+	// structured diffs should be split accordingly
+	if len(ods[1].ForeignKeys) != 0 || len(ods[1].Columns) != 2 {
+		t.Errorf("Expected first table ObjectDiff to have columns but no foreign keys, instead found %+v", ods[1])
+	}
+	if len(ods[2].ForeignKeys) != 1 || len(ods[2].Columns) != 0 || len(ods[2].Indexes) != 0 || len(ods[2].Options) != 0 {
+		t.Errorf("Expected second table ObjectDiff to only have foreign keys, instead found %+v", ods[2])
+	}
+}
+
+func TestObjectDiffJSON(t *testing.T) {
+	from, to := usersTables()
+	od := New(tengo.NewAlterTable(from, to), tengo.FlavorMySQL57)
+	b, err := json.Marshal(od)
+	if err != nil {
+		t.Fatalf("Unexpected error from json.Marshal: %s", err)
+	}
+	var decoded struct {
+		DiffType string `json:"diff_type"`
+		Columns  []struct {
+			Change string `json:"change"`
+			Name   string `json:"name"`
+			Before *struct {
+				Type string `json:"type"`
+			} `json:"before"`
+			After struct {
+				Type       string `json:"type"`
+				Definition string `json:"definition"`
+			} `json:"after"`
+		} `json:"columns"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unexpected error from json.Unmarshal: %s", err)
+	}
+	if decoded.DiffType != "ALTER" || len(decoded.Columns) != 2 {
+		t.Fatalf("Unexpected JSON output: %s", b)
+	}
+	col := decoded.Columns[0]
+	if col.Change != "modify" || col.Name != "email" || col.Before == nil || col.Before.Type != "varchar(100)" || col.After.Type != "varchar(255)" || col.After.Definition == "" {
+		t.Errorf("Unexpected JSON output for column diff: %+v", col)
+	}
+	if decoded.Columns[1].Before != nil {
+		t.Errorf("Expected added column to omit before value, instead found %+v", decoded.Columns[1].Before)
+	}
+}
+
+func TestNewRoutineDiff(t *testing.T) {
+	from := &tengo.Routine{Name: "cleanup", Type: tengo.ObjectTypeProc, Body: "BEGIN END", Definer: "root@%", SQLMode: "STRICT_TRANS_TABLES"}
+	to := &tengo.Routine{Name: "cleanup", Type: tengo.ObjectTypeProc, Body: "BEGIN SELECT 1; END", Definer: "root@%", SQLMode: "STRICT_TRANS_TABLES", Comment: "hi"}
+	od := New(&tengo.RoutineDiff{From: from, To: to}, tengo.FlavorMySQL57)
+	if len(od.Options) != 2 || od.Options[0].Name != "body" || od.Options[1].Name != "comment" {
+		t.Errorf("Unexpected option diffs %+v", od.Options)
+	}
+	if od.Options[0].Clause(tengo.StatementModifiers{}) != "" {
+		t.Error("Expected routine option diffs to have no clause")
+	}
+}
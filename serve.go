@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
+)
+
+// server implements the HTTP API of `skeema serve`. Parsed directory trees are
+// cached by dir and environment, and discarded whenever the watcher detects a
+// change to any *.sql file or option file, or upon POST /refresh. Database
+// connection pools persist across requests, since util.NewInstance caches
+// instances for the life of the process. Introspection results are never
+// cached between requests, since these are needed to detect drift.
+//
+// A mybase.Config lazily rebuilds its caches upon lookup, so it is not safe
+// for concurrent use by multiple runs; nor is a parsed fs.Dir, whose option
+// values may be overridden during a run. For this reason, each cached tree has
+// its own Config, and runs using the same tree are serialized by the tree's
+// mutex. Runs using different trees may proceed concurrently.
+type server struct {
+	basePath  string
+	token     string
+	newConfig func(environment string) (*mybase.Config, error)
+
+	sync.Mutex
+	trees      map[serverTreeKey]*serverTree
+	generation int // incremented whenever trees are discarded
+}
+
+type serverTreeKey struct {
+	dir         string // slash-separated path relative to basePath
+	environment string
+}
+
+type serverTree struct {
+	sync.Mutex
+	dir    *fs.Dir
+	err    error
+	parsed bool
+}
+
+func newServer(basePath, token string, newConfig func(environment string) (*mybase.Config, error)) *server {
+	return &server{
+		basePath:  basePath,
+		token:     token,
+		newConfig: newConfig,
+		trees:     make(map[serverTreeKey]*serverTree),
+	}
+}
+
+// ServeHTTP authenticates the request and then routes it to the appropriate
+// endpoint.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("serve: %s %s", r.Method, r.URL)
+	if s.token != "" {
+		header := r.Header.Get("Authorization")
+		supplied := strings.TrimPrefix(header, "Bearer ")
+		if supplied == header || subtle.ConstantTimeCompare([]byte(supplied), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeServerError(w, http.StatusUnauthorized, errors.New("Missing or incorrect bearer token"))
+			return
+		}
+	}
+	routes := map[string]struct {
+		method  string
+		handler func(http.ResponseWriter, *http.Request)
+	}{
+		"/targets": {http.MethodGet, s.handleTargets},
+		"/diff":    {http.MethodGet, s.handleDiff},
+		"/refresh": {http.MethodPost, s.handleRefresh},
+	}
+	route, ok := routes[r.URL.Path]
+	if !ok {
+		writeServerError(w, http.StatusNotFound, fmt.Errorf("Unknown endpoint %s", r.URL.Path))
+		return
+	} else if r.Method != route.method {
+		w.Header().Set("Allow", route.method)
+		writeServerError(w, http.StatusMethodNotAllowed, fmt.Errorf("Endpoint %s requires method %s", r.URL.Path, route.method))
+		return
+	}
+	route.handler(w, r)
+}
+
+// serverTarget describes one target in the response of GET /targets.
+type serverTarget struct {
+	Dir      string `json:"dir"`
+	Instance string `json:"instance"`
+	Schema   string `json:"schema"`
+}
+
+func (s *server) handleTargets(w http.ResponseWriter, r *http.Request) {
+	key, err := serverTreeKeyForRequest(r)
+	if err != nil {
+		writeServerError(w, http.StatusBadRequest, err)
+		return
+	}
+	tree := s.acquire(key)
+	defer tree.Unlock()
+	if tree.err != nil {
+		writeServerError(w, http.StatusBadRequest, tree.err)
+		return
+	}
+	targets, skipCount := applier.TargetsForDir(tree.dir, 5)
+	response := struct {
+		Dir         string         `json:"dir"`
+		Environment string         `json:"environment"`
+		Targets     []serverTarget `json:"targets"`
+		SkipCount   int            `json:"skipped"`
+	}{
+		Dir:         key.dir,
+		Environment: key.environment,
+		Targets:     make([]serverTarget, 0, len(targets)),
+		SkipCount:   skipCount,
+	}
+	for _, t := range targets {
+		response.Targets = append(response.Targets, serverTarget{
+			Dir:      t.Dir.RelPath(),
+			Instance: t.Instance.String(),
+			Schema:   t.SchemaName,
+		})
+	}
+	writeServerJSON(w, http.StatusOK, response)
+}
+
+// serverDiff is the response of GET /diff.
+type serverDiff struct {
+	Dir         string                 `json:"dir"`
+	Environment string                 `json:"environment"`
+	Differences bool                   `json:"differences"`
+	Targets     []applier.TargetResult `json:"targets"`
+	DDL         string                 `json:"ddl"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+func (s *server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	key, err := serverTreeKeyForRequest(r)
+	if err != nil {
+		writeServerError(w, http.StatusBadRequest, err)
+		return
+	}
+	tree := s.acquire(key)
+	defer tree.Unlock()
+	if tree.err != nil {
+		writeServerError(w, http.StatusBadRequest, tree.err)
+		return
+	}
+	var ddl bytes.Buffer
+	printer := applier.NewPrinter(false)
+	printer.SetOutput(&ddl)
+	sum, err := pushDir(tree.dir, printer)
+	response := serverDiff{
+		Dir:         key.dir,
+		Environment: key.environment,
+		Differences: sum.Differences,
+		Targets:     sum.Targets,
+		DDL:         ddl.String(),
+	}
+	if response.Targets == nil {
+		response.Targets = []applier.TargetResult{}
+	}
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusInternalServerError
+		response.Error = err.Error()
+	} else if err := pushResultError(tree.dir, sum); err != nil && ExitCode(err) != CodeDifferencesFound {
+		response.Error = err.Error()
+	}
+	writeServerJSON(w, status, response)
+}
+
+func (s *server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	generation := s.invalidate("refresh requested")
+	writeServerJSON(w, http.StatusOK, map[string]int{"generation": generation})
+}
+
+// reServerEnvironment restricts environment names supplied in requests, so
+// that they cannot be mistaken for options or option file syntax.
+var reServerEnvironment = regexp.MustCompile(`^[\w][\w.-]*$`)
+
+// serverTreeKeyForRequest returns the key of the tree requested by the dir and
+// environment query params of r, or an error if either is invalid. The dir
+// must be a relative path within the server's base dir.
+func serverTreeKeyForRequest(r *http.Request) (serverTreeKey, error) {
+	query := r.URL.Query()
+	key := serverTreeKey{
+		dir:         path.Clean(filepath.ToSlash(query.Get("dir"))),
+		environment: query.Get("environment"),
+	}
+	if key.environment == "" {
+		key.environment = "production"
+	}
+	if path.IsAbs(key.dir) || filepath.IsAbs(query.Get("dir")) || key.dir == ".." || strings.HasPrefix(key.dir, "../") {
+		return key, fmt.Errorf("Invalid dir %q: must be a relative path within the served directory tree", query.Get("dir"))
+	} else if !reServerEnvironment.MatchString(key.environment) {
+		return key, fmt.Errorf("Invalid environment name %q", key.environment)
+	}
+	return key, nil
+}
+
+// acquire returns the tree for key, parsing it if it is not already cached.
+// The returned tree is locked, and the caller must unlock it when done.
+func (s *server) acquire(key serverTreeKey) *serverTree {
+	s.Lock()
+	tree := s.trees[key]
+	if tree == nil {
+		tree = &serverTree{}
+		s.trees[key] = tree
+	}
+	s.Unlock()
+
+	tree.Lock()
+	if !tree.parsed {
+		tree.dir, tree.err = s.parseTree(key)
+		tree.parsed = true
+	}
+	return tree
+}
+
+func (s *server) parseTree(key serverTreeKey) (*fs.Dir, error) {
+	cfg, err := s.newConfig(key.environment)
+	if err != nil {
+		return nil, err
+	}
+	dirPath := filepath.Join(s.basePath, filepath.FromSlash(key.dir))
+	if fi, err := os.Stat(dirPath); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("Dir %s does not exist within the served directory tree", key.dir)
+	}
+	dir, err := fs.ParseDir(dirPath, cfg)
+	if err != nil {
+		return nil, err
+	} else if !dir.Managed(5) {
+		return nil, fmt.Errorf("Dir %s is not inside a skeema-managed directory tree", key.dir)
+	}
+	log.Debugf("serve: parsed %s for environment %s", dir, key.environment)
+	return dir, nil
+}
+
+// invalidate discards all cached trees, as well as all decrypted contents of
+// encrypted option files, so that they are re-parsed upon next use, and
+// returns the new generation number. Runs already in progress are unaffected,
+// since they hold their own reference to the old tree.
+func (s *server) invalidate(reason string) int {
+	s.Lock()
+	defer s.Unlock()
+	s.trees = make(map[serverTreeKey]*serverTree)
+	util.ForgetDecryptedFiles()
+	s.generation++
+	log.Infof("Discarding parsed directory trees: %s", reason)
+	return s.generation
+}
+
+// watch polls the server's directory tree for changes to *.sql files, option
+// files, and .gitignore files, invalidating the cached trees whenever a change
+// is found. Polling is used instead of filesystem notifications, since those
+// require a watch per subdirectory and are unreliable on network filesystems;
+// the cost is one walk of the tree per interval. It returns a function which
+// stops watching.
+func (s *server) watch(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	prev := s.treeFingerprint()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if current := s.treeFingerprint(); current != prev {
+					prev = current
+					s.invalidate("files changed in " + s.basePath)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// treeFingerprint returns a hash of the path, size, and modification time of
+// every file in the server's base dir that affects parsing, as well as of any
+// option files in the base dir's parents.
+func (s *server) treeFingerprint() uint64 {
+	h := fnv.New64a()
+	record := func(p string, fi os.FileInfo) {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", p, fi.Size(), fi.ModTime().UnixNano())
+	}
+	filepath.Walk(s.basePath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := fi.Name()
+		if fi.IsDir() {
+			if p != s.basePath && fs.SkipSubdir(filepath.Dir(p), name, nil) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(name, ".sql") || name == ".skeema" || name == ".skeema.enc" || name == ".gitignore" {
+			record(p, fi)
+		}
+		return nil
+	})
+	for parent := filepath.Dir(s.basePath); ; parent = filepath.Dir(parent) {
+		if fi, err := os.Stat(filepath.Join(parent, ".skeema")); err == nil {
+			record(filepath.Join(parent, ".skeema"), fi)
+		}
+		if parent == filepath.Dir(parent) {
+			break
+		}
+	}
+	return h.Sum64()
+}
+
+func writeServerJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		log.Warnf("serve: unable to write response: %s", err)
+	}
+}
+
+func writeServerError(w http.ResponseWriter, status int, err error) {
+	writeServerJSON(w, status, map[string]string{"error": err.Error()})
+}
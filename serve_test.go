@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+// newTestServer returns a server for a scratch dir tree whose host cannot be
+// connected to, along with a function which performs a request against it.
+func newTestServer(t *testing.T, token string) (*server, func(method, url, token string) (int, map[string]interface{})) {
+	t.Helper()
+	base := "testdata/.scratch/serve"
+	fs.WriteTestFile(t, base+"/mydb/.skeema", "host=127.0.0.1\nport=1\n")
+	fs.WriteTestFile(t, base+"/mydb/product/.skeema", "schema=product\n")
+	fs.WriteTestFile(t, base+"/mydb/product/users.sql", "CREATE TABLE users (id int unsigned NOT NULL, PRIMARY KEY (id));\n")
+	fs.WriteTestFile(t, base+"/mydb/analytics/.skeema", "schema=analytics\n")
+	cfg := mybase.ParseFakeCLI(t, CommandSuite, "skeema serve --connect-options='timeout=10ms' --dir="+base)
+	srv := newServer(base, token, diffConfigFactory(cfg))
+	do := func(method, url, token string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(method, url, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("Response to %s %s is not valid JSON: %s\n%s", method, url, err, rec.Body.String())
+		}
+		return rec.Code, body
+	}
+	return srv, do
+}
+
+func TestServerRouting(t *testing.T) {
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	_, do := newTestServer(t, "secret")
+
+	if code, _ := do("GET", "/targets", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without token, instead found %d", http.StatusUnauthorized, code)
+	}
+	if code, _ := do("GET", "/targets", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d with wrong token, instead found %d", http.StatusUnauthorized, code)
+	}
+	if code, _ := do("GET", "/nope", "secret"); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown endpoint, instead found %d", http.StatusNotFound, code)
+	}
+	if code, _ := do("GET", "/refresh", "secret"); code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for wrong method, instead found %d", http.StatusMethodNotAllowed, code)
+	}
+	badParams := []string{"dir=../other", "dir=/etc", "dir=mydb/../../other", "dir=nonexistent", "environment=-x", "environment=[prod]"}
+	for _, params := range badParams {
+		if code, body := do("GET", "/diff?"+params, "secret"); code != http.StatusBadRequest || body["error"] == "" {
+			t.Errorf("Expected status %d with error for params %s, instead found %d: %v", http.StatusBadRequest, params, code, body)
+		}
+	}
+
+	// The host cannot be connected to, so both targets are skipped
+	code, body := do("GET", "/targets?dir=mydb&environment=production", "secret")
+	if code != http.StatusOK || body["skipped"] != float64(2) || body["dir"] != "mydb" {
+		t.Errorf("Unexpected response from /targets: %d %v", code, body)
+	}
+	if code, body := do("POST", "/refresh", "secret"); code != http.StatusOK || body["generation"] != float64(1) {
+		t.Errorf("Unexpected response from /refresh: %d %v", code, body)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	srv, do := newTestServer(t, "")
+	if code, _ := do("GET", "/targets?dir=mydb", ""); code != http.StatusOK {
+		t.Fatalf("Unexpected status from /targets: %d", code)
+	}
+	stop := srv.watch(5 * time.Millisecond)
+	defer stop()
+
+	// Modifying a *.sql file should cause cached trees to be discarded
+	fs.WriteTestFile(t, "testdata/.scratch/serve/mydb/product/posts.sql", "CREATE TABLE posts (id int unsigned NOT NULL, PRIMARY KEY (id));\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		srv.Lock()
+		generation, cached := srv.generation, len(srv.trees)
+		srv.Unlock()
+		if generation > 0 && cached == 0 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("Trees not invalidated after file change: generation=%d, cached=%d", generation, cached)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServerRefreshEncrypted(t *testing.T) {
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	srv, do := newTestServer(t, "")
+	fs.WriteTestFile(t, "testdata/.scratch/serve/mydb/.skeema", "decrypt-command=cat\nhost=127.0.0.1\nport=1\n")
+	fs.WriteTestFile(t, "testdata/.scratch/serve/mydb/.skeema.enc", "password=first\n")
+	assertPassword := func(expected string) {
+		t.Helper()
+		tree := srv.acquire(serverTreeKey{dir: "mydb", environment: "production"})
+		defer tree.Unlock()
+		if tree.err != nil {
+			t.Fatalf("Unexpected error parsing tree: %s", tree.err)
+		} else if actual := tree.dir.Config.Get("password"); actual != expected {
+			t.Errorf("Expected password %q, instead found %q", expected, actual)
+		}
+	}
+	assertPassword("first")
+
+	// Decrypted contents should be discarded upon refresh
+	fs.WriteTestFile(t, "testdata/.scratch/serve/mydb/.skeema.enc", "password=second\n")
+	if code, _ := do("POST", "/refresh", ""); code != http.StatusOK {
+		t.Fatalf("Unexpected status from /refresh: %d", code)
+	}
+	assertPassword("second")
+}
+
+// TestServerConcurrency issues overlapping requests for the same and different
+// trees while files change and refreshes occur. It is primarily useful when
+// run with the race detector enabled, via `go test -race`.
+func TestServerConcurrency(t *testing.T) {
+	defer fs.RemoveTestDirectory(t, "testdata/.scratch")
+	srv, do := newTestServer(t, "")
+	stop := srv.watch(time.Millisecond)
+	defer stop()
+
+	var wg sync.WaitGroup
+	urls := []string{
+		"/targets?dir=mydb",
+		"/targets?dir=mydb/product&environment=staging",
+		"/diff?dir=mydb/product",
+		"/diff?dir=mydb",
+		"/diff?dir=mydb/analytics&environment=staging",
+	}
+	for n := 0; n < 20; n++ {
+		url := urls[n%len(urls)]
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			code, body := do("GET", url, "")
+			if code != http.StatusOK {
+				t.Errorf("Unexpected response from %s: %d %v", url, code, body)
+			} else if strings.HasPrefix(url, "/diff") && body["error"] == nil {
+				// Every target is skipped, since the host cannot be connected to
+				t.Errorf("Unexpected response from %s: %d %v", url, code, body)
+			}
+			if n%4 == 0 {
+				do("POST", "/refresh", "")
+			}
+		}(n)
+	}
+	for n := 0; n < 5; n++ {
+		fs.WriteTestFile(t, "testdata/.scratch/serve/mydb/product/users.sql", strings.Repeat("-- comment\n", n)+"CREATE TABLE users (id int unsigned NOT NULL, PRIMARY KEY (id));\n")
+		time.Sleep(2 * time.Millisecond)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/skeema/fs"
+)
+
+// gitChangesSince returns the changes to the repo containing dir since the git
+// revision in the since option, or nil if this option is not set or is not
+// defined by the current command. Each changed option file is logged as a
+// warning, since such changes prevent narrowing of the affected dirs.
+func gitChangesSince(dir *fs.Dir) (*fs.GitChanges, error) {
+	if dir.Config.FindOption("since") == nil || dir.Config.Get("since") == "" {
+		return nil, nil
+	}
+	ref := dir.Config.Get("since")
+	changes, err := fs.ChangesSince(dir.Path, ref)
+	if err != nil {
+		return nil, NewExitValue(CodeBadUsage, err.Error())
+	}
+	log.Infof("Narrowing to objects in %s changed since %s", countAndNoun(len(changes.SQLFiles), "*.sql file", "*.sql files"), ref)
+	for _, optionFile := range changes.OptionFiles {
+		log.Warnf("Option file %s changed since %s; all objects in its directory and subdirectories will be processed", optionFile, ref)
+	}
+	return changes, nil
+}
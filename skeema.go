@@ -7,6 +7,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
 	"github.com/skeema/skeema/util"
 	"github.com/skeema/skeema/workspace"
 )
@@ -45,6 +46,9 @@ func main() {
 		Exit(NewExitValue(CodeBadConfig, err.Error()))
 	}
 
+	// Record all warnings from here on, since the strict option may be enabled
+	// by a global option file
+	warnings := collectWarnings()
 	util.AddGlobalConfigFiles(cfg)
 	if err := util.ProcessSpecialGlobalOptions(cfg); err != nil {
 		Exit(NewExitValue(CodeBadConfig, err.Error()))
@@ -52,6 +56,14 @@ func main() {
 
 	err = cfg.HandleCommand()
 	workspace.Shutdown()
+	log.Debugf("Performed %d filesystem operations inspecting directories", fs.FilesystemOps())
+	if cfg.GetBool("strict") {
+		err = warnings.promote(err)
+	}
+	if cfg.FindOption("exit-code-mode") != nil {
+		log.Info(OutcomeLine(err, cfg.GetBool("dry-run")))
+		err = ApplyExitCodeMode(cfg.Get("exit-code-mode"), err)
+	}
 	Exit(err)
 }
 
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -162,6 +163,19 @@ func (s SkeemaIntegrationSuite) TestAddEnvHandler(t *testing.T) {
 		t.Fatalf("File contents of %s do not match expectation", file.Path())
 	}
 
+	// extending an undefined environment should fail; extending an existing one
+	// should record the inheritance in the new section
+	s.handleCommand(t, CodeBadConfig, ".", "skeema add-environment --host my.qa.invalid --dir mydb --extends nonexistent qa")
+	cfg = s.handleCommand(t, CodeSuccess, ".", "skeema add-environment --host my.qa.invalid --dir mydb --extends production qa --connect-options='timeout=10ms'")
+	file = getOptionFile(t, "mydb", cfg)
+	origFile.SetOptionValue("qa", "extends", "production")
+	origFile.SetOptionValue("qa", "host", "my.qa.invalid")
+	origFile.SetOptionValue("qa", "port", "3306")
+	origFile.SetOptionValue("qa", "connect-options", "timeout=10ms")
+	if !origFile.SameContents(file) {
+		t.Fatalf("File contents of %s do not match expectation", file.Path())
+	}
+
 	// localhost and socket should work properly
 	s.handleCommand(t, CodeSuccess, ".", "skeema add-environment -h localhost -S /var/lib/mysql/mysql.sock --dir mydb development")
 	file = getOptionFile(t, "mydb", cfg)
@@ -450,9 +464,25 @@ func (s SkeemaIntegrationSuite) TestPushHandler(t *testing.T) {
 	// the files; verify the files match. The push inherently verifies creation of
 	// schemas and tables.
 	s.cleanData(t)
+	s.handleCommand(t, CodeNotPermitted, ".", "skeema push --max-statements=1")
+	s.handleCommand(t, CodeNotPermitted, ".", "skeema push --max-altered-objects=2")
+	s.handleCommand(t, CodeBadConfig, ".", "skeema push --max-statements=-1")
+	s.handleCommand(t, CodeBadUsage, ".", "skeema push --skip-all")
+	s.handleCommand(t, CodeSuccess, ".", "skeema push --skip-all --schemas=product")
+	s.handleCommand(t, CodeSuccess, "mydb/analytics", "skeema push --skip-all")
 	s.handleCommand(t, CodeSuccess, ".", "skeema push")
 	s.reinitAndVerifyFiles(t, "", "")
 
+	// Commands must be run from within a tree containing .skeema files
+	if err := os.MkdirAll(filepath.Join(s.scratchPath(), "unmanaged"), 0777); err != nil {
+		t.Fatalf("Unable to create dir: %s", err)
+	}
+	s.handleCommand(t, CodeBadConfig, "unmanaged", "skeema push")
+	s.handleCommand(t, CodeBadConfig, "unmanaged", "skeema lint")
+	if err := os.Remove(filepath.Join(s.scratchPath(), "unmanaged")); err != nil {
+		t.Fatalf("Unable to remove dir: %s", err)
+	}
+
 	// Test bad option values
 	s.handleCommand(t, CodeBadConfig, ".", "skeema push --concurrent-instances=0")
 	s.handleCommand(t, CodeBadConfig, ".", "skeema push --alter-algorithm=invalid")
@@ -697,6 +727,131 @@ func (s SkeemaIntegrationSuite) TestForeignKeys(t *testing.T) {
 	s.handleCommand(t, CodeSuccess, ".", "skeema push")
 }
 
+func (s SkeemaIntegrationSuite) TestBrokenForeignKeys(t *testing.T) {
+	// Fixture contains a foreign key referencing a table that was dropped while
+	// foreign_key_checks was disabled
+	s.sourceSQL(t, "danglingfk.sql")
+	s.handleCommand(t, CodeSuccess, ".", "skeema init --dir mydb -h %s -P %d", s.d.Instance.Host, s.d.Instance.Port)
+	if contents := fs.ReadTestFile(t, "mydb/product/orphans.sql"); !strings.Contains(contents, "`orphans_parent`") {
+		t.Fatal("Expected mydb/product/orphans.sql to contain foreign key definition, but it did not")
+	}
+	orphanFKCount := func() int {
+		t.Helper()
+		schema, err := s.d.Schema("product")
+		if err != nil {
+			t.Fatalf("Unexpected error from Schema: %s", err)
+		}
+		return len(schema.Table("orphans").ForeignKeys)
+	}
+
+	// The broken constraint is reported as a difference, but dropping it is
+	// unsafe, so push leaves it in place without --allow-unsafe
+	s.handleCommand(t, CodeDifferencesFound, ".", "skeema diff")
+	s.handleCommand(t, CodeSuccess, ".", "skeema push")
+	if count := orphanFKCount(); count != 1 {
+		t.Errorf("Expected push without --allow-unsafe to leave foreign key in place, instead found %d foreign keys", count)
+	}
+	s.handleCommand(t, CodeDifferencesFound, ".", "skeema diff --allow-unsafe")
+	s.handleCommand(t, CodeSuccess, ".", "skeema push --allow-unsafe")
+	if count := orphanFKCount(); count != 0 {
+		t.Errorf("Expected push --allow-unsafe to drop foreign key, instead found %d foreign keys", count)
+	}
+
+	// Once the filesystem is updated to match, there should be no differences
+	s.handleCommand(t, CodeSuccess, ".", "skeema pull")
+	s.handleCommand(t, CodeSuccess, ".", "skeema diff")
+
+	// If the filesystem defines the missing parent table, the foreign key is no
+	// longer considered broken
+	s.dbExec(t, "product", "DROP TABLE orphans")
+	s.sourceSQL(t, "danglingfk.sql")
+	s.handleCommand(t, CodeSuccess, ".", "skeema pull")
+	fs.WriteTestFile(t, "mydb/product/parents.sql", "CREATE TABLE parents (id int unsigned NOT NULL, PRIMARY KEY (id)) ENGINE=InnoDB;\n")
+	s.handleCommand(t, CodeSuccess, ".", "skeema push")
+	s.assertTableExists(t, "product", "parents", "")
+	if count := orphanFKCount(); count != 1 {
+		t.Errorf("Expected foreign key to remain once parent table exists, instead found %d foreign keys", count)
+	}
+	s.handleCommand(t, CodeSuccess, ".", "skeema diff")
+}
+
+func (s SkeemaIntegrationSuite) TestPushBackup(t *testing.T) {
+	s.handleCommand(t, CodeSuccess, ".", "skeema init --dir mydb -h %s -P %d", s.d.Instance.Host, s.d.Instance.Port)
+	backupFiles := func(tableName string) []string {
+		t.Helper()
+		matches, err := filepath.Glob(filepath.Join("mydb", ".skeema-backups", "*", "*", "product", tableName+".sql"))
+		if err != nil {
+			t.Fatalf("Unexpected error from Glob: %s", err)
+		}
+		return matches
+	}
+
+	// Dropping a table or column should back up the table first
+	fs.RemoveTestFile(t, "mydb/product/comments.sql")
+	contents := fs.ReadTestFile(t, "mydb/product/posts.sql")
+	fs.WriteTestFile(t, "mydb/product/posts.sql", strings.Replace(contents, "  `body` text,\n", "", 1))
+	s.handleCommand(t, CodeSuccess, ".", "skeema push --allow-unsafe --backup-row-count")
+	for _, tableName := range []string{"comments", "posts"} {
+		matches := backupFiles(tableName)
+		if len(matches) != 1 {
+			t.Fatalf("Expected 1 backup file for %s, instead found %v", tableName, matches)
+		}
+		contents := fs.ReadTestFile(t, matches[0])
+		if !strings.Contains(contents, "CREATE TABLE `"+tableName+"`") || !strings.Contains(contents, "-- Row count: 0") {
+			t.Errorf("Backup file %s has unexpected contents:\n%s", matches[0], contents)
+		}
+	}
+	if _, err := os.Stat("mydb/.skeema-backups/.gitignore"); err != nil {
+		t.Errorf("Expected backup dir to contain .gitignore, but Stat returned %v", err)
+	}
+	if matches := backupFiles("users"); len(matches) != 0 {
+		t.Errorf("Expected no backup of unmodified table, instead found %v", matches)
+	}
+
+	// With --skip-backup, nothing should be written
+	fs.RemoveTestFile(t, "mydb/product/subscriptions.sql")
+	s.handleCommand(t, CodeSuccess, ".", "skeema push --allow-unsafe --skip-backup")
+	s.assertTableMissing(t, "product", "subscriptions", "")
+	if matches := backupFiles("subscriptions"); len(matches) != 0 {
+		t.Errorf("Expected no backup with --skip-backup, instead found %v", matches)
+	}
+
+	// If the backup cannot be written, the destructive statement must not run
+	fs.WriteTestFile(t, "mydb/notadir", "")
+	fs.RemoveTestFile(t, "mydb/product/users.sql")
+	s.handleCommand(t, CodeFatalError, ".", "skeema push --allow-unsafe --backup-dir=notadir")
+	s.assertTableExists(t, "product", "users", "")
+}
+
+func (s SkeemaIntegrationSuite) TestTrackingTable(t *testing.T) {
+	s.handleCommand(t, CodeSuccess, ".", "skeema init --dir mydb -h %s -P %d", s.d.Instance.Host, s.d.Instance.Port)
+
+	// Missing tracking table is shown in diff, and created by push
+	s.handleCommand(t, CodeDifferencesFound, ".", "skeema diff --tracking-table=_skeema_meta")
+	s.handleCommand(t, CodeSuccess, ".", "skeema push --tracking-table=_skeema_meta --label=abc123")
+	s.assertTableExists(t, "product", "_skeema_meta", "")
+	db, err := s.d.Connect("product", "")
+	if err != nil {
+		t.Fatalf("Unable to connect to DockerizedInstance: %s", err)
+	}
+	var label string
+	if err := db.QueryRow("SELECT label FROM _skeema_meta WHERE id = 1").Scan(&label); err != nil || label != "abc123" {
+		t.Errorf("Unexpected tracking table label %q / %v", label, err)
+	}
+
+	// Tracking table is excluded from subsequent diffs and pulls; each push
+	// updates its label
+	s.handleCommand(t, CodeSuccess, ".", "skeema diff --tracking-table=_skeema_meta")
+	s.handleCommand(t, CodeSuccess, ".", "skeema pull --tracking-table=_skeema_meta")
+	if _, err := os.Stat("mydb/product/_skeema_meta.sql"); err == nil {
+		t.Error("Expected pull to ignore tracking table, but _skeema_meta.sql was written")
+	}
+	s.handleCommand(t, CodeSuccess, ".", "skeema push --tracking-table=_skeema_meta --label=def456")
+	if err := db.QueryRow("SELECT label FROM _skeema_meta WHERE id = 1").Scan(&label); err != nil || label != "def456" {
+		t.Errorf("Unexpected tracking table label %q / %v", label, err)
+	}
+}
+
 func (s SkeemaIntegrationSuite) TestAutoInc(t *testing.T) {
 	// Insert 2 rows into product.users, so that next auto-inc value is now 3
 	s.dbExec(t, "product", "INSERT INTO users (name) VALUES (?), (?)", "foo", "bar")
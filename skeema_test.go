@@ -12,6 +12,7 @@ import (
 
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/testutil"
 	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
@@ -28,22 +29,10 @@ func TestMain(m *testing.M) {
 }
 
 func TestIntegration(t *testing.T) {
-	images := tengo.SplitEnv("SKEEMA_TEST_IMAGES")
-	if len(images) == 0 {
-		fmt.Println("SKEEMA_TEST_IMAGES env var is not set, so integration tests will be skipped!")
-		fmt.Println("To run integration tests, you may set SKEEMA_TEST_IMAGES to a comma-separated")
-		fmt.Println("list of Docker images. Example:\n# SKEEMA_TEST_IMAGES=\"mysql:5.6,mysql:5.7\" go test")
-	}
-	manager, err := tengo.NewDockerClient(tengo.DockerClientOptions{})
-	if err != nil {
-		t.Errorf("Unable to create sandbox manager: %s", err)
-	}
-	suite := &SkeemaIntegrationSuite{manager: manager}
-	tengo.RunSuite(suite, t, images)
+	testutil.RunSuite(t, &SkeemaIntegrationSuite{})
 }
 
 type SkeemaIntegrationSuite struct {
-	manager  *tengo.DockerClient
 	d        *tengo.DockerizedInstance
 	repoPath string
 }
@@ -56,11 +45,7 @@ func (s *SkeemaIntegrationSuite) Setup(backend string) (err error) {
 	}
 
 	// Spin up a Dockerized database server
-	s.d, err = s.manager.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
-		Name:         fmt.Sprintf("skeema-test-%s", strings.Replace(backend, ":", "-", -1)),
-		Image:        backend,
-		RootPassword: "fakepw",
-	})
+	s.d, err = testutil.DockerInstance(backend, "")
 	return err
 }
 
@@ -130,7 +115,10 @@ func (s *SkeemaIntegrationSuite) handleCommand(t *testing.T, expectedExitCode in
 
 	fullCommandLine := fmt.Sprintf(commandLine, a...)
 	fmt.Fprintf(os.Stderr, "\x1b[37;1m%s$\x1b[0m %s\n", filepath.Join("testdata", ".scratch", pwd), fullCommandLine)
-	fakeFileSource := mybase.SimpleSource(map[string]string{"password": s.d.Instance.Password})
+	// The all option is enabled here, as if in a global option file, to avoid
+	// needing confirmation whenever a test pushes from a non-leaf dir. Tests of
+	// the confirmation behavior can override this with --skip-all.
+	fakeFileSource := mybase.SimpleSource(map[string]string{"password": s.d.Instance.Password, "all": "1"})
 	cfg := mybase.ParseFakeCLI(t, CommandSuite, fullCommandLine, fakeFileSource)
 	util.AddGlobalConfigFiles(cfg)
 	err := util.ProcessSpecialGlobalOptions(cfg)
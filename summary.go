@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/linter"
+)
+
+// SummaryVersion is the schema_version of summary files written via the
+// summary-file option. It must be incremented upon any backwards-incompatible
+// change to the format, such as removing or renaming a field.
+const SummaryVersion = 1
+
+// loggedWarnings counts warning-level log entries over the lifetime of the
+// process, so that a summary can report the number logged during its run.
+var loggedWarnings int64
+
+func init() {
+	log.AddHook(warningCounter{})
+}
+
+// warningCounter is a logrus hook which increments loggedWarnings.
+type warningCounter struct{}
+
+// Levels returns the log levels counted, satisfying logrus.Hook.
+func (warningCounter) Levels() []log.Level {
+	return []log.Level{log.WarnLevel}
+}
+
+// Fire counts entry, satisfying logrus.Hook.
+func (warningCounter) Fire(entry *log.Entry) error {
+	atomic.AddInt64(&loggedWarnings, 1)
+	return nil
+}
+
+// runSummary is the content of a summary file, written at the end of a run
+// of diff, push, lint, or pull when the summary-file option is set. This
+// provides CI systems with a machine-readable description of the run, without
+// needing to parse STDOUT or STDERR.
+type runSummary struct {
+	SchemaVersion   int             `json:"schema_version"`
+	Command         string          `json:"command"`
+	ExitCode        int             `json:"exit_code"`
+	RawExitCode     int             `json:"raw_exit_code"`
+	ExitCodeMode    string          `json:"exit_code_mode,omitempty"`
+	Differences     bool            `json:"differences_found"`
+	Errors          bool            `json:"errors_occurred"`
+	Error           string          `json:"error,omitempty"`
+	DurationSeconds float64         `json:"duration_seconds"`
+	TargetCount     int             `json:"target_count"`
+	ObjectsChanged  int             `json:"objects_changed"`
+	StatementCount  int             `json:"statements"`
+	UnsafeCount     int             `json:"unsafe_statements"`
+	WarningCount    int             `json:"warnings"`
+	Targets         []summaryTarget `json:"targets"`
+
+	path          string
+	strict        bool
+	start         time.Time
+	startWarnings int64
+}
+
+// summaryTarget describes the outcome of a single target in a summary file.
+// For diff and push, a target is a schema on a database instance. For lint, a
+// target is a directory. For pull, a target is a directory mapping to a
+// schema.
+type summaryTarget struct {
+	applier.TargetResult
+	LintErrors   int `json:"lint_errors,omitempty"`
+	LintWarnings int `json:"lint_warnings,omitempty"`
+}
+
+// startSummary returns a new runSummary for the command in cfg, if the
+// summary-file option is set. Otherwise, nil is returned. All methods of
+// runSummary may safely be called on a nil pointer, and have no effect.
+func startSummary(cfg *mybase.Config) *runSummary {
+	if cfg.FindOption("summary-file") == nil || cfg.Get("summary-file") == "" {
+		return nil
+	}
+	var exitCodeMode string
+	if cfg.FindOption("exit-code-mode") != nil {
+		exitCodeMode = cfg.Get("exit-code-mode")
+	}
+	return &runSummary{
+		SchemaVersion: SummaryVersion,
+		Command:       cfg.CLI.Command.Name,
+		ExitCodeMode:  exitCodeMode,
+		Targets:       []summaryTarget{},
+		path:          cfg.Get("summary-file"),
+		strict:        cfg.GetBool("strict"),
+		start:         time.Now(),
+		startWarnings: atomic.LoadInt64(&loggedWarnings),
+	}
+}
+
+// addPushResult records the targets of a result from diff or push.
+func (s *runSummary) addPushResult(result applier.Result) {
+	if s == nil {
+		return
+	}
+	for _, tr := range result.Targets {
+		s.Targets = append(s.Targets, summaryTarget{TargetResult: tr})
+	}
+}
+
+// addLintResult records the linter result for a single directory.
+func (s *runSummary) addLintResult(dir *fs.Dir, result *linter.Result) {
+	if s == nil {
+		return
+	}
+	status := "ok"
+	if len(result.Exceptions) > 0 {
+		status = "error"
+	} else if result.ErrorCount > 0 || result.WarningCount > 0 {
+		status = "problems"
+	} else if result.ReformatCount > 0 {
+		status = "reformatted"
+	}
+	s.Targets = append(s.Targets, summaryTarget{
+		TargetResult: applier.TargetResult{
+			Dir:         dir.RelPath(),
+			Status:      status,
+			ObjectCount: result.ReformatCount,
+		},
+		LintErrors:   result.ErrorCount,
+		LintWarnings: result.WarningCount,
+	})
+}
+
+// addTarget records the outcome of a target which is not a diff or push target,
+// such as a directory processed by pull.
+func (s *runSummary) addTarget(tr applier.TargetResult) {
+	if s == nil {
+		return
+	}
+	s.Targets = append(s.Targets, summaryTarget{TargetResult: tr})
+}
+
+// finish computes totals and writes the summary file, reflecting err as the
+// final outcome of the command. The file is written atomically, by writing a
+// temporary file in the same directory and then renaming it. Failure to write
+// the file is logged, but does not otherwise affect the command's result.
+func (s *runSummary) finish(err error) {
+	if s == nil {
+		return
+	}
+	s.DurationSeconds = time.Since(s.start).Seconds()
+	s.TargetCount = len(s.Targets)
+	for _, target := range s.Targets {
+		s.ObjectsChanged += target.ObjectCount
+		s.StatementCount += target.StatementCount
+		s.UnsafeCount += target.UnsafeCount
+	}
+	s.WarningCount = int(atomic.LoadInt64(&loggedWarnings) - s.startWarnings)
+	for _, target := range s.Targets {
+		if target.Status == "differences" {
+			s.Differences = true
+		}
+	}
+
+	// Mirror the final exit code that main will use, if the strict option
+	// promotes warnings to a fatal error, and then if exit-code-mode remaps the
+	// result. The raw facts are recorded regardless of exit-code-mode.
+	if s.strict && s.WarningCount > 0 && ExitCode(err) < CodeFatalError {
+		err = NewExitValue(CodeFatalError, "Exiting with an error due to --strict, since %s logged", countAndNoun(s.WarningCount, "warning was", "warnings were"))
+	}
+	s.RawExitCode = ExitCode(err)
+	if err != nil {
+		s.Error = err.Error()
+		s.Errors = !DifferencesOnly(err)
+	}
+	s.ExitCode = ExitCode(ApplyExitCodeMode(s.ExitCodeMode, err))
+	if writeErr := s.write(); writeErr != nil {
+		log.Errorf("Unable to write summary file %s: %s", s.path, writeErr)
+	}
+}
+
+func (s *runSummary) write() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
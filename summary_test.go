@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/applier"
+)
+
+func TestRunSummary(t *testing.T) {
+	// Without summary-file, all methods are no-ops on a nil summary
+	cfg := mybase.ParseFakeCLI(t, CommandSuite, "skeema push")
+	if summary := startSummary(cfg); summary != nil {
+		t.Fatalf("Expected nil summary without summary-file, instead found %+v", summary)
+	} else {
+		summary.addPushResult(applier.Result{})
+		summary.finish(nil)
+	}
+
+	tempDir, err := ioutil.TempDir("", "skeema-summary")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	path := filepath.Join(tempDir, "summary.json")
+
+	cfg = mybase.ParseFakeCLI(t, CommandSuite, "skeema diff --summary-file="+path)
+	summary := startSummary(cfg)
+	summary.addPushResult(applier.Result{Targets: []applier.TargetResult{
+		{Dir: "mydb/product", Instance: "127.0.0.1:3306", Schema: "product", Status: "differences", ObjectCount: 3, StatementCount: 4, UnsafeCount: 1},
+		{Dir: "mydb/analytics", Instance: "127.0.0.1:3306", Schema: "analytics", Status: "error", Error: "oops"},
+	}})
+	warningCounter{}.Fire(&log.Entry{Level: log.WarnLevel, Message: "this is a warning"})
+	summary.finish(NewExitValue(CodeFatalError, "fatal"))
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unable to read summary file: %s", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(contents, &decoded); err != nil {
+		t.Fatalf("Unable to decode summary file: %s", err)
+	}
+	expected := map[string]interface{}{
+		"schema_version":    float64(SummaryVersion),
+		"command":           "diff",
+		"exit_code":         float64(CodeFatalError),
+		"error":             "fatal",
+		"target_count":      float64(2),
+		"objects_changed":   float64(3),
+		"statements":        float64(4),
+		"unsafe_statements": float64(1),
+		"warnings":          float64(1),
+	}
+	for key, value := range expected {
+		if decoded[key] != value {
+			t.Errorf("Expected %s to be %v, instead found %v", key, value, decoded[key])
+		}
+	}
+	if targets, ok := decoded["targets"].([]interface{}); !ok || len(targets) != 2 {
+		t.Errorf("Unexpected targets in summary file: %v", decoded["targets"])
+	} else if status := targets[1].(map[string]interface{})["status"]; status != "error" {
+		t.Errorf("Expected second target's status to be error, instead found %v", status)
+	}
+
+	// exit-code-mode affects exit_code, but the raw facts are still recorded
+	expectExitCodes := map[string][]int{
+		"strict":     {CodeDifferencesFound, CodeFatalError},
+		"zero":       {CodeSuccess, CodeFatalError},
+		"drift-only": {CodeDifferencesFound, CodeDifferencesFound},
+	}
+	for mode, expectCodes := range expectExitCodes {
+		cfg = mybase.ParseFakeCLI(t, CommandSuite, "skeema diff --exit-code-mode="+mode+" --summary-file="+path)
+		for n, err := range []error{NewExitValue(CodeDifferencesFound, ""), NewExitValue(CodeFatalError, "fatal")} {
+			summary = startSummary(cfg)
+			summary.addPushResult(applier.Result{Targets: []applier.TargetResult{
+				{Dir: "mydb/product", Instance: "127.0.0.1:3306", Schema: "product", Status: "differences", ObjectCount: 1, StatementCount: 1},
+			}})
+			summary.finish(err)
+			contents, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Unable to read summary file: %s", err)
+			}
+			var decoded runSummary
+			if err := json.Unmarshal(contents, &decoded); err != nil {
+				t.Fatalf("Unable to decode summary file: %s", err)
+			}
+			expectErrors := decoded.RawExitCode == CodeFatalError
+			if decoded.ExitCodeMode != mode || !decoded.Differences || decoded.Errors != expectErrors {
+				t.Errorf("Unexpected facts in summary with exit-code-mode=%s: %+v", mode, decoded)
+			}
+			if decoded.ExitCode != expectCodes[n] {
+				t.Errorf("With exit-code-mode=%s, expected exit_code %d, instead found %d", mode, expectCodes[n], decoded.ExitCode)
+			}
+		}
+	}
+
+	// No temp files should remain alongside the summary file
+	if entries, err := ioutil.ReadDir(tempDir); err != nil || len(entries) != 1 {
+		t.Errorf("Expected only the summary file in %s, instead found %v (err=%v)", tempDir, entries, err)
+	}
+}
@@ -0,0 +1,12 @@
+use product
+SET foreign_key_checks=0;
+CREATE TABLE parents (id int unsigned NOT NULL, PRIMARY KEY (id)) ENGINE=InnoDB;
+CREATE TABLE orphans (
+	id int unsigned NOT NULL,
+	parent_id int unsigned NOT NULL,
+	PRIMARY KEY (id),
+	KEY parent_id (parent_id),
+	CONSTRAINT orphans_parent FOREIGN KEY (parent_id) REFERENCES parents (id)
+) ENGINE=InnoDB;
+DROP TABLE parents;
+SET foreign_key_checks=1;
@@ -0,0 +1,5 @@
+CREATE TABLE authors (
+  id int unsigned NOT NULL AUTO_INCREMENT,
+  name varchar(100) NOT NULL,
+  PRIMARY KEY (id)
+) ENGINE=InnoDB;
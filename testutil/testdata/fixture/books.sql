@@ -0,0 +1,10 @@
+USE some_other_db;
+CREATE TABLE books (
+  id int unsigned NOT NULL AUTO_INCREMENT,
+  author_id int unsigned NOT NULL,
+  title varchar(200) NOT NULL,
+  PRIMARY KEY (id),
+  KEY author (author_id),
+  CONSTRAINT books_author FOREIGN KEY (author_id) REFERENCES authors (id)
+) ENGINE=InnoDB;
+INSERT INTO authors (name) VALUES ('Anonymous');
@@ -0,0 +1,236 @@
+// Package testutil provides helpers for writing integration tests which
+// interact with a real database server. These helpers are used by Skeema's own
+// integration tests, but may also be useful to applications using Skeema's
+// packages as a library.
+//
+// Tests obtain a database server in one of two ways. If the SKEEMA_TEST_DSN env
+// var is set, it is used to connect to an existing server. Otherwise, if the
+// SKEEMA_TEST_IMAGES env var is set to a comma-separated list of Docker images,
+// a sandbox container is created or reused for each image. If neither is set,
+// tests using these helpers are skipped.
+package testutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/skeema/skeema/fs"
+	"github.com/skeema/tengo"
+)
+
+// Environment variables examined by this package
+const (
+	ImagesEnvVar = "SKEEMA_TEST_IMAGES"
+	DSNEnvVar    = "SKEEMA_TEST_DSN"
+)
+
+// RootPassword is the root password used for Dockerized sandbox instances.
+const RootPassword = "fakepw"
+
+// Images returns the Docker images listed in the SKEEMA_TEST_IMAGES env var. If
+// none are listed, instructions for running integration tests are printed.
+func Images() []string {
+	images := tengo.SplitEnv(ImagesEnvVar)
+	if len(images) == 0 {
+		fmt.Println("SKEEMA_TEST_IMAGES env var is not set, so integration tests will be skipped!")
+		fmt.Println("To run integration tests, you may set SKEEMA_TEST_IMAGES to a comma-separated")
+		fmt.Println("list of Docker images. Example:\n# SKEEMA_TEST_IMAGES=\"mysql:5.6,mysql:5.7\" go test")
+	}
+	return images
+}
+
+// RunSuite runs all test methods of suite once per Docker image listed in the
+// SKEEMA_TEST_IMAGES env var. The suite's Setup method receives the image name
+// as its backend arg, and may use DockerInstance to obtain a sandbox instance.
+// The suite is skipped if no images are listed.
+func RunSuite(t *testing.T, suite tengo.IntegrationTestSuite) {
+	t.Helper()
+	tengo.RunSuite(suite, t, Images())
+}
+
+var dockerClient *tengo.DockerClient
+
+// DockerInstance returns a sandbox database instance running the supplied
+// Docker image, creating and starting its container if needed. Containers are
+// named consistently by image, so that all packages' tests share the same
+// container for a given image. An optional suffix may be supplied to obtain an
+// additional container for the same image. defaultConnParams, if non-empty, is
+// applied as default session variables to all connections to the instance.
+func DockerInstance(image, defaultConnParams string, suffix ...string) (*tengo.DockerizedInstance, error) {
+	if dockerClient == nil {
+		client, err := tengo.NewDockerClient(tengo.DockerClientOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create sandbox manager: %s", err)
+		}
+		dockerClient = client
+	}
+	name := fmt.Sprintf("skeema-test-%s", strings.Replace(image, ":", "-", -1))
+	if len(suffix) > 0 && suffix[0] != "" {
+		name = fmt.Sprintf("%s-%s", name, suffix[0])
+	}
+	return dockerClient.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
+		Name:              name,
+		Image:             image,
+		RootPassword:      RootPassword,
+		DefaultConnParams: defaultConnParams,
+	})
+}
+
+// NewInstance returns a database instance for use by a test, along with a
+// cleanup function which the test should call (typically via defer) when it
+// no longer needs the instance. The SKEEMA_TEST_DSN env var is used if set;
+// otherwise, the first image listed in SKEEMA_TEST_IMAGES is used to obtain a
+// Dockerized sandbox instance. If neither env var is set, or the instance
+// cannot be reached, the test is skipped.
+func NewInstance(t *testing.T) (*tengo.Instance, func()) {
+	t.Helper()
+	if dsn := os.Getenv(DSNEnvVar); dsn != "" {
+		inst, err := tengo.NewInstance("mysql", dsn)
+		if err != nil {
+			t.Fatalf("Invalid value for %s: %s", DSNEnvVar, err)
+		}
+		if ok, err := inst.CanConnect(); !ok {
+			t.Skipf("Skipping test: unable to connect to %s from %s: %s", inst, DSNEnvVar, err)
+		}
+		return inst, inst.CloseAll
+	}
+	images := tengo.SplitEnv(ImagesEnvVar)
+	if len(images) == 0 {
+		t.Skipf("Skipping test: neither %s nor %s env var is set", DSNEnvVar, ImagesEnvVar)
+	}
+	d, err := DockerInstance(images[0], "")
+	if err != nil {
+		t.Skipf("Skipping test: unable to obtain Dockerized instance for %s: %s", images[0], err)
+	}
+	cleanup := func() {
+		if err := d.Stop(); err != nil {
+			t.Errorf("Unable to stop Dockerized instance %s: %s", d, err)
+		}
+	}
+	return d.Instance, cleanup
+}
+
+// LoadFixtureDir creates a schema with the supplied name on inst, dropping any
+// pre-existing schema of the same name first. The *.sql files in dirPath are
+// then executed in the new schema, in file name order, and the resulting
+// schema is returned. Commands such as USE are ignored, so that all statements
+// execute in the new schema. Foreign key checks are disabled while the files
+// execute, so files may define tables with foreign keys in any order. Any
+// error is fatal to the test.
+func LoadFixtureDir(t *testing.T, inst *tengo.Instance, schemaName, dirPath string) *tengo.Schema {
+	t.Helper()
+	if has, err := inst.HasSchema(schemaName); err != nil {
+		t.Fatalf("Unable to query schemas on %s: %s", inst, err)
+	} else if has {
+		if err := inst.DropSchema(schemaName, tengo.BulkDropOptions{}); err != nil {
+			t.Fatalf("Unable to drop schema %s on %s: %s", schemaName, inst, err)
+		}
+	}
+	if _, err := inst.CreateSchema(schemaName, tengo.SchemaCreationOptions{}); err != nil {
+		t.Fatalf("Unable to create schema %s on %s: %s", schemaName, inst, err)
+	}
+	db, err := inst.Connect(schemaName, "foreign_key_checks=0")
+	if err != nil {
+		t.Fatalf("Unable to connect to %s: %s", inst, err)
+	}
+	for _, sqlFile := range fixtureFiles(t, dirPath) {
+		tokenized, err := sqlFile.Tokenize()
+		if err != nil {
+			t.Fatalf("Unable to read %s: %s", sqlFile, err)
+		}
+		for _, stmt := range tokenized.Statements {
+			if stmt.Type == fs.StatementTypeNoop || stmt.Type == fs.StatementTypeCommand {
+				continue
+			}
+			if _, err := db.Exec(stmt.Body()); err != nil {
+				t.Fatalf("Error executing statement at %s: %s", stmt.Location(), err)
+			}
+		}
+	}
+	schema, err := inst.Schema(schemaName)
+	if err != nil {
+		t.Fatalf("Unable to introspect schema %s on %s: %s", schemaName, inst, err)
+	}
+	return schema
+}
+
+// fixtureFiles returns the *.sql files in dirPath, sorted by name.
+func fixtureFiles(t *testing.T, dirPath string) []fs.SQLFile {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dirPath)
+	if err != nil {
+		t.Fatalf("Unable to read fixture dir %s: %s", dirPath, err)
+	}
+	absPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		t.Fatalf("Unable to determine absolute path of %s: %s", dirPath, err)
+	}
+	var result []fs.SQLFile
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && strings.HasSuffix(entry.Name(), ".sql") {
+			result = append(result, fs.SQLFile{Dir: absPath, FileName: entry.Name()})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FileName < result[j].FileName
+	})
+	return result
+}
+
+// ProvisionSchema combines NewInstance and LoadFixtureDir: it obtains an
+// instance, and loads the *.sql files in fixtureDir into a schema with the
+// supplied name. The returned cleanup function drops the schema and then
+// releases the instance. The test is skipped if no instance is available.
+func ProvisionSchema(t *testing.T, schemaName, fixtureDir string) (*tengo.Instance, func()) {
+	t.Helper()
+	inst, releaseInstance := NewInstance(t)
+	LoadFixtureDir(t, inst, schemaName, fixtureDir)
+	cleanup := func() {
+		if err := inst.DropSchema(schemaName, tengo.BulkDropOptions{}); err != nil {
+			t.Errorf("Unable to drop schema %s on %s: %s", schemaName, inst, err)
+		}
+		releaseInstance()
+	}
+	return inst, cleanup
+}
+
+// AssertSchemaMatchesDir fails the test if the schema with the supplied name on
+// inst differs from the definitions in the *.sql files in dirPath. The files
+// are loaded into a temporary schema on the same instance for comparison, so
+// differences purely in formatting or next auto-increment value are not
+// considered. Each difference is reported as the DDL which would be required
+// to make the schema match the dir.
+func AssertSchemaMatchesDir(t *testing.T, inst *tengo.Instance, schemaName, dirPath string) {
+	t.Helper()
+	actual, err := inst.Schema(schemaName)
+	if err != nil {
+		t.Fatalf("Unable to introspect schema %s on %s: %s", schemaName, inst, err)
+	}
+	expectedName := fmt.Sprintf("_skeema_expected_%s", schemaName)
+	expected := LoadFixtureDir(t, inst, expectedName, dirPath)
+	defer func() {
+		if err := inst.DropSchema(expectedName, tengo.BulkDropOptions{}); err != nil {
+			t.Errorf("Unable to drop schema %s on %s: %s", expectedName, inst, err)
+		}
+	}()
+
+	mods := tengo.StatementModifiers{
+		NextAutoInc: tengo.NextAutoIncIgnore,
+		AllowUnsafe: true,
+		Flavor:      inst.Flavor(),
+	}
+	diff := tengo.NewSchemaDiff(actual, expected)
+	for _, objDiff := range diff.ObjectDiffs() {
+		stmt, err := objDiff.Statement(mods)
+		if err != nil {
+			t.Errorf("Schema %s differs from %s for %s, and the difference cannot be expressed as DDL: %s", schemaName, dirPath, objDiff.ObjectKey(), err)
+		} else if stmt != "" {
+			t.Errorf("Schema %s differs from %s for %s: %s", schemaName, dirPath, objDiff.ObjectKey(), stmt)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package testutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestMain(m *testing.M) {
+	// Suppress packet error output when attempting to connect to a Dockerized
+	// mysql-server which is still starting up
+	tengo.UseFilteredDriverLogger()
+
+	os.Exit(m.Run())
+}
+
+func TestFixtureFiles(t *testing.T) {
+	files := fixtureFiles(t, "testdata/fixture")
+	if len(files) != 2 || files[0].FileName != "authors.sql" || files[1].FileName != "books.sql" {
+		t.Errorf("Unexpected result from fixtureFiles: %+v", files)
+	}
+}
+
+func TestProvisionSchema(t *testing.T) {
+	inst, cleanup := ProvisionSchema(t, "testutil_provision", "testdata/fixture")
+	defer cleanup()
+
+	schema, err := inst.Schema("testutil_provision")
+	if err != nil {
+		t.Fatalf("Unexpected error from Schema: %v", err)
+	}
+	if tables := schema.TablesByName(); len(tables) != 2 || tables["authors"] == nil || tables["books"] == nil {
+		t.Errorf("Unexpected tables in provisioned schema: %+v", tables)
+	}
+	if has, _ := inst.HasSchema("some_other_db"); has {
+		t.Error("Expected USE command in fixture to be ignored, but it was not")
+	}
+	if hasRows, err := inst.TableHasRows("testutil_provision", "authors"); err != nil || !hasRows {
+		t.Errorf("Expected INSERT in fixture to be executed; instead found hasRows=%t, err=%v", hasRows, err)
+	}
+
+	AssertSchemaMatchesDir(t, inst, "testutil_provision", "testdata/fixture")
+	if has, _ := inst.HasSchema("_skeema_expected_testutil_provision"); has {
+		t.Error("Expected AssertSchemaMatchesDir to drop its temporary schema, but it did not")
+	}
+}
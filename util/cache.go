@@ -18,7 +18,9 @@ func init() {
 
 // NewInstance wraps tengo.NewInstance such that two identical requests will
 // return the same *tengo.Instance. This helps reduce excessive creation of
-// redundant connections.
+// redundant connections. In addition to the drivers supported by tengo,
+// driver may be IntrospectionDriver, in which case the Instance connects via
+// the mysql driver but with introspection queries counted and throttled.
 func NewInstance(driver, dsn string) (*tengo.Instance, error) {
 	key := fmt.Sprintf("%s:%s", driver, dsn)
 	instanceCache.Lock()
@@ -27,10 +29,15 @@ func NewInstance(driver, dsn string) (*tengo.Instance, error) {
 	if already {
 		return instance, nil
 	}
-	instance, err := tengo.NewInstance(driver, dsn)
+	tengoDriver := driver
+	if driver == IntrospectionDriver {
+		tengoDriver = "mysql"
+	}
+	instance, err := tengo.NewInstance(tengoDriver, dsn)
 	if err != nil {
 		return nil, err
 	}
+	instance.Driver = driver
 	instanceCache.instanceMap[key] = instance
 	return instance, nil
 }
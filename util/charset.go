@@ -0,0 +1,40 @@
+package util
+
+import (
+	"strings"
+
+	"github.com/skeema/tengo"
+)
+
+// MySQL 8.0 and MariaDB 10.6 began reporting the three-byte utf8 character set
+// as utf8mb3, its underlying name; MySQL 8.0.30 additionally renamed its
+// collations from utf8_* to utf8mb3_*. The two spellings are aliases on all
+// flavors, but a schema introspected from a server using one spelling will
+// otherwise appear to differ from an identical schema using the other.
+
+// CanonicalCharSet returns the supplied character set or collation name with
+// the utf8mb3 alias replaced by utf8, for purposes of comparing names which
+// may have come from different server versions. Other names are returned
+// unchanged.
+func CanonicalCharSet(name string) string {
+	if name == "utf8mb3" {
+		return "utf8"
+	} else if strings.HasPrefix(name, "utf8mb3_") {
+		return "utf8" + strings.TrimPrefix(name, "utf8mb3")
+	}
+	return name
+}
+
+// EquivalentCharSets returns true if the supplied character set or collation
+// names are identical, or differ only in spelling of the utf8mb3 alias.
+func EquivalentCharSets(a, b string) bool {
+	return a == b || CanonicalCharSet(a) == CanonicalCharSet(b)
+}
+
+// FlavorHasUtf8mb3Alias returns false if flavor is known to predate both MySQL
+// 8.0 and MariaDB 10.6, in which case the server always spells the utf8
+// character set and its collations as "utf8". It returns true otherwise,
+// including for an unknown flavor.
+func FlavorHasUtf8mb3Alias(flavor tengo.Flavor) bool {
+	return !flavor.Known() || flavor.MySQLishMinVersion(8, 0) || flavor.VendorMinVersion(tengo.VendorMariaDB, 10, 6)
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/skeema/tengo"
+)
+
+func TestCanonicalCharSet(t *testing.T) {
+	cases := map[string]string{
+		"utf8mb3":            "utf8",
+		"utf8mb3_general_ci": "utf8_general_ci",
+		"utf8mb3_bin":        "utf8_bin",
+		"utf8":               "utf8",
+		"utf8_unicode_ci":    "utf8_unicode_ci",
+		"utf8mb4":            "utf8mb4",
+		"utf8mb4_0900_ai_ci": "utf8mb4_0900_ai_ci",
+		"latin1":             "latin1",
+		"utf8mb3x":           "utf8mb3x",
+	}
+	for input, expected := range cases {
+		if actual := CanonicalCharSet(input); actual != expected {
+			t.Errorf("Expected CanonicalCharSet(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+	if !EquivalentCharSets("utf8mb3_general_ci", "utf8_general_ci") || EquivalentCharSets("utf8mb4", "utf8mb3") {
+		t.Error("Unexpected result from EquivalentCharSets")
+	}
+}
+
+func TestFlavorHasUtf8mb3Alias(t *testing.T) {
+	cases := map[tengo.Flavor]bool{
+		tengo.FlavorUnknown:               true,
+		tengo.FlavorMySQL57:               false,
+		tengo.FlavorMySQL80:               true,
+		tengo.FlavorPercona80:             true,
+		tengo.FlavorMariaDB104:            false,
+		tengo.NewFlavor("mariadb", 10, 6): true,
+		tengo.NewFlavor("mysql", 5, 5):    false,
+	}
+	for flavor, expected := range cases {
+		if actual := FlavorHasUtf8mb3Alias(flavor); actual != expected {
+			t.Errorf("Expected FlavorHasUtf8mb3Alias(%s) to return %t, instead found %t", flavor, expected, actual)
+		}
+	}
+}
@@ -7,7 +7,9 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/mybase"
@@ -24,9 +26,18 @@ func AddGlobalOptions(cmd *mybase.Command) {
 	cmd.AddOption(mybase.StringOption("schema", 0, "", "Database schema name").Hidden())
 	cmd.AddOption(mybase.StringOption("ignore-schema", 0, "", "Ignore schemas that match regex").Hidden())
 	cmd.AddOption(mybase.StringOption("ignore-table", 0, "", "Ignore tables that match regex").Hidden())
+	cmd.AddOption(mybase.StringOption("ignore-dirs", 0, "", "Comma-separated names of subdirectories to skip, in addition to node_modules and vendor").Hidden())
 	cmd.AddOption(mybase.StringOption("default-character-set", 0, "", "Schema-level default character set").Hidden())
 	cmd.AddOption(mybase.StringOption("default-collation", 0, "", "Schema-level default collation").Hidden())
+	cmd.AddOption(mybase.StringOption("schema-comment", 0, "", "Schema-level comment (MariaDB 10.5+ only)").Hidden())
 	cmd.AddOption(mybase.StringOption("flavor", 0, "", "Database server expressed in format vendor:major.minor, for use in vendor/version specific syntax").Hidden())
+	cmd.AddOption(mybase.StringOption("format-version", 0, "", "Version of .skeema file format used in this repo; set automatically by init").Hidden())
+	cmd.AddOption(mybase.StringOption("extends", 0, "", "Name of another environment section in the same option file whose options this section inherits").Hidden())
+	cmd.AddOption(mybase.StringOption("include", 0, "", "Comma-separated paths of other option files whose options this file includes").Hidden())
+	cmd.AddOption(mybase.StringOption("include-optional", 0, "", "Same as include, but paths which do not exist are skipped").Hidden())
+	cmd.AddOption(mybase.StringOption("table-template", 0, "", "Name of a CREATE TABLE in this dir used as a template for template-tables, rather than as a table").Hidden())
+	cmd.AddOption(mybase.StringOption("template-tables", 0, "", "Comma-separated names of tables generated from table-template").Hidden())
+	cmd.AddOption(mybase.StringOption("manual-migrations", 0, "", "Comma-separated paths of manual migration files which supersede generated DDL for the tables they declare").Hidden())
 
 	// Deprecated options or deprecated aliases -- all hidden
 	cmd.AddOption(mybase.BoolOption("reuse-temp-schema", 0, false, "Do not drop temp-schema when done").Hidden())
@@ -34,20 +45,59 @@ func AddGlobalOptions(cmd *mybase.Command) {
 	// Visible global options
 	cmd.AddOption(mybase.StringOption("user", 'u', "root", "Username to connect to database host"))
 	cmd.AddOption(mybase.StringOption("password", 'p', "", "Password for database user; omit value to prompt from TTY (default no password)").ValueOptional())
+	cmd.AddOption(mybase.StringOption("ddl-user", 0, "", "Username for executing DDL, if different than user (default same as user)"))
+	cmd.AddOption(mybase.StringOption("ddl-password", 0, "", "Password for ddl-user; required if ddl-user is set"))
+	cmd.AddOption(mybase.StringOption("ssh-host", 0, "", "Bastion host (optionally with :port) through which to tunnel database connections via SSH"))
+	cmd.AddOption(mybase.StringOption("ssh-user", 0, "", "Username for ssh-host (default OS username)"))
+	cmd.AddOption(mybase.StringOption("ssh-key", 0, "", "Path to private key file for ssh-host (default ~/.ssh/id_ed25519, id_ecdsa, or id_rsa)"))
+	cmd.AddOption(mybase.StringOption("ssh-known-hosts", 0, "~/.ssh/known_hosts", "Path to known_hosts file used to verify the host key of ssh-host"))
 	cmd.AddOption(mybase.StringOption("host-wrapper", 'H', "", "External bin to shell out to for host lookup; see manual for template vars"))
+	cmd.AddOption(mybase.StringOption("decrypt-command", 0, "", "Shell command to decrypt .skeema.enc files, reading from STDIN and writing to STDOUT"))
 	cmd.AddOption(mybase.StringOption("temp-schema", 't', "_skeema_tmp", "Name of temporary schema for intermediate operations, created and dropped each run"))
 	cmd.AddOption(mybase.StringOption("temp-schema-binlog", 0, "auto", `Controls whether temp schema DDL operations are replicated (valid values: "on", "off", "auto")`))
 	cmd.AddOption(mybase.StringOption("temp-schema-threads", 0, "5", "Max number of concurrent CREATE/DROP with workspace=temp-schema"))
+	cmd.AddOption(mybase.StringOption("query-timeout", 0, "20s", "Abandon introspection queries that take longer than this duration (0 for no limit)"))
+	cmd.AddOption(mybase.StringOption("introspection-throttle", 0, "0", "Max introspection queries per second against each database server (0 for no limit)"))
+	cmd.AddOption(mybase.StringOption("introspection-batch-size", 0, "100", "Max tables to look up per table status introspection query"))
+	cmd.AddOption(mybase.BoolOption("introspection-prefer-show", 0, false, "Use SHOW TABLE STATUS instead of information_schema for table status on flavors without a data dictionary"))
+	cmd.AddOption(mybase.StringOption("fallback-ports", 0, "", "Comma-separated ports to try in order if the database host cannot be reached on its configured port"))
 	cmd.AddOption(mybase.StringOption("connect-options", 'o', "", "Comma-separated session options to set upon connecting to each database instance"))
 	cmd.AddOption(mybase.StringOption("workspace", 'w', "temp-schema", `Specifies where to run intermediate operations (valid values: "temp-schema", "docker")`))
 	cmd.AddOption(mybase.StringOption("docker-cleanup", 0, "none", `With --workspace=docker, specifies how to clean up containers (valid values: "none", "stop", "destroy")`))
+	cmd.AddOption(mybase.StringOption("docker-image", 0, "", "With --workspace=docker, image to use for containers (default derived from flavor)"))
+	cmd.AddOption(mybase.BoolOption("docker-fallback", 0, false, "With --workspace=docker, use --workspace=temp-schema instead if Docker is unavailable"))
+	cmd.AddOption(mybase.StringOption("docker-startup-timeout", 0, "30s", "With --workspace=docker, maximum time to wait for a container's database to accept connections"))
+	cmd.AddOption(mybase.StringOption("dir-mode", 0, "0755", "Octal permission mode for newly-created directories"))
+	cmd.AddOption(mybase.StringOption("file-mode", 0, "0644", "Octal permission mode for newly-created files"))
+	cmd.AddOption(mybase.StringOption("tracking-table", 0, "", "Name of table in each schema recording the last push; disabled if empty"))
+	cmd.AddOption(mybase.StringOption("state-file", 0, "", "Name of file in each schema dir recording object fingerprints as of the last pull or push; disabled if empty"))
+	cmd.AddOption(mybase.BoolOption("routine-delimiter", 0, true, "Wrap multi-statement routines in DELIMITER commands when writing new *.sql files"))
+	cmd.AddOption(mybase.BoolOption("environment-overrides", 0, false, "Apply and pull environment-specific object definitions in each schema dir's .overrides/<environment> subdir"))
+	cmd.AddOption(mybase.BoolOption("respect-gitignore", 0, true, "Skip subdirectories matching .gitignore patterns, if the repo base is a git repo root"))
 	cmd.AddOption(mybase.BoolOption("debug", 0, false, "Enable debug logging"))
+	cmd.AddOption(mybase.BoolOption("strict", 0, false, "Treat any logged warning as an error, causing a fatal exit code"))
 	cmd.AddOption(mybase.BoolOption("my-cnf", 0, true, "Parse ~/.my.cnf for configuration"))
 }
 
 // AddGlobalConfigFiles takes the mybase.Config generated from the CLI and adds
 // global option files as sources.
 func AddGlobalConfigFiles(cfg *mybase.Config) {
+	files, problems := GlobalConfigFiles(cfg)
+	for _, err := range problems {
+		log.Warn(err.Error())
+	}
+	for _, f := range files {
+		AddOptionSource(cfg, f)
+	}
+}
+
+// GlobalConfigFiles reads and parses the global option files which exist, and
+// returns them in order of increasing precedence, with the appropriate
+// sections already selected. Files which cannot be used are omitted from the
+// result, and instead an error describing each one is returned in problems.
+// The supplied cfg is used to know which options are valid, and to obtain the
+// values of the my-cnf option and environment arg.
+func GlobalConfigFiles(cfg *mybase.Config) (files []*mybase.File, problems []error) {
 	globalFilePaths := make([]string, 0, 4)
 
 	// Avoid using "real" global paths in test logic. Otherwise, if the user
@@ -69,7 +119,7 @@ func AddGlobalConfigFiles(cfg *mybase.Config) {
 			continue
 		}
 		if err := f.Read(); err != nil {
-			log.Warnf("Ignoring global option file %s due to read error: %s", f.Path(), err)
+			problems = append(problems, fmt.Errorf("Ignoring global option file %s due to read error: %s", f.Path(), err))
 			continue
 		}
 		if strings.HasSuffix(path, ".my.cnf") {
@@ -80,22 +130,39 @@ func AddGlobalConfigFiles(cfg *mybase.Config) {
 			}
 		}
 		if err := f.Parse(cfg); err != nil {
-			log.Warnf("Ignoring global option file %s due to parse error: %s", f.Path(), err)
+			problems = append(problems, fmt.Errorf("Ignoring global option file %s due to parse error: %s", f.Path(), err))
 			continue
 		}
 		if strings.HasSuffix(path, ".my.cnf") {
-			_ = f.UseSection("skeema", "client", "mysql") // safe to ignore error (doesn't matter if section doesn't exist)
+			_ = f.UseSection(myCnfSections...) // safe to ignore error (doesn't matter if section doesn't exist)
 		} else if cfg.CLI.Command.HasArg("environment") { // avoid panic on command without environment arg, such as help command!
-			_ = f.UseSection(cfg.Get("environment")) // safe to ignore error (doesn't matter if section doesn't exist)
+			if _, err := UseEnvironment(f, cfg.Get("environment")); err != nil {
+				problems = append(problems, fmt.Errorf("Ignoring global option file %s due to environment inheritance error: %s", f.Path(), err))
+				continue
+			}
+		}
+		if !strings.HasSuffix(path, ".my.cnf") {
+			included, err := IncludedFiles(f, cfg)
+			if err != nil {
+				problems = append(problems, fmt.Errorf("Ignoring global option file %s due to include error: %s", f.Path(), err))
+				continue
+			}
+			files = append(files, included...)
 		}
 
-		cfg.AddSource(f)
+		files = append(files, f)
 	}
+	return files, problems
 }
 
+// myCnfSections lists the sections of ~/.my.cnf which are used for Skeema's
+// configuration, in order of decreasing precedence.
+var myCnfSections = []string{"skeema", "client", "mysql"}
+
 // ProcessSpecialGlobalOptions performs special handling of global options with
 // unusual semantics -- handling restricted placement of host and schema;
-// obtaining a password from MYSQL_PWD or STDIN; enable debug logging.
+// obtaining a password from MYSQL_PWD or STDIN; setting permission modes for
+// created dirs and files; enable debug logging.
 func ProcessSpecialGlobalOptions(cfg *mybase.Config) error {
 	// The host and schema options are special -- most commands only expect
 	// to find them when recursively crawling directory configs. So if these
@@ -105,7 +172,20 @@ func ProcessSpecialGlobalOptions(cfg *mybase.Config) error {
 	cmdSuite := cfg.CLI.Command.Root()
 	for _, name := range []string{"host", "schema"} {
 		if cfg.Changed(name) && cfg.FindOption(name) == cmdSuite.Options()[name] {
-			return fmt.Errorf("Option %s cannot be set via %s for this command", name, cfg.Source(name))
+			return fmt.Errorf("Option %s cannot be set via %s for this command", name, SourceDescription(cfg, name))
+		}
+	}
+
+	// The extends option is only meaningful within an environment section of an
+	// option file, aside from commands such as add-environment which override it
+	if cfg.OnCLI("extends") && cfg.FindOption("extends") == cmdSuite.Options()["extends"] {
+		return errors.New("Option extends may only be used within an environment section of an option file")
+	}
+
+	// The include options are only meaningful within an option file
+	for _, name := range []string{"include", "include-optional"} {
+		if cfg.OnCLI(name) {
+			return fmt.Errorf("Option %s may only be used within an option file", name)
 		}
 	}
 
@@ -127,6 +207,16 @@ func ProcessSpecialGlobalOptions(cfg *mybase.Config) error {
 		}
 	}
 
+	dirMode, err := ParseMode("dir-mode", cfg.Get("dir-mode"), 0700)
+	if err != nil {
+		return err
+	}
+	fileMode, err := ParseMode("file-mode", cfg.Get("file-mode"), 0600)
+	if err != nil {
+		return err
+	}
+	DirMode, FileMode = dirMode, fileMode
+
 	if cfg.GetBool("debug") {
 		log.SetLevel(log.DebugLevel)
 	}
@@ -224,32 +314,39 @@ func parseConnectOptions(input string) (map[string]string, error) {
 	return result, err
 }
 
+// driverParams lists the lowercased names of all go-sql-driver/mysql special
+// params, which configure the driver rather than setting session variables.
+var driverParams = map[string]bool{
+	"allowallfiles":           true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"allowcleartextpasswords": true,
+	"allownativepasswords":    true,
+	"allowoldpasswords":       true,
+	"charset":                 true,
+	"clientfoundrows":         true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"collation":               true,
+	"columnswithalias":        true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"interpolateparams":       true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"loc":                     true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"maxallowedpacket":        true,
+	"multistatements":         true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"parsetime":               true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"readtimeout":             true,
+	"strict":                  true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
+	"timeout":                 true,
+	"tls":                     true,
+	"writetimeout":            true,
+}
+
+// IsDriverParam returns true if name is a go-sql-driver/mysql special param,
+// rather than a session variable. The comparison is case-insensitive.
+func IsDriverParam(name string) bool {
+	return driverParams[strings.ToLower(name)]
+}
+
 // RealConnectOptions takes a comma-separated string of connection options,
 // strips any Go driver-specific ones, and then returns the new string which
 // is now suitable for passing to an external tool.
 func RealConnectOptions(connectOpts string) (string, error) {
-	// list of lowercased versions of all go-sql-driver/mysql special params
-	ignored := map[string]bool{
-		"allowallfiles":           true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"allowcleartextpasswords": true,
-		"allownativepasswords":    true,
-		"allowoldpasswords":       true,
-		"charset":                 true,
-		"clientfoundrows":         true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"collation":               true,
-		"columnswithalias":        true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"interpolateparams":       true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"loc":                     true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"maxallowedpacket":        true,
-		"multistatements":         true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"parsetime":               true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"readtimeout":             true,
-		"strict":                  true, // banned in Dir.InstanceDefaultParams, listed here for sake of completeness
-		"timeout":                 true,
-		"tls":                     true,
-		"writetimeout":            true,
-	}
-
 	options, err := SplitConnectOptions(connectOpts)
 	if err != nil {
 		return "", err
@@ -259,7 +356,7 @@ func RealConnectOptions(connectOpts string) (string, error) {
 	// This is done via regular expressions substitution in order to keep the
 	// string in its original order.
 	for name, value := range options {
-		if ignored[strings.ToLower(name)] {
+		if IsDriverParam(name) {
 			re, err := regexp.Compile(fmt.Sprintf(`%s=%s(,|$)`, regexp.QuoteMeta(name), regexp.QuoteMeta(value)))
 			if err != nil {
 				return "", err
@@ -272,3 +369,21 @@ func RealConnectOptions(connectOpts string) (string, error) {
 	}
 	return connectOpts, nil
 }
+
+// ParseTimeout converts the supplied option value to a time.Duration. The
+// value may be any string accepted by time.ParseDuration, such as "90s" or
+// "5m", or a bare integer number of seconds. A value of 0 indicates no
+// timeout.
+func ParseTimeout(optionName, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		var seconds int
+		if seconds, err = strconv.Atoi(value); err == nil {
+			d = time.Duration(seconds) * time.Second
+		}
+	}
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Option %s must be a duration such as \"30s\" or \"5m\"; instead found \"%s\"", optionName, value)
+	}
+	return d, nil
+}
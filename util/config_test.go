@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/skeema/mybase"
 )
@@ -228,3 +229,26 @@ func TestRealConnectOptions(t *testing.T) {
 		t.Error("Expected error from SplitConnectOptions to be passed through to RealConnectOptions, but err is nil")
 	}
 }
+
+func TestParseTimeout(t *testing.T) {
+	expected := map[string]time.Duration{
+		"0":     0,
+		"0s":    0,
+		"30":    30 * time.Second,
+		"1.5s":  1500 * time.Millisecond,
+		"5m":    5 * time.Minute,
+		"250ms": 250 * time.Millisecond,
+	}
+	for input, expectDuration := range expected {
+		if actual, err := ParseTimeout("query-timeout", input); err != nil {
+			t.Errorf("Unexpected error from ParseTimeout(%q): %s", input, err)
+		} else if actual != expectDuration {
+			t.Errorf("Expected ParseTimeout(%q) to return %s, instead found %s", input, expectDuration, actual)
+		}
+	}
+	for _, input := range []string{"", "-5s", "-1", "soon", "5 minutes"} {
+		if _, err := ParseTimeout("query-timeout", input); err == nil {
+			t.Errorf("Expected ParseTimeout(%q) to return an error, but it did not", input)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/skeema/mybase"
+)
+
+// protocolMismatchErrors are errors returned by the driver when the server's
+// initial handshake was not a classic MySQL protocol handshake.
+var protocolMismatchErrors = []error{
+	mysql.ErrMalformPkt,
+	mysql.ErrOldProtocol,
+	mysql.ErrPktSync,
+	mysql.ErrPktSyncMul,
+}
+
+// IsProtocolMismatch returns true if err indicates that the server at the other
+// end of a connection attempt did not speak the classic MySQL client/server
+// protocol. This typically means the port belongs to the MySQL X protocol, a
+// proxy speaking some other protocol, or a non-database service entirely.
+func IsProtocolMismatch(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, mismatchErr := range protocolMismatchErrors {
+		if err == mismatchErr {
+			return true
+		}
+	}
+	return strings.HasPrefix(err.Error(), "unsupported protocol version")
+}
+
+// ExplainConnectError returns err as-is, unless it indicates a protocol
+// mismatch, in which case the returned error also suggests likely causes.
+// port is the port that the failed connection attempt used.
+func ExplainConnectError(err error, port int) error {
+	if !IsProtocolMismatch(err) {
+		return err
+	}
+	return fmt.Errorf("%s (the server on port %d did not respond with a MySQL protocol handshake; this port may be serving the MySQL X protocol, which typically uses port 33060, or a proxy speaking a different protocol)", err, port)
+}
+
+// FallbackPorts returns the ports listed in the fallback-ports option, in
+// order. An error is returned if any value is not a valid port number.
+func FallbackPorts(cfg *mybase.Config) ([]int, error) {
+	if cfg.FindOption("fallback-ports") == nil {
+		return nil, nil
+	}
+	values := cfg.GetSlice("fallback-ports", ',', true)
+	ports := make([]int, 0, len(values))
+	for _, value := range values {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("Option fallback-ports contains invalid port %q", value)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/skeema/mybase"
+)
+
+func TestExplainConnectError(t *testing.T) {
+	for _, err := range []error{mysql.ErrMalformPkt, mysql.ErrOldProtocol, mysql.ErrPktSync, errors.New("unsupported protocol version 11")} {
+		if !IsProtocolMismatch(err) {
+			t.Errorf("Expected %q to be considered a protocol mismatch, but it was not", err)
+		}
+		if explained := ExplainConnectError(err, 33060); !strings.Contains(explained.Error(), "port 33060") || !strings.HasPrefix(explained.Error(), err.Error()) {
+			t.Errorf("Unexpected explanation for %q: %s", err, explained)
+		}
+	}
+	err := errors.New("dial tcp 127.0.0.1:3306: connect: connection refused")
+	if IsProtocolMismatch(err) || IsProtocolMismatch(nil) {
+		t.Error("IsProtocolMismatch returned unexpected true")
+	}
+	if explained := ExplainConnectError(err, 3306); explained != err {
+		t.Errorf("Expected non-mismatch error to be returned as-is, instead found %s", explained)
+	}
+}
+
+func TestFallbackPorts(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmdSuite.AddSubCommand(cmd)
+
+	cases := map[string][]int{
+		"":                  {},
+		"3307":              {3307},
+		"'3307, 6033,3306'": {3307, 6033, 3306},
+	}
+	for value, expected := range cases {
+		cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff --fallback-ports="+value)
+		if ports, err := FallbackPorts(cfg); err != nil || !reflect.DeepEqual(ports, expected) {
+			t.Errorf("Unexpected result from FallbackPorts for %q: %v, %v", value, ports, err)
+		}
+	}
+	for _, value := range []string{"abc", "3307,0", "70000"} {
+		cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff --fallback-ports="+value)
+		if _, err := FallbackPorts(cfg); err == nil {
+			t.Errorf("Expected error from FallbackPorts for %q, but err was nil", value)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/skeema/mybase"
+)
+
+// EncryptedFileSuffix is appended to an option file's name to obtain the name
+// of its encrypted companion file, for example .skeema.enc alongside .skeema.
+// Such a file typically holds only sensitive options, such as password.
+const EncryptedFileSuffix = ".enc"
+
+// decryptedCache maps encrypted file paths to their decrypted contents, so that
+// each encrypted file is only decrypted once, even though parent option files
+// are re-added for every subdirectory. Long-running processes should call
+// ForgetDecryptedFiles whenever encrypted files may have changed.
+var decryptedCache struct {
+	sync.Mutex
+	contents map[string]string
+}
+
+func init() {
+	decryptedCache.contents = make(map[string]string)
+}
+
+// ForgetDecryptedFiles removes all decrypted contents from the cache, so that
+// subsequent calls to EncryptedOptionFile re-read and re-decrypt each encrypted
+// file.
+func ForgetDecryptedFiles() {
+	decryptedCache.Lock()
+	defer decryptedCache.Unlock()
+	decryptedCache.contents = make(map[string]string)
+}
+
+// EncryptedOptionFile returns the encrypted companion of option file f, if one
+// exists, decrypted in memory and parsed using cfg. A nil File and nil error
+// are returned if no companion file exists. Decryption is performed by
+// shelling out to the decrypt-command option's value from cfg, which must read
+// the encrypted contents from STDIN and write the decrypted ini-style contents
+// to STDOUT. The decrypted contents are never written to disk, and are never
+// included in errors or log messages. Comments must appear on their own lines
+// in the decrypted contents. The returned File has the sections for the
+// environment in cfg selected.
+func EncryptedOptionFile(f *mybase.File, cfg *mybase.Config) (*mybase.File, error) {
+	encPath := f.Path() + EncryptedFileSuffix
+	contents, err := decryptFile(encPath, cfg)
+	if err != nil || contents == "" {
+		return nil, err
+	}
+
+	// mybase can only parse files from disk, so parse an empty file to obtain a
+	// usable File, and then populate its values directly
+	enc := mybase.NewFile(os.DevNull)
+	if err := enc.Parse(cfg); err != nil {
+		return nil, err
+	}
+	enc.Dir, enc.Name = filepath.Dir(encPath), filepath.Base(encPath)
+	var section string
+	for n, line := range strings.Split(contents, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		} else if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("Parse error in %s line %d: unterminated section name", encPath, n+1)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, hasValue, loose := mybase.NormalizeOptionToken(line)
+		opt := cfg.FindOption(key)
+		if opt == nil {
+			if loose || cfg.LooseFileOptions {
+				continue
+			}
+			return nil, mybase.OptionNotDefinedError{Name: key, Source: fmt.Sprintf("%s line %d", encPath, n+1)}
+		}
+		if !hasValue {
+			if opt.RequireValue {
+				return nil, mybase.OptionMissingValueError{Name: opt.Name, Source: fmt.Sprintf("%s line %d", encPath, n+1)}
+			} else if opt.Type == mybase.OptionTypeBool {
+				value = "1"
+			}
+		} else if value == "" && opt.Type == mybase.OptionTypeString {
+			value = "''"
+		}
+		enc.SetOptionValue(section, opt.Name, value)
+	}
+	if cfg.CLI.Command.HasArg("environment") {
+		if _, err := UseEnvironment(enc, cfg.Get("environment")); err != nil {
+			return nil, err
+		}
+	}
+	return enc, nil
+}
+
+// decryptFile returns the decrypted contents of the encrypted file at encPath,
+// or an empty string if no such file exists.
+func decryptFile(encPath string, cfg *mybase.Config) (string, error) {
+	decryptedCache.Lock()
+	defer decryptedCache.Unlock()
+	if contents, ok := decryptedCache.contents[encPath]; ok {
+		return contents, nil
+	}
+	raw, err := ioutil.ReadFile(encPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("Unable to read encrypted option file %s: %s", encPath, err)
+	}
+	command := cfg.Get("decrypt-command")
+	if command == "" {
+		return "", fmt.Errorf("Encrypted option file %s exists, but the decrypt-command option is not set", encPath)
+	}
+	s := &ShellOut{
+		Command: command,
+		Dir:     filepath.Dir(encPath),
+		Stdin:   bytes.NewReader(raw), // even if empty, never fall back to our own STDIN
+	}
+	contents, err := s.RunCapture()
+	if err != nil {
+		// Intentionally omit the command's output from the error, since any partial
+		// output may contain sensitive values
+		return "", fmt.Errorf("Unable to decrypt encrypted option file %s using decrypt-command: %s", encPath, err)
+	}
+	decryptedCache.contents[encPath] = contents
+	return contents, nil
+}
+
+// CheckEncryptedOptions returns an error if the encrypted companion of option
+// file f sets any of the supplied option names in any section. Commands which
+// write option values to f should call this first, since Skeema never writes to
+// encrypted files, and any value written to f would be overridden by the
+// encrypted file's value anyway.
+func CheckEncryptedOptions(f *mybase.File, cfg *mybase.Config, names ...string) error {
+	enc, err := EncryptedOptionFile(f, cfg)
+	if err != nil || enc == nil {
+		return err
+	}
+	var found []string
+	for _, name := range names {
+		if enc.SomeSectionHasOption(name) {
+			found = append(found, name)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	sort.Strings(found)
+	verb, noun := "are", "these options"
+	if len(found) == 1 {
+		verb, noun = "is", "this option"
+	}
+	return fmt.Errorf("Unable to update %s: %s %s set in encrypted option file %s, which Skeema does not modify. Edit the encrypted file using your encryption tooling instead, or move %s out of it", f.Path(), strings.Join(found, ", "), verb, enc.Path(), noun)
+}
@@ -0,0 +1,140 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+func TestEncryptedOptionFile(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmd.AddArg("environment", "production", false)
+	cmdSuite.AddSubCommand(cmd)
+
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	writeFile := func(name, contents string) string {
+		t.Helper()
+		filePath := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+			t.Fatalf("Unable to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		return filePath
+	}
+	addFile := func(cfg *mybase.Config, filePath string) (*mybase.File, error) {
+		t.Helper()
+		f := mybase.NewFile(filePath)
+		if err := f.Parse(cfg); err != nil {
+			t.Fatalf("Unexpected error from Parse: %s", err)
+		}
+		if _, err := UseEnvironment(f, cfg.Get("environment")); err != nil {
+			t.Fatalf("Unexpected error from UseEnvironment: %s", err)
+		}
+		return f, AddOptionFile(cfg, f)
+	}
+
+	// The "encryption" here is rot13, with the decrypt-command set in the plain
+	// option file itself. Values from the encrypted file take precedence, and its
+	// environment sections apply.
+	plainFile := writeFile("ok/.skeema", "decrypt-command=\"tr a-z n-za-m\"\nuser=app\npassword=wrong\n")
+	encFile := writeFile("ok/.skeema.enc", "cnffjbeq=frperg\n# pbzzrag\n[cebqhpgvba]\nffu-hfre=onfgvba\n")
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	f, err := addFile(cfg, plainFile)
+	if err != nil {
+		t.Fatalf("Unexpected error from AddOptionFile: %s", err)
+	}
+	if cfg.Get("user") != "app" || cfg.Get("password") != "secret" || cfg.Get("ssh-user") != "bastion" {
+		t.Errorf("Unexpected option values: user=%s password=%s ssh-user=%s", cfg.Get("user"), cfg.Get("password"), cfg.Get("ssh-user"))
+	}
+	if source := cfg.Source("password").(*mybase.File).Path(); source != encFile {
+		t.Errorf("Expected password to come from %s, instead found %s", encFile, source)
+	}
+	if err := CheckEncryptedOptions(f, cfg, "host", "ssh-user"); err == nil || !strings.Contains(err.Error(), "ssh-user is set in encrypted option file "+encFile) {
+		t.Errorf("Unexpected error from CheckEncryptedOptions: %v", err)
+	}
+	if err := CheckEncryptedOptions(f, cfg, "host", "port"); err != nil {
+		t.Errorf("Unexpected error from CheckEncryptedOptions: %v", err)
+	}
+
+	// Missing decrypt-command is an error naming the encrypted file
+	plainFile = writeFile("nocmd/.skeema", "user=app\n")
+	encFile = writeFile("nocmd/.skeema.enc", "cnffjbeq=frperg\n")
+	if _, err := addFile(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff"), plainFile); err == nil || !strings.Contains(err.Error(), encFile) {
+		t.Errorf("Expected error for missing decrypt-command, instead found %v", err)
+	}
+
+	// Decryption failures name the file, but never include the command's output
+	plainFile = writeFile("fail/.skeema", "decrypt-command=\"cat; exit 1\"\n")
+	encFile = writeFile("fail/.skeema.enc", "password=secret\n")
+	if _, err := addFile(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff"), plainFile); err == nil || !strings.Contains(err.Error(), encFile) || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Unexpected error for failed decryption: %v", err)
+	}
+
+	// Decrypted contents must be valid, but errors do not echo values
+	plainFile = writeFile("invalid/.skeema", "decrypt-command=cat\n")
+	writeFile("invalid/.skeema.enc", "password=secret\nnot-a-real-option=secret\n")
+	if _, err := addFile(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff"), plainFile); err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Unexpected error for invalid decrypted contents: %v", err)
+	}
+
+	// An empty encrypted file is still supplied to decrypt-command as empty STDIN,
+	// rather than the command reading our own STDIN
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to create pipe: %s", err)
+	}
+	pw.WriteString("password=fromstdin\n")
+	pw.Close()
+	origStdin := os.Stdin
+	os.Stdin = pr
+	plainFile = writeFile("empty/.skeema", "decrypt-command=cat\npassword=plain\n")
+	writeFile("empty/.skeema.enc", "")
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	_, err = addFile(cfg, plainFile)
+	os.Stdin = origStdin
+	pr.Close()
+	if err != nil {
+		t.Fatalf("Unexpected error from AddOptionFile: %s", err)
+	} else if cfg.Get("password") != "plain" {
+		t.Errorf("Expected empty encrypted file to leave password unchanged, instead found %q", cfg.Get("password"))
+	}
+
+	// Decrypted contents are cached until ForgetDecryptedFiles is called
+	plainFile = writeFile("cached/.skeema", "decrypt-command=cat\n")
+	writeFile("cached/.skeema.enc", "password=first\n")
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if _, err = addFile(cfg, plainFile); err != nil || cfg.Get("password") != "first" {
+		t.Fatalf("Unexpected result from AddOptionFile: password=%s, err=%v", cfg.Get("password"), err)
+	}
+	writeFile("cached/.skeema.enc", "password=second\n")
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if _, err = addFile(cfg, plainFile); err != nil || cfg.Get("password") != "first" {
+		t.Errorf("Expected cached password to be used, instead found password=%s, err=%v", cfg.Get("password"), err)
+	}
+	ForgetDecryptedFiles()
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if _, err = addFile(cfg, plainFile); err != nil || cfg.Get("password") != "second" {
+		t.Errorf("Expected re-decrypted password after ForgetDecryptedFiles, instead found password=%s, err=%v", cfg.Get("password"), err)
+	}
+
+	// No companion file: nothing extra is added
+	plainFile = writeFile("none/.skeema", "user=app\n")
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if f, err = addFile(cfg, plainFile); err != nil {
+		t.Fatalf("Unexpected error from AddOptionFile: %s", err)
+	} else if enc, err := EncryptedOptionFile(f, cfg); enc != nil || err != nil {
+		t.Errorf("Expected nil File and nil error, instead found %v, %v", enc, err)
+	}
+}
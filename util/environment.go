@@ -0,0 +1,391 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+)
+
+// UseEnvironment selects the sections of option file f which apply to the
+// supplied environment, and returns their names in order of decreasing
+// precedence. The environment's own section is first, followed by any sections
+// it inherits from via the "extends" option, recursively. The file's top
+// section (options prior to any section header) is not included in the
+// returned chain, but always applies at lowest precedence. It is not an error
+// for f to lack a section for the environment.
+// An error is returned if any section of f extends an undefined section, if any
+// sections extend each other circularly, or if extends is used in the top
+// section. Inheritance only applies within f, so sections from other option
+// files cannot be extended. Calling this function repeatedly for the same
+// environment has no additional effect.
+func UseEnvironment(f *mybase.File, environment string) ([]string, error) {
+	extends := make(map[string]string)
+	for _, section := range f.SectionsWithOption("extends") {
+		if section == "" {
+			return nil, fmt.Errorf("%s: option extends may only be used within an environment section", f.Path())
+		}
+		_ = f.UseSection(section)
+		value, _ := f.OptionValue("extends")
+		extends[section] = strings.TrimSpace(unquote(value))
+	}
+
+	// Validate the inheritance of every section, not just this environment's, so
+	// that problems are reported regardless of which environment is in use
+	sections := make([]string, 0, len(extends)+1)
+	for section := range extends {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+	sections = append(sections, environment)
+	var chain []string
+	for _, section := range sections {
+		chain = []string{section}
+		for current := section; extends[current] != ""; current = extends[current] {
+			parent := extends[current]
+			for _, seen := range chain {
+				if seen == parent {
+					return nil, fmt.Errorf("%s: circular environment inheritance: %s", f.Path(), strings.Join(append(chain, parent), " ← "))
+				}
+			}
+			if !f.HasSection(parent) {
+				return nil, fmt.Errorf("%s: environment [%s] extends undefined environment [%s]", f.Path(), current, parent)
+			}
+			chain = append(chain, parent)
+		}
+	}
+
+	if len(chain) > 1 {
+		log.Debugf("%s: environment %s", f.Path(), FormatEnvironmentChain(chain))
+	}
+	_ = f.UseSection(chain...) // we don't care if the section doesn't exist
+	return chain, nil
+}
+
+// FormatEnvironmentChain returns a human-readable description of chain, as
+// returned by UseEnvironment, for example "staging ← production ← defaults".
+// The top section of the file is described as "defaults".
+func FormatEnvironmentChain(chain []string) string {
+	return strings.Join(append(append([]string{}, chain...), "defaults"), " ← ")
+}
+
+// SourceDescription returns a description of where option name's value in cfg
+// came from. This is typically just the source's String(). However, if the
+// value came from an option file using environment inheritance, the
+// description also indicates which section supplied the value, along with the
+// full inheritance chain, for example "/path/.skeema [production] (staging ←
+// production ← defaults)". For list options accumulated from multiple option
+// files, the description lists each element along with its source.
+func SourceDescription(cfg *mybase.Config, name string) string {
+	source := cfg.Source(name)
+	if ls, ok := source.(*listSource); ok {
+		elements := ListElements(cfg, name)
+		if len(elements) == 0 {
+			// All elements were cleared using ListReset
+			for n := len(ls.sources) - 1; n >= 0; n-- {
+				if _, ok := ls.sources[n].OptionValue(name); ok {
+					return describeSource(cfg, ls.sources[n], name)
+				}
+			}
+		}
+		descriptions := make([]string, len(elements))
+		for n, elem := range elements {
+			descriptions[n] = fmt.Sprintf("%s from %s", elem.Value, describeSource(cfg, elem.Source, name))
+		}
+		return strings.Join(descriptions, "; ")
+	}
+	return describeSource(cfg, source, name)
+}
+
+// describeSource returns a description of source, which supplies option name
+// in cfg, as described by SourceDescription.
+func describeSource(cfg *mybase.Config, source mybase.OptionValuer, name string) string {
+	if dsf, ok := source.(*DirSectionFile); ok {
+		if section, ok := dsf.Sections[name]; ok {
+			return fmt.Sprintf("%s [%s]", dsf.File, section)
+		}
+		source = dsf.File
+	}
+	f, ok := source.(*mybase.File)
+	if !ok || !cfg.CLI.Command.HasArg("environment") || strings.HasSuffix(f.Name, ".my.cnf") {
+		return fmt.Sprint(source)
+	}
+	chain, err := UseEnvironment(f, cfg.Get("environment"))
+	if err != nil || len(chain) < 2 {
+		return f.String()
+	}
+	where := "defaults"
+	setBy := make(map[string]bool)
+	for _, section := range f.SectionsWithOption(name) {
+		setBy[section] = true
+	}
+	for _, section := range chain {
+		if setBy[section] {
+			where = "[" + section + "]"
+			break
+		}
+	}
+	return fmt.Sprintf("%s %s (%s)", f, where, FormatEnvironmentChain(chain))
+}
+
+// unquote removes a matching pair of surrounding single or double quotes from
+// input, if present.
+func unquote(input string) string {
+	if len(input) >= 2 && (input[0] == '"' || input[0] == '\'') && input[len(input)-1] == input[0] {
+		return input[1 : len(input)-1]
+	}
+	return input
+}
+
+// EnvironmentSections selects the sections of option file f which apply to
+// the supplied environment, and returns the names of those which exist in f,
+// in order of decreasing precedence. The file's top section is not included in
+// the result, but always applies at lowest precedence. For .my.cnf files, a
+// fixed list of sections is used regardless of environment. Any environment
+// inheritance error is ignored, since UseEnvironment was already called on f
+// when it was originally parsed, which would have reported the error.
+func EnvironmentSections(f *mybase.File, environment string) []string {
+	chain := myCnfSections
+	if !strings.HasSuffix(f.Name, ".my.cnf") {
+		chain, _ = UseEnvironment(f, environment)
+	}
+	sections := []string{}
+	for _, section := range chain {
+		if f.HasSection(section) {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// DirSectionPrefix is the prefix of option file section names which only apply
+// to subdirectories matching a pattern. For example, options in a section
+// [dir:shard_*] apply to each subdirectory of the option file's directory with
+// a name beginning with "shard_", as well as all of their subdirectories.
+const DirSectionPrefix = "dir:"
+
+// CheckDirSection returns an error if section is a dir-pattern section with a
+// blank or malformed pattern.
+func CheckDirSection(f *mybase.File, section string) error {
+	if !strings.HasPrefix(section, DirSectionPrefix) {
+		return nil
+	}
+	pattern := strings.TrimPrefix(section, DirSectionPrefix)
+	if _, err := path.Match(pattern, ""); err != nil || strings.Trim(pattern, "/") == "" || path.IsAbs(pattern) {
+		return fmt.Errorf("%s: section [%s] has an invalid directory pattern", f.Path(), section)
+	}
+	return nil
+}
+
+// DirSectionFile is an option source which supplies the values of an already
+// parsed option file, except that Values, obtained from the file's dir-pattern
+// sections, take precedence over the file's own values.
+type DirSectionFile struct {
+	*mybase.File
+	Values   map[string]string // option name -> value from a dir-pattern section
+	Sections map[string]string // option name -> name of the section supplying Values[name]
+}
+
+// OptionValue returns the value of option name from Values if present, or
+// from the underlying option file otherwise.
+func (dsf *DirSectionFile) OptionValue(name string) (string, bool) {
+	if value, ok := dsf.Values[name]; ok {
+		return value, true
+	}
+	return dsf.File.OptionValue(name)
+}
+
+// MatchingDirSections returns the subset of sections which are dir-pattern
+// sections applying to the directory at relPath, a slash-separated path
+// relative to the option file's directory. A pattern applies to each directory
+// matching it, as well as all of their descendants, but never to the option
+// file's own directory. The result is ordered by decreasing precedence:
+// patterns with more path components take precedence over ones with fewer,
+// followed by patterns with more non-wildcard characters, followed by
+// sections appearing later in the file.
+func MatchingDirSections(sections []string, relPath string) []string {
+	relPath = path.Clean(relPath)
+	if relPath == "." || relPath == ".." || strings.HasPrefix(relPath, "../") || path.IsAbs(relPath) {
+		return nil
+	}
+	components := strings.Split(relPath, "/")
+	var matches []string
+	for n := len(sections) - 1; n >= 0; n-- {
+		if !strings.HasPrefix(sections[n], DirSectionPrefix) {
+			continue
+		}
+		pattern := strings.Trim(strings.TrimPrefix(sections[n], DirSectionPrefix), "/")
+		depth := strings.Count(pattern, "/") + 1
+		if pattern == "" || depth > len(components) {
+			continue
+		}
+		if ok, _ := path.Match(pattern, strings.Join(components[:depth], "/")); ok {
+			matches = append(matches, sections[n])
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		pi, pj := strings.TrimPrefix(matches[i], DirSectionPrefix), strings.TrimPrefix(matches[j], DirSectionPrefix)
+		if di, dj := strings.Count(strings.Trim(pi, "/"), "/"), strings.Count(strings.Trim(pj, "/"), "/"); di != dj {
+			return di > dj
+		}
+		return literalLength(pi) > literalLength(pj)
+	})
+	return matches
+}
+
+// literalLength returns the number of characters in pattern which are not
+// wildcards or part of a character class.
+func literalLength(pattern string) (length int) {
+	var inClass, escapeNext bool
+	for _, c := range pattern {
+		if escapeNext {
+			escapeNext = false
+			length++
+		} else if inClass {
+			inClass = (c != ']')
+		} else if c == '\\' {
+			escapeNext = true
+		} else if c == '[' {
+			inClass = true
+		} else if c != '*' && c != '?' && c != '/' {
+			length++
+		}
+	}
+	return length
+}
+
+// OptionSetting describes a single source which sets an option's value.
+type OptionSetting struct {
+	Source  string `json:"source"`            // option file path, "command-line", or "default"
+	Section string `json:"section,omitempty"` // option file section, or blank for the top section
+	Line    int    `json:"line,omitempty"`    // line number in option file, or 0 if not applicable
+	Value   string `json:"value"`
+}
+
+// String returns a description of the setting's location, for example
+// "/path/.skeema:4 [production]".
+func (setting OptionSetting) String() string {
+	where := setting.Source
+	if setting.Line > 0 {
+		where = fmt.Sprintf("%s:%d", where, setting.Line)
+	}
+	if setting.Section != "" {
+		where = fmt.Sprintf("%s [%s]", where, setting.Section)
+	}
+	return where
+}
+
+// OptionResolution describes how an option's effective value was determined.
+type OptionResolution struct {
+	Name     string          `json:"name"`
+	Value    string          `json:"value"`
+	Settings []OptionSetting `json:"settings"` // every source setting the option, in order of decreasing precedence, ending with the default
+}
+
+// ResolveOption determines the effective value of option name, along with
+// every source which sets it, including each applicable section of an option
+// file which sets it. The supplied files must be ordered from lowest
+// to highest precedence, in the same manner as they are added to a
+// mybase.Config, and should already be parsed. Sections of each file are
+// selected for the supplied environment, or using the fixed list of sections
+// for .my.cnf files, followed by any dir-pattern sections applying to the
+// directory at dirPath. The supplied cli may be nil. If no source sets the
+// option, defaultValue is its effective value. For list options, the effective
+// value accumulates the values of all files setting the option, unless the
+// option is set on the command-line.
+func ResolveOption(name, defaultValue string, cli *mybase.CommandLine, files []*mybase.File, environment, dirPath string) OptionResolution {
+	var settings []OptionSetting
+	var onCLI bool
+	if cli != nil {
+		if value, ok := cli.OptionValue(name); ok {
+			settings = append(settings, OptionSetting{Source: "command-line", Value: value})
+			onCLI = true
+		}
+	}
+	var fileValues []mybase.OptionValuer // effective value of each file setting the option, in order of decreasing precedence
+	for n := len(files) - 1; n >= 0; n-- {
+		f := files[n]
+		chain := EnvironmentSections(f, environment)
+		setBy := make(map[string]bool)
+		for _, section := range f.SectionsWithOption(name) {
+			setBy[section] = true
+		}
+		sections := append([]string{}, chain...)
+		if rel, err := filepath.Rel(f.Dir, dirPath); dirPath != "" && err == nil {
+			sections = append(sections, MatchingDirSections(f.SectionsWithOption(name), filepath.ToSlash(rel))...)
+		}
+		for _, section := range append(sections, "") {
+			if !setBy[section] {
+				continue
+			}
+			_ = f.UseSection(section)
+			value, _ := f.OptionValue(name)
+			if len(fileValues) == 0 || fileValues[len(fileValues)-1].(fileValue).path != f.Path() {
+				fileValues = append(fileValues, fileValue{path: f.Path(), value: value})
+			}
+			settings = append(settings, OptionSetting{
+				Source:  f.Path(),
+				Section: section,
+				Line:    optionLine(f.Path(), section, name),
+				Value:   value,
+			})
+		}
+		_ = f.UseSection(chain...) // restore the selection for environment
+	}
+	settings = append(settings, OptionSetting{Source: "default", Value: defaultValue})
+	resolution := OptionResolution{
+		Name:     name,
+		Value:    settings[0].Value,
+		Settings: settings,
+	}
+	if IsListOption(name) && !onCLI && len(fileValues) > 1 {
+		for i, j := 0, len(fileValues)-1; i < j; i, j = i+1, j-1 {
+			fileValues[i], fileValues[j] = fileValues[j], fileValues[i]
+		}
+		ls := &listSource{sources: fileValues}
+		resolution.Value, _ = ls.OptionValue(name)
+	}
+	return resolution
+}
+
+// fileValue is an OptionValuer supplying a single option file's effective
+// value for an option, used by ResolveOption in accumulating list options.
+type fileValue struct {
+	path  string
+	value string
+}
+
+// OptionValue satisfies the mybase.OptionValuer interface. Since a fileValue
+// is only created for a specific option, the supplied name is ignored.
+func (fv fileValue) OptionValue(_ string) (string, bool) {
+	return fv.value, true
+}
+
+// optionLine returns the line number of the last line of the option file at
+// filePath which sets option name within the named section, or 0 if no such
+// line exists or the file cannot be read.
+func optionLine(filePath, section, name string) (lineNo int) {
+	contents, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return 0
+	}
+	var currentSection string
+	for n, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		} else if line[0] == '[' {
+			if end := strings.Index(line, "]"); end > 0 {
+				currentSection = line[1:end]
+			}
+		} else if key, _, _, _ := mybase.NormalizeOptionToken(line); key == name && currentSection == section {
+			lineNo = n + 1
+		}
+	}
+	return lineNo
+}
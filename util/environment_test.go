@@ -0,0 +1,179 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+func TestUseEnvironment(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmd.AddArg("environment", "production", false)
+	cmdSuite.AddSubCommand(cmd)
+
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	parseFile := func(contents string) *mybase.File {
+		t.Helper()
+		filePath := filepath.Join(tempDir, ".skeema")
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		f := mybase.NewFile(filePath)
+		if err := f.Parse(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")); err != nil {
+			t.Fatalf("Unexpected error from Parse: %s", err)
+		}
+		return f
+	}
+
+	contents := "user=root\nschema=product\n\n[production]\nhost=prod.invalid\nuser=app\nconnect-options='wait_timeout=60'\n\n[staging]\nextends=production\nhost=staging.invalid\n\n[qa]\nextends='staging'\n"
+	f := parseFile(contents)
+	expected := map[string][]string{
+		"production":  {"production"},
+		"staging":     {"staging", "production"},
+		"qa":          {"qa", "staging", "production"},
+		"development": {"development"},
+	}
+	for environment, expectedChain := range expected {
+		chain, err := UseEnvironment(f, environment)
+		if err != nil {
+			t.Fatalf("Unexpected error from UseEnvironment(%q): %s", environment, err)
+		}
+		if !reflect.DeepEqual(chain, expectedChain) {
+			t.Errorf("Expected UseEnvironment(%q) to return %v, instead found %v", environment, expectedChain, chain)
+		}
+	}
+	if _, err := UseEnvironment(f, "qa"); err != nil {
+		t.Fatalf("Unexpected error from UseEnvironment: %s", err)
+	}
+	for option, expectedValue := range map[string]string{"host": "staging.invalid", "user": "app", "schema": "product", "connect-options": "'wait_timeout=60'"} {
+		if value, _ := f.OptionValue(option); value != expectedValue {
+			t.Errorf("Expected %s to be %q, instead found %q", option, expectedValue, value)
+		}
+	}
+	if actual := FormatEnvironmentChain([]string{"qa", "staging", "production"}); actual != "qa ← staging ← production ← defaults" {
+		t.Errorf("Unexpected result from FormatEnvironmentChain: %s", actual)
+	}
+
+	// Source descriptions should indicate which section supplied a value
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff staging")
+	cfg.AddSource(f)
+	for option, expectedSection := range map[string]string{"host": "[staging]", "user": "[production]", "schema": "defaults"} {
+		if actual := SourceDescription(cfg, option); !strings.Contains(actual, expectedSection+" (staging ← production ← defaults)") {
+			t.Errorf("Unexpected source description for %s: %s", option, actual)
+		}
+	}
+
+	// Cycles, undefined sections, and use of extends outside of a section are
+	// errors, regardless of which environment is selected
+	badContents := []string{
+		"[production]\nextends=staging\n[staging]\nextends=production\n",
+		"[staging]\nextends=staging\n",
+		"[staging]\nextends=nonexistent\n",
+		"extends=production\n[production]\nhost=prod.invalid\n",
+	}
+	for _, contents := range badContents {
+		f := parseFile(contents)
+		if _, err := UseEnvironment(f, "development"); err == nil {
+			t.Errorf("Expected error from UseEnvironment with file contents %q, but err was nil", contents)
+		}
+	}
+}
+
+func TestResolveOption(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmd.AddArg("environment", "production", false)
+	cmdSuite.AddSubCommand(cmd)
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff staging --user=cliuser")
+
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	parseFile := func(subdir, contents string) *mybase.File {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(tempDir, subdir), 0777); err != nil {
+			t.Fatalf("Unable to create dir: %s", err)
+		}
+		filePath := filepath.Join(tempDir, subdir, ".skeema")
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		f := mybase.NewFile(filePath)
+		if err := f.Parse(cfg); err != nil {
+			t.Fatalf("Unexpected error from Parse: %s", err)
+		}
+		return f
+	}
+	parent := parseFile("", "# comment\nuser=root\nport=3306\n\n[production]\nhost=prod.invalid\nport=3307\n\n[staging]\nextends=production\nignore_table=^tmp\nhost = staging.invalid\n")
+	child := parseFile("sub", "schema=product\n[staging]\nport=3308\n")
+	files := []*mybase.File{parent, child}
+
+	if sections := EnvironmentSections(parent, "staging"); !reflect.DeepEqual(sections, []string{"staging", "production"}) {
+		t.Errorf("Unexpected result from EnvironmentSections: %v", sections)
+	}
+	if sections := EnvironmentSections(child, "development"); len(sections) != 0 {
+		t.Errorf("Unexpected result from EnvironmentSections: %v", sections)
+	}
+
+	expected := map[string][]string{
+		"port":         {child.Path() + ":3 [staging]", parent.Path() + ":7 [production]", parent.Path() + ":3", "default"},
+		"host":         {parent.Path() + ":12 [staging]", parent.Path() + ":6 [production]", "default"},
+		"user":         {"command-line", parent.Path() + ":2", "default"},
+		"flavor":       {"default"},
+		"ignore-table": {parent.Path() + ":11 [staging]", "default"},
+	}
+	for name, expectedSettings := range expected {
+		resolution := ResolveOption(name, cfg.CLI.Command.Options()[name].Default, cfg.CLI, files, "staging", "")
+		actualSettings := make([]string, len(resolution.Settings))
+		for n, setting := range resolution.Settings {
+			actualSettings[n] = setting.String()
+		}
+		if !reflect.DeepEqual(actualSettings, expectedSettings) {
+			t.Errorf("Unexpected settings for %s: expected %v, found %v", name, expectedSettings, actualSettings)
+		} else if resolution.Value != resolution.Settings[0].Value {
+			t.Errorf("Unexpected value for %s: %q", name, resolution.Value)
+		}
+	}
+	if resolution := ResolveOption("port", "3306", nil, files, "production", ""); resolution.Value != "3307" || len(resolution.Settings) != 3 {
+		t.Errorf("Unexpected resolution for production environment: %+v", resolution)
+	}
+
+	// List options accumulate values across files
+	grandchild := parseFile("sub/sub", "ignore-table=^bak\n")
+	files = append(files, grandchild)
+	if resolution := ResolveOption("ignore-table", "", nil, files, "staging", ""); resolution.Value != "^tmp|^bak" || len(resolution.Settings) != 3 {
+		t.Errorf("Unexpected resolution for list option: %+v", resolution)
+	}
+}
+
+func TestMatchingDirSections(t *testing.T) {
+	sections := []string{"", "production", "dir:shard_*", "dir:shard_special", "dir:*", "dir:*/nested", "dir:shard_?", "dir:[st]hard_1"}
+	cases := map[string][]string{
+		".":                 nil,
+		"../shard_1":        nil,
+		"other":             {"dir:*"},
+		"shard_1":           {"dir:[st]hard_1", "dir:shard_?", "dir:shard_*", "dir:*"}, // ties go to later sections
+		"shard_special":     {"dir:shard_special", "dir:shard_*", "dir:*"},
+		"shard_special/foo": {"dir:shard_special", "dir:shard_*", "dir:*"},
+		"shard_12/nested":   {"dir:*/nested", "dir:shard_*", "dir:*"},
+	}
+	for relPath, expected := range cases {
+		if actual := MatchingDirSections(sections, relPath); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Unexpected result from MatchingDirSections for %s: expected %v, found %v", relPath, expected, actual)
+		}
+	}
+}
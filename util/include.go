@@ -0,0 +1,128 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skeema/mybase"
+)
+
+// MaxIncludeDepth is the maximum nesting depth of option files included via the
+// include or include-optional options. Exceeding this depth typically indicates
+// option files which include each other circularly.
+const MaxIncludeDepth = 8
+
+// IncludedFiles returns the option files included by option file f, using the
+// include and include-optional options in f's currently-selected sections.
+// Either option's value may be a comma-separated list of paths; relative paths
+// are resolved against the directory containing f. Included files may in turn
+// include other files, up to MaxIncludeDepth levels deep. The result is
+// ordered from lowest precedence to highest precedence, meaning that each file
+// appears after any files it includes, and f's own values should take
+// precedence over all of them. f itself is not included in the result.
+// Each included file is parsed using cfg, and has the sections for the
+// environment in cfg selected. An error is returned if a file listed by
+// include does not exist, or if any included file cannot be read or parsed.
+// Missing files listed by include-optional are silently skipped.
+func IncludedFiles(f *mybase.File, cfg *mybase.Config) ([]*mybase.File, error) {
+	return includedFiles(f, cfg, []string{f.Path()})
+}
+
+func includedFiles(f *mybase.File, cfg *mybase.Config, chain []string) ([]*mybase.File, error) {
+	var result []*mybase.File
+	for _, optName := range []string{"include", "include-optional"} {
+		value, _ := f.OptionValue(optName)
+		for _, includePath := range strings.Split(unquote(value), ",") {
+			includePath = strings.TrimSpace(unquote(strings.TrimSpace(includePath)))
+			if includePath == "" {
+				continue
+			}
+			included, err := includeFile(f, includePath, optName == "include-optional", cfg)
+			if err != nil {
+				return nil, err
+			} else if included == nil {
+				continue
+			}
+			if len(chain) >= MaxIncludeDepth {
+				return nil, fmt.Errorf("%s: option file includes nested more than %d levels deep, which may indicate circular includes: %s", chain[0], MaxIncludeDepth, strings.Join(append(chain, included.Path()), " → "))
+			}
+			nested, err := includedFiles(included, cfg, append(chain, included.Path()))
+			if err != nil {
+				return nil, err
+			}
+			result = append(append(result, nested...), included)
+		}
+	}
+	return result, nil
+}
+
+// includeFile reads and parses the option file at includePath, as included by
+// option file f. If optional is true and the file does not exist, a nil File
+// and nil error are returned.
+func includeFile(f *mybase.File, includePath string, optional bool, cfg *mybase.Config) (*mybase.File, error) {
+	if strings.HasPrefix(includePath, "~/") {
+		includePath = filepath.Join(os.Getenv("HOME"), includePath[2:])
+	} else if !filepath.IsAbs(includePath) {
+		includePath = filepath.Join(f.Dir, includePath)
+	}
+	included := mybase.NewFile(includePath)
+	fi, err := os.Stat(included.Path())
+	if os.IsNotExist(err) && optional {
+		return nil, nil
+	} else if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: included option file %s does not exist", f.Path(), included.Path())
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: unable to read included option file: %s", f.Path(), err)
+	} else if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: included option file %s is not a regular file", f.Path(), included.Path())
+	}
+	if err := included.Read(); err != nil {
+		return nil, fmt.Errorf("%s: unable to read included option file: %s", f.Path(), err)
+	}
+	if err := included.Parse(cfg); err != nil {
+		return nil, fmt.Errorf("%s: unable to parse included option file: %s", f.Path(), err)
+	}
+	if cfg.CLI.Command.HasArg("environment") {
+		if _, err := UseEnvironment(included, cfg.Get("environment")); err != nil {
+			return nil, err
+		}
+	}
+	return included, nil
+}
+
+// AddOptionFile adds option file f to cfg in the same manner as
+// AddOptionSource, preceded by any option files which f includes. This way,
+// each included file's values override those of option files previously added
+// to cfg, but f's own values override those of its included files. The
+// included files are only used as sources of option values; nothing ever
+// writes to them. If f has an encrypted companion file, its decrypted values
+// are added after f, so that they override f's own values.
+func AddOptionFile(cfg *mybase.Config, f *mybase.File) error {
+	return addOptionFile(cfg, f, f)
+}
+
+// AddDirSectionFile is like AddOptionFile, but adds dsf in place of its
+// underlying option file, so that its dir-pattern section values apply.
+func AddDirSectionFile(cfg *mybase.Config, dsf *DirSectionFile) error {
+	return addOptionFile(cfg, dsf.File, dsf)
+}
+
+func addOptionFile(cfg *mybase.Config, f *mybase.File, source mybase.OptionValuer) error {
+	included, err := IncludedFiles(f, cfg)
+	if err != nil {
+		return err
+	}
+	for _, inc := range included {
+		AddOptionSource(cfg, inc)
+	}
+	AddOptionSource(cfg, source)
+	enc, err := EncryptedOptionFile(f, cfg)
+	if err != nil {
+		return err
+	} else if enc != nil {
+		AddOptionSource(cfg, enc)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+func TestAddOptionFile(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmd.AddArg("environment", "production", false)
+	cmdSuite.AddSubCommand(cmd)
+
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+	writeFile := func(name, contents string) string {
+		t.Helper()
+		filePath := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(filePath), 0777); err != nil {
+			t.Fatalf("Unable to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+		return filePath
+	}
+	addFile := func(cfg *mybase.Config, filePath string) error {
+		t.Helper()
+		f := mybase.NewFile(filePath)
+		if err := f.Parse(cfg); err != nil {
+			t.Fatalf("Unexpected error from Parse: %s", err)
+		}
+		if _, err := UseEnvironment(f, cfg.Get("environment")); err != nil {
+			t.Fatalf("Unexpected error from UseEnvironment: %s", err)
+		}
+		return AddOptionFile(cfg, f)
+	}
+
+	// Relative paths are resolved against the including file; nested includes
+	// work; included files' environment sections apply; the including file's
+	// own values take precedence
+	opsFile := writeFile("ops/connections.cnf", "user=ops\nport=3307\n[production]\nhost=prod.invalid\ninclude=nested.cnf\n")
+	nestedFile := writeFile("ops/nested.cnf", "user=nested\nssh-user=bastion\n")
+	repoFile := writeFile("repo/.skeema", "include=../ops/connections.cnf\ninclude-optional=missing.cnf\nuser=app\n")
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+	if err := addFile(cfg, repoFile); err != nil {
+		t.Fatalf("Unexpected error from AddOptionFile: %s", err)
+	}
+	expected := map[string]string{
+		"user":     repoFile,
+		"host":     opsFile,
+		"port":     opsFile,
+		"ssh-user": nestedFile,
+	}
+	for name, expectedSource := range expected {
+		if source := cfg.Source(name).(*mybase.File).Path(); source != expectedSource {
+			t.Errorf("Expected option %s to come from %s, instead found %s", name, expectedSource, source)
+		}
+	}
+	if cfg.Get("user") != "app" || cfg.Get("host") != "prod.invalid" || cfg.Get("ssh-user") != "bastion" {
+		t.Errorf("Unexpected option values: user=%s host=%s ssh-user=%s", cfg.Get("user"), cfg.Get("host"), cfg.Get("ssh-user"))
+	}
+	if desc := SourceDescription(cfg, "host"); !strings.HasPrefix(desc, opsFile) {
+		t.Errorf("Unexpected SourceDescription for host: %s", desc)
+	}
+
+	// Sections for other environments do not apply
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema diff staging")
+	if err := addFile(cfg, repoFile); err != nil {
+		t.Fatalf("Unexpected error from AddOptionFile: %s", err)
+	}
+	if cfg.Changed("host") || cfg.Changed("ssh-user") {
+		t.Error("Expected production section of included file to be ignored for staging")
+	}
+
+	// Missing non-optional include is an error
+	writeFile("repo/.skeema", "include=missing.cnf\n")
+	if err := addFile(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff"), repoFile); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected error for missing included file, instead found %v", err)
+	}
+
+	// Circular includes exceed the depth limit
+	writeFile("repo/.skeema", "include=a.cnf\n")
+	writeFile("repo/a.cnf", "include=b.cnf\n")
+	writeFile("repo/b.cnf", "include="+filepath.Join(tempDir, "repo", "a.cnf")+"\n")
+	if err := addFile(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff"), repoFile); err == nil || !strings.Contains(err.Error(), "levels deep") {
+		t.Errorf("Expected error for circular includes, instead found %v", err)
+	}
+
+	// Included files must be valid option files
+	writeFile("repo/.skeema", "include=bad.cnf\n")
+	writeFile("repo/bad.cnf", "not-a-real-option=1\n")
+	if err := addFile(mybase.ParseFakeCLI(t, cmdSuite, "skeema diff"), repoFile); err == nil {
+		t.Error("Expected error for invalid included file, but err was nil")
+	}
+}
@@ -0,0 +1,272 @@
+package util
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/skeema/mybase"
+	"github.com/skeema/tengo"
+)
+
+// IntrospectionDriver is the name of a database/sql driver which wraps the
+// mysql driver, in order to count introspection queries along with their
+// elapsed time, and optionally limit their rate. This covers queries issued
+// internally by tengo, which Skeema cannot otherwise observe. Introspection
+// queries are SHOW commands, and SELECTs which either run in a connection
+// whose default database is information_schema, or mention information_schema
+// explicitly. All other queries and statements are passed through as-is.
+const IntrospectionDriver = "mysql-introspection"
+
+func init() {
+	sql.Register(IntrospectionDriver, introspectionDriver{})
+}
+
+// IntrospectionStats reports the number of introspection queries run against a
+// database server, along with their total elapsed time. Since queries may run
+// concurrently, the elapsed time may exceed the wall-clock time spent.
+type IntrospectionStats struct {
+	Queries int
+	Elapsed time.Duration
+}
+
+// introspectionTracker counts and optionally rate-limits introspection queries
+// against a single database server, across all connection pools and users.
+type introspectionTracker struct {
+	sync.Mutex
+	interval time.Duration // minimum time between the start of queries; 0 if unlimited
+	next     time.Time
+	stats    IntrospectionStats
+}
+
+// wait blocks until the rate limit permits another query to begin.
+func (tr *introspectionTracker) wait() {
+	tr.Lock()
+	if tr.interval == 0 {
+		tr.Unlock()
+		return
+	}
+	now := time.Now()
+	start := tr.next
+	if start.Before(now) {
+		start = now
+	}
+	tr.next = start.Add(tr.interval)
+	tr.Unlock()
+	time.Sleep(start.Sub(now))
+}
+
+// record counts a completed query.
+func (tr *introspectionTracker) record(elapsed time.Duration) {
+	tr.Lock()
+	tr.stats.Queries++
+	tr.stats.Elapsed += elapsed
+	tr.Unlock()
+}
+
+var introspectionTrackers struct {
+	sync.Mutex
+	byAddr map[string]*introspectionTracker
+}
+
+// introspectionTrackerForDSN returns the tracker for the server of dsn,
+// creating it if necessary. Trackers are keyed by network address, so that
+// connections using different users or params share the same tracker.
+func introspectionTrackerForDSN(dsn string) *introspectionTracker {
+	key := dsn
+	if cfg, err := mysql.ParseDSN(dsn); err == nil {
+		key = cfg.Net + "(" + cfg.Addr + ")"
+	}
+	introspectionTrackers.Lock()
+	defer introspectionTrackers.Unlock()
+	if introspectionTrackers.byAddr == nil {
+		introspectionTrackers.byAddr = make(map[string]*introspectionTracker)
+	}
+	tr := introspectionTrackers.byAddr[key]
+	if tr == nil {
+		tr = &introspectionTracker{}
+		introspectionTrackers.byAddr[key] = tr
+	}
+	return tr
+}
+
+// IntrospectionThrottle returns the value of the introspection-throttle
+// option, in queries per second. A value of 0 means no limit. An error is
+// returned if the value is not a non-negative number.
+func IntrospectionThrottle(cfg *mybase.Config) (float64, error) {
+	if cfg.FindOption("introspection-throttle") == nil {
+		return 0, nil
+	}
+	value := cfg.Get("introspection-throttle")
+	qps, err := strconv.ParseFloat(value, 64)
+	if err != nil || qps < 0 {
+		return 0, fmt.Errorf("Option introspection-throttle must be a non-negative number of queries per second; found %q", value)
+	}
+	return qps, nil
+}
+
+// SetIntrospectionThrottle limits introspection queries against the server of
+// inst to at most queriesPerSecond, across all instances connecting to the
+// same address via IntrospectionDriver. A value of 0 removes the limit.
+func SetIntrospectionThrottle(inst *tengo.Instance, queriesPerSecond float64) {
+	tr := introspectionTrackerForDSN(inst.BaseDSN)
+	tr.Lock()
+	defer tr.Unlock()
+	if queriesPerSecond > 0 {
+		tr.interval = time.Duration(float64(time.Second) / queriesPerSecond)
+	} else {
+		tr.interval = 0
+	}
+}
+
+// IntrospectionStatsForInstance returns the introspection queries run so far
+// against the server of inst, via IntrospectionDriver.
+func IntrospectionStatsForInstance(inst *tengo.Instance) IntrospectionStats {
+	tr := introspectionTrackerForDSN(inst.BaseDSN)
+	tr.Lock()
+	defer tr.Unlock()
+	return tr.stats
+}
+
+// isIntrospectionQuery returns true if query should be counted and throttled.
+// inInfoSchema indicates whether the connection's default database is
+// information_schema.
+func isIntrospectionQuery(query string, inInfoSchema bool) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if strings.HasPrefix(query, "show ") {
+		return true
+	} else if !strings.HasPrefix(query, "select") {
+		return false
+	}
+	return inInfoSchema || strings.Contains(query, "information_schema")
+}
+
+// introspectionDriver satisfies driver.Driver and driver.DriverContext.
+type introspectionDriver struct{}
+
+// Open returns a new connection to dsn.
+func (d introspectionDriver) Open(dsn string) (driver.Conn, error) {
+	connector, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(context.Background())
+}
+
+// OpenConnector returns a connector for dsn, wrapping the mysql driver's.
+func (d introspectionDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	connector, err := mysql.MySQLDriver{}.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	ic := &introspectionConnector{
+		Connector: connector,
+		tracker:   introspectionTrackerForDSN(dsn),
+	}
+	if cfg, err := mysql.ParseDSN(dsn); err == nil {
+		ic.inInfoSchema = strings.EqualFold(cfg.DBName, "information_schema")
+	}
+	return ic, nil
+}
+
+type introspectionConnector struct {
+	driver.Connector
+	tracker      *introspectionTracker
+	inInfoSchema bool
+}
+
+// Connect returns a new connection, wrapping the mysql driver's.
+func (ic *introspectionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := ic.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &introspectionConn{Conn: conn, tracker: ic.tracker, inInfoSchema: ic.inInfoSchema}, nil
+}
+
+// Driver returns the introspection driver.
+func (ic *introspectionConnector) Driver() driver.Driver {
+	return introspectionDriver{}
+}
+
+// introspectionConn wraps a connection of the mysql driver, forwarding all of
+// the optional interfaces implemented by the mysql driver.
+type introspectionConn struct {
+	driver.Conn
+	tracker      *introspectionTracker
+	inInfoSchema bool
+}
+
+// QueryContext runs query, first waiting for the rate limit if it is an
+// introspection query. If the mysql driver cannot run the query directly,
+// database/sql falls back to a prepared statement, which is not counted or
+// throttled again.
+func (c *introspectionConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	} else if !isIntrospectionQuery(query, c.inInfoSchema) {
+		return qc.QueryContext(ctx, query, args)
+	}
+	c.tracker.wait()
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		c.tracker.record(time.Since(start))
+	}
+	return rows, err
+}
+
+// ExecContext forwards to the mysql driver.
+func (c *introspectionConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if ec, ok := c.Conn.(driver.ExecerContext); ok {
+		return ec.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+// PrepareContext forwards to the mysql driver.
+func (c *introspectionConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx forwards to the mysql driver.
+func (c *introspectionConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bt, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bt.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping forwards to the mysql driver.
+func (c *introspectionConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession forwards to the mysql driver.
+func (c *introspectionConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue forwards to the mysql driver.
+func (c *introspectionConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/skeema/mybase"
+)
+
+func TestIsIntrospectionQuery(t *testing.T) {
+	cases := []struct {
+		query        string
+		inInfoSchema bool
+		expected     bool
+	}{
+		{"SHOW CREATE TABLE `users`", false, true},
+		{"  show table status like 'users'", false, true},
+		{"SELECT table_name FROM information_schema.tables WHERE table_schema = ?", false, true},
+		{"SELECT table_name FROM tables WHERE table_schema = ?", true, true},
+		{"SELECT table_name FROM tables WHERE table_schema = ?", false, false},
+		{"SELECT COUNT(*) FROM `users`", false, false},
+		{"SELECT @@version", false, false},
+		{"ALTER TABLE `users` ADD COLUMN `age` int", true, false},
+		{"SET SESSION wait_timeout = 28800", true, false},
+		{"SHOWCASE", false, false},
+	}
+	for _, c := range cases {
+		if actual := isIntrospectionQuery(c.query, c.inInfoSchema); actual != c.expected {
+			t.Errorf("Expected isIntrospectionQuery(%q, %t) to return %t, instead found %t", c.query, c.inInfoSchema, c.expected, actual)
+		}
+	}
+}
+
+func TestIntrospectionThrottle(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmdSuite.AddSubCommand(cmd)
+
+	cases := map[string]float64{
+		"":    0,
+		"0":   0,
+		"50":  50,
+		"2.5": 2.5,
+	}
+	for value, expected := range cases {
+		cli := "skeema diff"
+		if value != "" {
+			cli += " --introspection-throttle=" + value
+		}
+		cfg := mybase.ParseFakeCLI(t, cmdSuite, cli)
+		if qps, err := IntrospectionThrottle(cfg); err != nil || qps != expected {
+			t.Errorf("Unexpected result from IntrospectionThrottle for %q: %v, %v", value, qps, err)
+		}
+	}
+	for _, value := range []string{"fast", "-1"} {
+		cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff --introspection-throttle="+value)
+		if _, err := IntrospectionThrottle(cfg); err == nil {
+			t.Errorf("Expected error from IntrospectionThrottle for %q, but err was nil", value)
+		}
+	}
+}
+
+func TestIntrospectionTracker(t *testing.T) {
+	inst, err := NewInstance(IntrospectionDriver, "username:password@tcp(1.2.3.4:3306)/?interpolateParams=true")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %s", err)
+	} else if inst.Driver != IntrospectionDriver {
+		t.Errorf("Expected instance driver to be %s, instead found %s", IntrospectionDriver, inst.Driver)
+	}
+
+	// Connection pools of the instance, including those used by tengo for full
+	// schema introspection, use the wrapping driver
+	db, err := sql.Open(inst.Driver, inst.BaseDSN)
+	if err != nil {
+		t.Fatalf("Unexpected error from sql.Open: %s", err)
+	} else if _, ok := db.Driver().(introspectionDriver); !ok {
+		t.Errorf("Expected connection pool to use introspectionDriver, instead found %T", db.Driver())
+	}
+	db.Close()
+
+	// Trackers are shared by all DSNs with the same address
+	tr := introspectionTrackerForDSN(inst.BaseDSN)
+	if other := introspectionTrackerForDSN("otheruser@tcp(1.2.3.4:3306)/information_schema?timeout=1s"); other != tr {
+		t.Error("Expected DSNs with same address to share tracker, but they do not")
+	} else if other := introspectionTrackerForDSN("username:password@tcp(1.2.3.4:3307)/"); other == tr {
+		t.Error("Expected DSNs with different ports to have different trackers, but they do not")
+	}
+
+	tr.record(10 * time.Millisecond)
+	tr.record(5 * time.Millisecond)
+	if stats := IntrospectionStatsForInstance(inst); stats.Queries != 2 || stats.Elapsed != 15*time.Millisecond {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+
+	// With a throttle of 100 queries per second, 6 queries should take at least
+	// 50ms, since the first one does not wait
+	SetIntrospectionThrottle(inst, 100)
+	start := time.Now()
+	for n := 0; n < 6; n++ {
+		tr.wait()
+	}
+	if elapsed := time.Since(start); elapsed < 45*time.Millisecond {
+		t.Errorf("Expected throttled queries to take at least 50ms, instead took %s", elapsed)
+	}
+
+	// Removing the throttle should no longer wait
+	SetIntrospectionThrottle(inst, 0)
+	start = time.Now()
+	for n := 0; n < 100; n++ {
+		tr.wait()
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("Expected unthrottled queries not to wait, instead took %s", elapsed)
+	}
+}
@@ -0,0 +1,186 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/skeema/mybase"
+)
+
+// ListReset is the special value which clears any elements of a list option
+// inherited from lower-precedence option files. It may optionally be followed
+// by the option's delimiter and further elements, for example
+// "ignore-table=!reset|^tmp_" or "replicas=!reset,replica3.example.com".
+const ListReset = "!reset"
+
+// listOption describes how the values of a list option are combined across
+// option files.
+type listOption struct {
+	delimiter string // separator used when joining elements
+	split     bool   // if true, each option file's value may contain multiple elements separated by delimiter
+}
+
+// listOptions is the registry of options whose values accumulate across
+// option files, rather than a higher-precedence file replacing the value of a
+// lower-precedence one. Accumulation only applies to option files; a value
+// supplied on the command-line always replaces the accumulated value. Since
+// this changes how values are merged, options must be individually registered
+// here to obtain this behavior.
+var listOptions = map[string]listOption{
+	"ignore-table":         {delimiter: "|"}, // each value is a regex, combined by alternation
+	"ignore-table-options": {delimiter: ",", split: true},
+	"replicas":             {delimiter: ",", split: true},
+	"allow-auto-inc":       {delimiter: ",", split: true},
+	"allow-charset":        {delimiter: ",", split: true},
+	"allow-definer":        {delimiter: ",", split: true},
+	"allow-engine":         {delimiter: ",", split: true},
+}
+
+// IsListOption returns true if the named option is a list option, with values
+// accumulating across option files.
+func IsListOption(name string) bool {
+	_, ok := listOptions[name]
+	return ok
+}
+
+// ListElement is a single element of a list option's accumulated value, along
+// with the source which supplied it.
+type ListElement struct {
+	Value  string
+	Source mybase.OptionValuer
+}
+
+// accumulate combines the values of list option opt, supplied by sources in
+// order of increasing precedence. It returns the resulting elements, along
+// with the number of sources that set the option, and whether any of them
+// used ListReset.
+func (opt listOption) accumulate(name string, sources []mybase.OptionValuer) (elements []ListElement, setCount int, reset bool) {
+	for _, source := range sources {
+		raw, ok := source.OptionValue(name)
+		if !ok {
+			continue
+		}
+		setCount++
+		value := strings.TrimSpace(unquote(strings.TrimSpace(raw)))
+		if strings.HasPrefix(value, ListReset) {
+			rest := strings.TrimSpace(strings.TrimPrefix(value, ListReset))
+			if rest == "" || strings.HasPrefix(rest, opt.delimiter) {
+				elements, reset = nil, true
+				value = strings.TrimPrefix(rest, opt.delimiter)
+			}
+		}
+		values := []string{value}
+		if opt.split {
+			values = strings.Split(value, opt.delimiter)
+		}
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" && !hasElement(elements, v) {
+				elements = append(elements, ListElement{Value: v, Source: source})
+			}
+		}
+	}
+	return elements, setCount, reset
+}
+
+func hasElement(elements []ListElement, value string) bool {
+	for _, elem := range elements {
+		if elem.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func joinElements(elements []ListElement, delimiter string) string {
+	values := make([]string, len(elements))
+	for n, elem := range elements {
+		values[n] = elem.Value
+	}
+	return strings.Join(values, delimiter)
+}
+
+// listSource is an OptionValuer which supplies the accumulated values of list
+// options, across all of the option files it wraps. It does not supply values
+// for any other options. A listSource is added to a mybase.Config after each
+// option file by AddOptionSource, so that it overrides the individual files.
+type listSource struct {
+	sources []mybase.OptionValuer // ordered from lowest precedence to highest precedence
+}
+
+// OptionValue returns the accumulated value of list option name, satisfying
+// the mybase.OptionValuer interface. If only a single source sets the option,
+// its raw value is returned as-is.
+func (ls *listSource) OptionValue(name string) (string, bool) {
+	opt, ok := listOptions[name]
+	if !ok {
+		return "", false
+	}
+	elements, setCount, reset := opt.accumulate(name, ls.sources)
+	if setCount == 0 {
+		return "", false
+	} else if setCount == 1 && !reset {
+		for _, source := range ls.sources {
+			if raw, ok := source.OptionValue(name); ok {
+				return raw, true
+			}
+		}
+	}
+	return joinElements(elements, opt.delimiter), true
+}
+
+// String returns a description of the sources combined by ls.
+func (ls *listSource) String() string {
+	descriptions := make([]string, len(ls.sources))
+	for n, source := range ls.sources {
+		descriptions[n] = fmt.Sprint(source)
+	}
+	return "combination of " + strings.Join(descriptions, ", ")
+}
+
+// AddOptionSource adds source, typically an option file, to cfg. If source or
+// any option file previously added to cfg sets a list option, the option's
+// value in cfg becomes the accumulation of all of their values. This function
+// should be used instead of calling cfg.AddSource directly for option files.
+func AddOptionSource(cfg *mybase.Config, source mybase.OptionValuer) {
+	ls := &listSource{sources: []mybase.OptionValuer{source}}
+	if prev := currentListSource(cfg); prev != nil {
+		ls.sources = append(append([]mybase.OptionValuer{}, prev.sources...), source)
+	}
+	cfg.AddSource(source)
+	cfg.AddSource(ls)
+}
+
+// currentListSource returns the listSource supplying cfg's value for any list
+// option, or nil if no list option's value is currently accumulated. Since
+// each listSource wraps all option files of the prior one, the
+// highest-precedence listSource is the only one which can supply values.
+func currentListSource(cfg *mybase.Config) *listSource {
+	options := cfg.CLI.Command.Options()
+	for name := range listOptions {
+		if _, ok := options[name]; !ok {
+			continue
+		}
+		if ls, ok := cfg.Source(name).(*listSource); ok {
+			return ls
+		}
+	}
+	return nil
+}
+
+// ListElements returns the elements of list option name's value in cfg, along
+// with the source of each element. If the option's value did not come from
+// option files, for example if it was supplied on the command-line, all
+// elements have the same source. Panics if name is not a list option.
+func ListElements(cfg *mybase.Config, name string) []ListElement {
+	opt, ok := listOptions[name]
+	if !ok {
+		panic(fmt.Errorf("Assertion failed: option %s is not a list option", name))
+	}
+	source := cfg.Source(name)
+	if ls, ok := source.(*listSource); ok {
+		elements, _, _ := opt.accumulate(name, ls.sources)
+		return elements
+	}
+	elements, _, _ := opt.accumulate(name, []mybase.OptionValuer{source})
+	return elements
+}
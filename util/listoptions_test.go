@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+// testSource is a named OptionValuer, permitting comparison of sources by
+// pointer equality.
+type testSource struct {
+	name   string
+	values map[string]string
+}
+
+func (ts *testSource) OptionValue(name string) (string, bool) {
+	value, ok := ts.values[name]
+	return value, ok
+}
+
+func (ts *testSource) String() string {
+	return ts.name
+}
+
+func TestAddOptionSource(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("push", "", "", nil)
+	cmd.AddOption(mybase.StringOption("ignore-table", 0, "", ""))
+	cmd.AddOption(mybase.StringOption("replicas", 0, "", ""))
+	cmd.AddOption(mybase.StringOption("schema", 0, "", ""))
+	cmdSuite.AddSubCommand(cmd)
+
+	global := &testSource{"global", map[string]string{"ignore-table": "^_", "replicas": "'r1.invalid, r2.invalid'", "schema": "product"}}
+	parent := &testSource{"parent", map[string]string{"replicas": "r2.invalid,r3.invalid"}}
+	child := &testSource{"child", map[string]string{"ignore-table": "'^tmp'", "schema": "analytics"}}
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema push")
+	for _, source := range []mybase.OptionValuer{global, parent, child} {
+		AddOptionSource(cfg, source)
+	}
+	expected := map[string]string{
+		"ignore-table": "^_|^tmp",
+		"replicas":     "r1.invalid,r2.invalid,r3.invalid",
+		"schema":       "analytics",
+	}
+	for name, value := range expected {
+		if actual := cfg.Get(name); actual != value {
+			t.Errorf("Expected %s to be %q, instead found %q", name, value, actual)
+		}
+	}
+	if cfg.Source("schema") != child {
+		t.Errorf("Expected non-list option to be supplied by child source, instead found %v", cfg.Source("schema"))
+	}
+
+	// Per-element provenance
+	elements := ListElements(cfg, "replicas")
+	expectedSources := []mybase.OptionValuer{global, global, parent}
+	if len(elements) != len(expectedSources) {
+		t.Fatalf("Expected %d elements, instead found %+v", len(expectedSources), elements)
+	}
+	for n, elem := range elements {
+		if elem.Source != expectedSources[n] {
+			t.Errorf("Unexpected source for element %s: %v", elem.Value, elem.Source)
+		}
+	}
+	if desc := SourceDescription(cfg, "ignore-table"); desc != "^_ from global; ^tmp from child" {
+		t.Errorf("Unexpected source description: %s", desc)
+	}
+
+	// Reset clears inherited elements, optionally followed by new ones
+	AddOptionSource(cfg, &testSource{"grandchild", map[string]string{"replicas": "!reset, r4.invalid", "ignore-table": "!reset"}})
+	if actual := cfg.Get("replicas"); actual != "r4.invalid" {
+		t.Errorf("Expected reset to clear inherited replicas, instead found %q", actual)
+	}
+	if actual := cfg.Get("ignore-table"); actual != "" {
+		t.Errorf("Expected reset to clear inherited ignore-table, instead found %q", actual)
+	}
+	AddOptionSource(cfg, &testSource{"greatgrandchild", map[string]string{"ignore-table": "^bak"}})
+	if actual := cfg.Get("ignore-table"); actual != "^bak" {
+		t.Errorf("Expected ignore-table to be %q, instead found %q", "^bak", actual)
+	}
+
+	// Values on the command-line replace accumulated values
+	cfg = mybase.ParseFakeCLI(t, cmdSuite, "skeema push --replicas=r5.invalid")
+	AddOptionSource(cfg, global)
+	AddOptionSource(cfg, parent)
+	if actual := cfg.Get("replicas"); actual != "r5.invalid" {
+		t.Errorf("Expected command-line value to take precedence, instead found %q", actual)
+	}
+	if actual := cfg.Get("ignore-table"); actual != "^_" {
+		t.Errorf("Expected ignore-table to be %q, instead found %q", "^_", actual)
+	}
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// DirMode is the permission mode used for directories created by Skeema. It
+// is set from the dir-mode option by ProcessSpecialGlobalOptions.
+var DirMode os.FileMode = 0755
+
+// FileMode is the permission mode used for files created by Skeema. It is set
+// from the file-mode option by ProcessSpecialGlobalOptions.
+var FileMode os.FileMode = 0644
+
+// ParseMode converts an octal permission string, such as "0644" or "755", to
+// an os.FileMode. An error is returned if the value is not a valid permission
+// mode, or if it lacks any of the permission bits in required.
+func ParseMode(optionName, value string, required os.FileMode) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("Option %s must be an octal permission mode such as \"%#o\"; instead found \"%s\"", optionName, required, value)
+	}
+	if os.FileMode(mode)&required != required {
+		return 0, fmt.Errorf("Option %s value \"%s\" must include owner permissions of at least %#o", optionName, value, required)
+	}
+	return os.FileMode(mode), nil
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	cases := []struct {
+		value    string
+		required os.FileMode
+		expected os.FileMode
+		valid    bool
+	}{
+		{"0644", 0600, 0644, true},
+		{"644", 0600, 0644, true},
+		{"0600", 0600, 0600, true},
+		{"0755", 0700, 0755, true},
+		{"0750", 0700, 0750, true},
+		{"0444", 0600, 0, false},
+		{"0200", 0600, 0, false},
+		{"0644", 0700, 0, false},
+		{"0888", 0600, 0, false},
+		{"01777", 0700, 0, false},
+		{"rw-r--r--", 0600, 0, false},
+		{"", 0600, 0, false},
+	}
+	for _, c := range cases {
+		mode, err := ParseMode("file-mode", c.value, c.required)
+		if c.valid && (err != nil || mode != c.expected) {
+			t.Errorf("ParseMode(%q, %#o): expected %#o, found %#o / %v", c.value, c.required, c.expected, mode, err)
+		} else if !c.valid && err == nil {
+			t.Errorf("ParseMode(%q, %#o): expected error, instead found %#o", c.value, c.required, mode)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package util
+
+import (
+	"sort"
+
+	"github.com/skeema/mybase"
+)
+
+// OptionPlacement describes where an option may be configured.
+type OptionPlacement string
+
+// Constants enumerating valid option placements
+const (
+	PlacementAny        OptionPlacement = "any"         // command-line, global option files, or .skeema files
+	PlacementGlobal     OptionPlacement = "global"      // command-line or global option files only
+	PlacementCLI        OptionPlacement = "cli"         // command-line only
+	PlacementSkeemaFile OptionPlacement = "skeema-file" // .skeema files only, with limited command-specific exceptions
+	PlacementSection    OptionPlacement = "section"     // environment sections of global option files or .skeema files, with limited command-specific exceptions
+)
+
+// optionPlacements lists options which cannot be configured everywhere. Any
+// option not listed here has PlacementAny.
+var optionPlacements = map[string]OptionPlacement{
+	"brief":             PlacementCLI,
+	"dry-run":           PlacementCLI,
+	"targets":           PlacementCLI,
+	"debug":             PlacementGlobal,
+	"dir-mode":          PlacementGlobal,
+	"file-mode":         PlacementGlobal,
+	"my-cnf":            PlacementGlobal,
+	"strict":            PlacementGlobal,
+	"exit-code-mode":    PlacementGlobal,
+	"host":              PlacementSkeemaFile,
+	"schema":            PlacementSkeemaFile,
+	"format-version":    PlacementSkeemaFile,
+	"table-template":    PlacementSkeemaFile,
+	"template-tables":   PlacementSkeemaFile,
+	"manual-migrations": PlacementSkeemaFile,
+	"extends":           PlacementSection,
+}
+
+// OptionInfo describes a single option understood by a command suite, for use
+// by external tooling such as documentation generators or policy checkers.
+type OptionInfo struct {
+	Name           string          `json:"name"`
+	Shorthand      string          `json:"shorthand,omitempty"`
+	Type           string          `json:"type"`
+	Default        string          `json:"default"`
+	Description    string          `json:"description"`
+	Hidden         bool            `json:"hidden"`
+	Commands       []string        `json:"commands"`
+	Placement      OptionPlacement `json:"placement"`
+	PerEnvironment bool            `json:"per_environment"`
+}
+
+// OptionRegistry returns information about every option accepted by any
+// subcommand of suite, sorted by option name. Each option's Commands are also
+// sorted, so that output is stable across runs. If a subcommand overrides an
+// option of the same name, the suite-level definition is described if one
+// exists; otherwise, the definition from the alphabetically-first subcommand
+// is used. An option is only considered hidden if it is hidden in every
+// subcommand accepting it.
+func OptionRegistry(suite *mybase.Command) []OptionInfo {
+	cmdNames := make([]string, 0, len(suite.SubCommands))
+	for name := range suite.SubCommands {
+		cmdNames = append(cmdNames, name)
+	}
+	sort.Strings(cmdNames)
+
+	suiteOptions := suite.Options()
+	byName := make(map[string]*OptionInfo)
+	for _, cmdName := range cmdNames {
+		for name, opt := range suite.SubCommands[cmdName].Options() {
+			info, ok := byName[name]
+			if !ok {
+				def := opt
+				if suiteOpt, ok := suiteOptions[name]; ok {
+					def = suiteOpt
+				}
+				info = newOptionInfo(def)
+				info.Hidden = true
+				byName[name] = info
+			}
+			info.Commands = append(info.Commands, cmdName)
+			info.Hidden = info.Hidden && opt.HiddenOnCLI
+		}
+	}
+
+	result := make([]OptionInfo, 0, len(byName))
+	for _, info := range byName {
+		result = append(result, *info)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func newOptionInfo(opt *mybase.Option) *OptionInfo {
+	info := &OptionInfo{
+		Name:        opt.Name,
+		Type:        "string",
+		Default:     opt.Default,
+		Description: opt.Description,
+		Placement:   PlacementAny,
+	}
+	if opt.Shorthand != 0 {
+		info.Shorthand = string(opt.Shorthand)
+	}
+	if opt.Type == mybase.OptionTypeBool {
+		info.Type = "bool"
+		info.Default = opt.PrintableDefault()
+	}
+	if placement, ok := optionPlacements[opt.Name]; ok {
+		info.Placement = placement
+	}
+
+	// Environment-specific sections are supported in both global option files
+	// and .skeema files, but obviously not on the command-line
+	info.PerEnvironment = (info.Placement != PlacementCLI)
+	return info
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/skeema/mybase"
+)
+
+func TestOptionRegistry(t *testing.T) {
+	suite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(suite)
+	cmd1 := mybase.NewCommand("push", "", "", nil)
+	cmd1.AddOption(mybase.BoolOption("allow-unsafe", 0, false, "Permit generating ALTER or DROP operations that are potentially destructive"))
+	cmd1.AddOption(mybase.BoolOption("dry-run", 0, false, "Output DDL but don't run it"))
+	suite.AddSubCommand(cmd1)
+	cmd2 := mybase.NewCommand("diff", "", "", nil)
+	cmd2.AddOption(mybase.BoolOption("allow-unsafe", 0, false, "Permit generating ALTER or DROP operations that are potentially destructive"))
+	cmd2.AddOption(mybase.BoolOption("dry-run", 0, true, "Output DDL but don't run it").Hidden())
+	suite.AddSubCommand(cmd2)
+	cmd3 := mybase.NewCommand("init", "", "", nil)
+	cmd3.AddOption(mybase.StringOption("host", 'h', "", "Database hostname or IP address"))
+	suite.AddSubCommand(cmd3)
+
+	registry := OptionRegistry(suite)
+	byName := make(map[string]OptionInfo, len(registry))
+	for n, info := range registry {
+		if n > 0 && registry[n-1].Name >= info.Name {
+			t.Errorf("Registry not sorted by name: %s precedes %s", registry[n-1].Name, info.Name)
+		}
+		byName[info.Name] = info
+	}
+
+	if info := byName["user"]; !reflect.DeepEqual(info.Commands, []string{"diff", "help", "init", "push", "version"}) || info.Shorthand != "u" || info.Default != "root" || info.Placement != PlacementAny || !info.PerEnvironment {
+		t.Errorf("Unexpected info for global option user: %+v", info)
+	}
+	if info := byName["allow-unsafe"]; !reflect.DeepEqual(info.Commands, []string{"diff", "push"}) || info.Type != "bool" || info.Default != "false" {
+		t.Errorf("Unexpected info for option allow-unsafe: %+v", info)
+	}
+
+	// dry-run is only hidden in one of its commands; the definition from the
+	// alphabetically-first command is used, since there's no suite-level one
+	if info := byName["dry-run"]; info.Hidden || info.Default != "true" || info.Placement != PlacementCLI || info.PerEnvironment {
+		t.Errorf("Unexpected info for option dry-run: %+v", info)
+	}
+
+	// init overrides host to be visible, but the suite-level definition is
+	// described
+	if info := byName["host"]; info.Hidden || info.Shorthand != "" || info.Placement != PlacementSkeemaFile || !info.PerEnvironment {
+		t.Errorf("Unexpected info for option host: %+v", info)
+	}
+	if info := byName["schema"]; !info.Hidden {
+		t.Errorf("Expected option schema to be hidden, but it was not: %+v", info)
+	}
+	if info := byName["debug"]; info.Placement != PlacementGlobal {
+		t.Errorf("Unexpected info for option debug: %+v", info)
+	}
+
+	// JSON output must be identical across calls
+	b1, err := json.Marshal(registry)
+	if err != nil {
+		t.Fatalf("Unexpected error from json.Marshal: %s", err)
+	}
+	b2, _ := json.Marshal(OptionRegistry(suite))
+	if string(b1) != string(b2) {
+		t.Error("Expected OptionRegistry output to be stable, but it differed between calls")
+	}
+}
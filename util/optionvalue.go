@@ -0,0 +1,83 @@
+package util
+
+import (
+	"strings"
+)
+
+// optionValueEscapes lists the backslash escape sequences recognized by
+// UnquoteOptionValue, mapped to the characters they represent. These match the
+// escape sequences of SQL string literals.
+var optionValueEscapes = map[byte]byte{
+	'0':  0,
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'\\': '\\',
+	'\'': '\'',
+	'"':  '"',
+}
+
+// QuoteOptionValue returns value as a single-quoted string which may safely
+// be written to an option file, and which UnquoteOptionValue converts back to
+// the original value. Unlike the option file parser's own unquoting, this
+// permits arbitrary values to be stored, including ones containing newlines or
+// both types of quotes. Backslashes, single quotes, and control characters are
+// represented using the same backslash escapes as SQL string literals; any
+// other characters, including multi-byte characters, are written as-is.
+func QuoteOptionValue(value string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for n := 0; n < len(value); n++ {
+		switch c := value[n]; c {
+		case 0:
+			b.WriteString(`\0`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\\', '\'':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// UnquoteOptionValue interprets raw, an option value exactly as it appeared in
+// an option file (for example from mybase.Config.GetRaw), in the same manner
+// as a SQL string literal. If raw is wrapped in single or double quotes, they
+// are removed, doubled quotes of the same type become single quotes, and the
+// backslash escapes written by QuoteOptionValue are converted back to the
+// characters they represent. A backslash followed by any other character is
+// removed. If raw is not wrapped in quotes, it is returned with surrounding
+// whitespace trimmed.
+func UnquoteOptionValue(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 || (raw[0] != '\'' && raw[0] != '"') || raw[len(raw)-1] != raw[0] {
+		return raw
+	}
+	quote := raw[0]
+	raw = raw[1 : len(raw)-1]
+	var b strings.Builder
+	for n := 0; n < len(raw); n++ {
+		c := raw[n]
+		if c == '\\' && n+1 < len(raw) {
+			n++
+			if unescaped, ok := optionValueEscapes[raw[n]]; ok {
+				b.WriteByte(unescaped)
+			} else {
+				b.WriteByte(raw[n])
+			}
+			continue
+		} else if c == quote && n+1 < len(raw) && raw[n+1] == quote {
+			n++
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"testing/quick"
+
+	"github.com/skeema/mybase"
+)
+
+func TestUnquoteOptionValue(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"  foo ":            "foo",
+		"'foo'":             "foo",
+		`"foo"`:             "foo",
+		"'it''s'":           "it's",
+		`"say ""hi"""`:      `say "hi"`,
+		`'it\'s'`:           "it's",
+		`'a\nb\tc\\d\0'`:    "a\nb\tc\\d\x00",
+		`'\q'`:              "q",
+		`'mismatched"`:      `'mismatched"`,
+		`'"double" inside'`: `"double" inside`,
+		"'multi-byte ✓ 日本'": "multi-byte ✓ 日本",
+	}
+	for input, expected := range cases {
+		if actual := UnquoteOptionValue(input); actual != expected {
+			t.Errorf("Expected UnquoteOptionValue(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+}
+
+// TestQuoteOptionValueRoundTrip confirms that arbitrary values survive being
+// written to an option file using QuoteOptionValue, parsed back by mybase, and
+// then converted using UnquoteOptionValue.
+func TestQuoteOptionValueRoundTrip(t *testing.T) {
+	cmdSuite := mybase.NewCommandSuite("skeematest", "", "")
+	AddGlobalOptions(cmdSuite)
+	cmd := mybase.NewCommand("diff", "", "", nil)
+	cmd.AddArg("environment", "production", false)
+	cmdSuite.AddSubCommand(cmd)
+	cfg := mybase.ParseFakeCLI(t, cmdSuite, "skeema diff")
+
+	tempDir, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	roundTrip := func(value string) bool {
+		f := mybase.NewFile(tempDir, ".skeema")
+		f.SetOptionValue("", "schema-comment", QuoteOptionValue(value))
+		f.SetOptionValue("", "schema", "product")
+		if err := f.Write(true); err != nil {
+			t.Fatalf("Unexpected error from Write: %s", err)
+		}
+		reread := mybase.NewFile(tempDir, ".skeema")
+		if err := reread.Parse(cfg); err != nil {
+			t.Logf("Unexpected error from Parse for value %q: %s", value, err)
+			return false
+		}
+		raw, ok := reread.OptionValue("schema-comment")
+		if !ok {
+			t.Logf("Option schema-comment missing after writing value %q", value)
+			return false
+		}
+		if actual := UnquoteOptionValue(raw); actual != value {
+			t.Logf("Value %q was read back as %q", value, actual)
+			return false
+		}
+		if schema, _ := reread.OptionValue("schema"); schema != "product" {
+			t.Logf("Writing value %q clobbered subsequent option, which was read back as %q", value, schema)
+			return false
+		}
+		return true
+	}
+
+	values := []string{
+		"",
+		"plain",
+		" leading and trailing spaces ",
+		"it's",
+		`say "hi"`,
+		"`backticks`",
+		`both ' and " and ` + "`",
+		"multiple\nlines\r\nhere",
+		`C:\path\to\thing\`,
+		`\'`,
+		"# not a comment",
+		"trailing hash #",
+		"; semicolon",
+		"[not a section]",
+		"tab\there",
+		"nul\x00byte",
+		"multi-byte ✓ 日本語 🙂",
+	}
+	for _, value := range values {
+		if !roundTrip(value) {
+			t.Errorf("Value %q did not round-trip", value)
+		}
+	}
+	if err := quick.Check(roundTrip, nil); err != nil {
+		t.Error(err)
+	}
+}
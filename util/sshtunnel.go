@@ -0,0 +1,245 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+	"github.com/skeema/mybase"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHTunnelConfig describes how to reach database hosts through an SSH
+// bastion host.
+type SSHTunnelConfig struct {
+	Host           string // bastion hostname, optionally with :port suffix; port defaults to 22
+	User           string // bastion username; defaults to the OS username
+	KeyPath        string // path to private key file; defaults to the first of ~/.ssh/id_ed25519, ~/.ssh/id_ecdsa, ~/.ssh/id_rsa which exists
+	KnownHostsPath string // path to known_hosts file; defaults to ~/.ssh/known_hosts
+}
+
+// SSHTunnelConfigForOptions returns an SSHTunnelConfig based on the ssh-host,
+// ssh-user, ssh-key, and ssh-known-hosts options of cfg. If ssh-host is not
+// set, ok will be false.
+func SSHTunnelConfigForOptions(cfg *mybase.Config) (tc SSHTunnelConfig, ok bool) {
+	if cfg.FindOption("ssh-host") == nil || cfg.Get("ssh-host") == "" {
+		return tc, false
+	}
+	tc = SSHTunnelConfig{
+		Host:           cfg.Get("ssh-host"),
+		User:           cfg.Get("ssh-user"),
+		KeyPath:        cfg.Get("ssh-key"),
+		KnownHostsPath: cfg.Get("ssh-known-hosts"),
+	}
+	return tc, true
+}
+
+// address returns the bastion's host:port.
+func (tc SSHTunnelConfig) address() string {
+	if _, _, err := net.SplitHostPort(tc.Host); err == nil {
+		return tc.Host
+	}
+	return net.JoinHostPort(strings.Trim(tc.Host, "[]"), "22")
+}
+
+// String returns a user@host:port representation of the bastion.
+func (tc SSHTunnelConfig) String() string {
+	return fmt.Sprintf("%s@%s", tc.User, tc.address())
+}
+
+// withDefaults returns a copy of tc with default values filled in for any
+// blank fields other than Host.
+func (tc SSHTunnelConfig) withDefaults() (SSHTunnelConfig, error) {
+	if tc.User == "" {
+		u, err := user.Current()
+		if err != nil {
+			return tc, fmt.Errorf("Unable to determine default ssh-user: %s", err)
+		}
+		tc.User = u.Username
+	}
+	if tc.KnownHostsPath == "" {
+		tc.KnownHostsPath = "~/.ssh/known_hosts"
+	}
+	if tc.KeyPath == "" {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			candidate := "~/.ssh/" + name
+			if _, err := os.Stat(expandHomeDir(candidate)); err == nil {
+				tc.KeyPath = candidate
+				break
+			}
+		}
+		if tc.KeyPath == "" {
+			return tc, fmt.Errorf("No ssh-key specified, and no default private key found in ~/.ssh")
+		}
+	}
+	tc.KeyPath, tc.KnownHostsPath = expandHomeDir(tc.KeyPath), expandHomeDir(tc.KnownHostsPath)
+	return tc, nil
+}
+
+// expandHomeDir replaces a leading ~/ in path with the user's home directory.
+func expandHomeDir(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// SSHTunnelError is returned when a connection to a database host cannot be
+// established due to a problem with the SSH tunnel, as opposed to a problem
+// connecting to the database server itself.
+type SSHTunnelError struct {
+	Bastion string // user@host:port of bastion
+	Problem string // brief description, e.g. "authentication failed"
+	Err     error
+}
+
+// Error satisfies the builtin error interface.
+func (ste *SSHTunnelError) Error() string {
+	return fmt.Sprintf("SSH tunnel via %s: %s: %s", ste.Bastion, ste.Problem, ste.Err)
+}
+
+// sshTunnel is an SSH connection to a bastion host, through which any number
+// of database connections may be dialed.
+type sshTunnel struct {
+	sync.Mutex
+	config  SSHTunnelConfig
+	network string // name of the network registered with the mysql driver
+	client  *ssh.Client
+}
+
+var sshTunnels struct {
+	sync.Mutex
+	byConfig map[SSHTunnelConfig]*sshTunnel
+}
+
+func init() {
+	sshTunnels.byConfig = make(map[SSHTunnelConfig]*sshTunnel)
+}
+
+// SSHNetwork returns the name of a network, registered with the mysql driver,
+// which routes database connections through an SSH tunnel to the bastion
+// described by tc. This name may be used in place of "tcp" in a DSN. Tunnels
+// are pooled: all DSNs using the same bastion configuration share a single SSH
+// connection, which is only established upon the first database connection
+// attempt. Use CloseSSHTunnels to tear down all tunnels.
+func SSHNetwork(tc SSHTunnelConfig) (string, error) {
+	tc, err := tc.withDefaults()
+	if err != nil {
+		return "", err
+	}
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	if tunnel, ok := sshTunnels.byConfig[tc]; ok {
+		return tunnel.network, nil
+	}
+	tunnel := &sshTunnel{
+		config:  tc,
+		network: "skeema-ssh-" + strconv.Itoa(len(sshTunnels.byConfig)+1),
+	}
+	sshTunnels.byConfig[tc] = tunnel
+	mysql.RegisterDial(tunnel.network, tunnel.dial)
+	return tunnel.network, nil
+}
+
+// CloseSSHTunnels closes the SSH connections of all tunnels established by
+// SSHNetwork. Any database connections using them should be closed first.
+// Tunnels which are used again afterwards are re-established automatically.
+func CloseSSHTunnels() {
+	sshTunnels.Lock()
+	defer sshTunnels.Unlock()
+	for _, tunnel := range sshTunnels.byConfig {
+		tunnel.close()
+	}
+}
+
+// dial connects to addr through the tunnel, establishing the tunnel's SSH
+// connection first if necessary.
+func (tunnel *sshTunnel) dial(addr string) (net.Conn, error) {
+	client, err := tunnel.connect()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial("tcp", addr)
+	if err != nil {
+		return nil, &SSHTunnelError{Bastion: tunnel.config.String(), Problem: "unable to forward connection to " + addr, Err: err}
+	}
+	return conn, nil
+}
+
+// connect returns the tunnel's SSH client, establishing it if not already
+// connected.
+func (tunnel *sshTunnel) connect() (*ssh.Client, error) {
+	tunnel.Lock()
+	defer tunnel.Unlock()
+	if tunnel.client != nil {
+		return tunnel.client, nil
+	}
+	bastion := tunnel.config.String()
+	fail := func(problem string, err error) (*ssh.Client, error) {
+		return nil, &SSHTunnelError{Bastion: bastion, Problem: problem, Err: err}
+	}
+
+	keyBytes, err := ioutil.ReadFile(tunnel.config.KeyPath)
+	if err != nil {
+		return fail("unable to read ssh-key", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+	if err != nil {
+		return fail("unable to parse ssh-key "+tunnel.config.KeyPath, err)
+	}
+	checkKnownHost, err := knownhosts.New(tunnel.config.KnownHostsPath)
+	if err != nil {
+		return fail("unable to read ssh-known-hosts", err)
+	}
+
+	// The SSH package flattens host key errors into a string, so the original
+	// error is captured here to distinguish it from authentication failures
+	var hostKeyErr error
+	clientConfig := &ssh.ClientConfig{
+		User: tunnel.config.User,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKeyErr = checkKnownHost(hostname, remote, key)
+			return hostKeyErr
+		},
+		Timeout: 10 * time.Second,
+	}
+	client, err := ssh.Dial("tcp", tunnel.config.address(), clientConfig)
+	if ke, ok := hostKeyErr.(*knownhosts.KeyError); ok && len(ke.Want) == 0 {
+		return fail("host key verification failed", fmt.Errorf("host not found in %s", tunnel.config.KnownHostsPath))
+	} else if ok {
+		return fail("host key verification failed", fmt.Errorf("host key does not match entry in %s", tunnel.config.KnownHostsPath))
+	} else if hostKeyErr != nil {
+		return fail("host key verification failed", hostKeyErr)
+	} else if err != nil && strings.Contains(err.Error(), "unable to authenticate") {
+		return fail("authentication failed", err)
+	} else if err != nil {
+		return fail("unable to connect", err)
+	}
+	log.Debugf("Established SSH tunnel via %s", bastion)
+	tunnel.client = client
+	return client, nil
+}
+
+// close closes the tunnel's SSH connection, if any.
+func (tunnel *sshTunnel) close() {
+	tunnel.Lock()
+	defer tunnel.Unlock()
+	if tunnel.client != nil {
+		tunnel.client.Close()
+		tunnel.client = nil
+		log.Debugf("Closed SSH tunnel via %s", tunnel.config)
+	}
+}
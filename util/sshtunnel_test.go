@@ -0,0 +1,239 @@
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// generateTestKey returns a new RSA private key in PEM format, along with its
+// ssh.Signer.
+func generateTestKey(t *testing.T) ([]byte, ssh.Signer) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %s", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Unable to create signer: %s", err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return pemBytes, signer
+}
+
+// startTestSSHServer starts an SSH server on a random local port, which only
+// accepts clientKey, and supports forwarding connections. It returns the
+// server's listener, along with a counter of SSH connections established.
+func startTestSSHServer(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey) (net.Listener, *int32) {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	var established int32
+	serve := func(conn net.Conn) {
+		_, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&established, 1)
+		go ssh.DiscardRequests(reqs)
+		for newChan := range chans {
+			// direct-tcpip payload begins with destination host and port
+			payload := newChan.ExtraData()
+			hostLen := binary.BigEndian.Uint32(payload)
+			host := string(payload[4 : 4+hostLen])
+			port := binary.BigEndian.Uint32(payload[4+hostLen:])
+			dest, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+			if err != nil {
+				newChan.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			ch, chReqs, err := newChan.Accept()
+			if err != nil {
+				dest.Close()
+				continue
+			}
+			go ssh.DiscardRequests(chReqs)
+			go func() { io.Copy(ch, dest); ch.Close() }()
+			go func() { io.Copy(dest, ch); dest.Close() }()
+		}
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return listener, &established
+}
+
+// startTestEchoServer starts a TCP server on a random local port, which writes
+// back anything sent to it.
+func startTestEchoServer(t *testing.T) net.Listener {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %s", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(conn, conn); conn.Close() }()
+		}
+	}()
+	return listener
+}
+
+func TestSSHTunnel(t *testing.T) {
+	dirPath, err := ioutil.TempDir("", "skeematest")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dirPath)
+	writeFile := func(name, contents string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dirPath, name), []byte(contents), 0600); err != nil {
+			t.Fatalf("Unable to write file: %s", err)
+		}
+	}
+	_, hostKey := generateTestKey(t)
+	clientPEM, clientKey := generateTestKey(t)
+	otherPEM, _ := generateTestKey(t)
+	_, otherHostKey := generateTestKey(t)
+	writeFile("client_key", string(clientPEM))
+	writeFile("other_key", string(otherPEM))
+
+	sshListener, established := startTestSSHServer(t, hostKey, clientKey.PublicKey())
+	defer sshListener.Close()
+	echoListener := startTestEchoServer(t)
+	defer echoListener.Close()
+	sshAddr := sshListener.Addr().String()
+	writeFile("known_hosts", knownhosts.Line([]string{sshAddr}, hostKey.PublicKey())+"\n")
+	writeFile("wrong_known_hosts", knownhosts.Line([]string{sshAddr}, otherHostKey.PublicKey())+"\n")
+	writeFile("empty_known_hosts", "")
+	defer CloseSSHTunnels()
+
+	tc := SSHTunnelConfig{
+		Host:           sshAddr,
+		User:           "deploy",
+		KeyPath:        dirPath + "/client_key",
+		KnownHostsPath: dirPath + "/known_hosts",
+	}
+	network, err := SSHNetwork(tc)
+	if err != nil {
+		t.Fatalf("Unexpected error from SSHNetwork: %s", err)
+	}
+	if network2, err := SSHNetwork(tc); err != nil || network2 != network {
+		t.Errorf("Expected identical config to return same network %q, instead found %q, %v", network, network2, err)
+	}
+
+	// Multiple connections through the tunnel share one SSH connection
+	tunnel := sshTunnels.byConfig[tc]
+	for n := 0; n < 3; n++ {
+		conn, err := tunnel.dial(echoListener.Addr().String())
+		if err != nil {
+			t.Fatalf("Unexpected error dialing through tunnel: %s", err)
+		}
+		conn.Write([]byte("hello"))
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+			t.Errorf("Unexpected result reading through tunnel: %q, %v", buf, err)
+		}
+		conn.Close()
+	}
+	if count := atomic.LoadInt32(established); count != 1 {
+		t.Errorf("Expected 1 SSH connection to be established, instead found %d", count)
+	}
+
+	// After closing tunnels, they are re-established upon next use
+	CloseSSHTunnels()
+	if conn, err := tunnel.dial(echoListener.Addr().String()); err != nil {
+		t.Errorf("Unexpected error dialing through re-established tunnel: %s", err)
+	} else {
+		conn.Close()
+	}
+	if count := atomic.LoadInt32(established); count != 2 {
+		t.Errorf("Expected 2 SSH connections to be established, instead found %d", count)
+	}
+
+	// SSH problems are reported as SSHTunnelErrors describing the problem
+	cases := map[string]SSHTunnelConfig{
+		"authentication failed":          {KeyPath: dirPath + "/other_key", KnownHostsPath: dirPath + "/known_hosts"},
+		"host not found":                 {KeyPath: dirPath + "/client_key", KnownHostsPath: dirPath + "/empty_known_hosts"},
+		"host key does not match":        {KeyPath: dirPath + "/client_key", KnownHostsPath: dirPath + "/wrong_known_hosts"},
+		"unable to read ssh-key":         {KeyPath: dirPath + "/nonexistent", KnownHostsPath: dirPath + "/known_hosts"},
+		"unable to read ssh-known-hosts": {KeyPath: dirPath + "/client_key", KnownHostsPath: dirPath + "/nonexistent"},
+	}
+	for expected, badConfig := range cases {
+		badConfig.Host, badConfig.User = sshAddr, "deploy"
+		if _, err := SSHNetwork(badConfig); err != nil {
+			t.Fatalf("Unexpected error from SSHNetwork: %s", err)
+		}
+		_, err := sshTunnels.byConfig[badConfig].dial(echoListener.Addr().String())
+		if ste, ok := err.(*SSHTunnelError); !ok || !strings.Contains(ste.Error(), expected) {
+			t.Errorf("Expected SSHTunnelError containing %q, instead found %v", expected, err)
+		}
+	}
+
+	// Forwarding failures are also reported as SSHTunnelErrors
+	deadListener := startTestEchoServer(t)
+	deadAddr := deadListener.Addr().String()
+	deadListener.Close()
+	if _, err := tunnel.dial(deadAddr); err == nil {
+		t.Error("Expected error forwarding to closed port, but err was nil")
+	} else if _, ok := err.(*SSHTunnelError); !ok {
+		t.Errorf("Expected SSHTunnelError, instead found %T %v", err, err)
+	}
+}
+
+func TestSSHTunnelConfigAddress(t *testing.T) {
+	cases := map[string]string{
+		"bastion.example.com":      "bastion.example.com:22",
+		"bastion.example.com:2222": "bastion.example.com:2222",
+		"10.1.2.3":                 "10.1.2.3:22",
+		"[::1]":                    "[::1]:22",
+		"[::1]:2222":               "[::1]:2222",
+	}
+	for input, expected := range cases {
+		tc := SSHTunnelConfig{Host: input, User: "deploy"}
+		if actual := tc.address(); actual != expected {
+			t.Errorf("Expected address of %q to be %q, instead found %q", input, expected, actual)
+		}
+		if actual := tc.String(); actual != "deploy@"+expected {
+			t.Errorf("Unexpected String() for %q: %q", input, actual)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/skeema/mybase"
+	"github.com/skeema/skeema/fs"
+)
+
+// parseWorkingDir parses the current working directory, for use by commands
+// which operate on an existing directory tree. An error is returned if the
+// working directory is not part of a tree managed by Skeema, meaning no .skeema
+// file exists in it, its parent directories up to the repo base, or its
+// subdirectories. This prevents such commands from silently doing nothing
+// (or proceeding with an empty configuration) when run from the wrong place.
+func parseWorkingDir(cfg *mybase.Config) (*fs.Dir, error) {
+	dir, err := fs.ParseDir(".", cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.Managed(5) {
+		return nil, NewExitValue(CodeBadConfig, "%s is not inside a skeema-managed directory tree: no .skeema file found in this directory, its subdirectories, or its parent directories up to %s. Run this command from a directory created by `skeema init`, or run `skeema init` first.", dir, dir.RepoBase())
+	}
+	return dir, nil
+}
@@ -3,10 +3,13 @@ package workspace
 import (
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
 	"strings"
 	"sync"
+	"time"
 
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/tengo"
@@ -30,6 +33,36 @@ var cstore struct {
 	sync.Mutex
 }
 
+// dockerUnavailableError indicates that the Docker daemon could not be reached,
+// for example because it is not running. Other errors from the Docker API, such
+// as a failure to pull an image, are not wrapped in this type.
+type dockerUnavailableError struct {
+	err error
+}
+
+// Error satisfies the builtin error interface.
+func (due dockerUnavailableError) Error() string {
+	return fmt.Sprintf("Unable to use Docker for workspace: %s", due.err)
+}
+
+// isDockerUnavailable returns true if err indicates that a request to the
+// Docker daemon could not be made at all. The Docker client returns a
+// *url.Error for any failure to connect to the daemon's socket or endpoint,
+// except for refused connections, which have their own sentinel error.
+func isDockerUnavailable(err error) bool {
+	if _, ok := err.(*url.Error); ok {
+		return true
+	}
+	return err == docker.ErrConnectionRefused
+}
+
+// containerNameForImage returns the default container name to use for the
+// supplied image.
+func containerNameForImage(image string) string {
+	replacer := strings.NewReplacer(":", "-", "/", "-")
+	return fmt.Sprintf("skeema-%s", replacer.Replace(image))
+}
+
 // NewLocalDocker finds or creates a containerized MySQL instance, creates a
 // temporary schema on it, and returns it.
 func NewLocalDocker(opts Options) (ld *LocalDocker, err error) {
@@ -41,7 +74,7 @@ func NewLocalDocker(opts Options) (ld *LocalDocker, err error) {
 	defer cstore.Unlock()
 	if cstore.dockerClient == nil {
 		if cstore.dockerClient, err = tengo.NewDockerClient(tengo.DockerClientOptions{}); err != nil {
-			return
+			return nil, fmt.Errorf("Unable to configure Docker client: %s", err)
 		}
 		cstore.containers = make(map[string]*tengo.DockerizedInstance)
 		tengo.UseFilteredDriverLogger()
@@ -52,26 +85,35 @@ func NewLocalDocker(opts Options) (ld *LocalDocker, err error) {
 		cleanupAction:     opts.CleanupAction,
 		defaultConnParams: opts.DefaultConnParams,
 	}
-	image := opts.Flavor.String()
+	image := opts.Image
+	if image == "" {
+		image = opts.Flavor.String()
+	}
 	if opts.ContainerName == "" {
-		opts.ContainerName = fmt.Sprintf("skeema-%s", strings.Replace(image, ":", "-", -1))
+		opts.ContainerName = containerNameForImage(image)
 	}
 	if cstore.containers[opts.ContainerName] != nil {
 		ld.d = cstore.containers[opts.ContainerName]
 	} else {
 		log.Infof("Using container %s (image=%s) for workspace operations", opts.ContainerName, image)
-		ld.d, err = cstore.dockerClient.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
+		ld.d, err = getOrCreateContainer(tengo.DockerizedInstanceOptions{
 			Name:              opts.ContainerName,
 			Image:             image,
 			RootPassword:      opts.RootPassword,
 			DefaultConnParams: "", // intentionally not set here; see important comment in ConnectionPool()
-		})
+		}, opts.StartupTimeout)
+		// Any container that was created is tracked, even if its database did not
+		// accept connections in time, so that docker-cleanup still applies to it
 		if ld.d != nil {
-			cstore.containers[opts.ContainerName] = ld.d
 			RegisterShutdownFunc(ld.shutdown)
+			if ld.d.Instance != nil {
+				cstore.containers[opts.ContainerName] = ld.d
+			}
 		}
-		if err != nil {
-			return nil, err
+		if isDockerUnavailable(err) {
+			return nil, dockerUnavailableError{err}
+		} else if err != nil {
+			return nil, fmt.Errorf("Unable to obtain container %s for workspace: %s", opts.ContainerName, err)
 		}
 	}
 
@@ -115,6 +157,44 @@ func NewLocalDocker(opts Options) (ld *LocalDocker, err error) {
 	return ld, nil
 }
 
+// getOrCreateContainer wraps tengo's DockerClient.GetOrCreateInstance, retrying
+// until the containerized database accepts connections or timeout elapses. A
+// timeout of 0 means 30 seconds. Attempts run synchronously, since tengo's
+// calls cannot be interrupted; the initial call to GetOrCreateInstance may
+// itself wait up to 30 seconds for the database, so timeout is only checked
+// between attempts. If a container was created or found, it is returned even
+// alongside a non-nil error, so that the caller can track it for shutdown. The
+// caller must hold cstore's lock.
+func getOrCreateContainer(opts tengo.DockerizedInstanceOptions, timeout time.Duration) (*tengo.DockerizedInstance, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	di, err := cstore.dockerClient.GetOrCreateInstance(opts)
+	for err != nil {
+		// Only connection failures are retried; other errors, such as failure to
+		// pull an image, are returned immediately
+		_, isNetErr := err.(net.Error)
+		if isDockerUnavailable(err) || (di == nil && !isNetErr) || (di != nil && di.Instance == nil) {
+			return di, err
+		}
+		if !time.Now().Before(deadline) {
+			return di, fmt.Errorf("database did not accept connections within %s (configurable via docker-startup-timeout): %s", timeout, err)
+		}
+		time.Sleep(containerRetryInterval)
+		if di == nil {
+			di, err = cstore.dockerClient.GetOrCreateInstance(opts)
+		} else {
+			_, err = di.Instance.CanConnect()
+		}
+	}
+	return di, nil
+}
+
+// containerRetryInterval is the delay between attempts to connect to a
+// containerized database which is still starting up.
+const containerRetryInterval = 250 * time.Millisecond
+
 // ConnectionPool returns a connection pool (*sqlx.DB) to the temporary
 // workspace schema, using the supplied connection params (which may be blank).
 func (ld *LocalDocker) ConnectionPool(params string) (*sqlx.DB, error) {
@@ -1,12 +1,33 @@
 package workspace
 
 import (
+	"errors"
+	"net"
+	"net/url"
 	"testing"
 	"time"
 
+	docker "github.com/fsouza/go-dockerclient"
 	"github.com/skeema/tengo"
 )
 
+func TestIsDockerUnavailable(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "unix", Err: errors.New("no such file or directory")}
+	cases := map[error]bool{
+		nil: false,
+		&url.Error{Op: "Get", URL: "http://unix.sock/containers/skeema-mysql-5.7/json", Err: dialErr}: true,
+		docker.ErrConnectionRefused:                                                 true,
+		errors.New("cannot connect to Docker endpoint"):                             false,
+		&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}: false,
+		errors.New("API error (404): manifest for mysql:9.9 not found"):             false,
+	}
+	for err, expected := range cases {
+		if actual := isDockerUnavailable(err); actual != expected {
+			t.Errorf("Expected isDockerUnavailable(%v) to return %t, instead found %t", err, expected, actual)
+		}
+	}
+}
+
 func (s WorkspaceIntegrationSuite) TestLocalDockerErrors(t *testing.T) {
 	opts := Options{
 		Type:                TypeLocalDocker,
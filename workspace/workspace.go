@@ -18,6 +18,7 @@ import (
 	"github.com/nozzle/throttler"
 	log "github.com/sirupsen/logrus"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
 
@@ -78,12 +79,15 @@ type Options struct {
 	Instance            *tengo.Instance // only TypeTempSchema
 	Flavor              tengo.Flavor    // only TypeLocalDocker
 	ContainerName       string          // only TypeLocalDocker
+	Image               string          // only TypeLocalDocker; blank means derive from Flavor
 	SchemaName          string
 	DefaultCharacterSet string
 	DefaultCollation    string
-	DefaultConnParams   string    // only TypeLocalDocker
-	RootPassword        string    // only TypeLocalDocker
-	PrefabWorkspace     Workspace // only TypePrefab
+	DefaultConnParams   string        // only TypeLocalDocker
+	RootPassword        string        // only TypeLocalDocker
+	PrefabWorkspace     Workspace     // only TypePrefab
+	Fallback            *Options      // only TypeLocalDocker; used if Docker unavailable
+	StartupTimeout      time.Duration // only TypeLocalDocker; 0 means 30 seconds
 	LockWaitTimeout     time.Duration
 	Concurrency         int
 	SkipBinlog          bool
@@ -96,7 +100,14 @@ func New(opts Options) (Workspace, error) {
 	case TypeTempSchema:
 		return NewTempSchema(opts)
 	case TypeLocalDocker:
-		return NewLocalDocker(opts)
+		ld, err := NewLocalDocker(opts)
+		if _, unavailable := err.(dockerUnavailableError); unavailable && opts.Fallback != nil {
+			log.Warnf("%s; falling back to workspace=temp-schema as configured via docker-fallback", err)
+			return New(*opts.Fallback)
+		} else if err != nil {
+			return nil, err
+		}
+		return ld, nil
 	case TypePrefab:
 		return opts.PrefabWorkspace, nil
 	}
@@ -108,7 +119,8 @@ func New(opts Options) (Workspace, error) {
 // workspace won't be temp-schema based.
 // This method relies on option definitions from util.AddGlobalOptions(),
 // including "workspace", "temp-schema", "flavor", "docker-cleanup",
-// "reuse-temp-schema", "temp-schema-threads", "temp-schema-binlog"
+// "docker-image", "docker-fallback", "docker-startup-timeout", "reuse-temp-schema",
+// "temp-schema-threads", "temp-schema-binlog"
 func OptionsForDir(dir *fs.Dir, instance *tengo.Instance) (Options, error) {
 	requestedType, err := dir.Config.GetEnum("workspace", "temp-schema", "docker")
 	if err != nil {
@@ -127,7 +139,16 @@ func OptionsForDir(dir *fs.Dir, instance *tengo.Instance) (Options, error) {
 		if !opts.Flavor.Known() && instance != nil {
 			opts.Flavor = instance.Flavor()
 		}
-		opts.ContainerName = fmt.Sprintf("skeema-%s", strings.Replace(opts.Flavor.String(), ":", "-", -1))
+		opts.Image = dir.Config.Get("docker-image")
+		if opts.Image == "" {
+			opts.Image = opts.Flavor.String()
+		}
+		opts.ContainerName = containerNameForImage(opts.Image)
+		if opts.StartupTimeout, err = util.ParseTimeout("docker-startup-timeout", dir.Config.Get("docker-startup-timeout")); err != nil {
+			return Options{}, err
+		} else if opts.StartupTimeout == 0 {
+			return Options{}, errors.New("Option docker-startup-timeout must be greater than 0")
+		}
 		if cleanup, err := dir.Config.GetEnum("docker-cleanup", "none", "stop", "destroy"); err != nil {
 			return Options{}, err
 		} else if cleanup == "stop" {
@@ -138,28 +159,45 @@ func OptionsForDir(dir *fs.Dir, instance *tengo.Instance) (Options, error) {
 		if opts.DefaultConnParams, err = dir.InstanceDefaultParams(); err != nil {
 			return Options{}, err
 		}
-	} else {
-		opts.Type = TypeTempSchema
-		opts.Instance = instance
-		if !dir.Config.GetBool("reuse-temp-schema") {
-			opts.CleanupAction = CleanupActionDrop
-		}
-		if concurrency, err := dir.Config.GetInt("temp-schema-threads"); err != nil {
-			return Options{}, err
-		} else if concurrency < 1 {
-			return Options{}, errors.New("temp-schema-threads cannot be less than 1")
-		} else {
-			opts.Concurrency = concurrency
+		if dir.Config.GetBool("docker-fallback") && instance != nil {
+			fallback, err := tempSchemaOptionsForDir(dir, instance, opts)
+			if err != nil {
+				return Options{}, err
+			}
+			opts.Fallback = &fallback
 		}
-		binlogEnum, err := dir.Config.GetEnum("temp-schema-binlog", "on", "off", "auto")
-		if err != nil {
+	} else {
+		if opts, err = tempSchemaOptionsForDir(dir, instance, opts); err != nil {
 			return Options{}, err
 		}
-		opts.SkipBinlog = (binlogEnum == "off" || (binlogEnum == "auto" && instance.CanSkipBinlog()))
+	}
+	return opts, nil
+}
 
-		// Note: no support for opts.DefaultConnParams for temp-schema because the
-		// supplied instance already has default params
+// tempSchemaOptionsForDir returns a copy of base, modified to use a temporary
+// schema on the supplied instance, configured based on dir.
+func tempSchemaOptionsForDir(dir *fs.Dir, instance *tengo.Instance, base Options) (Options, error) {
+	opts := base
+	opts.Type = TypeTempSchema
+	opts.Instance = instance
+	opts.CleanupAction = CleanupActionNone
+	opts.DefaultConnParams = "" // supplied instance already has default params
+	opts.Fallback = nil
+	if !dir.Config.GetBool("reuse-temp-schema") {
+		opts.CleanupAction = CleanupActionDrop
 	}
+	if concurrency, err := dir.Config.GetInt("temp-schema-threads"); err != nil {
+		return Options{}, err
+	} else if concurrency < 1 {
+		return Options{}, errors.New("temp-schema-threads cannot be less than 1")
+	} else {
+		opts.Concurrency = concurrency
+	}
+	binlogEnum, err := dir.Config.GetEnum("temp-schema-binlog", "on", "off", "auto")
+	if err != nil {
+		return Options{}, err
+	}
+	opts.SkipBinlog = (binlogEnum == "off" || (binlogEnum == "auto" && instance.CanSkipBinlog()))
 	return opts, nil
 }
 
@@ -230,6 +268,39 @@ type Schema struct {
 	*tengo.Schema
 	LogicalSchema *fs.LogicalSchema
 	Failures      []*StatementError
+	Variables     map[string]string // global values of DDLVariables in the workspace; nil if unknown
+}
+
+// DDLVariables lists global server variables which affect the semantics of
+// DDL. If these differ between a workspace and a target, DDL that succeeded in
+// the workspace may fail or behave differently on the target.
+var DDLVariables = []string{
+	"default_storage_engine",
+	"explicit_defaults_for_timestamp",
+	"foreign_key_checks",
+	"innodb_file_per_table",
+	"innodb_strict_mode",
+}
+
+// ServerVariables returns the global values of DDLVariables from the server
+// that db is connected to. Variables which do not exist in the server's flavor
+// are omitted from the result.
+func ServerVariables(db *sqlx.DB) (map[string]string, error) {
+	query := fmt.Sprintf("SHOW GLOBAL VARIABLES WHERE Variable_name IN ('%s')", strings.Join(DDLVariables, "', '"))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make(map[string]string, len(DDLVariables))
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		result[strings.ToLower(name)] = value
+	}
+	return result, rows.Err()
 }
 
 // FailedKeys returns a slice of tengo.ObjectKey values corresponding to
@@ -316,6 +387,14 @@ func ExecLogicalSchema(logicalSchema *fs.LogicalSchema, opts Options) (wsSchema
 		}
 	}
 
+	// Record server variables affecting DDL, for comparison against targets.
+	// Failure here is not fatal, since it only prevents that comparison.
+	if db, err := ws.ConnectionPool(""); err == nil {
+		if wsSchema.Variables, err = ServerVariables(db); err != nil {
+			log.Debugf("Unable to query workspace server variables: %s", err)
+		}
+	}
+
 	wsSchema.Schema, fatalErr = ws.IntrospectSchema()
 	return
 }
@@ -362,6 +441,8 @@ func wrapFailure(statement *fs.Statement, err error) *StatementError {
 		stmtErr.Err = fmt.Errorf("SQL syntax error: %s", err)
 	} else if tengo.IsDatabaseError(err, mysqlerr.ER_LOCK_DEADLOCK) {
 		stmtErr.Err = err // Need to maintain original type
+	} else if tengo.IsDatabaseError(err, mysqlerr.ER_SPATIAL_CANT_HAVE_NULL) {
+		stmtErr.Err = fmt.Errorf("Error executing DDL in workspace: %s. Columns used in a SPATIAL index must be declared NOT NULL, and on MySQL 8 should also specify an SRID attribute so that the index can be used by the optimizer", err)
 	} else {
 		stmtErr.Err = fmt.Errorf("Error executing DDL in workspace: %s", err)
 	}
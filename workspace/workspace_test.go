@@ -9,6 +9,7 @@ import (
 
 	"github.com/skeema/mybase"
 	"github.com/skeema/skeema/fs"
+	"github.com/skeema/skeema/testutil"
 	"github.com/skeema/skeema/util"
 	"github.com/skeema/tengo"
 )
@@ -22,23 +23,11 @@ func TestMain(m *testing.M) {
 }
 
 func TestIntegration(t *testing.T) {
-	images := tengo.SplitEnv("SKEEMA_TEST_IMAGES")
-	if len(images) == 0 {
-		fmt.Println("SKEEMA_TEST_IMAGES env var is not set, so integration tests will be skipped!")
-		fmt.Println("To run integration tests, you may set SKEEMA_TEST_IMAGES to a comma-separated")
-		fmt.Println("list of Docker images. Example:\n# SKEEMA_TEST_IMAGES=\"mysql:5.6,mysql:5.7\" go test")
-	}
-	manager, err := tengo.NewDockerClient(tengo.DockerClientOptions{})
-	if err != nil {
-		t.Errorf("Unable to create sandbox manager: %s", err)
-	}
-	suite := &WorkspaceIntegrationSuite{manager: manager}
-	tengo.RunSuite(suite, t, images)
+	testutil.RunSuite(t, &WorkspaceIntegrationSuite{})
 }
 
 type WorkspaceIntegrationSuite struct {
-	manager *tengo.DockerClient
-	d       *tengo.DockerizedInstance
+	d *tengo.DockerizedInstance
 }
 
 func (s WorkspaceIntegrationSuite) TestExecLogicalSchema(t *testing.T) {
@@ -63,6 +52,9 @@ func (s WorkspaceIntegrationSuite) TestExecLogicalSchema(t *testing.T) {
 	if len(wsSchema.Tables) < 4 {
 		t.Errorf("Expected at least 4 tables, but instead found %d", len(wsSchema.Tables))
 	}
+	if engine := wsSchema.Variables["default_storage_engine"]; engine != "InnoDB" {
+		t.Errorf("Expected workspace default_storage_engine to be InnoDB, instead found %q", engine)
+	}
 
 	// Test with a valid ALTER involved
 	oldUserColumnCount := len(wsSchema.Table("users").Columns)
@@ -200,6 +192,8 @@ func (s WorkspaceIntegrationSuite) TestOptionsForDir(t *testing.T) {
 	assertOptsError("--workspace=invalid")
 	assertOptsError("--workspace=docker --docker-cleanup=invalid")
 	assertOptsError("--workspace=docker --connect-options='autocommit=0'")
+	assertOptsError("--workspace=docker --docker-startup-timeout=0")
+	assertOptsError("--workspace=docker --docker-startup-timeout=soon")
 	assertOptsError("--workspace=temp-schema --temp-schema-threads=0")
 	assertOptsError("--workspace=temp-schema --temp-schema-threads=-20")
 	assertOptsError("--workspace=temp-schema --temp-schema-threads=banana")
@@ -219,7 +213,10 @@ func (s WorkspaceIntegrationSuite) TestOptionsForDir(t *testing.T) {
 	// Test docker with defaults, which should have no cleanup action, and match
 	// flavor of suite's DockerizedInstance
 	opts = getOpts("--workspace=docker")
-	if opts.Type != TypeLocalDocker || opts.CleanupAction != CleanupActionNone || opts.Flavor != s.d.Flavor() {
+	if opts.Type != TypeLocalDocker || opts.CleanupAction != CleanupActionNone || opts.Flavor != s.d.Flavor() || opts.StartupTimeout != 30*time.Second {
+		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
+	}
+	if opts = getOpts("--workspace=docker --docker-startup-timeout=2m"); opts.StartupTimeout != 2*time.Minute {
 		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
 	}
 
@@ -232,9 +229,26 @@ func (s WorkspaceIntegrationSuite) TestOptionsForDir(t *testing.T) {
 	}
 
 	// Test docker with specific flavor
-	if opts = getOpts("--workspace=docker --flavor=mysql:5.5"); opts.Flavor.String() != "mysql:5.5" {
+	if opts = getOpts("--workspace=docker --flavor=mysql:5.5"); opts.Flavor.String() != "mysql:5.5" || opts.Image != "mysql:5.5" {
 		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
 	}
+
+	// Test docker with image override
+	opts = getOpts("--workspace=docker --flavor=mysql:5.7 --docker-image=mirror.example.com/mysql:5.7.30")
+	if opts.Flavor.String() != "mysql:5.7" || opts.Image != "mirror.example.com/mysql:5.7.30" || opts.ContainerName != "skeema-mirror.example.com-mysql-5.7.30" {
+		t.Errorf("Unexpected return from OptionsForDir: %+v", opts)
+	}
+
+	// Test docker with fallback to temp-schema
+	if opts = getOpts("--workspace=docker"); opts.Fallback != nil {
+		t.Errorf("Expected no fallback by default, instead found %+v", *opts.Fallback)
+	}
+	opts = getOpts("--workspace=docker --docker-fallback --reuse-temp-schema")
+	if opts.Fallback == nil {
+		t.Error("Expected fallback options to be set, but Fallback is nil")
+	} else if fb := opts.Fallback; fb.Type != TypeTempSchema || fb.Instance != s.d.Instance || fb.CleanupAction != CleanupActionNone || fb.Fallback != nil {
+		t.Errorf("Unexpected fallback options: %+v", *fb)
+	}
 }
 
 // TestPrefab confirms that ExecLogicalSchema still functions properly with a
@@ -286,11 +300,7 @@ func (s WorkspaceIntegrationSuite) TestPrefab(t *testing.T) {
 }
 
 func (s *WorkspaceIntegrationSuite) Setup(backend string) (err error) {
-	s.d, err = s.manager.GetOrCreateInstance(tengo.DockerizedInstanceOptions{
-		Name:         fmt.Sprintf("skeema-test-%s", strings.Replace(backend, ":", "-", -1)),
-		Image:        backend,
-		RootPassword: "fakepw",
-	})
+	s.d, err = testutil.DockerInstance(backend, "")
 	return err
 }
 